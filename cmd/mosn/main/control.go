@@ -134,6 +134,10 @@ var (
 				Name:  "drain-time-s",
 				Usage: "seconds to drain connections, default 600 seconds",
 				Value: 600,
+			}, cli.IntFlag{
+				Name:  "health-check-fail-time-s",
+				Usage: "seconds to fail the health check before draining connections, default 0 seconds",
+				Value: 0,
 			}, cli.StringFlag{
 				Name:  "parent-shutdown-time-s",
 				Usage: "parent shutdown time seconds, align to Istio startup params, currently useless",
@@ -169,6 +173,8 @@ var (
 			stm.AppendInitStage(func(cfg *v2.MOSNConfig) {
 				drainTime := c.Int("drain-time-s")
 				server.SetDrainTime(time.Duration(drainTime) * time.Second)
+				healthCheckFailTime := c.Int("health-check-fail-time-s")
+				server.SetHealthCheckFailTime(time.Duration(healthCheckFailTime) * time.Second)
 				// istio parameters
 				serviceCluster := c.String("service-cluster")
 				serviceNode := c.String("service-node")
@@ -205,6 +211,8 @@ var (
 			stm.AppendPreStartStage(mosn.DefaultPreStartStage) // called finally stage by default
 			// startup
 			stm.AppendStartStage(mosn.DefaultStartStage)
+			// before-stop
+			stm.AppendBeforeStopStage(mosn.DefaultBeforeStopStage)
 			// after-stop
 			stm.AppendAfterStopStage(holmes.Stop)
 			// execute all stages
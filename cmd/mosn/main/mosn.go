@@ -39,22 +39,35 @@ import (
 	_ "mosn.io/mosn/pkg/filter/network/proxy"
 	_ "mosn.io/mosn/pkg/filter/network/streamproxy"
 	_ "mosn.io/mosn/pkg/filter/network/tunnel"
+	_ "mosn.io/mosn/pkg/filter/stream/cors"
+	_ "mosn.io/mosn/pkg/filter/stream/decompress"
 	_ "mosn.io/mosn/pkg/filter/stream/dsl"
 	_ "mosn.io/mosn/pkg/filter/stream/dubbo"
+	_ "mosn.io/mosn/pkg/filter/stream/extauthz"
 	_ "mosn.io/mosn/pkg/filter/stream/faultinject"
 	_ "mosn.io/mosn/pkg/filter/stream/faulttolerance"
 	_ "mosn.io/mosn/pkg/filter/stream/flowcontrol"
 	_ "mosn.io/mosn/pkg/filter/stream/grpcmetric"
+	_ "mosn.io/mosn/pkg/filter/stream/grpcweb"
 	_ "mosn.io/mosn/pkg/filter/stream/gzip"
 	_ "mosn.io/mosn/pkg/filter/stream/headertometadata"
 	_ "mosn.io/mosn/pkg/filter/stream/ipaccess"
+	_ "mosn.io/mosn/pkg/filter/stream/jsonschema"
+	_ "mosn.io/mosn/pkg/filter/stream/jwtauth"
+	_ "mosn.io/mosn/pkg/filter/stream/localratelimit"
 	_ "mosn.io/mosn/pkg/filter/stream/mirror"
 	_ "mosn.io/mosn/pkg/filter/stream/payloadlimit"
 	_ "mosn.io/mosn/pkg/filter/stream/proxywasm"
+	_ "mosn.io/mosn/pkg/filter/stream/ratelimit"
+	_ "mosn.io/mosn/pkg/filter/stream/responsecache"
 	_ "mosn.io/mosn/pkg/filter/stream/seata"
+	_ "mosn.io/mosn/pkg/filter/stream/spiffeauthz"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2dubbo"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/httpconv"
+	_ "mosn.io/mosn/pkg/filter/stream/transform"
 	_ "mosn.io/mosn/pkg/metrics/sink"
+	_ "mosn.io/mosn/pkg/metrics/sink/otlp"
 	_ "mosn.io/mosn/pkg/metrics/sink/prometheus"
 	_ "mosn.io/mosn/pkg/network"
 	_ "mosn.io/mosn/pkg/protocol"
@@ -65,11 +78,13 @@ import (
 	_ "mosn.io/mosn/pkg/stream/http2"
 	_ "mosn.io/mosn/pkg/stream/xprotocol"
 	_ "mosn.io/mosn/pkg/trace/jaeger"
+	_ "mosn.io/mosn/pkg/trace/otlp"
 	_ "mosn.io/mosn/pkg/trace/skywalking"
 	_ "mosn.io/mosn/pkg/trace/skywalking/http"
 	_ "mosn.io/mosn/pkg/trace/sofa/http"
 	_ "mosn.io/mosn/pkg/trace/sofa/xprotocol"
 	_ "mosn.io/mosn/pkg/trace/sofa/xprotocol/bolt"
+	_ "mosn.io/mosn/pkg/trace/w3c"
 	_ "mosn.io/mosn/pkg/upstream/healthcheck"
 	_ "mosn.io/mosn/pkg/upstream/servicediscovery/dubbod"
 	_ "mosn.io/mosn/pkg/wasm/abi/proxywasm010"
@@ -127,7 +127,8 @@ func ConvertEndpointsConfig(xdsEndpoint *envoy_config_endpoint_v3.LocalityLbEndp
 		}
 		host := v2.Host{
 			HostConfig: v2.HostConfig{
-				Address: address,
+				Address:      address,
+				HealthStatus: convertHealthStatus(xdsHost.GetHealthStatus()),
 			},
 			MetaData: convertMeta(xdsHost.Metadata),
 		}
@@ -147,6 +148,21 @@ func ConvertEndpointsConfig(xdsEndpoint *envoy_config_endpoint_v3.LocalityLbEndp
 	return hosts
 }
 
+// convertHealthStatus converts the endpoint's health status reported by the control plane,
+// the timeout endpoint is treated as unhealthy, and the degraded endpoint is still available
+func convertHealthStatus(xdsHealthStatus envoy_config_core_v3.HealthStatus) v2.HostHealthStatus {
+	switch xdsHealthStatus {
+	case envoy_config_core_v3.HealthStatus_HEALTHY, envoy_config_core_v3.HealthStatus_DEGRADED:
+		return v2.HostHealthy
+	case envoy_config_core_v3.HealthStatus_UNHEALTHY, envoy_config_core_v3.HealthStatus_TIMEOUT:
+		return v2.HostUnhealthy
+	case envoy_config_core_v3.HealthStatus_DRAINING:
+		return v2.HostDraining
+	default:
+		return ""
+	}
+}
+
 func convertWeightedClusters(xdsWeightedClusters *envoy_config_route_v3.WeightedCluster) []v2.WeightedCluster {
 	if xdsWeightedClusters == nil {
 		return nil
@@ -201,6 +201,154 @@ func Test_convertEndpointsConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "health status",
+			args: args{
+				xdsEndpoint: &envoy_config_endpoint_v3.LocalityLbEndpoints{
+					LbEndpoints: []*envoy_config_endpoint_v3.LbEndpoint{
+						{
+							HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+								Endpoint: &envoy_config_endpoint_v3.Endpoint{
+									Address: &envoy_config_core_v3.Address{
+										Address: &envoy_config_core_v3.Address_SocketAddress{
+											SocketAddress: &envoy_config_core_v3.SocketAddress{
+												Address: "192.168.0.1",
+												PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{
+													PortValue: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+							HealthStatus: envoy_config_core_v3.HealthStatus_UNKNOWN,
+						},
+						{
+							HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+								Endpoint: &envoy_config_endpoint_v3.Endpoint{
+									Address: &envoy_config_core_v3.Address{
+										Address: &envoy_config_core_v3.Address_SocketAddress{
+											SocketAddress: &envoy_config_core_v3.SocketAddress{
+												Address: "192.168.0.2",
+												PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{
+													PortValue: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+							HealthStatus: envoy_config_core_v3.HealthStatus_HEALTHY,
+						},
+						{
+							HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+								Endpoint: &envoy_config_endpoint_v3.Endpoint{
+									Address: &envoy_config_core_v3.Address{
+										Address: &envoy_config_core_v3.Address_SocketAddress{
+											SocketAddress: &envoy_config_core_v3.SocketAddress{
+												Address: "192.168.0.3",
+												PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{
+													PortValue: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+							HealthStatus: envoy_config_core_v3.HealthStatus_UNHEALTHY,
+						},
+						{
+							HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+								Endpoint: &envoy_config_endpoint_v3.Endpoint{
+									Address: &envoy_config_core_v3.Address{
+										Address: &envoy_config_core_v3.Address_SocketAddress{
+											SocketAddress: &envoy_config_core_v3.SocketAddress{
+												Address: "192.168.0.4",
+												PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{
+													PortValue: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+							HealthStatus: envoy_config_core_v3.HealthStatus_DRAINING,
+						},
+						{
+							HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+								Endpoint: &envoy_config_endpoint_v3.Endpoint{
+									Address: &envoy_config_core_v3.Address{
+										Address: &envoy_config_core_v3.Address_SocketAddress{
+											SocketAddress: &envoy_config_core_v3.SocketAddress{
+												Address: "192.168.0.5",
+												PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{
+													PortValue: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+							HealthStatus: envoy_config_core_v3.HealthStatus_TIMEOUT,
+						},
+						{
+							HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+								Endpoint: &envoy_config_endpoint_v3.Endpoint{
+									Address: &envoy_config_core_v3.Address{
+										Address: &envoy_config_core_v3.Address_SocketAddress{
+											SocketAddress: &envoy_config_core_v3.SocketAddress{
+												Address: "192.168.0.6",
+												PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{
+													PortValue: 80,
+												},
+											},
+										},
+									},
+								},
+							},
+							HealthStatus: envoy_config_core_v3.HealthStatus_DEGRADED,
+						},
+					},
+				},
+			},
+			want: []v2.Host{
+				{
+					HostConfig: v2.HostConfig{
+						Address: "192.168.0.1:80",
+					},
+				},
+				{
+					HostConfig: v2.HostConfig{
+						Address:      "192.168.0.2:80",
+						HealthStatus: v2.HostHealthy,
+					},
+				},
+				{
+					HostConfig: v2.HostConfig{
+						Address:      "192.168.0.3:80",
+						HealthStatus: v2.HostUnhealthy,
+					},
+				},
+				{
+					HostConfig: v2.HostConfig{
+						Address:      "192.168.0.4:80",
+						HealthStatus: v2.HostDraining,
+					},
+				},
+				{
+					HostConfig: v2.HostConfig{
+						Address:      "192.168.0.5:80",
+						HealthStatus: v2.HostUnhealthy,
+					},
+				},
+				{
+					HostConfig: v2.HostConfig{
+						Address:      "192.168.0.6:80",
+						HealthStatus: v2.HostHealthy,
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -19,16 +19,27 @@ package server
 
 import (
 	"bytes"
+	"context"
 	rawjson "encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/router"
+	mosnserver "mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/variable"
 )
 
 func TestKnownFeatures(t *testing.T) {
@@ -245,3 +256,296 @@ func TestVersion(t *testing.T) {
 		t.Fatalf("expectation failure: %v", err)
 	}
 }
+
+func TestDrainProgressAndHealthCheck(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://127.0.0.1/api/v1/health", nil)
+	w := httptest.NewRecorder()
+	HealthCheck(w, r)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("health check status got %d", w.Result().StatusCode)
+	}
+	r = httptest.NewRequest("GET", "http://127.0.0.1/api/v1/drain", nil)
+	w = httptest.NewRecorder()
+	GetDrainProgress(w, r)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("drain progress status got %d", w.Result().StatusCode)
+	}
+	progress := &mosnserver.DrainProgress{}
+	if err := rawjson.Unmarshal(w.Body.Bytes(), progress); err != nil {
+		t.Fatalf("drain progress unmarshal error: %v", err)
+	}
+	if progress.State != mosnserver.DrainNotStarted {
+		t.Fatalf("drain state got %s", progress.State)
+	}
+	// no servers, drain finished immediately
+	if err := mosnserver.DrainListeners(); err != nil {
+		t.Fatalf("drain listeners error: %v", err)
+	}
+	w = httptest.NewRecorder()
+	HealthCheck(w, r)
+	if w.Result().StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("health check status got %d", w.Result().StatusCode)
+	}
+	w = httptest.NewRecorder()
+	GetDrainProgress(w, r)
+	rawjson.Unmarshal(w.Body.Bytes(), progress)
+	if progress.State != mosnserver.DrainDrained {
+		t.Fatalf("drain state got %s", progress.State)
+	}
+}
+
+func TestDumpConnectionsAndStreams(t *testing.T) {
+	for _, f := range []func(w http.ResponseWriter, r *http.Request){
+		DumpConnections,
+		DumpStreams,
+	} {
+		r := httptest.NewRequest("POST", "http://127.0.0.1/api/v1/connections", nil)
+		w := httptest.NewRecorder()
+		f(w, r)
+		if w.Result().StatusCode != http.StatusMethodNotAllowed {
+			t.Fatalf("response status got %d", w.Result().StatusCode)
+		}
+		r = httptest.NewRequest("GET", "http://127.0.0.1/api/v1/connections", nil)
+		w = httptest.NewRecorder()
+		f(w, r)
+		if w.Result().StatusCode != http.StatusOK {
+			t.Fatalf("response status got %d", w.Result().StatusCode)
+		}
+		// no active connections, returns an empty list
+		out := []map[string]interface{}{}
+		if err := rawjson.Unmarshal(w.Body.Bytes(), &out); err != nil || len(out) != 0 {
+			t.Fatalf("unexpected response: %s, error: %v", w.Body.String(), err)
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	// restore the loggers' level
+	origin := log.GetErrorLoggersInfo()
+	defer func() {
+		for p, level := range origin {
+			log.UpdateErrorLoggerLevel(p, levelMap[level])
+		}
+	}()
+	logName := "/tmp/mosn_admin/test_admin_logging.log"
+	os.Remove(logName)
+	logger, err := log.GetOrCreateDefaultErrorLogger(logName, log.INFO)
+	if err != nil {
+		t.Fatal("create logger failed")
+	}
+	logging := func(method string, query string) (int, map[string]string) {
+		r := httptest.NewRequest(method, "http://127.0.0.1/api/v1/logging?"+query, nil)
+		w := httptest.NewRecorder()
+		Logging(w, r)
+		levels := map[string]string{}
+		rawjson.Unmarshal(w.Body.Bytes(), &levels)
+		return w.Result().StatusCode, levels
+	}
+	logger.Debugf("debug before update")
+	// update a logger
+	code, levels := logging("POST", "level=debug&logger="+logName)
+	if code != http.StatusOK || !reflect.DeepEqual(levels, map[string]string{logName: "DEBUG"}) {
+		t.Fatalf("update logger level failed, status: %d, levels: %v", code, levels)
+	}
+	logger.Debugf("debug after update")
+	// update all loggers
+	code, levels = logging("POST", "level=ERROR")
+	if code != http.StatusOK || levels[logName] != "ERROR" || len(levels) != len(log.GetErrorLoggersInfo()) {
+		t.Fatalf("update all loggers level failed, status: %d, levels: %v", code, levels)
+	}
+	logger.Infof("info after update all")
+	logger.Errorf("error after update all")
+	// query
+	code, levels = logging("GET", "logger="+logName)
+	if code != http.StatusOK || !reflect.DeepEqual(levels, map[string]string{logName: "ERROR"}) {
+		t.Fatalf("query logger level failed, status: %d, levels: %v", code, levels)
+	}
+	code, levels = logging("GET", "")
+	if code != http.StatusOK || levels[logName] != "ERROR" {
+		t.Fatalf("query loggers level failed, status: %d, levels: %v", code, levels)
+	}
+	// invalid requests
+	if code, _ := logging("POST", "level=verbose"); code != http.StatusBadRequest {
+		t.Fatalf("invalid level expected bad request, but got %d", code)
+	}
+	if code, _ := logging("POST", "level=debug&logger=/tmp/mosn_admin/not_exists.log"); code != http.StatusNotFound {
+		t.Fatalf("unknown logger expected not found, but got %d", code)
+	}
+	if code, _ := logging("GET", "logger=/tmp/mosn_admin/not_exists.log"); code != http.StatusNotFound {
+		t.Fatalf("unknown logger expected not found, but got %d", code)
+	}
+	if code, _ := logging("PUT", ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("invalid method expected method not allowed, but got %d", code)
+	}
+	if logger.GetLogLevel() != log.ERROR {
+		t.Fatalf("logger level is not expected: %v", logger.GetLogLevel())
+	}
+	// verify the logging respects the level
+	time.Sleep(time.Second) // wait flush
+	lines, err := readLines(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "debug after update") || !strings.HasSuffix(lines[1], "error after update all") {
+		t.Fatalf("log write data is not expected: %v", lines)
+	}
+}
+
+func TestClusterMaintenance(t *testing.T) {
+	cluster.NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:        "maintenance_cluster",
+			ClusterType: v2.SIMPLE_CLUSTER,
+			LbType:      v2.LB_RANDOM,
+		},
+	}, nil, nil)
+	defer cluster.GetClusterMngAdapterInstance().Destroy()
+
+	request := func(method, url string) (int, *ClusterMaintenanceData) {
+		r := httptest.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		ClusterMaintenance(w, r)
+		data := &ClusterMaintenanceData{}
+		if w.Result().StatusCode == http.StatusOK {
+			if err := rawjson.Unmarshal(w.Body.Bytes(), data); err != nil {
+				t.Fatalf("unmarshal response error: %v", err)
+			}
+		}
+		return w.Result().StatusCode, data
+	}
+	maintenance := func() bool {
+		snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), "maintenance_cluster")
+		return snapshot.ClusterInfo().(types.MaintenanceModeCluster).MaintenanceMode()
+	}
+
+	code, data := request("GET", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/maintenance")
+	if code != http.StatusOK || data.Cluster != "maintenance_cluster" || data.MaintenanceMode {
+		t.Fatalf("unexpected response: %d, %+v", code, data)
+	}
+	code, data = request("POST", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/maintenance?enable=true")
+	if code != http.StatusOK || !data.MaintenanceMode || !maintenance() {
+		t.Fatalf("enable maintenance mode failed: %d, %+v", code, data)
+	}
+	code, data = request("POST", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/maintenance?enable=false")
+	if code != http.StatusOK || data.MaintenanceMode || maintenance() {
+		t.Fatalf("disable maintenance mode failed: %d, %+v", code, data)
+	}
+
+	// the maintenance mode is reset when the cluster config is updated
+	request("POST", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/maintenance?enable=true")
+	if err := cluster.GetClusterMngAdapterInstance().TriggerClusterAddOrUpdate(v2.Cluster{
+		Name:        "maintenance_cluster",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+	}); err != nil {
+		t.Fatalf("update cluster failed: %v", err)
+	}
+	if maintenance() {
+		t.Fatal("maintenance mode is not reset")
+	}
+
+	for _, tc := range []struct {
+		method string
+		url    string
+		code   int
+	}{
+		{"POST", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/maintenance?enable=yes", http.StatusBadRequest},
+		{"DELETE", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/maintenance", http.StatusMethodNotAllowed},
+		{"GET", "http://127.0.0.1/api/v1/clusters/unknown/maintenance", http.StatusNotFound},
+		{"GET", "http://127.0.0.1/api/v1/clusters/maintenance_cluster/hosts", http.StatusNotFound},
+	} {
+		if code, _ := request(tc.method, tc.url); code != tc.code {
+			t.Fatalf("%s %s expected status %d, but got %d", tc.method, tc.url, tc.code, code)
+		}
+	}
+}
+
+func TestRouterConfig(t *testing.T) {
+	if err := router.GetRoutersMangerInstance().AddOrUpdateRouters(&v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "admin_router",
+		},
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "admin_vh",
+				Domains: []string{"*"},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("add router config failed: %v", err)
+	}
+	request := func(method, url, body string) (int, *v2.RouterConfiguration) {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		RouterConfig(w, r)
+		cfg := &v2.RouterConfiguration{}
+		if w.Result().StatusCode == http.StatusOK {
+			if err := rawjson.Unmarshal(w.Body.Bytes(), cfg); err != nil {
+				t.Fatalf("unmarshal response error: %v", err)
+			}
+		}
+		return w.Result().StatusCode, cfg
+	}
+	ctx := variable.NewVariableContext(context.Background())
+	match := func() string {
+		rw := router.GetRoutersMangerInstance().GetRouterWrapperByName("admin_router")
+		r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "admin")
+		if r == nil {
+			return ""
+		}
+		return r.RouteRule().ClusterName(ctx)
+	}
+
+	route := `{"match":{"headers":[{"name":"service","value":"admin"}]},"route":{"cluster_name":"%s"}}`
+	code, cfg := request("POST", "http://127.0.0.1/api/v1/routers/admin_router", fmt.Sprintf(route, "cluster1"))
+	if code != http.StatusOK || len(cfg.VirtualHosts[0].Routers) != 1 {
+		t.Fatalf("add route failed: %d, %+v", code, cfg)
+	}
+	if c := match(); c != "cluster1" {
+		t.Fatalf("added route is not matched: %s", c)
+	}
+	code, cfg = request("POST", "http://127.0.0.1/api/v1/routers/admin_router?virtual_host=admin_vh&index=0", fmt.Sprintf(route, "cluster2"))
+	if code != http.StatusOK || cfg.VirtualHosts[0].Routers[0].Route.ClusterName != "cluster2" {
+		t.Fatalf("update route failed: %d, %+v", code, cfg)
+	}
+	if c := match(); c != "cluster2" {
+		t.Fatalf("updated route is not matched: %s", c)
+	}
+	code, cfg = request("GET", "http://127.0.0.1/api/v1/routers/admin_router", "")
+	if code != http.StatusOK || cfg.RouterConfigName != "admin_router" || len(cfg.VirtualHosts[0].Routers) != 1 {
+		t.Fatalf("get router config failed: %d, %+v", code, cfg)
+	}
+
+	for _, tc := range []struct {
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/admin_router", body: "{", code: http.StatusBadRequest},
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/admin_router", body: `{"match":{"regex":"("},"route":{"cluster_name":"c"}}`, code: http.StatusBadRequest},
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/admin_router", body: `{"match":{"prefix":"/"}}`, code: http.StatusBadRequest},
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/admin_router?index=x", body: fmt.Sprintf(route, "c"), code: http.StatusBadRequest},
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/admin_router?index=5", body: fmt.Sprintf(route, "c"), code: http.StatusBadRequest},
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/admin_router?virtual_host=unknown", body: fmt.Sprintf(route, "c"), code: http.StatusNotFound},
+		{method: "POST", url: "http://127.0.0.1/api/v1/routers/unknown", body: fmt.Sprintf(route, "c"), code: http.StatusNotFound},
+		{method: "DELETE", url: "http://127.0.0.1/api/v1/routers/admin_router", code: http.StatusBadRequest},
+		{method: "PUT", url: "http://127.0.0.1/api/v1/routers/admin_router", code: http.StatusMethodNotAllowed},
+		{method: "GET", url: "http://127.0.0.1/api/v1/routers/", code: http.StatusNotFound},
+	} {
+		if code, _ := request(tc.method, tc.url, tc.body); code != tc.code {
+			t.Fatalf("%s %s expected code %d, but got %d", tc.method, tc.url, tc.code, code)
+		}
+	}
+	if c := match(); c != "cluster2" {
+		t.Fatalf("route is changed by the invalid requests: %s", c)
+	}
+
+	code, cfg = request("DELETE", "http://127.0.0.1/api/v1/routers/admin_router?index=0", "")
+	if code != http.StatusOK || len(cfg.VirtualHosts[0].Routers) != 0 {
+		t.Fatalf("delete route failed: %d, %+v", code, cfg)
+	}
+	if c := match(); c != "" {
+		t.Fatalf("deleted route is still matched: %s", c)
+	}
+}
@@ -19,12 +19,15 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
 
 	gometrics "github.com/rcrowley/go-metrics"
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -34,8 +37,12 @@ import (
 	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/metrics/sink/console"
 	"mosn.io/mosn/pkg/plugin"
+	"mosn.io/mosn/pkg/proxy"
+	"mosn.io/mosn/pkg/router"
+	mosnserver "mosn.io/mosn/pkg/server"
 	"mosn.io/mosn/pkg/stagemanager"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
 )
 
 var levelMap = map[string]log.Level{
@@ -255,6 +262,69 @@ func UpdateLogLevel(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, msg)
 }
 
+// Logging queries or updates the error loggers' level at runtime
+// GET http://ip:port/api/v1/logging?logger=xxx, returns the level of the loggers, all loggers if logger is not set.
+// POST http://ip:port/api/v1/logging?level=debug&logger=xxx, updates the level of the logger,
+// all loggers if logger is not set. returns the applied levels.
+func Logging(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	loggerPath, withLogger := r.Form["logger"]
+	switch r.Method {
+	case http.MethodGet:
+		levels := log.GetErrorLoggersInfo()
+		if withLogger {
+			levels = filterLoggerLevels(levels, loggerPath)
+			if len(levels) == 0 {
+				log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: logger %v not found", "logging", loggerPath)
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, fmt.Sprintf(errMsgFmt, "logger not found"))
+				return
+			}
+		}
+		data, _ := json.Marshal(levels)
+		w.Write(data)
+	case http.MethodPost:
+		levelStr := r.FormValue("level")
+		level, ok := levelMap[strings.ToUpper(levelStr)]
+		if !ok {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid log level: %s", "logging", levelStr)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, fmt.Sprintf(errMsgFmt, "invalid log level: "+levelStr))
+			return
+		}
+		var applied map[string]string
+		if withLogger {
+			for _, p := range loggerPath {
+				if !log.UpdateErrorLoggerLevel(p, level) {
+					log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: logger %s not found", "logging", p)
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, fmt.Sprintf(errMsgFmt, "logger not found: "+p))
+					return
+				}
+			}
+			applied = filterLoggerLevels(log.GetErrorLoggersInfo(), loggerPath)
+		} else {
+			applied = log.UpdateAllErrorLoggerLevel(level)
+		}
+		log.DefaultLogger.Infof("[admin api] [logging] update log level as %s, applied: %v", levelStr, applied)
+		data, _ := json.Marshal(applied)
+		w.Write(data)
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "logging", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func filterLoggerLevels(levels map[string]string, loggerPath []string) map[string]string {
+	filtered := make(map[string]string, len(loggerPath))
+	for _, p := range loggerPath {
+		if level, ok := levels[p]; ok {
+			filtered[p] = level
+		}
+	}
+	return filtered
+}
+
 // post data:
 // loggeer path
 func EnableLogger(w http.ResponseWriter, r *http.Request) {
@@ -383,3 +453,188 @@ func GetEnv(w http.ResponseWriter, r *http.Request) {
 	data, _ := json.MarshalIndent(results, "", " ")
 	w.Write(data)
 }
+
+// GetDrainProgress returns the progress of the graceful drain
+func GetDrainProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "get drain progress", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, _ := json.MarshalIndent(mosnserver.GetDrainProgress(), "", " ")
+	w.Write(data)
+}
+
+// HealthCheck returns 503 once the graceful drain is started,
+// so the upstreams and load balancers can stop sending new traffic.
+func HealthCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "health check", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if mosnserver.HealthCheckFailed() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "draining")
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// DumpConnections returns the active downstream connections
+func DumpConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "dump connections", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, _ := json.MarshalIndent(proxy.DumpConnections(), "", " ")
+	w.Write(data)
+}
+
+// DumpStreams returns the in-flight downstream streams
+func DumpStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "dump streams", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, _ := json.MarshalIndent(proxy.DumpStreams(), "", " ")
+	w.Write(data)
+}
+
+// ClusterMaintenanceData is the maintenance mode state of a cluster
+type ClusterMaintenanceData struct {
+	Cluster         string `json:"cluster"`
+	MaintenanceMode bool   `json:"maintenance_mode"`
+}
+
+const clusterAPIPrefix = "/api/v1/clusters/"
+
+// ClusterMaintenance queries or toggles the maintenance mode of a cluster, the requests routed to
+// the cluster in maintenance mode are rejected with 503. the state is kept in memory only,
+// it is reset to the config when the cluster is updated.
+// GET http://ip:port/api/v1/clusters/{name}/maintenance, returns the maintenance mode state.
+// POST http://ip:port/api/v1/clusters/{name}/maintenance?enable=true, enables or disables the maintenance mode.
+func ClusterMaintenance(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, clusterAPIPrefix)
+	if !strings.HasSuffix(name, "/maintenance") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, errMsgFmt, "unknown api")
+		return
+	}
+	name = strings.TrimSuffix(name, "/maintenance")
+	snapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), name)
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, errMsgFmt, "cluster not found")
+		return
+	}
+	mc, ok := snapshot.ClusterInfo().(types.MaintenanceModeCluster)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, errMsgFmt, "cluster does not support maintenance mode")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enable, err := strconv.ParseBool(r.URL.Query().Get("enable"))
+		if err != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid enable: %s", "cluster maintenance", r.URL.Query().Get("enable"))
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, errMsgFmt, "invalid enable")
+			return
+		}
+		mc.SetMaintenanceMode(enable)
+		log.DefaultLogger.Infof("[admin api] [cluster maintenance] cluster %s maintenance mode: %t", name, enable)
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "cluster maintenance", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, _ := json.MarshalIndent(&ClusterMaintenanceData{
+		Cluster:         name,
+		MaintenanceMode: mc.MaintenanceMode(),
+	}, "", " ")
+	w.Write(data)
+}
+
+const routerAPIPrefix = "/api/v1/routers/"
+
+// RouterConfig queries or updates the routes of a router config at runtime, the routers are swapped
+// atomically, the in-flight requests are not affected. the changes are kept in memory only.
+// GET http://ip:port/api/v1/routers/{name}, returns the router config.
+// POST http://ip:port/api/v1/routers/{name}?virtual_host=xxx&index=n, the body is a route rule, replaces the
+// route at index n of the virtual host, appends the route if index is not set.
+// DELETE http://ip:port/api/v1/routers/{name}?virtual_host=xxx&index=n, deletes the route at index n.
+// the virtual host can be omitted if the router config has only one virtual host.
+func RouterConfig(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, routerAPIPrefix)
+	manager := router.GetRoutersMangerInstance()
+	updater, ok := manager.(types.RouteUpdater)
+	if name == "" || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, errMsgFmt, "unknown api")
+		return
+	}
+	virtualHost := r.URL.Query().Get("virtual_host")
+	index := -1
+	if v := r.URL.Query().Get("index"); v != "" || r.Method == http.MethodDelete {
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid index: %s", "router config", v)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, errMsgFmt, "invalid index")
+			return
+		}
+		index = i
+	}
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		body, rerr := ioutil.ReadAll(r.Body)
+		if rerr != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "router config", rerr)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, errMsgFmt, "read body error")
+			return
+		}
+		route := &v2.Router{}
+		if rerr := json.Unmarshal(body, route); rerr != nil {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid route: %s, %v", "router config", string(body), rerr)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, errMsgFmt, "invalid route")
+			return
+		}
+		err = updater.SetRoute(name, virtualHost, index, route)
+	case http.MethodDelete:
+		err = updater.DeleteRoute(name, virtualHost, index)
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "router config", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, router: %s, error: %v", "router config", name, err)
+		if errors.Is(err, router.ErrNoRouterConfig) || errors.Is(err, router.ErrNoVirtualHost) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		fmt.Fprintf(w, errMsgFmt, err.Error())
+		return
+	}
+	rw := manager.GetRouterWrapperByName(name)
+	if rw == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, errMsgFmt, "router config not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Infof("[admin api] [router config] router %s is updated by %s, virtual host: %s, index: %d", name, r.Method, virtualHost, index)
+	}
+	data, _ := json.MarshalIndent(rw.GetRoutersConfig(), "", " ")
+	w.Write(data)
+}
@@ -61,10 +61,17 @@ func init() {
 		"/api/v1/get_loglevel":    NewAPIHandler(GetLoggerInfo),
 		"/api/v1/enable_log":      NewAPIHandler(EnableLogger),
 		"/api/v1/disable_log":     NewAPIHandler(DisableLogger),
+		"/api/v1/logging":         NewAPIHandler(Logging),
 		"/api/v1/states":          NewAPIHandler(GetState),
 		"/api/v1/plugin":          NewAPIHandler(PluginApi),
 		"/api/v1/features":        NewAPIHandler(KnownFeatures),
 		"/api/v1/env":             NewAPIHandler(GetEnv),
+		"/api/v1/drain":           NewAPIHandler(GetDrainProgress),
+		"/api/v1/health":          NewAPIHandler(HealthCheck),
+		"/api/v1/connections":     NewAPIHandler(DumpConnections),
+		"/api/v1/streams":         NewAPIHandler(DumpStreams),
+		"/api/v1/clusters/":       NewAPIHandler(ClusterMaintenance),
+		"/api/v1/routers/":        NewAPIHandler(RouterConfig),
 		"/":                       NewAPIHandler(Help),
 	}
 }
@@ -39,6 +39,8 @@ type FaultInjectConfig struct {
 type DelayInjectConfig struct {
 	Percent             uint32             `json:"percentage,omitempty"`
 	DelayDurationConfig api.DurationConfig `json:"fixed_delay,omitempty"`
+	// MaxDelayConfig makes a random delay between the fixed delay and it
+	MaxDelayConfig api.DurationConfig `json:"max_delay,omitempty"`
 }
 
 type StreamGzip struct {
@@ -47,6 +49,175 @@ type StreamGzip struct {
 	ContentType   []string `json:"content_types,omitempty"`
 }
 
+// StreamDecompress decompresses the request body encoded by the content codings
+type StreamDecompress struct {
+	// Encodings are the content codings to decompress, gzip and deflate are supported
+	Encodings            []string `json:"encodings,omitempty"`
+	MaxDecompressedBytes uint64   `json:"max_decompressed_bytes,omitempty"`
+}
+
+// StreamTransform transforms the request and response body by the registered transformers
+type StreamTransform struct {
+	// RequestTransformer and ResponseTransformer are the names of the registered transformers,
+	// empty means the body of the direction is not transformed
+	RequestTransformer  string `json:"request_transformer,omitempty"`
+	ResponseTransformer string `json:"response_transformer,omitempty"`
+	// MaxBodyBytes limits the body passed to the transformers, the larger body is handled as a transformer error
+	MaxBodyBytes uint64 `json:"max_body_bytes,omitempty"`
+	// PassThroughOnError sends the original body if the transformer fails, otherwise the request is replied with 500
+	PassThroughOnError bool `json:"pass_through_on_error,omitempty"`
+}
+
+// StreamJwtAuth is the config of the jwt auth stream filter
+type StreamJwtAuth struct {
+	Providers []JwtProvider `json:"providers,omitempty"`
+}
+
+// JwtProvider describes how to verify the jwt issued by an issuer
+type JwtProvider struct {
+	Issuer    string   `json:"issuer,omitempty"`
+	Audiences []string `json:"audiences,omitempty"`
+	// PathPrefix limits the provider to the requests whose path has the prefix, empty means all requests
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// LocalJwks is the inline jwks, RemoteJwks is the http uri to fetch the jwks, one of them is required
+	LocalJwks  string `json:"local_jwks,omitempty"`
+	RemoteJwks string `json:"remote_jwks,omitempty"`
+	// CacheDuration is how long the fetched remote jwks is cached
+	CacheDuration api.DurationConfig `json:"cache_duration,omitempty"`
+	// Forward keeps the token in the request sent to the upstream, the token is stripped by default
+	Forward bool `json:"forward,omitempty"`
+}
+
+// StreamCors is the config of the cors stream filter
+type StreamCors struct {
+	// AllowOrigins are the allowed origins, "*" allows all origins,
+	// and the wildcard subdomain such as "https://*.example.com" is supported
+	AllowOrigins []string `json:"allow_origins,omitempty"`
+	AllowMethods []string `json:"allow_methods,omitempty"`
+	// AllowHeaders are the request headers allowed in the preflight, empty means the requested headers are allowed
+	AllowHeaders  []string `json:"allow_headers,omitempty"`
+	ExposeHeaders []string `json:"expose_headers,omitempty"`
+	// MaxAge is how long the preflight result can be cached by the browser
+	MaxAge           api.DurationConfig `json:"max_age,omitempty"`
+	AllowCredentials bool               `json:"allow_credentials,omitempty"`
+}
+
+// StreamJSONSchema is the config of the json schema validation stream filter,
+// it can be overridden by the per filter config of the route, the route with an invalid config is rejected.
+// The schema with the keywords not supported by the filter is invalid.
+type StreamJSONSchema struct {
+	// Schema is the json schema the json request body should conform to
+	Schema map[string]interface{} `json:"schema,omitempty"`
+	// MaxBodyBytes rejects the json request whose body is larger than it with 413, zero means no limit
+	MaxBodyBytes uint32 `json:"max_body_bytes,omitempty"`
+}
+
+// StreamSpiffeAuthz is the config of the spiffe authorization stream filter, which authorizes the requests
+// by the spiffe id in the client certificate verified by the listener, the client certificate not verified
+// is treated as missing. It can be overridden by the per filter config of the route, the route with
+// an invalid config is rejected.
+type StreamSpiffeAuthz struct {
+	// Principals are the allowed spiffe ids, "*" allows all ids, and the id ending with "/*"
+	// such as "spiffe://example.org/ns/default/*" allows the ids with the prefix.
+	// the requests are denied if no principal is configured
+	Principals []string `json:"principals,omitempty"`
+	// ClusterPrincipals are the allowed spiffe ids of the requests routed to the cluster, which take precedence over Principals
+	ClusterPrincipals map[string][]string `json:"cluster_principals,omitempty"`
+	// AllowMissingCert allows the requests without the client certificate, they are denied by default
+	AllowMissingCert bool `json:"allow_missing_cert,omitempty"`
+}
+
+// StreamExtAuthz is the config of the external authorization stream filter,
+// one of the http service and the grpc service is required.
+type StreamExtAuthz struct {
+	HttpService *ExtAuthzHttpService `json:"http_service,omitempty"`
+	GrpcService *ExtAuthzGrpcService `json:"grpc_service,omitempty"`
+	// Timeout is the timeout of the authorization call, 200ms by default
+	Timeout api.DurationConfig `json:"timeout,omitempty"`
+	// FailOpen allows the request if the authorization service is failed or timeout
+	FailOpen bool `json:"fail_open,omitempty"`
+	// AllowedHeaders are the request headers sent to the authorization service, empty means all headers
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+	// WithRequestBody sends the buffered request body, which is truncated to MaxRequestBytes if it is set
+	WithRequestBody bool   `json:"with_request_body,omitempty"`
+	MaxRequestBytes uint32 `json:"max_request_bytes,omitempty"`
+}
+
+// ExtAuthzHttpService calls the authorization service by http, the request path is appended to the server uri.
+// the request is allowed if the authorization service responds 200.
+type ExtAuthzHttpService struct {
+	ServerUri string `json:"server_uri,omitempty"`
+	// AllowedUpstreamHeaders are the headers of the authorization response added to the allowed request
+	AllowedUpstreamHeaders []string `json:"allowed_upstream_headers,omitempty"`
+}
+
+// ExtAuthzGrpcService calls the authorization service by the envoy.service.auth.v3.Authorization grpc service
+type ExtAuthzGrpcService struct {
+	Address string `json:"address,omitempty"`
+}
+
+// StreamRateLimit is the config of the global rate limit stream filter, which asks the external
+// rate limit service implementing envoy.service.ratelimit.v3.RateLimitService.
+type StreamRateLimit struct {
+	// Address is the grpc address of the rate limit service
+	Address string `json:"address,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+	// Timeout is the timeout of the rate limit call, 100ms by default
+	Timeout api.DurationConfig `json:"timeout,omitempty"`
+	// FailureModeDeny rejects the request with 500 if the rate limit service is failed or timeout,
+	// the request is allowed by default
+	FailureModeDeny bool `json:"failure_mode_deny,omitempty"`
+	// Descriptors are sent for every request, the descriptors of the route are appended to them
+	Descriptors []RateLimitDescriptor `json:"descriptors,omitempty"`
+}
+
+// RateLimitRouteConfig is the per route config of the global rate limit stream filter
+type RateLimitRouteConfig struct {
+	Descriptors []RateLimitDescriptor `json:"descriptors,omitempty"`
+}
+
+// RateLimitDescriptor is composed of the entries generated by the actions in order,
+// the descriptor is not sent if any of the actions is not matched.
+type RateLimitDescriptor struct {
+	Actions []RateLimitAction `json:"actions,omitempty"`
+}
+
+// RateLimitAction generates a descriptor entry, one of the fields should be set
+type RateLimitAction struct {
+	RequestHeader *RateLimitRequestHeader `json:"request_header,omitempty"`
+	// RemoteAddress generates the entry ("remote_address", <downstream ip>)
+	RemoteAddress *struct{}            `json:"remote_address,omitempty"`
+	GenericKey    *RateLimitGenericKey `json:"generic_key,omitempty"`
+}
+
+// RateLimitRequestHeader generates the entry (DescriptorKey, <header value>),
+// it is not matched if the request header is absent
+type RateLimitRequestHeader struct {
+	HeaderName    string `json:"header_name,omitempty"`
+	DescriptorKey string `json:"descriptor_key,omitempty"`
+}
+
+// RateLimitGenericKey generates a static entry, the key is "generic_key" if DescriptorKey is empty
+type RateLimitGenericKey struct {
+	DescriptorKey   string `json:"descriptor_key,omitempty"`
+	DescriptorValue string `json:"descriptor_value,omitempty"`
+}
+
+// StreamResponseCache is the config of the in-memory http response cache stream filter,
+// the responses with Cache-Control max-age are cached and the ttl is the max-age.
+// The responses with Set-Cookie, or Cache-Control private, no-store or no-cache are never cached.
+type StreamResponseCache struct {
+	// KeyHeaders are the request headers added to the cache key besides the method, host and path
+	KeyHeaders []string `json:"key_headers,omitempty"`
+	// MaxObjectBytes is the max body size of a cached response, 1MB by default
+	MaxObjectBytes uint32 `json:"max_object_bytes,omitempty"`
+	// MaxCacheBytes is the max total body size of the cached responses, 64MB by default,
+	// the least recently used responses are evicted if it is exceeded
+	MaxCacheBytes uint64 `json:"max_cache_bytes,omitempty"`
+	// MaxTTL limits the max-age of the cached responses if it is set
+	MaxTTL api.DurationConfig `json:"max_ttl,omitempty"`
+}
+
 // StreamDSL ...
 type StreamDSL struct {
 	Debug            bool   `json:"debug"` // TODO not implement
@@ -100,6 +271,17 @@ const (
 	GoPluginStreamFilterSuffix = "so_plugin"
 	GrpcMetricFilter           = "grpc_metric"
 	IPAccess                   = "ip_access"
+	GrpcWeb                    = "grpc_web"
+	LocalRateLimit             = "local_rate_limit"
+	Decompress                 = "decompress"
+	JwtAuth                    = "jwt_auth"
+	ExtAuthz                   = "ext_authz"
+	ResponseCache              = "response_cache"
+	RateLimit                  = "rate_limit"
+	Cors                       = "cors"
+	JSONSchema                 = "json_schema"
+	Transform                  = "transform"
+	SpiffeAuthz                = "spiffe_authz"
 )
 
 // HealthCheckFilter
@@ -157,15 +339,21 @@ type StreamFaultInject struct {
 	Abort           *AbortInject    `json:"abort,omitempty"`
 	UpstreamCluster string          `json:"upstream_cluster,omitempty"`
 	Headers         []HeaderMatcher `json:"headers,omitempty"`
+	// OverrideHeader is the request header to force or skip the injection, the value is "force" or "skip"
+	OverrideHeader string `json:"override_header,omitempty"`
 }
 
+// DelayInject holds the request before it is forwarded, the delay should be shorter than
+// the after route receiver filter timeout of the proxy, or the request is replied with 504
 type DelayInject struct {
 	DelayInjectConfig
-	Delay time.Duration `json:"-"`
+	Delay    time.Duration `json:"-"`
+	MaxDelay time.Duration `json:"-"`
 }
 
 func (d DelayInject) MarshalJSON() (b []byte, err error) {
 	d.DelayInjectConfig.DelayDurationConfig.Duration = d.Delay
+	d.DelayInjectConfig.MaxDelayConfig.Duration = d.MaxDelay
 	return json.Marshal(d.DelayInjectConfig)
 }
 
@@ -174,6 +362,7 @@ func (d *DelayInject) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	d.Delay = d.DelayDurationConfig.Duration
+	d.MaxDelay = d.MaxDelayConfig.Duration
 	return nil
 }
 
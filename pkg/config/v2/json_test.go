@@ -371,7 +371,9 @@ func TestRetryPolicyUnmarshal(t *testing.T) {
 	cfgStr := `{
 		"retry_on": true,
 		"retry_timeout": "1s",
-		"num_retries": 3
+		"num_retries": 3,
+		"hedge_delay": "100ms",
+		"retry_non_idempotent": true
 	}`
 	p := &RetryPolicy{}
 	if err := json.Unmarshal([]byte(cfgStr), p); err != nil {
@@ -379,7 +381,9 @@ func TestRetryPolicyUnmarshal(t *testing.T) {
 	}
 	if !(p.RetryOn &&
 		p.NumRetries == 3 &&
-		p.RetryTimeout == time.Second) {
+		p.RetryTimeout == time.Second &&
+		p.HedgeDelay == 100*time.Millisecond &&
+		p.RetryNonIdempotent) {
 		t.Errorf("unmarshal unexpected %v", p)
 	}
 }
@@ -932,7 +936,7 @@ func TestHashPolicyUnmarshal(t *testing.T) {
 }
 
 func TestHashPolicyMarshal(t *testing.T) {
-	config := `{"hash_policy":[{"header":{"key":"header_key"}}],"timeout":"0s"}`
+	config := `{"hash_policy":[{"header":{"key":"header_key"}}]}`
 
 	headerConfig := &RouterActionConfig{
 		HashPolicy: []HashPolicy{
@@ -950,7 +954,7 @@ func TestHashPolicyMarshal(t *testing.T) {
 		t.FailNow()
 	}
 
-	config2 := `{"hash_policy":[{"cookie":{"name":"name","path":"path","ttl":"5s"}}],"timeout":"0s"}`
+	config2 := `{"hash_policy":[{"cookie":{"name":"name","path":"path","ttl":"5s"}}]}`
 	cookieConfig := &RouterActionConfig{
 		HashPolicy: []HashPolicy{
 			{
@@ -971,7 +975,7 @@ func TestHashPolicyMarshal(t *testing.T) {
 		t.FailNow()
 	}
 
-	config3 := `{"hash_policy":[{"source_ip":{}}],"timeout":"0s"}`
+	config3 := `{"hash_policy":[{"source_ip":{}}]}`
 	ipConfig := &RouterActionConfig{
 		HashPolicy: []HashPolicy{
 			{
@@ -28,6 +28,36 @@ type LeastRequestLbConfig struct {
 func (lbconfig *LeastRequestLbConfig) isCluster_LbConfig() {
 }
 
+type RingHashLbConfig struct {
+	// MinimumRingSize is the minimum number of the virtual nodes in the hash ring,
+	// a larger ring makes the request distribution smoother
+	MinimumRingSize uint64 `json:"minimum_ring_size,omitempty"`
+}
+
+func (lbconfig *RingHashLbConfig) isCluster_LbConfig() {
+}
+
+// StickySessionConfig binds the requests carrying the cookie to the host encoded in the cookie,
+// the cookie is set on the response if a new host is chosen.
+type StickySessionConfig struct {
+	CookieName string `json:"cookie_name,omitempty"`
+	// TTL is the max age of the cookie, the cookie is a session cookie if it is zero
+	TTL  api.DurationConfig `json:"ttl,omitempty"`
+	Path string             `json:"path,omitempty"`
+}
+
+// LocalityLbConfig prefers the hosts in the same locality as mosn, the locality of a host
+// is described by its metadata. The requests spill over to the other localities only if
+// the healthy hosts in the local locality multiplied by the overprovisioning factor are not enough.
+type LocalityLbConfig struct {
+	// Locality is the locality of mosn, such as {"region": "cn-hangzhou", "zone": "zone-a"},
+	// the hosts with the same metadata values are in the local locality
+	Locality map[string]string `json:"locality,omitempty"`
+	// OverprovisioningFactor is a percent, default 140, which means the local locality takes all the requests
+	// until less than 1/1.4 (about 71%) of the local hosts are healthy
+	OverprovisioningFactor uint32 `json:"overprovisioning_factor,omitempty"`
+}
+
 type IsCluster_LbConfig interface {
 	isCluster_LbConfig()
 }
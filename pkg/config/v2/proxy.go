@@ -17,7 +17,11 @@
 
 package v2
 
-import "time"
+import (
+	"time"
+
+	"mosn.io/api"
+)
 
 // StreamProxy
 type StreamProxy struct {
@@ -50,4 +54,158 @@ type Proxy struct {
 	// concurrency num = worker num in worker pool per connection
 	// if concurrency num == 0, use global worker pool
 	ConcurrencyNum int `json:"concurrency_num,omitempty"`
+
+	// ReceiverFilterTimeout limits how long a stream receiver filter that returns
+	// StreamFilterStop can hold the stream, nil means the stream is never held
+	ReceiverFilterTimeout *ReceiverFilterTimeout `json:"receiver_filter_timeout,omitempty"`
+
+	// MaxReMatchRoute and MaxReChooseHost limit the times of a stream receiver filter
+	// can make the stream redo the route match or the host choose, zero means use the default value
+	MaxReMatchRoute int `json:"max_rematch_route,omitempty"`
+	MaxReChooseHost int `json:"max_rechoose_host,omitempty"`
+
+	// TunnelIdleTimeout closes the tunnel of the upgraded connections, such as websocket and http CONNECT,
+	// if no bytes are transferred for a while. nil means use the default idle timeout, zero means never timeout
+	TunnelIdleTimeout *api.DurationConfig `json:"tunnel_idle_timeout,omitempty"`
+
+	// ProtocolSniff configures the protocol detection of the Auto downstream protocol
+	ProtocolSniff *ProtocolSniffConfig `json:"protocol_sniff,omitempty"`
+
+	// HTTPConnect enables the http CONNECT tunneling, nil means the CONNECT request is proxied as a normal request
+	HTTPConnect *HTTPConnectConfig `json:"http_connect,omitempty"`
+
+	// TrustUpstreamHostHeader allows the request header x-mosn-upstream-host to choose the upstream host
+	// in the cluster, bypassing the load balancer. it is used for debugging and should only be enabled
+	// on the listeners of the trusted downstream.
+	TrustUpstreamHostHeader bool `json:"trust_upstream_host_header,omitempty"`
+
+	// ForwardedHeaders configures the x-forwarded-for and forwarded headers of the http requests,
+	// nil means the headers are forwarded as they are.
+	ForwardedHeaders *ForwardedHeadersConfig `json:"forwarded_headers,omitempty"`
+
+	// DownstreamIdleTimeout closes the downstream connection if it has no active streams for a while,
+	// nil or zero means never timeout.
+	// Unlike the listener's connection_idle_timeout, which closes the connection without any bytes read or
+	// written, in multiples of the connection read timeout, it closes the connection with no requests in flight,
+	// so the connection with only the keepalive or ping frames is closed, and a long running request is kept.
+	DownstreamIdleTimeout *api.DurationConfig `json:"downstream_idle_timeout,omitempty"`
+
+	// LocalReply configures the body of the http responses generated by mosn, such as the hijack replies,
+	// nil means the responses are sent without a body.
+	LocalReply *LocalReplyConfig `json:"local_reply,omitempty"`
+
+	// MetricsLabels derives the labels of the listener request metrics from the request headers,
+	// the requests are recorded in the metrics with the labels as well.
+	MetricsLabels []MetricsLabel `json:"metrics_labels,omitempty"`
+
+	// DebugHeaders adds the response headers of the routing decision for debugging,
+	// nil means no header is added.
+	DebugHeaders *DebugHeadersConfig `json:"debug_headers,omitempty"`
+}
+
+// DebugHeadersConfig is the config of the debug response headers of the http requests:
+// x-mosn-upstream-host, x-mosn-cluster and x-mosn-route.
+// The client supplied values of the headers are always stripped once it is configured.
+type DebugHeadersConfig struct {
+	// Enabled adds the headers to all the responses of the listener
+	Enabled bool `json:"enabled,omitempty"`
+	// RequestHeader adds the headers to the responses of the requests with the header.
+	// The header is trusted only if RequestHeaderValue or TrustedSourceCIDRs is configured,
+	// and it is stripped from the untrusted requests before they are sent to the upstream.
+	RequestHeader string `json:"request_header,omitempty"`
+	// RequestHeaderValue is the secret value of the RequestHeader, the header with other values is not trusted
+	RequestHeaderValue string `json:"request_header_value,omitempty"`
+	// TrustedSourceCIDRs are the downstream addresses that can send the RequestHeader, eg: 10.0.0.0/8.
+	// If both RequestHeaderValue and TrustedSourceCIDRs are configured, the request should match both.
+	TrustedSourceCIDRs []string `json:"trusted_source_cidrs,omitempty"`
+}
+
+// MetricsLabel maps a request header to a metrics label
+type MetricsLabel struct {
+	Header string `json:"header,omitempty"`
+	Label  string `json:"label,omitempty"`
+	// AllowedValues bounds the label values, the header values not in the list are recorded as "other".
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	// MaxValues bounds the label values if AllowedValues is empty, the header values beyond
+	// the first MaxValues distinct values are recorded as "other", zero means 64.
+	MaxValues int `json:"max_values,omitempty"`
+}
+
+// LocalReplyConfig is the config of the local reply formatter
+type LocalReplyConfig struct {
+	// Mappers are matched in order by the response status code, the first matched mapper is used
+	Mappers []LocalReplyMapper `json:"mappers,omitempty"`
+	// BodyFormat is used if no mapper is matched, nil means the response is sent without a body
+	BodyFormat *LocalReplyBodyFormat `json:"body_format,omitempty"`
+}
+
+// LocalReplyMapper maps the response status codes to a body format
+type LocalReplyMapper struct {
+	// StatusCodes is the status codes matched by the mapper, empty means match all
+	StatusCodes []int                `json:"status_codes,omitempty"`
+	BodyFormat  LocalReplyBodyFormat `json:"body_format,omitempty"`
+}
+
+// LocalReplyBodyFormat is the template of the local reply body.
+// %RESPONSE_CODE% in the text is replaced by the response status code,
+// and %REQ(header)% is replaced by the value of the request header.
+type LocalReplyBodyFormat struct {
+	Text        string `json:"text,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// ForwardedHeadersConfig is the config of the forwarded headers management
+type ForwardedHeadersConfig struct {
+	// XffNumTrustedHops is the number of the trusted proxies in front of mosn, the real client address
+	// is the x-forwarded-for entry skipping the trusted hops from the right.
+	// zero means the downstream remote address is the client address.
+	XffNumTrustedHops uint32 `json:"xff_num_trusted_hops,omitempty"`
+	// SkipXffAppend does not append the downstream remote address to the x-forwarded-for
+	SkipXffAppend bool `json:"skip_xff_append,omitempty"`
+	// UseForwarded appends the rfc 7239 forwarded header as well
+	UseForwarded bool `json:"use_forwarded,omitempty"`
+}
+
+// ProtocolSniffConfig is the config of the protocol detection
+type ProtocolSniffConfig struct {
+	// MaxBytes limits the bytes peeked to detect the protocol, zero means use the default value
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// Timeout is the max duration waiting for enough bytes to detect the protocol, zero means never timeout.
+	// the connection is closed if no bytes are received in the timeout, or the connection is in netpoll mode.
+	Timeout api.DurationConfig `json:"timeout,omitempty"`
+	// DefaultProtocol is used if the bytes are not enough to detect the protocol in the timeout or the max bytes,
+	// if it is empty, the connection is handled as an unknown protocol.
+	DefaultProtocol string `json:"default_protocol,omitempty"`
+}
+
+// HTTPConnectConfig is the config of the http CONNECT tunneling
+type HTTPConnectConfig struct {
+	// AllowedDestinations is the "host:port" patterns of the destinations can be tunneled to,
+	// the host can be a "*.suffix" wildcard, "*" matches any host or port.
+	// the CONNECT request to other destinations is denied.
+	AllowedDestinations []string `json:"allowed_destinations,omitempty"`
+}
+
+// ReceiverFilterTimeout is the per-phase timeout of the stream receiver filters
+// zero duration means the stream is not held in the phase
+type ReceiverFilterTimeout struct {
+	BeforeRoute     api.DurationConfig `json:"before_route,omitempty"`
+	AfterRoute      api.DurationConfig `json:"after_route,omitempty"`
+	AfterChooseHost api.DurationConfig `json:"after_choose_host,omitempty"`
+}
+
+// PhaseTimeout returns the timeout of the receiver filter phase
+func (t *ReceiverFilterTimeout) PhaseTimeout(phase api.ReceiverFilterPhase) time.Duration {
+	if t == nil {
+		return 0
+	}
+	switch phase {
+	case api.BeforeRoute:
+		return t.BeforeRoute.Duration
+	case api.AfterRoute:
+		return t.AfterRoute.Duration
+	case api.AfterChooseHost:
+		return t.AfterChooseHost.Duration
+	}
+	return 0
 }
@@ -49,6 +49,16 @@ type RouterConfig struct {
 	MetadataConfig        *MetadataConfig        `json:"metadata,omitempty"`
 	PerFilterConfig       map[string]interface{} `json:"per_filter_config,omitempty"`
 	RequestMirrorPolicies *RequestMirrorPolicy   `json:"request_mirror_policies,omitempty"`
+	AccessLog             *RouteAccessLog        `json:"access_log,omitempty"` // overrides the listener access logs
+}
+
+// RouteAccessLog overrides the access logs of the requests matched the route
+type RouteAccessLog struct {
+	// Disable makes the requests not logged by the listener access logs
+	Disable bool `json:"disable,omitempty"`
+	// FormatName is the name of the format in the access logs' log_formats, the access log
+	// without the named format uses its own format
+	FormatName string `json:"format_name,omitempty"`
 }
 
 type RouterActionConfig struct {
@@ -59,7 +69,7 @@ type RouterActionConfig struct {
 	WeightedClusters        []WeightedCluster    `json:"weighted_clusters,omitempty"`
 	HashPolicy              []HashPolicy         `json:"hash_policy,omitempty"`
 	MetadataConfig          *MetadataConfig      `json:"metadata_match,omitempty"`
-	TimeoutConfig           api.DurationConfig   `json:"timeout,omitempty"`
+	TimeoutConfig           *api.DurationConfig  `json:"timeout,omitempty"` // overrides the cluster request timeout, zero means no timeout
 	RetryPolicy             *RetryPolicy         `json:"retry_policy,omitempty"`
 	PrefixRewrite           string               `json:"prefix_rewrite,omitempty"`
 	RegexRewrite            *RegexRewrite        `json:"regex_rewrite,omitempty"`
@@ -70,6 +80,26 @@ type RouterActionConfig struct {
 	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	MaxRequestBodyBytes     uint64               `json:"max_request_body_bytes,omitempty"`
+	MaxResponseBodyBytes    uint64               `json:"max_response_body_bytes,omitempty"`
+	Priority                string               `json:"priority,omitempty"`
+	StreamRequestBody       *bool                `json:"stream_request_body,omitempty"` // nil keeps the protocol behavior, see types.StreamRequestBodyRule
+	Canary                  *CanaryConfig        `json:"canary,omitempty"`
+	// AdmissionPriority is the priority level in the admission queue of the cluster, it takes precedence
+	// over the priority header configured in the admission queue
+	AdmissionPriority *uint32 `json:"admission_priority,omitempty"`
+}
+
+// CanaryConfig sends a part of the requests to the canary cluster instead of the route cluster.
+// the requests matched the headers are always sent to the canary cluster, and then the percentage
+// of the other requests are sent to the canary cluster by the hash of HashHeader.
+type CanaryConfig struct {
+	ClusterName string          `json:"cluster_name,omitempty"`
+	Headers     []HeaderMatcher `json:"headers,omitempty"`
+	Percentage  uint32          `json:"percentage,omitempty"` // in [0, 100]
+	// HashHeader makes the requests with the same header value always assigned to the same cluster,
+	// the downstream remote ip is hashed if the header is not present.
+	HashHeader string `json:"hash_header,omitempty"`
 }
 
 type ClusterWeightConfig struct {
@@ -79,10 +109,33 @@ type ClusterWeightConfig struct {
 }
 
 type RetryPolicyConfig struct {
-	RetryOn            bool               `json:"retry_on,omitempty"`
+	RetryOn bool `json:"retry_on,omitempty"`
+	// RetryTimeoutConfig is the timeout of each try
 	RetryTimeoutConfig api.DurationConfig `json:"retry_timeout,omitempty"`
 	NumRetries         uint32             `json:"num_retries,omitempty"`
 	StatusCodes        []uint32           `json:"status_codes,omitempty"`
+	// HedgeDelayConfig is the delay to send a hedged request if the try has not responded
+	HedgeDelayConfig api.DurationConfig `json:"hedge_delay,omitempty"`
+	// RetryNonIdempotent allows to retry the http requests with non-idempotent methods, such as POST
+	RetryNonIdempotent bool `json:"retry_non_idempotent,omitempty"`
+	// GrpcStatusCodes are the grpc status codes to retry, such as 14 (UNAVAILABLE)
+	GrpcStatusCodes []uint32 `json:"grpc_status_codes,omitempty"`
+	// RetryBackOff is the exponential back off with full jitter between the retries
+	RetryBackOff *RetryBackOff `json:"retry_back_off,omitempty"`
+	// RetriableHeaders retries the response which has any of the headers, such as "x-envoy-overloaded".
+	// The header without value and regex only needs to be present in the response.
+	RetriableHeaders []HeaderMatcher `json:"retriable_headers,omitempty"`
+	// RetryConditions are the conditions to retry besides retry_on, only "connect-failure" is supported now,
+	// which retries the requests failed to connect the upstream on the other hosts, even for POST.
+	RetryConditions []string `json:"retry_conditions,omitempty"`
+}
+
+// RetryBackOff is the exponential back off between the retries, the interval of the nth retry
+// is a random duration in [0, min(base_interval * 2^(n-1), max_interval))
+type RetryBackOff struct {
+	BaseInterval api.DurationConfig `json:"base_interval,omitempty"`
+	// MaxInterval defaults to 10 times of the base interval
+	MaxInterval api.DurationConfig `json:"max_interval,omitempty"`
 }
 
 // RegexRewrite represents the regex rewrite parameters
@@ -131,7 +184,9 @@ type RouteAction struct {
 
 func (r RouteAction) MarshalJSON() (b []byte, err error) {
 	r.RouterActionConfig.MetadataConfig = metadataToConfig(r.MetadataMatch)
-	r.RouterActionConfig.TimeoutConfig.Duration = r.Timeout
+	if r.Timeout > 0 {
+		r.RouterActionConfig.TimeoutConfig = &api.DurationConfig{Duration: r.Timeout}
+	}
 	return json.Marshal(r.RouterActionConfig)
 }
 
@@ -139,7 +194,9 @@ func (r *RouteAction) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &r.RouterActionConfig); err != nil {
 		return err
 	}
-	r.Timeout = r.RouterActionConfig.TimeoutConfig.Duration
+	if r.RouterActionConfig.TimeoutConfig != nil {
+		r.Timeout = r.RouterActionConfig.TimeoutConfig.Duration
+	}
 	r.MetadataMatch = configToMetadata(r.MetadataConfig)
 	return nil
 }
@@ -172,10 +229,12 @@ func (cw *ClusterWeight) UnmarshalJSON(b []byte) error {
 type RetryPolicy struct {
 	RetryPolicyConfig
 	RetryTimeout time.Duration `json:"-"`
+	HedgeDelay   time.Duration `json:"-"`
 }
 
 func (rp RetryPolicy) MarshalJSON() (b []byte, err error) {
 	rp.RetryPolicyConfig.RetryTimeoutConfig.Duration = rp.RetryTimeout
+	rp.RetryPolicyConfig.HedgeDelayConfig.Duration = rp.HedgeDelay
 	return json.Marshal(rp.RetryPolicyConfig)
 }
 
@@ -184,6 +243,7 @@ func (rp *RetryPolicy) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	rp.RetryTimeout = rp.RetryTimeoutConfig.Duration
+	rp.HedgeDelay = rp.HedgeDelayConfig.Duration
 	return nil
 }
 
@@ -301,12 +361,14 @@ type VirtualHost struct {
 
 // RouterMatch represents the route matching parameters
 type RouterMatch struct {
-	Prefix         string                 `json:"prefix,omitempty"`    // Match request's Path with Prefix Comparing
-	Path           string                 `json:"path,omitempty"`      // Match request's Path with Exact Comparing
-	Regex          string                 `json:"regex,omitempty"`     // Match request's Path with Regex Comparing
-	Headers        []HeaderMatcher        `json:"headers,omitempty"`   // Match request's Headers
-	Variables      []VariableMatcher      `json:"variables,omitempty"` // Match request's variable
-	DslExpressions []DslExpressionMatcher `json:"dsl_expressions,omitempty"`
+	Prefix          string                  `json:"prefix,omitempty"`           // Match request's Path with Prefix Comparing
+	Path            string                  `json:"path,omitempty"`             // Match request's Path with Exact Comparing
+	Regex           string                  `json:"regex,omitempty"`            // Match request's Path with Regex Comparing
+	Headers         []HeaderMatcher         `json:"headers,omitempty"`          // Match request's Headers
+	QueryParameters []QueryParameterMatcher `json:"query_parameters,omitempty"` // Match request's query parameters
+	Variables       []VariableMatcher       `json:"variables,omitempty"`        // Match request's variable
+	DslExpressions  []DslExpressionMatcher  `json:"dsl_expressions,omitempty"`
+	Body            []BodyMatcher           `json:"body,omitempty"` // Match request's body
 }
 
 // RedirectAction represents the redirect response parameters
@@ -315,12 +377,15 @@ type RedirectAction struct {
 	PathRedirect   string `json:"path_redirect,omitempty"`
 	HostRedirect   string `json:"host_redirect,omitempty"`
 	SchemeRedirect string `json:"scheme_redirect,omitempty"`
+	// HttpsRedirect redirects to https, it is the same as the scheme redirect "https"
+	HttpsRedirect bool `json:"https_redirect,omitempty"`
 }
 
 // DirectResponseAction represents the direct response parameters
 type DirectResponseAction struct {
-	StatusCode int    `json:"status,omitempty"`
-	Body       string `json:"body,omitempty"`
+	StatusCode int               `json:"status,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
 }
 
 // WeightedCluster ...
@@ -338,6 +403,29 @@ type HeaderMatcher struct {
 	Regex bool   `json:"regex,omitempty"`
 }
 
+// QueryParameterMatcher specifies a query parameter that the route should match on.
+// The value is matched as exact or regex, or the parameter is only required to be present.
+// If the parameter is repeated in the query string, the first one is used.
+type QueryParameterMatcher struct {
+	Name    string `json:"name,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Regex   bool   `json:"regex,omitempty"`
+	Present bool   `json:"present,omitempty"`
+}
+
+// BodyMatcher specifies a field of the request body that the route should match on.
+// The field is extracted from the json body by the JSONPath, such as "$.user.id" or "$.items[0].name",
+// the whole body is matched if the JSONPath is empty.
+// The body is not matched if it is not a json or it is larger than the MaxBodyBytes.
+// The proxy reads at most the largest MaxBodyBytes of the virtual host's routes before routing,
+// the larger body is only matched by the routes without body matchers, and it is still streamed as a whole.
+type BodyMatcher struct {
+	JSONPath     string `json:"json_path,omitempty"`
+	Value        string `json:"value,omitempty"`
+	Regex        bool   `json:"regex,omitempty"`
+	MaxBodyBytes uint32 `json:"max_body_bytes,omitempty"`
+}
+
 // VariableMatcher specifies a set of variables that the route should match on.
 type VariableMatcher struct {
 	Name  string `json:"name,omitempty"`
@@ -378,4 +466,12 @@ type RequestMirrorPolicy struct {
 	Cluster      string `json:"cluster,omitempty"`
 	Percent      uint32 `json:"percent,omitempty"`
 	TraceSampled bool   `json:"trace_sampled,omitempty"` // TODO not implement
+	// Targets are the shadow clusters besides the Cluster, each target is sampled independently by its percent
+	Targets []RequestMirrorTarget `json:"targets,omitempty"`
+}
+
+// RequestMirrorTarget is a shadow cluster of the request mirror
+type RequestMirrorTarget struct {
+	Cluster string `json:"cluster,omitempty"`
+	Percent uint32 `json:"percent,omitempty"`
 }
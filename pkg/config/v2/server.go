@@ -52,6 +52,16 @@ type ListenerType string
 const EGRESS ListenerType = "egress"
 const INGRESS ListenerType = "ingress"
 
+// ConnectionLimitAction is the behavior of the listener when the max connections is reached
+type ConnectionLimitAction string
+
+const (
+	// ConnectionLimitPause stops accepting until the connections drop below the limit
+	ConnectionLimitPause ConnectionLimitAction = "pause"
+	// ConnectionLimitReject accepts the new connections and closes them immediately
+	ConnectionLimitReject ConnectionLimitAction = "reject"
+)
+
 type ListenerConfig struct {
 	Name                  string              `json:"name,omitempty"`
 	Type                  ListenerType        `json:"type,omitempty"`
@@ -61,11 +71,14 @@ type ListenerConfig struct {
 	UseOriginalDst        bool                `json:"use_original_dst,omitempty"`
 	AccessLogs            []AccessLog         `json:"access_logs,omitempty"`
 	ListenerFilters       []Filter            `json:"listener_filters,omitempty"`
-	FilterChains          []FilterChain       `json:"filter_chains,omitempty"` // only one filterchains at this time
+	FilterChains          []FilterChain       `json:"filter_chains,omitempty"` // multiple filter chains are matched by server names
 	StreamFilters         []Filter            `json:"stream_filters,omitempty"`
 	Inspector             bool                `json:"inspector,omitempty"`
 	ConnectionIdleTimeout *api.DurationConfig `json:"connection_idle_timeout,omitempty"`
 	DefaultReadBufferSize int                 `json:"default_read_buffer_size,omitempty"`
+	// MaxConnections limits the concurrent downstream connections, zero means no limit
+	MaxConnections        uint32                `json:"max_connections,omitempty"`
+	ConnectionLimitAction ConnectionLimitAction `json:"connection_limit_action,omitempty"` // default is pause
 }
 
 // Listener contains the listener's information
@@ -90,6 +103,7 @@ func (l Listener) MarshalJSON() (b []byte, err error) {
 var (
 	ErrNoAddrListener   = errors.New("address is required in listener config")
 	ErrUnsupportNetwork = errors.New("listener network only support tcp/udp/unix")
+	ErrConnLimitAction  = errors.New("listener connection limit action only support pause/reject")
 )
 
 const defaultBufferLimit = 1 << 15
@@ -105,6 +119,11 @@ func (l *Listener) UnmarshalJSON(b []byte) error {
 		l.Network = "tcp" // default is tcp
 	}
 	l.Network = strings.ToLower(l.Network)
+	switch l.ConnectionLimitAction {
+	case "", ConnectionLimitPause, ConnectionLimitReject:
+	default:
+		return ErrConnLimitAction
+	}
 	var err error
 	var addr net.Addr
 	switch l.Network {
@@ -129,6 +148,13 @@ func (l *Listener) UnmarshalJSON(b []byte) error {
 type AccessLog struct {
 	Path   string `json:"log_path,omitempty"`
 	Format string `json:"log_format,omitempty"`
+	// JSONFormat makes up the access log as a json object, the key is the json key
+	// and the value is formatted as the Format, Format is ignored if JSONFormat is set.
+	JSONFormat map[string]string `json:"log_json_format,omitempty"`
+	// JSONNullMissing emits null for the keys whose variables are not found, otherwise the keys are omitted.
+	JSONNullMissing bool `json:"log_json_null_missing,omitempty"`
+	// Formats is the named text formats, which can be chosen by the route access log config
+	Formats map[string]string `json:"log_formats,omitempty"`
 }
 
 // FilterChain wraps a set of match criteria, an option TLS context,
@@ -176,11 +202,17 @@ type Filter struct {
 	Type           string                      `json:"type,omitempty"`
 	GoPluginConfig *StreamFilterGoPluginConfig `json:"go_plugin_config"`
 	Config         map[string]interface{}      `json:"config,omitempty"`
+	// Priority orders the stream filters within each phase, the filters with higher priority run first,
+	// and the filters with the same priority run in the config order.
+	Priority int `json:"priority,omitempty"`
 }
 
 type FilterChainConfig struct {
-	FilterChainMatch string      `json:"match,omitempty"`
-	TLSConfig        *TLSConfig  `json:"tls_context,omitempty"`
-	TLSConfigs       []TLSConfig `json:"tls_context_set,omitempty"`
-	Filters          []Filter    `json:"filters,omitempty"`
+	FilterChainMatch string `json:"match,omitempty"`
+	// ServerNames matches the server name (SNI) of the TLS connections before decryption,
+	// such as "a.example.com" or "*.example.com". the filter chain without server names is the default one.
+	ServerNames []string    `json:"server_names,omitempty"`
+	TLSConfig   *TLSConfig  `json:"tls_context,omitempty"`
+	TLSConfigs  []TLSConfig `json:"tls_context_set,omitempty"`
+	Filters     []Filter    `json:"filters,omitempty"`
 }
@@ -46,6 +46,18 @@ type TLSConfig struct {
 	ExtendVerify      map[string]interface{} `json:"extend_verify,omitempty"`
 	Callbacks         []string               `json:"callbacks,omitempty"`
 	SdsConfig         *SdsConfig             `json:"sds_source,omitempty"`
+	// CRLFile is the certificate revocation list file used to reject the revoked client certificates
+	CRLFile string `json:"crl_file,omitempty"`
+	// CRLFailOpen allows the client certificates if the revocation list is expired
+	CRLFailOpen bool `json:"crl_fail_open,omitempty"`
+	// AutoSniFromHost makes the upstream connections use the host of the request as the server name (SNI),
+	// the ServerName is used if the request has no host. it is only used by the clusters.
+	// each server name has its own connection pool, the oldest pool of an upstream address is shut down
+	// if the server names of the address reach the limit (64).
+	AutoSniFromHost bool `json:"auto_sni_from_host,omitempty"`
+	// AutoSanValidation verifies the subject alt names of the upstream certificate against the server name,
+	// even if InsecureSkip is set. it is only used by the clusters.
+	AutoSanValidation bool `json:"auto_san_validation,omitempty"`
 }
 
 type SdsConfig struct {
@@ -41,6 +41,65 @@ type HealthCheckConfig struct {
 	ServiceName          string                 `json:"service_name,omitempty"`
 	SessionConfig        map[string]interface{} `json:"check_config,omitempty"`
 	CommonCallbacks      []string               `json:"common_callbacks,omitempty"` // HealthCheck support register some common callbacks that are not related to specific cluster
+	// EventMinInterval debounces the health events of a flapping host, the state changes within
+	// the interval are merged and only the latest state is published after the interval.
+	EventMinInterval api.DurationConfig  `json:"event_min_interval,omitempty"`
+	EventWebhook     *HealthEventWebhook `json:"event_webhook,omitempty"`
+	// WarmUp makes the new hosts receive no traffic until they pass healthy_threshold consecutive checks,
+	// the slow start of the host begins after the warm up if both are configured.
+	WarmUp bool `json:"warm_up,omitempty"`
+}
+
+// HealthEventWebhook posts the health events as json to the url
+type HealthEventWebhook struct {
+	URL           string             `json:"url,omitempty"`
+	TimeoutConfig api.DurationConfig `json:"timeout,omitempty"` // default is 3s
+}
+
+// OutlierDetection is the passive health check config, the host is ejected for a while
+// after the consecutive 5xx responses or connection failures
+type OutlierDetection struct {
+	Consecutive5xx         uint32             `json:"consecutive_5xx,omitempty"`
+	IntervalConfig         api.DurationConfig `json:"interval,omitempty"`
+	BaseEjectionTimeConfig api.DurationConfig `json:"base_ejection_time,omitempty"`
+	MaxEjectionTimeConfig  api.DurationConfig `json:"max_ejection_time,omitempty"`
+	MaxEjectionPercent     uint32             `json:"max_ejection_percent,omitempty"`
+}
+
+// AdaptiveConcurrency limits the in-flight requests to the cluster by the observed latency,
+// the limit decreases when the latency exceeds the target and increases when it recovers
+type AdaptiveConcurrency struct {
+	SampleWindowConfig  api.DurationConfig `json:"sample_window,omitempty"`
+	MinConcurrency      uint32             `json:"min_concurrency,omitempty"`
+	MaxConcurrency      uint32             `json:"max_concurrency,omitempty"`
+	LatencyTargetConfig api.DurationConfig `json:"latency_target,omitempty"`
+}
+
+// AdmissionQueue admits the requests to the cluster by priority. If the in-flight requests reach
+// the max concurrency, the requests wait in the queues of their priority levels, and the waiting
+// request of the highest priority is admitted when an in-flight request finishes.
+// Level 0 is the highest priority.
+type AdmissionQueue struct {
+	MaxConcurrency uint32 `json:"max_concurrency,omitempty"`
+	// Levels are the queues of the priority levels, defaults to a single level
+	Levels []AdmissionQueueLevel `json:"levels,omitempty"`
+	// PriorityHeader carries the priority of the request, such as x-priority. The header value is mapped
+	// to a level by HeaderValues, or parsed as the level number if it is not in HeaderValues.
+	PriorityHeader string            `json:"priority_header,omitempty"`
+	HeaderValues   map[string]uint32 `json:"header_values,omitempty"`
+	// DefaultLevel is the level of the requests without priority, nil means the lowest level
+	DefaultLevel *uint32 `json:"default_level,omitempty"`
+	// QueueTimeout is how long a request waits to be admitted, defaults to 1s
+	QueueTimeout api.DurationConfig `json:"queue_timeout,omitempty"`
+	// AgingInterval promotes a waiting request one level higher every interval to avoid starvation,
+	// zero means no aging
+	AgingInterval api.DurationConfig `json:"aging_interval,omitempty"`
+}
+
+// AdmissionQueueLevel is the queue of a priority level
+type AdmissionQueueLevel struct {
+	// MaxQueued limits the waiting requests of the level, zero means no limit
+	MaxQueued uint32 `json:"max_queued,omitempty"`
 }
 
 type HostConfig struct {
@@ -49,8 +108,21 @@ type HostConfig struct {
 	Weight         uint32          `json:"weight,omitempty"`
 	MetaDataConfig *MetadataConfig `json:"metadata,omitempty"`
 	TLSDisable     bool            `json:"tls_disable,omitempty"`
+	// HealthStatus is the host's health status reported by the control plane
+	HealthStatus HostHealthStatus `json:"health_status,omitempty"`
 }
 
+// HostHealthStatus is the health status of the host reported by the control plane,
+// the empty status means unknown, and the host is treated as healthy
+type HostHealthStatus string
+
+// Group of host health status
+const (
+	HostHealthy   HostHealthStatus = "HEALTHY"
+	HostUnhealthy HostHealthStatus = "UNHEALTHY"
+	HostDraining  HostHealthStatus = "DRAINING"
+)
+
 // ClusterType
 type ClusterType string
 
@@ -62,6 +134,7 @@ const (
 	EDS_CLUSTER         ClusterType = "EDS"
 	ORIGINALDST_CLUSTER ClusterType = "ORIGINAL_DST"
 	STRICT_DNS_CLUSTER  ClusterType = "STRICT_DNS"
+	LOGICAL_DNS_CLUSTER ClusterType = "LOGICAL_DNS"
 )
 
 // LbType
@@ -74,6 +147,7 @@ const (
 	LB_ORIGINAL_DST  LbType = "LB_ORIGINAL_DST"
 	LB_LEAST_REQUEST LbType = "LB_LEAST_REQUEST"
 	LB_MAGLEV        LbType = "LB_MAGLEV"
+	LB_RING_HASH     LbType = "LB_RING_HASH"
 )
 
 type DnsLookupFamily string
@@ -81,35 +155,125 @@ type DnsLookupFamily string
 const (
 	V4Only DnsLookupFamily = "V4_ONLY"
 	V6Only DnsLookupFamily = "V6_ONLY"
+	// Auto prefers the ipv6 addresses, and falls back to ipv4 if no ipv6 address is resolved
+	Auto DnsLookupFamily = "AUTO"
 )
 
 // Cluster represents a cluster's information
 type Cluster struct {
-	Name                 string              `json:"name,omitempty"`
-	ClusterType          ClusterType         `json:"type,omitempty"`
-	SubType              string              `json:"sub_type,omitempty"` //not used yet
-	LbType               LbType              `json:"lb_type,omitempty"`
-	MaxRequestPerConn    uint32              `json:"max_request_per_conn,omitempty"`
-	ConnBufferLimitBytes uint32              `json:"conn_buffer_limit_bytes,omitempty"`
-	CirBreThresholds     CircuitBreakers     `json:"circuit_breakers,omitempty"`
-	HealthCheck          HealthCheck         `json:"health_check,omitempty"`
-	Spec                 ClusterSpecInfo     `json:"spec,omitempty"`
-	LBSubSetConfig       LBSubsetConfig      `json:"lb_subset_config,omitempty"`
-	LBOriDstConfig       LBOriDstConfig      `json:"original_dst_lb_config,omitempty"`
-	ClusterManagerTLS    bool                `json:"cluster_manager_tls,omitempty"`
-	TLS                  TLSConfig           `json:"tls_context,omitempty"`
-	Hosts                []Host              `json:"hosts,omitempty"`
-	ConnectTimeout       *api.DurationConfig `json:"connect_timeout,omitempty"`
-	IdleTimeout          *api.DurationConfig `json:"idle_timeout,omitempty"`
-	LbConfig             IsCluster_LbConfig  `json:"lbconfig,omitempty"`
-	DnsRefreshRate       *api.DurationConfig `json:"dns_refresh_rate,omitempty"`
-	RespectDnsTTL        bool                `json:"respect_dns_ttl,omitempty"`
-	DnsLookupFamily      DnsLookupFamily     `json:"dns_lookup_family,omitempty"`
-	DnsResolverConfig    DnsResolverConfig   `json:"dns_resolvers,omitempty"`
-	DnsResolverFile      string              `json:"dns_resolver_file,omitempty"`
-	DnsResolverPort      string              `json:"dns_resolver_port,omitempty"`
+	Name                 string               `json:"name,omitempty"`
+	ClusterType          ClusterType          `json:"type,omitempty"`
+	SubType              string               `json:"sub_type,omitempty"` //not used yet
+	LbType               LbType               `json:"lb_type,omitempty"`
+	MaxRequestPerConn    uint32               `json:"max_request_per_conn,omitempty"`
+	ConnBufferLimitBytes uint32               `json:"conn_buffer_limit_bytes,omitempty"`
+	CirBreThresholds     CircuitBreakers      `json:"circuit_breakers,omitempty"`
+	HealthCheck          HealthCheck          `json:"health_check,omitempty"`
+	OutlierDetection     *OutlierDetection    `json:"outlier_detection,omitempty"`
+	AdaptiveConcurrency  *AdaptiveConcurrency `json:"adaptive_concurrency,omitempty"`
+	KeepAlive            *KeepAliveConfig     `json:"keepalive,omitempty"`
+	Spec                 ClusterSpecInfo      `json:"spec,omitempty"`
+	LBSubSetConfig       LBSubsetConfig       `json:"lb_subset_config,omitempty"`
+	LBOriDstConfig       LBOriDstConfig       `json:"original_dst_lb_config,omitempty"`
+	ClusterManagerTLS    bool                 `json:"cluster_manager_tls,omitempty"`
+	TLS                  TLSConfig            `json:"tls_context,omitempty"`
+	Hosts                []Host               `json:"hosts,omitempty"`
+	ConnectTimeout       *api.DurationConfig  `json:"connect_timeout,omitempty"`
+	IdleTimeout          *api.DurationConfig  `json:"idle_timeout,omitempty"`
+	DrainTimeout         *api.DurationConfig  `json:"drain_timeout,omitempty"`
+	HTTP2Upgrade         bool                 `json:"http2_upgrade,omitempty"`
+	LbConfig             IsCluster_LbConfig   `json:"lbconfig,omitempty"`
+	StickySession        *StickySessionConfig `json:"sticky_session,omitempty"`
+	RequestTimeout       *api.DurationConfig  `json:"request_timeout,omitempty"` // the route timeout takes precedence
+	// the headers configured in the route and the virtual host take precedence
+	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
+	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
+	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
+	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	DnsRefreshRate          *api.DurationConfig  `json:"dns_refresh_rate,omitempty"`
+	RespectDnsTTL           bool                 `json:"respect_dns_ttl,omitempty"`
+	DnsLookupFamily         DnsLookupFamily      `json:"dns_lookup_family,omitempty"`
+	DnsResolverConfig       DnsResolverConfig    `json:"dns_resolvers,omitempty"`
+	DnsResolverFile         string               `json:"dns_resolver_file,omitempty"`
+	DnsResolverPort         string               `json:"dns_resolver_port,omitempty"`
+
+	// SendProxyProtocol is the proxy protocol version ("v1" or "v2") sent on the new upstream connections,
+	// the header carries the addresses of the downstream which makes the connection
+	SendProxyProtocol string `json:"send_proxy_protocol,omitempty"`
+
+	// MaxConnectionsPerHost limits the connections of the cluster to a single host, zero means no limit.
+	// A host reaches the limit is skipped when choosing hosts, and the new connections to it are refused
+	// before they are dialed
+	MaxConnectionsPerHost uint32 `json:"max_connections_per_host,omitempty"`
+
+	// Http2ConnPool tunes the multiplexing of the http2 connections to a host
+	Http2ConnPool *Http2ConnPoolConfig `json:"http2_conn_pool,omitempty"`
+
+	// SlowStart ramps up the weight of the host which is newly added or becomes healthy again
+	SlowStart *SlowStartConfig `json:"slow_start,omitempty"`
+
+	LocalityLbConfig *LocalityLbConfig `json:"locality_lb_config,omitempty"`
+
+	// MaintenanceMode rejects all the requests routed to the cluster with 503,
+	// it can be toggled by the admin api at runtime
+	MaintenanceMode bool `json:"maintenance_mode,omitempty"`
+
+	// AdmissionQueue queues the requests by priority if the cluster is near capacity
+	AdmissionQueue *AdmissionQueue `json:"admission_queue,omitempty"`
+
+	// UpstreamIdleTimeout closes the pooled connection which carries no requests for the duration,
+	// nil or zero means the idle connections are kept.
+	// Unlike IdleTimeout, which closes the connection without any bytes read or written, in multiples of
+	// the connection read timeout, it closes the connection with no requests in flight, so the connection
+	// with only the heartbeats is closed, and the one with a long running request is kept.
+	UpstreamIdleTimeout *api.DurationConfig `json:"upstream_idle_timeout,omitempty"`
+
+	// ConnPoolPrefetch keeps warm connections in the connection pool of each host ahead of the requests
+	ConnPoolPrefetch *ConnPoolPrefetchConfig `json:"conn_pool_prefetch,omitempty"`
+}
+
+// ConnPoolPrefetchConfig is the config of the connections created ahead of the demand.
+// The pool keeps at least MinIdleConnections idle connections to a healthy host, and keeps
+// PreconnectRatio connections for each request in flight, e.g. 1.5 means 15 connections for 10 requests.
+// The ratio less than 1 is ignored. The prefetched connections beyond the min idle connections
+// are closed by the upstream idle timeout. The prefetched connections use the tls server name and the
+// proxy protocol addresses of the request which creates the connection pool.
+// Only the http1 connection pool prefetches the connections, the config is ignored by the http2 and
+// xprotocol connection pools.
+type ConnPoolPrefetchConfig struct {
+	MinIdleConnections uint32  `json:"min_idle_connections,omitempty"`
+	PreconnectRatio    float64 `json:"preconnect_ratio,omitempty"`
+}
+
+// SlowStartConfig is the slow start config of the weighted load balancers.
+// In the slow start window, the weight of a host is
+// weight * max(min_weight_percent / 100, (time since the host is added or healthy / window) ^ (1 / aggression))
+type SlowStartConfig struct {
+	SlowStartWindow  *api.DurationConfig `json:"slow_start_window,omitempty"`
+	MinWeightPercent float64             `json:"min_weight_percent,omitempty"` // default 10
+	Aggression       float64             `json:"aggression,omitempty"`         // default 1, the weight grows linearly
+}
+
+// Http2ConnPoolConfig is the config of the http2 connection pool of a host.
+// A new connection is created if the streams of all the connections reach the max concurrent streams,
+// and the requests wait for an available stream if the connections reach the max connections too.
+type Http2ConnPoolConfig struct {
+	// MaxConcurrentStreams limits the active streams of a connection, zero means all the streams share one connection
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams,omitempty"`
+	// MaxConnections limits the connections of a host, zero means no limit
+	MaxConnections uint32 `json:"max_connections,omitempty"`
+	// MaxPendingRequests limits the requests waiting for an available stream, zero means the requests are rejected
+	MaxPendingRequests uint32 `json:"max_pending_requests,omitempty"`
+	// PendingTimeout is how long a request waits for an available stream, defaults to 1s
+	PendingTimeout api.DurationConfig `json:"pending_timeout,omitempty"`
 }
 
+// the proxy protocol versions
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+)
+
 type DnsResolverConfig struct {
 	Servers  []string `json:"servers,omitempty"`
 	Search   []string `json:"search,omitempty"`
@@ -180,10 +344,36 @@ func (cb *CircuitBreakers) UnmarshalJSON(b []byte) (err error) {
 }
 
 type Thresholds struct {
+	Priority           string `json:"priority,omitempty"`
 	MaxConnections     uint32 `json:"max_connections,omitempty"`
 	MaxPendingRequests uint32 `json:"max_pending_requests,omitempty"`
 	MaxRequests        uint32 `json:"max_requests,omitempty"`
 	MaxRetries         uint32 `json:"max_retries,omitempty"`
+	// Cooldown is the duration the circuit breaker stays open, zero means the circuit breaker is disabled.
+	// The requests and the pending requests are counted by priority, while the connections are counted
+	// by cluster, as the connection pools are shared by the priorities.
+	Cooldown api.DurationConfig `json:"cooldown,omitempty"`
+	// HalfOpenRequests is the number of the probe requests after the cooldown, 1 by default.
+	// The circuit breaker closes if all the probes succeed, and opens again if any of them fails.
+	HalfOpenRequests uint32 `json:"half_open_requests,omitempty"`
+	// RetryBudget limits the active retries by the active requests, it takes precedence over the MaxRetries
+	RetryBudget *RetryBudget `json:"retry_budget,omitempty"`
+}
+
+// RetryBudget limits the active retries to a percentage of the active requests of the cluster
+type RetryBudget struct {
+	// BudgetPercent is the max percentage of the active requests that may be retries, defaults to 20
+	BudgetPercent float64 `json:"budget_percent,omitempty"`
+	// MinRetryConcurrency is the active retries allowed regardless of the active requests, defaults to 3
+	MinRetryConcurrency uint32 `json:"min_retry_concurrency,omitempty"`
+}
+
+// KeepAliveConfig is a configuration of the heartbeats on the upstream connections
+type KeepAliveConfig struct {
+	// HeartbeatInterval is the interval to send a heartbeat on the idle connection
+	HeartbeatInterval api.DurationConfig `json:"heartbeat_interval,omitempty"`
+	// MaxFailures is the count of the consecutive heartbeat failures to close the connection
+	MaxFailures uint32 `json:"max_failures,omitempty"`
 }
 
 // ClusterSpecInfo is a configuration of subscribe
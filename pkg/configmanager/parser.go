@@ -411,8 +411,23 @@ func AddOrUpdateNetworkFilterFactories(listenerName string, ln *v2.Listener) []a
 		return nil
 	}
 
+	factories := NewNetworkFilterFactories(ln, &ln.FilterChains[0])
+	if len(factories) == 0 {
+		log.DefaultLogger.Errorf("[config] network filter factories len is 0, listener: %+v", ln)
+		return nil
+	}
+
+	networkFilterFactoryMap.Store(listenerName, factories)
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[config] AddOrUpdateNetworkFilterFactories store network filter factories, name: %v", listenerName)
+	}
+
+	return factories
+}
+
+// NewNetworkFilterFactories creates the network filter factories of a filter chain in the listener
+func NewNetworkFilterFactories(ln *v2.Listener, c *v2.FilterChain) []api.NetworkFilterChainFactory {
 	var factories []api.NetworkFilterChainFactory
-	c := ln.FilterChains[0]
 	for _, f := range c.Filters {
 		factory, err := api.CreateNetworkFilterChainFactory(f.Type, f.Config)
 		if err != nil {
@@ -429,17 +444,6 @@ func AddOrUpdateNetworkFilterFactories(listenerName string, ln *v2.Listener) []a
 			factories = append(factories, factory)
 		}
 	}
-
-	if len(factories) == 0 {
-		log.DefaultLogger.Errorf("[config] network filter factories len is 0, listener: %+v", ln)
-		return nil
-	}
-
-	networkFilterFactoryMap.Store(listenerName, factories)
-	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
-		log.DefaultLogger.Debugf("[config] AddOrUpdateNetworkFilterFactories store network filter factories, name: %v", listenerName)
-	}
-
 	return factories
 }
 
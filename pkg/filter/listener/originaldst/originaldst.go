@@ -24,6 +24,7 @@ import (
 	"syscall"
 
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/mtls"
 )
 
 // OriginDST, option for syscall.GetsockoptIPv6Mreq
@@ -33,7 +34,11 @@ const (
 )
 
 func getOriginalAddr(conn net.Conn) ([]byte, int, error) {
-	tc := conn.(*net.TCPConn)
+	// the connection peeked for the server name is unwrapped
+	tc, ok := mtls.TCPConn(conn)
+	if !ok {
+		return nil, 0, errors.New("conn is not a tcp connection")
+	}
 
 	f, err := tc.File()
 	if err != nil {
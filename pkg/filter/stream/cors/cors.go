@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cors
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	headerOrigin           = "origin"
+	headerVary             = "vary"
+	headerRequestMethod    = "access-control-request-method"
+	headerRequestHeaders   = "access-control-request-headers"
+	headerAllowOrigin      = "access-control-allow-origin"
+	headerAllowMethods     = "access-control-allow-methods"
+	headerAllowHeaders     = "access-control-allow-headers"
+	headerAllowCredentials = "access-control-allow-credentials"
+	headerExposeHeaders    = "access-control-expose-headers"
+	headerMaxAge           = "access-control-max-age"
+)
+
+// corsFilter answers the preflight requests of the allowed origins directly,
+// and adds the cors headers to the responses of the actual requests.
+// see https://fetch.spec.whatwg.org/#http-cors-protocol
+type corsFilter struct {
+	ctx    context.Context
+	config *corsConfig
+	// origin is the allowed origin of the actual request
+	origin string
+
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+}
+
+func NewStreamFilter(ctx context.Context, config *corsConfig) *corsFilter {
+	return &corsFilter{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (f *corsFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *corsFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	origin, ok := headers.Get(headerOrigin)
+	if !ok || origin == "" {
+		return api.StreamFilterContinue
+	}
+	if !f.config.allowOrigin(origin) {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter] [cors] origin %s is not allowed", origin)
+		}
+		return api.StreamFilterContinue
+	}
+	method, _ := variable.GetString(ctx, types.VarMethod)
+	requestMethod, preflight := headers.Get(headerRequestMethod)
+	if method != http.MethodOptions || !preflight || requestMethod == "" {
+		f.origin = origin
+		return api.StreamFilterContinue
+	}
+
+	// answer the preflight request
+	respHeaders := newResponseHeaders(ctx)
+	f.setAllowOrigin(respHeaders, origin)
+	respHeaders.Set(headerAllowMethods, f.config.allowMethods)
+	allowHeaders := f.config.allowHeaders
+	if allowHeaders == "" {
+		allowHeaders, _ = headers.Get(headerRequestHeaders)
+	}
+	if allowHeaders != "" {
+		respHeaders.Set(headerAllowHeaders, allowHeaders)
+	}
+	if f.config.maxAge != "" {
+		respHeaders.Set(headerMaxAge, f.config.maxAge)
+	}
+	f.receiveHandler.SendHijackReply(http.StatusNoContent, respHeaders)
+	return api.StreamFilterStop
+}
+
+func (f *corsFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *corsFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.origin == "" {
+		return api.StreamFilterContinue
+	}
+	f.setAllowOrigin(headers, f.origin)
+	if f.config.exposeHeaders != "" {
+		headers.Set(headerExposeHeaders, f.config.exposeHeaders)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *corsFilter) OnDestroy() {}
+
+// setAllowOrigin sets the allowed origin and the credentials flag, the origin is echoed
+// unless all origins are allowed without credentials.
+func (f *corsFilter) setAllowOrigin(headers api.HeaderMap, origin string) {
+	if f.config.anyOrigin && !f.config.allowCredentials {
+		headers.Set(headerAllowOrigin, "*")
+		return
+	}
+	headers.Set(headerAllowOrigin, origin)
+	if vary, ok := headers.Get(headerVary); ok && vary != "" {
+		headers.Set(headerVary, vary+", Origin")
+	} else {
+		headers.Set(headerVary, "Origin")
+	}
+	if f.config.allowCredentials {
+		headers.Set(headerAllowCredentials, "true")
+	}
+}
+
+// newResponseHeaders creates the headers of the preflight response, the http1 downstream requires a http1 response header
+func newResponseHeaders(ctx context.Context) api.HeaderMap {
+	if pv, err := variable.Get(ctx, types.VariableDownStreamProtocol); err == nil && pv == protocol.HTTP1 {
+		return mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	}
+	return protocol.CommonHeader{}
+}
@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cors
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	hijackCode    int
+	hijackHeaders api.HeaderMap
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+	h.hijackHeaders = headers
+}
+
+func newTestFilter(t *testing.T, conf map[string]interface{}) (*corsFilter, *mockReceiveHandler) {
+	factory, err := CreateCorsFilterFactory(conf)
+	require.Nil(t, err)
+	filter := NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+	handler := &mockReceiveHandler{}
+	filter.SetReceiveFilterHandler(handler)
+	return filter, handler
+}
+
+func newRequestContext(method string) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableDownStreamProtocol, protocol.HTTP2)
+	variable.SetString(ctx, types.VarMethod, method)
+	return ctx
+}
+
+func TestCreateCorsFilterFactory(t *testing.T) {
+	_, err := CreateCorsFilterFactory(map[string]interface{}{})
+	assert.Equal(t, errNoOrigin, err)
+
+	factory, err := CreateCorsFilterFactory(map[string]interface{}{
+		"allow_origins": []interface{}{"*"},
+	})
+	require.Nil(t, err)
+	config := factory.(*FilterConfigFactory).config
+	assert.True(t, config.anyOrigin)
+	assert.Equal(t, "GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS", config.allowMethods)
+	assert.Equal(t, "", config.maxAge)
+}
+
+func TestCorsAllowOrigin(t *testing.T) {
+	config, err := makeCorsConfig(&v2.StreamCors{
+		AllowOrigins: []string{"https://*.example.com", "http://localhost:8080"},
+	})
+	require.Nil(t, err)
+	for _, tc := range []struct {
+		origin  string
+		allowed bool
+	}{
+		{"http://localhost:8080", true},
+		{"HTTP://LOCALHOST:8080", true},
+		{"http://localhost:8081", false},
+		{"https://app.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false},
+		{"http://app.example.com", false},
+		{"https://app.example.com.evil.com", false},
+	} {
+		assert.Equalf(t, tc.allowed, config.allowOrigin(tc.origin), "origin %s", tc.origin)
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	filter, handler := newTestFilter(t, map[string]interface{}{
+		"allow_origins":     []interface{}{"https://*.example.com"},
+		"allow_methods":     []interface{}{"GET", "post"},
+		"max_age":           "10m",
+		"allow_credentials": true,
+	})
+	headers := protocol.CommonHeader{
+		"origin":                         "https://app.example.com",
+		"access-control-request-method":  "POST",
+		"access-control-request-headers": "content-type,x-token",
+	}
+	status := filter.OnReceive(newRequestContext(http.MethodOptions), headers, nil, nil)
+	assert.Equal(t, api.StreamFilterStop, status)
+	assert.Equal(t, http.StatusNoContent, handler.hijackCode)
+	require.NotNil(t, handler.hijackHeaders)
+	expected := map[string]string{
+		"access-control-allow-origin":      "https://app.example.com",
+		"access-control-allow-methods":     "GET,POST",
+		"access-control-allow-headers":     "content-type,x-token",
+		"access-control-allow-credentials": "true",
+		"access-control-max-age":           "600",
+		"vary":                             "Origin",
+	}
+	for k, v := range expected {
+		got, ok := handler.hijackHeaders.Get(k)
+		assert.Truef(t, ok, "header %s is missing", k)
+		assert.Equalf(t, v, got, "header %s", k)
+	}
+	// the preflight response is not changed by the sender
+	assert.Equal(t, api.StreamFilterContinue, filter.Append(context.Background(), handler.hijackHeaders, nil, nil))
+
+	// the preflight of a disallowed origin is sent to the upstream
+	filter, handler = newTestFilter(t, map[string]interface{}{
+		"allow_origins": []interface{}{"https://*.example.com"},
+	})
+	headers = protocol.CommonHeader{
+		"origin":                        "https://app.example.org",
+		"access-control-request-method": "POST",
+	}
+	status = filter.OnReceive(newRequestContext(http.MethodOptions), headers, nil, nil)
+	assert.Equal(t, api.StreamFilterContinue, status)
+	assert.Equal(t, 0, handler.hijackCode)
+}
+
+func TestCorsActualRequest(t *testing.T) {
+	testCases := []struct {
+		conf     map[string]interface{}
+		origin   string
+		expected map[string]string
+	}{
+		// the allowed origin is echoed with credentials
+		{
+			conf: map[string]interface{}{
+				"allow_origins":     []interface{}{"https://app.example.com"},
+				"expose_headers":    []interface{}{"x-request-id", "x-trace-id"},
+				"allow_credentials": true,
+			},
+			origin: "https://app.example.com",
+			expected: map[string]string{
+				"access-control-allow-origin":      "https://app.example.com",
+				"access-control-allow-credentials": "true",
+				"access-control-expose-headers":    "x-request-id,x-trace-id",
+				"vary":                             "Accept-Encoding, Origin",
+			},
+		},
+		// all origins are allowed without credentials
+		{
+			conf: map[string]interface{}{
+				"allow_origins": []interface{}{"*"},
+			},
+			origin: "https://any.example.org",
+			expected: map[string]string{
+				"access-control-allow-origin": "*",
+				"vary":                        "Accept-Encoding",
+			},
+		},
+		// the disallowed origin gets no cors headers
+		{
+			conf: map[string]interface{}{
+				"allow_origins": []interface{}{"https://app.example.com"},
+			},
+			origin: "https://evil.example.com",
+			expected: map[string]string{
+				"vary": "Accept-Encoding",
+			},
+		},
+	}
+	for i, tc := range testCases {
+		filter, handler := newTestFilter(t, tc.conf)
+		headers := protocol.CommonHeader{
+			"origin": tc.origin,
+		}
+		status := filter.OnReceive(newRequestContext(http.MethodGet), headers, nil, nil)
+		assert.Equalf(t, api.StreamFilterContinue, status, "#%d", i)
+		assert.Equalf(t, 0, handler.hijackCode, "#%d", i)
+
+		respHeaders := protocol.CommonHeader{
+			"content-type": "application/json",
+			"vary":         "Accept-Encoding",
+		}
+		status = filter.Append(context.Background(), respHeaders, nil, nil)
+		assert.Equalf(t, api.StreamFilterContinue, status, "#%d", i)
+		for _, k := range []string{
+			"access-control-allow-origin", "access-control-allow-credentials",
+			"access-control-expose-headers", "vary",
+		} {
+			v, ok := respHeaders.Get(k)
+			expected, expectedOk := tc.expected[k]
+			assert.Equalf(t, expectedOk, ok, "#%d header %s", i, k)
+			assert.Equalf(t, expected, v, "#%d header %s", i, k)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+var errNoOrigin = errors.New("at least one allowed origin is required")
+
+func init() {
+	api.RegisterStream(v2.Cors, CreateCorsFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	config *corsConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	callbacks.AddStreamSenderFilter(filter, api.BeforeSend)
+}
+
+// CreateCorsFilterFactory creates the cors filter factory
+func CreateCorsFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create cors stream filter factory")
+	cfg, err := ParseStreamCorsFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeCorsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamCorsFilter
+func ParseStreamCorsFilter(cfg map[string]interface{}) (*v2.StreamCors, error) {
+	filterConfig := &v2.StreamCors{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// corsConfig is parsed from v2.StreamCors, the header values are joined in advance
+type corsConfig struct {
+	anyOrigin bool
+	origins   map[string]struct{}
+	// wildcard origins are stored as the scheme and the domain suffix, such as "https://" and ".example.com"
+	wildcards        [][2]string
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	maxAge           string
+	allowCredentials bool
+}
+
+// defaultAllowMethods are the methods allowed if no method is configured
+var defaultAllowMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+func makeCorsConfig(cfg *v2.StreamCors) (*corsConfig, error) {
+	if len(cfg.AllowOrigins) == 0 {
+		return nil, errNoOrigin
+	}
+	config := &corsConfig{
+		origins:          make(map[string]struct{}, len(cfg.AllowOrigins)),
+		allowHeaders:     strings.Join(cfg.AllowHeaders, ","),
+		exposeHeaders:    strings.Join(cfg.ExposeHeaders, ","),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			config.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			idx := strings.Index(origin, "*.")
+			config.wildcards = append(config.wildcards, [2]string{origin[:idx], origin[idx+1:]})
+		default:
+			config.origins[origin] = struct{}{}
+		}
+	}
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultAllowMethods
+	}
+	config.allowMethods = strings.ToUpper(strings.Join(methods, ","))
+	if cfg.MaxAge.Duration > 0 {
+		config.maxAge = strconv.FormatInt(int64(cfg.MaxAge.Duration.Seconds()), 10)
+	}
+	return config, nil
+}
+
+// allowOrigin checks whether the origin is allowed
+func (c *corsConfig) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := c.origins[origin]; ok {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) &&
+			len(origin) > len(w[0])+len(w[1]) {
+			return true
+		}
+	}
+	return false
+}
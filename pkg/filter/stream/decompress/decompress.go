@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/log"
+)
+
+const (
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+)
+
+var errTooLarge = errors.New("decompressed body is too large")
+
+// decompressFilter decompresses the request body, so the upstream receives the plain body
+type decompressFilter struct {
+	config  *decompressConfig
+	handler api.StreamReceiverFilterHandler
+}
+
+func NewStreamFilter(ctx context.Context, config *decompressConfig) *decompressFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [decompress] create a new decompress filter")
+	}
+	return &decompressFilter{
+		config: config,
+	}
+}
+
+func (f *decompressFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *decompressFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	ce, ok := headers.Get(headerContentEncoding)
+	if !ok {
+		return api.StreamFilterContinue
+	}
+	encoding := strings.ToLower(strings.TrimSpace(ce))
+	if !f.config.encodings[encoding] {
+		return api.StreamFilterContinue
+	}
+
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	data, err := decompress(encoding, body, f.config.maxDecompressedBytes)
+	switch err {
+	case nil:
+	case errTooLarge:
+		log.Proxy.Errorf(ctx, "[stream filter] [decompress] decompressed request body exceeds the limit: %d", f.config.maxDecompressedBytes)
+		f.handler.RequestInfo().SetResponseFlag(api.ReqEntityTooLarge)
+		f.handler.SendHijackReply(http.StatusRequestEntityTooLarge, headers)
+		return api.StreamFilterStop
+	default:
+		log.Proxy.Errorf(ctx, "[stream filter] [decompress] decompress request body with %s failed: %v", encoding, err)
+		f.handler.SendHijackReply(http.StatusBadRequest, headers)
+		return api.StreamFilterStop
+	}
+
+	headers.Del(headerContentEncoding)
+	if _, ok := headers.Get(headerContentLength); ok {
+		headers.Set(headerContentLength, strconv.Itoa(len(data)))
+	}
+	f.handler.SetRequestData(buffer.NewIoBufferBytes(data))
+	return api.StreamFilterContinue
+}
+
+// NeedRequestBody implements streamfilter.StreamReceiverFilterBodyBuffering, the compressed body is decompressed as a whole
+func (f *decompressFilter) NeedRequestBody(ctx context.Context, headers api.HeaderMap) bool {
+	ce, ok := headers.Get(headerContentEncoding)
+	return ok && f.config.encodings[strings.ToLower(strings.TrimSpace(ce))]
+}
+
+func (f *decompressFilter) OnDestroy() {}
+
+// decompress returns the decompressed body, errTooLarge is returned if the decompressed body
+// exceeds the limit, the body is never decompressed beyond the limit to avoid decompression bombs.
+func decompress(encoding string, body []byte, limit uint64) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch encoding {
+	case encodingDeflate:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out := &bytes.Buffer{}
+	// read one more byte to know whether the limit is exceeded
+	n, err := io.Copy(out, io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) > limit {
+		return nil, errTooLarge
+	}
+	return out.Bytes(), nil
+}
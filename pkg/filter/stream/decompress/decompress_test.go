@@ -0,0 +1,199 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	data       buffer.IoBuffer
+	hijackCode int
+	info       api.RequestInfo
+}
+
+func (h *mockReceiveHandler) SetRequestData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+func (h *mockReceiveHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func gzipBody(t *testing.T, body string) []byte {
+	b := &bytes.Buffer{}
+	w := gzip.NewWriter(b)
+	_, err := w.Write([]byte(body))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	return b.Bytes()
+}
+
+func deflateBody(t *testing.T, body string) []byte {
+	b := &bytes.Buffer{}
+	w := zlib.NewWriter(b)
+	_, err := w.Write([]byte(body))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	return b.Bytes()
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}) (*decompressFilter, *mockReceiveHandler) {
+	factory, err := CreateDecompressFilterFactory(conf)
+	require.Nil(t, err)
+	f := NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+	handler := &mockReceiveHandler{
+		info: network.NewRequestInfo(),
+	}
+	f.SetReceiveFilterHandler(handler)
+	return f, handler
+}
+
+func TestCreateDecompressFilterFactory(t *testing.T) {
+	factory, err := CreateDecompressFilterFactory(map[string]interface{}{})
+	require.Nil(t, err)
+	config := factory.(*FilterConfigFactory).config
+	assert.Equal(t, map[string]bool{encodingGzip: true}, config.encodings)
+	assert.Equal(t, uint64(defaultMaxDecompressedBytes), config.maxDecompressedBytes)
+
+	factory, err = CreateDecompressFilterFactory(map[string]interface{}{
+		"encodings":              []string{"GZIP", "deflate"},
+		"max_decompressed_bytes": 1024,
+	})
+	require.Nil(t, err)
+	config = factory.(*FilterConfigFactory).config
+	assert.Equal(t, map[string]bool{encodingGzip: true, encodingDeflate: true}, config.encodings)
+	assert.Equal(t, uint64(1024), config.maxDecompressedBytes)
+
+	_, err = CreateDecompressFilterFactory(map[string]interface{}{
+		"encodings": []string{"br"},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestDecompressRequest(t *testing.T) {
+	plain := strings.Repeat("hello mosn, ", 64)
+	testCases := []struct {
+		encoding string
+		body     []byte
+	}{
+		{encodingGzip, gzipBody(t, plain)},
+		{"Gzip", gzipBody(t, plain)},
+		{encodingDeflate, deflateBody(t, plain)},
+	}
+	for _, tc := range testCases {
+		f, handler := newFilter(t, map[string]interface{}{
+			"encodings": []string{encodingGzip, encodingDeflate},
+		})
+		headers := protocol.CommonHeader(map[string]string{
+			headerContentEncoding: tc.encoding,
+			headerContentLength:   strconv.Itoa(len(tc.body)),
+		})
+		status := f.OnReceive(context.Background(), headers, buffer.NewIoBufferBytes(tc.body), nil)
+		require.Equal(t, api.StreamFilterContinue, status)
+		require.NotNil(t, handler.data, "encoding: %s", tc.encoding)
+		assert.Equal(t, plain, handler.data.String())
+		_, ok := headers.Get(headerContentEncoding)
+		assert.False(t, ok)
+		cl, _ := headers.Get(headerContentLength)
+		assert.Equal(t, strconv.Itoa(len(plain)), cl)
+	}
+}
+
+func TestDecompressIgnored(t *testing.T) {
+	plain := "hello mosn"
+	// no content encoding
+	f, handler := newFilter(t, map[string]interface{}{})
+	headers := protocol.CommonHeader(map[string]string{})
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(context.Background(), headers, buffer.NewIoBufferString(plain), nil))
+	assert.Nil(t, handler.data)
+
+	// deflate is not enabled
+	body := deflateBody(t, plain)
+	headers = protocol.CommonHeader(map[string]string{
+		headerContentEncoding: encodingDeflate,
+	})
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(context.Background(), headers, buffer.NewIoBufferBytes(body), nil))
+	assert.Nil(t, handler.data)
+	ce, _ := headers.Get(headerContentEncoding)
+	assert.Equal(t, encodingDeflate, ce)
+}
+
+func TestDecompressRejected(t *testing.T) {
+	// a small body decompressed to a large one
+	bomb := gzipBody(t, strings.Repeat("0", 1024*1024))
+	f, handler := newFilter(t, map[string]interface{}{
+		"max_decompressed_bytes": 1024,
+	})
+	headers := protocol.CommonHeader(map[string]string{
+		headerContentEncoding: encodingGzip,
+	})
+	status := f.OnReceive(context.Background(), headers, buffer.NewIoBufferBytes(bomb), nil)
+	assert.Equal(t, api.StreamFilterStop, status)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, handler.hijackCode)
+	assert.True(t, handler.info.GetResponseFlag(api.ReqEntityTooLarge))
+	assert.Nil(t, handler.data)
+
+	// the body exactly reaches the limit
+	f, handler = newFilter(t, map[string]interface{}{
+		"max_decompressed_bytes": 1024,
+	})
+	status = f.OnReceive(context.Background(), headers, buffer.NewIoBufferBytes(gzipBody(t, strings.Repeat("0", 1024))), nil)
+	assert.Equal(t, api.StreamFilterContinue, status)
+	assert.Equal(t, 1024, handler.data.Len())
+
+	// invalid gzip body
+	f, handler = newFilter(t, map[string]interface{}{})
+	headers = protocol.CommonHeader(map[string]string{
+		headerContentEncoding: encodingGzip,
+	})
+	status = f.OnReceive(context.Background(), headers, buffer.NewIoBufferString("not gzip"), nil)
+	assert.Equal(t, api.StreamFilterStop, status)
+	assert.Equal(t, http.StatusBadRequest, handler.hijackCode)
+}
+
+func TestDecompressNeedRequestBody(t *testing.T) {
+	f, _ := newFilter(t, map[string]interface{}{})
+	assert.True(t, f.NeedRequestBody(context.Background(), protocol.CommonHeader(map[string]string{
+		headerContentEncoding: "Gzip",
+	})))
+	assert.False(t, f.NeedRequestBody(context.Background(), protocol.CommonHeader(map[string]string{
+		headerContentEncoding: encodingDeflate,
+	})))
+	assert.False(t, f.NeedRequestBody(context.Background(), protocol.CommonHeader(map[string]string{})))
+}
@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decompress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+
+	// defaultMaxDecompressedBytes limits the decompressed body to 4MB by default
+	defaultMaxDecompressedBytes = 4 * 1024 * 1024
+)
+
+var errUnsupportedEncoding = errors.New("unsupported encoding, only gzip and deflate are supported")
+
+func init() {
+	api.RegisterStream(v2.Decompress, CreateDecompressFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	config *decompressConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+}
+
+// CreateDecompressFilterFactory creates the decompress filter factory, gzip is decompressed by default
+func CreateDecompressFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create decompress stream filter factory")
+	cfg, err := ParseStreamDecompressFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeDecompressConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamDecompressFilter
+func ParseStreamDecompressFilter(cfg map[string]interface{}) (*v2.StreamDecompress, error) {
+	filterConfig := &v2.StreamDecompress{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// decompressConfig is parsed from v2.StreamDecompress
+type decompressConfig struct {
+	encodings            map[string]bool
+	maxDecompressedBytes uint64
+}
+
+func makeDecompressConfig(cfg *v2.StreamDecompress) (*decompressConfig, error) {
+	config := &decompressConfig{
+		encodings:            map[string]bool{},
+		maxDecompressedBytes: cfg.MaxDecompressedBytes,
+	}
+	if config.maxDecompressedBytes == 0 {
+		config.maxDecompressedBytes = defaultMaxDecompressedBytes
+	}
+	encodings := cfg.Encodings
+	if len(encodings) == 0 {
+		encodings = []string{encodingGzip}
+	}
+	for _, encoding := range encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != encodingGzip && encoding != encodingDeflate {
+			return nil, fmt.Errorf("%w: %s", errUnsupportedEncoding, encoding)
+		}
+		config.encodings[encoding] = true
+	}
+	return config, nil
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extauthz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var errEmptyResponse = errors.New("empty authorization response")
+
+// checkRequest is the request attributes sent to the authorization service
+type checkRequest struct {
+	method  string
+	host    string
+	path    string
+	headers map[string]string
+	body    []byte
+}
+
+// checkResponse is the decision of the authorization service
+type checkResponse struct {
+	allowed bool
+	// status is the http status code of the denied response
+	status int
+	// headers are added to the allowed request, or sent with the denied response
+	headers map[string]string
+}
+
+// authClient calls the authorization service, an error is returned only if the service is unavailable
+type authClient interface {
+	check(ctx context.Context, req *checkRequest) (*checkResponse, error)
+}
+
+// httpAuthClient sends the request with the same method and path to the authorization service
+type httpAuthClient struct {
+	serverUri      string
+	allowedHeaders map[string]bool
+	client         *http.Client
+}
+
+func newHttpAuthClient(serverUri string, allowedUpstreamHeaders []string) *httpAuthClient {
+	allowed := make(map[string]bool, len(allowedUpstreamHeaders))
+	for _, h := range allowedUpstreamHeaders {
+		allowed[strings.ToLower(h)] = true
+	}
+	return &httpAuthClient{
+		serverUri:      strings.TrimSuffix(serverUri, "/"),
+		allowedHeaders: allowed,
+		client:         &http.Client{},
+	}
+}
+
+func (c *httpAuthClient) check(ctx context.Context, req *checkRequest) (*checkResponse, error) {
+	var body io.Reader
+	if len(req.body) > 0 {
+		body = bytes.NewReader(req.body)
+	}
+	hreq, err := http.NewRequest(req.method, c.serverUri+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+	hreq = hreq.WithContext(ctx)
+	for k, v := range req.headers {
+		hreq.Header.Set(k, v)
+	}
+	if req.host != "" {
+		hreq.Host = req.host
+	}
+	resp, err := c.client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return &checkResponse{
+			status: resp.StatusCode,
+		}, nil
+	}
+	result := &checkResponse{
+		allowed: true,
+		headers: map[string]string{},
+	}
+	for k := range resp.Header {
+		if key := strings.ToLower(k); c.allowedHeaders[key] {
+			result.headers[key] = resp.Header.Get(k)
+		}
+	}
+	return result, nil
+}
+
+// grpcAuthClient calls the envoy.service.auth.v3.Authorization service
+type grpcAuthClient struct {
+	client authv3.AuthorizationClient
+}
+
+func newGrpcAuthClient(address string) (*grpcAuthClient, error) {
+	// the connection is established in the background
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("dial authorization service %s failed: %v", address, err)
+	}
+	return &grpcAuthClient{
+		client: authv3.NewAuthorizationClient(conn),
+	}, nil
+}
+
+func (c *grpcAuthClient) check(ctx context.Context, req *checkRequest) (*checkResponse, error) {
+	resp, err := c.client.Check(ctx, &authv3.CheckRequest{
+		Attributes: &authv3.AttributeContext{
+			Request: &authv3.AttributeContext_Request{
+				Http: &authv3.AttributeContext_HttpRequest{
+					Method:  req.method,
+					Host:    req.host,
+					Path:    req.path,
+					Headers: req.headers,
+					Body:    string(req.body),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetStatus() == nil {
+		return nil, errEmptyResponse
+	}
+	if resp.GetStatus().GetCode() == int32(codes.OK) {
+		result := &checkResponse{
+			allowed: true,
+			headers: map[string]string{},
+		}
+		for _, h := range resp.GetOkResponse().GetHeaders() {
+			result.headers[strings.ToLower(h.GetHeader().GetKey())] = h.GetHeader().GetValue()
+		}
+		return result, nil
+	}
+	result := &checkResponse{
+		status:  http.StatusForbidden,
+		headers: map[string]string{},
+	}
+	denied := resp.GetDeniedResponse()
+	if code := int(denied.GetStatus().GetCode()); code != 0 {
+		result.status = code
+	}
+	for _, h := range denied.GetHeaders() {
+		result.headers[strings.ToLower(h.GetHeader().GetKey())] = h.GetHeader().GetValue()
+	}
+	return result, nil
+}
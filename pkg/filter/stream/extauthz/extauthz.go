@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/streamfilter"
+	"mosn.io/mosn/pkg/types"
+)
+
+// extAuthzFilter asks the authorization service whether the request is allowed.
+// the stream is held while the authorization call is in flight and resumed by ContinueReceiving,
+// which requires the before route receiver filter timeout of the proxy is configured and longer than
+// the call timeout. otherwise the stream cannot be held, and the request waits for the decision.
+type extAuthzFilter struct {
+	ctx       context.Context
+	config    *extAuthzConfig
+	handler   api.StreamReceiverFilterHandler
+	cancel    context.CancelFunc
+	destroyed uint32
+}
+
+func NewStreamFilter(ctx context.Context, config *extAuthzConfig) *extAuthzFilter {
+	return &extAuthzFilter{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (f *extAuthzFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *extAuthzFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	req := f.makeRequest(ctx, headers, buf)
+	callCtx, cancel := context.WithTimeout(context.Background(), f.config.timeout)
+	f.cancel = cancel
+
+	continuer, ok := f.handler.(streamfilter.StreamReceiverFilterContinuer)
+	if !ok || !continuer.CanHoldReceiving() {
+		// the stream cannot be held, waits for the decision
+		defer cancel()
+		resp, err := f.config.client.check(callCtx, req)
+		return f.onResponse(ctx, headers, resp, err)
+	}
+	utils.GoWithRecover(func() {
+		defer cancel()
+		resp, err := f.config.client.check(callCtx, req)
+		if atomic.LoadUint32(&f.destroyed) == 1 {
+			return
+		}
+		f.onResponse(ctx, headers, resp, err)
+		// wakes up the stream, the hijack reply is sent if the request is denied
+		continuer.ContinueReceiving()
+	}, nil)
+	return api.StreamFilterStop
+}
+
+func (f *extAuthzFilter) OnDestroy() {
+	atomic.StoreUint32(&f.destroyed, 1)
+	if f.cancel != nil {
+		f.cancel()
+	}
+}
+
+// onResponse applies the decision of the authorization service, returns api.StreamFilterStop if the request is denied
+func (f *extAuthzFilter) onResponse(ctx context.Context, headers api.HeaderMap, resp *checkResponse, err error) api.StreamFilterStatus {
+	if err != nil {
+		if f.config.failOpen {
+			log.Proxy.Warnf(ctx, "[stream filter] [ext_authz] call authorization service failed, allow the request: %v", err)
+			return api.StreamFilterContinue
+		}
+		log.Proxy.Errorf(ctx, "[stream filter] [ext_authz] call authorization service failed, deny the request: %v", err)
+		f.handler.SendHijackReply(http.StatusForbidden, headers)
+		return api.StreamFilterStop
+	}
+	if !resp.allowed {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter] [ext_authz] request is denied with status %d", resp.status)
+		}
+		f.handler.SendHijackReply(resp.status, protocol.CommonHeader(resp.headers))
+		return api.StreamFilterStop
+	}
+	for k, v := range resp.headers {
+		headers.Set(k, v)
+	}
+	return api.StreamFilterContinue
+}
+
+// makeRequest copies the request attributes, which are used by the authorization call asynchronously
+func (f *extAuthzFilter) makeRequest(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer) *checkRequest {
+	req := &checkRequest{
+		headers: make(map[string]string),
+	}
+	req.method, _ = variable.GetString(ctx, types.VarMethod)
+	req.host, _ = variable.GetString(ctx, types.VarHost)
+	req.path, _ = variable.GetString(ctx, types.VarPath)
+	if query, err := variable.GetString(ctx, types.VarQueryString); err == nil && query != "" {
+		req.path += "?" + query
+	}
+	headers.Range(func(key, value string) bool {
+		key = strings.ToLower(key)
+		if f.config.allowedHeaders == nil || f.config.allowedHeaders[key] {
+			req.headers[key] = value
+		}
+		return true
+	})
+	if f.config.withRequestBody && buf != nil && buf.Len() > 0 {
+		body := buf.Bytes()
+		if max := int(f.config.maxRequestBytes); max > 0 && len(body) > max {
+			body = body[:max]
+		}
+		req.body = append([]byte(nil), body...)
+	}
+	return req
+}
@@ -0,0 +1,311 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extauthz
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// mockReceiveHandler holds the stream until ContinueReceiving is called if hold is true
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	hold          bool
+	hijackCode    int
+	hijackHeaders api.HeaderMap
+	continued     chan struct{}
+}
+
+func newMockReceiveHandler() *mockReceiveHandler {
+	return &mockReceiveHandler{
+		hold:      true,
+		continued: make(chan struct{}, 1),
+	}
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+	h.hijackHeaders = headers
+}
+
+func (h *mockReceiveHandler) CanHoldReceiving() bool {
+	return h.hold
+}
+
+func (h *mockReceiveHandler) ContinueReceiving() {
+	h.continued <- struct{}{}
+}
+
+func newRequest(method, path string) (context.Context, api.HeaderMap) {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, types.VarMethod, method)
+	_ = variable.SetString(ctx, types.VarHost, "example.com")
+	_ = variable.SetString(ctx, types.VarPath, path)
+	headers := protocol.CommonHeader{
+		"authorization": "token",
+		"x-ignored":     "ignored",
+	}
+	return ctx, headers
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}) *extAuthzFilter {
+	factory, err := CreateExtAuthzFilterFactory(conf)
+	require.Nil(t, err)
+	return NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+}
+
+// receive runs the filter, the held stream waits for it is resumed,
+// otherwise the stream stops only if the request is denied
+func receive(t *testing.T, f *extAuthzFilter, handler *mockReceiveHandler, ctx context.Context, headers api.HeaderMap, body buffer.IoBuffer) {
+	f.SetReceiveFilterHandler(handler)
+	status := f.OnReceive(ctx, headers, body, nil)
+	if handler.hold {
+		require.Equal(t, api.StreamFilterStop, status)
+		select {
+		case <-handler.continued:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the stream is not resumed")
+		}
+		return
+	}
+	if handler.hijackCode != 0 {
+		require.Equal(t, api.StreamFilterStop, status)
+	} else {
+		require.Equal(t, api.StreamFilterContinue, status)
+	}
+}
+
+func newHttpAuthServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the allowed headers are sent
+		assert.Equal(t, "", r.Header.Get("x-ignored"))
+		assert.Equal(t, "example.com", r.Host)
+		switch r.URL.Path {
+		case "/allow":
+			assert.Equal(t, "token", r.Header.Get("authorization"))
+			w.Header().Set("x-user", "alice")
+			w.Header().Set("x-not-allowed", "value")
+			w.WriteHeader(http.StatusOK)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+}
+
+func TestHttpExtAuthz(t *testing.T) {
+	server := newHttpAuthServer(t)
+	defer server.Close()
+	conf := func(failOpen bool) map[string]interface{} {
+		return map[string]interface{}{
+			"http_service": map[string]interface{}{
+				"server_uri":               server.URL,
+				"allowed_upstream_headers": []string{"x-user"},
+			},
+			"allowed_headers": []string{"authorization"},
+			"timeout":         "50ms",
+			"fail_open":       failOpen,
+		}
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		f := newFilter(t, conf(false))
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodGet, "/allow")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, 0, handler.hijackCode)
+		user, _ := headers.Get("x-user")
+		assert.Equal(t, "alice", user)
+		_, ok := headers.Get("x-not-allowed")
+		assert.False(t, ok)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		f := newFilter(t, conf(false))
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodGet, "/deny")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, http.StatusUnauthorized, handler.hijackCode)
+	})
+
+	t.Run("timeout fail closed", func(t *testing.T) {
+		f := newFilter(t, conf(false))
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodGet, "/slow")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+	})
+
+	t.Run("timeout fail open", func(t *testing.T) {
+		f := newFilter(t, conf(true))
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodGet, "/slow")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, 0, handler.hijackCode)
+	})
+
+	t.Run("stream not held", func(t *testing.T) {
+		f := newFilter(t, conf(false))
+		handler := newMockReceiveHandler()
+		handler.hold = false
+		ctx, headers := newRequest(http.MethodGet, "/allow")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, 0, handler.hijackCode)
+		user, _ := headers.Get("x-user")
+		assert.Equal(t, "alice", user)
+
+		f = newFilter(t, conf(false))
+		ctx, headers = newRequest(http.MethodGet, "/deny")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, http.StatusUnauthorized, handler.hijackCode)
+		assert.Len(t, handler.continued, 0)
+	})
+
+	t.Run("destroyed before the decision", func(t *testing.T) {
+		f := newFilter(t, conf(false))
+		handler := newMockReceiveHandler()
+		f.SetReceiveFilterHandler(handler)
+		ctx, headers := newRequest(http.MethodGet, "/slow")
+		require.Equal(t, api.StreamFilterStop, f.OnReceive(ctx, headers, nil, nil))
+		f.OnDestroy()
+		select {
+		case <-handler.continued:
+			t.Fatal("the destroyed stream is resumed")
+		case <-time.After(300 * time.Millisecond):
+		}
+		assert.Equal(t, 0, handler.hijackCode)
+	})
+}
+
+type mockAuthServer struct{}
+
+func (s *mockAuthServer) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+	switch httpReq.GetPath() {
+	case "/allow":
+		if httpReq.GetBody() != "body" || httpReq.GetMethod() != http.MethodPost {
+			break
+		}
+		return &authv3.CheckResponse{
+			Status: &status.Status{Code: int32(codes.OK)},
+			HttpResponse: &authv3.CheckResponse_OkResponse{
+				OkResponse: &authv3.OkHttpResponse{
+					Headers: []*corev3.HeaderValueOption{
+						{Header: &corev3.HeaderValue{Key: "x-user", Value: "alice"}},
+					},
+				},
+			},
+		}, nil
+	case "/slow":
+		time.Sleep(200 * time.Millisecond)
+	}
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied)},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status: &typev3.HttpStatus{Code: typev3.StatusCode_Unauthorized},
+				Headers: []*corev3.HeaderValueOption{
+					{Header: &corev3.HeaderValue{Key: "www-authenticate", Value: "Bearer"}},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestGrpcExtAuthz(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(server, &mockAuthServer{})
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conf := map[string]interface{}{
+		"grpc_service": map[string]interface{}{
+			"address": ln.Addr().String(),
+		},
+		"with_request_body": true,
+		"timeout":           "100ms",
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodPost, "/allow")
+		// waits for the grpc connection is established
+		f.config.timeout = time.Second
+		receive(t, f, handler, ctx, headers, buffer.NewIoBufferString("body"))
+		assert.Equal(t, 0, handler.hijackCode)
+		user, _ := headers.Get("x-user")
+		assert.Equal(t, "alice", user)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodPost, "/deny")
+		f.config.timeout = time.Second
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, http.StatusUnauthorized, handler.hijackCode)
+		value, _ := handler.hijackHeaders.Get("www-authenticate")
+		assert.Equal(t, "Bearer", value)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler()
+		ctx, headers := newRequest(http.MethodPost, "/slow")
+		receive(t, f, handler, ctx, headers, nil)
+		assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+	})
+}
+
+func TestCreateExtAuthzFilterFactory(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{},
+		{"http_service": map[string]interface{}{}},
+		{"grpc_service": map[string]interface{}{}},
+		{
+			"http_service": map[string]interface{}{"server_uri": "http://127.0.0.1:8080"},
+			"grpc_service": map[string]interface{}{"address": "127.0.0.1:8080"},
+		},
+	} {
+		_, err := CreateExtAuthzFilterFactory(conf)
+		assert.NotNil(t, err)
+	}
+}
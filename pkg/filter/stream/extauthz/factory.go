@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extauthz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+// defaultTimeout is the default timeout of the authorization call
+const defaultTimeout = 200 * time.Millisecond
+
+var (
+	errNoService       = errors.New("one of http_service and grpc_service is required")
+	errMultipleService = errors.New("only one of http_service and grpc_service can be set")
+	errNoServerUri     = errors.New("the server_uri of http_service is required")
+	errNoAddress       = errors.New("the address of grpc_service is required")
+)
+
+func init() {
+	api.RegisterStream(v2.ExtAuthz, CreateExtAuthzFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	config *extAuthzConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+}
+
+// CreateExtAuthzFilterFactory creates the external authorization filter factory
+func CreateExtAuthzFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create ext authz stream filter factory")
+	cfg, err := ParseStreamExtAuthzFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeExtAuthzConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamExtAuthzFilter
+func ParseStreamExtAuthzFilter(cfg map[string]interface{}) (*v2.StreamExtAuthz, error) {
+	filterConfig := &v2.StreamExtAuthz{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// extAuthzConfig is parsed from v2.StreamExtAuthz, the client is shared by the filters created by the factory
+type extAuthzConfig struct {
+	client          authClient
+	timeout         time.Duration
+	failOpen        bool
+	allowedHeaders  map[string]bool
+	withRequestBody bool
+	maxRequestBytes uint32
+}
+
+func makeExtAuthzConfig(cfg *v2.StreamExtAuthz) (*extAuthzConfig, error) {
+	config := &extAuthzConfig{
+		timeout:         cfg.Timeout.Duration,
+		failOpen:        cfg.FailOpen,
+		withRequestBody: cfg.WithRequestBody,
+		maxRequestBytes: cfg.MaxRequestBytes,
+	}
+	if config.timeout <= 0 {
+		config.timeout = defaultTimeout
+	}
+	if len(cfg.AllowedHeaders) > 0 {
+		config.allowedHeaders = make(map[string]bool, len(cfg.AllowedHeaders))
+		for _, h := range cfg.AllowedHeaders {
+			config.allowedHeaders[strings.ToLower(h)] = true
+		}
+	}
+	switch {
+	case cfg.HttpService != nil && cfg.GrpcService != nil:
+		return nil, errMultipleService
+	case cfg.HttpService != nil:
+		if cfg.HttpService.ServerUri == "" {
+			return nil, errNoServerUri
+		}
+		config.client = newHttpAuthClient(cfg.HttpService.ServerUri, cfg.HttpService.AllowedUpstreamHeaders)
+	case cfg.GrpcService != nil:
+		if cfg.GrpcService.Address == "" {
+			return nil, errNoAddress
+		}
+		client, err := newGrpcAuthClient(cfg.GrpcService.Address)
+		if err != nil {
+			return nil, err
+		}
+		config.client = client
+	default:
+		return nil, errNoService
+	}
+	return config, nil
+}
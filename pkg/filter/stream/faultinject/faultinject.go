@@ -27,27 +27,39 @@ import (
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+)
+
+// the values of the override header
+const (
+	overrideForce = "force"
+	overrideSkip  = "skip"
 )
 
 // faultInjectConfig is parsed from v2.StreamFaultInject
 type faultInjectConfig struct {
-	fixedDelay   time.Duration
-	delayPercent uint32
-	abortStatus  int
-	abortPercent uint32
-	upstream     string
-	headers      types.HeaderMatcher
+	fixedDelay     time.Duration
+	maxDelay       time.Duration
+	delayPercent   uint32
+	abortStatus    int
+	abortPercent   uint32
+	upstream       string
+	headers        types.HeaderMatcher
+	overrideHeader string
 }
 
 func makefaultInjectConfig(cfg *v2.StreamFaultInject) *faultInjectConfig {
 	faultConfig := &faultInjectConfig{
-		upstream: cfg.UpstreamCluster,
-		headers:  router.CreateHTTPHeaderMatcher(cfg.Headers),
+		upstream:       cfg.UpstreamCluster,
+		headers:        router.CreateHTTPHeaderMatcher(cfg.Headers),
+		overrideHeader: cfg.OverrideHeader,
 	}
 	if cfg.Delay != nil {
 		faultConfig.fixedDelay = cfg.Delay.Delay
+		faultConfig.maxDelay = cfg.Delay.MaxDelay
 		faultConfig.delayPercent = cfg.Delay.Percent
 	}
 	if cfg.Abort != nil {
@@ -83,6 +95,7 @@ type streamFaultInjectFilter struct {
 	stop    chan struct{}
 	rander  *rand.Rand
 	headers api.HeaderMap
+	timer   *utils.Timer
 }
 
 func NewFilter(ctx context.Context, cfg *v2.StreamFaultInject) api.StreamReceiverFilter {
@@ -130,22 +143,50 @@ func (f *streamFaultInjectFilter) OnReceive(ctx context.Context, headers api.Hea
 		}
 		return api.StreamFilterContinue
 	}
+	override := f.override(headers)
+	if override == overrideSkip {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] injection is skipped by the override header")
+		}
+		return api.StreamFilterContinue
+	}
+	force := override == overrideForce
 	// TODO: check downstream nodes, support later
 	//if !f.downstreamNodes() {
 	//	return api.StreamHeadersFilterContinue
 	//}
-	if !f.config.headers.Matches(ctx, headers) {
+	if !force && !f.config.headers.Matches(ctx, headers) {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] header is not matched, request headers: %v, config headers: %v", headers, f.config.headers)
 		}
 		return api.StreamFilterContinue
 	}
-	// TODO: some parameters can get from request header
-	if delay := f.getDelayDuration(); delay > 0 {
+	// the delay and the abort are decided independently
+	delay := f.getDelayDuration(force)
+	abort := f.isAbort(force)
+	if delay > 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] start a delay timer")
 		}
 		f.handler.RequestInfo().SetResponseFlag(api.DelayInjected)
+		// the proxy holds the stream during the delay if the after route receiver filter timeout is configured,
+		// the stream is resumed by ContinueReceiving, or ended by the abort reply. if the delay exceeds the timeout,
+		// the stream is replied with 504 and the late callbacks are dropped. otherwise waits for the delay here.
+		if continuer, ok := f.handler.(streamfilter.StreamReceiverFilterContinuer); ok && continuer.CanHoldReceiving() {
+			f.timer = utils.NewTimer(delay, func() {
+				select {
+				case <-f.stop:
+					return
+				default:
+				}
+				if abort {
+					f.abort(headers)
+					return
+				}
+				continuer.ContinueReceiving()
+			})
+			return api.StreamFilterStop
+		}
 		select {
 		case <-time.After(delay):
 		case <-f.stop:
@@ -155,7 +196,7 @@ func (f *streamFaultInjectFilter) OnReceive(ctx context.Context, headers api.Hea
 			return api.StreamFilterStop
 		}
 	}
-	if f.isAbort() {
+	if abort {
 		f.abort(headers)
 		return api.StreamFilterStop
 	}
@@ -164,6 +205,18 @@ func (f *streamFaultInjectFilter) OnReceive(ctx context.Context, headers api.Hea
 
 func (f *streamFaultInjectFilter) OnDestroy() {
 	close(f.stop)
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+}
+
+// override returns the value of the override header, the injection is forced or skipped by it
+func (f *streamFaultInjectFilter) override(headers api.HeaderMap) string {
+	if f.config.overrideHeader == "" || headers == nil {
+		return ""
+	}
+	value, _ := headers.Get(f.config.overrideHeader)
+	return value
 }
 
 // matches and inject
@@ -183,9 +236,19 @@ func (f *streamFaultInjectFilter) matchUpstream() bool {
 	return true
 }
 
-func (f *streamFaultInjectFilter) getDelayDuration() time.Duration {
-	// percent is 0 or delay is 0 means no delay
-	if f.config.delayPercent == 0 || f.config.fixedDelay == 0 {
+func (f *streamFaultInjectFilter) getDelayDuration(force bool) time.Duration {
+	// delay is 0 means no delay
+	if f.config.fixedDelay == 0 && f.config.maxDelay == 0 {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] no delay inject")
+		}
+		return 0
+	}
+	if force {
+		return f.delayDuration()
+	}
+	// percent is 0 means no delay
+	if f.config.delayPercent == 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] no delay inject")
 		}
@@ -198,10 +261,22 @@ func (f *streamFaultInjectFilter) getDelayDuration() time.Duration {
 		}
 		return 0
 	}
-	return f.config.fixedDelay
+	return f.delayDuration()
 }
 
-func (f *streamFaultInjectFilter) isAbort() bool {
+// delayDuration returns the fixed delay, or a random delay between the fixed delay and the max delay
+func (f *streamFaultInjectFilter) delayDuration() time.Duration {
+	if f.config.maxDelay <= f.config.fixedDelay {
+		return f.config.fixedDelay
+	}
+	return f.config.fixedDelay + time.Duration(f.rander.Int63n(int64(f.config.maxDelay-f.config.fixedDelay)+1))
+}
+
+func (f *streamFaultInjectFilter) isAbort(force bool) bool {
+	// the forced injection aborts if the abort status is configured
+	if force {
+		return f.config.abortStatus != 0
+	}
 	// percent is 0 means no abort
 	if f.config.abortPercent == 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
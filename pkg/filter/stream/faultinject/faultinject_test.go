@@ -114,7 +114,7 @@ func TestDelayPercent(t *testing.T) {
 		hint := uint32(0)
 		testCount := uint32(1000000)
 		for i := uint32(0); i < testCount; i++ {
-			if f.getDelayDuration() > 0 {
+			if f.getDelayDuration(false) > 0 {
 				hint++
 			}
 		}
@@ -142,7 +142,7 @@ func TestDelayPercent(t *testing.T) {
 	for _, nodelay := range nodelays {
 	Run:
 		for i := 0; i < 10000; i++ {
-			if nodelay.getDelayDuration() > 0 {
+			if nodelay.getDelayDuration(false) > 0 {
 				t.Error("nodelay get delayed")
 				break Run
 			}
@@ -156,7 +156,7 @@ func TestDelayPercent(t *testing.T) {
 		rander: rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 	for i := 0; i < 10000; i++ {
-		if mustdelay.getDelayDuration() == 0 {
+		if mustdelay.getDelayDuration(false) == 0 {
 			t.Error("must delay get no delay")
 			break
 		}
@@ -177,7 +177,7 @@ func TestAbortPercent(t *testing.T) {
 		hint := uint32(0)
 		testCount := uint32(1000000)
 		for i := uint32(0); i < testCount; i++ {
-			if f.isAbort() {
+			if f.isAbort(false) {
 				hint++
 			}
 		}
@@ -193,7 +193,7 @@ func TestAbortPercent(t *testing.T) {
 		rander: rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 	for i := 0; i < 10000; i++ {
-		if noAbort.isAbort() {
+		if noAbort.isAbort(false) {
 			t.Error("no abort got is abort")
 			break
 		}
@@ -205,7 +205,7 @@ func TestAbortPercent(t *testing.T) {
 		rander: rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 	for i := 0; i < 10000; i++ {
-		if !mustAbort.isAbort() {
+		if !mustAbort.isAbort(false) {
 			t.Error("must abort got no abort")
 			break
 		}
@@ -232,7 +232,7 @@ func TestFaultInject_AllWithDelay(t *testing.T) {
 		route: &mockRoute{
 			rule: &mockRouteRule{},
 		},
-		called: make(chan int, 1),
+		called: make(chan int, 2),
 	}
 	f := NewFilter(context.Background(), cfg)
 	f.SetReceiveFilterHandler(cb)
@@ -307,14 +307,12 @@ func TestFaultInject_MatchedUpstream(t *testing.T) {
 	f := NewFilter(context.Background(), cfg)
 	f.SetReceiveFilterHandler(cb)
 	start := time.Now()
-	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterContinue {
+	// the stream is held during the delay
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterStop {
 		t.Error("fault inject should matched")
 		return
 	}
-	cost := time.Now().Sub(start)
-	if cost < time.Second {
-		t.Errorf("expected delay at least 1s")
-	}
+	waitContinue(t, cb, start, time.Second)
 	notmatched := &mockStreamReceiverFilterCallbacks{
 		route: &mockRoute{
 			rule: &mockRouteRule{
@@ -358,14 +356,11 @@ func TestFaultInject_MatchedHeader(t *testing.T) {
 		"User": "Alice",
 	})
 	start := time.Now()
-	if status := f.OnReceive(context.TODO(), headers, nil, nil); status != api.StreamFilterContinue {
+	if status := f.OnReceive(context.TODO(), headers, nil, nil); status != api.StreamFilterStop {
 		t.Error("fault inject should matched")
 		return
 	}
-	cost := time.Now().Sub(start)
-	if cost < time.Second {
-		t.Errorf("expected delay at least 1s")
-	}
+	waitContinue(t, cb, start, time.Second)
 	notmatched := protocol.CommonHeader(map[string]string{
 		"User": "Bob",
 	})
@@ -414,3 +409,150 @@ func TestFaultInject_RouteConfigOverride(t *testing.T) {
 		t.Error("timeout")
 	}
 }
+
+// waitContinue waits for the delayed stream to be resumed, the delay should be in [min, min+1s)
+func waitContinue(t *testing.T, cb *mockStreamReceiverFilterCallbacks, start time.Time, min time.Duration) {
+	select {
+	case <-cb.called:
+		cost := time.Since(start)
+		if cost < min || cost >= min+time.Second {
+			t.Errorf("unexpected delay %s, expected at least %s", cost, min)
+		}
+	case <-time.After(min + 2*time.Second):
+		t.Error("the stream is not resumed")
+	}
+}
+
+func TestFaultInject_RandomDelay(t *testing.T) {
+	cfg := &v2.StreamFaultInject{
+		Delay: &v2.DelayInject{
+			Delay:    100 * time.Millisecond,
+			MaxDelay: 200 * time.Millisecond,
+			DelayInjectConfig: v2.DelayInjectConfig{
+				Percent: 100,
+			},
+		},
+	}
+	f := NewFilter(context.Background(), cfg).(*streamFaultInjectFilter)
+	for i := 0; i < 1000; i++ {
+		delay := f.getDelayDuration(false)
+		if delay < 100*time.Millisecond || delay > 200*time.Millisecond {
+			t.Fatalf("random delay %s is out of bounds", delay)
+		}
+	}
+
+	cb := &mockStreamReceiverFilterCallbacks{
+		info: &mockRequestInfo{},
+		route: &mockRoute{
+			rule: &mockRouteRule{},
+		},
+		called: make(chan int, 1),
+	}
+	f.SetReceiveFilterHandler(cb)
+	start := time.Now()
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterStop {
+		t.Fatal("fault inject should matched")
+	}
+	waitContinue(t, cb, start, 100*time.Millisecond)
+	if cb.info.flag != api.DelayInjected {
+		t.Error("delay injected flag is not set")
+	}
+}
+
+func TestFaultInject_OverrideHeader(t *testing.T) {
+	cfg := &v2.StreamFaultInject{
+		Abort: &v2.AbortInject{
+			Percent: 0,
+			Status:  503,
+		},
+		Headers: []v2.HeaderMatcher{
+			{
+				Name:  "User",
+				Value: "Alice",
+			},
+		},
+		OverrideHeader: "x-fault",
+	}
+	newCallbacks := func() *mockStreamReceiverFilterCallbacks {
+		return &mockStreamReceiverFilterCallbacks{
+			info: &mockRequestInfo{},
+			route: &mockRoute{
+				rule: &mockRouteRule{},
+			},
+			called: make(chan int, 2),
+		}
+	}
+	testcases := []struct {
+		headers map[string]string
+		status  api.StreamFilterStatus
+		code    int
+	}{
+		// the percentage is 0, no injection
+		{map[string]string{"User": "Alice"}, api.StreamFilterContinue, 0},
+		// the forced injection ignores the percentages and the header matchers
+		{map[string]string{"User": "Bob", "x-fault": "force"}, api.StreamFilterStop, 503},
+		{map[string]string{"User": "Alice", "x-fault": "force"}, api.StreamFilterStop, 503},
+		{map[string]string{"User": "Alice", "x-fault": "skip"}, api.StreamFilterContinue, 0},
+	}
+	for i, tc := range testcases {
+		cb := newCallbacks()
+		f := NewFilter(context.Background(), cfg)
+		f.SetReceiveFilterHandler(cb)
+		if status := f.OnReceive(context.TODO(), protocol.CommonHeader(tc.headers), nil, nil); status != tc.status {
+			t.Errorf("#%d unexpected status %v", i, status)
+		}
+		if cb.hijackCode != tc.code {
+			t.Errorf("#%d unexpected abort status %d", i, cb.hijackCode)
+		}
+	}
+
+	// the skip header skips the configured 100% delay
+	cfg = &v2.StreamFaultInject{
+		Delay: &v2.DelayInject{
+			Delay: time.Second,
+			DelayInjectConfig: v2.DelayInjectConfig{
+				Percent: 100,
+			},
+		},
+		OverrideHeader: "x-fault",
+	}
+	cb := newCallbacks()
+	f := NewFilter(context.Background(), cfg)
+	f.SetReceiveFilterHandler(cb)
+	start := time.Now()
+	if status := f.OnReceive(context.TODO(), protocol.CommonHeader{"x-fault": "skip"}, nil, nil); status != api.StreamFilterContinue {
+		t.Error("skipped injection should continue")
+	}
+	if time.Since(start) >= time.Second {
+		t.Error("skipped injection should not delay")
+	}
+}
+
+func TestFaultInject_DestroyDuringDelay(t *testing.T) {
+	cfg := &v2.StreamFaultInject{
+		Delay: &v2.DelayInject{
+			Delay: 100 * time.Millisecond,
+			DelayInjectConfig: v2.DelayInjectConfig{
+				Percent: 100,
+			},
+		},
+	}
+	cb := &mockStreamReceiverFilterCallbacks{
+		info: &mockRequestInfo{},
+		route: &mockRoute{
+			rule: &mockRouteRule{},
+		},
+		called: make(chan int, 1),
+	}
+	f := NewFilter(context.Background(), cfg)
+	f.SetReceiveFilterHandler(cb)
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterStop {
+		t.Fatal("fault inject should matched")
+	}
+	f.OnDestroy()
+	select {
+	case <-cb.called:
+		t.Error("the destroyed stream should not be resumed")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
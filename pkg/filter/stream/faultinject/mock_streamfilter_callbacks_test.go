@@ -39,6 +39,9 @@ func (cb *mockStreamReceiverFilterCallbacks) Route() api.Route {
 func (cb *mockStreamReceiverFilterCallbacks) RequestInfo() api.RequestInfo {
 	return cb.info
 }
+func (cb *mockStreamReceiverFilterCallbacks) CanHoldReceiving() bool {
+	return true
+}
 func (cb *mockStreamReceiverFilterCallbacks) ContinueReceiving() {
 	cb.called <- 1
 }
@@ -0,0 +1,284 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	contentTypeGrpc        = "application/grpc"
+	contentTypeGrpcWeb     = "application/grpc-web"
+	contentTypeGrpcWebText = "application/grpc-web-text"
+
+	headerContentType   = "content-type"
+	headerContentLength = "content-length"
+	headerTE            = "te"
+
+	// the length-prefixed message: 1 byte flag and 4 bytes message length
+	frameHeaderLen = 5
+	// the frame with the most significant bit of the flag set is the trailer frame
+	trailerFlag byte = 0x80
+)
+
+var errInvalidFrame = errors.New("invalid grpc web frame")
+
+// hop-by-hop headers are not allowed in http2
+var hopHeaders = map[string]struct{}{
+	"connection":        {},
+	"keep-alive":        {},
+	"proxy-connection":  {},
+	"transfer-encoding": {},
+	"upgrade":           {},
+	"expect":            {},
+	headerContentLength: {},
+}
+
+// grpcWebFilter translates the grpc-web request to grpc request, and the grpc response to grpc-web response.
+// see https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+type grpcWebFilter struct {
+	ctx context.Context
+	// grpcWeb is true if the request is a grpc-web request
+	grpcWeb bool
+	// text is true if the content type is grpc-web-text, the body is base64 encoded
+	text bool
+	// the message format of the content type, such as "+proto"
+	format string
+
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+}
+
+func NewStreamFilter(ctx context.Context) *grpcWebFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [grpcweb] create a new grpc web filter")
+	}
+	return &grpcWebFilter{
+		ctx: ctx,
+	}
+}
+
+func (f *grpcWebFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *grpcWebFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	ct, _ := headers.Get(headerContentType)
+	f.grpcWeb, f.text, f.format = parseContentType(ct)
+	if !f.grpcWeb {
+		return api.StreamFilterContinue
+	}
+
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	if f.text {
+		decoded, err := decodeText(body)
+		if err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter] [grpcweb] decode grpc web text body failed: %v", err)
+			f.grpcWeb = false
+			f.receiveHandler.SendHijackReply(http.StatusBadRequest, headers)
+			return api.StreamFilterStop
+		}
+		body = decoded
+	}
+	data, err := deframeRequest(body)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter] [grpcweb] deframe grpc web body failed: %v", err)
+		f.grpcWeb = false
+		f.receiveHandler.SendHijackReply(http.StatusBadRequest, headers)
+		return api.StreamFilterStop
+	}
+
+	// the grpc request is sent by http2
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, protocol.HTTP2)
+
+	outHeaders := make(map[string]string, 8)
+	headers.Range(func(key, value string) bool {
+		k := strings.ToLower(key)
+		if _, hop := hopHeaders[k]; !hop {
+			outHeaders[k] = value
+		}
+		return true
+	})
+	outHeaders[headerContentType] = contentTypeGrpc + f.format
+	outHeaders[headerTE] = "trailers"
+
+	f.receiveHandler.SetRequestHeaders(protocol.CommonHeader(outHeaders))
+	f.receiveHandler.SetRequestData(buffer.NewIoBufferBytes(data))
+	return api.StreamFilterContinue
+}
+
+func (f *grpcWebFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *grpcWebFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if !f.grpcWeb {
+		return api.StreamFilterContinue
+	}
+
+	// keep the message format of the upstream response
+	format := f.format
+	if ct, ok := headers.Get(headerContentType); ok && strings.HasPrefix(ct, contentTypeGrpc) {
+		format = ct[len(contentTypeGrpc):]
+	}
+	contentType := contentTypeGrpcWeb
+	if f.text {
+		contentType = contentTypeGrpcWebText
+	}
+
+	// the response header for the downstream protocol
+	outHeaders := f.responseHeaders(ctx, headers)
+	outHeaders.Set(headerContentType, contentType+format)
+	outHeaders.Del(headerContentLength)
+	f.sendHandler.SetResponseHeaders(outHeaders)
+
+	// trailers-only response is sent as headers
+	if buf == nil && trailers == nil {
+		return api.StreamFilterContinue
+	}
+
+	body := &bytes.Buffer{}
+	if buf != nil {
+		body.Write(buf.Bytes())
+	}
+	if trailers != nil {
+		body.Write(encodeTrailers(trailers))
+	}
+	data := body.Bytes()
+	if f.text {
+		data = []byte(base64.StdEncoding.EncodeToString(data))
+	}
+	f.sendHandler.SetResponseData(buffer.NewIoBufferBytes(data))
+	// the trailers are sent in body
+	f.sendHandler.SetResponseTrailers(nil)
+	return api.StreamFilterContinue
+}
+
+func (f *grpcWebFilter) OnDestroy() {}
+
+// responseHeaders copies the response headers, the http1 downstream requires a http1 response header
+func (f *grpcWebFilter) responseHeaders(ctx context.Context, headers api.HeaderMap) api.HeaderMap {
+	var out api.HeaderMap = protocol.CommonHeader{}
+	if pv, err := variable.Get(ctx, types.VariableDownStreamProtocol); err == nil && pv == protocol.HTTP1 {
+		out = mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	}
+	headers.Range(func(key, value string) bool {
+		out.Set(strings.ToLower(key), value)
+		return true
+	})
+	return out
+}
+
+// parseContentType returns whether the content type is grpc web, whether it is base64 encoded
+// and the message format of it
+func parseContentType(ct string) (grpcWeb bool, text bool, format string) {
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = strings.TrimSpace(ct[:i])
+	}
+	switch {
+	case strings.HasPrefix(ct, contentTypeGrpcWebText):
+		text, format = true, ct[len(contentTypeGrpcWebText):]
+	case strings.HasPrefix(ct, contentTypeGrpcWeb):
+		format = ct[len(contentTypeGrpcWeb):]
+	default:
+		return false, false, ""
+	}
+	if format != "" && format[0] != '+' {
+		return false, false, ""
+	}
+	return true, text, format
+}
+
+// decodeText decodes the base64 body, the body may be concatenated by several padded base64 chunks
+func decodeText(body []byte) ([]byte, error) {
+	encoded := bytes.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, body)
+	if len(encoded)%4 != 0 {
+		return nil, base64.CorruptInputError(len(encoded))
+	}
+	decoded := make([]byte, 0, base64.StdEncoding.DecodedLen(len(encoded)))
+	group := make([]byte, 3)
+	for i := 0; i < len(encoded); i += 4 {
+		n, err := base64.StdEncoding.Decode(group, encoded[i:i+4])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, group[:n]...)
+	}
+	return decoded, nil
+}
+
+// deframeRequest checks the length-prefixed messages, and drops the trailer frames which are not allowed in grpc request
+func deframeRequest(body []byte) ([]byte, error) {
+	out := make([]byte, 0, len(body))
+	for len(body) > 0 {
+		if len(body) < frameHeaderLen {
+			return nil, errInvalidFrame
+		}
+		length := int(binary.BigEndian.Uint32(body[1:frameHeaderLen]))
+		if len(body)-frameHeaderLen < length {
+			return nil, errInvalidFrame
+		}
+		frame := body[:frameHeaderLen+length]
+		if frame[0]&trailerFlag == 0 {
+			out = append(out, frame...)
+		}
+		body = body[frameHeaderLen+length:]
+	}
+	return out, nil
+}
+
+// encodeTrailers encodes the trailers to a grpc web trailer frame
+func encodeTrailers(trailers api.HeaderMap) []byte {
+	block := &bytes.Buffer{}
+	trailers.Range(func(key, value string) bool {
+		block.WriteString(strings.ToLower(key))
+		block.WriteString(":")
+		block.WriteString(value)
+		block.WriteString("\r\n")
+		return true
+	})
+	frame := make([]byte, frameHeaderLen, frameHeaderLen+block.Len())
+	frame[0] = trailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(block.Len()))
+	return append(frame, block.Bytes()...)
+}
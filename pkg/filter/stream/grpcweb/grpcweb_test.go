@@ -0,0 +1,283 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	_ "mosn.io/mosn/pkg/proxy"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	headers    api.HeaderMap
+	data       buffer.IoBuffer
+	hijackCode int
+}
+
+func (h *mockReceiveHandler) SetRequestHeaders(headers api.HeaderMap) {
+	h.headers = headers
+}
+
+func (h *mockReceiveHandler) SetRequestData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+type mockSendHandler struct {
+	api.StreamSenderFilterHandler
+	headers  api.HeaderMap
+	data     buffer.IoBuffer
+	trailers api.HeaderMap
+}
+
+func (h *mockSendHandler) SetResponseHeaders(headers api.HeaderMap) {
+	h.headers = headers
+}
+
+func (h *mockSendHandler) SetResponseData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockSendHandler) SetResponseTrailers(trailers api.HeaderMap) {
+	h.trailers = trailers
+}
+
+// a grpc-web-text request recorded from grpc-web javascript client
+var (
+	recordedTextRequestHeaders = map[string]string{
+		"content-type":   "application/grpc-web-text",
+		"accept":         "application/grpc-web-text",
+		"x-grpc-web":     "1",
+		"x-user-agent":   "grpc-web-javascript/0.1",
+		"content-length": "16",
+		"connection":     "keep-alive",
+	}
+	recordedTextRequestBody = "AAAAAAcKBXdvcmxk"
+	// message: 0x0a 0x05 "world"
+	expectedGrpcFrame = []byte{0x00, 0x00, 0x00, 0x00, 0x07, 0x0a, 0x05, 'w', 'o', 'r', 'l', 'd'}
+)
+
+func newTestFilter() (*grpcWebFilter, *mockReceiveHandler, *mockSendHandler, context.Context) {
+	ctx := variable.NewVariableContext(context.Background())
+	f := NewStreamFilter(ctx)
+	rh := &mockReceiveHandler{}
+	sh := &mockSendHandler{}
+	f.SetReceiveFilterHandler(rh)
+	f.SetSenderFilterHandler(sh)
+	return f, rh, sh, ctx
+}
+
+func TestParseContentType(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		grpcWeb     bool
+		text        bool
+		format      string
+	}{
+		{"application/grpc-web", true, false, ""},
+		{"application/grpc-web+proto", true, false, "+proto"},
+		{"application/grpc-web-text", true, true, ""},
+		{"Application/grpc-web-text+proto; charset=utf-8", true, true, "+proto"},
+		{"application/grpc", false, false, ""},
+		{"application/grpc-webx", false, false, ""},
+		{"application/json", false, false, ""},
+		{"", false, false, ""},
+	} {
+		grpcWeb, text, format := parseContentType(tc.contentType)
+		assert.Equal(t, tc.grpcWeb, grpcWeb, tc.contentType)
+		assert.Equal(t, tc.text, text, tc.contentType)
+		assert.Equal(t, tc.format, format, tc.contentType)
+	}
+}
+
+func TestGrpcWebTextRequest(t *testing.T) {
+	f, rh, _, ctx := newTestFilter()
+	headers := protocol.CommonHeader{}
+	for k, v := range recordedTextRequestHeaders {
+		headers.Set(k, v)
+	}
+	status := f.OnReceive(ctx, headers, buffer.NewIoBufferString(recordedTextRequestBody), nil)
+	require.Equal(t, api.StreamFilterContinue, status)
+	require.True(t, f.grpcWeb)
+	require.True(t, f.text)
+
+	// upstream sees well-formed grpc
+	ct, _ := rh.headers.Get("content-type")
+	assert.Equal(t, "application/grpc", ct)
+	te, _ := rh.headers.Get("te")
+	assert.Equal(t, "trailers", te)
+	for _, key := range []string{"content-length", "connection"} {
+		_, ok := rh.headers.Get(key)
+		assert.False(t, ok, key)
+	}
+	v, _ := rh.headers.Get("x-grpc-web")
+	assert.Equal(t, "1", v)
+	assert.Equal(t, expectedGrpcFrame, rh.data.Bytes())
+	proto, err := variable.Get(ctx, types.VariableUpstreamProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, protocol.HTTP2, proto)
+}
+
+func TestGrpcWebRequest(t *testing.T) {
+	f, rh, _, ctx := newTestFilter()
+	second := []byte{0x00, 0x00, 0x00, 0x00, 0x05, 0x0a, 0x03, 'f', 'o', 'o'}
+	body := append(append([]byte{}, expectedGrpcFrame...), second...)
+	// trailer frame is not allowed in grpc request
+	body = append(body, 0x80, 0x00, 0x00, 0x00, 0x00)
+	headers := protocol.CommonHeader{"content-type": "application/grpc-web+proto"}
+	status := f.OnReceive(ctx, headers, buffer.NewIoBufferBytes(body), nil)
+	require.Equal(t, api.StreamFilterContinue, status)
+	ct, _ := rh.headers.Get("content-type")
+	assert.Equal(t, "application/grpc+proto", ct)
+	assert.Equal(t, append(append([]byte{}, expectedGrpcFrame...), second...), rh.data.Bytes())
+
+	// concatenated padded base64 chunks
+	f, rh, _, ctx = newTestFilter()
+	headers = protocol.CommonHeader{"content-type": "application/grpc-web-text"}
+	text := base64.StdEncoding.EncodeToString(second) + base64.StdEncoding.EncodeToString(expectedGrpcFrame)
+	status = f.OnReceive(ctx, headers, buffer.NewIoBufferString(text), nil)
+	require.Equal(t, api.StreamFilterContinue, status)
+	assert.Equal(t, append(append([]byte{}, second...), expectedGrpcFrame...), rh.data.Bytes())
+}
+
+func TestGrpcWebInvalidRequest(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"application/grpc-web-text", []byte("AAAAAAcKBXdvcmx")},
+		{"application/grpc-web-text", []byte("!!!!")},
+		{"application/grpc-web", expectedGrpcFrame[:8]},
+		{"application/grpc-web", []byte{0x00, 0x00}},
+	} {
+		f, rh, _, ctx := newTestFilter()
+		headers := protocol.CommonHeader{"content-type": tc.contentType}
+		status := f.OnReceive(ctx, headers, buffer.NewIoBufferBytes(tc.body), nil)
+		assert.Equal(t, api.StreamFilterStop, status)
+		assert.Equal(t, 400, rh.hijackCode)
+		assert.False(t, f.grpcWeb)
+	}
+}
+
+func TestNotGrpcWebRequest(t *testing.T) {
+	f, rh, sh, ctx := newTestFilter()
+	headers := protocol.CommonHeader{"content-type": "application/grpc"}
+	status := f.OnReceive(ctx, headers, buffer.NewIoBufferBytes(expectedGrpcFrame), nil)
+	assert.Equal(t, api.StreamFilterContinue, status)
+	assert.Nil(t, rh.headers)
+	assert.Nil(t, rh.data)
+	status = f.Append(ctx, headers, buffer.NewIoBufferBytes(expectedGrpcFrame), protocol.CommonHeader{"grpc-status": "0"})
+	assert.Equal(t, api.StreamFilterContinue, status)
+	assert.Nil(t, sh.headers)
+}
+
+// parseTrailerFrame parses the trailer frame at the end of body
+func parseTrailerFrame(t *testing.T, body []byte) ([]byte, map[string]string) {
+	// skip the message frames
+	for len(body) > 0 && body[0]&trailerFlag == 0 {
+		length := int(binary.BigEndian.Uint32(body[1:frameHeaderLen]))
+		body = body[frameHeaderLen+length:]
+	}
+	require.True(t, len(body) > frameHeaderLen)
+	require.Equal(t, trailerFlag, body[0])
+	length := int(binary.BigEndian.Uint32(body[1:frameHeaderLen]))
+	require.Equal(t, len(body)-frameHeaderLen, length)
+	trailers := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(body[frameHeaderLen:]), "\r\n"), "\r\n") {
+		kv := strings.SplitN(line, ":", 2)
+		require.Len(t, kv, 2)
+		trailers[kv[0]] = kv[1]
+	}
+	return body, trailers
+}
+
+func TestGrpcWebTextResponse(t *testing.T) {
+	f, _, sh, ctx := newTestFilter()
+	headers := protocol.CommonHeader{}
+	for k, v := range recordedTextRequestHeaders {
+		headers.Set(k, v)
+	}
+	f.OnReceive(ctx, headers, buffer.NewIoBufferString(recordedTextRequestBody), nil)
+
+	respHeaders := protocol.CommonHeader{
+		"Content-Type":   "application/grpc+proto",
+		"Content-Length": "12",
+	}
+	trailers := protocol.CommonHeader{
+		"Grpc-Status":  "0",
+		"Grpc-Message": "OK",
+	}
+	status := f.Append(ctx, respHeaders, buffer.NewIoBufferBytes(expectedGrpcFrame), trailers)
+	require.Equal(t, api.StreamFilterContinue, status)
+
+	ct, _ := sh.headers.Get("content-type")
+	assert.Equal(t, "application/grpc-web-text+proto", ct)
+	_, ok := sh.headers.Get("content-length")
+	assert.False(t, ok)
+	assert.Nil(t, sh.trailers)
+
+	// client gets the message and the trailer frame
+	body, err := base64.StdEncoding.DecodeString(sh.data.String())
+	require.Nil(t, err)
+	assert.Equal(t, expectedGrpcFrame, body[:len(expectedGrpcFrame)])
+	trailerFrame, trailerMap := parseTrailerFrame(t, body)
+	assert.Equal(t, len(body)-len(expectedGrpcFrame), len(trailerFrame))
+	assert.Equal(t, map[string]string{"grpc-status": "0", "grpc-message": "OK"}, trailerMap)
+}
+
+func TestGrpcWebResponse(t *testing.T) {
+	f, _, sh, ctx := newTestFilter()
+	variable.Set(ctx, types.VariableDownStreamProtocol, protocol.HTTP1)
+	f.OnReceive(ctx, protocol.CommonHeader{"content-type": "application/grpc-web"}, buffer.NewIoBufferBytes(expectedGrpcFrame), nil)
+
+	status := f.Append(ctx, protocol.CommonHeader{"content-type": "application/grpc"},
+		buffer.NewIoBufferBytes(expectedGrpcFrame), protocol.CommonHeader{"grpc-status": "0"})
+	require.Equal(t, api.StreamFilterContinue, status)
+	// http1 downstream gets http1 response header
+	h1, ok := sh.headers.(mosnhttp.ResponseHeader)
+	require.True(t, ok)
+	assert.Equal(t, "application/grpc-web", string(h1.ContentType()))
+	_, trailerMap := parseTrailerFrame(t, sh.data.Bytes())
+	assert.Equal(t, map[string]string{"grpc-status": "0"}, trailerMap)
+
+	// trailers-only response is kept in headers
+	f, _, sh, ctx = newTestFilter()
+	f.OnReceive(ctx, protocol.CommonHeader{"content-type": "application/grpc-web"}, buffer.NewIoBufferBytes(expectedGrpcFrame), nil)
+	status = f.Append(ctx, protocol.CommonHeader{"content-type": "application/grpc", "grpc-status": "14"}, nil, nil)
+	require.Equal(t, api.StreamFilterContinue, status)
+	v, _ := sh.headers.Get("grpc-status")
+	assert.Equal(t, "14", v)
+	assert.Nil(t, sh.data)
+}
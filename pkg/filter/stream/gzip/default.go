@@ -28,4 +28,13 @@ const (
 	strContentType     = "Content-Type"
 	strAcceptEncoding  = "Accept-Encoding"
 	strContentEncoding = "Content-Encoding"
+	strContentLength   = "Content-Length"
+	strVary            = "Vary"
+)
+
+// the content codings supported, gzip is preferred if the client accepts both
+const (
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
 )
@@ -19,6 +19,7 @@ package gzip
 
 import (
 	"context"
+	"strconv"
 	"strings"
 
 	"github.com/valyala/fasthttp"
@@ -26,30 +27,11 @@ import (
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/types"
-	"mosn.io/pkg/variable"
 	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
 )
 
-/*
- * test first for the most common case "gzip,...":
- *   MSIE:    "gzip, deflate"
- *   Firefox: "gzip,deflate"
- *   Chrome:  "gzip,deflate,sdch"
- *   Safari:  "gzip, deflate"
- *   Opera:   "gzip, deflate"
- */
-
-var gzipCheck = map[string]bool{
-	"gzip":              true,
-	"gzip, deflate":     true,
-	"gzip , deflate":    true,
-	"gzip ,deflate":     true,
-	"gzip,deflate":      true,
-	"gzip,deflate,sdch": true,
-}
-
 // gzipConfig is parsed from v2.StreamGzip
-// TODO support minLength,ContentType,gziplevel etc.
 type gzipConfig struct {
 	gzipLevel      uint32
 	minCompressLen uint32
@@ -74,8 +56,10 @@ func makegzipConfig(cfg *v2.StreamGzip) *gzipConfig {
 
 // streamGzipFilter is an implement of api.StreamReceiverFilter
 type streamGzipFilter struct {
-	config         *gzipConfig
-	needGzip       bool
+	config   *gzipConfig
+	needGzip bool
+	// encoding is the content coding accepted by the client, gzip or deflate
+	encoding       string
 	receiveHandler api.StreamReceiverFilterHandler
 	sendHandler    api.StreamSenderFilterHandler
 }
@@ -96,14 +80,9 @@ func (f *streamGzipFilter) SetReceiveFilterHandler(handler api.StreamReceiverFil
 }
 
 func (f *streamGzipFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
-	// check request need gzip
-	if !f.checkGzip(ctx, headers) {
-		f.needGzip = false
-		return api.StreamFilterContinue
-	}
-
-	// for response check
-	f.needGzip = true
+	// check request need gzip, and keep the encoding for response check
+	f.encoding = f.checkGzip(ctx, headers)
+	f.needGzip = f.encoding != ""
 	return api.StreamFilterContinue
 }
 
@@ -120,9 +99,8 @@ func (f *streamGzipFilter) Append(ctx context.Context, headers api.HeaderMap, bu
 		return api.StreamFilterContinue
 	}
 
-	_, gziped := headers.Get(strContentEncoding)
 	// client don't need gzip or the body is already compressed
-	if !f.needGzip || gziped {
+	if !f.needGzip || isEncoded(headers) {
 		return api.StreamFilterContinue
 	}
 
@@ -139,45 +117,106 @@ func (f *streamGzipFilter) Append(ctx context.Context, headers api.HeaderMap, bu
 		return api.StreamFilterContinue
 	}
 
-	// set gzip response header
-	headers.Set(strContentEncoding, "gzip")
-
 	// usually gzip compression ratio is 3-10 times
 	outBuf := buffer.GetIoBuffer(buf.Len() / 3)
+	defer buffer.PutIoBuffer(outBuf)
+
+	var err error
+	switch f.encoding {
+	case encodingDeflate:
+		_, err = fasthttp.WriteDeflateLevel(outBuf, buf.Bytes(), int(f.config.gzipLevel))
+	default:
+		_, err = fasthttp.WriteGzipLevel(outBuf, buf.Bytes(), int(f.config.gzipLevel))
+	}
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter] [gzip] compress response body with %s failed: %v", f.encoding, err)
+		return api.StreamFilterContinue
+	}
 
-	fasthttp.WriteGzipLevel(outBuf, buf.Bytes(), int(f.config.gzipLevel))
+	// set gzip response header, the content length of the original body is invalid
+	headers.Set(strContentEncoding, f.encoding)
+	headers.Del(strContentLength)
+	if vary, ok := headers.Get(strVary); !ok || vary == "" {
+		headers.Set(strVary, strAcceptEncoding)
+	} else if !strings.Contains(strings.ToLower(vary), strings.ToLower(strAcceptEncoding)) {
+		headers.Set(strVary, vary+", "+strAcceptEncoding)
+	}
 	f.sendHandler.SetResponseData(outBuf)
 
-	buffer.PutIoBuffer(outBuf)
-
 	return api.StreamFilterContinue
 }
 
 func (f *streamGzipFilter) OnDestroy() {
 }
 
-// check request need gzip
-func (f *streamGzipFilter) checkGzip(ctx context.Context, headers types.HeaderMap) bool {
+// check request need gzip, returns the content coding used to compress the response,
+// empty means no compression
+func (f *streamGzipFilter) checkGzip(ctx context.Context, headers types.HeaderMap) string {
 	// check gzip switch
 	if gzipSwitch, _ := variable.GetString(ctx, types.VarProxyGzipSwitch); gzipSwitch == "off" {
-		return false
+		return ""
 	}
 
-	ae, _ := headers.Get(strAcceptEncoding)
-	if v, ok := gzipCheck[ae]; ok {
-		return v
+	// the response of HEAD request has no body
+	if method, _ := variable.GetString(ctx, types.VarMethod); method == strHead {
+		return ""
 	}
 
-	return false
-
+	ae, _ := headers.Get(strAcceptEncoding)
+	return parseAcceptEncoding(ae)
 }
 
-// check response content type
+// check response content type, the parameters such as charset are ignored
 func (f *streamGzipFilter) isCompressibleContentType(headers api.HeaderMap) bool {
 	contentType, _ := headers.Get(strContentType)
-	if _, ok := f.config.contentType[strings.ToLower(contentType)]; ok {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if _, ok := f.config.contentType[strings.ToLower(strings.TrimSpace(contentType))]; ok {
 		return true
 	}
 
 	return false
 }
+
+// isEncoded returns true if the response body is already encoded
+func isEncoded(headers api.HeaderMap) bool {
+	ce, ok := headers.Get(strContentEncoding)
+	if !ok {
+		return false
+	}
+	ce = strings.TrimSpace(ce)
+	return ce != "" && !strings.EqualFold(ce, encodingIdentity)
+}
+
+// parseAcceptEncoding returns gzip or deflate if the client accepts it, gzip is preferred.
+// the codings with q=0 are not acceptable, and "*" matches any coding not listed.
+func parseAcceptEncoding(ae string) string {
+	accepted := map[string]bool{}
+	for _, coding := range strings.Split(ae, ",") {
+		params := strings.Split(coding, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		accept := true
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				accept = err == nil && q > 0
+			}
+		}
+		accepted[name] = accept
+	}
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		accept, listed := accepted[encoding]
+		if !listed {
+			accept = accepted["*"]
+		}
+		if accept {
+			return encoding
+		}
+	}
+	return ""
+}
@@ -19,8 +19,12 @@ package gzip
 
 import (
 	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"io/ioutil"
 	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -156,6 +160,146 @@ func TestGzipNewStreamFilter(t *testing.T) {
 	}
 }
 
+func TestParseAcceptEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"gzip", encodingGzip},
+		{"gzip, deflate", encodingGzip},
+		{"gzip,deflate,sdch", encodingGzip},
+		{"deflate", encodingDeflate},
+		{"br;q=1.0, gzip;q=0.8", encodingGzip},
+		{"GZIP", encodingGzip},
+		{"gzip;q=0, deflate", encodingDeflate},
+		{"gzip;q=0", ""},
+		{"*", encodingGzip},
+		{"*, gzip;q=0", encodingDeflate},
+		{"br", ""},
+		{"identity", ""},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		if encoding := parseAcceptEncoding(tc.acceptEncoding); encoding != tc.expected {
+			t.Errorf("accept encoding %q expected %q, but got %q", tc.acceptEncoding, tc.expected, encoding)
+		}
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	rawbody := strings.Repeat("hello mosn, ", 32)
+	cfg := &v2.StreamGzip{
+		ContentLength: 64,
+		ContentType:   []string{"text/html", "application/json"},
+	}
+	checkFilter := func(method, acceptEncoding string, respHeaders map[string]string, body string) (api.HeaderMap, string) {
+		ctx := variable.NewVariableContext(context.Background())
+		if method != "" {
+			variable.SetString(ctx, types.VarMethod, method)
+		}
+		f := NewStreamFilter(ctx, cfg)
+		sendHandler := &mockSendHandler{}
+		f.SetSenderFilterHandler(sendHandler)
+		f.OnReceive(ctx, protocol.CommonHeader(map[string]string{
+			strAcceptEncoding: acceptEncoding,
+		}), nil, nil)
+		headers := protocol.CommonHeader(respHeaders)
+		buf := buffer.NewIoBufferString(body)
+		sendHandler.setData(buf)
+		f.Append(ctx, headers, buf, nil)
+		return headers, buf.String()
+	}
+
+	// gzip
+	headers, body := checkFilter("GET", "gzip, deflate", map[string]string{
+		strContentType:   "text/html; charset=utf-8",
+		strContentLength: strconv.Itoa(len(rawbody)),
+	}, rawbody)
+	if v, _ := headers.Get(strContentEncoding); v != encodingGzip {
+		t.Fatalf("expected gzip content encoding, but got %q", v)
+	}
+	if _, ok := headers.Get(strContentLength); ok {
+		t.Error("content length of the original body should be removed")
+	}
+	if v, _ := headers.Get(strVary); v != strAcceptEncoding {
+		t.Errorf("expected vary header %q, but got %q", strAcceptEncoding, v)
+	}
+	gr, err := gzip.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("create gzip reader failed: %v", err)
+	}
+	if decoded, err := ioutil.ReadAll(gr); err != nil || string(decoded) != rawbody {
+		t.Errorf("decompressed body is not the original body, error: %v", err)
+	}
+
+	// deflate
+	headers, body = checkFilter("POST", "deflate", map[string]string{
+		strContentType: "application/json",
+		strVary:        "Origin",
+	}, rawbody)
+	if v, _ := headers.Get(strContentEncoding); v != encodingDeflate {
+		t.Fatalf("expected deflate content encoding, but got %q", v)
+	}
+	if v, _ := headers.Get(strVary); v != "Origin, "+strAcceptEncoding {
+		t.Errorf("accept encoding should be appended to vary header, but got %q", v)
+	}
+	zr, err := zlib.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("create deflate reader failed: %v", err)
+	}
+	if decoded, err := ioutil.ReadAll(zr); err != nil || string(decoded) != rawbody {
+		t.Errorf("decompressed body is not the original body, error: %v", err)
+	}
+
+	// not eligible
+	for name, tc := range map[string]struct {
+		method         string
+		acceptEncoding string
+		respHeaders    map[string]string
+		body           string
+	}{
+		"not accepted": {
+			acceptEncoding: "br",
+			respHeaders:    map[string]string{strContentType: "text/html"},
+			body:           rawbody,
+		},
+		"refused by q value": {
+			acceptEncoding: "gzip;q=0",
+			respHeaders:    map[string]string{strContentType: "text/html"},
+			body:           rawbody,
+		},
+		"head request": {
+			method:         strHead,
+			acceptEncoding: "gzip",
+			respHeaders:    map[string]string{strContentType: "text/html"},
+			body:           rawbody,
+		},
+		"content type not allowed": {
+			acceptEncoding: "gzip",
+			respHeaders:    map[string]string{strContentType: "image/png"},
+			body:           rawbody,
+		},
+		"body too small": {
+			acceptEncoding: "gzip",
+			respHeaders:    map[string]string{strContentType: "text/html"},
+			body:           "small",
+		},
+		"already encoded": {
+			acceptEncoding: "gzip",
+			respHeaders:    map[string]string{strContentType: "text/html", strContentEncoding: "br"},
+			body:           rawbody,
+		},
+	} {
+		headers, body := checkFilter(tc.method, tc.acceptEncoding, tc.respHeaders, tc.body)
+		if body != tc.body {
+			t.Errorf("%s: response body should not be compressed", name)
+		}
+		if v, _ := headers.Get(strContentEncoding); v != tc.respHeaders[strContentEncoding] {
+			t.Errorf("%s: content encoding should not be changed, but got %q", name, v)
+		}
+	}
+}
+
 func BenchmarkGzip(b *testing.B) {
 	rand := rand.New(rand.NewSource(time.Now().UnixNano()))
 
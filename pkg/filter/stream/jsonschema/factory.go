@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+)
+
+func init() {
+	api.RegisterStream(v2.JSONSchema, CreateJSONSchemaFilterFactory)
+	router.RegisterPerFilterConfigParser(v2.JSONSchema, parseRouteConfig)
+}
+
+type FilterConfigFactory struct {
+	config *jsonSchemaConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.AfterRoute)
+}
+
+// CreateJSONSchemaFilterFactory creates the json schema filter factory
+func CreateJSONSchemaFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create json schema stream filter factory")
+	cfg, err := ParseStreamJSONSchemaFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeJSONSchemaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamJSONSchemaFilter
+func ParseStreamJSONSchemaFilter(cfg map[string]interface{}) (*v2.StreamJSONSchema, error) {
+	filterConfig := &v2.StreamJSONSchema{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// jsonSchemaConfig is parsed from v2.StreamJSONSchema, the schema is compiled in advance.
+// a nil schema means the requests are not validated
+type jsonSchemaConfig struct {
+	schema       *schema
+	maxBodyBytes int
+}
+
+// parseRouteConfig parses the per route config, it is registered as the per filter config parser,
+// so the route config is parsed once when the route is created.
+func parseRouteConfig(cfg interface{}) (interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	filterConfig := &v2.StreamJSONSchema{}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return makeJSONSchemaConfig(filterConfig)
+}
+
+func makeJSONSchemaConfig(cfg *v2.StreamJSONSchema) (*jsonSchemaConfig, error) {
+	config := &jsonSchemaConfig{
+		maxBodyBytes: int(cfg.MaxBodyBytes),
+	}
+	if cfg.Schema != nil {
+		data, err := json.Marshal(cfg.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if config.schema, err = parseSchema(data); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	headerContentType   = "content-type"
+	contentTypeJSON     = "application/json"
+	errInvalidJSON      = "request body is not a valid json"
+	errSchemaViolations = "request body does not conform to the schema"
+)
+
+// jsonSchemaFilter is an implement of StreamReceiverFilter, it validates the json request body
+type jsonSchemaFilter struct {
+	ctx     context.Context
+	handler api.StreamReceiverFilterHandler
+	config  *jsonSchemaConfig
+}
+
+func NewStreamFilter(ctx context.Context, config *jsonSchemaConfig) *jsonSchemaFilter {
+	return &jsonSchemaFilter{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (f *jsonSchemaFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *jsonSchemaFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	config, err := f.routeConfig()
+	if err != nil {
+		// the request is not validated by an invalid schema
+		log.Proxy.Errorf(ctx, "[stream filter] [json schema] invalid route config: %v", err)
+		f.handler.SendHijackReply(http.StatusInternalServerError, newResponseHeaders(ctx))
+		return api.StreamFilterStop
+	}
+	if config.schema == nil || buf == nil || buf.Len() == 0 || !isJSON(headers) {
+		return api.StreamFilterContinue
+	}
+	if config.maxBodyBytes > 0 && buf.Len() > config.maxBodyBytes {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter] [json schema] body size %d exceeds the limit %d", buf.Len(), config.maxBodyBytes)
+		}
+		f.handler.RequestInfo().SetResponseFlag(api.ReqEntityTooLarge)
+		f.handler.SendHijackReply(http.StatusRequestEntityTooLarge, newResponseHeaders(ctx))
+		return api.StreamFilterStop
+	}
+	var body interface{}
+	if err := json.Unmarshal(buf.Bytes(), &body); err != nil {
+		f.reject(ctx, errInvalidJSON, []violation{{"$", err.Error()}})
+		return api.StreamFilterStop
+	}
+	if violations := config.schema.validate("$", body); len(violations) > 0 {
+		f.reject(ctx, errSchemaViolations, violations)
+		return api.StreamFilterStop
+	}
+	return api.StreamFilterContinue
+}
+
+// NeedRequestBody implements streamfilter.StreamReceiverFilterBodyBuffering, the json body is validated as a whole
+func (f *jsonSchemaFilter) NeedRequestBody(ctx context.Context, headers api.HeaderMap) bool {
+	return isJSON(headers)
+}
+
+func (f *jsonSchemaFilter) OnDestroy() {}
+
+// routeConfig returns the per route config if the route has one, otherwise the filter config
+func (f *jsonSchemaFilter) routeConfig() (*jsonSchemaConfig, error) {
+	route := f.handler.Route()
+	if route == nil || route.RouteRule() == nil {
+		return f.config, nil
+	}
+	cfg, ok, err := router.ParsedPerFilterConfig(route.RouteRule(), v2.JSONSchema)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.config, nil
+	}
+	return cfg.(*jsonSchemaConfig), nil
+}
+
+type errorBody struct {
+	Error      string      `json:"error"`
+	Violations []violation `json:"violations"`
+}
+
+func (f *jsonSchemaFilter) reject(ctx context.Context, msg string, violations []violation) {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [json schema] reject request: %s, %v", msg, violations)
+	}
+	body, _ := json.Marshal(errorBody{Error: msg, Violations: violations})
+	respHeaders := newResponseHeaders(ctx)
+	respHeaders.Set(headerContentType, contentTypeJSON)
+	f.handler.SendHijackReplyWithBody(http.StatusBadRequest, respHeaders, string(body))
+}
+
+// isJSON reports whether the content type is application/json or a +json media type
+func isJSON(headers api.HeaderMap) bool {
+	contentType, ok := headers.Get(headerContentType)
+	if !ok || contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == contentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// newResponseHeaders creates the headers of the reject response, the http1 downstream requires a http1 response header
+func newResponseHeaders(ctx context.Context) api.HeaderMap {
+	if pv, err := variable.Get(ctx, types.VariableDownStreamProtocol); err == nil && pv == protocol.HTTP1 {
+		return mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	}
+	return protocol.CommonHeader{}
+}
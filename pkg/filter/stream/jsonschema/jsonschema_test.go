@@ -0,0 +1,245 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	route      api.Route
+	info       *mockRequestInfo
+	hijackCode int
+	hijackBody string
+}
+
+func (h *mockReceiveHandler) Route() api.Route {
+	return h.route
+}
+
+func (h *mockReceiveHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+func (h *mockReceiveHandler) SendHijackReplyWithBody(code int, headers api.HeaderMap, body string) {
+	h.hijackCode = code
+	h.hijackBody = body
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	flag api.ResponseFlag
+}
+
+func (info *mockRequestInfo) SetResponseFlag(flag api.ResponseFlag) {
+	info.flag = flag
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 8, "pattern": "^[a-z]+$"},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+		"email": {"anyOf": [{"type": "null"}, {"type": "string", "pattern": "@"}]}
+	}
+}`
+
+func newConf(t *testing.T, schema string, maxBodyBytes int) map[string]interface{} {
+	conf := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(schema), &conf))
+	return map[string]interface{}{
+		"schema":         conf,
+		"max_body_bytes": maxBodyBytes,
+	}
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}, route api.Route) (*jsonSchemaFilter, *mockReceiveHandler) {
+	factory, err := CreateJSONSchemaFilterFactory(conf)
+	require.Nil(t, err)
+	f := NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+	handler := &mockReceiveHandler{
+		route: route,
+		info:  &mockRequestInfo{},
+	}
+	f.SetReceiveFilterHandler(handler)
+	return f, handler
+}
+
+func receive(f *jsonSchemaFilter, contentType, body string) api.StreamFilterStatus {
+	headers := protocol.CommonHeader{}
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	var buf buffer.IoBuffer
+	if body != "" {
+		buf = buffer.NewIoBufferString(body)
+	}
+	return f.OnReceive(context.Background(), headers, buf, nil)
+}
+
+func TestCreateJSONSchemaFilterFactory(t *testing.T) {
+	_, err := CreateJSONSchemaFilterFactory(newConf(t, userSchema, 0))
+	assert.Nil(t, err)
+	_, err = CreateJSONSchemaFilterFactory(newConf(t, `{"type": "unknown"}`, 0))
+	assert.NotNil(t, err)
+	_, err = CreateJSONSchemaFilterFactory(newConf(t, `{"pattern": "("}`, 0))
+	assert.NotNil(t, err)
+	// the unsupported keywords are rejected, not ignored
+	_, err = CreateJSONSchemaFilterFactory(newConf(t, `{"type": "object", "propertyNames": {"maxLength": 3}}`, 0))
+	assert.EqualError(t, err, "unsupported keyword propertyNames")
+	_, err = CreateJSONSchemaFilterFactory(newConf(t, `{"properties": {"id": {"$ref": "#/definitions/id"}}}`, 0))
+	assert.EqualError(t, err, "unsupported keyword $ref")
+	// the annotations are allowed
+	_, err = CreateJSONSchemaFilterFactory(newConf(t, `{"$schema": "http://json-schema.org/draft-07/schema#", "title": "user", "format": "email"}`, 0))
+	assert.Nil(t, err)
+	// the schema can be configured by the routes only
+	_, err = CreateJSONSchemaFilterFactory(map[string]interface{}{})
+	assert.Nil(t, err)
+}
+
+func TestJSONSchemaValidBody(t *testing.T) {
+	f, handler := newFilter(t, newConf(t, userSchema, 0), nil)
+	for _, body := range []string{
+		`{"name": "alice", "age": 20}`,
+		`{"name": "bob", "age": 0, "role": "admin", "tags": ["a", "b"], "email": null}`,
+		`{"name": "carol", "age": 149, "email": "carol@example.com"}`,
+	} {
+		assert.Equal(t, api.StreamFilterContinue, receive(f, "application/json; charset=utf-8", body), body)
+		assert.Equal(t, 0, handler.hijackCode, body)
+	}
+}
+
+func TestJSONSchemaInvalidBody(t *testing.T) {
+	f, handler := newFilter(t, newConf(t, userSchema, 0), nil)
+	testCases := []struct {
+		body       string
+		violations []violation
+	}{
+		{`[]`, []violation{{"$", "expected object, but got array"}}},
+		{`{"name": "alice"}`, []violation{{"$.age", "property is required"}}},
+		{`{"name": "Alice", "age": 1.5}`, []violation{
+			{"$.age", "expected integer, but got number"},
+			{"$.name", "value does not match the pattern ^[a-z]+$"},
+		}},
+		{`{"name": "alice", "age": 150, "role": "root"}`, []violation{
+			{"$.age", "expected less than 150"},
+			{"$.role", "value is not one of the enum"},
+		}},
+		{`{"name": "alice", "age": -1, "extra": 1}`, []violation{
+			{"$.age", "expected greater than or equal to 0"},
+			{"$.extra", "no value is allowed"},
+		}},
+		{`{"name": "alice", "age": 1, "tags": ["a", 1, "c"], "email": "alice"}`, []violation{
+			{"$.email", "value does not match any of the schemas"},
+			{"$.tags", "expected at most 2 items"},
+			{"$.tags[1]", "expected string, but got integer"},
+		}},
+	}
+	for _, tc := range testCases {
+		handler.hijackCode = 0
+		assert.Equal(t, api.StreamFilterStop, receive(f, "application/json", tc.body), tc.body)
+		assert.Equal(t, http.StatusBadRequest, handler.hijackCode, tc.body)
+		resp := &errorBody{}
+		require.Nil(t, json.Unmarshal([]byte(handler.hijackBody), resp))
+		assert.Equal(t, errSchemaViolations, resp.Error)
+		assert.Equal(t, tc.violations, resp.Violations, tc.body)
+	}
+	// malformed json
+	assert.Equal(t, api.StreamFilterStop, receive(f, "application/problem+json", `{"name":`))
+	resp := &errorBody{}
+	require.Nil(t, json.Unmarshal([]byte(handler.hijackBody), resp))
+	assert.Equal(t, errInvalidJSON, resp.Error)
+}
+
+func TestJSONSchemaSkip(t *testing.T) {
+	f, handler := newFilter(t, newConf(t, userSchema, 0), nil)
+	// missing or non json content type
+	assert.Equal(t, api.StreamFilterContinue, receive(f, "", `{}`))
+	assert.Equal(t, api.StreamFilterContinue, receive(f, "text/plain", `{}`))
+	// empty body
+	assert.Equal(t, api.StreamFilterContinue, receive(f, "application/json", ""))
+	assert.Equal(t, 0, handler.hijackCode)
+}
+
+func TestJSONSchemaMaxBodyBytes(t *testing.T) {
+	f, handler := newFilter(t, newConf(t, userSchema, 16), nil)
+	assert.Equal(t, api.StreamFilterStop, receive(f, "application/json", `{"name": "alice", "age": 20}`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, handler.hijackCode)
+	assert.Equal(t, api.ReqEntityTooLarge, handler.info.flag)
+}
+
+func TestJSONSchemaPerRouteConfig(t *testing.T) {
+	route := &mockRoute{
+		rule: &mockRouteRule{
+			config: map[string]interface{}{
+				"json_schema": newConf(t, `{"type": "array"}`, 0),
+			},
+		},
+	}
+	f, handler := newFilter(t, newConf(t, userSchema, 0), route)
+	assert.Equal(t, api.StreamFilterContinue, receive(f, "application/json", `[1, 2]`))
+	assert.Equal(t, api.StreamFilterStop, receive(f, "application/json", `{"name": "alice", "age": 20}`))
+	assert.Equal(t, http.StatusBadRequest, handler.hijackCode)
+	// the route without config uses the filter config
+	route.rule.config = nil
+	handler.hijackCode = 0
+	assert.Equal(t, api.StreamFilterContinue, receive(f, "application/json", `{"name": "alice", "age": 20}`))
+	assert.Equal(t, 0, handler.hijackCode)
+	// the request is rejected by the invalid route config
+	route.rule.config = map[string]interface{}{
+		"json_schema": newConf(t, `{"if": {"type": "array"}}`, 0),
+	}
+	assert.Equal(t, api.StreamFilterStop, receive(f, "application/json", `{"name": "alice", "age": 20}`))
+	assert.Equal(t, http.StatusInternalServerError, handler.hijackCode)
+}
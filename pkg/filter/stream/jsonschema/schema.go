@@ -0,0 +1,332 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// the json types of the schema
+const (
+	typeNull    = "null"
+	typeBoolean = "boolean"
+	typeObject  = "object"
+	typeArray   = "array"
+	typeNumber  = "number"
+	typeInteger = "integer"
+	typeString  = "string"
+)
+
+// schemaTypes is the "type" keyword, it can be a type name or a list of type names
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var types []string
+	if err := json.Unmarshal(data, &types); err != nil {
+		var typ string
+		if err := json.Unmarshal(data, &typ); err != nil {
+			return errors.New("type should be a string or an array of strings")
+		}
+		types = []string{typ}
+	}
+	for _, typ := range types {
+		switch typ {
+		case typeNull, typeBoolean, typeObject, typeArray, typeNumber, typeInteger, typeString:
+		default:
+			return fmt.Errorf("unknown type %s", typ)
+		}
+	}
+	*t = types
+	return nil
+}
+
+// schema supports the validation keywords of the json schema draft 7 below, the format is an annotation only.
+// the schema with the other keywords, such as the references, is rejected, so it is not validated partially.
+type schema struct {
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	AnyOf                []*schema          `json:"anyOf,omitempty"`
+	OneOf                []*schema          `json:"oneOf,omitempty"`
+	Not                  *schema            `json:"not,omitempty"`
+
+	// boolean is set for the boolean schema, true accepts any value and false rejects any value
+	boolean *bool
+	pattern *regexp.Regexp
+}
+
+// annotationKeywords are the keywords allowed in the schema which do not affect the validation
+var annotationKeywords = []string{
+	"$schema", "$id", "$comment", "title", "description", "default", "examples", "readOnly", "writeOnly", "format",
+}
+
+// knownKeywords are the keywords allowed in the schema, the validation keywords are the json names of the schema fields
+var knownKeywords = func() map[string]bool {
+	keywords := make(map[string]bool)
+	for _, keyword := range annotationKeywords {
+		keywords[keyword] = true
+	}
+	typ := reflect.TypeOf(schema{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]; name != "" {
+			keywords[name] = true
+		}
+	}
+	return keywords
+}()
+
+// parseSchema parses the json schema, the schema can be a json object or a boolean
+func parseSchema(data []byte) (*schema, error) {
+	s := &schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *schema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		s.boolean = &b
+		return nil
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !knownKeywords[name] {
+			return fmt.Errorf("unsupported keyword %s", name)
+		}
+	}
+	type plain schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	return nil
+}
+
+// violation describes a value does not conform to the schema
+type violation struct {
+	// Path is the location of the value, such as $.items[0].name
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// validate returns the violations of the value, the value is decoded by encoding/json
+func (s *schema) validate(path string, value interface{}) []violation {
+	if s.boolean != nil {
+		if *s.boolean {
+			return nil
+		}
+		return []violation{{path, "no value is allowed"}}
+	}
+	typ := typeOf(value)
+	if len(s.Type) > 0 && !s.matchType(typ, value) {
+		return []violation{{path, fmt.Sprintf("expected %s, but got %s", strings.Join(s.Type, " or "), typ)}}
+	}
+	var violations []violation
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		violations = append(violations, violation{path, "value is not one of the enum"})
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(path, v)...)
+	case []interface{}:
+		violations = append(violations, s.validateArray(path, v)...)
+	case string:
+		violations = append(violations, s.validateString(path, v)...)
+	case float64:
+		violations = append(violations, s.validateNumber(path, v)...)
+	}
+	for _, sub := range s.AllOf {
+		violations = append(violations, sub.validate(path, value)...)
+	}
+	if len(s.AnyOf) > 0 && s.matched(s.AnyOf, path, value) == 0 {
+		violations = append(violations, violation{path, "value does not match any of the schemas"})
+	}
+	if len(s.OneOf) > 0 {
+		if n := s.matched(s.OneOf, path, value); n != 1 {
+			violations = append(violations, violation{path, fmt.Sprintf("value should match exactly one schema, but matched %d", n)})
+		}
+	}
+	if s.Not != nil && len(s.Not.validate(path, value)) == 0 {
+		violations = append(violations, violation{path, "value should not match the schema"})
+	}
+	return violations
+}
+
+func (s *schema) matchType(typ string, value interface{}) bool {
+	for _, t := range s.Type {
+		if t == typ || (t == typeNumber && typ == typeInteger) {
+			return true
+		}
+	}
+	return false
+}
+
+// matched returns the number of the schemas the value matched
+func (s *schema) matched(schemas []*schema, path string, value interface{}) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.validate(path, value)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *schema) validateObject(path string, object map[string]interface{}) []violation {
+	var violations []violation
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			violations = append(violations, violation{path + "." + name, "property is required"})
+		}
+	}
+	// sort the names to make the violations stable
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sub, ok := s.Properties[name]; ok {
+			violations = append(violations, sub.validate(path+"."+name, object[name])...)
+		} else if s.AdditionalProperties != nil {
+			violations = append(violations, s.AdditionalProperties.validate(path+"."+name, object[name])...)
+		}
+	}
+	return violations
+}
+
+func (s *schema) validateArray(path string, array []interface{}) []violation {
+	var violations []violation
+	if s.MinItems != nil && len(array) < *s.MinItems {
+		violations = append(violations, violation{path, fmt.Sprintf("expected at least %d items", *s.MinItems)})
+	}
+	if s.MaxItems != nil && len(array) > *s.MaxItems {
+		violations = append(violations, violation{path, fmt.Sprintf("expected at most %d items", *s.MaxItems)})
+	}
+	if s.Items != nil {
+		for i, item := range array {
+			violations = append(violations, s.Items.validate(path+"["+strconv.Itoa(i)+"]", item)...)
+		}
+	}
+	return violations
+}
+
+func (s *schema) validateString(path string, str string) []violation {
+	var violations []violation
+	length := utf8.RuneCountInString(str)
+	if s.MinLength != nil && length < *s.MinLength {
+		violations = append(violations, violation{path, fmt.Sprintf("expected at least %d characters", *s.MinLength)})
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		violations = append(violations, violation{path, fmt.Sprintf("expected at most %d characters", *s.MaxLength)})
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		violations = append(violations, violation{path, fmt.Sprintf("value does not match the pattern %s", s.Pattern)})
+	}
+	return violations
+}
+
+func (s *schema) validateNumber(path string, number float64) []violation {
+	var violations []violation
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if s.Minimum != nil && number < *s.Minimum {
+		violations = append(violations, violation{path, "expected greater than or equal to " + format(*s.Minimum)})
+	}
+	if s.Maximum != nil && number > *s.Maximum {
+		violations = append(violations, violation{path, "expected less than or equal to " + format(*s.Maximum)})
+	}
+	if s.ExclusiveMinimum != nil && number <= *s.ExclusiveMinimum {
+		violations = append(violations, violation{path, "expected greater than " + format(*s.ExclusiveMinimum)})
+	}
+	if s.ExclusiveMaximum != nil && number >= *s.ExclusiveMaximum {
+		violations = append(violations, violation{path, "expected less than " + format(*s.ExclusiveMaximum)})
+	}
+	return violations
+}
+
+// typeOf returns the json type of the value decoded by encoding/json
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return typeNull
+	case bool:
+		return typeBoolean
+	case map[string]interface{}:
+		return typeObject
+	case []interface{}:
+		return typeArray
+	case string:
+		return typeString
+	case float64:
+		if v == math.Trunc(v) {
+			return typeInteger
+		}
+		return typeNumber
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
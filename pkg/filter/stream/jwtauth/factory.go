@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+var (
+	errNoProvider = errors.New("at least one jwt provider is required")
+	errNoIssuer   = errors.New("the issuer of the jwt provider is required")
+	errNoJwks     = errors.New("one of local_jwks and remote_jwks is required")
+)
+
+func init() {
+	api.RegisterStream(v2.JwtAuth, CreateJwtAuthFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	config *jwtAuthConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+}
+
+// CreateJwtAuthFilterFactory creates the jwt auth filter factory
+func CreateJwtAuthFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create jwt auth stream filter factory")
+	cfg, err := ParseStreamJwtAuthFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeJwtAuthConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamJwtAuthFilter
+func ParseStreamJwtAuthFilter(cfg map[string]interface{}) (*v2.StreamJwtAuth, error) {
+	filterConfig := &v2.StreamJwtAuth{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// jwtAuthConfig is parsed from v2.StreamJwtAuth, the jwks is shared by the filters created by the factory
+type jwtAuthConfig struct {
+	providers []*provider
+}
+
+func makeJwtAuthConfig(cfg *v2.StreamJwtAuth) (*jwtAuthConfig, error) {
+	if len(cfg.Providers) == 0 {
+		return nil, errNoProvider
+	}
+	config := &jwtAuthConfig{}
+	for _, pc := range cfg.Providers {
+		if pc.Issuer == "" {
+			return nil, errNoIssuer
+		}
+		p := &provider{
+			issuer:     pc.Issuer,
+			audiences:  pc.Audiences,
+			pathPrefix: pc.PathPrefix,
+			forward:    pc.Forward,
+		}
+		switch {
+		case pc.LocalJwks != "":
+			keys, err := newLocalJwks(pc.LocalJwks)
+			if err != nil {
+				return nil, fmt.Errorf("parse local jwks of issuer %s failed: %v", pc.Issuer, err)
+			}
+			p.jwks = keys
+		case pc.RemoteJwks != "":
+			p.jwks = newRemoteJwks(pc.RemoteJwks, pc.CacheDuration.Duration)
+		default:
+			return nil, errNoJwks
+		}
+		config.providers = append(config.providers, p)
+	}
+	return config, nil
+}
@@ -0,0 +1,242 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package jwtauth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat/go-jwx/jwk"
+	"mosn.io/pkg/utils"
+)
+
+const (
+	// defaultCacheDuration is the default cache duration of the remote jwks
+	defaultCacheDuration = 10 * time.Minute
+	fetchTimeout         = 5 * time.Second
+)
+
+// minRefreshInterval limits the refresh triggered by an unknown key id
+var minRefreshInterval = 10 * time.Second
+
+// minFetchBackOff is the interval before the next fetch after the first failure,
+// it is doubled on each failure up to the cache duration
+var minFetchBackOff = time.Second
+
+var errKeyNotFound = errors.New("no key matches the key id")
+
+// jwks caches the json web key set of an issuer.
+// the remote jwks is fetched when it is expired, or a token is signed by an unknown key.
+// the fetch is done in background without holding the lock, and the concurrent requests share one fetch.
+type jwks struct {
+	uri           string
+	cacheDuration time.Duration
+	client        *http.Client
+
+	mutex     sync.Mutex
+	set       *jwk.Set
+	expire    time.Time
+	lastFetch time.Time
+	// fetching is closed when the fetch in progress is done, nil means no fetch in progress
+	fetching chan struct{}
+	// err is the error of the last fetch, the next fetch is not started before retryAt
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+func newLocalJwks(s string) (*jwks, error) {
+	set, err := jwk.ParseString(s)
+	if err != nil {
+		return nil, err
+	}
+	return &jwks{
+		set: set,
+	}, nil
+}
+
+func newRemoteJwks(uri string, cacheDuration time.Duration) *jwks {
+	if cacheDuration <= 0 {
+		cacheDuration = defaultCacheDuration
+	}
+	return &jwks{
+		uri:           uri,
+		cacheDuration: cacheDuration,
+		client: &http.Client{
+			Timeout: fetchTimeout,
+		},
+	}
+}
+
+// getKey returns the public key of the key id, if the kid is empty, the only key in the set is used.
+func (j *jwks) getKey(kid string) (interface{}, error) {
+	if j.uri == "" {
+		return lookupKey(j.set, kid)
+	}
+	set, err := j.keySet()
+	if err != nil {
+		return nil, err
+	}
+	key, err := lookupKey(set, kid)
+	// the keys may be rotated
+	if err == errKeyNotFound {
+		if set, ok := j.refresh(); ok {
+			key, err = lookupKey(set, kid)
+		}
+	}
+	return key, err
+}
+
+// keySet returns the cached key set, the expired key set is refreshed in background and still used
+// until the new one is fetched. the caller waits for the fetch only if no key set is fetched yet.
+func (j *jwks) keySet() (*jwk.Set, error) {
+	j.mutex.Lock()
+	if set := j.set; set != nil {
+		if time.Now().After(j.expire) {
+			j.fetchLocked()
+		}
+		j.mutex.Unlock()
+		return set, nil
+	}
+	done := j.fetchLocked()
+	j.mutex.Unlock()
+
+	<-done
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.set == nil {
+		return nil, j.err
+	}
+	return j.set, nil
+}
+
+// refresh fetches the key set for an unknown key id and waits for it,
+// ok is false if the key set has been fetched in the min refresh interval.
+func (j *jwks) refresh() (*jwk.Set, bool) {
+	j.mutex.Lock()
+	if j.fetching == nil && time.Since(j.lastFetch) < minRefreshInterval {
+		j.mutex.Unlock()
+		return nil, false
+	}
+	done := j.fetchLocked()
+	j.mutex.Unlock()
+
+	<-done
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.set, j.set != nil
+}
+
+// fetchLocked starts fetching the key set in background if no fetch is in progress, and returns
+// a channel closed when the fetch is done. no fetch is started in the back off after failures.
+// the caller should hold the mutex.
+func (j *jwks) fetchLocked() <-chan struct{} {
+	if j.fetching != nil {
+		return j.fetching
+	}
+	done := make(chan struct{})
+	now := time.Now()
+	if now.Before(j.retryAt) {
+		close(done)
+		return done
+	}
+	j.fetching = done
+	j.lastFetch = now
+	utils.GoWithRecover(func() {
+		set, err := j.fetch()
+		j.mutex.Lock()
+		j.onFetched(set, err)
+		j.mutex.Unlock()
+	}, func(r interface{}) {
+		j.mutex.Lock()
+		j.onFetched(nil, fmt.Errorf("fetch jwks from %s failed: %v", j.uri, r))
+		j.mutex.Unlock()
+	})
+	return done
+}
+
+// onFetched updates the key set with the result of the fetch, the last key set is kept if the fetch failed.
+// the caller should hold the mutex.
+func (j *jwks) onFetched(set *jwk.Set, err error) {
+	now := time.Now()
+	if err != nil {
+		j.err = err
+		j.failures++
+		j.retryAt = now.Add(fetchBackOff(j.failures, j.cacheDuration))
+	} else {
+		j.set = set
+		j.expire = now.Add(j.cacheDuration)
+		j.err, j.failures, j.retryAt = nil, 0, time.Time{}
+	}
+	if j.fetching != nil {
+		close(j.fetching)
+		j.fetching = nil
+	}
+}
+
+// fetchBackOff returns the interval before the next fetch after the failures
+func fetchBackOff(failures int, max time.Duration) time.Duration {
+	backOff := minFetchBackOff
+	for i := 1; i < failures && backOff < max; i++ {
+		backOff *= 2
+	}
+	if backOff > max {
+		backOff = max
+	}
+	return backOff
+}
+
+// fetch gets the remote jwks
+func (j *jwks) fetch() (*jwk.Set, error) {
+	resp, err := j.client.Get(j.uri)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks from %s failed: %v", j.uri, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks from %s failed: status code %d", j.uri, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks from %s failed: %v", j.uri, err)
+	}
+	set, err := jwk.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse jwks from %s failed: %v", j.uri, err)
+	}
+	return set, nil
+}
+
+func lookupKey(set *jwk.Set, kid string) (interface{}, error) {
+	if set == nil {
+		return nil, errKeyNotFound
+	}
+	var keys []jwk.Key
+	if kid == "" {
+		keys = set.Keys
+	} else {
+		keys = set.LookupKeyID(kid)
+	}
+	if len(keys) != 1 {
+		return nil, errKeyNotFound
+	}
+	return keys[0].Materialize()
+}
@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/streamfilter"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	headerAuthorization = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+var (
+	errNoToken          = errors.New("no bearer token in the request")
+	errIssuerNotAllowed = errors.New("the issuer of the token is not allowed")
+	errAudienceMismatch = errors.New("the audience of the token is not allowed")
+)
+
+// provider verifies the tokens issued by the issuer
+type provider struct {
+	issuer     string
+	audiences  []string
+	pathPrefix string
+	forward    bool
+	jwks       *jwks
+}
+
+func (p *provider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return p.jwks.getKey(kid)
+}
+
+// verify checks the signature, the exp, nbf, iat, iss and aud claims of the token
+func (p *provider) verify(raw string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(raw, claims, p.keyFunc); err != nil {
+		return nil, err
+	}
+	if !claims.VerifyIssuer(p.issuer, true) {
+		return nil, errIssuerNotAllowed
+	}
+	if len(p.audiences) == 0 {
+		return claims, nil
+	}
+	for _, aud := range p.audiences {
+		if claims.VerifyAudience(aud, true) {
+			return claims, nil
+		}
+	}
+	return nil, errAudienceMismatch
+}
+
+// jwtAuthFilter authenticates the request by the bearer token in the authorization header,
+// the verified claims are stored in the dynamic metadata, which namespace is the filter name
+// and the key is the issuer.
+type jwtAuthFilter struct {
+	ctx     context.Context
+	config  *jwtAuthConfig
+	handler api.StreamReceiverFilterHandler
+}
+
+func NewStreamFilter(ctx context.Context, config *jwtAuthConfig) *jwtAuthFilter {
+	return &jwtAuthFilter{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (f *jwtAuthFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *jwtAuthFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	path, _ := variable.GetString(ctx, types.VarPath)
+	providers := f.config.match(path)
+	if len(providers) == 0 {
+		return api.StreamFilterContinue
+	}
+	p, claims, err := f.authenticate(headers, providers)
+	if err != nil {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter] [jwt_auth] authenticate request %s failed: %v", path, err)
+		}
+		f.handler.SendHijackReply(http.StatusUnauthorized, headers)
+		return api.StreamFilterStop
+	}
+	if !p.forward {
+		headers.Del(headerAuthorization)
+	}
+	if md, ok := f.handler.(streamfilter.StreamFilterDynamicMetadata); ok {
+		md.SetDynamicMetadata(v2.JwtAuth, p.issuer, map[string]interface{}(claims))
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *jwtAuthFilter) OnDestroy() {}
+
+// authenticate verifies the token by the provider of the token's issuer
+func (f *jwtAuthFilter) authenticate(headers api.HeaderMap, providers []*provider) (*provider, jwt.MapClaims, error) {
+	raw, ok := extractToken(headers)
+	if !ok {
+		return nil, nil, errNoToken
+	}
+	// the issuer is trusted only after the token is verified
+	unverified := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(raw, unverified); err != nil {
+		return nil, nil, err
+	}
+	iss, _ := unverified["iss"].(string)
+	for _, p := range providers {
+		if p.issuer == iss {
+			claims, err := p.verify(raw)
+			return p, claims, err
+		}
+	}
+	return nil, nil, errIssuerNotAllowed
+}
+
+// match returns the providers of the request path
+func (c *jwtAuthConfig) match(path string) []*provider {
+	var providers []*provider
+	for _, p := range c.providers {
+		if strings.HasPrefix(path, p.pathPrefix) {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+func extractToken(headers api.HeaderMap) (string, bool) {
+	auth, ok := headers.Get(headerAuthorization)
+	if !ok || len(auth) <= len(bearerPrefix) || !strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len(bearerPrefix):])
+	return token, token != ""
+}
@@ -0,0 +1,349 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package jwtauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	hijackCode int
+	metadata   map[string]map[string]interface{}
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+func (h *mockReceiveHandler) GetDynamicMetadata(namespace, key string) (interface{}, bool) {
+	value, ok := h.metadata[namespace][key]
+	return value, ok
+}
+
+func (h *mockReceiveHandler) SetDynamicMetadata(namespace, key string, value interface{}) {
+	if h.metadata == nil {
+		h.metadata = map[string]map[string]interface{}{}
+	}
+	if h.metadata[namespace] == nil {
+		h.metadata[namespace] = map[string]interface{}{}
+	}
+	h.metadata[namespace][key] = value
+}
+
+type signer struct {
+	kid  string
+	priv *rsa.PrivateKey
+}
+
+func newSigner(t *testing.T, kid string) *signer {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	return &signer{
+		kid:  kid,
+		priv: priv,
+	}
+}
+
+func (s *signer) jwks() string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return fmt.Sprintf(`{"keys":[{"kty":"RSA","alg":"RS256","use":"sig","kid":"%s","n":"%s","e":"%s"}]}`,
+		s.kid, encode(s.priv.N.Bytes()), encode(big.NewInt(int64(s.priv.E)).Bytes()))
+}
+
+func (s *signer) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.kid
+	raw, err := token.SignedString(s.priv)
+	require.Nil(t, err)
+	return raw
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}) (*jwtAuthFilter, *mockReceiveHandler) {
+	factory, err := CreateJwtAuthFilterFactory(conf)
+	require.Nil(t, err)
+	f := NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+	handler := &mockReceiveHandler{}
+	f.SetReceiveFilterHandler(handler)
+	return f, handler
+}
+
+func newRequest(path, token string) (context.Context, api.HeaderMap) {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, types.VarPath, path)
+	headers := protocol.CommonHeader{}
+	if token != "" {
+		headers.Set(headerAuthorization, "Bearer "+token)
+	}
+	return ctx, headers
+}
+
+func TestJwtAuth(t *testing.T) {
+	foo := newSigner(t, "foo-key")
+	bar := newSigner(t, "bar-key")
+	conf := map[string]interface{}{
+		"providers": []interface{}{
+			map[string]interface{}{
+				"issuer":     "https://foo.example.com",
+				"audiences":  []string{"api"},
+				"local_jwks": foo.jwks(),
+			},
+			map[string]interface{}{
+				"issuer":      "https://bar.example.com",
+				"path_prefix": "/bar",
+				"local_jwks":  bar.jwks(),
+				"forward":     true,
+			},
+		},
+	}
+	now := time.Now()
+	valid := jwt.MapClaims{
+		"iss": "https://foo.example.com",
+		"aud": "api",
+		"sub": "alice",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	expired := jwt.MapClaims{
+		"iss": "https://foo.example.com",
+		"aud": "api",
+		"exp": now.Add(-time.Hour).Unix(),
+	}
+	wrongAudience := jwt.MapClaims{
+		"iss": "https://foo.example.com",
+		"aud": "other",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	wrongIssuer := jwt.MapClaims{
+		"iss": "https://evil.example.com",
+		"aud": "api",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	barClaims := jwt.MapClaims{
+		"iss": "https://bar.example.com",
+		"sub": "bob",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		f, handler := newFilter(t, conf)
+		ctx, headers := newRequest("/foo", foo.sign(t, valid))
+		assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+		assert.Equal(t, 0, handler.hijackCode)
+		// the token is stripped
+		_, ok := headers.Get(headerAuthorization)
+		assert.False(t, ok)
+		claims, ok := handler.GetDynamicMetadata(v2.JwtAuth, "https://foo.example.com")
+		require.True(t, ok)
+		assert.Equal(t, "alice", claims.(map[string]interface{})["sub"])
+	})
+
+	t.Run("forward token", func(t *testing.T) {
+		f, handler := newFilter(t, conf)
+		ctx, headers := newRequest("/bar/baz", bar.sign(t, barClaims))
+		assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+		_, ok := headers.Get(headerAuthorization)
+		assert.True(t, ok)
+		claims, ok := handler.GetDynamicMetadata(v2.JwtAuth, "https://bar.example.com")
+		require.True(t, ok)
+		assert.Equal(t, "bob", claims.(map[string]interface{})["sub"])
+	})
+
+	for _, tc := range []struct {
+		name  string
+		path  string
+		token string
+	}{
+		{"no token", "/foo", ""},
+		{"expired token", "/foo", foo.sign(t, expired)},
+		{"wrong audience", "/foo", foo.sign(t, wrongAudience)},
+		{"wrong issuer", "/foo", foo.sign(t, wrongIssuer)},
+		// signed by the key of another issuer
+		{"wrong signature", "/foo", bar.sign(t, valid)},
+		// the bar issuer is not allowed out of the path prefix
+		{"path not matched", "/foo", bar.sign(t, barClaims)},
+		{"malformed token", "/foo", "not.a.jwt"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, handler := newFilter(t, conf)
+			ctx, headers := newRequest(tc.path, tc.token)
+			assert.Equal(t, api.StreamFilterStop, f.OnReceive(ctx, headers, nil, nil))
+			assert.Equal(t, http.StatusUnauthorized, handler.hijackCode)
+			assert.Nil(t, handler.metadata)
+		})
+	}
+}
+
+func TestJwtAuthPathNotMatched(t *testing.T) {
+	bar := newSigner(t, "bar-key")
+	f, handler := newFilter(t, map[string]interface{}{
+		"providers": []interface{}{
+			map[string]interface{}{
+				"issuer":      "https://bar.example.com",
+				"path_prefix": "/bar",
+				"local_jwks":  bar.jwks(),
+			},
+		},
+	})
+	// no provider matches the path, the request is not authenticated
+	ctx, headers := newRequest("/foo", "")
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+	assert.Equal(t, 0, handler.hijackCode)
+}
+
+func TestRemoteJwksRefresh(t *testing.T) {
+	old := minRefreshInterval
+	minRefreshInterval = 0
+	defer func() {
+		minRefreshInterval = old
+	}()
+
+	first := newSigner(t, "first")
+	second := newSigner(t, "second")
+	var mutex sync.Mutex
+	current := first
+	var fetched int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.Write([]byte(current.jwks()))
+	}))
+	defer server.Close()
+
+	f, handler := newFilter(t, map[string]interface{}{
+		"providers": []interface{}{
+			map[string]interface{}{
+				"issuer":         "https://foo.example.com",
+				"remote_jwks":    server.URL,
+				"cache_duration": "1h",
+			},
+		},
+	})
+	claims := jwt.MapClaims{
+		"iss": "https://foo.example.com",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	// the jwks is fetched and cached
+	for i := 0; i < 3; i++ {
+		ctx, headers := newRequest("/", first.sign(t, claims))
+		assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// the keys are rotated, the unknown key id triggers a refresh
+	mutex.Lock()
+	current = second
+	mutex.Unlock()
+	ctx, headers := newRequest("/", second.sign(t, claims))
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+	assert.Equal(t, 0, handler.hijackCode)
+
+	// the old key is removed
+	ctx, headers = newRequest("/", first.sign(t, claims))
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(ctx, headers, nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, handler.hijackCode)
+}
+
+func TestRemoteJwksFetchBackOff(t *testing.T) {
+	signer := newSigner(t, "key")
+	var fetched, fail int32 = 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(signer.jwks()))
+	}))
+	defer server.Close()
+	j := newRemoteJwks(server.URL, time.Hour)
+
+	// the concurrent requests share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := j.getKey("")
+			assert.NotNil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+	// no fetch in the back off
+	_, err := j.getKey("")
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// fetched after the back off
+	atomic.StoreInt32(&fail, 0)
+	j.mutex.Lock()
+	j.retryAt = time.Time{}
+	j.mutex.Unlock()
+	_, err = j.getKey("")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+
+	// the expired key set is used while it is refreshed in background
+	atomic.StoreInt32(&fail, 1)
+	j.mutex.Lock()
+	j.expire = time.Now().Add(-time.Second)
+	j.mutex.Unlock()
+	_, err = j.getKey("")
+	assert.Nil(t, err)
+	for i := 0; i < 100 && atomic.LoadInt32(&fetched) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = j.getKey("")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetched))
+}
+
+func TestCreateJwtAuthFilterFactory(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{},
+		{"providers": []interface{}{map[string]interface{}{"local_jwks": `{"keys":[]}`}}},
+		{"providers": []interface{}{map[string]interface{}{"issuer": "foo"}}},
+		{"providers": []interface{}{map[string]interface{}{"issuer": "foo", "local_jwks": "invalid"}}},
+	} {
+		_, err := CreateJwtAuthFilterFactory(conf)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// tokenBucket is a lock free token bucket, the tokens are refilled lazily when taken.
+type tokenBucket struct {
+	fillInterval  int64 // nanoseconds
+	maxTokens     int64
+	tokensPerFill int64
+
+	tokens int64
+	// lastFill is the unix nano time of the last fill
+	lastFill int64
+}
+
+// newTokenBucket creates a token bucket which is full at the beginning
+func newTokenBucket(fillInterval time.Duration, maxTokens, tokensPerFill int64, now int64) *tokenBucket {
+	return &tokenBucket{
+		fillInterval:  int64(fillInterval),
+		maxTokens:     maxTokens,
+		tokensPerFill: tokensPerFill,
+		tokens:        maxTokens,
+		lastFill:      now,
+	}
+}
+
+// take takes a token from the bucket, if the bucket is empty, returns false
+// and the duration to wait for the next fill.
+func (b *tokenBucket) take(now int64) (bool, time.Duration) {
+	b.refill(now)
+	for {
+		tokens := atomic.LoadInt64(&b.tokens)
+		if tokens <= 0 {
+			wait := atomic.LoadInt64(&b.lastFill) + b.fillInterval - now
+			if wait < 0 {
+				wait = 0
+			}
+			return false, time.Duration(wait)
+		}
+		if atomic.CompareAndSwapInt64(&b.tokens, tokens, tokens-1) {
+			return true, 0
+		}
+	}
+}
+
+// refill adds the tokens of the passed fill intervals, only the one who
+// moves the fill time forward adds the tokens, the others skip the refill.
+func (b *tokenBucket) refill(now int64) {
+	last := atomic.LoadInt64(&b.lastFill)
+	fills := (now - last) / b.fillInterval
+	if fills <= 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt64(&b.lastFill, last, last+fills*b.fillInterval) {
+		return
+	}
+	add := b.maxTokens
+	if fills < b.maxTokens/b.tokensPerFill+1 {
+		add = fills * b.tokensPerFill
+	}
+	for {
+		tokens := atomic.LoadInt64(&b.tokens)
+		n := tokens + add
+		if n > b.maxTokens {
+			n = b.maxTokens
+		}
+		if atomic.CompareAndSwapInt64(&b.tokens, tokens, n) {
+			return
+		}
+	}
+}
+
+// full returns true if the bucket has the max tokens
+func (b *tokenBucket) full() bool {
+	return atomic.LoadInt64(&b.tokens) >= b.maxTokens
+}
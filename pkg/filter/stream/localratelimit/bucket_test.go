@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now().UnixNano()
+	b := newTokenBucket(100*time.Millisecond, 3, 2, now)
+	// saturate the bucket
+	for i := 0; i < 3; i++ {
+		ok, _ := b.take(now)
+		assert.True(t, ok)
+	}
+	ok, wait := b.take(now + int64(40*time.Millisecond))
+	assert.False(t, ok)
+	assert.Equal(t, 60*time.Millisecond, wait)
+
+	// refill 2 tokens after a fill interval
+	now += int64(100 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		ok, _ = b.take(now)
+		assert.True(t, ok)
+	}
+	ok, _ = b.take(now)
+	assert.False(t, ok)
+
+	// refill no more than the max tokens
+	now += int64(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		ok, _ = b.take(now)
+		assert.True(t, ok)
+	}
+	ok, _ = b.take(now)
+	assert.False(t, ok)
+}
+
+func TestTokenBucketConcurrency(t *testing.T) {
+	now := time.Now().UnixNano()
+	b := newTokenBucket(time.Second, 100, 100, now)
+	var (
+		wg      sync.WaitGroup
+		allowed int64
+	)
+	// 200 requests in 2 fill intervals
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ts := now
+			if i%2 == 0 {
+				ts += int64(time.Second)
+			}
+			for j := 0; j < 2; j++ {
+				if ok, _ := b.take(ts); ok {
+					atomic.AddInt64(&allowed, 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	// at most the initial tokens and one refill are taken
+	assert.True(t, allowed <= 200 && allowed >= 100, "allowed: %d", allowed)
+}
@@ -0,0 +1,61 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+)
+
+func init() {
+	api.RegisterStream(v2.LocalRateLimit, CreateLocalRateLimitFilterFactory)
+	router.RegisterPerFilterConfigParser(v2.LocalRateLimit, parseRouteConfig)
+}
+
+type FilterConfigFactory struct {
+	limiter *limiter
+}
+
+// CreateFilterChain adds the listener level filter before route if the token bucket
+// is configured, and the route level filter after route. a request is limited by
+// both of the listener's and the route's buckets.
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	if f.limiter != nil {
+		callbacks.AddStreamReceiverFilter(newListenerFilter(f.limiter), api.BeforeRoute)
+	}
+	callbacks.AddStreamReceiverFilter(newRouteFilter(), api.AfterRoute)
+}
+
+// CreateLocalRateLimitFilterFactory creates the local rate limit filter factory,
+// the listener's requests share the buckets of the factory.
+func CreateLocalRateLimitFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create local rate limit stream filter factory")
+	cfg, err := ParseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	factory := &FilterConfigFactory{}
+	if !cfg.IsEmpty() {
+		factory.limiter = newLimiter(cfg)
+	}
+	return factory, nil
+}
@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/pkg/buffer"
+)
+
+const headerRetryAfter = "Retry-After"
+
+// rateLimitFilter rejects the request with 429 if no token left in the bucket.
+// the listener level filter uses the limiter of the filter config, and the
+// route level filter uses the limiter of the route's per filter config.
+type rateLimitFilter struct {
+	limiter *limiter
+	handler api.StreamReceiverFilterHandler
+}
+
+func newListenerFilter(l *limiter) *rateLimitFilter {
+	return &rateLimitFilter{
+		limiter: l,
+	}
+}
+
+func newRouteFilter() *rateLimitFilter {
+	return &rateLimitFilter{}
+}
+
+func (f *rateLimitFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *rateLimitFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	l := f.limiter
+	if l == nil {
+		var err error
+		if l, err = f.routeLimiter(); err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter] [local_rate_limit] invalid route config: %v", err)
+			f.handler.SendHijackReply(http.StatusInternalServerError, headers)
+			return api.StreamFilterStop
+		}
+	}
+	if l == nil {
+		return api.StreamFilterContinue
+	}
+
+	allowed, wait := l.allow(headers)
+	if allowed {
+		return api.StreamFilterContinue
+	}
+
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(ctx, "[stream filter] [local_rate_limit] request is rate limited, retry after %v", wait)
+	}
+	f.handler.RequestInfo().SetResponseFlag(api.RateLimited)
+	headers.Set(headerRetryAfter, strconv.Itoa(retryAfterSeconds(wait)))
+	f.handler.SendHijackReply(http.StatusTooManyRequests, headers)
+	return api.StreamFilterStop
+}
+
+func (f *rateLimitFilter) OnDestroy() {}
+
+// routeLimiter returns the limiter of the route, which is parsed when the route is created
+func (f *rateLimitFilter) routeLimiter() (*limiter, error) {
+	route := f.handler.Route()
+	if route == nil {
+		return nil, nil
+	}
+	cfg, ok, err := router.ParsedPerFilterConfig(route.RouteRule(), v2.LocalRateLimit)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return cfg.(*limiter), nil
+}
+
+// retryAfterSeconds rounds up the wait duration to seconds, at least 1 second
+func retryAfterSeconds(wait time.Duration) int {
+	s := int(math.Ceil(wait.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
@@ -0,0 +1,243 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+type mockHandler struct {
+	api.StreamReceiverFilterHandler
+	route       api.Route
+	requestInfo api.RequestInfo
+	code        int
+	headers     api.HeaderMap
+}
+
+func (h *mockHandler) Route() api.Route {
+	return h.route
+}
+
+func (h *mockHandler) RequestInfo() api.RequestInfo {
+	return h.requestInfo
+}
+
+func (h *mockHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.code = code
+	h.headers = headers
+}
+
+func newMockHandler(route api.Route) *mockHandler {
+	return &mockHandler{
+		route:       route,
+		requestInfo: network.NewRequestInfo(),
+	}
+}
+
+// parsedRouteRule keeps the per filter configs parsed once, as the routes of the router package
+type parsedRouteRule struct {
+	api.RouteRule
+	parsed map[string]interface{}
+}
+
+func (r *parsedRouteRule) ParsedPerFilterConfig(name string) (interface{}, bool) {
+	cfg, ok := r.parsed[name]
+	return cfg, ok
+}
+
+func receive(f *rateLimitFilter, handler *mockHandler, headers api.HeaderMap) api.StreamFilterStatus {
+	f.SetReceiveFilterHandler(handler)
+	return f.OnReceive(context.Background(), headers, nil, nil)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(map[string]interface{}{
+		"fill_interval": "1s",
+		"max_tokens":    10,
+		"key_header":    "x-api-key",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, time.Second, cfg.FillInterval.Duration)
+	assert.Equal(t, int64(10), cfg.MaxTokens)
+	assert.Equal(t, int64(1), cfg.TokensPerFill)
+	assert.Equal(t, "x-api-key", cfg.KeyHeader)
+	assert.Equal(t, int64(defaultMaxKeys), cfg.MaxKeys)
+
+	cfg, err = ParseConfig(map[string]interface{}{})
+	require.Nil(t, err)
+	assert.True(t, cfg.IsEmpty())
+
+	for _, c := range []map[string]interface{}{
+		{"max_tokens": 10},
+		{"fill_interval": "1s"},
+		{"fill_interval": "1s", "max_tokens": 10, "tokens_per_fill": -1},
+		{"fill_interval": "1s", "max_tokens": 10, "max_keys": -1},
+	} {
+		_, err = ParseConfig(c)
+		assert.NotNil(t, err, "config: %v", c)
+	}
+}
+
+func TestListenerRateLimit(t *testing.T) {
+	factory, err := CreateLocalRateLimitFilterFactory(map[string]interface{}{
+		"fill_interval":   "200ms",
+		"max_tokens":      2,
+		"tokens_per_fill": 1,
+	})
+	require.Nil(t, err)
+	l := factory.(*FilterConfigFactory).limiter
+	require.NotNil(t, l)
+
+	// saturate the bucket
+	for i := 0; i < 2; i++ {
+		handler := newMockHandler(nil)
+		assert.Equal(t, api.StreamFilterContinue, receive(newListenerFilter(l), handler, protocol.CommonHeader{}))
+	}
+	handler := newMockHandler(nil)
+	assert.Equal(t, api.StreamFilterStop, receive(newListenerFilter(l), handler, protocol.CommonHeader{}))
+	assert.Equal(t, http.StatusTooManyRequests, handler.code)
+	retryAfter, _ := handler.headers.Get(headerRetryAfter)
+	assert.Equal(t, "1", retryAfter)
+	assert.True(t, handler.requestInfo.GetResponseFlag(api.RateLimited))
+
+	// refilled
+	time.Sleep(250 * time.Millisecond)
+	handler = newMockHandler(nil)
+	assert.Equal(t, api.StreamFilterContinue, receive(newListenerFilter(l), handler, protocol.CommonHeader{}))
+	handler = newMockHandler(nil)
+	assert.Equal(t, api.StreamFilterStop, receive(newListenerFilter(l), handler, protocol.CommonHeader{}))
+}
+
+func TestKeyHeaderRateLimit(t *testing.T) {
+	l := newLimiter(&Config{
+		FillInterval:  api.DurationConfig{Duration: time.Hour},
+		MaxTokens:     1,
+		TokensPerFill: 1,
+		KeyHeader:     "x-api-key",
+	})
+	f := newListenerFilter(l)
+	// each key has its own bucket
+	for _, key := range []string{"a", "b"} {
+		assert.Equal(t, api.StreamFilterContinue, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": key}))
+		assert.Equal(t, api.StreamFilterStop, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": key}))
+	}
+	// the requests without key share a bucket
+	assert.Equal(t, api.StreamFilterContinue, receive(f, newMockHandler(nil), protocol.CommonHeader{}))
+	assert.Equal(t, api.StreamFilterStop, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-other": "a"}))
+}
+
+func TestKeyHeaderMaxKeys(t *testing.T) {
+	l := newLimiter(&Config{
+		FillInterval:  api.DurationConfig{Duration: 100 * time.Millisecond},
+		MaxTokens:     1,
+		TokensPerFill: 1,
+		KeyHeader:     "x-api-key",
+		MaxKeys:       2,
+	})
+	f := newListenerFilter(l)
+	for _, key := range []string{"a", "b"} {
+		assert.Equal(t, api.StreamFilterContinue, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": key}))
+	}
+	// no idle bucket to evict, the new keys share the common bucket
+	assert.Equal(t, api.StreamFilterContinue, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": "c"}))
+	assert.Equal(t, api.StreamFilterStop, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": "d"}))
+	assert.Equal(t, int64(2), l.keys)
+
+	// the refilled buckets are evicted
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, api.StreamFilterContinue, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": "c"}))
+	assert.Equal(t, int64(1), l.keys)
+	_, ok := l.buckets.Load("a")
+	assert.False(t, ok)
+	assert.Equal(t, api.StreamFilterStop, receive(f, newMockHandler(nil), protocol.CommonHeader{"x-api-key": "c"}))
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newRoute := func(perFilterConfig map[string]interface{}) api.Route {
+		rule := mock.NewMockRouteRule(ctrl)
+		rule.EXPECT().PerFilterConfig().Return(perFilterConfig).AnyTimes()
+		parsed := map[string]interface{}{}
+		if cfg, ok := perFilterConfig[v2.LocalRateLimit]; ok {
+			l, err := parseRouteConfig(cfg)
+			require.Nil(t, err)
+			parsed[v2.LocalRateLimit] = l
+		}
+		route := mock.NewMockRoute(ctrl)
+		route.EXPECT().RouteRule().Return(&parsedRouteRule{RouteRule: rule, parsed: parsed}).AnyTimes()
+		return route
+	}
+	config := map[string]interface{}{
+		v2.LocalRateLimit: map[string]interface{}{
+			"fill_interval": "1h",
+			"max_tokens":    1,
+		},
+	}
+	limited := newRoute(config)
+	unlimited := newRoute(map[string]interface{}{})
+
+	factory, err := CreateLocalRateLimitFilterFactory(map[string]interface{}{})
+	require.Nil(t, err)
+	assert.Nil(t, factory.(*FilterConfigFactory).limiter)
+
+	// no route is matched
+	assert.Equal(t, api.StreamFilterContinue, receive(newRouteFilter(), newMockHandler(nil), protocol.CommonHeader{}))
+
+	assert.Equal(t, api.StreamFilterContinue, receive(newRouteFilter(), newMockHandler(limited), protocol.CommonHeader{}))
+	handler := newMockHandler(limited)
+	assert.Equal(t, api.StreamFilterStop, receive(newRouteFilter(), handler, protocol.CommonHeader{}))
+	assert.Equal(t, http.StatusTooManyRequests, handler.code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, api.StreamFilterContinue, receive(newRouteFilter(), newMockHandler(unlimited), protocol.CommonHeader{}))
+	}
+
+	// the routes with the same config have their own buckets
+	assert.Equal(t, api.StreamFilterContinue, receive(newRouteFilter(), newMockHandler(newRoute(config)), protocol.CommonHeader{}))
+
+	// the invalid route config is rejected
+	rule := mock.NewMockRouteRule(ctrl)
+	rule.EXPECT().PerFilterConfig().Return(map[string]interface{}{
+		v2.LocalRateLimit: map[string]interface{}{"max_tokens": 1},
+	}).AnyTimes()
+	route := mock.NewMockRoute(ctrl)
+	route.EXPECT().RouteRule().Return(rule).AnyTimes()
+	handler = newMockHandler(route)
+	assert.Equal(t, api.StreamFilterStop, receive(newRouteFilter(), handler, protocol.CommonHeader{}))
+	assert.Equal(t, http.StatusInternalServerError, handler.code)
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, 1, retryAfterSeconds(0))
+	assert.Equal(t, 1, retryAfterSeconds(100*time.Millisecond))
+	assert.Equal(t, 2, retryAfterSeconds(1100*time.Millisecond))
+}
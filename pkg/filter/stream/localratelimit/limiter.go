@@ -0,0 +1,184 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+)
+
+var (
+	errInvalidFillInterval  = errors.New("fill_interval should be greater than 0")
+	errInvalidMaxTokens     = errors.New("max_tokens should be greater than 0")
+	errInvalidTokensPerFill = errors.New("tokens_per_fill should be greater than 0")
+	errInvalidMaxKeys       = errors.New("max_keys should not be negative")
+)
+
+// defaultMaxKeys is the default max number of the buckets of the key header values
+const defaultMaxKeys = 10000
+
+// Config is the token bucket config of the local rate limit
+type Config struct {
+	FillInterval  api.DurationConfig `json:"fill_interval,omitempty"`
+	MaxTokens     int64              `json:"max_tokens,omitempty"`
+	TokensPerFill int64              `json:"tokens_per_fill,omitempty"`
+	// KeyHeader is optional, if it is set, the requests are limited by the buckets
+	// of the header value, the requests without the header share a common bucket.
+	KeyHeader string `json:"key_header,omitempty"`
+	// MaxKeys is the max number of the buckets of the key header values, 10000 by default.
+	// the idle buckets are evicted if it is exceeded, and the requests of the new values
+	// share the common bucket if there is no idle bucket.
+	MaxKeys int64 `json:"max_keys,omitempty"`
+}
+
+// IsEmpty returns true if the token bucket is not configured
+func (c *Config) IsEmpty() bool {
+	return c.FillInterval.Duration == 0 && c.MaxTokens == 0 && c.TokensPerFill == 0
+}
+
+func (c *Config) Validate() error {
+	if c.FillInterval.Duration <= 0 {
+		return errInvalidFillInterval
+	}
+	if c.MaxTokens <= 0 {
+		return errInvalidMaxTokens
+	}
+	if c.TokensPerFill <= 0 {
+		return errInvalidTokensPerFill
+	}
+	if c.MaxKeys < 0 {
+		return errInvalidMaxKeys
+	}
+	return nil
+}
+
+// ParseConfig parses the local rate limit config, the tokens_per_fill is 1 by default
+func ParseConfig(cfg interface{}) (*Config, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conf := &Config{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	if conf.IsEmpty() {
+		return conf, nil
+	}
+	if conf.TokensPerFill == 0 {
+		conf.TokensPerFill = 1
+	}
+	if conf.MaxKeys == 0 {
+		conf.MaxKeys = defaultMaxKeys
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// limiter limits the requests by the token buckets
+type limiter struct {
+	config *Config
+	// shared is the bucket of the requests without key
+	shared  *tokenBucket
+	buckets sync.Map // map[string]*tokenBucket
+	// mux protects the buckets creation and eviction, and the fields below
+	mux  sync.Mutex
+	keys int64
+	// lastEvict is the unix nano time of the last eviction
+	lastEvict int64
+}
+
+func newLimiter(conf *Config) *limiter {
+	return &limiter{
+		config: conf,
+		shared: newTokenBucket(conf.FillInterval.Duration, conf.MaxTokens, conf.TokensPerFill, time.Now().UnixNano()),
+	}
+}
+
+// allow takes a token for the request, if no token left, returns false
+// and the duration to wait for the next token.
+func (l *limiter) allow(headers api.HeaderMap) (bool, time.Duration) {
+	now := time.Now().UnixNano()
+	return l.bucket(headers, now).take(now)
+}
+
+func (l *limiter) bucket(headers api.HeaderMap, now int64) *tokenBucket {
+	if l.config.KeyHeader == "" || headers == nil {
+		return l.shared
+	}
+	key, ok := headers.Get(l.config.KeyHeader)
+	if !ok || key == "" {
+		return l.shared
+	}
+	if b, ok := l.buckets.Load(key); ok {
+		return b.(*tokenBucket)
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if b, ok := l.buckets.Load(key); ok {
+		return b.(*tokenBucket)
+	}
+	if l.keys >= l.config.MaxKeys {
+		l.evictIdle(now)
+		if l.keys >= l.config.MaxKeys {
+			return l.shared
+		}
+	}
+	b := newTokenBucket(l.config.FillInterval.Duration, l.config.MaxTokens, l.config.TokensPerFill, now)
+	l.buckets.Store(key, b)
+	l.keys++
+	return b
+}
+
+// evictIdle removes the full buckets, which are the same as the new ones.
+// it runs at most once per fill interval, as no bucket becomes full in between.
+func (l *limiter) evictIdle(now int64) {
+	if now-l.lastEvict < int64(l.config.FillInterval.Duration) {
+		return
+	}
+	l.lastEvict = now
+	l.buckets.Range(func(key, value interface{}) bool {
+		b := value.(*tokenBucket)
+		b.refill(now)
+		if b.full() {
+			l.buckets.Delete(key)
+			l.keys--
+		}
+		return true
+	})
+}
+
+// parseRouteConfig parses the per route config into the limiter, it is registered as the per filter config parser,
+// so the limiter is created once when the route is created, and the route owns the buckets of its requests.
+// nil limiter means the route is not limited.
+func parseRouteConfig(cfg interface{}) (interface{}, error) {
+	conf, err := ParseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if conf.IsEmpty() {
+		return (*limiter)(nil), nil
+	}
+	return newLimiter(conf), nil
+}
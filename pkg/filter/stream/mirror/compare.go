@@ -0,0 +1,181 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/pkg/buffer"
+)
+
+const defaultCompareMaxBodyBytes = 4 * 1024
+
+// compareConfig compares the mirror responses with the original response
+type compareConfig struct {
+	// SamplePercent is the percent of the mirrored requests compared, default 100
+	SamplePercent uint32 `json:"sample_percent,omitempty"`
+	// CompareBody compares the hash of the body besides the status code
+	CompareBody bool `json:"compare_body,omitempty"`
+	// MaxBodyBytes is the max bytes of the body hashed, default 4096
+	MaxBodyBytes int `json:"max_body_bytes,omitempty"`
+}
+
+func parseCompareConfig(v interface{}) (*compareConfig, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	c := &compareConfig{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.SamplePercent == 0 || c.SamplePercent > 100 {
+		c.SamplePercent = 100
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = defaultCompareMaxBodyBytes
+	}
+	return c, nil
+}
+
+func (c *compareConfig) sampled() bool {
+	return c.SamplePercent >= 100 || uint32(rand.Intn(100)) < c.SamplePercent
+}
+
+// DiffResult is the result of a mismatched comparison
+type DiffResult struct {
+	ClusterName     string
+	PrimaryStatus   int
+	ShadowStatus    int
+	PrimaryBodyHash uint64 // zero if the body is not compared
+	ShadowBodyHash  uint64
+}
+
+// DiffCallback is called with the result if the mirror response mismatches the original response
+type DiffCallback func(ctx context.Context, result *DiffResult)
+
+var diffCallback DiffCallback
+
+// RegisterDiffCallback sets the callback of the mismatched comparisons.
+// Call this function before mosn service start.
+func RegisterDiffCallback(cb DiffCallback) {
+	diffCallback = cb
+}
+
+// response is the part of a response compared
+type response struct {
+	status   int
+	bodyHash uint64
+}
+
+// comparison compares the mirror responses of a request with the original response,
+// the one arrives later does the comparison, so the original response is never delayed.
+type comparison struct {
+	config      *compareConfig
+	ctx         context.Context
+	clusterName string
+	stats       *stats
+
+	mutex   sync.Mutex
+	primary *response
+	// shadows are the mirror responses arrived before the original response
+	shadows []*response
+}
+
+func newComparison(ctx context.Context, config *compareConfig, clusterName string, s *stats) *comparison {
+	return &comparison{
+		config:      config,
+		ctx:         ctx,
+		clusterName: clusterName,
+		stats:       s,
+	}
+}
+
+func (c *comparison) newResponse(ctx context.Context, proto api.ProtocolName, headers api.HeaderMap, data buffer.IoBuffer) *response {
+	r := &response{}
+	if code, err := protocol.MappingHeaderStatusCode(ctx, proto, headers); err == nil {
+		r.status = code
+	}
+	if c.config.CompareBody {
+		var body []byte
+		if data != nil {
+			body = data.Bytes()
+		}
+		if len(body) > c.config.MaxBodyBytes {
+			body = body[:c.config.MaxBodyBytes]
+		}
+		h := fnv.New64a()
+		_, _ = h.Write(body)
+		r.bodyHash = h.Sum64()
+	}
+	return r
+}
+
+func (c *comparison) onPrimary(r *response) {
+	c.mutex.Lock()
+	c.primary = r
+	shadows := c.shadows
+	c.shadows = nil
+	c.mutex.Unlock()
+	for _, shadow := range shadows {
+		c.compare(r, shadow)
+	}
+}
+
+func (c *comparison) onShadow(r *response) {
+	c.mutex.Lock()
+	primary := c.primary
+	if primary == nil {
+		c.shadows = append(c.shadows, r)
+	}
+	c.mutex.Unlock()
+	if primary != nil {
+		c.compare(primary, r)
+	}
+}
+
+func (c *comparison) compare(primary, shadow *response) {
+	if c.stats != nil {
+		c.stats.diffTotal.Inc(1)
+	}
+	if *primary == *shadow {
+		return
+	}
+	if c.stats != nil {
+		c.stats.diffMismatch.Inc(1)
+	}
+	result := &DiffResult{
+		ClusterName:     c.clusterName,
+		PrimaryStatus:   primary.status,
+		ShadowStatus:    shadow.status,
+		PrimaryBodyHash: primary.bodyHash,
+		ShadowBodyHash:  shadow.bodyHash,
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[stream filter] [mirror] response mismatch: %+v", result)
+	}
+	if diffCallback != nil {
+		diffCallback(c.ctx, result)
+	}
+}
@@ -37,120 +37,180 @@ type mirror struct {
 	receiveHandler api.StreamReceiverFilterHandler
 	dp             api.ProtocolName
 	up             api.ProtocolName
-	ctx            context.Context
 	headers        api.HeaderMap
 	data           buffer.IoBuffer
 	trailers       api.HeaderMap
-	clusterName    string
-	cluster        types.ClusterInfo
-	sender         types.StreamSender
-	host           types.Host
+	compare        *compareConfig
+	comparisons    []*comparison
+	sendHandler    api.StreamSenderFilterHandler
+}
+
+// shadow sends the mirrored request to a shadow cluster, each shadow has its own clone
+// of the request, so the shadows do not affect each other.
+type shadow struct {
+	mirror      *mirror
+	ctx         context.Context
+	headers     api.HeaderMap
+	data        buffer.IoBuffer
+	trailers    api.HeaderMap
+	clusterName string
+	cluster     types.ClusterInfo
+	sender      types.StreamSender
+	host        types.Host
+	stats       *stats
+	comparison  *comparison
 }
 
 func (m *mirror) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
 	m.receiveHandler = handler
 }
 
+// mirrorClusters returns the sampled shadow clusters of the mirror policy
+func mirrorClusters(policy api.MirrorPolicy) []string {
+	if mp, ok := policy.(types.MultiMirrorPolicy); ok {
+		return mp.MirrorClusters()
+	}
+	if policy.IsMirror() {
+		return []string{policy.ClusterName()}
+	}
+	return nil
+}
+
 func (m *mirror) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
 
 	if m.receiveHandler.Route() == nil || m.receiveHandler.Route().RouteRule() == nil {
 		return api.StreamFilterContinue
 	}
 
-	mirrorPolicy := m.receiveHandler.Route().RouteRule().Policy().MirrorPolicy()
-	if !mirrorPolicy.IsMirror() {
+	clusters := mirrorClusters(m.receiveHandler.Route().RouteRule().Policy().MirrorPolicy())
+	if len(clusters) == 0 {
 		return api.StreamFilterContinue
 	}
 
-	clusterName := mirrorPolicy.ClusterName()
-
-	utils.GoWithRecover(func() {
-		clusterAdapter := cluster.GetClusterMngAdapterInstance()
-
-		m.ctx = buffer.CleanBufferPoolContext(ctx)
-		if headers != nil {
-			// ! xprotocol should reimplement Clone function, not use default, trans protocol.CommonHeader
-			h := headers.Clone()
-			// nolint
-			if _, ok := h.(protocol.CommonHeader); ok {
-				log.DefaultLogger.Errorf("not support mirror, protocal {%v} must implement Clone function", m.getDownStreamProtocol())
-				return
-			}
-			m.headers = h
+	// clone the request before the original one is sent, the buffers may be drained by the upstream
+	if headers != nil {
+		// ! xprotocol should reimplement Clone function, not use default, trans protocol.CommonHeader
+		h := headers.Clone()
+		// nolint
+		if _, ok := h.(protocol.CommonHeader); ok {
+			log.DefaultLogger.Errorf("not support mirror, protocal {%v} must implement Clone function", m.getDownStreamProtocol(ctx))
+			return api.StreamFilterContinue
 		}
-		if buf != nil {
-			m.data = buf.Clone()
-		}
-		if trailers != nil {
-			m.trailers = trailers.Clone()
+		m.headers = h
+	}
+	if buf != nil {
+		m.data = buf.Clone()
+	}
+	if trailers != nil {
+		m.trailers = trailers.Clone()
+	}
+	m.dp, m.up = m.getProtocol(ctx)
+
+	for _, clusterName := range clusters {
+		s := m.newShadow(ctx, clusterName)
+		if m.compare != nil && m.compare.sampled() {
+			s.comparison = newComparison(s.ctx, m.compare, clusterName, getStats(clusterName))
+			m.comparisons = append(m.comparisons, s.comparison)
 		}
+		utils.GoWithRecover(s.send, nil)
+	}
+	if m.broadcast {
+		m.receiveHandler.SendHijackReply(api.SuccessCode, m.headers)
+		return api.StreamFilterStop
+	}
+	return api.StreamFilterContinue
+}
 
-		m.dp, m.up = m.getProtocol()
+// newShadow creates a shadow with the clone of the buffered request
+func (m *mirror) newShadow(ctx context.Context, clusterName string) *shadow {
+	s := &shadow{
+		mirror:      m,
+		ctx:         newMirrorContext(ctx),
+		clusterName: clusterName,
+	}
+	if m.headers != nil {
+		s.headers = m.headers.Clone()
+	}
+	if m.data != nil {
+		s.data = m.data.Clone()
+	}
+	if m.trailers != nil {
+		s.trailers = m.trailers.Clone()
+	}
+	return s
+}
 
-		snap := clusterAdapter.GetClusterSnapshot(ctx, clusterName)
-		if snap == nil {
-			log.DefaultLogger.Errorf("mirror cluster {%s} not found", clusterName)
-			return
-		}
-		m.cluster = snap.ClusterInfo()
-		m.clusterName = clusterName
-
-		amplification := m.amplification
-		if m.broadcast {
-			amplification = 0
-			snap.HostSet().Range(func(host types.Host) bool {
-				if host.Health() {
-					amplification++
-				}
-				return true
-			})
-		}
+func (s *shadow) send() {
+	clusterAdapter := cluster.GetClusterMngAdapterInstance()
 
-		for i := 0; i < amplification; i++ {
-			connPool, host := clusterAdapter.ConnPoolForCluster(m, snap, m.up)
-			if connPool == nil {
-				if log.DefaultLogger.GetLogLevel() >= log.INFO {
-					log.DefaultLogger.Infof("mirror get connPool failed, cluster:%s", m.clusterName)
-				}
-				break
-			}
-			var (
-				streamSender types.StreamSender
-				failReason   types.PoolFailureReason
-			)
-
-			if m.up == protocol.HTTP1 {
-				// ! http1 use fake receiver reduce connect
-				_, streamSender, failReason = connPool.NewStream(m.ctx, &receiver{})
-			} else {
-				_, streamSender, failReason = connPool.NewStream(m.ctx, nil)
+	snap := clusterAdapter.GetClusterSnapshot(s.ctx, s.clusterName)
+	if snap == nil {
+		log.DefaultLogger.Errorf("mirror cluster {%s} not found", s.clusterName)
+		return
+	}
+	s.cluster = snap.ClusterInfo()
+	s.stats = getStats(s.clusterName)
+
+	amplification := s.mirror.amplification
+	if s.mirror.broadcast {
+		amplification = 0
+		snap.HostSet().Range(func(host types.Host) bool {
+			if host.Health() {
+				amplification++
 			}
+			return true
+		})
+	}
 
-			if failReason != "" {
-				m.OnFailure(failReason, host)
-				continue
+	for i := 0; i < amplification; i++ {
+		connPool, host := clusterAdapter.ConnPoolForCluster(s, snap, s.mirror.up)
+		if connPool == nil {
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("mirror get connPool failed, cluster:%s", s.clusterName)
 			}
+			break
+		}
+		if s.stats != nil {
+			s.stats.requestTotal.Inc(1)
+		}
 
-			m.OnReady(streamSender, host)
+		// the response of the mirror cluster is discarded, only counted by the receiver
+		r := newReceiver(s.mirror.up, s.stats)
+		r.comparison = s.comparison
+		_, streamSender, failReason := connPool.NewStream(s.ctx, r)
+		if failReason != "" {
+			r.onResult(false)
+			s.OnFailure(failReason, host)
+			continue
 		}
-	}, nil)
-	if m.broadcast {
-		m.receiveHandler.SendHijackReply(api.SuccessCode, m.headers)
-		return api.StreamFilterStop
+		streamSender.GetStream().AddEventListener(r)
+
+		s.OnReady(streamSender, host)
+	}
+}
+
+func (m *mirror) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	m.sendHandler = handler
+}
+
+// OnSend records the original response for the comparison
+func (m *mirror) OnSend(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	for _, c := range m.comparisons {
+		c.onPrimary(c.newResponse(ctx, m.dp, headers, buf))
 	}
 	return api.StreamFilterContinue
 }
 
 func (m *mirror) OnDestroy() {}
 
-func (m *mirror) getProtocol() (dp, up types.ProtocolName) {
-	dp = m.getDownStreamProtocol()
-	up = m.getUpstreamProtocol()
+func (m *mirror) getProtocol(ctx context.Context) (dp, up types.ProtocolName) {
+	dp = m.getDownStreamProtocol(ctx)
+	up = m.getUpstreamProtocol(ctx)
 	return
 }
 
-func (m *mirror) getDownStreamProtocol() (prot types.ProtocolName) {
-	if dpv, err := variable.Get(m.ctx, types.VariableDownStreamProtocol); err == nil {
+func (m *mirror) getDownStreamProtocol(ctx context.Context) (prot types.ProtocolName) {
+	if dpv, err := variable.Get(ctx, types.VariableDownStreamProtocol); err == nil {
 		if dp, ok := dpv.(types.ProtocolName); ok {
 			return dp
 		}
@@ -158,73 +218,102 @@ func (m *mirror) getDownStreamProtocol() (prot types.ProtocolName) {
 	return m.receiveHandler.RequestInfo().Protocol()
 }
 
-func (m *mirror) getUpstreamProtocol() (currentProtocol types.ProtocolName) {
+func (m *mirror) getUpstreamProtocol(ctx context.Context) (currentProtocol types.ProtocolName) {
 	configProtocol := protocol.Auto
 
 	if m.receiveHandler.Route() != nil && m.receiveHandler.Route().RouteRule() != nil && m.receiveHandler.Route().RouteRule().UpstreamProtocol() != "" {
 		configProtocol = types.ProtocolName(m.receiveHandler.Route().RouteRule().UpstreamProtocol())
 	}
 
-	if protov, err := variable.Get(m.ctx, types.VariableUpstreamProtocol); err == nil {
+	if protov, err := variable.Get(ctx, types.VariableUpstreamProtocol); err == nil {
 		if proto, ok := protov.(types.ProtocolName); ok {
 			configProtocol = proto
 		}
 	}
 
+	currentProtocol = configProtocol
 	if configProtocol == protocol.Auto {
-		currentProtocol = m.getDownStreamProtocol()
+		currentProtocol = m.getDownStreamProtocol(ctx)
 	}
 	return currentProtocol
 }
 
-func (m *mirror) MetadataMatchCriteria() api.MetadataMatchCriteria {
+// mirrorStringVariables are the request variables used by the client streams to encode the request
+var mirrorStringVariables = []string{
+	types.VarMethod,
+	types.VarHost,
+	types.VarIstioHeaderHost,
+	types.VarPath,
+	types.VarPathOriginal,
+	types.VarQueryString,
+}
+
+// newMirrorContext creates a context with its own variables for the mirror request,
+// so the mirror response does not overwrite the variables of the original request, such as the status.
+func newMirrorContext(ctx context.Context) context.Context {
+	mctx := variable.NewVariableContext(buffer.CleanBufferPoolContext(ctx))
+	for _, name := range mirrorStringVariables {
+		if v, err := variable.GetString(ctx, name); err == nil {
+			_ = variable.SetString(mctx, name, v)
+		}
+	}
+	if pgc, err := variable.Get(ctx, types.VariableProxyGeneralConfig); err == nil {
+		_ = variable.Set(mctx, types.VariableProxyGeneralConfig, pgc)
+	}
+	if useStream, err := variable.Get(ctx, types.VarHttp2RequestUseStream); err == nil {
+		_ = variable.Set(mctx, types.VarHttp2RequestUseStream, useStream)
+	}
+	return mctx
+}
+
+func (s *shadow) MetadataMatchCriteria() api.MetadataMatchCriteria {
 	return nil
 }
 
-func (m *mirror) DownstreamConnection() net.Conn {
-	return m.receiveHandler.Connection().RawConn()
+func (s *shadow) DownstreamConnection() net.Conn {
+	return s.mirror.receiveHandler.Connection().RawConn()
 }
 
-func (m *mirror) DownstreamHeaders() types.HeaderMap {
-	return m.headers
+func (s *shadow) DownstreamHeaders() types.HeaderMap {
+	return s.headers
 }
 
-func (m *mirror) DownstreamContext() context.Context {
-	return m.ctx
+func (s *shadow) DownstreamContext() context.Context {
+	return s.ctx
 }
 
-func (m *mirror) DownstreamCluster() types.ClusterInfo {
-	return m.cluster
+func (s *shadow) DownstreamCluster() types.ClusterInfo {
+	return s.cluster
 }
 
-func (m *mirror) DownstreamRoute() api.Route {
-	return m.receiveHandler.Route()
+func (s *shadow) DownstreamRoute() api.Route {
+	return s.mirror.receiveHandler.Route()
 }
 
-func (m *mirror) OnFailure(reason types.PoolFailureReason, host types.Host) {}
+func (s *shadow) OnFailure(reason types.PoolFailureReason, host types.Host) {}
 
-func (m *mirror) OnReady(sender types.StreamSender, host types.Host) {
-	m.sender = sender
-	m.host = host
+func (s *shadow) OnReady(sender types.StreamSender, host types.Host) {
+	s.sender = sender
+	s.host = host
 
-	m.sendDataOnce()
+	s.sendDataOnce()
 }
 
-func (m *mirror) sendDataOnce() {
-	endStream := m.data == nil && m.trailers == nil
+func (s *shadow) sendDataOnce() {
+	endStream := s.data == nil && s.trailers == nil
 
-	m.sender.AppendHeaders(m.ctx, m.headers, endStream)
+	s.sender.AppendHeaders(s.ctx, s.headers, endStream)
 
 	if endStream {
 		return
 	}
 
-	endStream = m.trailers == nil
-	m.sender.AppendData(m.ctx, m.data, endStream)
+	endStream = s.trailers == nil
+	s.sender.AppendData(s.ctx, s.data, endStream)
 
 	if endStream {
 		return
 	}
 
-	m.sender.AppendTrailers(m.ctx, m.trailers)
+	s.sender.AppendTrailers(s.ctx, s.trailers)
 }
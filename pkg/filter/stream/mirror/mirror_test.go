@@ -0,0 +1,256 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	_ "mosn.io/mosn/pkg/proxy"
+	_ "mosn.io/mosn/pkg/stream/http"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestNewMirrorContext(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarMethod, "POST")
+	variable.SetString(ctx, types.VarPath, "/mirror")
+	variable.SetString(ctx, types.VarHeaderStatus, "200")
+
+	mctx := newMirrorContext(ctx)
+	if method, err := variable.GetString(mctx, types.VarMethod); err != nil || method != "POST" {
+		t.Fatalf("method is not copied, got: %s, %v", method, err)
+	}
+	if path, err := variable.GetString(mctx, types.VarPath); err != nil || path != "/mirror" {
+		t.Fatalf("path is not copied, got: %s, %v", path, err)
+	}
+	// the mirror response does not affect the original request
+	variable.SetString(mctx, types.VarHeaderStatus, "500")
+	variable.SetString(mctx, types.VarPath, "/changed")
+	if status, _ := variable.GetString(ctx, types.VarHeaderStatus); status != "200" {
+		t.Fatalf("original status is changed to %s", status)
+	}
+	if path, _ := variable.GetString(ctx, types.VarPath); path != "/mirror" {
+		t.Fatalf("original path is changed to %s", path)
+	}
+}
+
+func newTestStats() *stats {
+	return &stats{
+		requestTotal: gometrics.NewCounter(),
+		responseSucc: gometrics.NewCounter(),
+		responseFail: gometrics.NewCounter(),
+		diffTotal:    gometrics.NewCounter(),
+		diffMismatch: gometrics.NewCounter(),
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	s := newTestStats()
+	for _, status := range []string{"200", "404", "500", "503"} {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarHeaderStatus, status)
+		r := newReceiver(protocol.HTTP1, s)
+		r.OnReceive(ctx, nil, nil, nil)
+		// a mirror request is counted only once
+		r.OnResetStream(types.StreamRemoteReset)
+	}
+	r := newReceiver(protocol.HTTP1, s)
+	r.OnDecodeError(context.Background(), errors.New("decode error"), nil)
+	r = newReceiver(protocol.HTTP1, s)
+	r.OnResetStream(types.StreamConnectionFailed)
+	if s.responseSucc.Count() != 2 || s.responseFail.Count() != 4 {
+		t.Fatalf("unexpected stats, success: %d, fail: %d", s.responseSucc.Count(), s.responseFail.Count())
+	}
+	// no stats
+	newReceiver(protocol.HTTP1, nil).OnResetStream(types.StreamConnectionFailed)
+}
+
+func TestNewMirrorConfigCompare(t *testing.T) {
+	factory, err := NewMirrorConfig(map[string]interface{}{
+		"compare": map[string]interface{}{
+			"compare_body": true,
+		},
+	})
+	assert.Nil(t, err)
+	cfg := factory.(*config)
+	assert.Equal(t, &compareConfig{
+		SamplePercent: 100,
+		CompareBody:   true,
+		MaxBodyBytes:  defaultCompareMaxBodyBytes,
+	}, cfg.Compare)
+	assert.True(t, cfg.Compare.sampled())
+
+	factory, err = NewMirrorConfig(map[string]interface{}{
+		"compare": map[string]interface{}{
+			"sample_percent": 10,
+			"max_body_bytes": 16,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(10), factory.(*config).Compare.SamplePercent)
+	assert.Equal(t, 16, factory.(*config).Compare.MaxBodyBytes)
+
+	_, err = NewMirrorConfig(map[string]interface{}{
+		"compare": "invalid",
+	})
+	assert.NotNil(t, err)
+}
+
+func TestComparison(t *testing.T) {
+	var results []*DiffResult
+	RegisterDiffCallback(func(ctx context.Context, result *DiffResult) {
+		results = append(results, result)
+	})
+	defer RegisterDiffCallback(nil)
+
+	cfg, _ := parseCompareConfig(map[string]interface{}{
+		"compare_body":   true,
+		"max_body_bytes": 8,
+	})
+	newResponse := func(c *comparison, status string, body string) *response {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarHeaderStatus, status)
+		return c.newResponse(ctx, protocol.HTTP1, nil, buffer.NewIoBufferString(body))
+	}
+
+	s := newTestStats()
+	// the mirror response arrives after the original response
+	c := newComparison(context.Background(), cfg, "shadow", s)
+	c.onPrimary(newResponse(c, "200", "hello"))
+	c.onShadow(newResponse(c, "200", "hello"))
+	// only the first 8 bytes are hashed
+	c.onShadow(newResponse(c, "200", "hello world"))
+	c.onShadow(newResponse(c, "200", "hello wo"))
+	assert.Equal(t, int64(3), s.diffTotal.Count())
+	assert.Equal(t, int64(1), s.diffMismatch.Count())
+	assert.Len(t, results, 1)
+
+	// the mirror responses arrive before the original response
+	s = newTestStats()
+	results = nil
+	c = newComparison(context.Background(), cfg, "shadow", s)
+	c.onShadow(newResponse(c, "500", "hello"))
+	c.onShadow(newResponse(c, "200", "hello"))
+	assert.Equal(t, int64(0), s.diffTotal.Count())
+	c.onPrimary(newResponse(c, "200", "hello"))
+	assert.Equal(t, int64(2), s.diffTotal.Count())
+	assert.Equal(t, int64(1), s.diffMismatch.Count())
+	assert.Equal(t, []*DiffResult{{
+		ClusterName:     "shadow",
+		PrimaryStatus:   200,
+		ShadowStatus:    500,
+		PrimaryBodyHash: results[0].PrimaryBodyHash,
+		ShadowBodyHash:  results[0].PrimaryBodyHash,
+	}}, results)
+
+	// the body is not compared
+	cfg.CompareBody = false
+	s = newTestStats()
+	c = newComparison(context.Background(), cfg, "shadow", s)
+	c.onPrimary(newResponse(c, "200", "hello"))
+	c.onShadow(newResponse(c, "200", "world"))
+	assert.Equal(t, int64(0), s.diffMismatch.Count())
+}
+
+func TestReceiverCompare(t *testing.T) {
+	cfg, _ := parseCompareConfig(map[string]interface{}{})
+	s := newTestStats()
+	c := newComparison(context.Background(), cfg, "shadow", s)
+	for _, status := range []string{"200", "503"} {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarHeaderStatus, status)
+		r := newReceiver(protocol.HTTP1, s)
+		r.comparison = c
+		r.OnReceive(ctx, nil, nil, nil)
+	}
+	// the failed mirror request is not compared
+	r := newReceiver(protocol.HTTP1, s)
+	r.comparison = c
+	r.OnResetStream(types.StreamConnectionFailed)
+
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarHeaderStatus, "200")
+	m := &mirror{dp: protocol.HTTP1, comparisons: []*comparison{c}}
+	assert.Equal(t, api.StreamFilterContinue, m.OnSend(ctx, nil, nil, nil))
+	assert.Equal(t, int64(2), s.diffTotal.Count())
+	assert.Equal(t, int64(1), s.diffMismatch.Count())
+}
+
+type testMultiMirrorPolicy struct {
+	api.MirrorPolicy
+	clusters []string
+}
+
+func (p *testMultiMirrorPolicy) MirrorClusters() []string {
+	return p.clusters
+}
+
+type testMirrorPolicy struct {
+	api.MirrorPolicy
+	mirror bool
+}
+
+func (p *testMirrorPolicy) IsMirror() bool {
+	return p.mirror
+}
+
+func (p *testMirrorPolicy) ClusterName() string {
+	return "shadow"
+}
+
+func TestMirrorClusters(t *testing.T) {
+	assert.Equal(t, []string{"shadow1", "shadow2"}, mirrorClusters(&testMultiMirrorPolicy{clusters: []string{"shadow1", "shadow2"}}))
+	assert.Equal(t, []string{"shadow"}, mirrorClusters(&testMirrorPolicy{mirror: true}))
+	assert.Nil(t, mirrorClusters(&testMirrorPolicy{}))
+}
+
+func TestNewShadow(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarPath, "/mirror")
+	m := &mirror{
+		headers:  protocol.CommonHeader{"service": "test"},
+		data:     buffer.NewIoBufferString("body"),
+		trailers: protocol.CommonHeader{"trailer": "test"},
+	}
+	s1 := m.newShadow(ctx, "shadow1")
+	s2 := m.newShadow(ctx, "shadow2")
+	assert.Equal(t, "shadow1", s1.clusterName)
+	assert.Equal(t, "shadow2", s2.clusterName)
+	// each shadow has its own clone of the request
+	s1.data.Drain(s1.data.Len())
+	s1.headers.Set("service", "changed")
+	assert.Equal(t, "body", s2.data.String())
+	assert.Equal(t, "body", m.data.String())
+	v, _ := s2.headers.Get("service")
+	assert.Equal(t, "test", v)
+	v, _ = s2.trailers.Get("trailer")
+	assert.Equal(t, "test", v)
+	// the shadows have their own variables
+	variable.SetString(s1.ctx, types.VarPath, "/changed")
+	path, _ := variable.GetString(s2.ctx, types.VarPath)
+	assert.Equal(t, "/mirror", path)
+}
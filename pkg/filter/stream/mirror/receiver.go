@@ -19,17 +19,57 @@ package mirror
 
 import (
 	"context"
+	"net/http"
+	"sync/atomic"
 
 	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 )
 
-type receiver struct{}
+// receiver discards the response of the mirror request, only the result is counted,
+// and compared with the original response if the comparison is sampled.
+type receiver struct {
+	protocol   types.ProtocolName
+	stats      *stats
+	comparison *comparison
+	// done makes sure a mirror request is counted only once
+	done uint32
+}
+
+func newReceiver(proto types.ProtocolName, s *stats) *receiver {
+	return &receiver{
+		protocol: proto,
+		stats:    s,
+	}
+}
 
 func (r *receiver) OnReceive(ctx context.Context, headers api.HeaderMap, data buffer.IoBuffer, trailers api.HeaderMap) {
-	// http1 close client
+	code, err := protocol.MappingHeaderStatusCode(ctx, r.protocol, headers)
+	r.onResult(err == nil && code < http.StatusInternalServerError)
+	if r.comparison != nil {
+		r.comparison.onShadow(r.comparison.newResponse(ctx, r.protocol, headers, data))
+	}
 }
 
 func (r *receiver) OnDecodeError(ctx context.Context, err error, headers api.HeaderMap) {
+	r.onResult(false)
+}
+
+func (r *receiver) OnResetStream(reason types.StreamResetReason) {
+	r.onResult(false)
+}
+
+func (r *receiver) OnDestroyStream() {}
 
+func (r *receiver) onResult(success bool) {
+	if r.stats == nil || !atomic.CompareAndSwapUint32(&r.done, 0, 1) {
+		return
+	}
+	if success {
+		r.stats.responseSucc.Inc(1)
+	} else {
+		r.stats.responseFail.Inc(1)
+	}
 }
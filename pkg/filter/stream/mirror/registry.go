@@ -28,6 +28,7 @@ var (
 	defaultAmplification = 1
 	amplificationKey     = "amplification"
 	broadcastKey         = "broadcast"
+	compareKey           = "compare"
 )
 
 func init() {
@@ -47,12 +48,20 @@ func NewMirrorConfig(conf map[string]interface{}) (api.StreamFilterChainFactory,
 	if broadcast, ok := conf[broadcastKey]; ok {
 		c.BroadCast = broadcast.(bool)
 	}
+	if compare, ok := conf[compareKey]; ok {
+		cc, err := parseCompareConfig(compare)
+		if err != nil {
+			return nil, err
+		}
+		c.Compare = cc
+	}
 	return c, nil
 }
 
 type config struct {
-	Amplification int  `json:"amplification,omitempty"`
-	BroadCast     bool `json:"broadcast,omitempty"`
+	Amplification int            `json:"amplification,omitempty"`
+	BroadCast     bool           `json:"broadcast,omitempty"`
+	Compare       *compareConfig `json:"compare,omitempty"`
 }
 
 func (c *config) CreateFilterChain(ctx context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
@@ -61,4 +70,9 @@ func (c *config) CreateFilterChain(ctx context.Context, callbacks api.StreamFilt
 		broadcast:     c.BroadCast,
 	}
 	callbacks.AddStreamReceiverFilter(m, api.AfterRoute)
+	// the original response is needed by the comparison, the broadcast has no original response
+	if c.Compare != nil && !c.BroadCast {
+		m.compare = c.Compare
+		callbacks.AddStreamSenderFilter(m, api.BeforeSend)
+	}
 }
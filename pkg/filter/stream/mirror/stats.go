@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+var (
+	requestTotal = "request_total"
+	responseSucc = "response_succ_total"
+	responseFail = "response_fail_total"
+	diffTotal    = "diff_total"
+	diffMismatch = "diff_mismatch_total"
+	clusterKey   = "cluster"
+	metricPre    = "mirror"
+)
+
+var (
+	statsMux     sync.RWMutex
+	statsFactory = make(map[string]*stats)
+)
+
+// stats records the mirrored requests of a cluster, the responses of
+// the mirror cluster are discarded and only counted here.
+type stats struct {
+	requestTotal gometrics.Counter
+	responseSucc gometrics.Counter
+	responseFail gometrics.Counter
+	// diffTotal and diffMismatch count the comparisons with the original responses
+	diffTotal    gometrics.Counter
+	diffMismatch gometrics.Counter
+}
+
+func getStats(clusterName string) *stats {
+	statsMux.RLock()
+	s, ok := statsFactory[clusterName]
+	statsMux.RUnlock()
+	if ok {
+		return s
+	}
+	statsMux.Lock()
+	defer statsMux.Unlock()
+	if s, ok = statsFactory[clusterName]; ok {
+		return s
+	}
+	labels := map[string]string{
+		clusterKey: clusterName,
+	}
+	mts, err := metrics.NewMetrics(metricPre, labels)
+	if err != nil {
+		log.DefaultLogger.Errorf("create metrics fail: labels:%v, err: %v", labels, err)
+		statsFactory[clusterName] = nil
+		return nil
+	}
+	s = &stats{
+		requestTotal: mts.Counter(requestTotal),
+		responseSucc: mts.Counter(responseSucc),
+		responseFail: mts.Counter(responseFail),
+		diffTotal:    mts.Counter(diffTotal),
+		diffMismatch: mts.Counter(diffMismatch),
+	}
+	statsFactory[clusterName] = s
+	return s
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+)
+
+var errUnknownCode = errors.New("unknown rate limit response code")
+
+// rateLimitResponse is the decision of the rate limit service
+type rateLimitResponse struct {
+	overLimit bool
+	// headers are sent with the rate limited response
+	headers map[string]string
+}
+
+// rateLimitClient calls the rate limit service, an error is returned only if the service is unavailable
+type rateLimitClient interface {
+	shouldRateLimit(ctx context.Context, domain string, descriptors []descriptor) (*rateLimitResponse, error)
+}
+
+// grpcRateLimitClient calls the envoy.service.ratelimit.v3.RateLimitService
+type grpcRateLimitClient struct {
+	client rlsv3.RateLimitServiceClient
+}
+
+func newGrpcRateLimitClient(address string) (*grpcRateLimitClient, error) {
+	// the connection is established in the background
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("dial rate limit service %s failed: %v", address, err)
+	}
+	return &grpcRateLimitClient{
+		client: rlsv3.NewRateLimitServiceClient(conn),
+	}, nil
+}
+
+func (c *grpcRateLimitClient) shouldRateLimit(ctx context.Context, domain string, descriptors []descriptor) (*rateLimitResponse, error) {
+	req := &rlsv3.RateLimitRequest{
+		Domain:      domain,
+		Descriptors: make([]*ratelimitv3.RateLimitDescriptor, 0, len(descriptors)),
+	}
+	for _, d := range descriptors {
+		entries := make([]*ratelimitv3.RateLimitDescriptor_Entry, 0, len(d))
+		for _, entry := range d {
+			entries = append(entries, &ratelimitv3.RateLimitDescriptor_Entry{
+				Key:   entry.key,
+				Value: entry.value,
+			})
+		}
+		req.Descriptors = append(req.Descriptors, &ratelimitv3.RateLimitDescriptor{
+			Entries: entries,
+		})
+	}
+	resp, err := c.client.ShouldRateLimit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.GetOverallCode() {
+	case rlsv3.RateLimitResponse_OK:
+		return &rateLimitResponse{}, nil
+	case rlsv3.RateLimitResponse_OVER_LIMIT:
+		result := &rateLimitResponse{
+			overLimit: true,
+			headers:   map[string]string{},
+		}
+		for _, h := range resp.GetResponseHeadersToAdd() {
+			result.headers[h.GetKey()] = h.GetValue()
+		}
+		return result, nil
+	default:
+		return nil, errUnknownCode
+	}
+}
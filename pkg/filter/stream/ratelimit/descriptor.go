@@ -0,0 +1,163 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+const (
+	remoteAddressKey = "remote_address"
+	genericKey       = "generic_key"
+)
+
+var (
+	errNoAction       = errors.New("the actions of the descriptor are required")
+	errInvalidAction  = errors.New("one of request_header, remote_address and generic_key is required in the action")
+	errMultipleAction = errors.New("only one of request_header, remote_address and generic_key can be set in the action")
+	errInvalidHeader  = errors.New("the header_name and the descriptor_key of request_header are required")
+	errNoGenericValue = errors.New("the descriptor_value of generic_key is required")
+)
+
+// descriptorEntry is a key value pair of the descriptor
+type descriptorEntry struct {
+	key   string
+	value string
+}
+
+// descriptor is sent to the rate limit service, the service limits the requests with the same entries
+type descriptor []descriptorEntry
+
+// descriptorAction generates an entry of the descriptor, returns false if the action is not matched
+type descriptorAction func(headers api.HeaderMap, info api.RequestInfo) (descriptorEntry, bool)
+
+// descriptorBuilder makes the descriptor from the request by the actions in order
+type descriptorBuilder struct {
+	actions []descriptorAction
+}
+
+func newDescriptorBuilders(cfgs []v2.RateLimitDescriptor) ([]*descriptorBuilder, error) {
+	builders := make([]*descriptorBuilder, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if len(cfg.Actions) == 0 {
+			return nil, errNoAction
+		}
+		b := &descriptorBuilder{
+			actions: make([]descriptorAction, 0, len(cfg.Actions)),
+		}
+		for _, actionCfg := range cfg.Actions {
+			action, err := newDescriptorAction(actionCfg)
+			if err != nil {
+				return nil, err
+			}
+			b.actions = append(b.actions, action)
+		}
+		builders = append(builders, b)
+	}
+	return builders, nil
+}
+
+func newDescriptorAction(cfg v2.RateLimitAction) (descriptorAction, error) {
+	count := 0
+	for _, set := range []bool{cfg.RequestHeader != nil, cfg.RemoteAddress != nil, cfg.GenericKey != nil} {
+		if set {
+			count++
+		}
+	}
+	switch {
+	case count == 0:
+		return nil, errInvalidAction
+	case count > 1:
+		return nil, errMultipleAction
+	}
+	switch {
+	case cfg.RequestHeader != nil:
+		name, key := cfg.RequestHeader.HeaderName, cfg.RequestHeader.DescriptorKey
+		if name == "" || key == "" {
+			return nil, errInvalidHeader
+		}
+		return func(headers api.HeaderMap, _ api.RequestInfo) (descriptorEntry, bool) {
+			if headers == nil {
+				return descriptorEntry{}, false
+			}
+			value, ok := headers.Get(name)
+			return descriptorEntry{key: key, value: value}, ok
+		}, nil
+	case cfg.RemoteAddress != nil:
+		return remoteAddressAction, nil
+	default:
+		if cfg.GenericKey.DescriptorValue == "" {
+			return nil, errNoGenericValue
+		}
+		entry := descriptorEntry{
+			key:   cfg.GenericKey.DescriptorKey,
+			value: cfg.GenericKey.DescriptorValue,
+		}
+		if entry.key == "" {
+			entry.key = genericKey
+		}
+		return func(api.HeaderMap, api.RequestInfo) (descriptorEntry, bool) {
+			return entry, true
+		}, nil
+	}
+}
+
+// remoteAddressAction generates the entry with the downstream ip
+func remoteAddressAction(_ api.HeaderMap, info api.RequestInfo) (descriptorEntry, bool) {
+	if info == nil || info.DownstreamRemoteAddress() == nil {
+		return descriptorEntry{}, false
+	}
+	addr := info.DownstreamRemoteAddress().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return descriptorEntry{key: remoteAddressKey, value: addr}, true
+}
+
+// build returns false if any of the actions is not matched
+func (b *descriptorBuilder) build(headers api.HeaderMap, info api.RequestInfo) (descriptor, bool) {
+	d := make(descriptor, 0, len(b.actions))
+	for _, action := range b.actions {
+		entry, ok := action(headers, info)
+		if !ok {
+			return nil, false
+		}
+		d = append(d, entry)
+	}
+	return d, true
+}
+
+// parseRouteDescriptors parses the descriptor builders of the route config,
+// it is registered as the per filter config parser, so the route config is parsed once when the route is created.
+func parseRouteDescriptors(cfg interface{}) (interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conf := &v2.RateLimitRouteConfig{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	return newDescriptorBuilders(conf.Descriptors)
+}
@@ -0,0 +1,117 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+)
+
+// defaultTimeout is the default timeout of the rate limit call
+const defaultTimeout = 100 * time.Millisecond
+
+var (
+	errNoAddress = errors.New("the address of the rate limit service is required")
+	errNoDomain  = errors.New("the domain of the rate limit service is required")
+)
+
+func init() {
+	api.RegisterStream(v2.RateLimit, CreateRateLimitFilterFactory)
+	router.RegisterPerFilterConfigParser(v2.RateLimit, parseRouteDescriptors)
+}
+
+type FilterConfigFactory struct {
+	config *rateLimitConfig
+}
+
+// CreateFilterChain adds the filter after route, so the descriptors of the route can be composed
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.AfterRoute)
+}
+
+// CreateRateLimitFilterFactory creates the global rate limit filter factory
+func CreateRateLimitFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create rate limit stream filter factory")
+	cfg, err := ParseStreamRateLimitFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeRateLimitConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamRateLimitFilter
+func ParseStreamRateLimitFilter(cfg map[string]interface{}) (*v2.StreamRateLimit, error) {
+	filterConfig := &v2.StreamRateLimit{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// rateLimitConfig is parsed from v2.StreamRateLimit, the client is shared by the filters created by the factory
+type rateLimitConfig struct {
+	client          rateLimitClient
+	domain          string
+	timeout         time.Duration
+	failureModeDeny bool
+	descriptors     []*descriptorBuilder
+}
+
+func makeRateLimitConfig(cfg *v2.StreamRateLimit) (*rateLimitConfig, error) {
+	if cfg.Address == "" {
+		return nil, errNoAddress
+	}
+	if cfg.Domain == "" {
+		return nil, errNoDomain
+	}
+	descriptors, err := newDescriptorBuilders(cfg.Descriptors)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newGrpcRateLimitClient(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	config := &rateLimitConfig{
+		client:          client,
+		domain:          cfg.Domain,
+		timeout:         cfg.Timeout.Duration,
+		failureModeDeny: cfg.FailureModeDeny,
+		descriptors:     descriptors,
+	}
+	if config.timeout <= 0 {
+		config.timeout = defaultTimeout
+	}
+	return config, nil
+}
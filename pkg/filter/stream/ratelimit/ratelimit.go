@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+)
+
+// rateLimitFilter asks the rate limit service whether the request is over limit, and rejects it with 429.
+// the request waits for the decision, the call is limited by the configured timeout.
+type rateLimitFilter struct {
+	ctx     context.Context
+	config  *rateLimitConfig
+	handler api.StreamReceiverFilterHandler
+}
+
+func NewStreamFilter(ctx context.Context, config *rateLimitConfig) *rateLimitFilter {
+	return &rateLimitFilter{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (f *rateLimitFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *rateLimitFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	descriptors, err := f.makeDescriptors(headers)
+	if err != nil {
+		return f.onResponse(ctx, headers, nil, err)
+	}
+	if len(descriptors) == 0 {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[stream filter] [rate_limit] no descriptor is matched")
+		}
+		return api.StreamFilterContinue
+	}
+	callCtx, cancel := context.WithTimeout(context.Background(), f.config.timeout)
+	defer cancel()
+	resp, err := f.config.client.shouldRateLimit(callCtx, f.config.domain, descriptors)
+	return f.onResponse(ctx, headers, resp, err)
+}
+
+func (f *rateLimitFilter) OnDestroy() {}
+
+// onResponse applies the decision of the rate limit service, returns api.StreamFilterStop if the request is rejected
+func (f *rateLimitFilter) onResponse(ctx context.Context, headers api.HeaderMap, resp *rateLimitResponse, err error) api.StreamFilterStatus {
+	if err != nil {
+		if !f.config.failureModeDeny {
+			log.Proxy.Warnf(ctx, "[stream filter] [rate_limit] call rate limit service failed, allow the request: %v", err)
+			return api.StreamFilterContinue
+		}
+		log.Proxy.Errorf(ctx, "[stream filter] [rate_limit] call rate limit service failed, reject the request: %v", err)
+		f.handler.SendHijackReply(http.StatusInternalServerError, headers)
+		return api.StreamFilterStop
+	}
+	if !resp.overLimit {
+		return api.StreamFilterContinue
+	}
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(ctx, "[stream filter] [rate_limit] request is rate limited by the rate limit service")
+	}
+	f.handler.RequestInfo().SetResponseFlag(api.RateLimited)
+	for k, v := range resp.headers {
+		headers.Set(k, v)
+	}
+	f.handler.SendHijackReply(http.StatusTooManyRequests, headers)
+	return api.StreamFilterStop
+}
+
+// makeDescriptors composes the descriptors of the filter config and the route
+func (f *rateLimitFilter) makeDescriptors(headers api.HeaderMap) ([]descriptor, error) {
+	builders := f.config.descriptors
+	if route := f.handler.Route(); route != nil && route.RouteRule() != nil {
+		cfg, ok, err := router.ParsedPerFilterConfig(route.RouteRule(), v2.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			routeBuilders := cfg.([]*descriptorBuilder)
+			builders = append(builders[:len(builders):len(builders)], routeBuilders...)
+		}
+	}
+	info := f.handler.RequestInfo()
+	descriptors := make([]descriptor, 0, len(builders))
+	for _, b := range builders {
+		if d, ok := b.build(headers, info); ok {
+			descriptors = append(descriptors, d)
+		}
+	}
+	return descriptors, nil
+}
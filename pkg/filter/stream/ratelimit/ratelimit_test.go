@@ -0,0 +1,368 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"mosn.io/api"
+
+	"mosn.io/mosn/pkg/protocol"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	route         api.Route
+	info          *mockRequestInfo
+	hijackCode    int
+	hijackHeaders api.HeaderMap
+}
+
+func newMockReceiveHandler(route api.Route) *mockReceiveHandler {
+	return &mockReceiveHandler{
+		route: route,
+		info: &mockRequestInfo{
+			remote: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345},
+		},
+	}
+}
+
+func (h *mockReceiveHandler) Route() api.Route {
+	return h.route
+}
+
+func (h *mockReceiveHandler) RequestInfo() api.RequestInfo {
+	return h.info
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+	h.hijackHeaders = headers
+}
+
+type mockRequestInfo struct {
+	api.RequestInfo
+	remote net.Addr
+	flag   api.ResponseFlag
+}
+
+func (info *mockRequestInfo) DownstreamRemoteAddress() net.Addr {
+	return info.remote
+}
+
+func (info *mockRequestInfo) SetResponseFlag(flag api.ResponseFlag) {
+	info.flag = flag
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	config map[string]interface{}
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+// mockRateLimitServer rejects the requests of the blocked user, and records the last request
+type mockRateLimitServer struct {
+	mutex sync.Mutex
+	last  *rlsv3.RateLimitRequest
+}
+
+func (s *mockRateLimitServer) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	s.mutex.Lock()
+	s.last = req
+	s.mutex.Unlock()
+	for _, d := range req.GetDescriptors() {
+		for _, entry := range d.GetEntries() {
+			if entry.GetKey() == "user" && entry.GetValue() == "blocked" {
+				return &rlsv3.RateLimitResponse{
+					OverallCode: rlsv3.RateLimitResponse_OVER_LIMIT,
+					ResponseHeadersToAdd: []*corev3.HeaderValue{
+						{Key: "x-ratelimit-limit", Value: "10"},
+					},
+				}, nil
+			}
+		}
+	}
+	return &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+	}, nil
+}
+
+// lastDescriptors returns the descriptors of the last request in a comparable form
+func (s *mockRateLimitServer) lastDescriptors() [][]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var result [][]string
+	for _, d := range s.last.GetDescriptors() {
+		var entries []string
+		for _, entry := range d.GetEntries() {
+			entries = append(entries, entry.GetKey()+"="+entry.GetValue())
+		}
+		result = append(result, entries)
+	}
+	return result
+}
+
+func startRateLimitServer(t *testing.T) (*mockRateLimitServer, string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := grpc.NewServer()
+	mock := &mockRateLimitServer{}
+	rlsv3.RegisterRateLimitServiceServer(server, mock)
+	go server.Serve(ln)
+	return mock, ln.Addr().String(), server.Stop
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}) *rateLimitFilter {
+	factory, err := CreateRateLimitFilterFactory(conf)
+	require.Nil(t, err)
+	return NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+}
+
+// receive runs the filter, the stream stops only if the request is rejected
+func receive(t *testing.T, f *rateLimitFilter, handler *mockReceiveHandler, headers api.HeaderMap) {
+	f.SetReceiveFilterHandler(handler)
+	status := f.OnReceive(context.Background(), headers, nil, nil)
+	if handler.hijackCode != 0 {
+		require.Equal(t, api.StreamFilterStop, status)
+	} else {
+		require.Equal(t, api.StreamFilterContinue, status)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server, address, stop := startRateLimitServer(t)
+	defer stop()
+	conf := map[string]interface{}{
+		"address": address,
+		"domain":  "mosn",
+		// waits for the grpc connection is established
+		"timeout": "1s",
+		"descriptors": []interface{}{
+			map[string]interface{}{
+				"actions": []interface{}{
+					map[string]interface{}{
+						"generic_key": map[string]interface{}{"descriptor_value": "api"},
+					},
+					map[string]interface{}{
+						"request_header": map[string]interface{}{"header_name": "x-user", "descriptor_key": "user"},
+					},
+				},
+			},
+			map[string]interface{}{
+				"actions": []interface{}{
+					map[string]interface{}{"remote_address": map[string]interface{}{}},
+				},
+			},
+		},
+	}
+	route := &mockRoute{
+		rule: &mockRouteRule{
+			config: map[string]interface{}{
+				"rate_limit": map[string]interface{}{
+					"descriptors": []interface{}{
+						map[string]interface{}{
+							"actions": []interface{}{
+								map[string]interface{}{
+									"generic_key": map[string]interface{}{"descriptor_key": "route", "descriptor_value": "orders"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler(nil)
+		receive(t, f, handler, protocol.CommonHeader{"x-user": "alice"})
+		assert.Equal(t, 0, handler.hijackCode)
+		assert.Equal(t, [][]string{
+			{"generic_key=api", "user=alice"},
+			{"remote_address=10.0.0.1"},
+		}, server.lastDescriptors())
+	})
+
+	t.Run("over limit", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler(nil)
+		receive(t, f, handler, protocol.CommonHeader{"x-user": "blocked"})
+		assert.Equal(t, http.StatusTooManyRequests, handler.hijackCode)
+		assert.Equal(t, api.RateLimited, handler.info.flag)
+		limit, _ := handler.hijackHeaders.Get("x-ratelimit-limit")
+		assert.Equal(t, "10", limit)
+	})
+
+	t.Run("header absent", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler(nil)
+		receive(t, f, handler, protocol.CommonHeader{})
+		assert.Equal(t, 0, handler.hijackCode)
+		// the descriptor is skipped if the header is absent
+		assert.Equal(t, [][]string{{"remote_address=10.0.0.1"}}, server.lastDescriptors())
+	})
+
+	t.Run("route descriptors", func(t *testing.T) {
+		f := newFilter(t, conf)
+		handler := newMockReceiveHandler(route)
+		receive(t, f, handler, protocol.CommonHeader{"x-user": "alice"})
+		assert.Equal(t, 0, handler.hijackCode)
+		assert.Equal(t, [][]string{
+			{"generic_key=api", "user=alice"},
+			{"remote_address=10.0.0.1"},
+			{"route=orders"},
+		}, server.lastDescriptors())
+		// the listener descriptors are not changed by the route
+		assert.Len(t, f.config.descriptors, 2)
+	})
+
+	t.Run("no descriptor", func(t *testing.T) {
+		f := newFilter(t, map[string]interface{}{
+			"address": address,
+			"domain":  "mosn",
+		})
+		handler := newMockReceiveHandler(nil)
+		f.SetReceiveFilterHandler(handler)
+		assert.Equal(t, api.StreamFilterContinue, f.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil))
+	})
+}
+
+func TestRateLimitServiceUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	address := ln.Addr().String()
+	ln.Close()
+	conf := func(deny bool) map[string]interface{} {
+		return map[string]interface{}{
+			"address":           address,
+			"domain":            "mosn",
+			"timeout":           "50ms",
+			"failure_mode_deny": deny,
+			"descriptors": []interface{}{
+				map[string]interface{}{
+					"actions": []interface{}{
+						map[string]interface{}{"remote_address": map[string]interface{}{}},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("fail open", func(t *testing.T) {
+		f := newFilter(t, conf(false))
+		handler := newMockReceiveHandler(nil)
+		receive(t, f, handler, protocol.CommonHeader{})
+		assert.Equal(t, 0, handler.hijackCode)
+	})
+
+	t.Run("fail closed", func(t *testing.T) {
+		f := newFilter(t, conf(true))
+		handler := newMockReceiveHandler(nil)
+		receive(t, f, handler, protocol.CommonHeader{})
+		assert.Equal(t, http.StatusInternalServerError, handler.hijackCode)
+	})
+
+	t.Run("invalid route config", func(t *testing.T) {
+		f := newFilter(t, conf(true))
+		handler := newMockReceiveHandler(&mockRoute{
+			rule: &mockRouteRule{
+				config: map[string]interface{}{
+					"rate_limit": map[string]interface{}{
+						"descriptors": []interface{}{map[string]interface{}{}},
+					},
+				},
+			},
+		})
+		receive(t, f, handler, protocol.CommonHeader{})
+		assert.Equal(t, http.StatusInternalServerError, handler.hijackCode)
+	})
+}
+
+func TestCreateRateLimitFilterFactory(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{},
+		{"address": "127.0.0.1:8081"},
+		{"domain": "mosn"},
+		{
+			"address":     "127.0.0.1:8081",
+			"domain":      "mosn",
+			"descriptors": []interface{}{map[string]interface{}{}},
+		},
+		{
+			"address": "127.0.0.1:8081",
+			"domain":  "mosn",
+			"descriptors": []interface{}{
+				map[string]interface{}{
+					"actions": []interface{}{map[string]interface{}{}},
+				},
+			},
+		},
+		{
+			"address": "127.0.0.1:8081",
+			"domain":  "mosn",
+			"descriptors": []interface{}{
+				map[string]interface{}{
+					"actions": []interface{}{
+						map[string]interface{}{
+							"remote_address": map[string]interface{}{},
+							"generic_key":    map[string]interface{}{"descriptor_value": "api"},
+						},
+					},
+				},
+			},
+		},
+		{
+			"address": "127.0.0.1:8081",
+			"domain":  "mosn",
+			"descriptors": []interface{}{
+				map[string]interface{}{
+					"actions": []interface{}{
+						map[string]interface{}{
+							"request_header": map[string]interface{}{"header_name": "x-user"},
+						},
+					},
+				},
+			},
+		},
+	} {
+		_, err := CreateRateLimitFilterFactory(conf)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+)
+
+// response is a cached response
+type response struct {
+	status  int
+	headers api.HeaderMap
+	body    []byte
+	created time.Time
+	expire  time.Time
+}
+
+// cacheEntry keeps the responses of a cache key, the responses are varied by the
+// values of the request headers listed in the Vary response header.
+type cacheEntry struct {
+	key      string
+	vary     []string
+	variants map[string]*response
+	size     int
+}
+
+// cache is a lru cache bounded by the total body size of the responses
+type cache struct {
+	mux      sync.Mutex
+	maxBytes int
+	size     int
+	lru      *list.List
+	entries  map[string]*list.Element
+}
+
+func newCache(maxBytes int) *cache {
+	return &cache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the unexpired response of the key, variantKey computes the variant key
+// with the vary headers of the cached entry.
+func (c *cache) get(key string, variantKey func(vary []string) string, now time.Time) (*response, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	vk := variantKey(entry.vary)
+	resp, ok := entry.variants[vk]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(resp.expire) {
+		c.removeVariant(elem, vk)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return resp, true
+}
+
+// set stores the response, an entry with different vary headers is replaced.
+func (c *cache) set(key string, vary []string, vk string, resp *response) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(resp.body) > c.maxBytes {
+		return
+	}
+	elem, ok := c.entries[key]
+	if ok && !equalVary(elem.Value.(*cacheEntry).vary, vary) {
+		c.removeElement(elem)
+		ok = false
+	}
+	if !ok {
+		elem = c.lru.PushFront(&cacheEntry{
+			key:      key,
+			vary:     vary,
+			variants: make(map[string]*response),
+		})
+		c.entries[key] = elem
+	}
+	entry := elem.Value.(*cacheEntry)
+	if old, exists := entry.variants[vk]; exists {
+		entry.size -= len(old.body)
+		c.size -= len(old.body)
+	}
+	entry.variants[vk] = resp
+	entry.size += len(resp.body)
+	c.size += len(resp.body)
+	c.lru.MoveToFront(elem)
+	// evicts the least recently used entries
+	for c.size > c.maxBytes {
+		back := c.lru.Back()
+		if back == nil {
+			break
+		}
+		c.removeElement(back)
+	}
+}
+
+func (c *cache) removeVariant(elem *list.Element, vk string) {
+	entry := elem.Value.(*cacheEntry)
+	if resp, ok := entry.variants[vk]; ok {
+		delete(entry.variants, vk)
+		entry.size -= len(resp.body)
+		c.size -= len(resp.body)
+	}
+	if len(entry.variants) == 0 {
+		c.removeElement(elem)
+	}
+}
+
+func (c *cache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+func (c *cache) len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.lru.Len()
+}
+
+func equalVary(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
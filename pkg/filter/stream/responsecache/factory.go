@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+const (
+	defaultMaxObjectBytes = 1 << 20
+	defaultMaxCacheBytes  = 64 << 20
+)
+
+func init() {
+	api.RegisterStream(v2.ResponseCache, CreateResponseCacheFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	config *cacheConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	callbacks.AddStreamSenderFilter(filter, api.BeforeSend)
+}
+
+// CreateResponseCacheFilterFactory creates the response cache filter factory,
+// the cache is shared by the filters created by the factory.
+func CreateResponseCacheFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create response cache stream filter factory")
+	cfg, err := ParseStreamResponseCacheFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{makeCacheConfig(cfg)}, nil
+}
+
+// ParseStreamResponseCacheFilter
+func ParseStreamResponseCacheFilter(cfg map[string]interface{}) (*v2.StreamResponseCache, error) {
+	filterConfig := &v2.StreamResponseCache{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// cacheConfig is parsed from v2.StreamResponseCache
+type cacheConfig struct {
+	keyHeaders     []string
+	maxObjectBytes int
+	maxTTL         int64
+	cache          *cache
+}
+
+func makeCacheConfig(cfg *v2.StreamResponseCache) *cacheConfig {
+	config := &cacheConfig{
+		maxObjectBytes: int(cfg.MaxObjectBytes),
+		maxTTL:         int64(cfg.MaxTTL.Duration.Seconds()),
+	}
+	for _, h := range cfg.KeyHeaders {
+		config.keyHeaders = append(config.keyHeaders, strings.ToLower(h))
+	}
+	if config.maxObjectBytes <= 0 {
+		config.maxObjectBytes = defaultMaxObjectBytes
+	}
+	maxCacheBytes := int(cfg.MaxCacheBytes)
+	if maxCacheBytes <= 0 {
+		maxCacheBytes = defaultMaxCacheBytes
+	}
+	config.cache = newCache(maxCacheBytes)
+	return config
+}
@@ -0,0 +1,255 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package responsecache
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	headerCacheControl  = "Cache-Control"
+	headerVary          = "Vary"
+	headerAge           = "Age"
+	headerAuthorization = "Authorization"
+	headerSetCookie     = "Set-Cookie"
+)
+
+// responseCacheFilter serves the cacheable GET requests from the cache, the response of
+// a missed request is stored by the sender filter if it is cacheable.
+type responseCacheFilter struct {
+	config         *cacheConfig
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+	// key is the cache key of the request, empty means the response should not be stored
+	key            string
+	requestHeaders api.HeaderMap
+}
+
+func NewStreamFilter(ctx context.Context, config *cacheConfig) *responseCacheFilter {
+	return &responseCacheFilter{
+		config: config,
+	}
+}
+
+func (f *responseCacheFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *responseCacheFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if method, _ := variable.GetString(ctx, types.VarMethod); method != http.MethodGet {
+		return api.StreamFilterContinue
+	}
+	// the responses of the authorized requests are private
+	if _, ok := headers.Get(headerAuthorization); ok {
+		return api.StreamFilterContinue
+	}
+	cc, _ := headers.Get(headerCacheControl)
+	directives := parseCacheControl(cc)
+	if _, ok := directives["no-store"]; ok {
+		return api.StreamFilterContinue
+	}
+	key := f.cacheKey(ctx, headers)
+	// no-cache asks for a fresh response, which is still stored
+	if _, ok := directives["no-cache"]; !ok {
+		now := time.Now()
+		resp, hit := f.config.cache.get(key, func(vary []string) string {
+			return variantKey(headers, vary)
+		}, now)
+		if hit {
+			if log.Proxy.GetLogLevel() >= log.DEBUG {
+				log.Proxy.Debugf(ctx, "[stream filter] [response_cache] cache hit: %s", key)
+			}
+			respHeaders := resp.headers.Clone()
+			respHeaders.Set(headerAge, strconv.FormatInt(int64(now.Sub(resp.created).Seconds()), 10))
+			_ = variable.SetString(ctx, types.VarHeaderStatus, strconv.Itoa(resp.status))
+			f.receiveHandler.SendDirectResponse(respHeaders, buffer.NewIoBufferBytes(append([]byte(nil), resp.body...)), nil)
+			return api.StreamFilterStop
+		}
+	}
+	f.key = key
+	f.requestHeaders = headers
+	return api.StreamFilterContinue
+}
+
+func (f *responseCacheFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *responseCacheFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.key == "" {
+		return api.StreamFilterContinue
+	}
+	status, err := variable.GetString(ctx, types.VarHeaderStatus)
+	if err != nil || status != strconv.Itoa(http.StatusOK) {
+		return api.StreamFilterContinue
+	}
+	ttl := f.responseTTL(headers)
+	if ttl <= 0 {
+		return api.StreamFilterContinue
+	}
+	vary, ok := parseVary(headers)
+	if !ok {
+		return api.StreamFilterContinue
+	}
+	var body []byte
+	if buf != nil {
+		if buf.Len() > f.config.maxObjectBytes {
+			return api.StreamFilterContinue
+		}
+		body = append(body, buf.Bytes()...)
+	}
+	now := time.Now()
+	f.config.cache.set(f.key, vary, variantKey(f.requestHeaders, vary), &response{
+		status:  http.StatusOK,
+		headers: headers.Clone(),
+		body:    body,
+		created: now,
+		expire:  now.Add(ttl),
+	})
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [response_cache] cache stored: %s, ttl: %v", f.key, ttl)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *responseCacheFilter) OnDestroy() {}
+
+// cacheKey is composed of the method, host, path, query string and the configured request headers
+func (f *responseCacheFilter) cacheKey(ctx context.Context, headers api.HeaderMap) string {
+	var sb strings.Builder
+	method, _ := variable.GetString(ctx, types.VarMethod)
+	host, _ := variable.GetString(ctx, types.VarHost)
+	path, _ := variable.GetString(ctx, types.VarPath)
+	sb.WriteString(method)
+	sb.WriteByte(' ')
+	sb.WriteString(host)
+	sb.WriteString(path)
+	if query, err := variable.GetString(ctx, types.VarQueryString); err == nil && query != "" {
+		sb.WriteByte('?')
+		sb.WriteString(query)
+	}
+	for _, h := range f.config.keyHeaders {
+		v, _ := headers.Get(h)
+		sb.WriteByte('\n')
+		sb.WriteString(h)
+		sb.WriteByte(':')
+		sb.WriteString(v)
+	}
+	return sb.String()
+}
+
+// responseTTL returns the ttl of the response, zero means the response is not cacheable.
+// the responses setting cookies are private, and s-maxage is preferred since the cache is shared.
+func (f *responseCacheFilter) responseTTL(headers api.HeaderMap) time.Duration {
+	// all the Cache-Control headers are checked, as the directives may be split into multiple headers
+	var cc []string
+	setCookie := false
+	headers.Range(func(key, value string) bool {
+		switch {
+		case strings.EqualFold(key, headerSetCookie):
+			setCookie = true
+			return false
+		case strings.EqualFold(key, headerCacheControl):
+			cc = append(cc, value)
+		}
+		return true
+	})
+	if setCookie {
+		return 0
+	}
+	directives := parseCacheControl(strings.Join(cc, ","))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0
+		}
+	}
+	age, ok := directives["s-maxage"]
+	if !ok {
+		age = directives["max-age"]
+	}
+	seconds, err := strconv.ParseInt(age, 10, 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	if f.config.maxTTL > 0 && seconds > f.config.maxTTL {
+		seconds = f.config.maxTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// parseCacheControl returns the directives of the Cache-Control header, the names are lower cased
+func parseCacheControl(cc string) map[string]string {
+	directives := make(map[string]string)
+	for _, d := range strings.Split(cc, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		var value string
+		if i := strings.IndexByte(d, '='); i >= 0 {
+			value = strings.Trim(strings.TrimSpace(d[i+1:]), `"`)
+			d = strings.TrimSpace(d[:i])
+		}
+		directives[strings.ToLower(d)] = value
+	}
+	return directives
+}
+
+// parseVary returns the sorted lower cased header names of the Vary header,
+// the response is not cacheable if it varies by "*".
+func parseVary(headers api.HeaderMap) ([]string, bool) {
+	v, _ := headers.Get(headerVary)
+	var vary []string
+	for _, name := range strings.Split(v, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "*" {
+			return nil, false
+		}
+		vary = append(vary, name)
+	}
+	sort.Strings(vary)
+	return vary, true
+}
+
+// variantKey is composed of the values of the vary headers in the request
+func variantKey(headers api.HeaderMap, vary []string) string {
+	if len(vary) == 0 {
+		return ""
+	}
+	values := make([]string, 0, len(vary))
+	for _, name := range vary {
+		v, _ := headers.Get(name)
+		values = append(values, v)
+	}
+	return strings.Join(values, "\n")
+}
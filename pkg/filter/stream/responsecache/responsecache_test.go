@@ -0,0 +1,252 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package responsecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/protocol/http"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	headers api.HeaderMap
+	body    buffer.IoBuffer
+}
+
+func (h *mockReceiveHandler) SendDirectResponse(headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) {
+	h.headers = headers
+	h.body = buf
+}
+
+func newConfig(t *testing.T, conf map[string]interface{}) *cacheConfig {
+	factory, err := CreateResponseCacheFilterFactory(conf)
+	require.Nil(t, err)
+	return factory.(*FilterConfigFactory).config
+}
+
+func newRequest(method, path string, headers map[string]string) (context.Context, api.HeaderMap) {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, types.VarMethod, method)
+	_ = variable.SetString(ctx, types.VarHost, "example.com")
+	_ = variable.SetString(ctx, types.VarPath, path)
+	h := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return ctx, h
+}
+
+// request runs the filter with the request, the upstream responds the response if the cache is missed.
+// returns the handler, whose headers are set if the cache is hit.
+func request(t *testing.T, config *cacheConfig, reqHeaders map[string]string, respHeaders map[string]string, body string) *mockReceiveHandler {
+	ctx, headers := newRequest("GET", "/resource", reqHeaders)
+	handler := &mockReceiveHandler{}
+	f := NewStreamFilter(ctx, config)
+	f.SetReceiveFilterHandler(handler)
+	if f.OnReceive(ctx, headers, nil, nil) == api.StreamFilterStop {
+		require.NotNil(t, handler.headers)
+		return handler
+	}
+	_ = variable.SetString(ctx, types.VarHeaderStatus, "200")
+	resp := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	for k, v := range respHeaders {
+		resp.Set(k, v)
+	}
+	assert.Equal(t, api.StreamFilterContinue, f.Append(ctx, resp, buffer.NewIoBufferString(body), nil))
+	return handler
+}
+
+func TestResponseCacheHit(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{})
+	resp := map[string]string{
+		"Cache-Control": "public, max-age=60",
+		"Content-Type":  "text/plain",
+	}
+	// miss
+	handler := request(t, config, nil, resp, "hello")
+	assert.Nil(t, handler.headers)
+	assert.Equal(t, 1, config.cache.len())
+	// hit
+	handler = request(t, config, nil, resp, "")
+	ct, _ := handler.headers.Get("Content-Type")
+	assert.Equal(t, "text/plain", ct)
+	age, ok := handler.headers.Get("Age")
+	assert.True(t, ok)
+	assert.Equal(t, "0", age)
+	assert.Equal(t, "hello", handler.body.String())
+	// the body of the cached response is not drained by the direct response
+	handler.body.Drain(handler.body.Len())
+	handler = request(t, config, nil, resp, "")
+	assert.Equal(t, "hello", handler.body.String())
+}
+
+func TestResponseCacheMiss(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{
+		"max_object_bytes": 4,
+	})
+	for _, tc := range []struct {
+		name        string
+		reqHeaders  map[string]string
+		respHeaders map[string]string
+		body        string
+	}{
+		{"no max-age", nil, map[string]string{"Cache-Control": "public"}, "ok"},
+		{"response no-store", nil, map[string]string{"Cache-Control": "no-store, max-age=60"}, "ok"},
+		{"response no-cache", nil, map[string]string{"Cache-Control": "no-cache, max-age=60"}, "ok"},
+		{"private", nil, map[string]string{"Cache-Control": "private, max-age=60"}, "ok"},
+		{"private fields", nil, map[string]string{"Cache-Control": `max-age=60, Private="Set-Cookie"`}, "ok"},
+		{"set cookie", nil, map[string]string{"Cache-Control": "public, max-age=60", "Set-Cookie": "session=secret"}, "ok"},
+		{"vary all", nil, map[string]string{"Cache-Control": "max-age=60", "Vary": "*"}, "ok"},
+		{"request no-store", map[string]string{"Cache-Control": "no-store"}, map[string]string{"Cache-Control": "max-age=60"}, "ok"},
+		{"authorization", map[string]string{"Authorization": "token"}, map[string]string{"Cache-Control": "max-age=60"}, "ok"},
+		{"max object bytes", nil, map[string]string{"Cache-Control": "max-age=60"}, "hello"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request(t, config, tc.reqHeaders, tc.respHeaders, tc.body)
+			assert.Equal(t, 0, config.cache.len())
+		})
+	}
+
+	// the directives in multiple Cache-Control headers are all checked
+	ctx, headers := newRequest("GET", "/resource", nil)
+	f := NewStreamFilter(ctx, config)
+	f.SetReceiveFilterHandler(&mockReceiveHandler{})
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+	_ = variable.SetString(ctx, types.VarHeaderStatus, "200")
+	respHeaders := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	respHeaders.Add("Cache-Control", "max-age=60")
+	respHeaders.Add("Cache-Control", "no-store")
+	assert.Equal(t, api.StreamFilterContinue, f.Append(ctx, respHeaders, buffer.NewIoBufferString("ok"), nil))
+	assert.Equal(t, 0, config.cache.len())
+
+	// the request with no-cache is not served from the cache, but the response is stored
+	resp := map[string]string{"Cache-Control": "max-age=60"}
+	request(t, config, map[string]string{"Cache-Control": "no-cache"}, resp, "ok")
+	assert.Equal(t, 1, config.cache.len())
+	handler := request(t, config, map[string]string{"Cache-Control": "no-cache"}, resp, "ok")
+	assert.Nil(t, handler.headers)
+	handler = request(t, config, nil, resp, "ok")
+	assert.NotNil(t, handler.headers)
+
+	// the non GET request is not cached
+	ctx, headers = newRequest("POST", "/resource", nil)
+	f = NewStreamFilter(ctx, config)
+	f.SetReceiveFilterHandler(&mockReceiveHandler{})
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, headers, nil, nil))
+	assert.Equal(t, "", f.key)
+}
+
+func TestResponseCacheExpire(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{
+		"max_ttl": "1s",
+	})
+	resp := map[string]string{"Cache-Control": "max-age=60"}
+	request(t, config, nil, resp, "hello")
+	handler := request(t, config, nil, resp, "")
+	assert.NotNil(t, handler.headers)
+
+	// the max-age is limited by the max ttl
+	time.Sleep(1100 * time.Millisecond)
+	handler = request(t, config, nil, resp, "world")
+	assert.Nil(t, handler.headers)
+	handler = request(t, config, nil, resp, "")
+	assert.Equal(t, "world", handler.body.String())
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{})
+	resp := map[string]string{
+		"Cache-Control": "max-age=60",
+		"Vary":          "Accept-Encoding, Accept-Language",
+	}
+	gzipEn := map[string]string{"Accept-Encoding": "gzip", "Accept-Language": "en"}
+	gzipZh := map[string]string{"Accept-Encoding": "gzip", "Accept-Language": "zh"}
+	request(t, config, gzipEn, resp, "en")
+	request(t, config, gzipZh, resp, "zh")
+	assert.Equal(t, 1, config.cache.len())
+
+	handler := request(t, config, gzipEn, resp, "")
+	assert.Equal(t, "en", handler.body.String())
+	handler = request(t, config, gzipZh, resp, "")
+	assert.Equal(t, "zh", handler.body.String())
+	handler = request(t, config, map[string]string{"Accept-Language": "en"}, resp, "identity")
+	assert.Nil(t, handler.headers)
+
+	// the response with different vary headers replaces the cached variants
+	request(t, config, map[string]string{"Cache-Control": "no-cache"}, map[string]string{"Cache-Control": "max-age=60"}, "all")
+	handler = request(t, config, gzipZh, resp, "")
+	assert.Equal(t, "all", handler.body.String())
+}
+
+func TestResponseCacheKeyHeaders(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{
+		"key_headers": []string{"X-Tenant"},
+	})
+	resp := map[string]string{"Cache-Control": "max-age=60"}
+	request(t, config, map[string]string{"X-Tenant": "a"}, resp, "a")
+	handler := request(t, config, map[string]string{"X-Tenant": "b"}, resp, "b")
+	assert.Nil(t, handler.headers)
+	assert.Equal(t, 2, config.cache.len())
+	handler = request(t, config, map[string]string{"X-Tenant": "a"}, resp, "")
+	assert.Equal(t, "a", handler.body.String())
+}
+
+func TestCacheEvict(t *testing.T) {
+	c := newCache(10)
+	now := time.Now()
+	newResponse := func(body string) *response {
+		return &response{
+			body:    []byte(body),
+			headers: protocol.CommonHeader{},
+			created: now,
+			expire:  now.Add(time.Minute),
+		}
+	}
+	c.set("a", nil, "", newResponse("aaaa"))
+	c.set("b", nil, "", newResponse("bbbb"))
+	// a is recently used
+	_, ok := c.get("a", func([]string) string { return "" }, now)
+	assert.True(t, ok)
+	c.set("c", nil, "", newResponse("cccc"))
+	_, ok = c.get("b", func([]string) string { return "" }, now)
+	assert.False(t, ok)
+	_, ok = c.get("a", func([]string) string { return "" }, now)
+	assert.True(t, ok)
+	assert.Equal(t, 8, c.size)
+
+	// the response larger than the cache is not stored
+	c.set("d", nil, "", newResponse("ddddddddddd"))
+	assert.Equal(t, 2, c.len())
+
+	// the expired response is removed
+	_, ok = c.get("a", func([]string) string { return "" }, now.Add(time.Hour))
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.len())
+	assert.Equal(t, 4, c.size)
+}
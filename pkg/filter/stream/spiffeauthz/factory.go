@@ -0,0 +1,124 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spiffeauthz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+)
+
+func init() {
+	api.RegisterStream(v2.SpiffeAuthz, CreateSpiffeAuthzFilterFactory)
+	router.RegisterPerFilterConfigParser(v2.SpiffeAuthz, parseRouteConfig)
+}
+
+type FilterConfigFactory struct {
+	config *spiffeAuthzConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	callbacks.AddStreamReceiverFilter(filter, api.AfterRoute)
+}
+
+// CreateSpiffeAuthzFilterFactory creates the spiffe authorization filter factory
+func CreateSpiffeAuthzFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create spiffe authz stream filter factory")
+	cfg, err := ParseStreamSpiffeAuthzFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeSpiffeAuthzConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamSpiffeAuthzFilter
+func ParseStreamSpiffeAuthzFilter(cfg map[string]interface{}) (*v2.StreamSpiffeAuthz, error) {
+	filterConfig := &v2.StreamSpiffeAuthz{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// spiffeAuthzConfig is parsed from v2.StreamSpiffeAuthz
+type spiffeAuthzConfig struct {
+	principals        []string
+	clusterPrincipals map[string][]string
+	allowMissingCert  bool
+}
+
+func makeSpiffeAuthzConfig(cfg *v2.StreamSpiffeAuthz) (*spiffeAuthzConfig, error) {
+	if err := checkPrincipals(cfg.Principals); err != nil {
+		return nil, err
+	}
+	for cluster, principals := range cfg.ClusterPrincipals {
+		if err := checkPrincipals(principals); err != nil {
+			return nil, fmt.Errorf("invalid principals of cluster %s: %v", cluster, err)
+		}
+	}
+	return &spiffeAuthzConfig{
+		principals:        cfg.Principals,
+		clusterPrincipals: cfg.ClusterPrincipals,
+		allowMissingCert:  cfg.AllowMissingCert,
+	}, nil
+}
+
+func checkPrincipals(principals []string) error {
+	for _, p := range principals {
+		if p != "*" && !strings.HasPrefix(p, spiffeScheme+"://") {
+			return fmt.Errorf("principal %s is not a spiffe id", p)
+		}
+	}
+	return nil
+}
+
+// allowed checks whether the spiffe id is allowed to access the cluster
+func (c *spiffeAuthzConfig) allowed(cluster string, id string) bool {
+	principals := c.principals
+	if cp, ok := c.clusterPrincipals[cluster]; ok {
+		principals = cp
+	}
+	for _, p := range principals {
+		switch {
+		case p == "*":
+			return true
+		case strings.HasSuffix(p, "/*"):
+			if strings.HasPrefix(id, p[:len(p)-1]) {
+				return true
+			}
+		case p == id:
+			return true
+		}
+	}
+	return false
+}
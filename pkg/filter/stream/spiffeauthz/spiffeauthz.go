@@ -0,0 +1,177 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spiffeauthz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/types"
+)
+
+const spiffeScheme = "spiffe"
+
+var (
+	errNoSpiffeID        = errors.New("no spiffe id in the client certificate")
+	errMultipleSpiffeIDs = errors.New("multiple spiffe ids in the client certificate")
+)
+
+// tlsConn is implemented by the tls connection, such as mtls.TLSConn
+type tlsConn interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// spiffeAuthzFilter is an implement of StreamReceiverFilter, it authorizes the requests by the spiffe id of the client
+type spiffeAuthzFilter struct {
+	ctx     context.Context
+	handler api.StreamReceiverFilterHandler
+	config  *spiffeAuthzConfig
+}
+
+func NewStreamFilter(ctx context.Context, config *spiffeAuthzConfig) *spiffeAuthzFilter {
+	return &spiffeAuthzFilter{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (f *spiffeAuthzFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *spiffeAuthzFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	config, err := f.routeConfig()
+	if err != nil {
+		f.deny(ctx, headers, "invalid route config: "+err.Error())
+		return api.StreamFilterStop
+	}
+	cert := verifiedPeerCertificate(ctx)
+	if cert == nil {
+		if config.allowMissingCert {
+			return api.StreamFilterContinue
+		}
+		f.deny(ctx, headers, "no verified client certificate")
+		return api.StreamFilterStop
+	}
+	id, err := spiffeID(cert)
+	if err != nil {
+		f.deny(ctx, headers, err.Error())
+		return api.StreamFilterStop
+	}
+	cluster := ""
+	if route := f.handler.Route(); route != nil && route.RouteRule() != nil {
+		cluster = route.RouteRule().ClusterName(ctx)
+	}
+	if !config.allowed(cluster, id) {
+		f.deny(ctx, headers, "spiffe id "+id+" is not allowed to access cluster "+cluster)
+		return api.StreamFilterStop
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *spiffeAuthzFilter) OnDestroy() {}
+
+func (f *spiffeAuthzFilter) deny(ctx context.Context, headers api.HeaderMap, reason string) {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [spiffe authz] deny request: %s", reason)
+	}
+	f.handler.SendHijackReply(http.StatusForbidden, headers)
+}
+
+// routeConfig returns the per route config if the route has one, otherwise the filter config
+func (f *spiffeAuthzFilter) routeConfig() (*spiffeAuthzConfig, error) {
+	route := f.handler.Route()
+	if route == nil || route.RouteRule() == nil {
+		return f.config, nil
+	}
+	cfg, ok, err := router.ParsedPerFilterConfig(route.RouteRule(), v2.SpiffeAuthz)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.config, nil
+	}
+	return cfg.(*spiffeAuthzConfig), nil
+}
+
+// parseRouteConfig parses the per route config, it is registered as the per filter config parser,
+// so the route config is parsed once when the route is created.
+func parseRouteConfig(cfg interface{}) (interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	filterConfig := &v2.StreamSpiffeAuthz{}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return makeSpiffeAuthzConfig(filterConfig)
+}
+
+// verifiedPeerCertificate returns the leaf certificate of the verified chain of the downstream connection.
+// the certificate requested but not verified by the listener, such as require_client_cert without verify_client,
+// is not trusted, and treated as missing.
+func verifiedPeerCertificate(ctx context.Context) *x509.Certificate {
+	cv, err := variable.Get(ctx, types.VariableConnection)
+	if err != nil {
+		return nil
+	}
+	conn, ok := cv.(api.Connection)
+	if !ok || conn == nil {
+		return nil
+	}
+	tc, ok := conn.RawConn().(tlsConn)
+	if !ok {
+		return nil
+	}
+	state := tc.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// spiffeID returns the spiffe id in the uri san of the leaf certificate,
+// a certificate with more than one spiffe id is invalid.
+func spiffeID(cert *x509.Certificate) (string, error) {
+	id := ""
+	for _, uri := range cert.URIs {
+		if uri.Scheme != spiffeScheme {
+			continue
+		}
+		if id != "" {
+			return "", errMultipleSpiffeIDs
+		}
+		id = uri.String()
+	}
+	if id == "" {
+		return "", errNoSpiffeID
+	}
+	return id, nil
+}
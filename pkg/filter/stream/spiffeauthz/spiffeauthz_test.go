@@ -0,0 +1,274 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spiffeauthz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/mtls/certtool"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	route      api.Route
+	hijackCode int
+}
+
+func (h *mockReceiveHandler) Route() api.Route {
+	return h.route
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+type mockRoute struct {
+	api.Route
+	rule *mockRouteRule
+}
+
+func (r *mockRoute) RouteRule() api.RouteRule {
+	return r.rule
+}
+
+type mockRouteRule struct {
+	api.RouteRule
+	cluster string
+	config  map[string]interface{}
+}
+
+func (r *mockRouteRule) ClusterName(ctx context.Context) string {
+	return r.cluster
+}
+
+func (r *mockRouteRule) PerFilterConfig() map[string]interface{} {
+	return r.config
+}
+
+type mockConnection struct {
+	api.Connection
+	rawConn net.Conn
+}
+
+func (c *mockConnection) RawConn() net.Conn {
+	return c.rawConn
+}
+
+type mockTLSConn struct {
+	net.Conn
+	state tls.ConnectionState
+}
+
+func (c *mockTLSConn) ConnectionState() tls.ConnectionState {
+	return c.state
+}
+
+// newClientCert creates a client certificate with the uri sans
+func newClientCert(t *testing.T, uris ...string) *x509.Certificate {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	template, err := certtool.CreateTemplate("client", false, nil)
+	require.Nil(t, err)
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		require.Nil(t, err)
+		template.URIs = append(template.URIs, u)
+	}
+	info, err := certtool.SignCertificate(template, priv)
+	require.Nil(t, err)
+	block, _ := pem.Decode([]byte(info.CertPem))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	return cert
+}
+
+// newContext creates a context with the downstream connection, a nil cert means a tls connection without the client certificate
+func newContext(tlsConn bool, cert *x509.Certificate) context.Context {
+	return newContextWithVerified(tlsConn, cert, true)
+}
+
+// newContextWithVerified creates a context with the downstream connection, the client certificate is verified or not
+func newContextWithVerified(tlsConn bool, cert *x509.Certificate, verified bool) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	conn := &mockConnection{rawConn: &net.TCPConn{}}
+	if tlsConn {
+		state := tls.ConnectionState{HandshakeComplete: true}
+		if cert != nil {
+			state.PeerCertificates = []*x509.Certificate{cert}
+			if verified {
+				state.VerifiedChains = [][]*x509.Certificate{{cert}}
+			}
+		}
+		conn.rawConn = &mockTLSConn{state: state}
+	}
+	_ = variable.Set(ctx, types.VariableConnection, conn)
+	return ctx
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}, route api.Route) (*spiffeAuthzFilter, *mockReceiveHandler) {
+	factory, err := CreateSpiffeAuthzFilterFactory(conf)
+	require.Nil(t, err)
+	f := NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+	handler := &mockReceiveHandler{route: route}
+	f.SetReceiveFilterHandler(handler)
+	return f, handler
+}
+
+func TestCreateSpiffeAuthzFilterFactory(t *testing.T) {
+	_, err := CreateSpiffeAuthzFilterFactory(map[string]interface{}{
+		"principals": []string{"*", "spiffe://example.org/ns/default/sa/client", "spiffe://example.org/ns/prod/*"},
+	})
+	assert.Nil(t, err)
+	_, err = CreateSpiffeAuthzFilterFactory(map[string]interface{}{
+		"principals": []string{"client"},
+	})
+	assert.NotNil(t, err)
+	_, err = CreateSpiffeAuthzFilterFactory(map[string]interface{}{
+		"cluster_principals": map[string][]string{"backend": {"https://example.org/client"}},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestSpiffeAuthz(t *testing.T) {
+	allowed := newClientCert(t, "spiffe://example.org/ns/default/sa/client")
+	prefixed := newClientCert(t, "spiffe://example.org/ns/prod/sa/client")
+	denied := newClientCert(t, "spiffe://example.org/ns/default/sa/other")
+	noSpiffe := newClientCert(t, "https://example.org/client")
+	multiple := newClientCert(t, "spiffe://example.org/ns/default/sa/client", "spiffe://example.org/ns/default/sa/other")
+
+	route := &mockRoute{rule: &mockRouteRule{cluster: "backend"}}
+	conf := map[string]interface{}{
+		"principals": []string{"spiffe://example.org/ns/default/sa/client", "spiffe://example.org/ns/prod/*"},
+	}
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		allowed bool
+	}{
+		{"allowed id", newContext(true, allowed), true},
+		{"allowed prefix", newContext(true, prefixed), true},
+		{"denied id", newContext(true, denied), false},
+		{"no spiffe id", newContext(true, noSpiffe), false},
+		{"multiple spiffe ids", newContext(true, multiple), false},
+		{"no client cert", newContext(true, nil), false},
+		{"unverified client cert", newContextWithVerified(true, allowed, false), false},
+		{"no tls", newContext(false, nil), false},
+	} {
+		f, handler := newFilter(t, conf, route)
+		status := f.OnReceive(tc.ctx, protocol.CommonHeader{}, nil, nil)
+		if tc.allowed {
+			assert.Equal(t, api.StreamFilterContinue, status, tc.name)
+			assert.Equal(t, 0, handler.hijackCode, tc.name)
+		} else {
+			assert.Equal(t, api.StreamFilterStop, status, tc.name)
+			assert.Equal(t, http.StatusForbidden, handler.hijackCode, tc.name)
+		}
+	}
+
+	// the missing client certificate is allowed by the config, but the invalid one is still denied
+	conf["allow_missing_cert"] = true
+	for _, ctx := range []context.Context{newContext(true, nil), newContext(false, nil)} {
+		f, handler := newFilter(t, conf, route)
+		assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, protocol.CommonHeader{}, nil, nil))
+		assert.Equal(t, 0, handler.hijackCode)
+	}
+	f, handler := newFilter(t, conf, route)
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(newContext(true, denied), protocol.CommonHeader{}, nil, nil))
+	assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+
+	// no principal is configured, all the requests are denied
+	f, handler = newFilter(t, map[string]interface{}{}, route)
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(newContext(true, allowed), protocol.CommonHeader{}, nil, nil))
+	assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+}
+
+func TestSpiffeAuthzClusterPrincipals(t *testing.T) {
+	allowed := newClientCert(t, "spiffe://example.org/ns/default/sa/client")
+	admin := newClientCert(t, "spiffe://example.org/ns/default/sa/admin")
+	conf := map[string]interface{}{
+		"principals": []string{"spiffe://example.org/ns/default/sa/client"},
+		"cluster_principals": map[string][]string{
+			"admin": {"spiffe://example.org/ns/default/sa/admin"},
+		},
+	}
+	// the cluster principals take precedence
+	route := &mockRoute{rule: &mockRouteRule{cluster: "admin"}}
+	f, _ := newFilter(t, conf, route)
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(newContext(true, admin), protocol.CommonHeader{}, nil, nil))
+	f, handler := newFilter(t, conf, route)
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(newContext(true, allowed), protocol.CommonHeader{}, nil, nil))
+	assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+	// the other clusters use the principals
+	route = &mockRoute{rule: &mockRouteRule{cluster: "backend"}}
+	f, _ = newFilter(t, conf, route)
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(newContext(true, allowed), protocol.CommonHeader{}, nil, nil))
+	f, _ = newFilter(t, conf, route)
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(newContext(true, admin), protocol.CommonHeader{}, nil, nil))
+}
+
+func TestSpiffeAuthzRouteConfig(t *testing.T) {
+	allowed := newClientCert(t, "spiffe://example.org/ns/default/sa/client")
+	other := newClientCert(t, "spiffe://example.org/ns/default/sa/other")
+	conf := map[string]interface{}{
+		"principals": []string{"spiffe://example.org/ns/default/sa/client"},
+	}
+	// the route allows all the spiffe ids
+	route := &mockRoute{rule: &mockRouteRule{
+		cluster: "backend",
+		config: map[string]interface{}{
+			"spiffe_authz": map[string]interface{}{
+				"principals": []string{"*"},
+			},
+		},
+	}}
+	for _, cert := range []*x509.Certificate{allowed, other} {
+		f, _ := newFilter(t, conf, route)
+		assert.Equal(t, api.StreamFilterContinue, f.OnReceive(newContext(true, cert), protocol.CommonHeader{}, nil, nil))
+	}
+	// the missing client certificate is still denied by the route config
+	f, handler := newFilter(t, conf, route)
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(newContext(true, nil), protocol.CommonHeader{}, nil, nil))
+	assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+
+	// the invalid route config denies all the requests
+	route.rule.config = map[string]interface{}{
+		"spiffe_authz": map[string]interface{}{
+			"principals": []string{"other"},
+		},
+	}
+	for _, cert := range []*x509.Certificate{allowed, other} {
+		f, handler := newFilter(t, conf, route)
+		assert.Equal(t, api.StreamFilterStop, f.OnReceive(newContext(true, cert), protocol.CommonHeader{}, nil, nil))
+		assert.Equal(t, http.StatusForbidden, handler.hijackCode)
+	}
+}
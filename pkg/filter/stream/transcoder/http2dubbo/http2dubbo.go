@@ -0,0 +1,386 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http2dubbo
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/valyala/fasthttp"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/protocol/http"
+	"mosn.io/pkg/variable"
+)
+
+const (
+	defaultDubboVersion = "2.0.2"
+	// request, two way, hessian2 serialization
+	requestFlag     = 0xc2
+	contentTypeJSON = "application/json"
+)
+
+var (
+	errProtocolNotRequired = errors.New("protocol is not the required")
+	errNoMapping           = errors.New("no dubbo mapping for the request path")
+	errInvalidArgs         = errors.New("invalid dubbo arguments")
+)
+
+func init() {
+	transcoder.MustRegister("http2dubbo", NewTranscoder)
+}
+
+// mapping maps a http request path to a dubbo method,
+// the http body is a json array of the method arguments.
+type mapping struct {
+	Path       string   `json:"path"`
+	Service    string   `json:"service"`
+	Method     string   `json:"method"`
+	Version    string   `json:"version,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	ParamTypes []string `json:"param_types,omitempty"`
+}
+
+type config struct {
+	DubboVersion string     `json:"dubbo_version,omitempty"`
+	Mappings     []*mapping `json:"mappings"`
+}
+
+type http2dubbo struct {
+	dubboVersion string
+	mappings     map[string]*mapping
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	conf, err := parseConfig(cfg)
+	if err != nil {
+		log.DefaultLogger.Errorf("[stream filter][transcoder][http2dubbo] parse config failed: %v", err)
+		return nil
+	}
+	t := &http2dubbo{
+		dubboVersion: conf.DubboVersion,
+		mappings:     make(map[string]*mapping, len(conf.Mappings)),
+	}
+	if t.dubboVersion == "" {
+		t.dubboVersion = defaultDubboVersion
+	}
+	for _, m := range conf.Mappings {
+		t.mappings[m.Path] = m
+	}
+	return t
+}
+
+func parseConfig(cfg map[string]interface{}) (*config, error) {
+	conf := &config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	for _, m := range conf.Mappings {
+		if m.Path == "" || m.Service == "" || m.Method == "" {
+			return nil, fmt.Errorf("path, service and method are required, mapping: %+v", m)
+		}
+		for _, typ := range m.ParamTypes {
+			if _, ok := paramDescs[typ]; !ok {
+				return nil, fmt.Errorf("unsupported param type: %s", typ)
+			}
+		}
+	}
+	return conf, nil
+}
+
+// Accept transcodes the http requests that have a dubbo mapping
+func (t *http2dubbo) Accept(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) bool {
+	httpHeader, ok := headers.(http.RequestHeader)
+	if !ok {
+		return false
+	}
+	_, ok = t.mappings[requestPath(httpHeader)]
+	return ok
+}
+
+// TranscodingRequest makes http request to dubbo request
+func (t *http2dubbo) TranscodingRequest(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	httpHeader, ok := headers.(http.RequestHeader)
+	if !ok {
+		return nil, nil, nil, errProtocolNotRequired
+	}
+	m, ok := t.mappings[requestPath(httpHeader)]
+	if !ok {
+		return nil, nil, nil, errNoMapping
+	}
+	args, err := decodeArgs(m, buf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data, err := t.encodeRequest(m, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	frame := dubbo.NewRpcRequest(nil, buffer.NewIoBufferBytes(data))
+	if frame == nil {
+		return nil, nil, nil, errInvalidArgs
+	}
+	// set upstream protocol
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, dubbo.ProtocolName)
+	return frame, frame.GetData(), trailers, nil
+}
+
+// TranscodingResponse makes dubbo response to http response,
+// the dubbo value is encoded as json body and the dubbo exceptions are mapped to http 5xx.
+func (t *http2dubbo) TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok {
+		// if the response is not dubbo response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+	var payload []byte
+	if buf != nil {
+		payload = buf.Bytes()
+	}
+	status, body, err := decodeResponse(frame.Status, payload)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	targetResponse := fasthttp.Response{}
+	targetResponse.SetStatusCode(status)
+	targetResponse.Header.SetContentType(contentTypeJSON)
+	return http.ResponseHeader{ResponseHeader: &targetResponse.Header}, buffer.NewIoBufferBytes(body), trailers, nil
+}
+
+func requestPath(headers http.RequestHeader) string {
+	uri := string(headers.RequestURI())
+	if idx := strings.IndexByte(uri, '?'); idx >= 0 {
+		uri = uri[:idx]
+	}
+	return uri
+}
+
+// paramDescs maps the supported java types to the dubbo parameter descriptors
+var paramDescs = map[string]string{
+	"boolean":           "Z",
+	"int":               "I",
+	"long":              "J",
+	"double":            "D",
+	"java.lang.Boolean": "Ljava/lang/Boolean;",
+	"java.lang.Integer": "Ljava/lang/Integer;",
+	"java.lang.Long":    "Ljava/lang/Long;",
+	"java.lang.Double":  "Ljava/lang/Double;",
+	"java.lang.String":  "Ljava/lang/String;",
+	"java.util.Map":     "Ljava/util/Map;",
+	"java.util.List":    "Ljava/util/List;",
+}
+
+// decodeArgs decodes the json array body to the arguments of the dubbo method
+func decodeArgs(m *mapping, buf types.IoBuffer) ([]interface{}, error) {
+	if len(m.ParamTypes) == 0 {
+		return nil, nil
+	}
+	if buf == nil || buf.Len() == 0 {
+		return nil, fmt.Errorf("%w: empty body", errInvalidArgs)
+	}
+	var values []interface{}
+	if err := json.Unmarshal(buf.Bytes(), &values); err != nil {
+		return nil, fmt.Errorf("%w: body is not a json array: %v", errInvalidArgs, err)
+	}
+	if len(values) != len(m.ParamTypes) {
+		return nil, fmt.Errorf("%w: expect %d arguments, got %d", errInvalidArgs, len(m.ParamTypes), len(values))
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		arg, err := convertArg(m.ParamTypes[i], v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: argument %d: %v", errInvalidArgs, i, err)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+func convertArg(typ string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case "boolean", "java.lang.Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "int", "java.lang.Integer":
+		if f, ok := v.(float64); ok {
+			return int32(f), nil
+		}
+	case "long", "java.lang.Long":
+		if f, ok := v.(float64); ok {
+			return int64(f), nil
+		}
+	case "double", "java.lang.Double":
+		if f, ok := v.(float64); ok {
+			return f, nil
+		}
+	case "java.lang.String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "java.util.Map":
+		if _, ok := v.(map[string]interface{}); ok {
+			return toHessianValue(v), nil
+		}
+	case "java.util.List":
+		if _, ok := v.([]interface{}); ok {
+			return toHessianValue(v), nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not %s", v, typ)
+}
+
+// toHessianValue converts the json object to the map type that hessian supports
+func toHessianValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(value))
+		for k, e := range value {
+			m[k] = toHessianValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(value))
+		for i, e := range value {
+			l[i] = toHessianValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+// toJSONValue converts the hessian decoded value to the value that json supports
+func toJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[fmt.Sprint(k)] = toJSONValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(value))
+		for i, e := range value {
+			l[i] = toJSONValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+// encodeRequest encodes the dubbo request frame, the request id is set by the stream
+func (t *http2dubbo) encodeRequest(m *mapping, args []interface{}) ([]byte, error) {
+	var desc strings.Builder
+	for _, typ := range m.ParamTypes {
+		desc.WriteString(paramDescs[typ])
+	}
+	attachments := map[string]string{
+		"path":      m.Service,
+		"interface": m.Service,
+	}
+	if m.Version != "" {
+		attachments["version"] = m.Version
+	}
+	if m.Group != "" {
+		attachments["group"] = m.Group
+	}
+	encoder := hessian.NewEncoder()
+	fields := append([]interface{}{t.dubboVersion, m.Service, m.Version, m.Method, desc.String()}, args...)
+	for _, field := range append(fields, attachments) {
+		if err := encoder.Encode(field); err != nil {
+			return nil, err
+		}
+	}
+	payload := encoder.Buffer()
+	data := make([]byte, dubbo.HeaderLen, dubbo.HeaderLen+len(payload))
+	copy(data, dubbo.MagicTag)
+	data[dubbo.FlagIdx] = requestFlag
+	binary.BigEndian.PutUint32(data[dubbo.DataLenIdx:], uint32(len(payload)))
+	return append(data, payload...), nil
+}
+
+// decodeResponse decodes the dubbo response payload to the http status and json body
+func decodeResponse(status byte, payload []byte) (int, []byte, error) {
+	decoder := hessian.NewDecoder(payload)
+	if status != dubbo.RespStatusOK {
+		// the payload of the failed response is the error message
+		msg, _ := decoder.Decode()
+		body, err := json.Marshal(map[string]interface{}{
+			"error": fmt.Sprint(msg),
+		})
+		return statusMapping(status), body, err
+	}
+	rspType, err := decoder.Decode()
+	if err != nil {
+		return 0, nil, err
+	}
+	switch rspType {
+	case hessian.RESPONSE_WITH_EXCEPTION, hessian.RESPONSE_WITH_EXCEPTION_WITH_ATTACHMENTS:
+		expt, err := decoder.Decode()
+		if err != nil {
+			return 0, nil, err
+		}
+		msg := fmt.Sprint(expt)
+		if e, ok := expt.(error); ok {
+			msg = e.Error()
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"error": msg,
+		})
+		return nethttp.StatusInternalServerError, body, err
+	case hessian.RESPONSE_VALUE, hessian.RESPONSE_VALUE_WITH_ATTACHMENTS:
+		value, err := decoder.Decode()
+		if err != nil {
+			return 0, nil, err
+		}
+		body, err := json.Marshal(toJSONValue(value))
+		return nethttp.StatusOK, body, err
+	case hessian.RESPONSE_NULL_VALUE, hessian.RESPONSE_NULL_VALUE_WITH_ATTACHMENTS:
+		return nethttp.StatusOK, []byte("null"), nil
+	}
+	return 0, nil, fmt.Errorf("unknown dubbo response type: %v", rspType)
+}
+
+// statusMapping maps the failed dubbo response status to http status
+func statusMapping(status byte) int {
+	switch status {
+	case dubbo.RespStatusClientTimeout, dubbo.RespStatusServerTimeout:
+		return nethttp.StatusGatewayTimeout
+	case dubbo.RespStatusServerThreadpoolExhaustedError:
+		return nethttp.StatusServiceUnavailable
+	default:
+		return nethttp.StatusBadGateway
+	}
+}
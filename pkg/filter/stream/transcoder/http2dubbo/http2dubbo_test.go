@@ -0,0 +1,260 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http2dubbo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	nethttp "net/http"
+	"reflect"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/protocol/http"
+)
+
+func newTestTranscoder(t *testing.T) *http2dubbo {
+	tc := NewTranscoder(map[string]interface{}{
+		"mappings": []interface{}{
+			map[string]interface{}{
+				"path":        "/greeter/hello",
+				"service":     "com.foo.Greeter",
+				"method":      "sayHello",
+				"version":     "1.0.0",
+				"group":       "gray",
+				"param_types": []interface{}{"java.lang.String", "int", "java.util.Map"},
+			},
+			map[string]interface{}{
+				"path":    "/greeter/ping",
+				"service": "com.foo.Greeter",
+				"method":  "ping",
+			},
+		},
+	})
+	if tc == nil {
+		t.Fatal("create transcoder failed")
+	}
+	return tc.(*http2dubbo)
+}
+
+func buildHttpRequestHeaders(uri string) http.RequestHeader {
+	header := &fasthttp.RequestHeader{}
+	header.SetMethod("POST")
+	header.SetRequestURI(uri)
+	return http.RequestHeader{RequestHeader: header}
+}
+
+func TestNewTranscoderInvalidConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"mappings": "invalid"},
+		{"mappings": []interface{}{map[string]interface{}{"path": "/hello"}}},
+		{"mappings": []interface{}{map[string]interface{}{
+			"path":        "/hello",
+			"service":     "com.foo.Greeter",
+			"method":      "sayHello",
+			"param_types": []interface{}{"com.foo.Unknown"},
+		}}},
+	} {
+		if tc := NewTranscoder(cfg); tc != nil {
+			t.Errorf("expect nil transcoder for config: %v", cfg)
+		}
+	}
+}
+
+func TestAccept(t *testing.T) {
+	tc := newTestTranscoder(t)
+	if !tc.Accept(context.Background(), buildHttpRequestHeaders("/greeter/hello?debug=true"), nil, nil) {
+		t.Error("the mapped path should be accepted")
+	}
+	if tc.Accept(context.Background(), buildHttpRequestHeaders("/greeter/unknown"), nil, nil) {
+		t.Error("the unmapped path should not be accepted")
+	}
+	if tc.Accept(context.Background(), &bolt.Request{}, nil, nil) {
+		t.Error("the non http request should not be accepted")
+	}
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	tc := newTestTranscoder(t)
+	headers := buildHttpRequestHeaders("/greeter/hello")
+	body := buffer.NewIoBufferString(`["world", 18, {"city": "hangzhou"}]`)
+	got, buf, _, err := tc.TranscodingRequest(context.Background(), headers, body, nil)
+	if err != nil {
+		t.Fatalf("transcoding request failed: %v", err)
+	}
+	frame, ok := got.(*dubbo.Frame)
+	if !ok {
+		t.Fatalf("expect dubbo frame, got: %T", got)
+	}
+	if frame.GetStreamType() != api.Request || !frame.IsTwoWay {
+		t.Errorf("expect two way dubbo request")
+	}
+	for k, v := range map[string]string{
+		dubbo.ServiceNameHeader: "com.foo.Greeter",
+		dubbo.MethodNameHeader:  "sayHello",
+		dubbo.VersionNameHeader: "1.0.0",
+	} {
+		if got, _ := frame.Get(k); got != v {
+			t.Errorf("unexpected %s header, want: %s, got: %s", k, v, got)
+		}
+	}
+
+	// the payload is the hessian encoded dubbo invocation
+	decoder := hessian.NewDecoder(buf.Bytes())
+	var fields []interface{}
+	for i := 0; i < 9; i++ {
+		field, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("decode field %d failed: %v", i, err)
+		}
+		fields = append(fields, field)
+	}
+	expected := []interface{}{
+		defaultDubboVersion, "com.foo.Greeter", "1.0.0", "sayHello",
+		"Ljava/lang/String;ILjava/util/Map;",
+		"world", int32(18), map[interface{}]interface{}{"city": "hangzhou"},
+	}
+	if !reflect.DeepEqual(fields[:8], expected) {
+		t.Errorf("unexpected dubbo invocation: %v", fields[:8])
+	}
+	attachments, ok := fields[8].(map[interface{}]interface{})
+	if !ok || attachments["group"] != "gray" || attachments["interface"] != "com.foo.Greeter" {
+		t.Errorf("unexpected attachments: %v", fields[8])
+	}
+
+	// the method without arguments ignores the body
+	got, _, _, err = tc.TranscodingRequest(context.Background(), buildHttpRequestHeaders("/greeter/ping"), nil, nil)
+	if err != nil {
+		t.Fatalf("transcoding request failed: %v", err)
+	}
+	if method, _ := got.Get(dubbo.MethodNameHeader); method != "ping" {
+		t.Errorf("unexpected method: %s", method)
+	}
+}
+
+func TestTranscodingRequestInvalidArgs(t *testing.T) {
+	tc := newTestTranscoder(t)
+	for _, body := range []string{
+		"",
+		`{"name": "world"}`,
+		`["world"]`,
+		`[18, 18, {}]`,
+		`["world", 18, "hangzhou"]`,
+	} {
+		_, _, _, err := tc.TranscodingRequest(context.Background(), buildHttpRequestHeaders("/greeter/hello"), buffer.NewIoBufferString(body), nil)
+		if !errors.Is(err, errInvalidArgs) {
+			t.Errorf("expect invalid args error for body %q, got: %v", body, err)
+		}
+	}
+	_, _, _, err := tc.TranscodingRequest(context.Background(), buildHttpRequestHeaders("/greeter/unknown"), nil, nil)
+	if err != errNoMapping {
+		t.Errorf("expect no mapping error, got: %v", err)
+	}
+}
+
+func newResponse(t *testing.T, status byte, fields ...interface{}) *dubbo.Frame {
+	encoder := hessian.NewEncoder()
+	for _, field := range fields {
+		if err := encoder.Encode(field); err != nil {
+			t.Fatalf("encode dubbo response failed: %v", err)
+		}
+	}
+	payload := encoder.Buffer()
+	data := make([]byte, dubbo.HeaderLen, dubbo.HeaderLen+len(payload))
+	copy(data, dubbo.MagicTag)
+	data[dubbo.FlagIdx] = 0x02
+	data[dubbo.StatusIdx] = status
+	binary.BigEndian.PutUint64(data[dubbo.IdIdx:], 1)
+	binary.BigEndian.PutUint32(data[dubbo.DataLenIdx:], uint32(len(payload)))
+	return dubbo.NewRpcResponse(nil, buffer.NewIoBufferBytes(append(data, payload...)))
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	tc := newTestTranscoder(t)
+	testCases := []struct {
+		name   string
+		frame  *dubbo.Frame
+		status int
+		body   string
+	}{
+		{
+			name:   "value",
+			frame:  newResponse(t, hessian.Response_OK, hessian.RESPONSE_VALUE, map[interface{}]interface{}{"greeting": "hello world"}),
+			status: nethttp.StatusOK,
+			body:   `{"greeting":"hello world"}`,
+		},
+		{
+			name:   "null value",
+			frame:  newResponse(t, hessian.Response_OK, hessian.RESPONSE_NULL_VALUE),
+			status: nethttp.StatusOK,
+			body:   `null`,
+		},
+		{
+			name:   "exception",
+			frame:  newResponse(t, hessian.Response_OK, hessian.RESPONSE_WITH_EXCEPTION, "java.lang.IllegalArgumentException: name is empty"),
+			status: nethttp.StatusInternalServerError,
+			body:   `{"error":"java.lang.IllegalArgumentException: name is empty"}`,
+		},
+		{
+			name:   "timeout",
+			frame:  newResponse(t, hessian.Response_SERVER_TIMEOUT, "timeout"),
+			status: nethttp.StatusGatewayTimeout,
+			body:   `{"error":"timeout"}`,
+		},
+		{
+			name:   "service not found",
+			frame:  newResponse(t, hessian.Response_SERVICE_NOT_FOUND, "service not found"),
+			status: nethttp.StatusBadGateway,
+			body:   `{"error":"service not found"}`,
+		},
+	}
+	for _, tc2 := range testCases {
+		t.Run(tc2.name, func(t *testing.T) {
+			got, buf, _, err := tc.TranscodingResponse(context.Background(), tc2.frame, tc2.frame.GetData(), nil)
+			if err != nil {
+				t.Fatalf("transcoding response failed: %v", err)
+			}
+			headers, ok := got.(http.ResponseHeader)
+			if !ok {
+				t.Fatalf("expect http response header, got: %T", got)
+			}
+			if headers.StatusCode() != tc2.status {
+				t.Errorf("unexpected status, want: %d, got: %d", tc2.status, headers.StatusCode())
+			}
+			if ct, _ := headers.Get("Content-Type"); ct != contentTypeJSON {
+				t.Errorf("unexpected content type: %s", ct)
+			}
+			if buf.String() != tc2.body {
+				t.Errorf("unexpected body, want: %s, got: %s", tc2.body, buf.String())
+			}
+		})
+	}
+
+	// the non dubbo response is not transcoded
+	headers := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	body := buffer.NewIoBufferString("hijack")
+	got, buf, _, err := tc.TranscodingResponse(context.Background(), headers, body, nil)
+	if err != nil || !reflect.DeepEqual(got, headers) || buf != body {
+		t.Errorf("the non dubbo response should not be transcoded")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+// defaultMaxBodyBytes limits the transformed body to 4MB by default
+const defaultMaxBodyBytes = 4 * 1024 * 1024
+
+func init() {
+	api.RegisterStream(v2.Transform, CreateTransformFilterFactory)
+}
+
+type FilterConfigFactory struct {
+	config *transformConfig
+}
+
+func (f *FilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter(context, f.config)
+	if f.config.request != nil {
+		callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	}
+	if f.config.response != nil {
+		callbacks.AddStreamSenderFilter(filter, api.BeforeSend)
+	}
+}
+
+// CreateTransformFilterFactory creates the transform filter factory, the transformers must be registered
+// before the filter is configured
+func CreateTransformFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	log.DefaultLogger.Debugf("create transform stream filter factory")
+	cfg, err := ParseStreamTransformFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+	config, err := makeTransformConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &FilterConfigFactory{config}, nil
+}
+
+// ParseStreamTransformFilter
+func ParseStreamTransformFilter(cfg map[string]interface{}) (*v2.StreamTransform, error) {
+	filterConfig := &v2.StreamTransform{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, filterConfig); err != nil {
+		return nil, err
+	}
+	return filterConfig, nil
+}
+
+// transformConfig is parsed from v2.StreamTransform
+type transformConfig struct {
+	request            Transformer
+	response           Transformer
+	maxBodyBytes       uint64
+	passThroughOnError bool
+}
+
+func makeTransformConfig(cfg *v2.StreamTransform) (*transformConfig, error) {
+	config := &transformConfig{
+		maxBodyBytes:       cfg.MaxBodyBytes,
+		passThroughOnError: cfg.PassThroughOnError,
+	}
+	if config.maxBodyBytes == 0 {
+		config.maxBodyBytes = defaultMaxBodyBytes
+	}
+	if cfg.RequestTransformer == "" && cfg.ResponseTransformer == "" {
+		return nil, fmt.Errorf("%w: no transformer is configured", ErrUnknownTransformer)
+	}
+	if name := cfg.RequestTransformer; name != "" {
+		if config.request = GetTransformer(name); config.request == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTransformer, name)
+		}
+	}
+	if name := cfg.ResponseTransformer; name != "" {
+		if config.response = GetTransformer(name); config.response == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTransformer, name)
+		}
+	}
+	return config, nil
+}
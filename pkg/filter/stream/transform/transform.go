@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+const headerContentLength = "Content-Length"
+
+var errTooLarge = errors.New("body is too large to transform")
+
+// transformFilter transforms the request body before it is routed and the response body before it is sent
+type transformFilter struct {
+	config         *transformConfig
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+}
+
+func NewStreamFilter(ctx context.Context, config *transformConfig) *transformFilter {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [transform] create a new transform filter")
+	}
+	return &transformFilter{
+		config: config,
+	}
+}
+
+func (f *transformFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *transformFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *transformFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.config.request == nil {
+		return api.StreamFilterContinue
+	}
+	data, err := f.transform(ctx, f.config.request, headers, buf)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter] [transform] transform request body failed: %v", err)
+		if f.config.passThroughOnError {
+			return api.StreamFilterContinue
+		}
+		f.receiveHandler.SendHijackReply(http.StatusInternalServerError, headers)
+		return api.StreamFilterStop
+	}
+	if data != nil {
+		f.receiveHandler.SetRequestData(data)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *transformFilter) OnSend(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.config.response == nil {
+		return api.StreamFilterContinue
+	}
+	data, err := f.transform(ctx, f.config.response, headers, buf)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter] [transform] transform response body failed: %v", err)
+		if f.config.passThroughOnError {
+			return api.StreamFilterContinue
+		}
+		// the response is replaced by an empty 500 response
+		_ = variable.SetString(ctx, types.VarHeaderStatus, strconv.Itoa(http.StatusInternalServerError))
+		if headers != nil {
+			if _, ok := headers.Get(headerContentLength); ok {
+				headers.Set(headerContentLength, "0")
+			}
+		}
+		f.sendHandler.SetResponseData(buffer.NewIoBuffer(0))
+		return api.StreamFilterContinue
+	}
+	if data != nil {
+		f.sendHandler.SetResponseData(data)
+	}
+	return api.StreamFilterContinue
+}
+
+// transform calls the transformer with the buffered body, and applies the header mutations.
+// returns nil data if the message has no headers.
+func (f *transformFilter) transform(ctx context.Context, transformer Transformer, headers api.HeaderMap, buf buffer.IoBuffer) (data buffer.IoBuffer, err error) {
+	if headers == nil {
+		return nil, nil
+	}
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	if uint64(len(body)) > f.config.maxBodyBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", errTooLarge, len(body), f.config.maxBodyBytes)
+	}
+	// the transformer is user provided, a panic is handled as an error
+	defer func() {
+		if r := recover(); r != nil {
+			data, err = nil, fmt.Errorf("transformer panic: %v", r)
+		}
+	}()
+	result, err := transformer(ctx, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+	for _, key := range result.RemoveHeaders {
+		headers.Del(key)
+	}
+	for key, value := range result.SetHeaders {
+		headers.Set(key, value)
+	}
+	if _, ok := headers.Get(headerContentLength); ok {
+		headers.Set(headerContentLength, strconv.Itoa(len(result.Body)))
+	}
+	return buffer.NewIoBufferBytes(result.Body), nil
+}
+
+// NeedRequestBody implements streamfilter.StreamReceiverFilterBodyBuffering, the request body is transformed as a whole
+func (f *transformFilter) NeedRequestBody(ctx context.Context, headers api.HeaderMap) bool {
+	return f.config.request != nil
+}
+
+func (f *transformFilter) OnDestroy() {}
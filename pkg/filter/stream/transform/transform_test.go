@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	data       buffer.IoBuffer
+	hijackCode int
+}
+
+func (h *mockReceiveHandler) SetRequestData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.hijackCode = code
+}
+
+type mockSendHandler struct {
+	api.StreamSenderFilterHandler
+	data buffer.IoBuffer
+}
+
+func (h *mockSendHandler) SetResponseData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func identityTransformer(ctx context.Context, headers api.HeaderMap, body []byte) (*Result, error) {
+	return &Result{Body: body}, nil
+}
+
+func reverseTransformer(ctx context.Context, headers api.HeaderMap, body []byte) (*Result, error) {
+	reversed := make([]byte, len(body))
+	for i, b := range body {
+		reversed[len(body)-1-i] = b
+	}
+	return &Result{
+		Body:          reversed,
+		SetHeaders:    map[string]string{"X-Transformed": "reverse"},
+		RemoveHeaders: []string{"X-Remove"},
+	}, nil
+}
+
+func errorTransformer(ctx context.Context, headers api.HeaderMap, body []byte) (*Result, error) {
+	return nil, errors.New("transform failed")
+}
+
+func panicTransformer(ctx context.Context, headers api.HeaderMap, body []byte) (*Result, error) {
+	panic("transformer panic")
+}
+
+func init() {
+	RegisterTransformer("test_identity", identityTransformer)
+	RegisterTransformer("test_reverse", reverseTransformer)
+	RegisterTransformer("test_error", errorTransformer)
+	RegisterTransformer("test_panic", panicTransformer)
+}
+
+func newFilter(t *testing.T, conf map[string]interface{}) (*transformFilter, *mockReceiveHandler, *mockSendHandler) {
+	factory, err := CreateTransformFilterFactory(conf)
+	require.Nil(t, err)
+	f := NewStreamFilter(context.Background(), factory.(*FilterConfigFactory).config)
+	receiveHandler := &mockReceiveHandler{}
+	sendHandler := &mockSendHandler{}
+	f.SetReceiveFilterHandler(receiveHandler)
+	f.SetSenderFilterHandler(sendHandler)
+	return f, receiveHandler, sendHandler
+}
+
+func TestRegisterTransformer(t *testing.T) {
+	assert.Equal(t, ErrDuplicateTransformer, RegisterTransformer("test_identity", identityTransformer))
+	assert.NotNil(t, GetTransformer("test_identity"))
+	assert.Nil(t, GetTransformer("unknown"))
+}
+
+func TestCreateTransformFilterFactory(t *testing.T) {
+	factory, err := CreateTransformFilterFactory(map[string]interface{}{
+		"request_transformer": "test_identity",
+	})
+	require.Nil(t, err)
+	config := factory.(*FilterConfigFactory).config
+	assert.NotNil(t, config.request)
+	assert.Nil(t, config.response)
+	assert.Equal(t, uint64(defaultMaxBodyBytes), config.maxBodyBytes)
+	assert.False(t, config.passThroughOnError)
+
+	for _, conf := range []map[string]interface{}{
+		{},
+		{"request_transformer": "unknown"},
+		{"response_transformer": "unknown"},
+	} {
+		_, err := CreateTransformFilterFactory(conf)
+		assert.True(t, errors.Is(err, ErrUnknownTransformer), conf)
+	}
+}
+
+func TestTransformBothDirections(t *testing.T) {
+	for _, tc := range []struct {
+		transformer string
+		body        string
+		expected    string
+		header      string
+	}{
+		{transformer: "test_identity", body: "hello mosn", expected: "hello mosn"},
+		{transformer: "test_reverse", body: "hello mosn", expected: "nsom olleh", header: "reverse"},
+	} {
+		f, receiveHandler, sendHandler := newFilter(t, map[string]interface{}{
+			"request_transformer":  tc.transformer,
+			"response_transformer": tc.transformer,
+		})
+		// request
+		headers := protocol.CommonHeader{"X-Remove": "1", headerContentLength: "10"}
+		status := f.OnReceive(context.Background(), headers, buffer.NewIoBufferString(tc.body), nil)
+		assert.Equal(t, api.StreamFilterContinue, status)
+		require.NotNil(t, receiveHandler.data)
+		assert.Equal(t, tc.expected, receiveHandler.data.String())
+		v, _ := headers.Get("X-Transformed")
+		assert.Equal(t, tc.header, v)
+		// the header is removed by the reverse transformer only
+		_, present := headers.Get("X-Remove")
+		assert.Equal(t, tc.header == "", present)
+		// response
+		headers = protocol.CommonHeader{headerContentLength: "10"}
+		status = f.OnSend(context.Background(), headers, buffer.NewIoBufferString(tc.body), nil)
+		assert.Equal(t, api.StreamFilterContinue, status)
+		require.NotNil(t, sendHandler.data)
+		assert.Equal(t, tc.expected, sendHandler.data.String())
+		v, _ = headers.Get(headerContentLength)
+		assert.Equal(t, strconv.Itoa(len(tc.expected)), v)
+	}
+}
+
+func TestTransformError(t *testing.T) {
+	for _, tc := range []struct {
+		conf map[string]interface{}
+		body string
+	}{
+		{conf: map[string]interface{}{"request_transformer": "test_error", "response_transformer": "test_error"}, body: "body"},
+		{conf: map[string]interface{}{"request_transformer": "test_panic", "response_transformer": "test_panic"}, body: "body"},
+		// the body exceeds the size cap
+		{conf: map[string]interface{}{"request_transformer": "test_reverse", "response_transformer": "test_reverse", "max_body_bytes": 2}, body: "body"},
+	} {
+		// hijack 500
+		f, receiveHandler, sendHandler := newFilter(t, tc.conf)
+		status := f.OnReceive(context.Background(), protocol.CommonHeader{}, buffer.NewIoBufferString(tc.body), nil)
+		assert.Equal(t, api.StreamFilterStop, status)
+		assert.Equal(t, http.StatusInternalServerError, receiveHandler.hijackCode)
+		assert.Nil(t, receiveHandler.data)
+
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.SetString(ctx, types.VarHeaderStatus, "200")
+		status = f.OnSend(ctx, protocol.CommonHeader{}, buffer.NewIoBufferString(tc.body), nil)
+		assert.Equal(t, api.StreamFilterContinue, status)
+		code, _ := variable.GetString(ctx, types.VarHeaderStatus)
+		assert.Equal(t, "500", code)
+		require.NotNil(t, sendHandler.data)
+		assert.Equal(t, 0, sendHandler.data.Len())
+
+		// pass through
+		tc.conf["pass_through_on_error"] = true
+		f, receiveHandler, sendHandler = newFilter(t, tc.conf)
+		status = f.OnReceive(context.Background(), protocol.CommonHeader{}, buffer.NewIoBufferString(tc.body), nil)
+		assert.Equal(t, api.StreamFilterContinue, status)
+		assert.Equal(t, 0, receiveHandler.hijackCode)
+		assert.Nil(t, receiveHandler.data)
+		status = f.OnSend(context.Background(), protocol.CommonHeader{}, buffer.NewIoBufferString(tc.body), nil)
+		assert.Equal(t, api.StreamFilterContinue, status)
+		assert.Nil(t, sendHandler.data)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"mosn.io/api"
+)
+
+var (
+	ErrDuplicateTransformer = errors.New("duplicate transformer")
+	ErrUnknownTransformer   = errors.New("unknown transformer")
+)
+
+// Result is the result of a transformer
+type Result struct {
+	// Body replaces the original body
+	Body []byte
+	// SetHeaders and RemoveHeaders are the header mutations of the transformed message
+	SetHeaders    map[string]string
+	RemoveHeaders []string
+}
+
+// Transformer transforms the buffered body of the request or the response.
+// the headers should not be changed by the transformer, use the header mutations of the result instead.
+type Transformer func(ctx context.Context, headers api.HeaderMap, body []byte) (*Result, error)
+
+var (
+	transformersMutex sync.RWMutex
+	transformers      = map[string]Transformer{}
+)
+
+// RegisterTransformer registers a transformer by name, the name can be used in the transform filter config
+func RegisterTransformer(name string, transformer Transformer) error {
+	transformersMutex.Lock()
+	defer transformersMutex.Unlock()
+	if _, ok := transformers[name]; ok {
+		return ErrDuplicateTransformer
+	}
+	transformers[name] = transformer
+	return nil
+}
+
+// GetTransformer returns the registered transformer, nil means not found
+func GetTransformer(name string) Transformer {
+	transformersMutex.RLock()
+	defer transformersMutex.RUnlock()
+	return transformers[name]
+}
@@ -61,11 +61,46 @@ func EnableAllAccessLog() {
 	}
 }
 
+// LogFormatter formats the access log of a request by the variables in the context
+type LogFormatter interface {
+	Format(ctx context.Context, buf buffer.IoBuffer)
+}
+
+// NamedFormatAccessLog is implemented by the access log which has the named formats
+type NamedFormatAccessLog interface {
+	// LogWithFormat logs by the named format, the default format is used if the name is not found
+	LogWithFormat(name string, ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo)
+}
+
 // types.AccessLog
 type accesslog struct {
-	output  string
+	output    string
+	formatter LogFormatter
+	logger    *log.Logger
+	// formatters is the named formatters
+	formatters map[string]LogFormatter
+}
+
+// textFormatter formats the access log by the text format, such as "%start_time% %duration%"
+type textFormatter struct {
 	entries []*logEntry
-	logger  *log.Logger
+}
+
+// NewTextFormatter creates a text formatter, the default access log format is used if the format is empty
+func NewTextFormatter(format string) (LogFormatter, error) {
+	entries, err := parseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return &textFormatter{
+		entries: entries,
+	}, nil
+}
+
+func (f *textFormatter) Format(ctx context.Context, buf buffer.IoBuffer) {
+	for idx := range f.entries {
+		f.entries[idx].log(ctx, buf)
+	}
 }
 
 type logEntry struct {
@@ -102,22 +137,46 @@ func (le *logEntry) log(ctx context.Context, buf buffer.IoBuffer) {
 	}
 }
 
+// value returns the value of the entry, returns false if the variable is not found
+// or the value is variable.ValueNotFound
+func (le *logEntry) value(ctx context.Context) (string, bool) {
+	if le.text != "" {
+		return le.text, true
+	}
+	value, err := GetVariableValueAsString(ctx, le.name)
+	if err != nil || value == variable.ValueNotFound {
+		return "", false
+	}
+	return value, true
+}
+
 // NewAccessLog
 func NewAccessLog(output string, format string) (api.AccessLog, error) {
-	lg, err := log.GetOrCreateLogger(output, nil)
+	formatter, err := NewTextFormatter(format)
 	if err != nil {
 		return nil, err
 	}
+	return NewAccessLogWithFormatter(output, formatter)
+}
 
-	entries, err := parseFormat(format)
+// NewAccessLogWithFormatter creates an access log which is formatted by the formatter
+func NewAccessLogWithFormatter(output string, formatter LogFormatter) (api.AccessLog, error) {
+	return NewAccessLogWithFormatters(output, formatter, nil)
+}
+
+// NewAccessLogWithFormatters creates an access log which is formatted by the formatter,
+// and the named formatters can be chosen by NamedFormatAccessLog
+func NewAccessLogWithFormatters(output string, formatter LogFormatter, formatters map[string]LogFormatter) (api.AccessLog, error) {
+	lg, err := log.GetOrCreateLogger(output, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	l := &accesslog{
-		output:  output,
-		entries: entries,
-		logger:  lg,
+		output:     output,
+		formatter:  formatter,
+		logger:     lg,
+		formatters: formatters,
 	}
 
 	if DefaultDisableAccessLog {
@@ -135,10 +194,24 @@ func (l *accesslog) Log(ctx context.Context, reqHeaders api.HeaderMap, respHeade
 		return
 	}
 
-	buf := log.GetLogBuffer(AccessLogLen)
-	for idx := range l.entries {
-		l.entries[idx].log(ctx, buf)
+	l.log(ctx, l.formatter)
+}
+
+// LogWithFormat implements NamedFormatAccessLog
+func (l *accesslog) LogWithFormat(name string, ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	if l.logger.Disable() {
+		return
 	}
+	formatter, ok := l.formatters[name]
+	if !ok {
+		formatter = l.formatter
+	}
+	l.log(ctx, formatter)
+}
+
+func (l *accesslog) log(ctx context.Context, formatter LogFormatter) {
+	buf := log.GetLogBuffer(AccessLogLen)
+	formatter.Format(ctx, buf)
 	buf.WriteString("\n")
 	l.logger.Print(buf, true)
 }
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"mosn.io/pkg/buffer"
+)
+
+// JSONFormatter formats the access log as a json object, the value of each key
+// is formatted by the text format, such as {"duration": "%duration%"}.
+type JSONFormatter struct {
+	fields []*jsonField
+	// nullMissing emits null for the keys whose variables are not found,
+	// otherwise the keys are omitted.
+	nullMissing bool
+}
+
+type jsonField struct {
+	// key is the quoted json key
+	key     string
+	entries []*logEntry
+}
+
+// NewJSONFormatter creates a json formatter, the keys are sorted in the log
+func NewJSONFormatter(fields map[string]string, nullMissing bool) (*JSONFormatter, error) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	f := &JSONFormatter{
+		fields:      make([]*jsonField, 0, len(keys)),
+		nullMissing: nullMissing,
+	}
+	for _, key := range keys {
+		if fields[key] == "" {
+			return nil, ErrLogFormatUndefined
+		}
+		entries, err := parseFormat(fields[key])
+		if err != nil {
+			return nil, err
+		}
+		f.fields = append(f.fields, &jsonField{
+			key:     quoteJSON(key),
+			entries: entries,
+		})
+	}
+	return f, nil
+}
+
+func (f *JSONFormatter) Format(ctx context.Context, buf buffer.IoBuffer) {
+	buf.WriteString("{")
+	first := true
+	for _, field := range f.fields {
+		value, ok := field.value(ctx)
+		if !ok && !f.nullMissing {
+			continue
+		}
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+		buf.WriteString(field.key)
+		buf.WriteString(":")
+		if ok {
+			buf.WriteString(quoteJSON(value))
+		} else {
+			buf.WriteString("null")
+		}
+	}
+	buf.WriteString("}")
+}
+
+// value returns the value of the field, returns false if any variable of the field is not found
+func (field *jsonField) value(ctx context.Context) (string, bool) {
+	if len(field.entries) == 1 {
+		return field.entries[0].value(ctx)
+	}
+	value := ""
+	for _, entry := range field.entries {
+		v, ok := entry.value(ctx)
+		if !ok {
+			return "", false
+		}
+		value += v
+	}
+	return value, true
+}
+
+func quoteJSON(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+const (
+	testVarDynamicMetadata = "test_dynamic_metadata"
+	testVarMissingMetadata = "test_missing_metadata"
+)
+
+func registerTestJSONVarDefs() {
+	registerTestVarDefs()
+	variable.Register(variable.NewStringVariable(testVarDynamicMetadata, nil, nil, variable.DefaultStringSetter, 0))
+	variable.Register(variable.NewStringVariable(testVarMissingMetadata, nil, nil, variable.DefaultStringSetter, 0))
+}
+
+func TestJSONAccessLog(t *testing.T) {
+	registerTestJSONVarDefs()
+
+	formatter, err := NewJSONFormatter(map[string]string{
+		"start_time":       "%start_time%",
+		"duration":         "%duration%",
+		"upstream_host":    "%upstream_host%",
+		"response_code":    "%response_code%",
+		"service":          "%request_header_service%",
+		"metadata":         "%" + testVarDynamicMetadata + "%",
+		"missing":          "%" + testVarMissingMetadata + "%",
+		"missing_header":   "%request_header_missing%",
+		"local_address":    "local %upstream_local_address%",
+		"with \"quote\"":   "%response_header_server%",
+		"unknown_variable": "%unknown_variable%",
+	}, false)
+	require.Nil(t, err)
+
+	logName := "/tmp/mosn_bench/test_json_access.log"
+	os.Remove(logName)
+	accessLog, err := NewAccessLogWithFormatter(logName, formatter)
+	require.Nil(t, err)
+
+	ctx := prepareLocalIpv4Ctx()
+	require.Nil(t, variable.SetString(ctx, testVarDynamicMetadata, "dynamic \"value\""))
+	accessLog.Log(ctx, nil, nil, nil)
+	time.Sleep(2 * time.Second)
+
+	b, err := ioutil.ReadFile(logName)
+	require.Nil(t, err)
+	require.True(t, len(b) > 0 && b[len(b)-1] == '\n', "log: %s", string(b))
+
+	fields := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(b, &fields), "log: %s", string(b))
+	assert.NotEmpty(t, fields["start_time"])
+	assert.NotEmpty(t, fields["duration"])
+	assert.Equal(t, "0", fields["response_code"])
+	assert.Equal(t, "test", fields["service"])
+	assert.Equal(t, "dynamic \"value\"", fields["metadata"])
+	assert.Equal(t, "local 127.0.0.1:23456", fields["local_address"])
+	assert.Equal(t, "MOSN", fields["with \"quote\""])
+	assert.Equal(t, UnknownDefaultValue, fields["unknown_variable"])
+	// the missing values are omitted
+	for _, key := range []string{"upstream_host", "missing", "missing_header"} {
+		_, ok := fields[key]
+		assert.False(t, ok, "key: %s", key)
+	}
+}
+
+func TestJSONFormatterNullMissing(t *testing.T) {
+	registerTestJSONVarDefs()
+
+	formatter, err := NewJSONFormatter(map[string]string{
+		"service":     "%request_header_service%",
+		"missing":     "%" + testVarMissingMetadata + "%",
+		"missing_sum": "%request_header_service% %" + testVarMissingMetadata + "%",
+	}, true)
+	require.Nil(t, err)
+
+	buf := buffer.NewIoBuffer(256)
+	formatter.Format(prepareLocalIpv4Ctx(), buf)
+	assert.Equal(t, `{"missing":null,"missing_sum":null,"service":"test"}`, buf.String())
+
+	fields := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &fields))
+	v, ok := fields["missing"]
+	assert.True(t, ok)
+	assert.Nil(t, v)
+}
+
+func TestJSONFormatterInvalid(t *testing.T) {
+	registerTestJSONVarDefs()
+
+	for _, fields := range []map[string]string{
+		{"empty": ""},
+		{"empty_var": "%%"},
+		{"unclosed_var": "%request_header_service"},
+	} {
+		_, err := NewJSONFormatter(fields, false)
+		assert.NotNil(t, err, "fields: %v", fields)
+	}
+}
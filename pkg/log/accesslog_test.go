@@ -791,3 +791,28 @@ func responseHeaderMapGetter(ctx context.Context, value *variable.IndexedValue,
 
 	return headerValue, nil
 }
+
+func TestAccessLogWithNamedFormat(t *testing.T) {
+	registerTestVarDefs()
+
+	formatter, err := NewTextFormatter("default %upstream_local_address%")
+	require.Nil(t, err)
+	short, err := NewTextFormatter("short %upstream_local_address%")
+	require.Nil(t, err)
+	logName := "/tmp/mosn_bench/test_named_format_access.log"
+	os.Remove(logName)
+	accessLog, err := NewAccessLogWithFormatters(logName, formatter, map[string]LogFormatter{
+		"short": short,
+	})
+	require.Nil(t, err)
+	nal, ok := accessLog.(NamedFormatAccessLog)
+	require.True(t, ok)
+
+	ctx := prepareLocalIpv6Ctx()
+	nal.LogWithFormat("short", ctx, nil, nil, nil)
+	nal.LogWithFormat("unknown", ctx, nil, nil, nil)
+	time.Sleep(2 * time.Second)
+	b, err := ioutil.ReadFile(logName)
+	require.Nil(t, err)
+	require.Equal(t, "short 127.0.0.1:23456\ndefault 127.0.0.1:23456\n", string(b))
+}
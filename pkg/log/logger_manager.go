@@ -202,6 +202,13 @@ func UpdateErrorLoggerLevel(p string, level log.Level) bool {
 	return false
 }
 
+// UpdateAllErrorLoggerLevel updates all the exists ErrorLoggers' level, and returns the applied levels.
+// the applied level may be lower than the level input if the log level control is set.
+func UpdateAllErrorLoggerLevel(level log.Level) map[string]string {
+	errorLoggerManagerInstance.SetAllErrorLoggerLevel(level)
+	return errorLoggerManagerInstance.GetAllErrorLogger()
+}
+
 // ToggleLogger enable/disable the exists logger, include ErrorLogger and Logger
 func ToggleLogger(p string, disable bool) bool {
 	// find ErrorLogger
@@ -29,6 +29,7 @@ const (
 	DownstreamConnectionTotal    = "connection_total"
 	DownstreamConnectionDestroy  = "connection_destroy"
 	DownstreamConnectionActive   = "connection_active"
+	DownstreamConnectionOverflow = "connection_overflow"
 	DownstreamBytesReadTotal     = "bytes_read_total"
 	DownstreamBytesReadBuffered  = "bytes_read_buffered"
 	DownstreamBytesWriteTotal    = "bytes_write_total"
@@ -55,6 +56,13 @@ const (
 	DownstreamRequest503Total    = "request_503_total"
 	DownstreamRequest504Total    = "request_504_total"
 	DownstreamRequestOtherTotal  = "request_other_code"
+	DownstreamFilterTimeout      = "filter_timeout"
+	DownstreamReMatchRouteExceed = "rematch_route_exceeded"
+	DownstreamReChooseHostExceed = "rechoose_host_exceeded"
+	DownstreamRequestBodyExceed  = "request_body_exceeded"
+	DownstreamResponseBodyExceed = "response_body_exceeded"
+	DownstreamReqHeaderExceed    = "request_header_exceeded"
+	DownstreamRespHeaderExceed   = "response_header_exceeded"
 )
 
 // NewProxyStats returns a stats with namespace prefix proxy
@@ -68,3 +76,17 @@ func NewListenerStats(listenerName string) types.Metrics {
 	metrics, _ := NewMetrics(DownstreamType, map[string]string{"listener": listenerName})
 	return metrics
 }
+
+// NewListenerLabeledStats returns a stats with namespace prefix listener and the extra labels
+func NewListenerLabeledStats(listenerName string, labels map[string]string) types.Metrics {
+	l := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l["listener"] = listenerName
+	metrics, err := NewMetrics(DownstreamType, l)
+	if err != nil {
+		metrics, _ = NewNilMetrics(DownstreamType, l)
+	}
+	return metrics
+}
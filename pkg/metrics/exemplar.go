@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// Exemplar is a recently recorded sample of a histogram, it links the metrics
+// to the trace of the sampled request
+type Exemplar struct {
+	TraceId   string
+	Value     int64
+	Timestamp time.Time
+}
+
+// histogram -> *Exemplar, only the latest exemplar is kept
+var exemplars = sync.Map{}
+
+// UpdateWithExemplar updates the histogram, and records the value as the histogram's
+// exemplar if the trace id is not empty
+func UpdateWithExemplar(h gometrics.Histogram, value int64, traceId string) {
+	h.Update(value)
+	if traceId == "" {
+		return
+	}
+	if _, ok := h.(gometrics.NilHistogram); ok {
+		return
+	}
+	exemplars.Store(unwrapHistogram(h), &Exemplar{
+		TraceId:   traceId,
+		Value:     value,
+		Timestamp: time.Now(),
+	})
+}
+
+// GetExemplar returns the latest exemplar of the histogram
+func GetExemplar(h gometrics.Histogram) (Exemplar, bool) {
+	v, ok := exemplars.Load(unwrapHistogram(h))
+	if !ok {
+		return Exemplar{}, false
+	}
+	return *(v.(*Exemplar)), true
+}
+
+// unwrapHistogram returns the histogram in the registry, the exemplar is recorded by
+// the lazy histogram, and is read by the registered histogram when the metrics are flushed.
+func unwrapHistogram(h gometrics.Histogram) gometrics.Histogram {
+	if lh, ok := h.(*lazyHistogram); ok {
+		lh.preFunc()
+		return lh.histogram
+	}
+	return h
+}
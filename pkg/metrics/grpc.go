@@ -0,0 +1,39 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"mosn.io/mosn/pkg/types"
+)
+
+// GrpcMessageType represents the grpc message metrics type
+const GrpcMessageType = "grpc_message"
+
+// metrics key of the grpc messages proxied
+const (
+	GrpcRequestMessageTotal  = "request_message_total"
+	GrpcRequestMessageBytes  = "request_message_bytes"
+	GrpcResponseMessageTotal = "response_message_total"
+	GrpcResponseMessageBytes = "response_message_bytes"
+)
+
+// NewGrpcMessageStats returns a stats of the grpc messages with namespace cluster and method
+func NewGrpcMessageStats(clusterName string, method string) types.Metrics {
+	metrics, _ := NewMetrics(GrpcMessageType, map[string]string{"cluster": clusterName, "method": method})
+	return metrics
+}
@@ -0,0 +1,189 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/metrics/sink"
+	"mosn.io/mosn/pkg/otlp"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+const sinkType = "otlp"
+
+func init() {
+	sink.RegisterSink(sinkType, builder)
+}
+
+// otlpSink pushes the metrics to the OTLP collector with the export interval,
+// the counters are exported as the cumulative sums, and the gauges are exported as the gauges.
+type otlpSink struct {
+	client    *otlp.Client
+	resource  otlp.Resource
+	startTime time.Time
+	stop      chan struct{}
+}
+
+// ~ MetricsSink
+func (s *otlpSink) Flush(writer io.Writer, ms []types.Metrics) {
+	data, err := json.Marshal(s.metricsRequest(ms, time.Now()))
+	if err != nil {
+		log.DefaultLogger.Errorf("[metrics] [sink] [otlp] marshal metrics failed: %v", err)
+		return
+	}
+	writer.Write(data)
+}
+
+func (s *otlpSink) metricsRequest(ms []types.Metrics, now time.Time) otlp.ExportMetricsServiceRequest {
+	// keeps the order of the metrics, the data points of the same name are merged
+	var all []*otlp.Metric
+	index := make(map[string]*otlp.Metric)
+	getMetric := func(name string, counter bool) *otlp.Metric {
+		if m, ok := index[name]; ok {
+			return m
+		}
+		m := &otlp.Metric{Name: name}
+		if counter {
+			m.Sum = &otlp.Sum{
+				AggregationTemporality: otlp.AggregationTemporalityCumulative,
+				IsMonotonic:            true,
+			}
+		} else {
+			m.Gauge = &otlp.Gauge{}
+		}
+		index[name] = m
+		all = append(all, m)
+		return m
+	}
+	timestamp := otlp.UnixNano(now)
+	startTimestamp := otlp.UnixNano(s.startTime)
+
+	for _, m := range ms {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		attrs := make([]otlp.KeyValue, 0, len(labelKeys))
+		for i := range labelKeys {
+			attrs = append(attrs, otlp.StringAttribute(labelKeys[i], labelVals[i]))
+		}
+		addGauge := func(name string, value int64) {
+			g := getMetric(name, false).Gauge
+			g.DataPoints = append(g.DataPoints, otlp.NumberDataPoint{
+				Attributes:   attrs,
+				TimeUnixNano: timestamp,
+				AsInt:        strconv.FormatInt(value, 10),
+			})
+		}
+		prefix := m.Type() + "_"
+		m.Each(func(key string, i interface{}) {
+			if sink.IsExclusionKeys(key) {
+				return
+			}
+			name := prefix + key
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				sum := getMetric(name, true).Sum
+				sum.DataPoints = append(sum.DataPoints, otlp.NumberDataPoint{
+					Attributes:        attrs,
+					StartTimeUnixNano: startTimestamp,
+					TimeUnixNano:      timestamp,
+					AsInt:             strconv.FormatInt(metric.Count(), 10),
+				})
+			case gometrics.Gauge:
+				addGauge(name, metric.Value())
+			case gometrics.Histogram:
+				h := metric.Snapshot()
+				addGauge(name+"_min", h.Min())
+				addGauge(name+"_max", h.Max())
+			}
+		})
+	}
+
+	metricList := make([]otlp.Metric, 0, len(all))
+	for _, m := range all {
+		metricList = append(metricList, *m)
+	}
+	return otlp.ExportMetricsServiceRequest{
+		ResourceMetrics: []otlp.ResourceMetrics{
+			{
+				Resource: s.resource,
+				ScopeMetrics: []otlp.ScopeMetrics{
+					{
+						Scope:   otlp.InstrumentationScope{Name: otlp.InstrumentationScopeName},
+						Metrics: metricList,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *otlpSink) export() {
+	request := s.metricsRequest(metrics.GetAll(), time.Now())
+	if err := s.client.ExportMetrics(&request); err != nil {
+		log.DefaultLogger.Errorf("[metrics] [sink] [otlp] export metrics to %s failed: %v", s.client.Config().Endpoint, err)
+	}
+}
+
+func (s *otlpSink) run() {
+	ticker := time.NewTicker(s.client.Config().ExportInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.client.Close()
+			return
+		case <-ticker.C:
+			s.export()
+		}
+	}
+}
+
+// NewOtlpSink returns a metrics sink that pushes the metrics to the OTLP collector
+func NewOtlpSink(config *otlp.Config) (types.MetricsSink, error) {
+	client, err := otlp.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	s := &otlpSink{
+		client:    client,
+		resource:  config.Resource(),
+		startTime: time.Now(),
+		stop:      make(chan struct{}),
+	}
+	utils.GoWithRecover(s.run, nil)
+	return s, nil
+}
+
+// factory
+func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
+	config, err := otlp.ParseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewOtlpSink(config)
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/otlp"
+)
+
+func TestOtlpSinkExport(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	// mock otlp receiver
+	received := make(chan otlp.ExportMetricsServiceRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, otlp.MetricsPath, r.URL.Path)
+		var request otlp.ExportMetricsServiceRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		select {
+		case received <- request:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	m, _ := metrics.NewMetrics("test_otlp", map[string]string{"cluster": "c1"})
+	m.Counter("requests").Inc(10)
+	m.Gauge("active").Update(3)
+	m.Histogram("latency").Update(5)
+
+	s, err := builder(map[string]interface{}{
+		"endpoint":        server.URL,
+		"export_interval": "50ms",
+		"service_name":    "test_service",
+	})
+	require.Nil(t, err)
+	defer close(s.(*otlpSink).stop)
+
+	var request otlp.ExportMetricsServiceRequest
+	select {
+	case request = <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("metrics are not exported")
+	}
+	require.Len(t, request.ResourceMetrics, 1)
+	rm := request.ResourceMetrics[0]
+	assert.Contains(t, rm.Resource.Attributes, otlp.StringAttribute("service.name", "test_service"))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	exported := make(map[string]otlp.Metric)
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		exported[metric.Name] = metric
+	}
+	label := otlp.StringAttribute("cluster", "c1")
+	// counter
+	requests, ok := exported["test_otlp_requests"]
+	require.True(t, ok)
+	require.NotNil(t, requests.Sum)
+	assert.True(t, requests.Sum.IsMonotonic)
+	assert.Equal(t, otlp.AggregationTemporalityCumulative, requests.Sum.AggregationTemporality)
+	require.Len(t, requests.Sum.DataPoints, 1)
+	assert.Equal(t, "10", requests.Sum.DataPoints[0].AsInt)
+	assert.Equal(t, []otlp.KeyValue{label}, requests.Sum.DataPoints[0].Attributes)
+	// gauge
+	active, ok := exported["test_otlp_active"]
+	require.True(t, ok)
+	require.NotNil(t, active.Gauge)
+	assert.Equal(t, "3", active.Gauge.DataPoints[0].AsInt)
+	// histogram
+	assert.Equal(t, "5", exported["test_otlp_latency_min"].Gauge.DataPoints[0].AsInt)
+	assert.Equal(t, "5", exported["test_otlp_latency_max"].Gauge.DataPoints[0].AsInt)
+}
+
+func TestOtlpSinkConfig(t *testing.T) {
+	_, err := builder(map[string]interface{}{})
+	assert.NotNil(t, err)
+	_, err = builder(map[string]interface{}{
+		"endpoint": "127.0.0.1:4317",
+		"protocol": "unknown",
+	})
+	assert.NotNil(t, err)
+	// the grpc connection is established in the background
+	s, err := builder(map[string]interface{}{
+		"endpoint": "127.0.0.1:4317",
+		"protocol": "grpc",
+	})
+	require.Nil(t, err)
+	close(s.(*otlpSink).stop)
+}
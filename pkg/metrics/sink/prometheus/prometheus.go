@@ -29,6 +29,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -43,9 +44,11 @@ import (
 )
 
 var (
-	sinkType        = "prometheus"
-	defaultEndpoint = "/metrics"
-	numBufPool      = sync.Pool{
+	sinkType           = "prometheus"
+	defaultEndpoint    = "/metrics"
+	openMetricsType    = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	observationsSuffix = "_observations"
+	numBufPool         = sync.Pool{
 		New: func() interface{} {
 			b := make([]byte, 0, 24)
 			return &b
@@ -62,6 +65,7 @@ type promConfig struct {
 	ExportUrl             string    `json:"export_url"` // when this value is not nil, PromSink will work under the PUSHGATEWAY mode.
 	Port                  int       `json:"port"`       // pull mode attrs
 	Endpoint              string    `json:"endpoint"`
+	OpenMetricsEndpoint   string    `json:"openmetrics_endpoint"` // exports mosn metrics with exemplars in OpenMetrics format
 	DisableCollectProcess bool      `json:"disable_collect_process"`
 	DisableCollectGo      bool      `json:"disable_collect_go"`
 	Percentiles           []int     `json:"percentiles,omitempty"`
@@ -88,6 +92,17 @@ func (exporter *promHttpExporter) ServeHTTP(rsp http.ResponseWriter, req *http.R
 	exporter.sink.Flush(rsp, metrics.GetAll())
 }
 
+// openMetricsExporter exports the mosn metrics in OpenMetrics text format,
+// the histograms' exemplars are only available in this format.
+type openMetricsExporter struct {
+	sink *promSink
+}
+
+func (exporter *openMetricsExporter) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+	rsp.Header().Set("Content-Type", openMetricsType)
+	exporter.sink.FlushOpenMetrics(rsp, metrics.GetAll())
+}
+
 // ~ MetricsSink
 func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 	w := writer
@@ -125,6 +140,79 @@ func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 	}
 }
 
+// FlushOpenMetrics writes the metrics in OpenMetrics text format.
+// The samples of a metric family must be contiguous in OpenMetrics, so the samples are
+// grouped by family before written.
+func (psink *promSink) FlushOpenMetrics(writer io.Writer, ms []types.Metrics) {
+	families := make(map[string]types.IoBuffer)
+	order := make([]string, 0, len(ms))
+	family := func(name, typ string) types.IoBuffer {
+		buf, ok := families[name]
+		if !ok {
+			buf = buffer.GetIoBuffer(256)
+			buf.WriteString("# TYPE ")
+			buf.WriteString(name)
+			buf.WriteString(" ")
+			buf.WriteString(typ)
+			buf.WriteString("\n")
+			families[name] = buf
+			order = append(order, name)
+		}
+		return buf
+	}
+
+	for _, m := range ms {
+		typ := m.Type()
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+
+		prefix := typ + "_"
+		suffix := makeLabelStr(labelKeys, labelVals)
+
+		m.Each(func(name string, i interface{}) {
+			if sink.IsExclusionKeys(name) {
+				return
+			}
+			key := flattenKey(prefix + name)
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				name := strings.TrimSuffix(key, "_total")
+				writeSample(family(name, "counter"), name+"_total", suffix, float64(metric.Count()), nil)
+			case gometrics.Gauge:
+				writeSample(family(key, "gauge"), key, suffix, float64(metric.Value()), nil)
+			case gometrics.Histogram:
+				snapshot := metric.Snapshot()
+				writeSample(family(key+"_min", "gauge"), key+"_min", suffix, float64(snapshot.Min()), nil)
+				writeSample(family(key+"_max", "gauge"), key+"_max", suffix, float64(snapshot.Max()), nil)
+				if len(psink.config.Percentiles) > 0 {
+					ps := snapshot.Percentiles(psink.config.percentilesFloat)
+					if len(ps) == len(psink.config.Percentiles) {
+						for i, p := range psink.config.Percentiles {
+							writeSample(family(key, "gauge"), key, fmt.Sprintf("%s,percentile=\"P%d\"", suffix, p), ps[i], nil)
+						}
+					}
+				}
+				// the observations counter carries the exemplar
+				var exemplar *metrics.Exemplar
+				if e, ok := metrics.GetExemplar(metric); ok {
+					exemplar = &e
+				}
+				name := key + observationsSuffix
+				writeSample(family(name, "counter"), name+"_total", suffix, float64(snapshot.Count()), exemplar)
+			}
+		})
+	}
+
+	for _, name := range order {
+		buf := families[name]
+		buf.WriteTo(writer)
+		buffer.PutIoBuffer(buf)
+	}
+	io.WriteString(writer, "# EOF\n")
+}
+
 func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffer, name string, labels string, snapshot gometrics.Histogram) {
 	// min
 	psink.flushGauge(tracker, buf, name+"_min", labels, float64(snapshot.Min()))
@@ -177,6 +265,25 @@ func (psink *promSink) flushCounter(tracker map[string]bool, buf types.IoBuffer,
 	buf.WriteString("\n")
 }
 
+// writeSample writes a sample line, the exemplar is appended if not nil:
+// name{labels} value # {trace_id="..."} exemplar_value timestamp
+func writeSample(buf types.IoBuffer, name string, labels string, val float64, exemplar *metrics.Exemplar) {
+	buf.WriteString(name)
+	buf.WriteString("{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, val)
+	if exemplar != nil {
+		buf.WriteString(" # {trace_id=\"")
+		buf.WriteString(exemplar.TraceId)
+		buf.WriteString("\"} ")
+		writeFloat(buf, float64(exemplar.Value))
+		buf.WriteString(" ")
+		writeFloat(buf, float64(exemplar.Timestamp.UnixNano())/float64(time.Second))
+	}
+	buf.WriteString("\n")
+}
+
 // NewPromeSink returns a metrics sink that produces Prometheus metrics using store data
 func NewPromeSink(config *promConfig) types.MetricsSink {
 	promReg := prometheus.NewRegistry()
@@ -201,6 +308,11 @@ func NewPromeSink(config *promConfig) types.MetricsSink {
 			DisableCompression: true,
 		}),
 	})
+	if config.OpenMetricsEndpoint != "" {
+		srvMux.Handle(config.OpenMetricsEndpoint, &openMetricsExporter{
+			sink: promSink,
+		})
+	}
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf("0.0.0.0:%d", config.Port),
@@ -241,6 +353,15 @@ func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
 		}
 	}
 
+	if promCfg.OpenMetricsEndpoint != "" {
+		if !strings.HasPrefix(promCfg.OpenMetricsEndpoint, "/") {
+			return nil, fmt.Errorf("invalid openmetrics endpoint format:%s", promCfg.OpenMetricsEndpoint)
+		}
+		if promCfg.OpenMetricsEndpoint == promCfg.Endpoint {
+			return nil, fmt.Errorf("openmetrics endpoint conflicts with endpoint:%s", promCfg.Endpoint)
+		}
+	}
+
 	if len(promCfg.Percentiles) > 0 {
 		percentilesFloat := make([]float64, 0, len(promCfg.Percentiles))
 		for _, p := range promCfg.Percentiles {
@@ -234,6 +234,107 @@ func TestPrometheusHistogramMetrics(t *testing.T) {
 	}
 }
 
+func TestPrometheusOpenMetricsExemplar(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t2", map[string]string{"lbk1": "lbv1"})
+	s.Counter("request_total").Inc(3)
+	s.Gauge("active").Update(2)
+	h := s.Histogram("duration")
+	h.Update(100)
+	metrics.UpdateWithExemplar(h, 200, "4bf92f3577b34da6a3ce929d0e0e4736")
+	// empty trace id does not replace the exemplar
+	metrics.UpdateWithExemplar(h, 300, "")
+
+	psink := &promSink{config: &promConfig{}}
+	buf := &bytes.Buffer{}
+	psink.FlushOpenMetrics(buf, metrics.GetAll())
+	body := buf.String()
+
+	for _, expected := range []string{
+		"# TYPE t2_request counter\nt2_request_total{lbk1=\"lbv1\"} 3.0\n",
+		"# TYPE t2_active gauge\nt2_active{lbk1=\"lbv1\"} 2.0\n",
+		"t2_duration_min{lbk1=\"lbv1\"} 100.0\n",
+		"t2_duration_max{lbk1=\"lbv1\"} 300.0\n",
+		"# TYPE t2_duration_observations counter\nt2_duration_observations_total{lbk1=\"lbv1\"} 3.0 # {trace_id=\"4bf92f3577b34da6a3ce929d0e0e4736\"} 200.0 ",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("openmetrics output does not contain %q:\n%s", expected, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Error("openmetrics output should be terminated by # EOF")
+	}
+
+	// the exemplar is not exported in prometheus text format
+	buf.Reset()
+	psink.Flush(buf, metrics.GetAll())
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Error("prometheus text format should not contain exemplar")
+	}
+}
+
+func TestPrometheusOpenMetricsEndpoint(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t3", map[string]string{"lbk1": "lbv1"})
+	metrics.UpdateWithExemplar(s.Histogram("duration"), 10, "0af7651916cd43dd8448eb211c80319c")
+
+	_, err := builder(map[string]interface{}{
+		"port":                 8090,
+		"openmetrics_endpoint": "openmetrics",
+	})
+	if err == nil {
+		t.Error("invalid openmetrics endpoint should be failed")
+	}
+	_, err = builder(map[string]interface{}{
+		"port":                 8090,
+		"openmetrics_endpoint": "/metrics",
+	})
+	if err == nil {
+		t.Error("openmetrics endpoint conflicts with endpoint should be failed")
+	}
+	_, err = builder(map[string]interface{}{
+		"port":                 8090,
+		"endpoint":             "/metrics",
+		"openmetrics_endpoint": "/metrics/openmetrics",
+	})
+	if err != nil {
+		t.Fatal("create prometheus sink failed:", err)
+	}
+
+	store.StartService(nil)
+	defer func() {
+		store.StopService()
+		time.Sleep(time.Second)
+	}()
+	time.Sleep(time.Second) // wait server start
+
+	tc := http.Client{}
+	// nolint
+	resp, err := tc.Get("http://127.0.0.1:8090/metrics/openmetrics")
+	if err != nil {
+		t.Fatal("get openmetrics failed:", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !bytes.Contains(body, []byte(`t3_duration_observations_total{lbk1="lbv1"} 1.0 # {trace_id="0af7651916cd43dd8448eb211c80319c"} 10.0 `)) {
+		t.Errorf("exemplar not exported:\n%s", body)
+	}
+
+	// nolint
+	resp2, err := tc.Get("http://127.0.0.1:8090/metrics")
+	if err != nil {
+		t.Fatal("get metrics failed:", err)
+	}
+	defer resp2.Body.Close()
+	body, _ = ioutil.ReadAll(resp2.Body)
+	if bytes.Contains(body, []byte("trace_id")) {
+		t.Error("prometheus endpoint should not contain exemplar")
+	}
+}
+
 func TestPrometheusMetricsFilter(t *testing.T) {
 	metrics.ResetAll()
 	testCases := []struct {
@@ -249,6 +249,10 @@ func ResetAll() {
 	}
 	defaultStore.metrics = make(map[string]types.Metrics, 100)
 	defaultStore.matcher = defaultMatcher
+	exemplars.Range(func(key, _ interface{}) bool {
+		exemplars.Delete(key)
+		return true
+	})
 }
 
 func fullName(typ string, labels map[string]string) (fullName string, keys, values []string) {
@@ -24,7 +24,8 @@ const TLSType = "mosn_tls"
 
 // tls metrics key
 const (
-	TLSConnpoolChanged = "connpool_changed"
+	TLSConnpoolChanged   = "connpool_changed"
+	TLSClientCertRevoked = "client_cert_revoked"
 )
 
 // NewTLSStats returns a TLSMetrics named ${name}
@@ -51,14 +51,24 @@ const (
 
 //  key in cluster
 const (
-	UpstreamRequestRetry         = "request_retry"
-	UpstreamRequestRetryOverflow = "request_retry_overflow"
-	UpstreamLBSubSetsFallBack    = "lb_subsets_fallback"
-	UpstreamLBSubsetsCreated     = "lb_subsets_created"
-	UpstreamBytesReadTotal       = "connection_bytes_read_total"
-	UpstreamBytesReadBuffered    = "connection_bytes_read_buffered"
-	UpstreamBytesWriteTotal      = "connection_bytes_write"
-	UpstreamBytesWriteBuffered   = "connection_bytes_write_buffered"
+	UpstreamRequestRetry               = "request_retry"
+	UpstreamRequestRetryOverflow       = "request_retry_overflow"
+	UpstreamLBSubSetsFallBack          = "lb_subsets_fallback"
+	UpstreamLBSubsetsCreated           = "lb_subsets_created"
+	UpstreamBytesReadTotal             = "connection_bytes_read_total"
+	UpstreamBytesReadBuffered          = "connection_bytes_read_buffered"
+	UpstreamBytesWriteTotal            = "connection_bytes_write"
+	UpstreamBytesWriteBuffered         = "connection_bytes_write_buffered"
+	UpstreamConnectionIdle             = "connection_idle" // only the http1 and xprotocol pingpong pools park idle connections
+	UpstreamRequestPending             = "request_pending" // not counted by the xprotocol multiplex pool, which fails requests while connecting
+	UpstreamRequestCircuitBreakerOpen  = "request_circuit_breaker_open"
+	UpstreamRequestConcurrencyLimited  = "request_concurrency_limited"
+	UpstreamDnsResolveFailure          = "dns_resolve_failure"
+	UpstreamConnectionHostOverflow     = "connection_host_overflow"
+	UpstreamRequestMaintenanceMode     = "request_maintenance_mode"
+	UpstreamRequestAdmissionRejected   = "request_admission_rejected"
+	UpstreamConnectionGoAway           = "connection_goaway_received"
+	UpstreamRequestConnectFailureRetry = "request_connect_failure_retry"
 )
 
 // NewHostStats returns a stats that namespace contains cluster and host address
@@ -136,6 +136,20 @@ func (mr *MockClusterManagerMockRecorder) Destroy() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Destroy", reflect.TypeOf((*MockClusterManager)(nil).Destroy))
 }
 
+// DrainHost mocks base method.
+func (m *MockClusterManager) DrainHost(clusterName, addr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DrainHost", clusterName, addr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DrainHost indicates an expected call of DrainHost.
+func (mr *MockClusterManagerMockRecorder) DrainHost(clusterName, addr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DrainHost", reflect.TypeOf((*MockClusterManager)(nil).DrainHost), clusterName, addr)
+}
+
 // GetClusterSnapshot mocks base method.
 func (m *MockClusterManager) GetClusterSnapshot(context context.Context, cluster string) types.ClusterSnapshot {
 	m.ctrl.T.Helper()
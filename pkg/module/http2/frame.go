@@ -556,7 +556,8 @@ func (fr *Framer) checkFrameOrder(f Frame) error {
 	}
 
 	switch fh.Type {
-	case FrameHeaders, FrameContinuation:
+	// the END_HEADERS flag of PUSH_PROMISE is the same as HEADERS
+	case FrameHeaders, FrameContinuation, FramePushPromise:
 		if fh.Flags.Has(FlagHeadersEndHeaders) {
 			fr.lastHeaderStream = 0
 		} else {
@@ -264,11 +264,17 @@ func NewServerConn(conn api.Connection) *MServerConn {
 	return sc
 }
 
+// SetMaxConcurrentStreams sets the max concurrent streams advertised to the client,
+// the streams exceeding the limit are refused. It should be called before Init.
+func (sc *MServerConn) SetMaxConcurrentStreams(n uint32) {
+	sc.advMaxStreams = n
+}
+
 // Init send settings frame and window update
 func (sc *MServerConn) Init() error {
 	settings := writeSettings{
 		{SettingMaxFrameSize, defaultMaxReadFrameSize},
-		{SettingMaxConcurrentStreams, defaultMaxStreams * 100},
+		{SettingMaxConcurrentStreams, sc.advMaxStreams},
 		{SettingMaxHeaderListSize, http.DefaultMaxHeaderBytes},
 		{SettingInitialWindowSize, uint32(initialConnRecvWindowSize)},
 	}
@@ -504,16 +510,9 @@ func (sc *MServerConn) processHeaders(ctx context.Context, f *MetaHeadersFrame)
 	// advertised concurrent stream limit to be exceeded MUST treat
 	// this as a stream error (Section 5.4.2) of type PROTOCOL_ERROR
 	// or REFUSED_STREAM.
-	if sc.curClientStreams+1 > sc.advMaxStreams {
-		if sc.unackedSettings == 0 {
-			// They should know better.
-			return nil, false, false, streamError(id, ErrCodeProtocol)
-		}
-		// Assume it's a network race, where they just haven't
-		// received our last SETTINGS update. But actually
-		// this can't happen yet, because we don't yet provide
-		// a way for users to adjust server parameters at
-		// runtime.
+	// The REFUSED_STREAM is always used, so the client knows the request
+	// is not processed and it can be retried safely.
+	if atomic.LoadUint32(&sc.curClientStreams)+1 > sc.advMaxStreams {
 		return nil, false, false, streamError(id, ErrCodeRefusedStream)
 	}
 
@@ -959,18 +958,22 @@ func (sc *MServerConn) startGracefulShutdownInternal() {
 }
 
 func (sc *MServerConn) resetStream(se StreamError) error {
-	if st := sc.getStream(se.StreamID); st != nil {
-		if log.DefaultLogger.GetLogLevel() >= log.WARN {
-			log.DefaultLogger.Warnf("[Mserver Conn] streamId %d send RestFrame ", se.StreamID)
-		}
+	st := sc.getStream(se.StreamID)
+	// the refused stream is never created, but the client still needs the RST_STREAM to retry it
+	if st == nil && se.Code != ErrCodeRefusedStream {
+		return nil
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.WARN {
+		log.DefaultLogger.Warnf("[Mserver Conn] streamId %d send RestFrame ", se.StreamID)
+	}
+	if st != nil {
 		st.resetQueued = true
-
-		buf := buffer.NewIoBuffer(frameHeaderLen + 8)
-		sc.Framer.startWrite(buf, FrameRSTStream, 0, se.StreamID)
-		sc.Framer.writeUint32(buf, uint32(se.Code))
-		return sc.Framer.endWrite(buf)
 	}
-	return nil
+
+	buf := buffer.NewIoBuffer(frameHeaderLen + 8)
+	sc.Framer.startWrite(buf, FrameRSTStream, 0, se.StreamID)
+	sc.Framer.writeUint32(buf, uint32(se.Code))
+	return sc.Framer.endWrite(buf)
 }
 
 func (sc *MServerConn) goAway(code ErrCode, debugData []byte) {
@@ -996,6 +999,12 @@ type MClientConn struct {
 	api.Connection
 
 	onceInitFrame sync.Once
+
+	// SuppressPush refuses the streams promised by the server with RST_STREAM,
+	// otherwise the PUSH_PROMISE is treated as a connection error, since the push is disabled by the settings.
+	SuppressPush bool
+	// StreamPriority is sent with the HEADERS frame of each new stream if it is not zero
+	StreamPriority PriorityParam
 }
 
 // NewClientConn return Http2 Client conncetion
@@ -1274,6 +1283,7 @@ func (cc *MClientConn) writeHeaders(streamID uint32, endStream bool, maxFrameSiz
 				BlockFragment: chunk,
 				EndStream:     endStream,
 				EndHeaders:    endHeaders,
+				Priority:      cc.StreamPriority,
 			})
 			first = false
 		} else {
@@ -1348,9 +1358,7 @@ func (sc *MClientConn) HandleFrame(ctx context.Context, f Frame) (*http.Response
 	case *GoAwayFrame:
 		lastStream, err = sc.processGoAway(f)
 	case *PushPromiseFrame:
-		// A client cannot push. Thus, servers MUST treat the receipt of a PUSH_PROMISE
-		// frame as a connection error (Section 5.4.1) of type PROTOCOL_ERROR.
-		err = ConnectionError(ErrCodeProtocol)
+		err = sc.processPushPromise(f)
 	default:
 		err = fmt.Errorf("http2: server ignoring frame: %v", f.Header())
 	}
@@ -1716,6 +1724,25 @@ func (cc *MClientConn) processResetStream(f *RSTStreamFrame) error {
 	return nil
 }
 
+// processPushPromise processes PushPromise Frame for Http2 Client
+func (cc *MClientConn) processPushPromise(f *PushPromiseFrame) error {
+	// the push is disabled by the settings, the server should not send a PUSH_PROMISE
+	if !cc.SuppressPush {
+		return ConnectionError(ErrCodeProtocol)
+	}
+	// the promised stream must be a server initiated stream
+	if f.PromiseID == 0 || f.PromiseID%2 != 0 {
+		return ConnectionError(ErrCodeProtocol)
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[Mclient Conn] refuse the pushed stream %d associated with stream %d", f.PromiseID, f.StreamID)
+	}
+	buf := buffer.NewIoBuffer(frameHeaderLen + 8)
+	cc.Framer.startWrite(buf, FrameRSTStream, 0, f.PromiseID)
+	cc.Framer.writeUint32(buf, uint32(ErrCodeRefusedStream))
+	return cc.Framer.endWrite(buf)
+}
+
 // processGoAway processes GoAway Frame for Http2 Client
 func (cc *MClientConn) processGoAway(f *GoAwayFrame) (uint32, error) {
 	if f.ErrCode == ErrCodeNo {
@@ -2011,6 +2038,35 @@ func (fr *MFramer) readMetaFrame(ctx context.Context, hf *HeadersFrame, data buf
 	return mh, msize, nil
 }
 
+// readPushPromise reads the CONTINUATION frames of the PUSH_PROMISE, and decodes the header block
+// to keep the hpack dynamic table in sync, the promised request headers are discarded.
+func (fr *MFramer) readPushPromise(ctx context.Context, pp *PushPromiseFrame, data buffer.IoBuffer, off int) (int, error) {
+	hdec := fr.ReadMetaHeaders
+	hdec.SetEmitEnabled(false)
+	defer hdec.SetEmitEnabled(true)
+
+	var hc headersOrContinuation = pp
+	msize := 0
+	for {
+		if _, err := hdec.Write(hc.HeaderBlockFragment()); err != nil {
+			return 0, ConnectionError(ErrCodeCompression)
+		}
+		if hc.HeadersEnded() {
+			break
+		}
+		f, size, err := fr.ReadFrame(ctx, data, off+msize)
+		if err != nil {
+			return 0, err
+		}
+		msize += size
+		hc = f.(*ContinuationFrame) // guaranteed by checkFrameOrder
+	}
+	if err := hdec.Close(); err != nil {
+		return 0, ConnectionError(ErrCodeCompression)
+	}
+	return msize, nil
+}
+
 // ReadFrame read Frame
 func (fr *MFramer) ReadFrame(ctx context.Context, data buffer.IoBuffer, off int) (Frame, int, error) {
 	fr.errDetail = nil
@@ -2054,6 +2110,19 @@ func (fr *MFramer) ReadFrame(ctx context.Context, data buffer.IoBuffer, off int)
 		}
 	}
 
+	if fh.Type == FramePushPromise && fr.ReadMetaHeaders != nil {
+		msize, err = fr.readPushPromise(ctx, f.(*PushPromiseFrame), data, off+size)
+
+		if err != nil {
+			fr.lastFrame = last
+			fr.lastHeaderStream = lastHeader
+			if ce, ok := err.(connError); ok {
+				return nil, 0, fr.connError(ce.Code, ce.Reason)
+			}
+			return nil, 0, err
+		}
+	}
+
 	if fh.Type != FrameContinuation {
 		data.Drain(size + msize)
 	}
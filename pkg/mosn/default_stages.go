@@ -21,6 +21,7 @@ import (
 	admin "mosn.io/mosn/pkg/admin/server"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/featuregate"
+	"mosn.io/mosn/pkg/server"
 	"mosn.io/mosn/pkg/stagemanager"
 )
 
@@ -59,3 +60,13 @@ func DefaultStartStage(mosn stagemanager.Application) {
 	srv := admin.Server{}
 	srv.Start(m.Config)
 }
+
+// Default Before-stop Stage wrappers
+// drains the listeners before graceful stop, the upgrade transfers
+// the existing connections to the new server, so it is not drained.
+func DefaultBeforeStopStage(action stagemanager.StopAction, _ stagemanager.Application) error {
+	if action != stagemanager.GracefulStop {
+		return nil
+	}
+	return server.DrainListeners()
+}
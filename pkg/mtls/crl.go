@@ -0,0 +1,206 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/pkg/utils"
+)
+
+// the stats of the server side certificate verification are recorded in the global tls metrics
+const globalStats = "global"
+
+// crlReloadInterval is the interval to check whether the crl file is changed
+var crlReloadInterval = 10 * time.Second
+
+// crlVerifier rejects the peer certificates whose serial number is listed in the certificate revocation list.
+// the crl file is checked in background at most once per crlReloadInterval, and reloaded when it is changed,
+// so the handshakes only read the loaded crl.
+type crlVerifier struct {
+	path string
+	// failOpen allows the certificates if the crl is expired
+	failOpen bool
+	stats    *TLSStats
+
+	// crl is the latest loaded *crlInfo
+	crl atomic.Value
+	// nextReload is the unix nano time to check the crl file again
+	nextReload int64
+
+	// mutex protects the reload
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// crlInfo is a parsed certificate revocation list
+type crlInfo struct {
+	list *pkix.CertificateList
+	// issuer is the der encoded issuer name of the crl
+	issuer  []byte
+	revoked map[string]struct{}
+}
+
+func newCRLVerifier(path string, failOpen bool) (*crlVerifier, error) {
+	v := &crlVerifier{
+		path:     path,
+		failOpen: failOpen,
+		stats:    NewStats(globalStats),
+	}
+	if err := v.reload(); err != nil {
+		return nil, err
+	}
+	v.nextReload = time.Now().Add(crlReloadInterval).UnixNano()
+	return v, nil
+}
+
+// reload loads the crl file if the file is changed since the last load
+func (v *crlVerifier) reload() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	info, err := os.Stat(v.path)
+	if err != nil {
+		return fmt.Errorf("load crl file error: %v", err)
+	}
+	if v.crl.Load() != nil && info.ModTime().Equal(v.modTime) && info.Size() == v.size {
+		return nil
+	}
+	b, err := ioutil.ReadFile(v.path)
+	if err != nil {
+		return fmt.Errorf("load crl file error: %v", err)
+	}
+	// both pem and der are supported
+	list, err := x509.ParseCRL(b)
+	if err != nil {
+		return fmt.Errorf("parse crl file error: %v", err)
+	}
+	issuer, err := asn1.Marshal(list.TBSCertList.Issuer)
+	if err != nil {
+		return fmt.Errorf("parse crl file error: %v", err)
+	}
+	revoked := make(map[string]struct{}, len(list.TBSCertList.RevokedCertificates))
+	for _, rc := range list.TBSCertList.RevokedCertificates {
+		revoked[rc.SerialNumber.String()] = struct{}{}
+	}
+	v.crl.Store(&crlInfo{
+		list:    list,
+		issuer:  issuer,
+		revoked: revoked,
+	})
+	v.modTime = info.ModTime()
+	v.size = info.Size()
+	return nil
+}
+
+// current returns the latest loaded crl, and starts a reload in background if the reload interval is passed.
+// if the crl file is changed but cannot be loaded, the last one is used.
+func (v *crlVerifier) current() *crlInfo {
+	now := time.Now().UnixNano()
+	next := atomic.LoadInt64(&v.nextReload)
+	if now >= next && atomic.CompareAndSwapInt64(&v.nextReload, next, now+int64(crlReloadInterval)) {
+		utils.GoWithRecover(func() {
+			if err := v.reload(); err != nil {
+				log.DefaultLogger.Errorf("[mtls] reload crl %s failed, use the last one: %v", v.path, err)
+			}
+		}, nil)
+	}
+	return v.crl.Load().(*crlInfo)
+}
+
+// wrap returns a VerifyPeerCertificate function that checks the crl after the verify function
+func (v *crlVerifier) wrap(verify func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if verify != nil {
+			if err := verify(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		return v.verify(rawCerts, verifiedChains)
+	}
+}
+
+func (v *crlVerifier) verify(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	// no client certificate
+	if len(rawCerts) == 0 {
+		return nil
+	}
+	crl := v.current()
+	if crl.list.HasExpired(time.Now()) {
+		if !v.failOpen {
+			return ErrorCRLExpired
+		}
+		log.DefaultLogger.Warnf("[mtls] crl %s is expired, skip the revocation check", v.path)
+		return nil
+	}
+	var err error
+	if len(verifiedChains) > 0 {
+		err = crl.verifyChains(verifiedChains)
+	} else {
+		// the certificates are not verified by the tls, only the serial number can be checked
+		err = crl.verifyRawCerts(rawCerts)
+	}
+	if err == ErrorCertificateRevoked {
+		v.stats.TLSClientCertRevoked.Inc(1)
+	}
+	return err
+}
+
+func (crl *crlInfo) verifyChains(chains [][]*x509.Certificate) error {
+	for _, chain := range chains {
+		for i := 0; i < len(chain)-1; i++ {
+			cert, issuer := chain[i], chain[i+1]
+			if !bytes.Equal(cert.RawIssuer, crl.issuer) {
+				continue
+			}
+			// the crl is not signed by the issuer in chain
+			if issuer.CheckCRLSignature(crl.list) != nil {
+				continue
+			}
+			if _, ok := crl.revoked[cert.SerialNumber.String()]; ok {
+				return ErrorCertificateRevoked
+			}
+		}
+	}
+	return nil
+}
+
+func (crl *crlInfo) verifyRawCerts(rawCerts [][]byte) error {
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(cert.RawIssuer, crl.issuer) {
+			continue
+		}
+		if _, ok := crl.revoked[cert.SerialNumber.String()]; ok {
+			return ErrorCertificateRevoked
+		}
+	}
+	return nil
+}
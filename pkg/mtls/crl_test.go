@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package mtls
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+func parsePemCert(t *testing.T, s string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(s))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	return cert
+}
+
+// writeCRL writes a crl signed by the root ca to the path
+func writeCRL(t *testing.T, path string, expiry time.Time, revoked ...*x509.Certificate) {
+	root := certtool.GetRootCA()
+	ca := parsePemCert(t, root.CertPem)
+	block, _ := pem.Decode([]byte(root.KeyPem))
+	require.NotNil(t, block)
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	require.Nil(t, err)
+	var list []pkix.RevokedCertificate
+	for _, cert := range revoked {
+		list = append(list, pkix.RevokedCertificate{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := ca.CreateCRL(rand.Reader, priv, list, time.Now().Add(-time.Hour), expiry)
+	require.Nil(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	require.Nil(t, ioutil.WriteFile(path, data, 0644))
+}
+
+func createClientCert(t *testing.T, cn string) *x509.Certificate {
+	info := &certInfo{
+		CommonName: cn,
+		Curve:      "P256",
+	}
+	secret, err := info.CreateSecret()
+	require.Nil(t, err)
+	return parsePemCert(t, secret.Certificate)
+}
+
+func TestCRLVerifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crl")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.crl")
+	interval := crlReloadInterval
+	crlReloadInterval = 10 * time.Millisecond
+	defer func() {
+		crlReloadInterval = interval
+	}()
+
+	ca := parsePemCert(t, certtool.GetRootCA().CertPem)
+	valid := createClientCert(t, "valid")
+	revoked := createClientCert(t, "revoked")
+	chains := func(cert *x509.Certificate) ([][]byte, [][]*x509.Certificate) {
+		return [][]byte{cert.Raw}, [][]*x509.Certificate{{cert, ca}}
+	}
+
+	writeCRL(t, path, time.Now().Add(time.Hour), revoked)
+	v, err := newCRLVerifier(path, false)
+	require.Nil(t, err)
+	before := v.stats.TLSClientCertRevoked.Count()
+
+	// valid certificate
+	assert.Nil(t, v.verify(chains(valid)))
+	// revoked certificate
+	assert.Equal(t, ErrorCertificateRevoked, v.verify(chains(revoked)))
+	assert.Equal(t, ErrorCertificateRevoked, v.verify([][]byte{revoked.Raw}, nil))
+	assert.Equal(t, before+2, v.stats.TLSClientCertRevoked.Count())
+	// no client certificate
+	assert.Nil(t, v.verify(nil, nil))
+
+	// crl file changed, the valid certificate is revoked too after the crl is reloaded in background
+	writeCRL(t, path, time.Now().Add(time.Hour), revoked, valid)
+	modTime := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(path, modTime, modTime))
+	assert.Eventually(t, func() bool {
+		return v.verify(chains(valid)) == ErrorCertificateRevoked
+	}, time.Second, 10*time.Millisecond)
+
+	// broken crl file, the last one is used
+	require.Nil(t, ioutil.WriteFile(path, []byte("broken"), 0644))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, ErrorCertificateRevoked, v.verify(chains(valid)))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// the crl file is not checked in the reload interval
+	crlReloadInterval = time.Hour
+	v.verify(chains(valid))
+	time.Sleep(20 * time.Millisecond)
+	writeCRL(t, path, time.Now().Add(time.Hour), revoked)
+	modTime = modTime.Add(time.Minute)
+	require.Nil(t, os.Chtimes(path, modTime, modTime))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, ErrorCertificateRevoked, v.verify(chains(valid)))
+	crlReloadInterval = 10 * time.Millisecond
+
+	// expired crl
+	writeCRL(t, path, time.Now().Add(-time.Minute), revoked)
+	closed, err := newCRLVerifier(path, false)
+	require.Nil(t, err)
+	assert.Equal(t, ErrorCRLExpired, closed.verify(chains(valid)))
+	open, err := newCRLVerifier(path, true)
+	require.Nil(t, err)
+	assert.Nil(t, open.verify(chains(valid)))
+	assert.Nil(t, open.verify(chains(revoked)))
+
+	// crl file not exists
+	_, err = newCRLVerifier(filepath.Join(dir, "not_exists.crl"), false)
+	assert.NotNil(t, err)
+}
+
+func TestCRLVerifyClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crl")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.crl")
+
+	info := &certInfo{
+		CommonName: "test",
+		Curve:      "P256",
+	}
+	cfg, err := info.CreateCertConfig()
+	require.Nil(t, err)
+	cfg.RequireClientCert = true
+	cfg.VerifyClient = true
+	cfg.CRLFile = path
+
+	revokedCfg, err := info.CreateCertConfig()
+	require.Nil(t, err)
+	writeCRL(t, path, time.Now().Add(time.Hour), parsePemCert(t, revokedCfg.CertChain))
+
+	ctx, err := newTLSContext(cfg, &SecretInfo{
+		Certificate: cfg.CertChain,
+		PrivateKey:  cfg.PrivateKey,
+		Validation:  cfg.CACert,
+	})
+	require.Nil(t, err)
+	verify := ctx.GetServerTLSConfigContext().Config().VerifyPeerCertificate
+	require.NotNil(t, verify)
+	ca := parsePemCert(t, cfg.CACert)
+	for _, c := range []struct {
+		cert     string
+		expected error
+	}{
+		{cfg.CertChain, nil},
+		{revokedCfg.CertChain, ErrorCertificateRevoked},
+	} {
+		cert := parsePemCert(t, c.cert)
+		assert.Equal(t, c.expected, verify([][]byte{cert.Raw}, [][]*x509.Certificate{{cert, ca}}))
+	}
+}
@@ -23,12 +23,14 @@ import (
 )
 
 type TLSStats struct {
-	TLSConnpoolChanged gometrics.Counter
+	TLSConnpoolChanged   gometrics.Counter
+	TLSClientCertRevoked gometrics.Counter
 }
 
 func NewStats(name string) *TLSStats {
 	s := metrics.NewTLSStats(name)
 	return &TLSStats{
-		TLSConnpoolChanged: s.Counter(metrics.TLSConnpoolChanged),
+		TLSConnpoolChanged:   s.Counter(metrics.TLSConnpoolChanged),
+		TLSClientCertRevoked: s.Counter(metrics.TLSClientCertRevoked),
 	}
 }
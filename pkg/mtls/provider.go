@@ -18,6 +18,8 @@
 package mtls
 
 import (
+	"sync/atomic"
+
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/types"
 )
@@ -26,6 +28,50 @@ import (
 // staticProvider stored a static certificate
 type staticProvider struct {
 	*tlsContext
+	// rotated stores the *tlsContext of the rotated certificate
+	rotated atomic.Value
+}
+
+// current returns the tls context of the rotated certificate if the certificate is rotated
+func (p *staticProvider) current() *tlsContext {
+	if ctx, ok := p.rotated.Load().(*tlsContext); ok {
+		return ctx
+	}
+	return p.tlsContext
+}
+
+// rotate replaces the certificate, the new handshakes use the new certificate.
+// the old certificate is kept if the new certificate is invalid.
+func (p *staticProvider) rotate(cfg *v2.TLSConfig) error {
+	secret := &SecretInfo{
+		Certificate: cfg.CertChain,
+		PrivateKey:  cfg.PrivateKey,
+		Validation:  cfg.CACert,
+	}
+	// the fallback is not allowed, or the invalid certificate makes an empty tls context
+	rotateCfg := *cfg
+	rotateCfg.Fallback = false
+	ctx, err := newTLSContext(&rotateCfg, secret)
+	if err != nil {
+		return err
+	}
+	if ctx.server == nil {
+		return ErrorNoCertConfigure
+	}
+	p.rotated.Store(ctx)
+	return nil
+}
+
+func (p *staticProvider) GetTLSConfigContext(client bool) *types.TLSConfigContext {
+	return p.current().GetTLSConfigContext(client)
+}
+
+func (p *staticProvider) MatchedServerName(sn string) bool {
+	return p.current().MatchedServerName(sn)
+}
+
+func (p *staticProvider) MatchedALPN(protocols []string) bool {
+	return p.current().MatchedALPN(protocols)
 }
 
 func (p *staticProvider) Ready() bool {
@@ -33,7 +79,7 @@ func (p *staticProvider) Ready() bool {
 }
 
 func (p *staticProvider) Empty() bool {
-	return p.tlsContext.server == nil
+	return p.current().server == nil
 }
 
 // NewProvider returns a types.Provider.
@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+)
+
+const (
+	recordHeaderLen          = 5
+	recordTypeHandshake      = 0x16
+	handshakeTypeClientHello = 0x01
+	extensionServerName      = 0x0000
+	serverNameTypeHostName   = 0x00
+	// maxPlaintext is the max length of a TLS record payload
+	maxPlaintext = 16384
+)
+
+// peekTimeout is the timeout of reading the ClientHello, the connection is closed if the ClientHello
+// is not received in time, so the connections not sending any data are not kept long.
+var peekTimeout = 5 * time.Second
+
+// ClientHelloConn is a connection which has been peeked for the TLS ClientHello,
+// the peeked bytes are read again before the bytes of the raw connection.
+type ClientHelloConn struct {
+	net.Conn
+	peeked []byte
+}
+
+// Read reads the peeked bytes first
+func (c *ClientHelloConn) Read(b []byte) (int, error) {
+	if len(c.peeked) > 0 {
+		n := copy(b, c.peeked)
+		c.peeked = c.peeked[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// Buffered returns the number of the peeked bytes not read yet
+func (c *ClientHelloConn) Buffered() int {
+	return len(c.peeked)
+}
+
+// TCPConn returns the tcp connection of the connection, the connection peeked for the server name is unwrapped.
+func TCPConn(c net.Conn) (*net.TCPConn, bool) {
+	if hc, ok := c.(*ClientHelloConn); ok {
+		c = hc.Conn
+	}
+	tc, ok := c.(*net.TCPConn)
+	return tc, ok
+}
+
+// PeekServerName reads the first TLS record of the connection and returns the server name (SNI)
+// in the ClientHello. the server name is empty if the connection is not a TLS connection or
+// the ClientHello has no server name. the returned connection should be used instead of the raw connection.
+func PeekServerName(c net.Conn) (string, net.Conn, error) {
+	if _, ok := c.(*net.TCPConn); !ok {
+		return "", c, nil
+	}
+	conn := &ClientHelloConn{
+		Conn: c,
+	}
+	c.SetReadDeadline(time.Now().Add(peekTimeout))
+	defer c.SetReadDeadline(time.Time{}) // clear read deadline
+
+	header := make([]byte, recordHeaderLen)
+	n, err := io.ReadFull(c, header[:1])
+	conn.peeked = header[:n]
+	if err != nil {
+		return "", nil, err
+	}
+	// Non TLS
+	if header[0] != recordTypeHandshake {
+		return "", conn, nil
+	}
+	n, err = io.ReadFull(c, header[1:])
+	conn.peeked = header[:1+n]
+	if err != nil {
+		return "", nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[3:]))
+	if length > maxPlaintext {
+		return "", conn, nil
+	}
+	record := make([]byte, recordHeaderLen+length)
+	copy(record, header)
+	n, err = io.ReadFull(c, record[recordHeaderLen:])
+	conn.peeked = record[:recordHeaderLen+n]
+	if err != nil {
+		return "", nil, err
+	}
+	serverName, ok := parseServerName(record[recordHeaderLen:])
+	if !ok && log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[mtls] no server name found in the first record, local address: %v, remote address: %v", c.LocalAddr(), c.RemoteAddr())
+	}
+	return serverName, conn, nil
+}
+
+// parseServerName returns the server name of the ClientHello handshake message,
+// the message which is not completed in the data is parsed as far as possible.
+func parseServerName(data []byte) (string, bool) {
+	// handshake type (1) + length (3)
+	if len(data) < 4 || data[0] != handshakeTypeClientHello {
+		return "", false
+	}
+	data = data[4:]
+	// version (2) + random (32)
+	if len(data) < 34 {
+		return "", false
+	}
+	data = data[34:]
+	// session id
+	data, ok := skipVector(data, 1)
+	if !ok {
+		return "", false
+	}
+	// cipher suites
+	if data, ok = skipVector(data, 2); !ok {
+		return "", false
+	}
+	// compression methods
+	if data, ok = skipVector(data, 1); !ok {
+		return "", false
+	}
+	// no extensions
+	if len(data) < 2 {
+		return "", false
+	}
+	extensions := data[2:]
+	if l := int(binary.BigEndian.Uint16(data)); l < len(extensions) {
+		extensions = extensions[:l]
+	}
+	for len(extensions) >= 4 {
+		extType := binary.BigEndian.Uint16(extensions)
+		extLen := int(binary.BigEndian.Uint16(extensions[2:]))
+		if len(extensions) < 4+extLen {
+			return "", false
+		}
+		ext := extensions[4 : 4+extLen]
+		extensions = extensions[4+extLen:]
+		if extType != extensionServerName {
+			continue
+		}
+		// server name list
+		if len(ext) < 2 {
+			return "", false
+		}
+		list := ext[2:]
+		for len(list) >= 3 {
+			nameType := list[0]
+			nameLen := int(binary.BigEndian.Uint16(list[1:]))
+			if len(list) < 3+nameLen {
+				return "", false
+			}
+			if nameType == serverNameTypeHostName {
+				return string(list[3 : 3+nameLen]), true
+			}
+			list = list[3+nameLen:]
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// skipVector skips a vector whose length is encoded in lenBytes bytes
+func skipVector(data []byte, lenBytes int) ([]byte, bool) {
+	if len(data) < lenBytes {
+		return nil, false
+	}
+	var l int
+	for i := 0; i < lenBytes; i++ {
+		l = l<<8 | int(data[i])
+	}
+	data = data[lenBytes:]
+	if len(data) < l {
+		return nil, false
+	}
+	return data[l:], true
+}
@@ -0,0 +1,152 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClientHello crafts a TLS record with a ClientHello, the server name extension
+// is added after another extension if the server name is not empty.
+func mockClientHello(serverName string) []byte {
+	var extensions []byte
+	// supported groups extension
+	extensions = append(extensions, 0x00, 0x0a, 0x00, 0x04, 0x00, 0x02, 0x00, 0x17)
+	if serverName != "" {
+		name := []byte(serverName)
+		ext := make([]byte, 9, 9+len(name))
+		binary.BigEndian.PutUint16(ext[0:], extensionServerName)
+		binary.BigEndian.PutUint16(ext[2:], uint16(len(name)+5))
+		binary.BigEndian.PutUint16(ext[4:], uint16(len(name)+3))
+		ext[6] = serverNameTypeHostName
+		binary.BigEndian.PutUint16(ext[7:], uint16(len(name)))
+		extensions = append(extensions, append(ext, name...)...)
+	}
+	var body []byte
+	body = append(body, 0x03, 0x03)             // version
+	body = append(body, make([]byte, 32)...)    // random
+	body = append(body, 0x00)                   // session id
+	body = append(body, 0x00, 0x02, 0x13, 0x01) // cipher suites
+	body = append(body, 0x01, 0x00)             // compression methods
+	body = append(body, byte(len(extensions)>>8), byte(len(extensions)))
+	body = append(body, extensions...)
+
+	handshake := []byte{handshakeTypeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	record := []byte{recordTypeHandshake, 0x03, 0x01, byte(len(handshake) >> 8), byte(len(handshake))}
+	return append(record, handshake...)
+}
+
+func TestParseServerName(t *testing.T) {
+	for _, name := range []string{"a.example.com", "b.example.com", "localhost"} {
+		serverName, ok := parseServerName(mockClientHello(name)[recordHeaderLen:])
+		assert.True(t, ok)
+		assert.Equal(t, name, serverName)
+	}
+	// no server name
+	serverName, ok := parseServerName(mockClientHello("")[recordHeaderLen:])
+	assert.False(t, ok)
+	assert.Equal(t, "", serverName)
+	// truncated
+	hello := mockClientHello("a.example.com")
+	for _, l := range []int{recordHeaderLen, recordHeaderLen + 4, recordHeaderLen + 40, len(hello) - 1} {
+		serverName, ok = parseServerName(hello[recordHeaderLen:l])
+		assert.False(t, ok)
+		assert.Equal(t, "", serverName)
+	}
+	// not a ClientHello
+	serverName, ok = parseServerName([]byte{0x02, 0x00, 0x00, 0x00})
+	assert.False(t, ok)
+}
+
+func TestPeekServerName(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+
+	testCases := []struct {
+		data       []byte
+		serverName string
+	}{
+		{
+			data:       append(mockClientHello("a.example.com"), []byte("more data")...),
+			serverName: "a.example.com",
+		},
+		{
+			data:       mockClientHello(""),
+			serverName: "",
+		},
+		{
+			data:       []byte("GET / HTTP/1.1\r\n\r\n"),
+			serverName: "",
+		},
+	}
+	for _, tc := range testCases {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.Nil(t, err)
+		_, err = client.Write(tc.data)
+		require.Nil(t, err)
+		client.Close()
+
+		server, err := ln.Accept()
+		require.Nil(t, err)
+		serverName, conn, err := PeekServerName(server)
+		require.Nil(t, err)
+		assert.Equal(t, tc.serverName, serverName)
+		// the peeked bytes can be read again
+		data, err := ioutil.ReadAll(conn)
+		require.Nil(t, err)
+		assert.Equal(t, tc.data, data)
+		// the tcp connection is unwrapped
+		tcpConn, ok := TCPConn(conn)
+		assert.True(t, ok)
+		assert.Equal(t, server, tcpConn)
+		server.Close()
+	}
+}
+
+func TestPeekServerNameTimeout(t *testing.T) {
+	defer func(timeout time.Duration) {
+		peekTimeout = timeout
+	}(peekTimeout)
+	peekTimeout = 100 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	// the client sends nothing
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.Nil(t, err)
+	defer client.Close()
+	server, err := ln.Accept()
+	require.Nil(t, err)
+	defer server.Close()
+
+	start := time.Now()
+	_, _, err = PeekServerName(server)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
 )
 
 // Test the tls functions in static mode.
@@ -633,3 +634,168 @@ func TestReadError(t *testing.T) {
 		t.Fatalf("wait result timeout")
 	}
 }
+
+// TestRotateCertificate tests the new handshakes use the rotated certificate
+func TestRotateCertificate(t *testing.T) {
+	cfg, err := (&certInfo{"Cert1", "RSA", "www.example.com"}).CreateCertConfig()
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	lc := &v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			FilterChains: []v2.FilterChain{
+				{
+					TLSContexts: []v2.TLSConfig{*cfg},
+				},
+			},
+		},
+	}
+	ctxMng, err := NewTLSServerContextManager(lc)
+	if err != nil {
+		t.Fatalf("create context manager failed %v", err)
+	}
+	server := MockServer{
+		Mng: ctxMng,
+	}
+	server.GoListenAndServe()
+	defer server.Close()
+	time.Sleep(time.Second) //wait server start
+
+	requestCN := func() string {
+		cltMng, err := NewTLSClientContextManager("", &v2.TLSConfig{
+			Status:       true,
+			ServerName:   "www.example.com",
+			InsecureSkip: true,
+		})
+		if err != nil {
+			t.Fatalf("create client context manager failed %v", err)
+		}
+		resp, err := MockClient(server.Addr, cltMng)
+		if err != nil {
+			t.Fatalf("request server error %v", err)
+		}
+		defer resp.Body.Close()
+		ioutil.ReadAll(resp.Body)
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if cn := requestCN(); cn != "Cert1" {
+		t.Fatalf("expected certificate Cert1, but got %s", cn)
+	}
+
+	updater, ok := ctxMng.(types.TLSContextUpdater)
+	if !ok {
+		t.Fatal("server context manager should be a tls context updater")
+	}
+	// rotate the certificate
+	newCfg, err := (&certInfo{"Cert2", "RSA", "www.example.com"}).CreateCertConfig()
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	if err := updater.UpdateTLSContext(newCfg); err != nil {
+		t.Fatalf("rotate certificate failed: %v", err)
+	}
+	if cn := requestCN(); cn != "Cert2" {
+		t.Fatalf("expected rotated certificate Cert2, but got %s", cn)
+	}
+
+	// invalid certificate keeps the old one, even if fallback is configured
+	invalidCfg := &v2.TLSConfig{
+		Status:     true,
+		CertChain:  "invalid_certificate",
+		PrivateKey: "invalid_key",
+		Fallback:   true,
+	}
+	if err := updater.UpdateTLSContext(invalidCfg); err == nil {
+		t.Fatal("rotate invalid certificate should be failed")
+	}
+	if cn := requestCN(); cn != "Cert2" {
+		t.Fatalf("expected certificate Cert2 kept, but got %s", cn)
+	}
+
+	// no tls context matched the server name
+	newCfg.ServerName = "www.example.net"
+	if err := updater.UpdateTLSContext(newCfg); err != ErrorNoTLSContextMatched {
+		t.Fatalf("expected no tls context matched, but got %v", err)
+	}
+}
+
+func TestClientServerName(t *testing.T) {
+	var filterChains []v2.FilterChain
+	for _, info := range []*certInfo{
+		{"Cert1", "RSA", "www.example.com"},
+		{"Cert2", "RSA", "*.foo.com"},
+	} {
+		cfg, err := info.CreateCertConfig()
+		if err != nil {
+			t.Fatalf("create certificate failed: %v", err)
+		}
+		filterChains = append(filterChains, v2.FilterChain{
+			TLSContexts: []v2.TLSConfig{*cfg},
+		})
+	}
+	lc := &v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			FilterChains: filterChains,
+		},
+	}
+	ctxMng, err := NewTLSServerContextManager(lc)
+	if err != nil {
+		t.Fatalf("tls context manager error: %v", err)
+	}
+	server := MockServer{
+		Mng: ctxMng,
+	}
+	server.GoListenAndServe()
+	defer server.Close()
+	time.Sleep(time.Second) //wait server start
+
+	// handshake returns the common name of the server certificate
+	handshake := func(cfg *v2.TLSConfig, serverName string) (string, error) {
+		cltMng, err := NewTLSClientContextManager("", cfg)
+		if err != nil {
+			t.Fatalf("create client context manager failed %v", err)
+		}
+		mng, ok := cltMng.(types.TLSServerNameContextManager)
+		if !ok {
+			t.Fatalf("client context manager can not choose the server name")
+		}
+		c, err := net.Dial("tcp", server.Addr)
+		if err != nil {
+			t.Fatalf("dial server failed: %v", err)
+		}
+		conn, err := mng.ConnWithServerName(c, serverName)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		return conn.(*TLSConn).ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	cfg := &v2.TLSConfig{
+		Status:            true,
+		ServerName:        "www.example.com",
+		InsecureSkip:      true,
+		AutoSniFromHost:   true,
+		AutoSanValidation: true,
+	}
+	// the configured server name is used if no server name is chosen
+	cn, err := handshake(cfg, "")
+	if err != nil || cn != "Cert1" {
+		t.Fatalf("expected Cert1, but got %s, error: %v", cn, err)
+	}
+	// the chosen server name is sent
+	cn, err = handshake(cfg, "test.foo.com")
+	if err != nil || cn != "Cert2" {
+		t.Fatalf("expected Cert2, but got %s, error: %v", cn, err)
+	}
+	// the server returns the first certificate for the unknown server name, which is not matched
+	if _, err := handshake(cfg, "www.bar.com"); err == nil || !strings.Contains(err.Error(), "subject alt names") {
+		t.Fatalf("expected the subject alt names verify failed, but got: %v", err)
+	}
+	// without the subject alt names validation, the insecure skip accepts any certificate
+	cfg.AutoSanValidation = false
+	cn, err = handshake(cfg, "www.bar.com")
+	if err != nil || cn != "Cert1" {
+		t.Fatalf("expected Cert1, but got %s, error: %v", cn, err)
+	}
+}
@@ -84,6 +84,7 @@ type tlsContext struct {
 	secret     *SecretInfo
 	client     *types.TLSConfigContext
 	server     *types.TLSConfigContext
+	crl        *crlVerifier
 }
 
 var _ TlsContext = (*tlsContext)(nil)
@@ -122,6 +123,9 @@ func (ctx *tlsContext) SetServerConfig(tmpl *tls.Config, cfg *v2.TLSConfig, hook
 	}
 	tlsConfig.ClientAuth = hooks.GetClientAuth(cfg)
 	tlsConfig.VerifyPeerCertificate = hooks.ServerHandshakeVerify(tlsConfig)
+	if ctx.crl != nil {
+		tlsConfig.VerifyPeerCertificate = ctx.crl.wrap(tlsConfig.VerifyPeerCertificate)
+	}
 
 	ctx.server = types.NewTLSConfigContext(tlsConfig, hooks.GenerateHashValue)
 	// build matches
@@ -238,6 +242,13 @@ func newTLSContext(cfg *v2.TLSConfig, secret *SecretInfo) (*tlsContext, error) {
 
 	ctx.config = cfg
 	ctx.secret = secret
+	if cfg.CRLFile != "" {
+		crl, err := newCRLVerifier(cfg.CRLFile, cfg.CRLFailOpen)
+		if err != nil {
+			return nil, err
+		}
+		ctx.crl = crl
+	}
 
 	// needs copy template config
 	if len(tmpl.Certificates) > 0 {
@@ -18,6 +18,9 @@
 package mtls
 
 import (
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net"
 	"reflect"
 	"time"
@@ -105,7 +108,10 @@ func (mng *serverContextManager) GetConfigForClient(info *tls.ClientHelloInfo) (
 }
 
 func (mng *serverContextManager) Conn(c net.Conn) (net.Conn, error) {
-	if _, ok := c.(*net.TCPConn); !ok {
+	switch c.(type) {
+	// the connection peeked for server name is a tcp connection too
+	case *net.TCPConn, *ClientHelloConn:
+	default:
 		return c, nil
 	}
 	if !mng.Enabled() {
@@ -136,6 +142,26 @@ func (mng *serverContextManager) Conn(c net.Conn) (net.Conn, error) {
 	}
 }
 
+// UpdateTLSContext rotates the certificate of the static provider with the same server name,
+// the established connections are not affected.
+func (mng *serverContextManager) UpdateTLSContext(cfg *v2.TLSConfig) error {
+	for _, p := range mng.providers {
+		provider, ok := p.(*staticProvider)
+		if !ok || provider.current().config == nil || provider.current().config.ServerName != cfg.ServerName {
+			continue
+		}
+		if err := provider.rotate(cfg); err != nil {
+			log.DefaultLogger.Errorf("[mtls] rotate certificate failed, server name: %s, error: %v", cfg.ServerName, err)
+			return err
+		}
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[mtls] rotate certificate success, server name: %s", cfg.ServerName)
+		}
+		return nil
+	}
+	return ErrorNoTLSContextMatched
+}
+
 func (mng *serverContextManager) Enabled() bool {
 	for _, p := range mng.providers {
 		if p.Ready() {
@@ -150,6 +176,10 @@ type clientContextManager struct {
 	provider types.TLSProvider
 	// fallback
 	fallback bool
+	// autoServerName makes the server name chosen by the host of the request
+	autoServerName bool
+	// autoSanValidation verifies the subject alt names of the server certificate against the server name
+	autoSanValidation bool
 }
 
 // NewTLSClientContextManager returns a types.TLSContextManager used in TLS Client
@@ -159,8 +189,10 @@ func NewTLSClientContextManager(name string, cfg *v2.TLSConfig) (types.TLSClient
 		return nil, err
 	}
 	mng := &clientContextManager{
-		provider: provider,
-		fallback: cfg.Fallback,
+		provider:          provider,
+		fallback:          cfg.Fallback,
+		autoServerName:    cfg.AutoSniFromHost,
+		autoSanValidation: cfg.AutoSanValidation,
 	}
 	return mng, nil
 }
@@ -168,6 +200,11 @@ func NewTLSClientContextManager(name string, cfg *v2.TLSConfig) (types.TLSClient
 var handshakeTimeout = types.DefaultConnReadTimeout
 
 func (mng *clientContextManager) Conn(c net.Conn) (net.Conn, error) {
+	return mng.ConnWithServerName(c, "")
+}
+
+// ConnWithServerName implements types.TLSServerNameContextManager
+func (mng *clientContextManager) ConnWithServerName(c net.Conn, serverName string) (net.Conn, error) {
 	if _, ok := c.(*net.TCPConn); !ok {
 		return c, nil
 	}
@@ -175,7 +212,7 @@ func (mng *clientContextManager) Conn(c net.Conn) (net.Conn, error) {
 		return c, nil
 	}
 	// make tls connection and try handshake
-	tlsconn := tls.Client(c, mng.provider.GetTLSConfigContext(true).Config())
+	tlsconn := tls.Client(c, mng.clientConfig(serverName))
 	tlsconn.SetReadDeadline(time.Now().Add(handshakeTimeout))
 	if err := tlsconn.Handshake(); err != nil {
 		c.Close() // close the failed connection
@@ -187,6 +224,53 @@ func (mng *clientContextManager) Conn(c net.Conn) (net.Conn, error) {
 	}, nil
 }
 
+// clientConfig returns the tls config of the handshake, a non-empty server name overrides the configured one
+func (mng *clientContextManager) clientConfig(serverName string) *tls.Config {
+	config := mng.provider.GetTLSConfigContext(true).Config()
+	if serverName == "" && !mng.autoSanValidation {
+		return config
+	}
+	config = config.Clone()
+	if serverName != "" {
+		config.ServerName = serverName
+	}
+	if mng.autoSanValidation {
+		config.VerifyPeerCertificate = verifyServerName(config.ServerName, config.VerifyPeerCertificate)
+	}
+	return config
+}
+
+// verifyServerName wraps the verify function with the subject alt names verification of the server certificate,
+// it works even if the insecure skip is set.
+func verifyServerName(serverName string, verify func([][]byte, [][]*x509.Certificate) error) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if verify != nil {
+			if err := verify(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		if serverName == "" {
+			return nil
+		}
+		if len(rawCerts) == 0 {
+			return errors.New("tls: no server certificate")
+		}
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		if err := leaf.VerifyHostname(serverName); err != nil {
+			return fmt.Errorf("tls: subject alt names not matched the server name: %v", err)
+		}
+		return nil
+	}
+}
+
+// AutoServerName implements types.TLSServerNameContextManager
+func (mng *clientContextManager) AutoServerName() bool {
+	return mng.autoServerName
+}
+
 func (mng *clientContextManager) Enabled() bool {
 	return mng != nil && mng.provider != nil && mng.provider.Ready()
 }
@@ -95,3 +95,12 @@ type ConfigHooksFactory interface {
 
 // ErrorNoCertConfigure represents config has no certificate
 var ErrorNoCertConfigure = errors.New("no certificate config")
+
+// ErrorNoTLSContextMatched represents no tls context matches the config to update
+var ErrorNoTLSContextMatched = errors.New("no tls context matched")
+
+// ErrorCertificateRevoked represents the peer certificate is listed in the certificate revocation list
+var ErrorCertificateRevoked = errors.New("certificate is revoked")
+
+// ErrorCRLExpired represents the certificate revocation list is expired
+var ErrorCRLExpired = errors.New("certificate revocation list is expired")
@@ -918,7 +918,7 @@ func (c *connection) Close(ccType api.ConnectionCloseType, eventType api.Connect
 	}
 
 	// shutdown read first
-	if rawc, ok := c.rawConnection.(*net.TCPConn); ok {
+	if rawc, ok := mtls.TCPConn(c.rawConnection); ok {
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 			log.DefaultLogger.Debugf("[network] [close connection] Close TCP Conn, Remote Address is = %s, eventType is = %s", rawc.RemoteAddr(), eventType)
 		}
@@ -991,7 +991,7 @@ func (c *connection) NextProtocol() string {
 func (c *connection) SetNoDelay(enable bool) {
 	if c.rawConnection != nil {
 
-		if rawc, ok := c.rawConnection.(*net.TCPConn); ok {
+		if rawc, ok := mtls.TCPConn(c.rawConnection); ok {
 			rawc.SetNoDelay(enable)
 		}
 	}
@@ -1086,6 +1086,11 @@ type clientConnection struct {
 	connectTimeout time.Duration
 
 	connectOnce sync.Once
+
+	// proxyProtocolHeader is written before any other bytes, including the tls handshake
+	proxyProtocolHeader []byte
+	// serverName is the tls server name (SNI) of the connection, empty means the configured one
+	serverName string
 }
 
 func newClientConnection(connectTimeout time.Duration, tlsMng types.TLSClientContextManager, remoteAddr net.Addr, stopChan chan struct{}) types.ClientConnection {
@@ -1145,6 +1150,11 @@ func (cc *clientConnection) connect() (event api.ConnectionEvent, err error) {
 		}
 		return
 	}
+	if err = cc.writeProxyProtocolHeader(timeout); err != nil {
+		cc.rawConnection.Close()
+		event = api.ConnectFailed
+		return
+	}
 	atomic.StoreUint32(&cc.connected, 1)
 	event = api.Connected
 	cc.localAddr = cc.rawConnection.LocalAddr()
@@ -1171,6 +1181,29 @@ func (cc *clientConnection) connect() (event api.ConnectionEvent, err error) {
 	return
 }
 
+// SetProxyProtocolHeader implements types.ProxyProtocolConnection
+func (cc *clientConnection) SetProxyProtocolHeader(header []byte) {
+	cc.proxyProtocolHeader = header
+}
+
+// SetServerName implements types.ServerNameConnection
+func (cc *clientConnection) SetServerName(serverName string) {
+	cc.serverName = serverName
+}
+
+func (cc *clientConnection) writeProxyProtocolHeader(timeout time.Duration) error {
+	if len(cc.proxyProtocolHeader) == 0 {
+		return nil
+	}
+	if err := cc.rawConnection.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := cc.rawConnection.Write(cc.proxyProtocolHeader); err != nil {
+		return err
+	}
+	return cc.rawConnection.SetWriteDeadline(time.Time{})
+}
+
 func (cc *clientConnection) tryConnect() (event api.ConnectionEvent, err error) {
 	event, err = cc.connect()
 	if err != nil {
@@ -1179,7 +1212,11 @@ func (cc *clientConnection) tryConnect() (event api.ConnectionEvent, err error)
 	if cc.tlsMng == nil {
 		return
 	}
-	cc.rawConnection, err = cc.tlsMng.Conn(cc.rawConnection)
+	if mng, ok := cc.tlsMng.(types.TLSServerNameContextManager); ok {
+		cc.rawConnection, err = mng.ConnWithServerName(cc.rawConnection, cc.serverName)
+	} else {
+		cc.rawConnection, err = cc.tlsMng.Conn(cc.rawConnection)
+	}
 	if err == nil {
 		return
 	}
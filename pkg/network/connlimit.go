@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+// connLimitWaitInterval is the interval of a paused listener checking whether it is still running
+var connLimitWaitInterval = time.Second
+
+var errAcceptPaused = errors.New("listener stopped while waiting for the connection limit")
+
+// connLimiter limits the concurrent connections accepted by a listener,
+// a token is taken for each accepted connection and released when the connection is closed.
+type connLimiter struct {
+	tokens   chan struct{}
+	reject   bool
+	overflow gometrics.Counter
+}
+
+func newConnLimiter(listenerName string, maxConnections uint32, action v2.ConnectionLimitAction) *connLimiter {
+	return &connLimiter{
+		tokens:   make(chan struct{}, maxConnections),
+		reject:   action == v2.ConnectionLimitReject,
+		overflow: metrics.NewListenerStats(listenerName).Counter(metrics.DownstreamConnectionOverflow),
+	}
+}
+
+// tryAcquire takes a token without blocking
+func (cl *connLimiter) tryAcquire() bool {
+	select {
+	case cl.tokens <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquireTimeout waits for a token at most timeout
+func (cl *connLimiter) acquireTimeout(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case cl.tokens <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (cl *connLimiter) release() {
+	<-cl.tokens
+}
+
+// active returns the number of the connections holding a token
+func (cl *connLimiter) active() int {
+	return len(cl.tokens)
+}
+
+// connReleaser is a connection event listener that releases the token when the connection is closed
+type connReleaser struct {
+	limiter  *connLimiter
+	released uint32
+}
+
+func (r *connReleaser) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() && atomic.CompareAndSwapUint32(&r.released, 0, 1) {
+		r.limiter.release()
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+type acceptedConn struct {
+	rawc      net.Conn
+	listeners []api.ConnectionEventListener
+}
+
+// close closes the accepted connection as the connection handler does
+func (c *acceptedConn) close() {
+	c.rawc.Close()
+	for _, listener := range c.listeners {
+		listener.OnEvent(api.LocalClose)
+	}
+}
+
+type limitEventListener struct {
+	mockEventListener
+	accepted chan *acceptedConn
+}
+
+func (e *limitEventListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, c chan api.Connection, buf []byte, listeners []api.ConnectionEventListener) {
+	e.accepted <- &acceptedConn{rawc: rawc, listeners: listeners}
+}
+
+func startLimitListener(t *testing.T, name string, action v2.ConnectionLimitAction) (*listener, *limitEventListener, chan struct{}) {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	ln := NewListener(&v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name:                  name,
+			Network:               "tcp",
+			BindToPort:            true,
+			MaxConnections:        2,
+			ConnectionLimitAction: action,
+		},
+		Addr: addr,
+	}).(*listener)
+	el := &limitEventListener{accepted: make(chan *acceptedConn, 8)}
+	ln.SetListenerCallbacks(el)
+	stopped := make(chan struct{})
+	go func() {
+		ln.Start(nil, false)
+		close(stopped)
+	}()
+	require.Eventually(t, func() bool {
+		ln.mutex.Lock()
+		defer ln.mutex.Unlock()
+		return ln.state == ListenerRunning
+	}, time.Second, 10*time.Millisecond)
+	return ln, el, stopped
+}
+
+func dial(t *testing.T, ln *listener) net.Conn {
+	conn, err := net.Dial("tcp", ln.rawl.Addr().String())
+	require.Nil(t, err)
+	return conn
+}
+
+func waitAccepted(t *testing.T, el *limitEventListener, timeout time.Duration) *acceptedConn {
+	select {
+	case c := <-el.accepted:
+		return c
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+func TestListenerConnLimitReject(t *testing.T) {
+	ln, el, _ := startLimitListener(t, "test_conn_limit_reject", v2.ConnectionLimitReject)
+	defer ln.Close(nil)
+
+	var accepted []*acceptedConn
+	for i := 0; i < 2; i++ {
+		conn := dial(t, ln)
+		defer conn.Close()
+		c := waitAccepted(t, el, time.Second)
+		require.NotNil(t, c)
+		accepted = append(accepted, c)
+	}
+	assert.Equal(t, 2, ln.limiter.active())
+
+	// the new connection is accepted and closed immediately
+	conn := dial(t, ln)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, waitAccepted(t, el, 100*time.Millisecond))
+	assert.Equal(t, int64(1), ln.limiter.overflow.Count())
+
+	// admitted after a connection is closed, the duplicate close events are ignored
+	accepted[0].close()
+	accepted[0].close()
+	assert.Equal(t, 1, ln.limiter.active())
+	conn = dial(t, ln)
+	defer conn.Close()
+	assert.NotNil(t, waitAccepted(t, el, time.Second))
+	assert.Equal(t, 2, ln.limiter.active())
+}
+
+func TestListenerConnLimitPause(t *testing.T) {
+	ln, el, _ := startLimitListener(t, "test_conn_limit_pause", v2.ConnectionLimitPause)
+	defer ln.Close(nil)
+
+	var accepted []*acceptedConn
+	for i := 0; i < 2; i++ {
+		conn := dial(t, ln)
+		defer conn.Close()
+		c := waitAccepted(t, el, time.Second)
+		require.NotNil(t, c)
+		accepted = append(accepted, c)
+	}
+
+	// the new connection waits in the backlog until a connection is closed
+	conn := dial(t, ln)
+	defer conn.Close()
+	assert.Nil(t, waitAccepted(t, el, 200*time.Millisecond))
+	assert.Equal(t, int64(1), ln.limiter.overflow.Count())
+	accepted[1].close()
+	c := waitAccepted(t, el, time.Second)
+	require.NotNil(t, c)
+	assert.Equal(t, 2, ln.limiter.active())
+}
+
+func TestListenerConnLimitPauseStop(t *testing.T) {
+	interval := connLimitWaitInterval
+	connLimitWaitInterval = 10 * time.Millisecond
+	defer func() {
+		connLimitWaitInterval = interval
+	}()
+	ln, el, stopped := startLimitListener(t, "test_conn_limit_pause_stop", v2.ConnectionLimitPause)
+	defer ln.Close(nil)
+
+	for i := 0; i < 2; i++ {
+		conn := dial(t, ln)
+		defer conn.Close()
+		require.NotNil(t, waitAccepted(t, el, time.Second))
+	}
+	time.Sleep(50 * time.Millisecond)
+	// the paused accept loop exits when the listener is stopped
+	require.Nil(t, ln.Shutdown())
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("paused listener is not stopped")
+	}
+}
@@ -108,7 +108,23 @@ func getDnsType(dnsType v2.DnsLookupFamily) uint16 {
 }
 
 func (dr *DnsResolver) DnsResolve(dnsAddr string, dnsLookupFamily v2.DnsLookupFamily) *[]DnsResponse {
-	dnsQueryType := getDnsType(dnsLookupFamily)
+	// auto prefers the ipv6 addresses, and falls back to ipv4
+	if dnsLookupFamily == v2.Auto {
+		if dnsRsp := dr.resolve(dnsAddr, dns.TypeAAAA); dnsRsp != nil {
+			return dnsRsp
+		}
+		dnsLookupFamily = v2.V4Only
+	}
+	if dnsRsp := dr.resolve(dnsAddr, getDnsType(dnsLookupFamily)); dnsRsp != nil {
+		return dnsRsp
+	}
+
+	log.DefaultLogger.Errorf("[network] [dns] resolve addr: %s failed.", dnsAddr)
+
+	return nil
+}
+
+func (dr *DnsResolver) resolve(dnsAddr string, dnsQueryType uint16) *[]DnsResponse {
 	msg := new(dns.Msg)
 	addrs := dr.clientConfig.NameList(dnsAddr)
 	var dnsRsp []DnsResponse
@@ -156,7 +172,5 @@ func (dr *DnsResolver) DnsResolve(dnsAddr string, dnsLookupFamily v2.DnsLookupFa
 		}
 	}
 
-	log.DefaultLogger.Errorf("[network] [dns] resolve addr: %s failed.", dnsAddr)
-
 	return nil
 }
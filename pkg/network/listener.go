@@ -27,6 +27,7 @@ import (
 	"syscall"
 	"time"
 
+	"mosn.io/api"
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
@@ -77,6 +78,8 @@ type listener struct {
 	rawl                    net.Listener
 	config                  *v2.Listener
 	mutex                   sync.Mutex
+	// limiter is not nil if the listener limits the concurrent connections
+	limiter *connLimiter
 	// listener state indicates the listener's running state. The listener state effects if a listener binded to a port
 	state ListenerState
 }
@@ -94,6 +97,10 @@ func NewListener(lc *v2.Listener) types.Listener {
 		config:                  lc,
 	}
 
+	if lc.MaxConnections > 0 {
+		l.limiter = newConnLimiter(lc.Name, lc.MaxConnections, lc.ConnectionLimitAction)
+	}
+
 	if lc.InheritListener != nil {
 		//inherit old process's listener
 		l.rawl = lc.InheritListener
@@ -190,6 +197,10 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 func (l *listener) acceptEventLoop(lctx context.Context) {
 	for {
 		if err := l.accept(lctx); err != nil {
+			if err == errAcceptPaused {
+				log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s stop accepting connections while paused", l.name)
+				return
+			}
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
 				log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s stop accepting connections by deadline", l.name)
 				return
@@ -384,18 +395,62 @@ func (l *listener) listen(lctx context.Context) error {
 }
 
 func (l *listener) accept(lctx context.Context) error {
+	pause := l.limiter != nil && !l.limiter.reject
+	if pause {
+		if err := l.waitConnLimit(); err != nil {
+			return err
+		}
+	}
+
 	rawc, err := l.rawl.Accept()
 
 	if err != nil {
+		if pause {
+			l.limiter.release()
+		}
 		return err
 	}
 
+	// the token is released when the connection is closed
+	var listeners []api.ConnectionEventListener
+	if l.limiter != nil {
+		if !pause && !l.limiter.tryAcquire() {
+			l.limiter.overflow.Inc(1)
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[network] [listener] listener %s reaches the max connections, close the connection from %s", l.name, rawc.RemoteAddr())
+			}
+			rawc.Close()
+			return nil
+		}
+		listeners = []api.ConnectionEventListener{&connReleaser{limiter: l.limiter}}
+	}
+
 	// TODO: use thread pool
 	utils.GoWithRecover(func() {
 		if l.cb != nil {
-			l.cb.OnAccept(rawc, l.useOriginalDst, nil, nil, nil, nil)
+			l.cb.OnAccept(rawc, l.useOriginalDst, nil, nil, nil, listeners)
 		}
 	}, nil)
 
 	return nil
 }
+
+// waitConnLimit pauses accepting until the connections drop below the limit,
+// errAcceptPaused is returned if the listener is stopped while paused.
+func (l *listener) waitConnLimit() error {
+	if l.limiter.tryAcquire() {
+		return nil
+	}
+	l.limiter.overflow.Inc(1)
+	log.DefaultLogger.Infof("[network] [listener] listener %s reaches the max connections %d, pause accepting", l.name, cap(l.limiter.tokens))
+	for !l.limiter.acquireTimeout(connLimitWaitInterval) {
+		l.mutex.Lock()
+		running := l.state == ListenerRunning
+		l.mutex.Unlock()
+		if !running {
+			return errAcceptPaused
+		}
+	}
+	log.DefaultLogger.Infof("[network] [listener] listener %s resume accepting", l.name)
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// proxyProtocolV2Signature is the first 12 bytes of the proxy protocol v2 header
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	proxyProtocolV2Local = 0x20
+	proxyProtocolV2Proxy = 0x21
+	proxyProtocolV2TCP4  = 0x11
+	proxyProtocolV2TCP6  = 0x21
+)
+
+// ProxyProtocolHeader encodes the proxy protocol header carrying the client address src and
+// the destination address dst. the addresses are "ip:port", if any of them is invalid,
+// the header tells the receiver the addresses are unknown.
+func ProxyProtocolHeader(version string, src, dst string) ([]byte, error) {
+	srcIP, srcPort, srcOK := splitProxyProtocolAddr(src)
+	dstIP, dstPort, dstOK := splitProxyProtocolAddr(dst)
+	known := srcOK && dstOK
+	switch version {
+	case v2.ProxyProtocolV1:
+		if !known || (srcIP.To4() == nil) != (dstIP.To4() == nil) {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		proto := "TCP4"
+		if srcIP.To4() == nil {
+			proto = "TCP6"
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, srcIP, dstIP, srcPort, dstPort)), nil
+	case v2.ProxyProtocolV2:
+		var b bytes.Buffer
+		b.Write(proxyProtocolV2Signature)
+		if !known {
+			b.Write([]byte{proxyProtocolV2Local, 0, 0, 0})
+			return b.Bytes(), nil
+		}
+		b.WriteByte(proxyProtocolV2Proxy)
+		// the ipv4 addresses are mapped to ipv6 if the families are different
+		if src4, dst4 := srcIP.To4(), dstIP.To4(); src4 != nil && dst4 != nil {
+			b.WriteByte(proxyProtocolV2TCP4)
+			_ = binary.Write(&b, binary.BigEndian, uint16(net.IPv4len*2+4))
+			b.Write(src4)
+			b.Write(dst4)
+		} else {
+			b.WriteByte(proxyProtocolV2TCP6)
+			_ = binary.Write(&b, binary.BigEndian, uint16(net.IPv6len*2+4))
+			b.Write(srcIP.To16())
+			b.Write(dstIP.To16())
+		}
+		_ = binary.Write(&b, binary.BigEndian, srcPort)
+		_ = binary.Write(&b, binary.BigEndian, dstPort)
+		return b.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol version: %s", version)
+	}
+}
+
+func splitProxyProtocolAddr(addr string) (net.IP, uint16, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, 0, false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, false
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, 0, false
+	}
+	return ip, uint16(p), true
+}
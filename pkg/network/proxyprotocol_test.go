@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func TestProxyProtocolHeaderV1(t *testing.T) {
+	for _, tc := range []struct {
+		src, dst string
+		header   string
+	}{
+		{"192.168.1.1:56324", "10.0.0.1:443", "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n"},
+		{"[2001:db8::1]:56324", "[2001:db8::2]:443", "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"},
+		// the families are different
+		{"192.168.1.1:56324", "[2001:db8::2]:443", "PROXY UNKNOWN\r\n"},
+		{"", "10.0.0.1:443", "PROXY UNKNOWN\r\n"},
+		{"invalid", "10.0.0.1:443", "PROXY UNKNOWN\r\n"},
+	} {
+		header, err := ProxyProtocolHeader(v2.ProxyProtocolV1, tc.src, tc.dst)
+		require.Nil(t, err)
+		assert.Equal(t, tc.header, string(header))
+	}
+}
+
+func TestProxyProtocolHeaderV2(t *testing.T) {
+	header, err := ProxyProtocolHeader(v2.ProxyProtocolV2, "192.168.1.1:56324", "10.0.0.1:443")
+	require.Nil(t, err)
+	expected := append([]byte("\r\n\r\n\x00\r\nQUIT\n"),
+		0x21, 0x11, 0x00, 0x0c, // PROXY, TCP4, 12 bytes addresses
+		192, 168, 1, 1, // source address
+		10, 0, 0, 1, // destination address
+		0xdc, 0x04, // source port 56324
+		0x01, 0xbb, // destination port 443
+	)
+	assert.Equal(t, expected, header)
+
+	header, err = ProxyProtocolHeader(v2.ProxyProtocolV2, "[2001:db8::1]:56324", "10.0.0.1:443")
+	require.Nil(t, err)
+	require.Len(t, header, 16+36)
+	assert.Equal(t, []byte{0x21, 0x21, 0x00, 0x24}, header[12:16])
+	assert.Equal(t, net.ParseIP("2001:db8::1").To16(), net.IP(header[16:32]))
+	// the ipv4 address is mapped to ipv6
+	assert.Equal(t, net.ParseIP("10.0.0.1").To16(), net.IP(header[32:48]))
+
+	// the addresses are unknown
+	header, err = ProxyProtocolHeader(v2.ProxyProtocolV2, "", "")
+	require.Nil(t, err)
+	assert.Equal(t, append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x20, 0x00, 0x00, 0x00), header)
+
+	_, err = ProxyProtocolHeader("v3", "192.168.1.1:56324", "10.0.0.1:443")
+	assert.NotNil(t, err)
+}
+
+func TestClientConnectionProxyProtocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		header := "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n"
+		b := make([]byte, len(header)+len("data"))
+		_, _ = io.ReadFull(conn, b)
+		received <- b
+	}()
+
+	conn := NewClientConnection(time.Second, nil, ln.Addr(), nil)
+	header, _ := ProxyProtocolHeader(v2.ProxyProtocolV1, "192.168.1.1:56324", "10.0.0.1:443")
+	conn.(types.ProxyProtocolConnection).SetProxyProtocolHeader(header)
+	require.Nil(t, conn.Connect())
+	defer conn.Close(api.NoFlush, api.LocalClose)
+	require.Nil(t, conn.Write(buffer.NewIoBufferString("data")))
+
+	select {
+	case b := <-received:
+		// the header is sent before the data
+		assert.True(t, bytes.HasPrefix(b, header))
+		assert.Equal(t, "data", string(b[len(header):]))
+	case <-time.After(3 * time.Second):
+		t.Fatal("no bytes received")
+	}
+}
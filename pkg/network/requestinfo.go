@@ -25,6 +25,8 @@ import (
 
 	"go.uber.org/atomic"
 	"mosn.io/api"
+
+	"mosn.io/mosn/pkg/types"
 )
 
 // RequestInfo
@@ -45,6 +47,10 @@ type RequestInfo struct {
 	downstreamRemoteAddress  net.Addr
 	isHealthCheckRequest     bool
 	routerRule               api.RouteRule
+	upstreamClusterName      string
+	canaryDecision           types.CanaryDecision
+	grpcStatus               int
+	hasGrpcStatus            bool
 }
 
 func newRequestInfoWithPort(protocol api.ProtocolName) api.RequestInfo {
@@ -197,3 +203,28 @@ func (r *RequestInfo) RouteEntry() api.RouteRule {
 func (r *RequestInfo) SetRouteEntry(routerRule api.RouteRule) {
 	r.routerRule = routerRule
 }
+
+func (r *RequestInfo) UpstreamClusterName() string {
+	return r.upstreamClusterName
+}
+
+func (r *RequestInfo) SetUpstreamClusterName(name string) {
+	r.upstreamClusterName = name
+}
+
+func (r *RequestInfo) GrpcStatus() (int, bool) {
+	return r.grpcStatus, r.hasGrpcStatus
+}
+
+func (r *RequestInfo) SetGrpcStatus(status int) {
+	r.grpcStatus = status
+	r.hasGrpcStatus = true
+}
+
+func (r *RequestInfo) CanaryDecision() types.CanaryDecision {
+	return r.canaryDecision
+}
+
+func (r *RequestInfo) SetCanaryDecision(decision types.CanaryDecision) {
+	r.canaryDecision = decision
+}
@@ -233,8 +233,13 @@ func transferGetFile(c *connection) (file *os.File, tlsConn *mtls.TLSConn, err e
 		if err != nil {
 			return nil, nil, fmt.Errorf("Unix File failed %v", err)
 		}
+	case *mtls.ClientHelloConn:
+		file, err = transferClientHelloFile(conn)
+		if err != nil {
+			return nil, nil, err
+		}
 	case *mtls.Conn:
-		mtlsConn, ok := conn.Conn.(*net.TCPConn)
+		mtlsConn, ok := mtls.TCPConn(conn.Conn)
 		if !ok {
 			return nil, nil, errors.New("unexpected Conn type")
 		}
@@ -246,8 +251,13 @@ func transferGetFile(c *connection) (file *os.File, tlsConn *mtls.TLSConn, err e
 		tlsConn = conn
 		netConn := conn.GetRawConn()
 		switch conn := netConn.(type) {
+		case *mtls.ClientHelloConn:
+			file, err = transferClientHelloFile(conn)
+			if err != nil {
+				return nil, nil, err
+			}
 		case *mtls.Conn:
-			mtlsConn, ok := conn.Conn.(*net.TCPConn)
+			mtlsConn, ok := mtls.TCPConn(conn.Conn)
 			if !ok {
 				return nil, nil, errors.New("unexpected Conn type")
 			}
@@ -269,6 +279,23 @@ func transferGetFile(c *connection) (file *os.File, tlsConn *mtls.TLSConn, err e
 	return
 }
 
+// transferClientHelloFile returns the file of the connection peeked for the server name,
+// the peeked bytes not read yet can not be transferred.
+func transferClientHelloFile(conn *mtls.ClientHelloConn) (*os.File, error) {
+	if conn.Buffered() > 0 {
+		return nil, errors.New("peeked bytes are not read")
+	}
+	tc, ok := mtls.TCPConn(conn)
+	if !ok {
+		return nil, errors.New("unexpected Conn type")
+	}
+	file, err := tc.File()
+	if err != nil {
+		return nil, fmt.Errorf("ClientHelloConn File failed %v", err)
+	}
+	return file, nil
+}
+
 func transferBuildIoBuffer(c *connection) types.IoBuffer {
 	buf := buffer.GetIoBuffer(c.writeBufLen())
 	for _, b := range c.writeBuffers {
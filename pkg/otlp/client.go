@@ -0,0 +1,231 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"mosn.io/mosn/pkg/log"
+)
+
+// The paths of the OTLP/HTTP exports
+const (
+	TracesPath  = "/v1/traces"
+	MetricsPath = "/v1/metrics"
+)
+
+// The methods of the OTLP/gRPC exports
+const (
+	TracesMethod  = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	MetricsMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+)
+
+// retryBackoff is the wait time before the first retry, and it is doubled for each retry
+var retryBackoff = 100 * time.Millisecond
+
+// Client exports the OTLP requests to the collector with the configured protocol
+type Client struct {
+	config *Config
+	client *http.Client
+	conn   *grpc.ClientConn
+}
+
+// NewClient creates an OTLP/HTTP or OTLP/gRPC client
+func NewClient(config *Config) (*Client, error) {
+	c := &Client{
+		config: config,
+	}
+	if config.Protocol != ProtocolGRPC {
+		c.client = &http.Client{
+			Timeout: config.Timeout.Duration,
+		}
+		return c, nil
+	}
+	// the endpoint is the address, the https scheme enables tls
+	target := config.Endpoint
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if strings.HasPrefix(target, "https://") {
+		target = strings.TrimPrefix(target, "https://")
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))}
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "http://"), "/")
+	// the connection is established in the background
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("dial otlp collector %s failed: %v", config.Endpoint, err)
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// Config returns the config of the client
+func (c *Client) Config() *Config {
+	return c.config
+}
+
+// Close closes the grpc connection of the client
+func (c *Client) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// ExportTraces exports the spans
+func (c *Client) ExportTraces(request *ExportTraceServiceRequest) error {
+	if c.conn != nil {
+		body, err := request.MarshalProto()
+		if err != nil {
+			return err
+		}
+		return c.export(TracesMethod, func() (bool, error) {
+			return c.invoke(TracesMethod, body)
+		})
+	}
+	return c.exportJSON(TracesPath, request)
+}
+
+// ExportMetrics exports the metrics
+func (c *Client) ExportMetrics(request *ExportMetricsServiceRequest) error {
+	if c.conn != nil {
+		body, err := request.MarshalProto()
+		if err != nil {
+			return err
+		}
+		return c.export(MetricsMethod, func() (bool, error) {
+			return c.invoke(MetricsMethod, body)
+		})
+	}
+	return c.exportJSON(MetricsPath, request)
+}
+
+// exportJSON posts the json encoded request to the path
+func (c *Client) exportJSON(path string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(c.config.Endpoint, "/") + path
+	return c.export(url, func() (bool, error) {
+		return c.post(url, body)
+	})
+}
+
+// export sends the request, the failed export is retried
+// if the collector is unavailable or asks for a retry.
+func (c *Client) export(target string, send func() (retryable bool, err error)) error {
+	backoff := retryBackoff
+	var err error
+	for i := 0; i <= c.config.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var retryable bool
+		retryable, err = send()
+		if err == nil || !retryable {
+			break
+		}
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[otlp] export to %s failed, retry times: %d, error: %v", target, i, err)
+		}
+	}
+	return err
+}
+
+func (c *Client) post(url string, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, err
+	}
+	return false, err
+}
+
+func (c *Client) invoke(method string, body []byte) (retryable bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout.Duration)
+	defer cancel()
+	if len(c.config.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.config.Headers))
+	}
+	var reply []byte
+	err = c.conn.Invoke(ctx, method, body, &reply, grpc.ForceCodec(rawCodec{}))
+	if err == nil {
+		return false, nil
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+		return true, err
+	}
+	return false, err
+}
+
+// rawCodec sends the protobuf encoded request as it is, and keeps the response bytes,
+// the export response is not decoded.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected otlp message type: %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected otlp message type: %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,192 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(map[string]interface{}{
+		"endpoint":        "http://127.0.0.1:4318",
+		"export_interval": "1s",
+		"service_name":    "test",
+		"instance":        "instance-1",
+		"resource_attributes": map[string]interface{}{
+			"zone": "z1",
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, ProtocolHTTP, config.Protocol)
+	assert.Equal(t, time.Second, config.ExportInterval.Duration)
+	assert.Equal(t, defaultTimeout, config.Timeout.Duration)
+	assert.Equal(t, defaultMaxRetries, config.MaxRetries)
+	assert.Equal(t, defaultBatchSize, config.BatchSize)
+	assert.Equal(t, Resource{
+		Attributes: []KeyValue{
+			StringAttribute("service.name", "test"),
+			StringAttribute("service.instance.id", "instance-1"),
+			StringAttribute("zone", "z1"),
+		},
+	}, config.Resource())
+
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"endpoint": "127.0.0.1:4317", "protocol": "unknown"},
+		{"endpoint": "127.0.0.1:4317", "export_interval": "invalid"},
+	} {
+		_, err := ParseConfig(cfg)
+		assert.NotNil(t, err, "%v", cfg)
+	}
+}
+
+func TestClientExportRetry(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	defer func() {
+		retryBackoff = backoff
+	}()
+
+	var requests int32
+	var status int32 = http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		assert.Equal(t, MetricsPath, r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, `{"resourceMetrics":[]}`, string(body))
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	config, err := ParseConfig(map[string]interface{}{
+		"endpoint":    server.URL,
+		"max_retries": 2,
+		"headers": map[string]interface{}{
+			"Authorization": "token",
+		},
+	})
+	require.Nil(t, err)
+	client, err := NewClient(config)
+	require.Nil(t, err)
+	request := &ExportMetricsServiceRequest{ResourceMetrics: []ResourceMetrics{}}
+	// retry the unavailable collector
+	assert.NotNil(t, client.ExportMetrics(request))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	// the bad request is not retried
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&status, http.StatusBadRequest)
+	assert.NotNil(t, client.ExportMetrics(request))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	// success
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&status, http.StatusOK)
+	assert.Nil(t, client.ExportMetrics(request))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestClientExportGRPC(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	defer func() {
+		retryBackoff = backoff
+	}()
+
+	// mock otlp receiver, the request is kept in the unknown fields of the empty message
+	var requests int32
+	received := make(chan []byte, 1)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		assert.Equal(t, MetricsMethod, method)
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		assert.Equal(t, []string{"token"}, md.Get("authorization"))
+		request := &emptypb.Empty{}
+		if err := stream.RecvMsg(request); err != nil {
+			return err
+		}
+		// retry the unavailable collector
+		if atomic.AddInt32(&requests, 1) == 1 {
+			return status.Error(codes.Unavailable, "unavailable")
+		}
+		received <- request.ProtoReflect().GetUnknown()
+		return stream.SendMsg(&emptypb.Empty{})
+	}))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	config, err := ParseConfig(map[string]interface{}{
+		"endpoint":    ln.Addr().String(),
+		"protocol":    ProtocolGRPC,
+		"max_retries": 2,
+		"headers": map[string]interface{}{
+			"Authorization": "token",
+		},
+	})
+	require.Nil(t, err)
+	client, err := NewClient(config)
+	require.Nil(t, err)
+	defer client.Close()
+
+	request := &ExportMetricsServiceRequest{
+		ResourceMetrics: []ResourceMetrics{
+			{
+				Resource: config.Resource(),
+				ScopeMetrics: []ScopeMetrics{
+					{
+						Scope: InstrumentationScope{Name: InstrumentationScopeName},
+						Metrics: []Metric{
+							{
+								Name: "requests",
+								Gauge: &Gauge{
+									DataPoints: []NumberDataPoint{{TimeUnixNano: "1", AsInt: "10"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	require.Nil(t, client.ExportMetrics(request))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	expected, err := request.MarshalProto()
+	require.Nil(t, err)
+	select {
+	case body := <-received:
+		assert.Equal(t, expected, body)
+	case <-time.After(3 * time.Second):
+		t.Fatal("metrics are not exported")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"mosn.io/api"
+)
+
+// Group of the export protocols
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+)
+
+const (
+	defaultServiceName    = "mosn"
+	defaultExportInterval = 10 * time.Second
+	defaultTimeout        = 5 * time.Second
+	defaultMaxRetries     = 3
+	defaultBatchSize      = 512
+)
+
+// Config is the config of the OTLP exporter
+type Config struct {
+	// Endpoint is the collector's address, such as http://127.0.0.1:4318 for http,
+	// or 127.0.0.1:4317 for grpc, and the https scheme enables tls for grpc
+	Endpoint string `json:"endpoint"`
+	// Protocol is the export protocol, http with the json encoding by default, or grpc with the protobuf encoding
+	Protocol string            `json:"protocol,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	// ExportInterval is the interval to export the metrics and the batched spans
+	ExportInterval api.DurationConfig `json:"export_interval,omitempty"`
+	Timeout        api.DurationConfig `json:"timeout,omitempty"`
+	// MaxRetries is the max retry times of a failed export
+	MaxRetries int `json:"max_retries,omitempty"`
+	// BatchSize is the max number of the spans in an export
+	BatchSize int `json:"batch_size,omitempty"`
+	// ServiceName and Instance are the service.name and service.instance.id resource attributes
+	ServiceName        string            `json:"service_name,omitempty"`
+	Instance           string            `json:"instance,omitempty"`
+	ResourceAttributes map[string]string `json:"resource_attributes,omitempty"`
+}
+
+// ParseConfig parses the OTLP exporter config and sets the default values
+func ParseConfig(cfg map[string]interface{}) (*Config, error) {
+	config := &Config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing otlp config error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parsing otlp config error, err: %v, cfg: %v", err, cfg)
+	}
+	if config.Endpoint == "" {
+		return nil, errors.New("otlp endpoint is not specified")
+	}
+	switch config.Protocol {
+	case "":
+		config.Protocol = ProtocolHTTP
+	case ProtocolHTTP, ProtocolGRPC:
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol: %s", config.Protocol)
+	}
+	if config.ExportInterval.Duration <= 0 {
+		config.ExportInterval.Duration = defaultExportInterval
+	}
+	if config.Timeout.Duration <= 0 {
+		config.Timeout.Duration = defaultTimeout
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	if config.Instance == "" {
+		config.Instance, _ = os.Hostname()
+	}
+	return config, nil
+}
+
+// Resource returns the resource of the exported data
+func (c *Config) Resource() Resource {
+	attrs := make([]KeyValue, 0, len(c.ResourceAttributes)+2)
+	attrs = append(attrs, StringAttribute("service.name", c.ServiceName))
+	if c.Instance != "" {
+		attrs = append(attrs, StringAttribute("service.instance.id", c.Instance))
+	}
+	for _, k := range sortedKeys(c.ResourceAttributes) {
+		attrs = append(attrs, StringAttribute(k, c.ResourceAttributes[k]))
+	}
+	return Resource{
+		Attributes: attrs,
+	}
+}
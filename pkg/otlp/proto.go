@@ -0,0 +1,262 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The OTLP data model in the protobuf encoding is used by OTLP/gRPC, the field numbers follow
+// the opentelemetry-proto definitions. The messages are encoded from the json model directly,
+// so the hex ids and the 64 bits integers in strings are converted here.
+
+// MarshalProto encodes the request in the protobuf encoding
+func (r *ExportTraceServiceRequest) MarshalProto() ([]byte, error) {
+	var b []byte
+	for i := range r.ResourceSpans {
+		rs, err := r.ResourceSpans[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 1, rs)
+	}
+	return b, nil
+}
+
+func (rs *ResourceSpans) marshalProto() ([]byte, error) {
+	b := appendMessage(nil, 1, rs.Resource.marshalProto())
+	for i := range rs.ScopeSpans {
+		ss, err := rs.ScopeSpans[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 2, ss)
+	}
+	return b, nil
+}
+
+func (ss *ScopeSpans) marshalProto() ([]byte, error) {
+	b := appendMessage(nil, 1, ss.Scope.marshalProto())
+	for i := range ss.Spans {
+		s, err := ss.Spans[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 2, s)
+	}
+	return b, nil
+}
+
+func (s *Span) marshalProto() (b []byte, err error) {
+	if b, err = appendHex(b, 1, s.TraceId); err != nil {
+		return nil, fmt.Errorf("invalid trace id %s: %v", s.TraceId, err)
+	}
+	if b, err = appendHex(b, 2, s.SpanId); err != nil {
+		return nil, fmt.Errorf("invalid span id %s: %v", s.SpanId, err)
+	}
+	if s.TraceState != "" {
+		b = appendString(b, 3, s.TraceState)
+	}
+	if s.ParentSpanId != "" {
+		if b, err = appendHex(b, 4, s.ParentSpanId); err != nil {
+			return nil, fmt.Errorf("invalid parent span id %s: %v", s.ParentSpanId, err)
+		}
+	}
+	b = appendString(b, 5, s.Name)
+	b = appendVarint(b, 6, uint64(s.Kind))
+	if b, err = appendUnixNano(b, 7, s.StartTimeUnixNano); err != nil {
+		return nil, err
+	}
+	if b, err = appendUnixNano(b, 8, s.EndTimeUnixNano); err != nil {
+		return nil, err
+	}
+	for i := range s.Attributes {
+		kv, err := s.Attributes[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 9, kv)
+	}
+	b = appendMessage(b, 15, appendVarint(nil, 3, uint64(s.Status.Code)))
+	return b, nil
+}
+
+// MarshalProto encodes the request in the protobuf encoding
+func (r *ExportMetricsServiceRequest) MarshalProto() ([]byte, error) {
+	var b []byte
+	for i := range r.ResourceMetrics {
+		rm, err := r.ResourceMetrics[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 1, rm)
+	}
+	return b, nil
+}
+
+func (rm *ResourceMetrics) marshalProto() ([]byte, error) {
+	b := appendMessage(nil, 1, rm.Resource.marshalProto())
+	for i := range rm.ScopeMetrics {
+		sm, err := rm.ScopeMetrics[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 2, sm)
+	}
+	return b, nil
+}
+
+func (sm *ScopeMetrics) marshalProto() ([]byte, error) {
+	b := appendMessage(nil, 1, sm.Scope.marshalProto())
+	for i := range sm.Metrics {
+		m, err := sm.Metrics[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 2, m)
+	}
+	return b, nil
+}
+
+func (m *Metric) marshalProto() ([]byte, error) {
+	b := appendString(nil, 1, m.Name)
+	if m.Gauge != nil {
+		points, err := marshalDataPoints(m.Gauge.DataPoints)
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 5, points)
+	}
+	if m.Sum != nil {
+		points, err := marshalDataPoints(m.Sum.DataPoints)
+		if err != nil {
+			return nil, err
+		}
+		points = appendVarint(points, 2, uint64(m.Sum.AggregationTemporality))
+		points = appendVarint(points, 3, protowire.EncodeBool(m.Sum.IsMonotonic))
+		b = appendMessage(b, 7, points)
+	}
+	return b, nil
+}
+
+// marshalDataPoints encodes the data points field of the gauge and the sum
+func marshalDataPoints(points []NumberDataPoint) ([]byte, error) {
+	var b []byte
+	for i := range points {
+		p, err := points[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 1, p)
+	}
+	return b, nil
+}
+
+func (p *NumberDataPoint) marshalProto() (b []byte, err error) {
+	if p.StartTimeUnixNano != "" {
+		if b, err = appendUnixNano(b, 2, p.StartTimeUnixNano); err != nil {
+			return nil, err
+		}
+	}
+	if b, err = appendUnixNano(b, 3, p.TimeUnixNano); err != nil {
+		return nil, err
+	}
+	v, err := strconv.ParseInt(p.AsInt, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid int value %s: %v", p.AsInt, err)
+	}
+	b = protowire.AppendTag(b, 6, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(v))
+	for i := range p.Attributes {
+		kv, err := p.Attributes[i].marshalProto()
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 7, kv)
+	}
+	return b, nil
+}
+
+func (r *Resource) marshalProto() []byte {
+	var b []byte
+	for i := range r.Attributes {
+		// the resource attributes are all strings
+		kv, _ := r.Attributes[i].marshalProto()
+		b = appendMessage(b, 1, kv)
+	}
+	return b
+}
+
+func (s *InstrumentationScope) marshalProto() []byte {
+	return appendString(nil, 1, s.Name)
+}
+
+func (kv *KeyValue) marshalProto() ([]byte, error) {
+	var value []byte
+	switch {
+	case kv.Value.StringValue != nil:
+		value = appendString(value, 1, *kv.Value.StringValue)
+	case kv.Value.IntValue != nil:
+		v, err := strconv.ParseInt(*kv.Value.IntValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int value of attribute %s: %v", kv.Key, err)
+		}
+		value = appendVarint(value, 3, uint64(v))
+	}
+	b := appendString(nil, 1, kv.Key)
+	return appendMessage(b, 2, value), nil
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendHex decodes the hex encoded id to the bytes field
+func appendHex(b []byte, num protowire.Number, s string) ([]byte, error) {
+	id, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, id), nil
+}
+
+// appendUnixNano decodes the unix nano string to the fixed64 field
+func appendUnixNano(b []byte, num protowire.Number, s string) ([]byte, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unix nano %s: %v", s, err)
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v), nil
+}
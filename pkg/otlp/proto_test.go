@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeFields decodes the fields of a message, the varint and fixed64 values are decoded to uint64,
+// and the bytes values are kept raw.
+func decodeFields(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	fields := make(map[protowire.Number][]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0)
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.True(t, n > 0)
+		b = b[n:]
+		fields[num] = append(fields[num], v)
+	}
+	return fields
+}
+
+func TestMarshalTraceRequest(t *testing.T) {
+	request := &ExportTraceServiceRequest{
+		ResourceSpans: []ResourceSpans{
+			{
+				Resource: Resource{Attributes: []KeyValue{StringAttribute("service.name", "test")}},
+				ScopeSpans: []ScopeSpans{
+					{
+						Scope: InstrumentationScope{Name: InstrumentationScopeName},
+						Spans: []Span{
+							{
+								TraceId:           "4bf92f3577b34da6a3ce929d0e0e4736",
+								SpanId:            "00f067aa0ba902b7",
+								Name:              "test",
+								Kind:              SpanKindServer,
+								StartTimeUnixNano: "100",
+								EndTimeUnixNano:   "200",
+								Attributes:        []KeyValue{IntAttribute("mosn.response_code", 503)},
+								Status:            Status{Code: StatusCodeError},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	b, err := request.MarshalProto()
+	require.Nil(t, err)
+	resourceSpans := decodeFields(t, b)[1]
+	require.Len(t, resourceSpans, 1)
+	rs := decodeFields(t, resourceSpans[0].([]byte))
+	resource := decodeFields(t, rs[1][0].([]byte))
+	attr := decodeFields(t, resource[1][0].([]byte))
+	assert.Equal(t, []byte("service.name"), attr[1][0])
+	assert.Equal(t, []byte("test"), decodeFields(t, attr[2][0].([]byte))[1][0])
+	ss := decodeFields(t, rs[2][0].([]byte))
+	assert.Equal(t, []byte(InstrumentationScopeName), decodeFields(t, ss[1][0].([]byte))[1][0])
+
+	span := decodeFields(t, ss[2][0].([]byte))
+	traceId, _ := hex.DecodeString("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := hex.DecodeString("00f067aa0ba902b7")
+	assert.Equal(t, traceId, span[1][0])
+	assert.Equal(t, spanId, span[2][0])
+	assert.Empty(t, span[4])
+	assert.Equal(t, []byte("test"), span[5][0])
+	assert.Equal(t, uint64(SpanKindServer), span[6][0])
+	assert.Equal(t, uint64(100), span[7][0])
+	assert.Equal(t, uint64(200), span[8][0])
+	attr = decodeFields(t, span[9][0].([]byte))
+	assert.Equal(t, []byte("mosn.response_code"), attr[1][0])
+	assert.Equal(t, uint64(503), decodeFields(t, attr[2][0].([]byte))[3][0])
+	assert.Equal(t, uint64(StatusCodeError), decodeFields(t, span[15][0].([]byte))[3][0])
+
+	// the invalid id is not encoded
+	request.ResourceSpans[0].ScopeSpans[0].Spans[0].TraceId = "invalid"
+	_, err = request.MarshalProto()
+	assert.NotNil(t, err)
+}
+
+func TestMarshalMetricsRequest(t *testing.T) {
+	request := &ExportMetricsServiceRequest{
+		ResourceMetrics: []ResourceMetrics{
+			{
+				ScopeMetrics: []ScopeMetrics{
+					{
+						Scope: InstrumentationScope{Name: InstrumentationScopeName},
+						Metrics: []Metric{
+							{
+								Name: "requests",
+								Sum: &Sum{
+									DataPoints: []NumberDataPoint{
+										{
+											Attributes:        []KeyValue{StringAttribute("cluster", "c1")},
+											StartTimeUnixNano: "100",
+											TimeUnixNano:      "200",
+											AsInt:             "10",
+										},
+									},
+									AggregationTemporality: AggregationTemporalityCumulative,
+									IsMonotonic:            true,
+								},
+							},
+							{
+								Name: "active",
+								Gauge: &Gauge{
+									DataPoints: []NumberDataPoint{{TimeUnixNano: "200", AsInt: "-3"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	b, err := request.MarshalProto()
+	require.Nil(t, err)
+	rm := decodeFields(t, decodeFields(t, b)[1][0].([]byte))
+	metrics := decodeFields(t, rm[2][0].([]byte))[2]
+	require.Len(t, metrics, 2)
+
+	counter := decodeFields(t, metrics[0].([]byte))
+	assert.Equal(t, []byte("requests"), counter[1][0])
+	sum := decodeFields(t, counter[7][0].([]byte))
+	assert.Equal(t, uint64(AggregationTemporalityCumulative), sum[2][0])
+	assert.Equal(t, uint64(1), sum[3][0])
+	point := decodeFields(t, sum[1][0].([]byte))
+	assert.Equal(t, uint64(100), point[2][0])
+	assert.Equal(t, uint64(200), point[3][0])
+	assert.Equal(t, uint64(10), point[6][0])
+	attr := decodeFields(t, point[7][0].([]byte))
+	assert.Equal(t, []byte("cluster"), attr[1][0])
+
+	gauge := decodeFields(t, metrics[1].([]byte))
+	assert.Equal(t, []byte("active"), gauge[1][0])
+	point = decodeFields(t, decodeFields(t, gauge[5][0].([]byte))[1][0].([]byte))
+	assert.Empty(t, point[2])
+	assert.Equal(t, int64(-3), int64(point[6][0].(uint64)))
+
+	// the invalid value is not encoded
+	request.ResourceMetrics[0].ScopeMetrics[0].Metrics[1].Gauge.DataPoints[0].AsInt = "invalid"
+	_, err = request.MarshalProto()
+	assert.NotNil(t, err)
+}
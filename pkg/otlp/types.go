@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The OTLP data model in the json encoding, see https://github.com/open-telemetry/opentelemetry-proto.
+// The trace id and span id are encoded in hex, and the 64 bits integers are encoded in strings.
+
+// InstrumentationScopeName is the name of the instrumentation scope of the exported data
+const InstrumentationScopeName = "mosn"
+
+// Group of the span kinds
+const (
+	SpanKindServer = 2
+	SpanKindClient = 3
+)
+
+// Group of the span status codes
+const (
+	StatusCodeUnset = 0
+	StatusCodeOk    = 1
+	StatusCodeError = 2
+)
+
+// AggregationTemporalityCumulative means the value is accumulated from the start time
+const AggregationTemporalityCumulative = 2
+
+type AnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// StringAttribute returns a string attribute
+func StringAttribute(key, value string) KeyValue {
+	return KeyValue{
+		Key:   key,
+		Value: AnyValue{StringValue: &value},
+	}
+}
+
+// IntAttribute returns an int attribute
+func IntAttribute(key string, value int64) KeyValue {
+	v := strconv.FormatInt(value, 10)
+	return KeyValue{
+		Key:   key,
+		Value: AnyValue{IntValue: &v},
+	}
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type InstrumentationScope struct {
+	Name string `json:"name"`
+}
+
+// ExportTraceServiceRequest is posted to /v1/traces
+type ExportTraceServiceRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type ScopeSpans struct {
+	Scope InstrumentationScope `json:"scope"`
+	Spans []Span               `json:"spans"`
+}
+
+type Span struct {
+	TraceId           string     `json:"traceId"`
+	SpanId            string     `json:"spanId"`
+	TraceState        string     `json:"traceState,omitempty"`
+	ParentSpanId      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+type Status struct {
+	Code int `json:"code,omitempty"`
+}
+
+// ExportMetricsServiceRequest is posted to /v1/metrics
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+type ScopeMetrics struct {
+	Scope   InstrumentationScope `json:"scope"`
+	Metrics []Metric             `json:"metrics"`
+}
+
+type Metric struct {
+	Name  string `json:"name"`
+	Sum   *Sum   `json:"sum,omitempty"`
+	Gauge *Gauge `json:"gauge,omitempty"`
+}
+
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsInt             string     `json:"asInt"`
+}
+
+// UnixNano encodes the time in the unix nano string
+func UnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -18,6 +18,7 @@
 package http
 
 import (
+	"net/url"
 	"strings"
 
 	"mosn.io/mosn/pkg/log"
@@ -32,6 +33,8 @@ type (
 )
 
 // the query string looks like:  "field1=value1&field2=value2&field3=value3..."
+// the keys and values are url decoded, the parameter without value is parsed as an empty value,
+// and only the first one is kept if the parameter is repeated.
 func ParseQueryString(query string) types.QueryParams {
 	var QueryParams = make(types.QueryParams, 10)
 
@@ -41,14 +44,31 @@ func ParseQueryString(query string) types.QueryParams {
 	queryMaps := strings.Split(query, "&")
 
 	for _, qm := range queryMaps {
-		queryMap := strings.Split(qm, "=")
-
-		if len(queryMap) != 2 {
+		if qm == "" {
+			continue
+		}
+		var key, value string
+		queryMap := strings.SplitN(qm, "=", 2)
+		key = unescapeQuery(strings.TrimSpace(queryMap[0]))
+		if len(queryMap) == 2 {
+			value = unescapeQuery(strings.TrimSpace(queryMap[1]))
+		}
+		if key == "" {
 			log.DefaultLogger.Errorf("parse query parameters error,parameters = %s", qm)
-		} else {
-			QueryParams[strings.TrimSpace(queryMap[0])] = strings.TrimSpace(queryMap[1])
+			continue
+		}
+		if _, ok := QueryParams[key]; !ok {
+			QueryParams[key] = value
 		}
 	}
 
 	return QueryParams
 }
+
+// unescapeQuery decodes the url encoded query component, returns the raw one if it is not a valid encoding
+func unescapeQuery(s string) string {
+	if v, err := url.QueryUnescape(s); err == nil {
+		return v
+	}
+	return s
+}
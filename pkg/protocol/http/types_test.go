@@ -67,6 +67,28 @@ func TestParseQueryString(t *testing.T) {
 				"test":   "biz",
 			},
 		},
+
+		{
+			args: args{
+				query: "key1=value%201&key%202=a+b&empty&&equal=a=b",
+			},
+			want: types.QueryParams{
+				"key1":  "value 1",
+				"key 2": "a b",
+				"empty": "",
+				"equal": "a=b",
+			},
+		},
+
+		{
+			args: args{
+				query: "key1=first&key1=second&bad=%zz",
+			},
+			want: types.QueryParams{
+				"key1": "first",
+				"bad":  "%zz",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -18,9 +18,12 @@
 package dubbo
 
 import (
+	"strings"
+
 	"mosn.io/api"
 	"mosn.io/pkg/buffer"
 
+	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/types"
 )
@@ -46,6 +49,8 @@ type Frame struct {
 
 	data    types.IoBuffer // wrapper of data
 	content types.IoBuffer // wrapper of payload
+
+	attachmentsDecoded bool // the attachments are set as headers
 }
 
 var _ api.XFrame = &Frame{}
@@ -84,6 +89,30 @@ func (r *Frame) GetHeader() types.HeaderMap {
 	return r
 }
 
+// Get returns the header value, the attachment headers are decoded from the payload
+// when any of them is required at the first time.
+func (r *Frame) Get(key string) (string, bool) {
+	if strings.HasPrefix(key, AttachmentHeaderPrefix) {
+		r.decodeAttachments()
+	}
+	return r.Header.Get(key)
+}
+
+func (r *Frame) decodeAttachments() {
+	if r.attachmentsDecoded || r.IsEvent || r.Direction != EventRequest || r.SerializationId != 2 {
+		return
+	}
+	r.attachmentsDecoded = true
+	attachments, err := decodeAttachments(r.payload)
+	if err != nil {
+		log.DefaultLogger.Warnf("[xprotocol][dubbo] decode attachments of request %d failed: %v", r.Id, err)
+		return
+	}
+	for key, val := range attachments {
+		r.Set(AttachmentHeaderPrefix+key, val)
+	}
+}
+
 func (r *Frame) GetData() types.IoBuffer {
 	return r.content
 }
@@ -104,6 +133,7 @@ func (r *Frame) Clone() api.HeaderMap {
 		payload: make([]byte, len(r.payload)),
 	}
 	clone.Header = r.Header
+	clone.attachmentsDecoded = r.attachmentsDecoded
 	copy(clone.rawData, r.rawData)
 	copy(clone.payload, r.payload)
 	clone.data = buffer.NewIoBufferBytes(clone.rawData)
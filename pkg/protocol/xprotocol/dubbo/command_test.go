@@ -19,9 +19,11 @@ package dubbo
 
 import (
 	"context"
+	"encoding/binary"
 	"reflect"
 	"testing"
 
+	hessian "github.com/apache/dubbo-go-hessian2"
 	"mosn.io/api"
 	"mosn.io/pkg/buffer"
 )
@@ -88,3 +90,63 @@ func TestFrame(t *testing.T) {
 var encodeData = []uint8{
 	218, 187, 34, 20, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 4, 116, 101, 115, 116,
 }
+
+// newRequestData builds a dubbo request with one string argument and the attachments
+func newRequestData(t *testing.T, attachments map[string]string) []byte {
+	encoder := hessian.NewEncoder()
+	for _, field := range []interface{}{"2.0.2", "com.foo.Greeter", "1.0.0", "sayHello", "Ljava/lang/String;", "world", attachments} {
+		if err := encoder.Encode(field); err != nil {
+			t.Fatalf("encode dubbo request failed: %v", err)
+		}
+	}
+	payload := encoder.Buffer()
+	data := make([]byte, HeaderLen, HeaderLen+len(payload))
+	copy(data, MagicTag)
+	data[FlagIdx] = 0xc2
+	binary.BigEndian.PutUint64(data[IdIdx:], 1)
+	binary.BigEndian.PutUint32(data[DataLenIdx:], uint32(len(payload)))
+	return append(data, payload...)
+}
+
+func TestFrameAttachments(t *testing.T) {
+	data := newRequestData(t, map[string]string{
+		"version": "2.0.0",
+		"group":   "gray",
+	})
+
+	// the attachments are decoded when required
+	cmd, err := decodeFrame(newContextWithListenerName("Cheap"), buffer.NewIoBufferBytes(data))
+	if err != nil {
+		t.Fatalf("decode dubbo request failed: %v", err)
+	}
+	frame := cmd.(*Frame)
+	if frame.attachmentsDecoded {
+		t.Fatalf("the attachments should not be decoded")
+	}
+	if v, ok := frame.Get(AttachmentHeaderPrefix + "version"); !ok || v != "2.0.0" {
+		t.Errorf("unexpected version attachment: %s", v)
+	}
+	if v, ok := frame.Get(AttachmentHeaderPrefix + "group"); !ok || v != "gray" {
+		t.Errorf("unexpected group attachment: %s", v)
+	}
+	if _, ok := frame.Get(AttachmentHeaderPrefix + "notexists"); ok {
+		t.Errorf("unexpected attachment")
+	}
+	// the method version is not overwritten
+	if v, _ := frame.Get(VersionNameHeader); v != "1.0.0" {
+		t.Errorf("unexpected method version: %s", v)
+	}
+
+	// the attachments are decoded by the ingress listener
+	cmd, err = decodeFrame(newContextWithListenerName(IngressDubbo), buffer.NewIoBufferBytes(data))
+	if err != nil {
+		t.Fatalf("decode dubbo request failed: %v", err)
+	}
+	frame = cmd.(*Frame)
+	if !frame.attachmentsDecoded {
+		t.Fatalf("the attachments should be decoded")
+	}
+	if v, ok := frame.Get(AttachmentHeaderPrefix + "group"); !ok || v != "gray" {
+		t.Errorf("unexpected group attachment: %s", v)
+	}
+}
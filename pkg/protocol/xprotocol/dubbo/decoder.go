@@ -222,55 +222,83 @@ func getServiceAwareMeta(ctx context.Context, frame *Frame) (meta map[string]str
 
 		// decode the attachment to get the real service and group parameters
 		if !matched && (listener == EgressDubbo || listener == IngressDubbo) {
-
-			// decode arguments maybe panic, when dubbo payload have complex struct
-			defer func() {
-				if r := recover(); r != nil {
-					err = fmt.Errorf("decode arguments error :%v\n%s", r, debug.Stack())
-				}
-			}()
-
-			field, err = decoder.Decode()
+			attachments, err := readAttachments(decoder)
 			if err != nil {
-				return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo argument types error: %v", err)
+				return nil, err
 			}
-
-			arguments := getArgumentCount(field.(string))
-			// we must skip all method arguments.
-			for i := 0; i < arguments; i++ {
-				_, err = decoder.Decode()
-				if err != nil {
-					return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo argument error: %v", err)
+			frame.attachmentsDecoded = true
+			for key, val := range attachments {
+				meta[key] = val
+				meta[AttachmentHeaderPrefix+key] = val
+				// we should use interface value,
+				// convenient for us to do service discovery.
+				if key == InterfaceNameHeader {
+					meta[ServiceNameHeader] = val
 				}
 			}
+		}
+	}
 
-			field, err = decoder.Decode()
-			if err != nil {
-				return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo attachments error: %v", err)
-			}
+	return meta, nil
+}
+
+// decodeAttachments decodes the attachments of the request payload
+func decodeAttachments(payload []byte) (map[string]string, error) {
+	decoder := decodePool.Get().(*hessian.Decoder)
+	defer decodePool.Put(decoder)
+	decoder.Reset(payload)
+
+	// skip framework version + path + version + method
+	for i := 0; i < 4; i++ {
+		if _, err := decoder.Decode(); err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode request field fail: %v", err)
+		}
+	}
+	return readAttachments(decoder)
+}
 
-			if field != nil {
-				if origin, ok := field.(map[interface{}]interface{}); ok {
-					// we loop all attachments and check element type,
-					// we should only read string types.
-					for k, v := range origin {
-						if key, ok := k.(string); ok {
-							if val, ok := v.(string); ok {
-								meta[key] = val
-								// we should use interface value,
-								// convenient for us to do service discovery.
-								if key == InterfaceNameHeader {
-									meta[ServiceNameHeader] = val
-								}
-							}
-						}
-					}
+// readAttachments skips the method arguments and reads the attachments,
+// only the attachments with string key and value are returned.
+func readAttachments(decoder *hessian.Decoder) (attachments map[string]string, err error) {
+	// decode arguments maybe panic, when dubbo payload have complex struct
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("decode arguments error :%v\n%s", r, debug.Stack())
+		}
+	}()
+
+	field, err := decoder.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo argument types error: %v", err)
+	}
+
+	arguments := getArgumentCount(field.(string))
+	// we must skip all method arguments.
+	for i := 0; i < arguments; i++ {
+		_, err = decoder.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo argument error: %v", err)
+		}
+	}
+
+	field, err = decoder.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo attachments error: %v", err)
+	}
+
+	attachments = make(map[string]string)
+	if origin, ok := field.(map[interface{}]interface{}); ok {
+		// we loop all attachments and check element type,
+		// we should only read string types.
+		for k, v := range origin {
+			if key, ok := k.(string); ok {
+				if val, ok := v.(string); ok {
+					attachments[key] = val
 				}
 			}
 		}
 	}
-
-	return meta, nil
+	return attachments, nil
 }
 
 //  more unit test:
@@ -84,6 +84,9 @@ const (
 	VersionNameHeader          string = "version"
 	GroupNameHeader            string = "group"
 	InterfaceNameHeader        string = "interface"
+	// AttachmentHeaderPrefix is the prefix of the request attachment headers,
+	// such as "attachment.version", which can be used by the route header matcher
+	AttachmentHeaderPrefix string = "attachment."
 )
 
 const (
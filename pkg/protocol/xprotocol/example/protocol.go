@@ -87,7 +87,7 @@ func (proto proto) Decode(ctx context.Context, data types.IoBuffer) (interface{}
 	return nil, nil
 }
 
-func NewCodec() api.Protocol {
+func NewCodec() api.XProtocol {
 	return &proto{}
 }
 
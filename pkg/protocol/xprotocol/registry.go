@@ -20,6 +20,7 @@ package xprotocol
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/protocol"
@@ -73,3 +74,49 @@ func RegisterXProtocolCodec(codec api.XProtocolCodec) error {
 
 	return nil
 }
+
+// RegisterProtocol registers the protocol implemented by the codec, the codec encodes and decodes the frames,
+// replies the heartbeat for the keepalive and hijacks the failed requests.
+// The codec is shared by all the connections, so it should be stateless.
+// The codec can implement types.ProtocolMatcher to be detected by the listener with the auto protocol,
+// and api.HTTPMapping to map the response status to the http status code.
+func RegisterProtocol(name api.ProtocolName, codec api.XProtocol) error {
+	if name == "" || codec == nil {
+		return errors.New("protocol name or codec is empty, xprotocol register failed")
+	}
+	if codec.Name() != name {
+		return fmt.Errorf("protocol name %s mismatches the codec %s, xprotocol register failed", name, codec.Name())
+	}
+	return RegisterXProtocolCodec(&protocolCodec{
+		name:  name,
+		proto: codec,
+	})
+}
+
+// protocolCodec makes an api.XProtocolCodec of the registered codec
+type protocolCodec struct {
+	name  api.ProtocolName
+	proto api.XProtocol
+}
+
+func (c *protocolCodec) ProtocolName() api.ProtocolName {
+	return c.name
+}
+
+func (c *protocolCodec) NewXProtocol(_ context.Context) api.XProtocol {
+	return c.proto
+}
+
+func (c *protocolCodec) ProtocolMatch() api.ProtocolMatch {
+	if m, ok := c.proto.(types.ProtocolMatcher); ok {
+		return m.Match
+	}
+	return nil
+}
+
+func (c *protocolCodec) HTTPMapping() api.HTTPMapping {
+	if m, ok := c.proto.(api.HTTPMapping); ok {
+		return m
+	}
+	return nil
+}
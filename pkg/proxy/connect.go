@@ -0,0 +1,212 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net"
+	nethttp "net/http"
+	"strings"
+	"sync"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// isConnectRequest returns true if the downstream request is a http CONNECT request,
+// and the CONNECT tunneling is enabled
+func (s *downStream) isConnectRequest() bool {
+	if s.proxy == nil || s.proxy.config == nil || s.proxy.config.HTTPConnect == nil {
+		return false
+	}
+	if s.getDownstreamProtocol() != protocol.HTTP1 {
+		return false
+	}
+	method, err := variable.GetString(s.context, types.VarMethod)
+	return err == nil && method == nethttp.MethodConnect
+}
+
+// connectAllowed returns true if the authority of the CONNECT request matches any of the patterns
+func connectAllowed(authority string, patterns []string) bool {
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil || host == "" || port == "" {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		allowedHost, allowedPort, err := net.SplitHostPort(pattern)
+		if err != nil {
+			continue
+		}
+		if allowedPort != "*" && allowedPort != port {
+			continue
+		}
+		allowedHost = strings.ToLower(allowedHost)
+		switch {
+		case allowedHost == "*", allowedHost == host:
+			return true
+		case strings.HasPrefix(allowedHost, "*.") && strings.HasSuffix(host, allowedHost[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// connect establishes the upstream connection to the destination of the CONNECT request,
+// the connections are switched to a tunnel after the 200 response is sent to the downstream.
+func (s *downStream) connect() {
+	authority, _ := variable.GetString(s.context, types.VarHost)
+	if !connectAllowed(authority, s.proxy.config.HTTPConnect.AllowedDestinations) {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] CONNECT to %s is not allowed, proxyId: %d", authority, s.ID)
+		s.sendHijackReply(nethttp.StatusForbidden, s.downstreamReqHeaders)
+		return
+	}
+
+	data := s.proxy.clusterManager.TCPConnForCluster(s, s.snapshot)
+	if data.Connection == nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] no healthy upstream for CONNECT to %s, cluster: %s", authority, s.cluster.Name())
+		s.requestInfo.SetResponseFlag(api.NoHealthyUpstream)
+		s.sendHijackReply(api.NoHealthUpstreamCode, s.downstreamReqHeaders)
+		return
+	}
+	s.requestInfo.OnUpstreamHostSelected(data.Host)
+	s.requestInfo.SetUpstreamLocalAddress(data.Host.AddressString())
+
+	upstream := newConnectUpstream(data.Connection)
+	// the connect timeout of the cluster is applied by the connection
+	if err := data.Connection.Connect(); err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] CONNECT to %s failed: %v", authority, err)
+		s.cluster.Stats().UpstreamConnectionConFail.Inc(1)
+		s.requestInfo.SetResponseFlag(api.UpstreamConnectionFailure)
+		s.sendHijackReply(nethttp.StatusBadGateway, s.downstreamReqHeaders)
+		return
+	}
+	s.cluster.Stats().UpstreamConnectionTotal.Inc(1)
+	data.Connection.SetCollector(s.cluster.Stats().UpstreamBytesReadTotal, s.cluster.Stats().UpstreamBytesWriteTotal)
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] CONNECT to %s established, proxyId: %d, upstream: %d",
+			authority, s.ID, data.Connection.ID())
+	}
+	s.connectUpstream = upstream
+	s.sendHijackReply(nethttp.StatusOK, s.downstreamReqHeaders)
+}
+
+// connectUpstream is the upstream connection of the CONNECT request, it is used as
+// the upstream side of the tunnel. the bytes received before the tunnel starts are kept.
+type connectUpstream struct {
+	conn types.ClientConnection
+
+	mux      sync.Mutex
+	receiver types.TunnelReceiver
+	pending  buffer.IoBuffer
+	closed   bool
+}
+
+func newConnectUpstream(conn types.ClientConnection) *connectUpstream {
+	u := &connectUpstream{
+		conn: conn,
+	}
+	conn.AddConnectionEventListener(u)
+	conn.FilterManager().AddReadFilter(u)
+	return u
+}
+
+// types.TunnelStream
+func (u *connectUpstream) UpgradedConnection() (api.Connection, bool) {
+	return u.conn, true
+}
+
+func (u *connectUpstream) Tunnel(receiver types.TunnelReceiver) {
+	// the pending bytes are passed to the receiver before the bytes received afterwards
+	for {
+		u.mux.Lock()
+		pending := u.pending
+		u.pending = nil
+		if pending == nil {
+			u.receiver = receiver
+			closed := u.closed
+			u.mux.Unlock()
+			if closed {
+				receiver.OnTunnelClose()
+			}
+			return
+		}
+		u.mux.Unlock()
+		receiver.OnTunnelData(pending)
+	}
+}
+
+// closeIfNotTunneled closes the upstream connection if the tunnel is not started
+func (u *connectUpstream) closeIfNotTunneled() {
+	u.mux.Lock()
+	tunneled := u.receiver != nil
+	u.mux.Unlock()
+	if !tunneled {
+		u.conn.Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+// api.ReadFilter
+func (u *connectUpstream) OnData(data buffer.IoBuffer) api.FilterStatus {
+	// the read buffer is reused by the connection, copy it
+	b := buffer.NewIoBufferBytes(append([]byte(nil), data.Bytes()...))
+	data.Drain(data.Len())
+
+	u.mux.Lock()
+	receiver := u.receiver
+	if receiver == nil {
+		if u.pending == nil {
+			u.pending = buffer.NewIoBuffer(b.Len())
+		}
+		u.pending.Write(b.Bytes())
+	}
+	u.mux.Unlock()
+
+	if receiver != nil {
+		receiver.OnTunnelData(b)
+	}
+	return api.Stop
+}
+
+func (u *connectUpstream) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (u *connectUpstream) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
+
+// api.ConnectionEventListener
+func (u *connectUpstream) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	u.mux.Lock()
+	receiver := u.receiver
+	if receiver == nil {
+		u.closed = true
+	}
+	u.mux.Unlock()
+
+	if receiver != nil {
+		receiver.OnTunnelClose()
+	}
+}
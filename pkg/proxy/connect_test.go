@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+func TestConnectAllowed(t *testing.T) {
+	patterns := []string{"www.example.com:443", "*.example.org:443", "api.example.net:*"}
+	for _, tc := range []struct {
+		authority string
+		allowed   bool
+	}{
+		{"www.example.com:443", true},
+		{"WWW.Example.com:443", true},
+		{"www.example.com:80", false},
+		{"foo.example.org:443", true},
+		{"foo.bar.example.org:443", true},
+		{"example.org:443", false},
+		{"api.example.net:8080", true},
+		{"www.example.net:8080", false},
+		{"www.example.com", false},
+		{"", false},
+	} {
+		assert.Equal(t, tc.allowed, connectAllowed(tc.authority, patterns), tc.authority)
+	}
+	assert.True(t, connectAllowed("10.0.0.1:22", []string{"*:*"}))
+	assert.False(t, connectAllowed("10.0.0.1:22", nil))
+}
+
+func TestConnectDenied(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, types.VarHost, "www.example.com:22")
+	s := &downStream{
+		context: ctx,
+		proxy: &proxy{
+			config: &v2.Proxy{
+				HTTPConnect: &v2.HTTPConnectConfig{
+					AllowedDestinations: []string{"www.example.com:443"},
+				},
+			},
+		},
+		requestInfo: &network.RequestInfo{},
+	}
+	s.connect()
+	assert.True(t, s.directResponse)
+	assert.Equal(t, http.StatusForbidden, s.requestInfo.ResponseCode())
+	assert.Nil(t, s.connectUpstream)
+}
+
+type mockClientConnection struct {
+	*mock.MockConnection
+}
+
+func (c *mockClientConnection) Connect() error {
+	return nil
+}
+
+// mockTunnelReceiver records the bytes and close of the tunnel
+type mockTunnelReceiver struct {
+	data   string
+	closed bool
+}
+
+func (r *mockTunnelReceiver) OnTunnelData(data buffer.IoBuffer) {
+	r.data += data.String()
+}
+
+func (r *mockTunnelReceiver) OnTunnelClose() {
+	r.closed = true
+}
+
+func TestConnectUpstreamTunnel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := &connectUpstream{
+		conn: &mockClientConnection{mock.NewMockConnection(ctrl)},
+	}
+	conn, upgraded := u.UpgradedConnection()
+	assert.True(t, upgraded)
+	assert.Equal(t, u.conn, conn)
+
+	// the bytes received before the tunnel starts are kept
+	data := buffer.NewIoBufferString("hello ")
+	assert.Equal(t, api.Stop, u.OnData(data))
+	assert.Equal(t, 0, data.Len())
+	receiver := &mockTunnelReceiver{}
+	u.Tunnel(receiver)
+	u.OnData(buffer.NewIoBufferString("world"))
+	assert.Equal(t, "hello world", receiver.data)
+	assert.False(t, receiver.closed)
+	u.OnEvent(api.RemoteClose)
+	assert.True(t, receiver.closed)
+
+	// the close before the tunnel starts is passed to the receiver
+	u = &connectUpstream{
+		conn: &mockClientConnection{mock.NewMockConnection(ctrl)},
+	}
+	u.OnEvent(api.Connected)
+	u.OnEvent(api.RemoteClose)
+	receiver = &mockTunnelReceiver{}
+	u.Tunnel(receiver)
+	assert.True(t, receiver.closed)
+}
+
+func TestConnectUpstreamNotTunneled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock.NewMockConnection(ctrl)
+	conn.EXPECT().Close(api.NoFlush, api.LocalClose).Return(nil).Times(1)
+	u := &connectUpstream{
+		conn: &mockClientConnection{conn},
+	}
+	u.closeIfNotTunneled()
+
+	// the tunneled connection is closed by the tunnel
+	u.Tunnel(&mockTunnelReceiver{})
+	u.closeIfNotTunneled()
+}
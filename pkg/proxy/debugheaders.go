@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"sync"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	headerDebugUpstreamHost = headerUpstreamHost
+	headerDebugCluster      = "x-mosn-cluster"
+	headerDebugRoute        = "x-mosn-route"
+)
+
+var debugHeaders = []string{headerDebugUpstreamHost, headerDebugCluster, headerDebugRoute}
+
+// debugHeadersTrusts caches the parsed debug headers config by listener name
+var debugHeadersTrusts sync.Map
+
+// debugHeadersTrust checks whether the debug request header of a request is trusted
+type debugHeadersTrust struct {
+	config *v2.Proxy
+	header string
+	value  []byte
+	cidrs  []*net.IPNet
+	// err is the error of the config, no request header is trusted then
+	err error
+}
+
+// getDebugHeadersTrust returns the debug request header check of the listener,
+// nil means no request header is configured
+func getDebugHeadersTrust(listenerName string, config *v2.Proxy) *debugHeadersTrust {
+	if config.DebugHeaders == nil || config.DebugHeaders.RequestHeader == "" {
+		debugHeadersTrusts.Delete(listenerName)
+		return nil
+	}
+	if v, ok := debugHeadersTrusts.Load(listenerName); ok {
+		if t := v.(*debugHeadersTrust); t.config == config {
+			return t
+		}
+	}
+	t := newDebugHeadersTrust(config)
+	if t.err != nil {
+		log.DefaultLogger.Alertf("proxy.config", "[proxy] listener %s debug request header is not trusted: %v", listenerName, t.err)
+	}
+	debugHeadersTrusts.Store(listenerName, t)
+	return t
+}
+
+func newDebugHeadersTrust(config *v2.Proxy) *debugHeadersTrust {
+	cfg := config.DebugHeaders
+	t := &debugHeadersTrust{
+		config: config,
+		header: cfg.RequestHeader,
+		value:  []byte(cfg.RequestHeaderValue),
+		cidrs:  make([]*net.IPNet, 0, len(cfg.TrustedSourceCIDRs)),
+	}
+	if cfg.RequestHeaderValue == "" && len(cfg.TrustedSourceCIDRs) == 0 {
+		t.err = errors.New("neither request_header_value nor trusted_source_cidrs is configured")
+		return t
+	}
+	for _, cidr := range cfg.TrustedSourceCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.err = err
+			return t
+		}
+		t.cidrs = append(t.cidrs, ipNet)
+	}
+	return t
+}
+
+// trusted returns true if the request header has the configured value and the request is from the trusted sources
+func (t *debugHeadersTrust) trusted(headers types.HeaderMap, remote net.Addr) bool {
+	if t == nil || t.err != nil || headers == nil {
+		return false
+	}
+	value, ok := headers.Get(t.header)
+	if !ok {
+		return false
+	}
+	if len(t.value) > 0 && subtle.ConstantTimeCompare([]byte(value), t.value) != 1 {
+		return false
+	}
+	if len(t.cidrs) > 0 {
+		ip := addrIP(remote)
+		if ip == nil {
+			return false
+		}
+		for _, ipNet := range t.cidrs {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case nil:
+		return nil
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// debugHeadersEnabled returns true if the debug headers are configured for the http requests
+func (s *downStream) debugHeadersEnabled() bool {
+	if s.proxy == nil || s.proxy.config == nil || s.proxy.config.DebugHeaders == nil {
+		return false
+	}
+	switch s.getDownstreamProtocol() {
+	case protocol.HTTP1, protocol.HTTP2:
+		return true
+	}
+	return false
+}
+
+// requestDebugHeadersTrusted returns true if the request has the trusted debug request header,
+// the result is kept for the request
+func (s *downStream) requestDebugHeadersTrusted() bool {
+	if !s.debugHeadersChecked {
+		s.debugHeadersChecked = true
+		s.debugHeadersTrusted = s.proxy.debugHeaders.trusted(s.downstreamReqHeaders, s.requestInfo.DownstreamRemoteAddress())
+	}
+	return s.debugHeadersTrusted
+}
+
+// removeRequestDebugHeaders strips the client supplied debug headers before the request is sent to the upstream,
+// it is called after the upstream host is chosen, so the trusted x-mosn-upstream-host is already used.
+// the debug request header is stripped as well if it is not trusted.
+func (s *downStream) removeRequestDebugHeaders() {
+	if !s.debugHeadersEnabled() || s.downstreamReqHeaders == nil {
+		return
+	}
+	for _, h := range debugHeaders {
+		s.downstreamReqHeaders.Del(h)
+	}
+	if h := s.proxy.config.DebugHeaders.RequestHeader; h != "" && !s.requestDebugHeadersTrusted() {
+		s.downstreamReqHeaders.Del(h)
+	}
+}
+
+// setResponseDebugHeaders adds the upstream host, cluster and route of the request to the response headers
+// if the debug headers are enabled for the listener or the request has the trusted header.
+// the values in the response from the upstream are always stripped.
+func (s *downStream) setResponseDebugHeaders(headers types.HeaderMap) {
+	if !s.debugHeadersEnabled() || headers == nil {
+		return
+	}
+	for _, h := range debugHeaders {
+		headers.Del(h)
+	}
+	if !s.proxy.config.DebugHeaders.Enabled && !s.requestDebugHeadersTrusted() {
+		return
+	}
+	if host := s.requestInfo.UpstreamHost(); host != nil {
+		headers.Set(headerDebugUpstreamHost, host.AddressString())
+	}
+	if recorder, ok := s.requestInfo.(types.UpstreamClusterRecorder); ok && recorder.UpstreamClusterName() != "" {
+		headers.Set(headerDebugCluster, recorder.UpstreamClusterName())
+	}
+	if rule := s.requestInfo.RouteEntry(); rule != nil {
+		route := rule.Matcher()
+		if vh := rule.VirtualHost(); vh != nil {
+			route = vh.Name() + ":" + route
+		}
+		headers.Set(headerDebugRoute, route)
+	}
+}
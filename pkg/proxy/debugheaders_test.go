@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+func TestDebugHeaders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vh := mock.NewMockVirtualHost(ctrl)
+	vh.EXPECT().Name().Return("test-vh").AnyTimes()
+	rule := mock.NewMockRouteRule(ctrl)
+	rule.EXPECT().VirtualHost().Return(vh).AnyTimes()
+	rule.EXPECT().Matcher().Return("/foo").AnyTimes()
+	host := gomockHedgeHost(ctrl, "127.0.0.1:8080", nil)
+
+	newDownstream := func(config *v2.DebugHeadersConfig, proto types.ProtocolName, headers types.HeaderMap) *downStream {
+		info := network.NewRequestInfo()
+		info.OnUpstreamHostSelected(host)
+		info.SetRouteEntry(rule)
+		info.(types.UpstreamClusterRecorder).SetUpstreamClusterName("test-cluster")
+		info.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 12345})
+		p := &proxy{
+			config: &v2.Proxy{
+				DownstreamProtocol: string(proto),
+				DebugHeaders:       config,
+			},
+		}
+		if config != nil && config.RequestHeader != "" {
+			p.debugHeaders = newDebugHeadersTrust(p.config)
+		}
+		return &downStream{
+			context:              variable.NewVariableContext(context.Background()),
+			proxy:                p,
+			requestInfo:          info,
+			downstreamReqHeaders: headers,
+		}
+	}
+	assertDebugHeaders := func(t *testing.T, headers types.HeaderMap, expected bool) {
+		for h, v := range map[string]string{
+			headerDebugUpstreamHost: "127.0.0.1:8080",
+			headerDebugCluster:      "test-cluster",
+			headerDebugRoute:        "test-vh:/foo",
+		} {
+			value, ok := headers.Get(h)
+			assert.Equal(t, expected, ok, h)
+			if expected {
+				assert.Equal(t, v, value)
+			}
+		}
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		reqHeaders := protocol.CommonHeader{headerDebugCluster: "fake"}
+		s := newDownstream(nil, protocol.HTTP1, reqHeaders)
+		s.removeRequestDebugHeaders()
+		assert.Equal(t, "fake", reqHeaders[headerDebugCluster])
+		respHeaders := protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, false)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		reqHeaders := protocol.CommonHeader{
+			headerDebugUpstreamHost: "10.0.0.1:80",
+			headerDebugCluster:      "fake",
+			headerDebugRoute:        "fake",
+		}
+		s := newDownstream(&v2.DebugHeadersConfig{Enabled: true}, protocol.HTTP2, reqHeaders)
+		// the client supplied values are not sent to the upstream
+		s.removeRequestDebugHeaders()
+		assert.Empty(t, reqHeaders)
+		respHeaders := protocol.CommonHeader{headerDebugCluster: "fake"}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, true)
+	})
+
+	t.Run("trusted request header", func(t *testing.T) {
+		config := &v2.DebugHeadersConfig{RequestHeader: "x-mosn-debug", RequestHeaderValue: "secret"}
+		reqHeaders := protocol.CommonHeader{"x-mosn-debug": "secret"}
+		s := newDownstream(config, protocol.HTTP1, reqHeaders)
+		s.removeRequestDebugHeaders()
+		assert.Equal(t, "secret", reqHeaders["x-mosn-debug"])
+		respHeaders := protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, true)
+
+		// the header with other values is not trusted, and it is not sent to the upstream
+		reqHeaders = protocol.CommonHeader{"x-mosn-debug": "1"}
+		s = newDownstream(config, protocol.HTTP1, reqHeaders)
+		s.removeRequestDebugHeaders()
+		assert.Empty(t, reqHeaders)
+		respHeaders = protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, false)
+
+		// the values from the upstream are stripped without the trusted header
+		s = newDownstream(config, protocol.HTTP1, protocol.CommonHeader{})
+		respHeaders = protocol.CommonHeader{headerDebugUpstreamHost: "fake", headerDebugRoute: "fake"}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, false)
+	})
+
+	t.Run("trusted source cidrs", func(t *testing.T) {
+		config := &v2.DebugHeadersConfig{RequestHeader: "x-mosn-debug", TrustedSourceCIDRs: []string{"10.1.0.0/16"}}
+		s := newDownstream(config, protocol.HTTP1, protocol.CommonHeader{"x-mosn-debug": "1"})
+		respHeaders := protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, true)
+
+		config = &v2.DebugHeadersConfig{RequestHeader: "x-mosn-debug", TrustedSourceCIDRs: []string{"192.168.0.0/16"}}
+		s = newDownstream(config, protocol.HTTP1, protocol.CommonHeader{"x-mosn-debug": "1"})
+		respHeaders = protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, false)
+
+		// both the value and the source should match if both are configured
+		config = &v2.DebugHeadersConfig{RequestHeader: "x-mosn-debug", RequestHeaderValue: "secret", TrustedSourceCIDRs: []string{"10.1.0.0/16"}}
+		s = newDownstream(config, protocol.HTTP1, protocol.CommonHeader{"x-mosn-debug": "1"})
+		respHeaders = protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, false)
+	})
+
+	t.Run("request header only is not trusted", func(t *testing.T) {
+		for _, config := range []*v2.DebugHeadersConfig{
+			{RequestHeader: "x-mosn-debug"},
+			{RequestHeader: "x-mosn-debug", TrustedSourceCIDRs: []string{"invalid"}},
+		} {
+			reqHeaders := protocol.CommonHeader{"x-mosn-debug": "1"}
+			s := newDownstream(config, protocol.HTTP1, reqHeaders)
+			s.removeRequestDebugHeaders()
+			assert.Empty(t, reqHeaders)
+			respHeaders := protocol.CommonHeader{}
+			s.setResponseDebugHeaders(respHeaders)
+			assertDebugHeaders(t, respHeaders, false)
+		}
+	})
+
+	t.Run("not http", func(t *testing.T) {
+		s := newDownstream(&v2.DebugHeadersConfig{Enabled: true}, types.ProtocolName("bolt"), protocol.CommonHeader{})
+		respHeaders := protocol.CommonHeader{}
+		s.setResponseDebugHeaders(respHeaders)
+		assertDebugHeaders(t, respHeaders, false)
+	})
+}
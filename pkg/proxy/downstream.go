@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"runtime/debug"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -34,8 +35,10 @@ import (
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/track"
 	"mosn.io/mosn/pkg/types"
@@ -61,12 +64,41 @@ type downStream struct {
 	// ~~~ control args
 	timeout    Timeout
 	retryState *retryState
+	// the hosts tried by the previous attempts, the retries prefer the other hosts
+	previousHosts []types.Host
 
 	requestInfo     types.RequestInfo
 	responseSender  types.StreamSender
 	upstreamRequest *upstreamRequest
+	// the upstream connection of the CONNECT request
+	connectUpstream *connectUpstream
 	perRetryTimer   *utils.Timer
 	responseTimer   *utils.Timer
+	// responseDeadline is the time the global timeout is reached, zero means no global timeout
+	responseDeadline time.Time
+
+	// ~~~ hedging
+	// the hedged request sent if the upstream request has not responded in the hedge delay
+	hedgeRequest *upstreamRequest
+	hedgeTimer   *utils.Timer
+	// hedgeTriggered is set by the hedge timer, the hedged request is sent in the proxy goroutine
+	hedgeTriggered uint32
+	// hedgeInflight is the number of the hedged requests in flight
+	hedgeInflight int32
+	// the buffer pool contexts of the hedged requests, given back when the stream is cleaned
+	hedgeContexts []context.Context
+
+	// the concurrency limiter of the cluster, it is released with the request latency
+	concurrencyLimiter  types.ConcurrencyLimiter
+	concurrencyAcquired time.Time
+	// the admission queue of the cluster, it is released when the request is finished
+	admissionQueue types.AdmissionQueue
+	// the circuit breaker of the request's priority, it is released when the request is finished.
+	// circuitBreakerPending is true until the upstream stream is ready,
+	// and circuitBreakerProbe is true if the request probes the half open circuit breaker.
+	circuitBreaker        types.CircuitBreaker
+	circuitBreakerPending bool
+	circuitBreakerProbe   bool
 
 	// ~~~ downstream request buf
 	downstreamReqHeaders  types.HeaderMap
@@ -109,6 +141,21 @@ type downStream struct {
 	// stream filter chain
 	streamFilterChain         streamFilterChain
 	receiverFiltersAgainPhase types.Phase
+	// the dynamic metadata shared by the stream filters, namespace -> key -> value
+	dynamicMetadata   map[string]map[string]interface{}
+	senderFilterPhase api.SenderFilterPhase
+	// times of the receiver filters redo the route match or the host choose
+	reMatchRouteTimes int
+	reChooseHostTimes int
+	// debugHeadersChecked and debugHeadersTrusted keep the result of the trusted debug request header check
+	debugHeadersChecked bool
+	debugHeadersTrusted bool
+	// the state of the receiver filters holding the stream, protected by receiverFilterMux
+	receiverFilterMux   sync.Mutex
+	receiverFilterState receiverFilterState
+	// the filter holds the stream, and the filter resumes the stream before it is held
+	heldReceiverFilter    *streamReceiverFilterHandler
+	resumedReceiverFilter *streamReceiverFilterHandler
 
 	context context.Context
 	tracks  *track.Tracks
@@ -118,6 +165,9 @@ type downStream struct {
 
 	snapshot types.ClusterSnapshot
 
+	// grpcMessages records the messages of the grpc request and response, nil means not a grpc request
+	grpcMessages *grpcMessageRecorder
+
 	phase types.Phase
 }
 
@@ -184,18 +234,168 @@ func (s *downStream) receiverFilterStatusHandler(phase api.ReceiverFilterPhase,
 	case api.StreamFilterReMatchRoute:
 		// Retry only at the AfterRoute phase
 		if phase == api.AfterRoute {
+			if s.reMatchRouteTimes >= s.maxReMatchRoute() {
+				log.Proxy.Warnf(s.context, "[proxy] [downstream] rematch route exceeded, times: %d, proxyId: %d", s.reMatchRouteTimes, s.ID)
+				s.proxy.stats.ReMatchRouteExceeded.Inc(1)
+				s.proxy.listenerStats.ReMatchRouteExceeded.Inc(1)
+				return
+			}
+			s.reMatchRouteTimes++
 			// FiltersIndex is not increased until no retry is required
 			s.receiverFiltersAgainPhase = types.MatchRoute
 		}
 	case api.StreamFilterReChooseHost:
 		// Retry only at the AfterChooseHost phase
 		if phase == api.AfterChooseHost {
+			if s.reChooseHostTimes >= s.maxReChooseHost() {
+				log.Proxy.Warnf(s.context, "[proxy] [downstream] rechoose host exceeded, times: %d, proxyId: %d", s.reChooseHostTimes, s.ID)
+				s.proxy.stats.ReChooseHostExceeded.Inc(1)
+				s.proxy.listenerStats.ReChooseHostExceeded.Inc(1)
+				return
+			}
+			s.reChooseHostTimes++
 			// FiltersIndex is not increased until no retry is required
 			s.receiverFiltersAgainPhase = types.ChooseHost
 		}
 	}
 }
 
+func (s *downStream) maxReMatchRoute() int {
+	if s.proxy.config != nil && s.proxy.config.MaxReMatchRoute > 0 {
+		return s.proxy.config.MaxReMatchRoute
+	}
+	return defaultMaxReMatchRoute
+}
+
+func (s *downStream) maxReChooseHost() int {
+	if s.proxy.config != nil && s.proxy.config.MaxReChooseHost > 0 {
+		return s.proxy.config.MaxReChooseHost
+	}
+	return defaultMaxReChooseHost
+}
+
+// runReceiverFilter invokes the receiver filters of the phase.
+// if a filter returns StreamFilterStop without ending the stream and the phase timeout is configured,
+// the stream is held until the filter resumes it by ContinueReceiving or ends it by SendHijackReply,
+// or a 504 is replied when the phase timeout is reached. the resumed stream runs the following filters of the phase.
+// without the phase timeout, the stream is not held, the same as the filter stops the phase.
+func (s *downStream) runReceiverFilter(phase api.ReceiverFilterPhase) {
+	s.setReceiverFilterState(receiverFilterRunning)
+	defer s.setReceiverFilterState(receiverFilterIdle)
+
+	for {
+		status := s.streamFilterChain.RunReceiverFilter(s.context, phase,
+			s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers, s.receiverFilterStatusHandler)
+		// the filter asks for redo but not allowed, such as retry budget exceeded or in an unexpected phase,
+		// treats it as StreamFilterContinue
+		if (status == api.StreamFilterReMatchRoute || status == api.StreamFilterReChooseHost) &&
+			s.receiverFiltersAgainPhase == types.InitPhase {
+			s.streamFilterChain.SkipReceiverFilter()
+			continue
+		}
+		if status != api.StreamFilterStop || !s.holdReceiverFilter(phase) {
+			return
+		}
+		s.streamFilterChain.ResumeReceiverFilter()
+	}
+}
+
+func (s *downStream) setReceiverFilterState(state receiverFilterState) {
+	s.receiverFilterMux.Lock()
+	s.receiverFilterState = state
+	s.heldReceiverFilter = nil
+	s.resumedReceiverFilter = nil
+	s.receiverFilterMux.Unlock()
+}
+
+// receiverFilterTimeout returns how long a receiver filter can hold the stream in the phase,
+// zero means the stream is not held.
+func (s *downStream) receiverFilterTimeout(phase api.ReceiverFilterPhase) time.Duration {
+	if s.proxy.config == nil {
+		return 0
+	}
+	return s.proxy.config.ReceiverFilterTimeout.PhaseTimeout(phase)
+}
+
+// holdReceiverFilter holds the stream stopped by a receiver filter,
+// returns true if the filter resumes the stream by ContinueReceiving.
+func (s *downStream) holdReceiverFilter(phase api.ReceiverFilterPhase) bool {
+	handler := s.streamFilterChain.stoppedReceiverHandler()
+
+	s.receiverFilterMux.Lock()
+	// the filter has already ended the stream
+	if handler == nil || s.directResponse || atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+		s.receiverFilterMux.Unlock()
+		return false
+	}
+	// the filter has already resumed the stream
+	if s.resumedReceiverFilter == handler {
+		s.resumedReceiverFilter = nil
+		handler.holdEnded = true
+		s.receiverFilterMux.Unlock()
+		return true
+	}
+	timeout := s.receiverFilterTimeout(phase)
+	if timeout <= 0 {
+		// the hold is disabled
+		s.receiverFilterMux.Unlock()
+		return false
+	}
+	s.receiverFilterState = receiverFilterHeld
+	s.heldReceiverFilter = handler
+	s.receiverFilterMux.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		timedOut := false
+		select {
+		case <-s.notify:
+		case <-timer.C:
+			timedOut = true
+		}
+
+		s.receiverFilterMux.Lock()
+		if s.receiverFilterState == receiverFilterHeld {
+			switch {
+			case timedOut:
+				s.onReceiverFilterTimeout(phase, timeout)
+			case s.directResponse || atomic.LoadUint32(&s.downstreamReset) == 1 ||
+				atomic.LoadUint32(&s.downstreamCleaned) == 1:
+				// the stream is ended by others, such as the downstream reset
+			default:
+				// not woken up by the filter, keeps holding
+				s.receiverFilterMux.Unlock()
+				continue
+			}
+			handler.holdEnded = true
+			s.receiverFilterState = receiverFilterRunning
+			s.heldReceiverFilter = nil
+			s.receiverFilterMux.Unlock()
+			return false
+		}
+		// the filter resumes or ends the stream
+		if timedOut {
+			// the notify sent by the filter is not consumed
+			s.cleanNotify()
+		}
+		resumed := !s.directResponse && atomic.LoadUint32(&s.downstreamCleaned) == 0
+		s.receiverFilterState = receiverFilterRunning
+		s.heldReceiverFilter = nil
+		s.receiverFilterMux.Unlock()
+		return resumed
+	}
+}
+
+func (s *downStream) onReceiverFilterTimeout(phase api.ReceiverFilterPhase, timeout time.Duration) {
+	log.Proxy.Errorf(s.context, "[proxy] [downstream] receiver filter timeout, phase: %v, timeout: %s, proxyId: %d", phase, timeout, s.ID)
+
+	s.proxy.stats.DownstreamFilterTimeout.Inc(1)
+	s.proxy.listenerStats.DownstreamFilterTimeout.Inc(1)
+
+	s.sendHijackReply(api.TimeoutExceptionCode, s.downstreamReqHeaders)
+}
+
 func (s *downStream) senderFilterStatusHandler(phase api.SenderFilterPhase, status api.StreamFilterStatus) {
 	if status == api.StreamFiltertermination {
 		// no reuse buffer
@@ -210,12 +410,24 @@ func (s *downStream) endStream() {
 		// not reuse buffer
 		atomic.StoreUint32(&s.reuseBuffer, 0)
 	}
+	s.runAfterSendFilter()
 	s.cleanStream()
 
 	// note: if proxy logic resets the stream, there maybe some underlying data in the conn.
 	// we ignore this for now, fix as a todo
 }
 
+// runAfterSendFilter invokes the sender filters registered at the AfterSend phase,
+// the response is already flushed, so the filter status is ignored.
+func (s *downStream) runAfterSendFilter() {
+	if atomic.LoadUint32(&s.downstreamCleaned) == 1 || s.streamFilterChain.DefaultStreamFilterChainImpl == nil {
+		return
+	}
+	s.senderFilterPhase = streamfilter.AfterSend
+	s.streamFilterChain.RunSenderFilter(s.context, streamfilter.AfterSend,
+		s.downstreamRespHeaders, s.downstreamRespDataBuf, s.downstreamRespTrailers, nil)
+}
+
 // Clean up on the very end of the stream: end stream or reset stream
 // Resources to clean up / reset:
 // 	+ upstream request
@@ -242,6 +454,12 @@ func (s *downStream) cleanStream() {
 		s.upstreamProcessDone.Store(true)
 		s.upstreamRequest.resetStream()
 	}
+	if s.hedgeRequest != nil {
+		s.hedgeRequest.cancel()
+	}
+	if s.connectUpstream != nil {
+		s.connectUpstream.closeIfNotTunneled()
+	}
 
 	// clean up timers
 	s.cleanUp()
@@ -259,9 +477,15 @@ func (s *downStream) cleanStream() {
 	// after this func call, we should never touch the s.streamFilterChain
 	s.streamFilterChain.destroy()
 
+	// the dynamic metadata is not used after the filters destroyed
+	s.dynamicMetadata = nil
+
 	// delete stream reference
 	s.delete()
 
+	// the hedged requests do not share the stream buffers
+	s.giveHedgeContexts()
+
 	// recycle if no reset events
 	s.giveStream()
 }
@@ -287,10 +511,11 @@ func (s *downStream) requestMetrics() {
 		s.proxy.listenerStats.DownstreamProcessTime.Update(processTime)
 		s.proxy.listenerStats.DownstreamProcessTimeTotal.Inc(processTime)
 
-		s.proxy.stats.DownstreamRequestTime.Update(streamDurationNs)
+		traceId := exemplarTraceId(s.context)
+		metrics.UpdateWithExemplar(s.proxy.stats.DownstreamRequestTime, streamDurationNs, traceId)
 		s.proxy.stats.DownstreamRequestTimeTotal.Inc(streamDurationNs)
 
-		s.proxy.listenerStats.DownstreamRequestTime.Update(streamDurationNs)
+		metrics.UpdateWithExemplar(s.proxy.listenerStats.DownstreamRequestTime, streamDurationNs, traceId)
 		s.proxy.listenerStats.DownstreamRequestTimeTotal.Inc(streamDurationNs)
 
 		s.proxy.stats.DownstreamUpdateRequestCode(s.requestInfo.ResponseCode())
@@ -301,6 +526,8 @@ func (s *downStream) requestMetrics() {
 			s.proxy.listenerStats.DownstreamRequestFailed.Inc(1)
 		}
 
+		s.labeledRequestMetrics(streamDurationNs, traceId)
+
 		s.requestInfo.SetProcessTimeDuration(time.Duration(processTime))
 
 	}
@@ -309,6 +536,24 @@ func (s *downStream) requestMetrics() {
 	s.proxy.listenerStats.DownstreamRequestActive.Dec(1)
 }
 
+// labeledRequestMetrics records the request metrics with the labels derived from the request headers
+func (s *downStream) labeledRequestMetrics(streamDurationNs int64, traceId string) {
+	if s.proxy.metricsLabeler == nil {
+		return
+	}
+	stats := s.proxy.metricsLabeler.getStats(s.downstreamReqHeaders)
+	if stats == nil {
+		return
+	}
+	stats.DownstreamRequestTotal.Inc(1)
+	metrics.UpdateWithExemplar(stats.DownstreamRequestTime, streamDurationNs, traceId)
+	stats.DownstreamRequestTimeTotal.Inc(streamDurationNs)
+	stats.DownstreamUpdateRequestCode(s.requestInfo.ResponseCode())
+	if s.isRequestFailed() {
+		stats.DownstreamRequestFailed.Inc(1)
+	}
+}
+
 // isRequestFailed marks request failed due to mosn process
 func (s *downStream) isRequestFailed() bool {
 	return s.requestInfo.GetResponseFlag(types.MosnProcessFailedFlags)
@@ -327,15 +572,34 @@ func (s *downStream) writeLog() {
 	}
 	// proxy access log
 	if s.proxy != nil && s.proxy.accessLogs != nil {
-		for _, al := range s.proxy.accessLogs {
-			al.Log(s.context, s.downstreamReqHeaders, s.downstreamRespHeaders, s.requestInfo)
-		}
+		s.writeProxyLog()
 	}
 
 	// per-stream access log
 	s.streamFilterChain.Log(s.context, s.downstreamReqHeaders, s.downstreamRespHeaders, s.requestInfo)
 }
 
+// writeProxyLog logs by the proxy access logs, the matched route can disable the logs
+// or choose a named format of the access logs
+func (s *downStream) writeProxyLog() {
+	var formatName string
+	if s.route != nil {
+		if rule, ok := s.route.RouteRule().(types.AccessLogRule); ok {
+			if rule.AccessLogDisabled() {
+				return
+			}
+			formatName = rule.AccessLogFormatName()
+		}
+	}
+	for _, al := range s.proxy.accessLogs {
+		if nal, ok := al.(log.NamedFormatAccessLog); ok && formatName != "" {
+			nal.LogWithFormat(formatName, s.context, s.downstreamReqHeaders, s.downstreamRespHeaders, s.requestInfo)
+			continue
+		}
+		al.Log(s.context, s.downstreamReqHeaders, s.downstreamRespHeaders, s.requestInfo)
+	}
+}
+
 func (s *downStream) delete() {
 	if s.proxy != nil {
 		s.proxy.deleteActiveStream(s)
@@ -449,6 +713,12 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 		// init phase
 		case types.InitPhase:
 			s.printPhaseInfo(phase, id)
+			// the filters which read the full body can not handle a streamed body
+			if s.requestBodyStreamed() && s.filterNeedRequestBody() && !s.bufferRequestBody() {
+				if p, err := s.processError(id); err != nil {
+					return p
+				}
+			}
 			phase++
 
 		// downstream filter before route
@@ -456,8 +726,7 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 			s.printPhaseInfo(phase, id)
 			s.tracks.StartTrack(track.StreamFilterBeforeRoute)
 
-			s.streamFilterChain.RunReceiverFilter(s.context, api.BeforeRoute,
-				s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers, s.receiverFilterStatusHandler)
+			s.runReceiverFilter(api.BeforeRoute)
 			s.tracks.EndTrack(track.StreamFilterBeforeRoute)
 
 			if p, err := s.processError(id); err != nil {
@@ -483,8 +752,7 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 			s.printPhaseInfo(phase, id)
 
 			s.tracks.StartTrack(track.StreamFilterAfterRoute)
-			s.streamFilterChain.RunReceiverFilter(s.context, api.AfterRoute,
-				s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers, s.receiverFilterStatusHandler)
+			s.runReceiverFilter(api.AfterRoute)
 			s.tracks.EndTrack(track.StreamFilterAfterRoute)
 
 			if p, err := s.processError(id); err != nil {
@@ -511,8 +779,7 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 			s.printPhaseInfo(phase, id)
 
 			s.tracks.StartTrack(track.StreamFilterAfterChooseHost)
-			s.streamFilterChain.RunReceiverFilter(s.context, api.AfterChooseHost,
-				s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers, s.receiverFilterStatusHandler)
+			s.runReceiverFilter(api.AfterChooseHost)
 			s.tracks.EndTrack(track.StreamFilterAfterChooseHost)
 
 			if p, err := s.processError(id); err != nil {
@@ -686,12 +953,146 @@ func (s *downStream) matchRoute() {
 
 	// get router instance and do routing
 	routers := s.proxy.routersWrapper.GetRouters()
+	// the request body is buffered before routing if any route matches the body,
+	// the larger body is not matched by the body matchers.
+	if br, ok := routers.(types.RequestBodyRouters); ok && s.downstreamReqDataBuf != nil {
+		if limit := br.MaxRequestBodyMatchBytes(s.context); limit > 0 {
+			if body, ok := s.peekRequestBody(limit); ok {
+				_ = variable.Set(s.context, types.VarRouterRequestBody, body)
+			}
+		}
+	}
 	// call route handler to get route info
 	s.snapshot, s.route = s.proxy.routeHandlerFactory.DoRouteHandler(s.context, headers, routers, s.proxy.clusterManager)
 
 	// set RouteEntry so that it can be accessed in stream filters of api.AfterRoute phase.
 	if s.route != nil {
 		s.requestInfo.SetRouteEntry(s.route.RouteRule())
+		s.chooseCanaryCluster()
+		s.recordUpstreamCluster()
+		// the streamed request body is kept streaming unless the route requires it to be buffered
+		if s.routeBufferRequestBody() && !s.bufferRequestBody() {
+			return
+		}
+		// the request body is checked as soon as the route is known, before any upstream request is made
+		s.checkRequestBodyLimit()
+	}
+}
+
+// chooseCanaryCluster replaces the route cluster with the canary cluster of the route.
+// the requests matched the canary headers are always sent to the canary cluster, and then
+// the percentage of the other requests is chosen by the hash key, the decision is recorded on the request info.
+func (s *downStream) chooseCanaryCluster() {
+	rule, ok := s.route.RouteRule().(types.CanaryRule)
+	if !ok {
+		return
+	}
+	policy := rule.CanaryPolicy()
+	if policy == nil {
+		return
+	}
+	decision := types.CanaryPrimary
+	if policy.MatchHeaders(s.context, s.downstreamReqHeaders) {
+		decision = types.CanaryByHeader
+	} else if policy.InPercentage(s.canaryHashKey(policy)) {
+		decision = types.CanaryByPercentage
+	}
+	if recorder, ok := s.requestInfo.(types.CanaryRecorder); ok {
+		recorder.SetCanaryDecision(decision)
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] canary decision: %s, canary cluster: %s", decision, policy.ClusterName())
+	}
+	if decision != types.CanaryPrimary {
+		s.snapshot = s.proxy.clusterManager.GetClusterSnapshot(s.context, policy.ClusterName())
+	}
+}
+
+// canaryHashKey returns the value of the hash header, or the downstream remote ip if the header is not present
+func (s *downStream) canaryHashKey(policy types.CanaryPolicy) string {
+	if name := policy.HashHeader(); name != "" && s.downstreamReqHeaders != nil {
+		if value, ok := s.downstreamReqHeaders.Get(name); ok && value != "" {
+			return value
+		}
+	}
+	if addr := s.requestInfo.DownstreamRemoteAddress(); addr != nil {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			return host
+		}
+		return addr.String()
+	}
+	return ""
+}
+
+// recordUpstreamCluster records the cluster chosen by the route on the request info,
+// the cluster may be one of the weighted clusters
+func (s *downStream) recordUpstreamCluster() {
+	recorder, ok := s.requestInfo.(types.UpstreamClusterRecorder)
+	if !ok || s.snapshot == nil || reflect.ValueOf(s.snapshot).IsNil() {
+		return
+	}
+	if info := s.snapshot.ClusterInfo(); info != nil {
+		recorder.SetUpstreamClusterName(info.Name())
+	}
+}
+
+func (s *downStream) bodyLimitRule() types.BodyLimitRule {
+	if s.route == nil || s.route.RouteRule() == nil {
+		return nil
+	}
+	rule, _ := s.route.RouteRule().(types.BodyLimitRule)
+	return rule
+}
+
+// checkRequestBodyLimit sends a hijack reply with 413 if the request body exceeds the route limit
+func (s *downStream) checkRequestBodyLimit() {
+	rule := s.bodyLimitRule()
+	if rule == nil || s.directResponse || s.downstreamReqDataBuf == nil {
+		return
+	}
+	limit := rule.MaxRequestBodyBytes()
+	if limit == 0 || uint64(s.downstreamReqDataBuf.Len()) <= limit {
+		return
+	}
+	s.sendRequestBodyTooLarge(uint64(s.downstreamReqDataBuf.Len()), limit)
+}
+
+// sendRequestBodyTooLarge sends a hijack reply with 413 for the request body exceeds the limit
+func (s *downStream) sendRequestBodyTooLarge(size, limit uint64) {
+	log.Proxy.Errorf(s.context, "[proxy] [downstream] request body exceeds the limit, size: %d, limit: %d, proxyId: %d",
+		size, limit, s.ID)
+	s.proxy.stats.RequestBodyExceeded.Inc(1)
+	s.proxy.listenerStats.RequestBodyExceeded.Inc(1)
+	s.requestInfo.SetResponseFlag(api.ReqEntityTooLarge)
+	s.sendHijackReply(nethttp.StatusRequestEntityTooLarge, s.downstreamReqHeaders)
+}
+
+// checkResponseBodyLimit sends a hijack reply with 502 if the response body exceeds the route limit
+func (s *downStream) checkResponseBodyLimit() {
+	rule := s.bodyLimitRule()
+	if rule == nil || s.downstreamRespDataBuf == nil {
+		return
+	}
+	limit := rule.MaxResponseBodyBytes()
+	if limit == 0 || uint64(s.downstreamRespDataBuf.Len()) <= limit {
+		return
+	}
+	log.Proxy.Errorf(s.context, "[proxy] [downstream] response body exceeds the limit, size: %d, limit: %d, proxyId: %d",
+		s.downstreamRespDataBuf.Len(), limit, s.ID)
+	s.proxy.stats.ResponseBodyExceeded.Inc(1)
+	s.proxy.listenerStats.ResponseBodyExceeded.Inc(1)
+	s.sendHijackReply(nethttp.StatusBadGateway, s.downstreamReqHeaders)
+}
+
+// sendInformational sends the informational (1xx) response to the downstream without the sender filters,
+// the response is dropped if the downstream protocol does not support it.
+func (s *downStream) sendInformational(headers types.HeaderMap) {
+	sender, ok := s.responseSender.(types.StreamInformationalSender)
+	if !ok {
+		return
+	}
+	if err := sender.AppendInformationalHeaders(s.context, headers); err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] send informational response failed: %v", err)
 	}
 }
 
@@ -730,6 +1131,11 @@ func (s *downStream) getUpstreamProtocol() types.ProtocolName {
 		proto = s.getDownstreamProtocol()
 	}
 
+	// the cluster forces the http1 requests to use h2c
+	if proto == protocol.HTTP1 && s.clusterHTTP2Upgrade() {
+		proto = protocol.HTTP2
+	}
+
 	return proto
 }
 
@@ -760,6 +1166,14 @@ func (s *downStream) chooseHost(endStream bool) {
 		if log.Proxy.GetLogLevel() >= log.INFO {
 			log.Proxy.Infof(s.context, "[proxy] [downstream] direct response, proxyId = %d", s.ID)
 		}
+		if hr, ok := resp.(types.DirectResponseHeadersRule); ok && len(hr.ResponseHeaders()) > 0 {
+			if s.downstreamReqHeaders == nil {
+				s.downstreamReqHeaders = protocol.CommonHeader(make(map[string]string, len(hr.ResponseHeaders())))
+			}
+			for k, v := range hr.ResponseHeaders() {
+				s.downstreamReqHeaders.Set(k, v)
+			}
+		}
 		if resp.Body() != "" {
 			s.sendHijackReplyWithBody(resp.StatusCode(), s.downstreamReqHeaders, resp.Body())
 		} else {
@@ -829,13 +1243,42 @@ func (s *downStream) chooseHost(endStream bool) {
 		return
 	}
 	// as ClusterName has random factor when choosing weighted cluster,
-	// so need determination at the first time
-	clusterName := s.route.RouteRule().ClusterName(s.context)
+	// the cluster is determined by the snapshot got in matchRoute
+	s.cluster = s.snapshot.ClusterInfo()
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
-		log.Proxy.Debugf(s.context, "[proxy] [downstream] route match result:%+v, clusterName=%v", s.route, clusterName)
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] route match result:%+v, clusterName=%v", s.route, s.cluster.Name())
 	}
 
-	s.cluster = s.snapshot.ClusterInfo()
+	if s.clusterInMaintenance() {
+		if log.Proxy.GetLogLevel() >= log.WARN {
+			log.Proxy.Warnf(s.context, "[proxy] [downstream] cluster %s is in maintenance mode, proxyId: %d", s.cluster.Name(), s.ID)
+		}
+		s.cluster.Stats().UpstreamRequestMaintenanceMode.Inc(1)
+		s.requestInfo.SetResponseFlag(api.UpstreamOverflow)
+		s.sendHijackReplyWithBody(nethttp.StatusServiceUnavailable, s.downstreamReqHeaders, maintenanceModeBody)
+		return
+	}
+
+	if !s.allowCircuitBreaker() {
+		if log.Proxy.GetLogLevel() >= log.WARN {
+			log.Proxy.Warnf(s.context, "[proxy] [downstream] circuit breaker of cluster %s is open, proxyId: %d", s.cluster.Name(), s.ID)
+		}
+		s.cluster.Stats().UpstreamRequestCircuitBreakerOpen.Inc(1)
+		s.requestInfo.SetResponseFlag(api.UpstreamOverflow)
+		s.sendHijackReply(api.UpstreamOverFlowCode, s.downstreamReqHeaders)
+		return
+	}
+
+	// the CONNECT request is tunneled to the upstream connection directly
+	if s.isConnectRequest() {
+		s.connect()
+		return
+	}
+
+	// the trusted header bypasses the load balancer, the retries are sent to the same host
+	if host := s.upstreamHostOverride(); host != nil {
+		s.snapshot = newHostOverrideSnapshot(s.snapshot, host)
+	}
 
 	host, pool, err := s.initializeUpstreamConnectionPool(s)
 	if err != nil {
@@ -845,7 +1288,27 @@ func (s *downStream) chooseHost(endStream bool) {
 		return
 	}
 
-	parseProxyTimeout(s.context, &s.timeout, s.route, s.downstreamReqHeaders)
+	if !s.admitRequest() {
+		if log.Proxy.GetLogLevel() >= log.WARN {
+			log.Proxy.Warnf(s.context, "[proxy] [downstream] admission queue of cluster %s rejects the request, proxyId: %d", s.cluster.Name(), s.ID)
+		}
+		s.cluster.Stats().UpstreamRequestAdmissionRejected.Inc(1)
+		s.requestInfo.SetResponseFlag(api.UpstreamOverflow)
+		s.sendHijackReply(api.UpstreamOverFlowCode, s.downstreamReqHeaders)
+		return
+	}
+
+	if !s.acquireConcurrency() {
+		if log.Proxy.GetLogLevel() >= log.WARN {
+			log.Proxy.Warnf(s.context, "[proxy] [downstream] concurrency limit of cluster %s is reached, proxyId: %d", s.cluster.Name(), s.ID)
+		}
+		s.cluster.Stats().UpstreamRequestConcurrencyLimited.Inc(1)
+		s.requestInfo.SetResponseFlag(api.UpstreamOverflow)
+		s.sendHijackReply(api.UpstreamOverFlowCode, s.downstreamReqHeaders)
+		return
+	}
+
+	parseProxyTimeout(s.context, &s.timeout, s.route, s.cluster, s.downstreamReqHeaders)
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] timeout info: %+v", s.timeout)
@@ -865,9 +1328,130 @@ func (s *downStream) chooseHost(endStream bool) {
 	s.upstreamRequest.host = host
 }
 
+// maintenanceModeBody is the response body of the requests rejected by the cluster in maintenance mode
+const maintenanceModeBody = "cluster in maintenance mode"
+
+// clusterInMaintenance returns true if the cluster is put into the maintenance mode
+func (s *downStream) clusterInMaintenance() bool {
+	mc, ok := s.cluster.(types.MaintenanceModeCluster)
+	return ok && mc.MaintenanceMode()
+}
+
+// allowCircuitBreaker returns false if the circuit breaker of the request's priority rejects the request,
+// the request is allowed once per stream, the one allowed before the host is chosen again is released
+// if the request is sent to another cluster.
+func (s *downStream) allowCircuitBreaker() bool {
+	var cb types.CircuitBreaker
+	if cbm, ok := s.cluster.(types.CircuitBreakerManager); ok {
+		priority := types.DefaultPriority
+		if rule, ok := s.route.RouteRule().(types.PriorityRule); ok {
+			priority = rule.Priority()
+		}
+		cb = cbm.CircuitBreaker(priority)
+	}
+	if s.circuitBreaker != nil {
+		if s.circuitBreaker == cb {
+			return true
+		}
+		s.releaseCircuitBreaker()
+	}
+	if cb == nil {
+		return true
+	}
+	allowed, probe := cb.Allow()
+	if !allowed {
+		return false
+	}
+	s.circuitBreaker = cb
+	s.circuitBreakerPending = true
+	s.circuitBreakerProbe = probe
+	return true
+}
+
+// circuitBreakerReady is called when the upstream stream of the request is ready
+func (s *downStream) circuitBreakerReady() {
+	if s.circuitBreaker != nil && s.circuitBreakerPending {
+		s.circuitBreakerPending = false
+		s.circuitBreaker.Ready()
+	}
+}
+
+// releaseCircuitBreaker finishes the request in the circuit breaker, the request fails
+// if the upstream is reset or responds a server error
+func (s *downStream) releaseCircuitBreaker() {
+	success := atomic.LoadUint32(&s.upstreamReset) == 0 && s.requestInfo.ResponseCode() < nethttp.StatusInternalServerError
+	s.circuitBreaker.Release(s.circuitBreakerPending, s.circuitBreakerProbe, success)
+	s.circuitBreaker = nil
+	s.circuitBreakerPending = false
+	s.circuitBreakerProbe = false
+}
+
+// acquireConcurrency returns false if the concurrency limiter of the cluster rejects the request,
+// the concurrency is acquired once per stream, the one acquired before the host is chosen again is released
+// if the request is sent to another cluster.
+func (s *downStream) acquireConcurrency() bool {
+	var limiter types.ConcurrencyLimiter
+	if getter, ok := s.cluster.(types.ConcurrencyLimiterGetter); ok {
+		limiter = getter.ConcurrencyLimiter()
+	}
+	if s.concurrencyLimiter != nil {
+		if s.concurrencyLimiter == limiter {
+			return true
+		}
+		s.concurrencyLimiter.Release(time.Since(s.concurrencyAcquired))
+		s.concurrencyLimiter = nil
+	}
+	if limiter == nil {
+		return true
+	}
+	if !limiter.TryAcquire() {
+		return false
+	}
+	s.concurrencyLimiter = limiter
+	s.concurrencyAcquired = time.Now()
+	return true
+}
+
+// admitRequest returns false if the admission queue of the cluster rejects the request,
+// it waits in the queue of the request's priority if the cluster is near capacity.
+// the request is admitted once per stream, the admission before the host is chosen again is released
+// if the request is sent to another cluster.
+func (s *downStream) admitRequest() bool {
+	var queue types.AdmissionQueue
+	if getter, ok := s.cluster.(types.AdmissionQueueGetter); ok {
+		queue = getter.AdmissionQueue()
+	}
+	if s.admissionQueue != nil {
+		if s.admissionQueue == queue {
+			return true
+		}
+		s.admissionQueue.Release()
+		s.admissionQueue = nil
+	}
+	if queue == nil {
+		return true
+	}
+	level := queue.Level(s.downstreamReqHeaders)
+	if rule, ok := s.route.RouteRule().(types.AdmissionPriorityRule); ok {
+		if l, ok := rule.AdmissionPriority(); ok {
+			level = l
+		}
+	}
+	if !queue.Admit(level) {
+		return false
+	}
+	s.admissionQueue = queue
+	return true
+}
+
 func (s *downStream) receiveHeaders(endStream bool) {
+	s.removeRequestHopByHopHeaders()
+	s.removeRequestDebugHeaders()
+	s.setForwardedHeaders()
+	s.grpcMessages = newGrpcMessageRecorder(s.context, s.downstreamReqHeaders, s.cluster)
 
-	// Modify request headers
+	// Modify request headers, the route and the virtual host take precedence over the cluster
+	router.FinalizeClusterRequestHeaders(s.context, s.downstreamReqHeaders, s.cluster)
 	s.route.RouteRule().FinalizeRequestHeaders(s.context, s.downstreamReqHeaders, s.requestInfo)
 	// Call upstream's append header method to build upstream's request
 	s.upstreamRequest.appendHeaders(endStream)
@@ -890,6 +1474,9 @@ func (s *downStream) receiveData(endStream bool) {
 
 	s.requestInfo.SetBytesReceived(s.requestInfo.BytesReceived() + uint64(data.Len()))
 	s.downstreamRecvDone = endStream
+	if s.grpcMessages != nil {
+		s.grpcMessages.recordRequest(data)
+	}
 
 	if endStream {
 		s.onUpstreamRequestSent()
@@ -946,6 +1533,9 @@ func (s *downStream) onUpstreamRequestSent() {
 		// setup per req timeout timer
 		s.setupPerReqTimeout()
 
+		// setup hedge timer
+		s.setupHedgeTimer()
+
 		// setup global timeout timer
 		if s.timeout.GlobalTimeout > 0 {
 			if log.Proxy.GetLogLevel() >= log.DEBUG {
@@ -956,6 +1546,7 @@ func (s *downStream) onUpstreamRequestSent() {
 			}
 
 			ID := atomic.LoadUint32(&s.ID)
+			s.responseDeadline = time.Now().Add(s.timeout.GlobalTimeout)
 			s.responseTimer = utils.NewTimer(s.timeout.GlobalTimeout,
 				func() {
 					// When a stream trigger timeout, this function will be called,
@@ -1078,7 +1669,13 @@ func (s *downStream) initializeUpstreamConnectionPool(lbCtx types.LoadBalancerCo
 
 	currentProtocol := s.getUpstreamProtocol()
 
-	connPool, host = s.proxy.clusterManager.ConnPoolForCluster(lbCtx, s.snapshot, currentProtocol)
+	snapshot := s.snapshot
+	// the retries are sent to the hosts not tried before
+	if excluded := s.retryExcludedHosts(); excluded != nil {
+		snapshot = newExcludeHostsSnapshot(snapshot, excluded)
+	}
+
+	connPool, host = s.proxy.clusterManager.ConnPoolForCluster(lbCtx, snapshot, currentProtocol)
 
 	if connPool == nil {
 		return nil, nil, fmt.Errorf("[proxy] [downstream] no healthy upstream in cluster %s", s.cluster.Name())
@@ -1097,12 +1694,18 @@ func (s *downStream) initializeUpstreamConnectionPool(lbCtx types.LoadBalancerCo
 func (s *downStream) appendHeaders(endStream bool) {
 	s.upstreamProcessDone.Store(endStream)
 	headers := s.downstreamRespHeaders
+	s.setResponseDebugHeaders(headers)
 	// Currently, just log the error
 	if err := s.responseSender.AppendHeaders(s.context, headers, endStream); err != nil {
 		log.Proxy.Errorf(s.context, "append headers error: %s", err)
 	}
 
 	if endStream {
+		// the connections are switched to a tunnel after the upgrade response is sent,
+		// the stream ends when the tunnel is closed
+		if s.startTunnel() {
+			return
+		}
 		s.endStream()
 	}
 }
@@ -1112,6 +1715,9 @@ func (s *downStream) appendData(endStream bool) {
 
 	data := s.downstreamRespDataBuf
 	s.requestInfo.SetBytesSent(s.requestInfo.BytesSent() + uint64(data.Len()))
+	if s.grpcMessages != nil {
+		s.grpcMessages.recordResponse(data)
+	}
 	s.responseSender.AppendData(s.context, data, endStream)
 
 	if endStream {
@@ -1138,6 +1744,12 @@ func (s *downStream) onUpstreamReset(reason types.StreamResetReason) {
 			if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
 				s.upstreamRequest.host.HostStats().UpstreamResponseFailed.Inc(1)
 				s.upstreamRequest.host.ClusterInfo().Stats().UpstreamResponseFailed.Inc(1)
+				if isConnectionFailure(reason) {
+					s.putOutlierResult(s.upstreamRequest.host, false)
+				}
+				if reason == types.StreamConnectionFailed {
+					s.retryState.onConnectFailure(s.upstreamRequest.host)
+				}
 			}
 
 			// setup retry timer and return
@@ -1153,6 +1765,10 @@ func (s *downStream) onUpstreamReset(reason types.StreamResetReason) {
 		}
 	}
 
+	if s.upstreamRequest != nil && s.upstreamRequest.host != nil && isConnectionFailure(reason) {
+		s.putOutlierResult(s.upstreamRequest.host, false)
+	}
+
 	// clean up all timers
 	s.cleanUp()
 
@@ -1188,6 +1804,7 @@ func (s *downStream) onUpstreamHeaders(endStream bool) {
 			if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
 				s.upstreamRequest.host.HostStats().UpstreamResponseFailed.Inc(1)
 				s.upstreamRequest.host.ClusterInfo().Stats().UpstreamResponseFailed.Inc(1)
+				s.putOutlierResult(s.upstreamRequest.host, !s.upstreamResponseFailed())
 			}
 
 			return
@@ -1199,15 +1816,32 @@ func (s *downStream) onUpstreamHeaders(endStream bool) {
 		s.retryState.reset()
 	}
 
+	// the per try timeout limits the time to the first response byte,
+	// while the global timeout limits the whole request
+	if s.perRetryTimer != nil {
+		s.perRetryTimer.Stop()
+		s.perRetryTimer = nil
+	}
+
 	s.handleUpstreamStatusCode()
 
 	s.downstreamResponseStarted = true
 
+	s.removeResponseHopByHopHeaders()
+
 	// directResponse for no route should be nil
 	if s.route != nil {
+		router.FinalizeClusterResponseHeaders(s.context, headers, s.cluster)
 		s.route.RouteRule().FinalizeResponseHeaders(s.context, headers, s.requestInfo)
 	}
 
+	// the sticky session cookie is set if the load balancer chooses a new host for the session
+	if cookie, err := variable.Get(s.context, types.VariableStickySessionCookie); err == nil {
+		if v, ok := cookie.(string); ok && v != "" {
+			headers.Add("Set-Cookie", v)
+		}
+	}
+
 	if endStream {
 		s.onUpstreamResponseRecvFinished()
 	}
@@ -1219,14 +1853,43 @@ func (s *downStream) onUpstreamHeaders(endStream bool) {
 func (s *downStream) handleUpstreamStatusCode() {
 	// todo: support config?
 	if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
-		if s.requestInfo.ResponseCode() >= http.InternalServerError {
+		if s.upstreamResponseFailed() {
 			s.upstreamRequest.host.HostStats().UpstreamResponseFailed.Inc(1)
 			s.upstreamRequest.host.ClusterInfo().Stats().UpstreamResponseFailed.Inc(1)
+			s.putOutlierResult(s.upstreamRequest.host, false)
 		} else {
 			s.upstreamRequest.host.HostStats().UpstreamResponseSuccess.Inc(1)
 			s.upstreamRequest.host.ClusterInfo().Stats().UpstreamResponseSuccess.Inc(1)
+			s.putOutlierResult(s.upstreamRequest.host, true)
+		}
+	}
+}
+
+// upstreamResponseFailed returns true if the upstream responds a server error,
+// the grpc status is checked for the grpc response whose http status is always 200
+func (s *downStream) upstreamResponseFailed() bool {
+	if s.requestInfo.ResponseCode() >= http.InternalServerError {
+		return true
+	}
+	if recorder, ok := s.requestInfo.(types.GrpcStatusRecorder); ok {
+		if status, ok := recorder.GrpcStatus(); ok {
+			return isGrpcFailure(status)
 		}
 	}
+	return false
+}
+
+// putOutlierResult reports the upstream result to the cluster's outlier detector, if any
+func (s *downStream) putOutlierResult(host types.Host, success bool) {
+	if getter, ok := host.ClusterInfo().(types.OutlierDetectorGetter); ok {
+		if od := getter.OutlierDetector(); od != nil {
+			od.PutResult(host, success)
+		}
+	}
+}
+
+func isConnectionFailure(reason types.StreamResetReason) bool {
+	return reason == types.StreamConnectionFailed || reason == types.StreamConnectionTermination
 }
 
 func (s *downStream) onUpstreamData(endStream bool) {
@@ -1290,6 +1953,7 @@ func (s *downStream) setupRetry(endStream bool) bool {
 		s.perRetryTimer.Stop()
 		s.perRetryTimer = nil
 	}
+	s.stopHedgeTimer()
 
 	atomic.CompareAndSwapUint32(&s.upstreamResponseReceived, 1, 0)
 
@@ -1299,11 +1963,23 @@ func (s *downStream) setupRetry(endStream bool) bool {
 // Note: retry-timer MUST be stopped before active stream got recycled, otherwise resetting stream's properties will cause panic here
 func (s *downStream) doRetry() {
 	// retry interval
-	time.Sleep(10 * time.Millisecond)
+	if !s.waitRetry() {
+		return
+	}
 
 	// no reuse buffer
 	atomic.StoreUint32(&s.reuseBuffer, 0)
 
+	if s.upstreamRequest != nil {
+		s.addPreviousHost(s.upstreamRequest.host)
+	}
+
+	// the CONNECT request is tunneled to the upstream connection directly
+	if s.isConnectRequest() {
+		s.connect()
+		return
+	}
+
 	host, pool, err := s.initializeUpstreamConnectionPool(s)
 
 	if err != nil {
@@ -1339,11 +2015,67 @@ func (s *downStream) doRetry() {
 
 	// setup per try timeout timer
 	s.setupPerReqTimeout()
+	s.setupHedgeTimer()
 
 	s.upstreamRequestSent = true
 	s.downstreamRecvDone = true
 }
 
+// waitRetry waits for the retry interval, which is interrupted by the reset and capped by the global timeout.
+// returns false if the request should not be retried, the reason is handled by processError.
+func (s *downStream) waitRetry() bool {
+	interval := defaultRetryInterval
+	if s.retryState != nil {
+		interval = s.retryState.backOff()
+	}
+	if !s.responseDeadline.IsZero() {
+		if remaining := time.Until(s.responseDeadline); remaining < interval {
+			interval = remaining
+		}
+	}
+	interrupted := func() bool {
+		return atomic.LoadUint32(&s.downstreamCleaned) == 1 || s.processDone()
+	}
+	if interval > 0 {
+		timer := time.NewTimer(interval)
+		// the notify without the reset does not interrupt the waiting
+		for waiting := true; waiting && !interrupted(); {
+			select {
+			case <-timer.C:
+				waiting = false
+			case <-s.notify:
+			}
+		}
+		timer.Stop()
+	}
+	if interrupted() {
+		return false
+	}
+	if !s.responseDeadline.IsZero() && !time.Now().Before(s.responseDeadline) {
+		s.onRetryTimeout()
+		return false
+	}
+	return true
+}
+
+// onRetryTimeout ends the request with the global timeout reached before the retry.
+// the response timer ignores the upstream request waiting for the retry, so the timeout is handled here.
+func (s *downStream) onRetryTimeout() {
+	// the stats are counted by the response timer if it is fired
+	if atomic.CompareAndSwapUint32(&s.upstreamResponseReceived, 0, 1) {
+		s.cluster.Stats().UpstreamRequestTimeout.Inc(1)
+		if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
+			s.upstreamRequest.host.HostStats().UpstreamRequestTimeout.Inc(1)
+		}
+	}
+	if s.upstreamRequest != nil {
+		s.upstreamRequest.setupRetry = false
+	}
+	if atomic.CompareAndSwapUint32(&s.upstreamReset, 0, 1) {
+		s.resetReason.Store(types.UpstreamGlobalTimeout)
+	}
+}
+
 // Downstream got reset in proxy context on scenario below:
 // 1. downstream filter reset downstream
 // 2. corresponding upstream got reset
@@ -1372,6 +2104,7 @@ func (s *downStream) sendHijackReply(code int, headers types.HeaderMap) {
 	s.downstreamRespDataBuf = nil
 	s.downstreamRespTrailers = nil
 	s.directResponse = true
+	s.setLocalReplyBody(code, headers)
 }
 
 // TODO: rpc status code may be not matched
@@ -1399,6 +2132,7 @@ func (s *downStream) cleanUp() {
 	// if  a downstream filter ends downstream before send to upstream, retryState will be nil
 	if s.retryState != nil {
 		s.retryState.reset()
+		s.retryState.releaseHedge()
 	}
 
 	// reset pertry timer
@@ -1412,7 +2146,24 @@ func (s *downStream) cleanUp() {
 		s.responseTimer.Stop()
 		s.responseTimer = nil
 	}
+	s.responseDeadline = time.Time{}
+
+	s.stopHedgeTimer()
+
+	// release the concurrency with the latency of the request
+	if s.concurrencyLimiter != nil {
+		s.concurrencyLimiter.Release(time.Since(s.concurrencyAcquired))
+		s.concurrencyLimiter = nil
+	}
 
+	if s.admissionQueue != nil {
+		s.admissionQueue.Release()
+		s.admissionQueue = nil
+	}
+
+	if s.circuitBreaker != nil {
+		s.releaseCircuitBreaker()
+	}
 }
 
 func (s *downStream) setBufferLimit(bufferLimit uint32) {
@@ -1522,9 +2273,17 @@ func (s *downStream) waitNotify(id uint32) (phase types.Phase, err error) {
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] waitNotify begin %p, proxyId = %d", s, s.ID)
 	}
-	select {
-	case <-s.notify:
+	for {
+		select {
+		case <-s.notify:
+		}
+		// woken up by the hedge timer, sends the hedged request and keeps waiting
+		if !s.shouldHedge() {
+			break
+		}
+		s.doHedge()
 	}
+	s.settleHedge()
 	return s.processError(id)
 }
 
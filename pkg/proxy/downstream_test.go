@@ -19,13 +19,19 @@ package proxy
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	gometrics "github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/assert"
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/mock"
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
@@ -33,6 +39,7 @@ import (
 	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
 	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/variable"
 )
@@ -88,6 +95,45 @@ func TestDownstream_FinishTracing_Enable_SpanIsNotNil(t *testing.T) {
 	}
 }
 
+func TestDownstreamRequestMetricsExemplar(t *testing.T) {
+	metrics.ResetAll()
+	defer trace.Disable()
+	span := &mockSpan{traceId: "4bf92f3577b34da6a3ce929d0e0e4736"}
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableTraceSpan, span)
+	newDownstream := func() *downStream {
+		requestInfo := network.NewRequestInfo()
+		requestInfo.SetRequestFinishedDuration(time.Now().Add(time.Millisecond))
+		return &downStream{
+			context:     ctx,
+			requestInfo: requestInfo,
+			proxy: &proxy{
+				stats:         newProxyStats("exemplar_proxy"),
+				listenerStats: newListenerStats("exemplar_listener"),
+			},
+		}
+	}
+	// trace is disabled, no exemplar
+	trace.Disable()
+	s := newDownstream()
+	s.requestMetrics()
+	_, ok := metrics.GetExemplar(s.proxy.stats.DownstreamRequestTime)
+	assert.False(t, ok)
+	// the trace id of the span is attached to the latency histograms
+	trace.Enable()
+	s = newDownstream()
+	s.requestMetrics()
+	for _, h := range []gometrics.Histogram{
+		s.proxy.stats.DownstreamRequestTime,
+		s.proxy.listenerStats.DownstreamRequestTime,
+	} {
+		exemplar, ok := metrics.GetExemplar(h)
+		assert.True(t, ok)
+		assert.Equal(t, span.traceId, exemplar.TraceId)
+		assert.True(t, exemplar.Value > 0)
+	}
+}
+
 func TestDirectResponse(t *testing.T) {
 	testCases := []struct {
 		client *mockResponseSender
@@ -135,6 +181,37 @@ func TestDirectResponse(t *testing.T) {
 				}
 			},
 		},
+		// with headers
+		{
+			client: &mockResponseSender{},
+			route: &mockRoute{
+				direct: &mockDirectRule{
+					status: 503,
+					body:   "under maintenance",
+					headers: map[string]string{
+						"content-type": "text/plain",
+						"retry-after":  "120",
+					},
+				},
+			},
+			check: func(t *testing.T, ctx context.Context, client *mockResponseSender) {
+				if client.headers == nil {
+					t.Fatal("want to receive a header response")
+				}
+				if code, err := variable.GetString(ctx, types.VarHeaderStatus); err != nil || code != "503" {
+					t.Error("response status code not expected")
+				}
+				if v, ok := client.headers.Get("retry-after"); !ok || v != "120" {
+					t.Error("response headers not expected")
+				}
+				if v, ok := client.headers.Get("content-type"); !ok || v != "text/plain" {
+					t.Error("response headers not expected")
+				}
+				if client.data == nil || client.data.String() != "under maintenance" {
+					t.Error("response data not expected")
+				}
+			},
+		},
 	}
 	for _, tc := range testCases {
 		ctx := variable.NewVariableContext(context.Background())
@@ -167,6 +244,95 @@ func TestDirectResponse(t *testing.T) {
 	}
 }
 
+func TestRedirectResponse(t *testing.T) {
+	testCases := []struct {
+		rule     *mockRedirectRule
+		host     string
+		path     string
+		query    string
+		code     string
+		location string
+	}{
+		// https redirect
+		{
+			rule: &mockRedirectRule{
+				code:   http.StatusMovedPermanently,
+				scheme: "https",
+			},
+			host:     "example.com",
+			path:     "/foo",
+			query:    "a=1",
+			code:     "301",
+			location: "https://example.com/foo?a=1",
+		},
+		// the http port is removed when redirecting to https
+		{
+			rule: &mockRedirectRule{
+				code:   http.StatusMovedPermanently,
+				scheme: "https",
+			},
+			host:     "example.com:80",
+			path:     "/foo",
+			code:     "301",
+			location: "https://example.com/foo",
+		},
+		// host and path redirect
+		{
+			rule: &mockRedirectRule{
+				code: http.StatusFound,
+				host: "example.org",
+				path: "/bar",
+			},
+			host:     "example.com",
+			path:     "/foo",
+			code:     "302",
+			location: "http://example.org/bar",
+		},
+	}
+	for i, tc := range testCases {
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.Set(ctx, types.VariableDownStreamProtocol, protocol.HTTP2)
+		variable.SetString(ctx, types.VarScheme, "http")
+		variable.SetString(ctx, types.VarHost, tc.host)
+		variable.SetString(ctx, types.VarPath, tc.path)
+		variable.SetString(ctx, types.VarQueryString, tc.query)
+		client := &mockResponseSender{}
+		s := &downStream{
+			proxy: &proxy{
+				config: &v2.Proxy{},
+				routersWrapper: &mockRouterWrapper{
+					routers: &mockRouters{
+						route: &mockRoute{
+							redirect: tc.rule,
+						},
+					},
+				},
+				clusterManager:      &mockClusterManager{},
+				readCallbacks:       &mockReadFilterCallbacks{},
+				stats:               globalStats,
+				listenerStats:       newListenerStats("test"),
+				serverStreamConn:    &mockServerConn{},
+				routeHandlerFactory: router.DefaultMakeHandler,
+			},
+			responseSender: client,
+			requestInfo:    &network.RequestInfo{},
+			context:        ctx,
+		}
+		s.initStreamFilterChain()
+		s.OnReceive(ctx, protocol.CommonHeader{}, buffer.NewIoBuffer(1), nil)
+		time.Sleep(100 * time.Millisecond)
+		if client.headers == nil {
+			t.Fatalf("#%d want to receive a header response", i)
+		}
+		if code, err := variable.GetString(ctx, types.VarHeaderStatus); err != nil || code != tc.code {
+			t.Errorf("#%d response status code not expected: %s", i, code)
+		}
+		if location, ok := client.headers.Get("location"); !ok || location != tc.location {
+			t.Errorf("#%d response location not expected: %s", i, location)
+		}
+	}
+}
+
 func TestSetDownstreamRouter(t *testing.T) {
 	s := &downStream{
 		context: context.Background(),
@@ -485,3 +651,572 @@ func TestGetUpstreamProtocol(t *testing.T) {
 		assert.Equal(t, tc.expectedProtocol, currentProtocol)
 	}
 }
+
+func TestRouteBodyLimit(t *testing.T) {
+	rule := &mockBodyLimitRouteRule{
+		maxRequestBodyBytes:  4,
+		maxResponseBodyBytes: 8,
+	}
+	newStream := func(route *mockRoute) *downStream {
+		return &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			route:   route,
+			proxy: &proxy{
+				config:        &v2.Proxy{},
+				stats:         globalStats,
+				listenerStats: newListenerStats("test_body_limit"),
+			},
+			requestInfo: &network.RequestInfo{},
+		}
+	}
+	t.Run("request", func(t *testing.T) {
+		for _, tc := range []struct {
+			body     string
+			route    *mockRoute
+			wantCode int
+		}{
+			{body: "1234", route: &mockRoute{rule: rule}},
+			{body: "12345", route: &mockRoute{rule: rule}, wantCode: 413},
+			// no limit
+			{body: "12345", route: &mockRoute{rule: &mockBodyLimitRouteRule{}}},
+			{body: "12345", route: &mockRoute{}},
+		} {
+			s := newStream(tc.route)
+			s.downstreamReqDataBuf = buffer.NewIoBufferString(tc.body)
+			before := s.proxy.listenerStats.RequestBodyExceeded.Count()
+			s.checkRequestBodyLimit()
+			wantStat := int64(0)
+			if tc.wantCode != 0 {
+				wantStat = 1
+			}
+			assert.Equal(t, tc.wantCode != 0, s.directResponse)
+			assert.Equal(t, tc.wantCode, s.requestInfo.ResponseCode())
+			assert.Equal(t, tc.wantCode != 0, s.requestInfo.GetResponseFlag(api.ReqEntityTooLarge))
+			assert.Equal(t, wantStat, s.proxy.listenerStats.RequestBodyExceeded.Count()-before)
+		}
+	})
+	t.Run("request streaming in", func(t *testing.T) {
+		// the request body grows after route by a receiver filter
+		s := newStream(&mockRoute{rule: rule})
+		s.downstreamReqDataBuf = buffer.NewIoBufferString("12")
+		s.checkRequestBodyLimit()
+		assert.False(t, s.directResponse)
+		s.initStreamFilterChain()
+		handler := newStreamReceiverFilterHandler(s)
+		handler.InjectData(buffer.NewIoBufferString("34"), false)
+		assert.False(t, s.directResponse)
+		handler.InjectData(buffer.NewIoBufferString("5"), false)
+		assert.True(t, s.directResponse)
+		assert.Equal(t, 413, s.requestInfo.ResponseCode())
+	})
+	t.Run("response", func(t *testing.T) {
+		for _, tc := range []struct {
+			body     string
+			wantCode int
+		}{
+			{body: "12345678"},
+			{body: "123456789", wantCode: 502},
+		} {
+			s := newStream(&mockRoute{rule: rule})
+			s.downstreamRespHeaders = protocol.CommonHeader{}
+			s.downstreamRespDataBuf = buffer.NewIoBufferString(tc.body)
+			before := s.proxy.stats.ResponseBodyExceeded.Count()
+			s.checkResponseBodyLimit()
+			wantStat := int64(0)
+			if tc.wantCode != 0 {
+				wantStat = 1
+				assert.Nil(t, s.downstreamRespDataBuf)
+			}
+			assert.Equal(t, tc.wantCode != 0, s.directResponse)
+			assert.Equal(t, tc.wantCode, s.requestInfo.ResponseCode())
+			assert.Equal(t, wantStat, s.proxy.stats.ResponseBodyExceeded.Count()-before)
+		}
+	})
+	t.Run("hijack", func(t *testing.T) {
+		sender := &mockResponseSender{}
+		s := newStream(nil)
+		s.proxy.routersWrapper = &mockRouterWrapper{
+			routers: &mockRouters{
+				route: &mockRoute{rule: rule},
+			},
+		}
+		s.proxy.clusterManager = &mockClusterManager{}
+		s.proxy.readCallbacks = &mockReadFilterCallbacks{}
+		s.proxy.serverStreamConn = &mockServerConn{}
+		s.proxy.routeHandlerFactory = router.DefaultMakeHandler
+		s.responseSender = sender
+		s.notify = make(chan struct{}, 1)
+		s.initStreamFilterChain()
+		s.OnReceive(s.context, protocol.CommonHeader{}, buffer.NewIoBufferString("12345"), nil)
+		time.Sleep(100 * time.Millisecond)
+		if sender.headers == nil {
+			t.Fatal("want to receive a header response")
+		}
+		if code, err := variable.GetString(s.context, types.VarHeaderStatus); err != nil || code != "413" {
+			t.Errorf("response status code not expected: %s", code)
+		}
+	})
+}
+
+type mockBodyLimitRouteRule struct {
+	mockRouteRule
+	maxRequestBodyBytes  uint64
+	maxResponseBodyBytes uint64
+}
+
+func (r *mockBodyLimitRouteRule) MaxRequestBodyBytes() uint64 {
+	return r.maxRequestBodyBytes
+}
+
+func (r *mockBodyLimitRouteRule) MaxResponseBodyBytes() uint64 {
+	return r.maxResponseBodyBytes
+}
+
+type mockMaintenanceCluster struct {
+	types.ClusterInfo
+	stats       types.ClusterStats
+	maintenance bool
+}
+
+func (c *mockMaintenanceCluster) Name() string {
+	return "maintenance"
+}
+
+func (c *mockMaintenanceCluster) Stats() types.ClusterStats {
+	return c.stats
+}
+
+func (c *mockMaintenanceCluster) MaintenanceMode() bool {
+	return c.maintenance
+}
+
+func (c *mockMaintenanceCluster) SetMaintenanceMode(enable bool) {
+	c.maintenance = enable
+}
+
+func TestClusterMaintenanceMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	info := &mockMaintenanceCluster{
+		stats: types.ClusterStats{
+			UpstreamRequestMaintenanceMode: gometrics.NewCounter(),
+		},
+	}
+	snapshot := mock.NewMockClusterSnapshot(ctrl)
+	snapshot.EXPECT().ClusterInfo().Return(info).AnyTimes()
+
+	newDownstream := func() *downStream {
+		return &downStream{
+			ID:                   1,
+			context:              variable.NewVariableContext(context.Background()),
+			proxy:                &proxy{config: &v2.Proxy{}},
+			route:                &mockRoute{},
+			snapshot:             snapshot,
+			requestInfo:          &network.RequestInfo{},
+			downstreamReqHeaders: protocol.CommonHeader{},
+		}
+	}
+
+	info.SetMaintenanceMode(true)
+	s := newDownstream()
+	s.chooseHost(true)
+	assert.True(t, s.directResponse)
+	assert.Equal(t, http.StatusServiceUnavailable, s.requestInfo.ResponseCode())
+	assert.True(t, s.requestInfo.GetResponseFlag(api.UpstreamOverflow))
+	assert.Equal(t, maintenanceModeBody, s.downstreamRespDataBuf.String())
+	assert.Nil(t, s.upstreamRequest)
+	assert.Equal(t, int64(1), info.stats.UpstreamRequestMaintenanceMode.Count())
+
+	// the requests are routed to the cluster again once the maintenance mode is disabled
+	info.SetMaintenanceMode(false)
+	s = newDownstream()
+	s.cluster = info
+	assert.False(t, s.clusterInMaintenance())
+	assert.Equal(t, int64(1), info.stats.UpstreamRequestMaintenanceMode.Count())
+}
+
+type mockCanaryRouteRule struct {
+	mockRouteRule
+	policy types.CanaryPolicy
+}
+
+func (r *mockCanaryRouteRule) CanaryPolicy() types.CanaryPolicy {
+	return r.policy
+}
+
+func TestChooseCanaryCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rcfg := &v2.Router{}
+	rcfg.Route.ClusterName = "stable"
+	rcfg.Route.Canary = &v2.CanaryConfig{
+		ClusterName: "canary",
+		Headers: []v2.HeaderMatcher{
+			{Name: "x-canary", Value: "true"},
+		},
+		Percentage: 30,
+		HashHeader: "x-user-id",
+	}
+	rule, err := router.NewRouteRuleImplBase(nil, rcfg)
+	assert.Nil(t, err)
+
+	stable := mock.NewMockClusterSnapshot(ctrl)
+	canary := mock.NewMockClusterSnapshot(ctrl)
+	cm := mock.NewMockClusterManager(ctrl)
+	cm.EXPECT().GetClusterSnapshot(gomock.Any(), "canary").Return(canary).AnyTimes()
+
+	choose := func(headers protocol.CommonHeader, remote string) (types.ClusterSnapshot, types.CanaryDecision) {
+		info := &network.RequestInfo{}
+		if remote != "" {
+			addr, _ := net.ResolveTCPAddr("tcp", remote)
+			info.SetDownstreamRemoteAddress(addr)
+		}
+		s := &downStream{
+			context:              variable.NewVariableContext(context.Background()),
+			proxy:                &proxy{clusterManager: cm},
+			route:                &mockRoute{rule: &mockCanaryRouteRule{policy: rule.CanaryPolicy()}},
+			snapshot:             stable,
+			requestInfo:          info,
+			downstreamReqHeaders: headers,
+		}
+		s.chooseCanaryCluster()
+		return s.snapshot, info.CanaryDecision()
+	}
+
+	// the header forces the canary cluster
+	snapshot, decision := choose(protocol.CommonHeader{"x-canary": "true", "x-user-id": "1"}, "")
+	assert.Same(t, canary, snapshot)
+	assert.Equal(t, types.CanaryByHeader, decision)
+
+	// the percentage of the other requests is sent to the canary cluster, the assignment is stable
+	const total = 10000
+	canaryCount := 0
+	for i := 0; i < total; i++ {
+		headers := protocol.CommonHeader{"x-canary": "false", "x-user-id": strconv.Itoa(i)}
+		snapshot, decision = choose(headers, "")
+		again, againDecision := choose(headers, "")
+		assert.Same(t, snapshot, again)
+		assert.Equal(t, decision, againDecision)
+		switch decision {
+		case types.CanaryByPercentage:
+			assert.Same(t, canary, snapshot)
+			canaryCount++
+		case types.CanaryPrimary:
+			assert.Same(t, stable, snapshot)
+		default:
+			t.Fatalf("unexpected decision: %s", decision)
+		}
+	}
+	assert.InDelta(t, 0.3, float64(canaryCount)/total, 0.02)
+
+	// the remote ip is hashed without the hash header, the port is ignored
+	for i := 0; i < 100; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/10, i%10)
+		_, decision = choose(protocol.CommonHeader{}, ip+":1000")
+		_, again := choose(protocol.CommonHeader{}, ip+":2000")
+		assert.Equal(t, decision, again)
+	}
+
+	// the route without canary policy is not changed
+	s := &downStream{
+		route:       &mockRoute{rule: &mockCanaryRouteRule{}},
+		snapshot:    stable,
+		requestInfo: &network.RequestInfo{},
+	}
+	s.chooseCanaryCluster()
+	assert.Same(t, stable, s.snapshot)
+	assert.Equal(t, types.CanaryDecision(""), s.requestInfo.(*network.RequestInfo).CanaryDecision())
+}
+
+type mockAccessLogRouteRule struct {
+	mockRouteRule
+	disabled   bool
+	formatName string
+}
+
+func (r *mockAccessLogRouteRule) AccessLogDisabled() bool {
+	return r.disabled
+}
+
+func (r *mockAccessLogRouteRule) AccessLogFormatName() string {
+	return r.formatName
+}
+
+// mockNamedFormatAccessLog records the format names of the logs, empty means the default format
+type mockNamedFormatAccessLog struct {
+	logs []string
+}
+
+func (l *mockNamedFormatAccessLog) Log(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	l.logs = append(l.logs, "")
+}
+
+func (l *mockNamedFormatAccessLog) LogWithFormat(name string, ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	l.logs = append(l.logs, name)
+}
+
+func TestRouteAccessLog(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		route *mockRoute
+		logs  []string
+	}{
+		{name: "no route", logs: []string{""}},
+		{name: "default", route: &mockRoute{rule: &mockRouteRule{}}, logs: []string{""}},
+		{name: "no override", route: &mockRoute{rule: &mockAccessLogRouteRule{}}, logs: []string{""}},
+		{name: "disabled", route: &mockRoute{rule: &mockAccessLogRouteRule{disabled: true}}, logs: nil},
+		{name: "custom format", route: &mockRoute{rule: &mockAccessLogRouteRule{formatName: "short"}}, logs: []string{"short"}},
+	} {
+		al := &mockNamedFormatAccessLog{}
+		s := &downStream{
+			context:     variable.NewVariableContext(context.Background()),
+			requestInfo: &network.RequestInfo{},
+			proxy: &proxy{
+				config:     &v2.Proxy{},
+				accessLogs: []api.AccessLog{al},
+			},
+			streamFilterChain: streamFilterChain{
+				DefaultStreamFilterChainImpl: &streamfilter.DefaultStreamFilterChainImpl{},
+			},
+		}
+		if tc.route != nil {
+			s.route = tc.route
+		}
+		s.writeLog()
+		assert.Equal(t, tc.logs, al.logs, tc.name)
+	}
+}
+
+type mockInformationalSender struct {
+	types.StreamSender
+	headers []types.HeaderMap
+}
+
+func (s *mockInformationalSender) AppendInformationalHeaders(ctx context.Context, headers types.HeaderMap) error {
+	s.headers = append(s.headers, headers)
+	return nil
+}
+
+func TestUpstreamInformational(t *testing.T) {
+	sender := &mockInformationalSender{}
+	s := &downStream{
+		context:        context.Background(),
+		responseSender: sender,
+	}
+	r := &upstreamRequest{downStream: s}
+	early := protocol.CommonHeader{"link": "</style.css>; rel=preload"}
+	r.OnReceiveInformational(context.Background(), early)
+	assert.Equal(t, []types.HeaderMap{early}, sender.headers)
+	// the informational responses after the final response are ignored
+	s.upstreamResponseReceived = 1
+	r.OnReceiveInformational(context.Background(), protocol.CommonHeader{})
+	assert.Len(t, sender.headers, 1)
+	// the downstream protocol does not support the informational responses
+	s = &downStream{
+		context:        context.Background(),
+		responseSender: &mockResponseSender{},
+	}
+	r = &upstreamRequest{downStream: s}
+	r.OnReceiveInformational(context.Background(), early)
+}
+
+type mockConcurrencyLimiter struct {
+	inflight int
+}
+
+func (l *mockConcurrencyLimiter) TryAcquire() bool {
+	l.inflight++
+	return true
+}
+
+func (l *mockConcurrencyLimiter) Release(latency time.Duration) {
+	l.inflight--
+}
+
+func (l *mockConcurrencyLimiter) Limit() uint32 {
+	return 1
+}
+
+type mockConcurrencyLimitedCluster struct {
+	types.ClusterInfo
+	limiter *mockConcurrencyLimiter
+}
+
+func (c *mockConcurrencyLimitedCluster) ConcurrencyLimiter() types.ConcurrencyLimiter {
+	return c.limiter
+}
+
+func TestAcquireConcurrencyOnce(t *testing.T) {
+	cluster1 := &mockConcurrencyLimitedCluster{limiter: &mockConcurrencyLimiter{}}
+	cluster2 := &mockConcurrencyLimitedCluster{limiter: &mockConcurrencyLimiter{}}
+	s := &downStream{
+		cluster: cluster1,
+	}
+	// the host is chosen again in the same cluster
+	assert.True(t, s.acquireConcurrency())
+	assert.True(t, s.acquireConcurrency())
+	assert.Equal(t, 1, cluster1.limiter.inflight)
+	// the route is matched again to another cluster
+	s.cluster = cluster2
+	assert.True(t, s.acquireConcurrency())
+	assert.Equal(t, 0, cluster1.limiter.inflight)
+	assert.Equal(t, 1, cluster2.limiter.inflight)
+
+	s.cleanUp()
+	assert.Equal(t, 0, cluster2.limiter.inflight)
+}
+
+type mockAdmissionQueue struct {
+	admitted int
+}
+
+func (q *mockAdmissionQueue) Level(headers api.HeaderMap) int {
+	return 0
+}
+
+func (q *mockAdmissionQueue) Admit(level int) bool {
+	q.admitted++
+	return true
+}
+
+func (q *mockAdmissionQueue) Release() {
+	q.admitted--
+}
+
+type mockAdmissionCluster struct {
+	types.ClusterInfo
+	queue *mockAdmissionQueue
+}
+
+func (c *mockAdmissionCluster) AdmissionQueue() types.AdmissionQueue {
+	return c.queue
+}
+
+func TestAdmitRequestOnce(t *testing.T) {
+	cluster1 := &mockAdmissionCluster{queue: &mockAdmissionQueue{}}
+	cluster2 := &mockAdmissionCluster{queue: &mockAdmissionQueue{}}
+	s := &downStream{
+		cluster: cluster1,
+		route:   &mockRoute{},
+	}
+	// the host is chosen again in the same cluster
+	assert.True(t, s.admitRequest())
+	assert.True(t, s.admitRequest())
+	assert.Equal(t, 1, cluster1.queue.admitted)
+	// the route is matched again to another cluster
+	s.cluster = cluster2
+	assert.True(t, s.admitRequest())
+	assert.Equal(t, 0, cluster1.queue.admitted)
+	assert.Equal(t, 1, cluster2.queue.admitted)
+
+	s.cleanUp()
+	assert.Equal(t, 0, cluster2.queue.admitted)
+}
+
+func TestWaitRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	timeoutStats := gometrics.NewCounter()
+	cluster := mock.NewMockClusterInfo(ctrl)
+	cluster.EXPECT().Stats().Return(types.ClusterStats{
+		UpstreamRequestTimeout: timeoutStats,
+	}).AnyTimes()
+	newStream := func() *downStream {
+		s := &downStream{
+			ID:         1,
+			context:    variable.NewVariableContext(context.Background()),
+			cluster:    cluster,
+			notify:     make(chan struct{}, 1),
+			retryState: &retryState{backOffBase: 10 * time.Second, backOffMax: 10 * time.Second},
+			upstreamRequest: &upstreamRequest{
+				setupRetry: true,
+			},
+		}
+		s.upstreamRequest.downStream = s
+		return s
+	}
+
+	// retry without the global timeout, the notify without the reset is ignored
+	s := newStream()
+	s.retryState = nil
+	s.sendNotify()
+	assert.True(t, s.waitRetry())
+
+	// the back off is interrupted by the downstream reset
+	s = newStream()
+	s.OnResetStream(types.StreamRemoteReset)
+	start := time.Now()
+	assert.False(t, s.waitRetry())
+	assert.True(t, time.Since(start) < time.Second)
+
+	// the global timeout is reached before the retry
+	s = newStream()
+	s.responseDeadline = time.Now().Add(-time.Millisecond)
+	start = time.Now()
+	assert.False(t, s.waitRetry())
+	assert.True(t, time.Since(start) < time.Second)
+	assert.False(t, s.upstreamRequest.setupRetry)
+	assert.Equal(t, uint32(1), s.upstreamReset)
+	assert.Equal(t, types.UpstreamGlobalTimeout, s.resetReason.Load())
+	assert.Equal(t, int64(1), timeoutStats.Count())
+}
+
+func TestRetryBudgetPerStream(t *testing.T) {
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryOn:    true,
+			NumRetries: 3,
+		},
+		RetryTimeout: time.Second,
+	}
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	clusterInfo := &fakeClusterInfo{
+		mgr: cluster.NewResourceManager(v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{
+				{RetryBudget: &v2.RetryBudget{BudgetPercent: 20, MinRetryConcurrency: 1}},
+			},
+		}),
+	}
+	retries := clusterInfo.ResourceManager().Retries()
+	newStream := func() *downStream {
+		return &downStream{
+			cluster:    clusterInfo,
+			retryState: newRetryState(r.Policy().RetryPolicy(), nil, clusterInfo, protocol.HTTP1),
+		}
+	}
+	// the connection failure is retried without checking the request and the response
+	retry := func(s *downStream) api.RetryCheckStatus {
+		return s.retryState.retry(nil, nil, types.StreamConnectionFailed)
+	}
+
+	// the requests without retry hold nothing of the budget
+	for i := 0; i < 3; i++ {
+		newStream().cleanUp()
+	}
+	assert.Equal(t, int64(0), retries.Cur())
+
+	// the retry uses up the budget, the retries of the other streams are rejected
+	s1 := newStream()
+	assert.Equal(t, api.ShouldRetry, retry(s1))
+	// retry again releases the slot held by the previous retry
+	assert.Equal(t, api.ShouldRetry, retry(s1))
+	assert.Equal(t, int64(1), retries.Cur())
+	for i := 0; i < 3; i++ {
+		s := newStream()
+		assert.Equal(t, api.RetryOverflow, retry(s))
+		s.cleanUp()
+	}
+	assert.Equal(t, int64(1), retries.Cur())
+
+	// the budget is released when the retried stream ends
+	s1.cleanUp()
+	assert.Equal(t, int64(0), retries.Cur())
+	s2 := newStream()
+	assert.Equal(t, api.ShouldRetry, retry(s2))
+	s2.cleanUp()
+	assert.Equal(t, int64(0), retries.Cur())
+}
@@ -0,0 +1,170 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// activeProxies is the registry of the proxies which have an active downstream connection,
+// the key is the *proxy, it is used to dump the connections and streams for debugging.
+var activeProxies sync.Map
+
+// ConnectionInfo describes an active downstream connection
+type ConnectionInfo struct {
+	ID            uint64 `json:"id"`
+	Listener      string `json:"listener"`
+	RemoteAddress string `json:"remote_address"`
+	Protocol      string `json:"protocol"`
+	// Age is the time since the connection is created, in milliseconds
+	Age           int64 `json:"age_ms"`
+	ActiveStreams int   `json:"active_streams"`
+}
+
+// StreamInfo describes an in-flight downstream stream
+type StreamInfo struct {
+	ID           uint32 `json:"id"`
+	ConnectionID uint64 `json:"connection_id"`
+	Listener     string `json:"listener"`
+	Phase        string `json:"phase"`
+	VirtualHost  string `json:"virtual_host,omitempty"`
+	Cluster      string `json:"cluster,omitempty"`
+	UpstreamHost string `json:"upstream_host,omitempty"`
+	// Elapsed is the time since the request is received, in milliseconds
+	Elapsed int64 `json:"elapsed_ms"`
+}
+
+func registerActiveProxy(p *proxy) {
+	activeProxies.Store(p, struct{}{})
+}
+
+func unregisterActiveProxy(p *proxy) {
+	activeProxies.Delete(p)
+}
+
+func rangeActiveProxies(f func(p *proxy)) {
+	activeProxies.Range(func(key, _ interface{}) bool {
+		f(key.(*proxy))
+		return true
+	})
+}
+
+func (p *proxy) connectionID() uint64 {
+	if p.readCallbacks == nil {
+		return 0
+	}
+	return p.readCallbacks.Connection().ID()
+}
+
+// DumpConnections returns the active downstream connections, sorted by the connection id
+func DumpConnections() []ConnectionInfo {
+	now := time.Now()
+	infos := []ConnectionInfo{}
+	rangeActiveProxies(func(p *proxy) {
+		info := ConnectionInfo{
+			ID:       p.connectionID(),
+			Listener: p.listenerName,
+			Age:      now.Sub(p.createdAt).Milliseconds(),
+		}
+		if p.readCallbacks != nil {
+			if addr := p.readCallbacks.Connection().RemoteAddr(); addr != nil {
+				info.RemoteAddress = addr.String()
+			}
+		}
+		p.asMux.RLock()
+		if ssc := p.serverStreamConn; ssc != nil {
+			info.Protocol = string(ssc.Protocol())
+		}
+		info.ActiveStreams = p.activeStreams.Len()
+		p.asMux.RUnlock()
+		infos = append(infos, info)
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// DumpStreams returns the in-flight downstream streams, sorted by the connection id and the stream id
+func DumpStreams() []StreamInfo {
+	now := time.Now()
+	infos := []StreamInfo{}
+	rangeActiveProxies(func(p *proxy) {
+		connID := p.connectionID()
+		// the streams are snapshotted under the lock, as the finished streams are reused once they are removed
+		p.asMux.RLock()
+		defer p.asMux.RUnlock()
+		for e := p.activeStreams.Front(); e != nil; e = e.Next() {
+			s, ok := e.Value.(*downStream)
+			if !ok || s == nil {
+				continue
+			}
+			info := s.dumpInfo(now)
+			info.ConnectionID = connID
+			info.Listener = p.listenerName
+			infos = append(infos, info)
+		}
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].ConnectionID != infos[j].ConnectionID {
+			return infos[i].ConnectionID < infos[j].ConnectionID
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// dumpInfo snapshots the stream, the fields are updated by the stream without lock,
+// so each of them is read once and checked before it is used.
+func (s *downStream) dumpInfo(now time.Time) StreamInfo {
+	info := StreamInfo{
+		ID:    s.ID,
+		Phase: phaseName(s.phase),
+	}
+	if ri := s.requestInfo; ri != nil {
+		info.Elapsed = now.Sub(ri.StartTime()).Milliseconds()
+	}
+	if route := s.route; route != nil {
+		if rule := route.RouteRule(); rule != nil && !reflect.ValueOf(rule).IsNil() {
+			if vh := rule.VirtualHost(); vh != nil && !reflect.ValueOf(vh).IsNil() {
+				info.VirtualHost = vh.Name()
+			}
+		}
+	}
+	if cluster := s.cluster; cluster != nil && !reflect.ValueOf(cluster).IsNil() {
+		info.Cluster = cluster.Name()
+	}
+	if ur := s.upstreamRequest; ur != nil {
+		if host := ur.host; host != nil && !reflect.ValueOf(host).IsNil() {
+			info.UpstreamHost = host.AddressString()
+		}
+	}
+	return info
+}
+
+func phaseName(phase types.Phase) string {
+	if phase >= 0 && int(phase) < len(types.PhaseName) {
+		return types.PhaseName[phase]
+	}
+	return "Unknown"
+}
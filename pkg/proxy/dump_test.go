@@ -0,0 +1,192 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func resetActiveProxies() {
+	activeProxies.Range(func(key, _ interface{}) bool {
+		activeProxies.Delete(key)
+		return true
+	})
+}
+
+func TestDumpConnectionsAndStreams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resetActiveProxies()
+	defer resetActiveProxies()
+
+	newDumpProxy := func(id uint64, remote string, listener string) *proxy {
+		cb := mock.NewMockReadFilterCallbacks(ctrl)
+		cb.EXPECT().Connection().DoAndReturn(func() api.Connection {
+			conn := mock.NewMockConnection(ctrl)
+			conn.EXPECT().ID().Return(id).AnyTimes()
+			conn.EXPECT().RemoteAddr().DoAndReturn(func() net.Addr {
+				addr, _ := net.ResolveTCPAddr("tcp", remote)
+				return addr
+			}).AnyTimes()
+			return conn
+		}).AnyTimes()
+		sconn := mock.NewMockServerStreamConnection(ctrl)
+		sconn.EXPECT().Protocol().Return(protocol.HTTP1).AnyTimes()
+		return &proxy{
+			readCallbacks:    cb,
+			serverStreamConn: sconn,
+			activeStreams:    list.New(),
+			listenerName:     listener,
+			createdAt:        time.Now().Add(-2 * time.Second),
+		}
+	}
+	p1 := newDumpProxy(2, "127.0.0.1:10001", "listener_1")
+	p2 := newDumpProxy(1, "127.0.0.1:10002", "listener_2")
+	registerActiveProxy(p1)
+	registerActiveProxy(p2)
+
+	// an in-flight stream is waiting for the upstream response
+	route := mock.NewMockRoute(ctrl)
+	route.EXPECT().RouteRule().DoAndReturn(func() api.RouteRule {
+		rule := mock.NewMockRouteRule(ctrl)
+		rule.EXPECT().VirtualHost().DoAndReturn(func() api.VirtualHost {
+			vh := mock.NewMockVirtualHost(ctrl)
+			vh.EXPECT().Name().Return("test_vhost").AnyTimes()
+			return vh
+		}).AnyTimes()
+		return rule
+	}).AnyTimes()
+	cluster := mock.NewMockClusterInfo(ctrl)
+	cluster.EXPECT().Name().Return("test_cluster").AnyTimes()
+	host := mock.NewMockHost(ctrl)
+	host.EXPECT().AddressString().Return("127.0.0.1:8080").AnyTimes()
+	waiting := &downStream{
+		ID:          11,
+		proxy:       p1,
+		route:       route,
+		cluster:     cluster,
+		requestInfo: network.NewRequestInfo(),
+		phase:       types.WaitNotify,
+		context:     context.Background(),
+	}
+	waiting.upstreamRequest = &upstreamRequest{
+		downStream: waiting,
+		host:       host,
+	}
+	waiting.element = p1.activeStreams.PushBack(waiting)
+	// an in-flight stream is running the receiver filters, no route is matched yet
+	filtering := &downStream{
+		ID:          10,
+		proxy:       p1,
+		requestInfo: network.NewRequestInfo(),
+		phase:       types.DownFilter,
+	}
+	filtering.element = p1.activeStreams.PushBack(filtering)
+	// the route is matched, but the rule and the upstream host are not set
+	nilRoute := mock.NewMockRoute(ctrl)
+	nilRoute.EXPECT().RouteRule().Return((*mock.MockRouteRule)(nil)).AnyTimes()
+	matched := &downStream{
+		ID:              12,
+		proxy:           p1,
+		route:           nilRoute,
+		phase:           types.MatchRoute,
+		upstreamRequest: &upstreamRequest{},
+	}
+	matched.element = p1.activeStreams.PushBack(matched)
+
+	conns := DumpConnections()
+	require.Len(t, conns, 2)
+	require.Equal(t, ConnectionInfo{
+		ID:            1,
+		Listener:      "listener_2",
+		RemoteAddress: "127.0.0.1:10002",
+		Protocol:      string(protocol.HTTP1),
+		Age:           conns[0].Age,
+		ActiveStreams: 0,
+	}, conns[0])
+	require.Equal(t, uint64(2), conns[1].ID)
+	require.Equal(t, "listener_1", conns[1].Listener)
+	require.Equal(t, "127.0.0.1:10001", conns[1].RemoteAddress)
+	require.Equal(t, 3, conns[1].ActiveStreams)
+	require.True(t, conns[1].Age >= 2000)
+
+	time.Sleep(10 * time.Millisecond)
+	streams := DumpStreams()
+	require.Len(t, streams, 3)
+	require.Equal(t, StreamInfo{
+		ID:           10,
+		ConnectionID: 2,
+		Listener:     "listener_1",
+		Phase:        "DownFilter",
+		Elapsed:      streams[0].Elapsed,
+	}, streams[0])
+	require.Equal(t, StreamInfo{
+		ID:           11,
+		ConnectionID: 2,
+		Listener:     "listener_1",
+		Phase:        "WaitNotify",
+		VirtualHost:  "test_vhost",
+		Cluster:      "test_cluster",
+		UpstreamHost: "127.0.0.1:8080",
+		Elapsed:      streams[1].Elapsed,
+	}, streams[1])
+	require.True(t, streams[1].Elapsed >= 10)
+	require.Equal(t, StreamInfo{
+		ID:           12,
+		ConnectionID: 2,
+		Listener:     "listener_1",
+		Phase:        "MatchRoute",
+	}, streams[2])
+	// json output
+	data, err := json.Marshal(streams[1])
+	require.Nil(t, err)
+	out := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(data, &out))
+	require.Equal(t, "WaitNotify", out["phase"])
+	require.Equal(t, "test_vhost", out["virtual_host"])
+	require.Equal(t, "test_cluster", out["cluster"])
+	require.Equal(t, "127.0.0.1:8080", out["upstream_host"])
+	require.Equal(t, float64(2), out["connection_id"])
+	require.Contains(t, out, "elapsed_ms")
+
+	// the finished stream is not dumped
+	p1.deleteActiveStream(waiting)
+	streams = DumpStreams()
+	require.Len(t, streams, 2)
+	require.Equal(t, uint32(10), streams[0].ID)
+
+	// the closed connection is not dumped
+	p2.stats = globalStats
+	p2.listenerStats = newListenerStats("listener_2")
+	p2.onDownstreamEvent(api.RemoteClose)
+	conns = DumpConnections()
+	require.Len(t, conns, 1)
+	require.Equal(t, uint64(2), conns[0].ID)
+}
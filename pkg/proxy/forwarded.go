@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net"
+	"strings"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+const (
+	headerXForwardedFor   = "x-forwarded-for"
+	headerXForwardedProto = "x-forwarded-proto"
+	headerForwarded       = "forwarded"
+)
+
+// setForwardedHeaders appends the downstream remote address to the forwarded headers of the http request,
+// and records the real client address skipping the trusted hops of the x-forwarded-for.
+func (s *downStream) setForwardedHeaders() {
+	if s.proxy == nil || s.proxy.config == nil || s.proxy.config.ForwardedHeaders == nil || s.downstreamReqHeaders == nil {
+		return
+	}
+	switch s.getDownstreamProtocol() {
+	case protocol.HTTP1, protocol.HTTP2:
+	default:
+		return
+	}
+	config := s.proxy.config.ForwardedHeaders
+	remote := addressIP(s.requestInfo.DownstreamRemoteAddress())
+	if remote == "" {
+		return
+	}
+
+	xff := parseForwardedFor(s.downstreamReqHeaders)
+	// the remote address is always the last hop, no matter it is appended or not
+	hops := append(xff, remote)
+	if !config.SkipXffAppend {
+		s.downstreamReqHeaders.Set(headerXForwardedFor, strings.Join(hops, ", "))
+	}
+	client := remote
+	if config.XffNumTrustedHops > 0 {
+		// not enough hops means the x-forwarded-for is not trusted, use the remote address instead
+		if idx := len(hops) - 1 - int(config.XffNumTrustedHops); idx >= 0 {
+			client = hops[idx]
+		}
+	}
+	if err := variable.SetString(s.context, types.VarProxyClientAddress, client); err != nil {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] set client address variable failed: %v", err)
+	}
+
+	scheme := s.downstreamScheme()
+	// the x-forwarded-proto set by the trusted proxies is kept
+	if _, ok := s.downstreamReqHeaders.Get(headerXForwardedProto); !ok || config.XffNumTrustedHops == 0 {
+		s.downstreamReqHeaders.Set(headerXForwardedProto, scheme)
+	}
+
+	if config.UseForwarded {
+		element := "for=" + forwardedNode(remote) + ";proto=" + scheme
+		if forwarded, ok := s.downstreamReqHeaders.Get(headerForwarded); ok && forwarded != "" {
+			element = forwarded + ", " + element
+		}
+		s.downstreamReqHeaders.Set(headerForwarded, element)
+	}
+}
+
+// downstreamScheme returns the scheme of the downstream connection
+func (s *downStream) downstreamScheme() string {
+	if s.proxy.readCallbacks != nil {
+		if _, ok := s.proxy.readCallbacks.Connection().RawConn().(*mtls.TLSConn); ok {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// parseForwardedFor returns the addresses in the x-forwarded-for header
+func parseForwardedFor(headers types.HeaderMap) []string {
+	value, ok := headers.Get(headerXForwardedFor)
+	if !ok || value == "" {
+		return nil
+	}
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// addressIP returns the ip of the address without the port, empty string means not an ip address
+func addressIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case nil:
+		return ""
+	case *net.TCPAddr:
+		return a.IP.String()
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil || net.ParseIP(host) == nil {
+			return ""
+		}
+		return host
+	}
+}
+
+// forwardedNode formats the ip as the node of the forwarded header, see rfc 7239 section 6
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+func TestSetForwardedHeaders(t *testing.T) {
+	newDownstream := func(config *v2.ForwardedHeadersConfig, proto types.ProtocolName, remote net.Addr, headers types.HeaderMap) *downStream {
+		info := network.NewRequestInfo()
+		info.SetDownstreamRemoteAddress(remote)
+		return &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			proxy: &proxy{
+				config: &v2.Proxy{
+					DownstreamProtocol: string(proto),
+					ForwardedHeaders:   config,
+				},
+			},
+			requestInfo:          info,
+			downstreamReqHeaders: headers,
+		}
+	}
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.3"), Port: 34567}
+	clientAddress := func(s *downStream) string {
+		addr, _ := variable.GetString(s.context, types.VarProxyClientAddress)
+		return addr
+	}
+
+	t.Run("append", func(t *testing.T) {
+		headers := protocol.CommonHeader{}
+		s := newDownstream(&v2.ForwardedHeadersConfig{}, protocol.HTTP1, remote, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, "10.0.0.3", headers[headerXForwardedFor])
+		assert.Equal(t, "http", headers[headerXForwardedProto])
+		assert.Equal(t, "10.0.0.3", clientAddress(s))
+		_, ok := headers[headerForwarded]
+		assert.False(t, ok)
+
+		headers = protocol.CommonHeader{
+			headerXForwardedFor:   "10.0.0.1,10.0.0.2",
+			headerXForwardedProto: "https",
+		}
+		s = newDownstream(&v2.ForwardedHeadersConfig{}, protocol.HTTP2, remote, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, "10.0.0.1, 10.0.0.2, 10.0.0.3", headers[headerXForwardedFor])
+		// the downstream is not trusted
+		assert.Equal(t, "http", headers[headerXForwardedProto])
+		assert.Equal(t, "10.0.0.3", clientAddress(s))
+	})
+
+	t.Run("skip append", func(t *testing.T) {
+		headers := protocol.CommonHeader{headerXForwardedFor: "10.0.0.1"}
+		s := newDownstream(&v2.ForwardedHeadersConfig{SkipXffAppend: true, XffNumTrustedHops: 1}, protocol.HTTP1, remote, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, "10.0.0.1", headers[headerXForwardedFor])
+		assert.Equal(t, "10.0.0.1", clientAddress(s))
+	})
+
+	t.Run("trusted hops", func(t *testing.T) {
+		headers := protocol.CommonHeader{
+			headerXForwardedFor:   "192.168.1.1, 10.0.0.1, 10.0.0.2",
+			headerXForwardedProto: "https",
+		}
+		s := newDownstream(&v2.ForwardedHeadersConfig{XffNumTrustedHops: 2}, protocol.HTTP1, remote, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, "192.168.1.1, 10.0.0.1, 10.0.0.2, 10.0.0.3", headers[headerXForwardedFor])
+		assert.Equal(t, "10.0.0.1", clientAddress(s))
+		// set by the trusted proxies
+		assert.Equal(t, "https", headers[headerXForwardedProto])
+
+		// not enough hops
+		headers = protocol.CommonHeader{headerXForwardedFor: "10.0.0.2"}
+		s = newDownstream(&v2.ForwardedHeadersConfig{XffNumTrustedHops: 2}, protocol.HTTP1, remote, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, "10.0.0.3", clientAddress(s))
+		assert.Equal(t, "http", headers[headerXForwardedProto])
+	})
+
+	t.Run("forwarded", func(t *testing.T) {
+		headers := protocol.CommonHeader{}
+		s := newDownstream(&v2.ForwardedHeadersConfig{UseForwarded: true}, protocol.HTTP1, remote, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, "for=10.0.0.3;proto=http", headers[headerForwarded])
+
+		headers = protocol.CommonHeader{headerForwarded: "for=192.0.2.60;proto=https"}
+		ipv6 := &net.TCPAddr{IP: net.ParseIP("2001:db8:cafe::17"), Port: 4711}
+		s = newDownstream(&v2.ForwardedHeadersConfig{UseForwarded: true}, protocol.HTTP1, ipv6, headers)
+		s.setForwardedHeaders()
+		assert.Equal(t, `for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]";proto=http`, headers[headerForwarded])
+		assert.Equal(t, "2001:db8:cafe::17", headers[headerXForwardedFor])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		headers := protocol.CommonHeader{}
+		newDownstream(nil, protocol.HTTP1, remote, headers).setForwardedHeaders()
+		assert.Len(t, headers, 0)
+		// not a http request
+		newDownstream(&v2.ForwardedHeadersConfig{}, types.ProtocolName("bolt"), remote, headers).setForwardedHeaders()
+		assert.Len(t, headers, 0)
+	})
+}
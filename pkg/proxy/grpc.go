@@ -0,0 +1,268 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+const (
+	headerGrpcStatus  = "grpc-status"
+	headerContentType = "content-type"
+	grpcContentType   = "application/grpc"
+
+	// grpcMessagePrefixLen is the length of the message prefix, 1 byte compressed flag and 4 bytes message length
+	grpcMessagePrefixLen = 5
+)
+
+// grpcStatusToHTTP maps the grpc status codes to the http status codes,
+// see https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+var grpcStatusToHTTP = map[int]int{
+	0:  http.StatusOK,                  // OK
+	1:  499,                            // CANCELLED
+	2:  http.StatusInternalServerError, // UNKNOWN
+	3:  http.StatusBadRequest,          // INVALID_ARGUMENT
+	4:  http.StatusGatewayTimeout,      // DEADLINE_EXCEEDED
+	5:  http.StatusNotFound,            // NOT_FOUND
+	6:  http.StatusConflict,            // ALREADY_EXISTS
+	7:  http.StatusForbidden,           // PERMISSION_DENIED
+	8:  http.StatusTooManyRequests,     // RESOURCE_EXHAUSTED
+	9:  http.StatusBadRequest,          // FAILED_PRECONDITION
+	10: http.StatusConflict,            // ABORTED
+	11: http.StatusBadRequest,          // OUT_OF_RANGE
+	12: http.StatusNotImplemented,      // UNIMPLEMENTED
+	13: http.StatusInternalServerError, // INTERNAL
+	14: http.StatusServiceUnavailable,  // UNAVAILABLE
+	15: http.StatusInternalServerError, // DATA_LOSS
+	16: http.StatusUnauthorized,        // UNAUTHENTICATED
+}
+
+// parseGrpcStatus returns the grpc status of a grpc response, the status is carried in the trailers,
+// or in the headers if the response is trailers-only.
+func parseGrpcStatus(headers, trailers types.HeaderMap) (int, bool) {
+	if headers == nil {
+		return 0, false
+	}
+	if ct, ok := headers.Get(headerContentType); !ok || !strings.HasPrefix(ct, grpcContentType) {
+		return 0, false
+	}
+	value, ok := "", false
+	if trailers != nil {
+		value, ok = trailers.Get(headerGrpcStatus)
+	}
+	if !ok {
+		value, ok = headers.Get(headerGrpcStatus)
+	}
+	if !ok {
+		return 0, false
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || status < 0 {
+		return 0, false
+	}
+	return status, true
+}
+
+// isGrpcFailure returns true if the grpc status is regarded as a server error
+func isGrpcFailure(status int) bool {
+	code, ok := grpcStatusToHTTP[status]
+	if !ok {
+		return true
+	}
+	return code >= http.StatusInternalServerError
+}
+
+// recordGrpcStatus records the grpc status of the response on the request info and the variable,
+// so the metrics, the access logs and the retry policy reflect the grpc result.
+func (s *downStream) recordGrpcStatus(headers, trailers types.HeaderMap) {
+	status, ok := parseGrpcStatus(headers, trailers)
+	if !ok {
+		return
+	}
+	if recorder, ok := s.requestInfo.(types.GrpcStatusRecorder); ok {
+		recorder.SetGrpcStatus(status)
+	}
+	if err := variable.SetString(s.context, types.VarGrpcStatus, strconv.Itoa(status)); err != nil {
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] set grpc status variable failed: %v", err)
+	}
+}
+
+// isGrpcRequest returns true if the request is a grpc request
+func isGrpcRequest(headers types.HeaderMap) bool {
+	if headers == nil {
+		return false
+	}
+	ct, ok := headers.Get(headerContentType)
+	return ok && strings.HasPrefix(ct, grpcContentType)
+}
+
+// grpcMessageStats is the stats of the grpc messages of a cluster and method
+type grpcMessageStats struct {
+	RequestMessageTotal  gometrics.Counter
+	RequestMessageBytes  gometrics.Counter
+	ResponseMessageTotal gometrics.Counter
+	ResponseMessageBytes gometrics.Counter
+}
+
+// grpcMessageStatsCache caches the stats by cluster and method, avoids the lock of the metrics store
+var grpcMessageStatsCache sync.Map
+
+// maxGrpcMethods bounds the method label values of a cluster,
+// the methods beyond the bound are recorded as "other"
+var maxGrpcMethods = defaultMaxLabelValues
+
+// grpcMethods records the method label values by cluster name
+var grpcMethods sync.Map
+
+type grpcMethodSet struct {
+	mux     sync.Mutex
+	methods map[string]struct{}
+}
+
+// grpcMethodLabel returns the method label value of the request path, the path which is not
+// a grpc method such as /package.Service/Method, and the methods beyond the bound are recorded as "other"
+func grpcMethodLabel(clusterName string, path string) string {
+	method := strings.TrimPrefix(path, "/")
+	i := strings.IndexByte(method, '/')
+	if i <= 0 || i == len(method)-1 || strings.IndexByte(method[i+1:], '/') >= 0 || method == otherLabelValue {
+		return otherLabelValue
+	}
+	v, _ := grpcMethods.LoadOrStore(clusterName, &grpcMethodSet{
+		methods: map[string]struct{}{},
+	})
+	set := v.(*grpcMethodSet)
+	set.mux.Lock()
+	defer set.mux.Unlock()
+	if _, ok := set.methods[method]; ok {
+		return method
+	}
+	if len(set.methods) >= maxGrpcMethods {
+		return otherLabelValue
+	}
+	set.methods[method] = struct{}{}
+	return method
+}
+
+func getGrpcMessageStats(clusterName string, method string) *grpcMessageStats {
+	key := clusterName + "|" + method
+	if v, ok := grpcMessageStatsCache.Load(key); ok {
+		return v.(*grpcMessageStats)
+	}
+	s := metrics.NewGrpcMessageStats(clusterName, method)
+	stats := &grpcMessageStats{
+		RequestMessageTotal:  s.Counter(metrics.GrpcRequestMessageTotal),
+		RequestMessageBytes:  s.Counter(metrics.GrpcRequestMessageBytes),
+		ResponseMessageTotal: s.Counter(metrics.GrpcResponseMessageTotal),
+		ResponseMessageBytes: s.Counter(metrics.GrpcResponseMessageBytes),
+	}
+	v, _ := grpcMessageStatsCache.LoadOrStore(key, stats)
+	return v.(*grpcMessageStats)
+}
+
+// grpcMessageCounter counts the length-prefixed grpc messages of a stream direction,
+// a message may be split into several data frames and a data frame may contain several messages.
+type grpcMessageCounter struct {
+	// prefix holds the received bytes of an incomplete message prefix
+	prefix []byte
+	// remaining is the length of the current message payload not received yet
+	remaining uint32
+}
+
+// count returns the number of the messages completed in the data
+func (c *grpcMessageCounter) count(data []byte) int {
+	messages := 0
+	for len(data) > 0 {
+		if c.remaining > 0 {
+			n := uint32(len(data))
+			if n > c.remaining {
+				n = c.remaining
+			}
+			c.remaining -= n
+			data = data[n:]
+			if c.remaining == 0 {
+				messages++
+			}
+			continue
+		}
+		need := grpcMessagePrefixLen - len(c.prefix)
+		if len(data) < need {
+			c.prefix = append(c.prefix, data...)
+			break
+		}
+		c.prefix = append(c.prefix, data[:need]...)
+		data = data[need:]
+		c.remaining = binary.BigEndian.Uint32(c.prefix[1:])
+		c.prefix = c.prefix[:0]
+		// the empty message is completed with the prefix
+		if c.remaining == 0 {
+			messages++
+		}
+	}
+	return messages
+}
+
+// grpcMessageRecorder records the messages and bytes of the grpc request and response
+type grpcMessageRecorder struct {
+	stats    *grpcMessageStats
+	request  grpcMessageCounter
+	response grpcMessageCounter
+}
+
+// newGrpcMessageRecorder returns a recorder for the grpc request, nil means the request is not a grpc request.
+// the metrics are labeled by the cluster and the method, see grpcMethodLabel.
+func newGrpcMessageRecorder(ctx context.Context, headers types.HeaderMap, cluster types.ClusterInfo) *grpcMessageRecorder {
+	if cluster == nil || !isGrpcRequest(headers) {
+		return nil
+	}
+	path, _ := variable.GetString(ctx, types.VarPath)
+	return &grpcMessageRecorder{
+		stats: getGrpcMessageStats(cluster.Name(), grpcMethodLabel(cluster.Name(), path)),
+	}
+}
+
+func (r *grpcMessageRecorder) recordRequest(data buffer.IoBuffer) {
+	if data == nil || data.Len() == 0 {
+		return
+	}
+	r.stats.RequestMessageBytes.Inc(int64(data.Len()))
+	if n := r.request.count(data.Bytes()); n > 0 {
+		r.stats.RequestMessageTotal.Inc(int64(n))
+	}
+}
+
+func (r *grpcMessageRecorder) recordResponse(data buffer.IoBuffer) {
+	if data == nil || data.Len() == 0 {
+		return
+	}
+	r.stats.ResponseMessageBytes.Inc(int64(data.Len()))
+	if n := r.response.count(data.Bytes()); n > 0 {
+		r.stats.ResponseMessageTotal.Inc(int64(n))
+	}
+}
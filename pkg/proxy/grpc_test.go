@@ -0,0 +1,198 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+func TestParseGrpcStatus(t *testing.T) {
+	grpcHeaders := protocol.CommonHeader{"content-type": "application/grpc+proto"}
+	testcases := []struct {
+		headers  types.HeaderMap
+		trailers types.HeaderMap
+		status   int
+		ok       bool
+	}{
+		{grpcHeaders, protocol.CommonHeader{"grpc-status": "0"}, 0, true},
+		{grpcHeaders, protocol.CommonHeader{"grpc-status": "14"}, 14, true},
+		// trailers-only response
+		{protocol.CommonHeader{"content-type": "application/grpc", "grpc-status": "5"}, nil, 5, true},
+		{grpcHeaders, protocol.CommonHeader{"grpc-status": "abc"}, 0, false},
+		{grpcHeaders, nil, 0, false},
+		// not a grpc response
+		{protocol.CommonHeader{"content-type": "application/json"}, protocol.CommonHeader{"grpc-status": "14"}, 0, false},
+		{nil, nil, 0, false},
+	}
+	for i, tc := range testcases {
+		status, ok := parseGrpcStatus(tc.headers, tc.trailers)
+		assert.Equal(t, tc.ok, ok, "case %d", i)
+		assert.Equal(t, tc.status, status, "case %d", i)
+	}
+}
+
+func TestRecordGrpcStatus(t *testing.T) {
+	testcases := []struct {
+		status string
+		failed bool
+	}{
+		{"0", false},
+		{"5", false},
+		{"2", true},
+		{"4", true},
+		{"14", true},
+		{"99", true},
+	}
+	for _, tc := range testcases {
+		s := &downStream{
+			context:     variable.NewVariableContext(context.Background()),
+			requestInfo: network.NewRequestInfo(),
+		}
+		s.requestInfo.SetResponseCode(200)
+		s.recordGrpcStatus(protocol.CommonHeader{"content-type": "application/grpc"},
+			protocol.CommonHeader{"grpc-status": tc.status})
+
+		status, ok := s.requestInfo.(types.GrpcStatusRecorder).GrpcStatus()
+		require.True(t, ok)
+		v, err := variable.GetString(s.context, types.VarGrpcStatus)
+		require.Nil(t, err)
+		assert.Equal(t, tc.status, v)
+		assert.Equal(t, tc.status, strconv.Itoa(status))
+		assert.Equal(t, tc.failed, s.upstreamResponseFailed(), "grpc status %s", tc.status)
+	}
+
+	// the http response is not recorded
+	s := &downStream{
+		context:     variable.NewVariableContext(context.Background()),
+		requestInfo: network.NewRequestInfo(),
+	}
+	s.requestInfo.SetResponseCode(200)
+	s.recordGrpcStatus(protocol.CommonHeader{"content-type": "text/plain"}, nil)
+	_, ok := s.requestInfo.(types.GrpcStatusRecorder).GrpcStatus()
+	assert.False(t, ok)
+	assert.False(t, s.upstreamResponseFailed())
+}
+
+// grpcMessage returns a length-prefixed grpc message with the payload
+func grpcMessage(payload string) []byte {
+	msg := make([]byte, grpcMessagePrefixLen, grpcMessagePrefixLen+len(payload))
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(payload)))
+	return append(msg, payload...)
+}
+
+func TestGrpcMessageCounter(t *testing.T) {
+	var stream []byte
+	for _, payload := range []string{"hello", "", "grpc streaming message"} {
+		stream = append(stream, grpcMessage(payload)...)
+	}
+	// all the messages in one frame
+	c := &grpcMessageCounter{}
+	assert.Equal(t, 3, c.count(stream))
+
+	// the messages and the prefixes are split into several frames
+	for _, size := range []int{1, 2, 3, 7, 11} {
+		c := &grpcMessageCounter{}
+		messages := 0
+		for i := 0; i < len(stream); i += size {
+			end := i + size
+			if end > len(stream) {
+				end = len(stream)
+			}
+			messages += c.count(stream[i:end])
+		}
+		assert.Equal(t, 3, messages, "frame size %d", size)
+		assert.Equal(t, uint32(0), c.remaining)
+		assert.Len(t, c.prefix, 0)
+	}
+
+	// the incomplete message is not counted
+	c = &grpcMessageCounter{}
+	assert.Equal(t, 0, c.count(grpcMessage("incomplete")[:8]))
+}
+
+func TestGrpcMessageRecorder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cluster := mock.NewMockClusterInfo(ctrl)
+	cluster.EXPECT().Name().Return("grpc_cluster").AnyTimes()
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, types.VarPath, "/helloworld.Greeter/SayHello")
+
+	// not a grpc request
+	assert.Nil(t, newGrpcMessageRecorder(ctx, protocol.CommonHeader{"content-type": "application/json"}, cluster))
+
+	headers := protocol.CommonHeader{"content-type": "application/grpc+proto"}
+	r := newGrpcMessageRecorder(ctx, headers, cluster)
+	require.NotNil(t, r)
+	// the stats are shared by the streams of the same cluster and method
+	assert.Equal(t, r.stats, newGrpcMessageRecorder(ctx, headers, cluster).stats)
+
+	// client streaming, the second message is split into two frames
+	second := grpcMessage("second request")
+	r.recordRequest(buffer.NewIoBufferBytes(append(grpcMessage("first request"), second[:4]...)))
+	assert.Equal(t, int64(1), r.stats.RequestMessageTotal.Count())
+	r.recordRequest(buffer.NewIoBufferBytes(second[4:]))
+	assert.Equal(t, int64(2), r.stats.RequestMessageTotal.Count())
+	assert.Equal(t, int64(len("first request")+len("second request")+2*grpcMessagePrefixLen), r.stats.RequestMessageBytes.Count())
+
+	// server streaming
+	for i := 0; i < 3; i++ {
+		r.recordResponse(buffer.NewIoBufferBytes(grpcMessage("response")))
+	}
+	r.recordResponse(nil)
+	assert.Equal(t, int64(3), r.stats.ResponseMessageTotal.Count())
+	assert.Equal(t, int64(3*(len("response")+grpcMessagePrefixLen)), r.stats.ResponseMessageBytes.Count())
+
+	// the metrics are labeled by the cluster and the method
+	stats := metrics.NewGrpcMessageStats("grpc_cluster", "helloworld.Greeter/SayHello")
+	assert.Equal(t, int64(2), stats.Counter(metrics.GrpcRequestMessageTotal).Count())
+}
+
+func TestGrpcMethodLabel(t *testing.T) {
+	defer func(max int) {
+		maxGrpcMethods = max
+	}(maxGrpcMethods)
+	maxGrpcMethods = 2
+
+	assert.Equal(t, "helloworld.Greeter/SayHello", grpcMethodLabel("label_cluster", "/helloworld.Greeter/SayHello"))
+	// the path which is not a grpc method
+	for _, path := range []string{"", "/", "/helloworld.Greeter", "/helloworld.Greeter/", "//SayHello", "/a/b/c", "/other"} {
+		assert.Equal(t, otherLabelValue, grpcMethodLabel("label_cluster", path), "path: %s", path)
+	}
+	// the methods beyond the bound of the cluster
+	assert.Equal(t, "helloworld.Greeter/SayBye", grpcMethodLabel("label_cluster", "/helloworld.Greeter/SayBye"))
+	assert.Equal(t, otherLabelValue, grpcMethodLabel("label_cluster", "/helloworld.Greeter/SayAgain"))
+	assert.Equal(t, "helloworld.Greeter/SayHello", grpcMethodLabel("label_cluster", "/helloworld.Greeter/SayHello"))
+	// the bound is per cluster
+	assert.Equal(t, "helloworld.Greeter/SayAgain", grpcMethodLabel("label_cluster2", "/helloworld.Greeter/SayAgain"))
+}
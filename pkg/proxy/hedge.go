@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+	"mosn.io/pkg/variable"
+)
+
+// maxHedgeChooseHostTimes is the max times to choose a host different from the upstream request
+const maxHedgeChooseHostTimes = 3
+
+// setupHedgeTimer starts the hedge timer after the upstream request is sent,
+// a hedged request is sent if the upstream request has not responded in the hedge delay.
+func (s *downStream) setupHedgeTimer() {
+	if s.retryState == nil || s.retryState.hedgeDelay <= 0 || s.hedgeRequest != nil {
+		return
+	}
+	s.stopHedgeTimer()
+
+	ID := atomic.LoadUint32(&s.ID)
+	s.hedgeTimer = utils.NewTimer(s.retryState.hedgeDelay,
+		func() {
+			atomic.StoreUint32(&s.reuseBuffer, 0)
+
+			if atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+				return
+			}
+			if ID != atomic.LoadUint32(&s.ID) {
+				return
+			}
+			if atomic.LoadUint32(&s.upstreamResponseReceived) == 1 {
+				return
+			}
+			atomic.StoreUint32(&s.hedgeTriggered, 1)
+			s.sendNotify()
+		})
+}
+
+func (s *downStream) stopHedgeTimer() {
+	if s.hedgeTimer != nil {
+		s.hedgeTimer.Stop()
+		s.hedgeTimer = nil
+	}
+	atomic.StoreUint32(&s.hedgeTriggered, 0)
+}
+
+// shouldHedge returns true if the stream is woken up by the hedge timer, and the upstream request is still in flight
+func (s *downStream) shouldHedge() bool {
+	if !atomic.CompareAndSwapUint32(&s.hedgeTriggered, 1, 0) {
+		return false
+	}
+	return s.hedgeRequest == nil && s.upstreamRequest != nil && !s.processDone() &&
+		atomic.LoadUint32(&s.downstreamCleaned) == 0 &&
+		atomic.LoadUint32(&s.upstreamResponseReceived) == 0
+}
+
+// doHedge sends a hedged request, the response received first is sent to the downstream
+func (s *downStream) doHedge() {
+	if !s.retryState.hedge(s.context) {
+		return
+	}
+
+	host, pool := s.chooseHedgeHost()
+	if pool == nil {
+		s.retryState.releaseHedge()
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] no healthy upstream for hedged request in cluster %s", s.cluster.Name())
+		return
+	}
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(s.context, "[proxy] [downstream] send hedged request, proxyId: %d, host: %s", s.ID, host.AddressString())
+	}
+
+	// the upstream requests are in flight at the same time, so the hedged request
+	// uses its own buffers, and the stream buffers should not be reused.
+	atomic.StoreUint32(&s.reuseBuffer, 0)
+	ctx := buffer.NewBufferPoolContext(buffer.CleanBufferPoolContext(s.context))
+	s.hedgeContexts = append(s.hedgeContexts, ctx)
+	s.hedgeRequest = &upstreamRequest{
+		downStream: s,
+		proxy:      s.proxy,
+		connPool:   pool,
+		host:       host,
+		protocol:   s.upstreamRequest.protocol,
+		context:    ctx,
+	}
+	atomic.StoreInt32(&s.hedgeInflight, 2)
+
+	s.hedgeRequest.appendHeaders(s.downstreamReqDataBuf == nil && s.downstreamReqTrailers == nil)
+
+	if s.downstreamReqDataBuf != nil {
+		s.downstreamReqDataBuf.Count(1)
+		s.hedgeRequest.appendData(s.downstreamReqTrailers == nil)
+	}
+
+	if s.downstreamReqTrailers != nil {
+		s.hedgeRequest.appendTrailers()
+	}
+
+	// the per try timeout covers both of the upstream requests
+	s.setupPerReqTimeout()
+}
+
+// chooseHedgeHost chooses a host for the hedged request, a host different from the upstream request is preferred
+func (s *downStream) chooseHedgeHost() (types.Host, types.ConnectionPool) {
+	var (
+		host types.Host
+		pool types.ConnectionPool
+	)
+	for i := 0; i < maxHedgeChooseHostTimes; i++ {
+		pool, host = s.proxy.clusterManager.ConnPoolForCluster(s, s.snapshot, s.upstreamRequest.protocol)
+		if pool == nil {
+			return nil, nil
+		}
+		if host.AddressString() != s.upstreamRequest.host.AddressString() {
+			break
+		}
+	}
+	return host, pool
+}
+
+// settleHedge keeps the upstream request which responds first, and cancels the other one.
+// if both of the requests are reset, the last one is kept to handle the reset.
+func (s *downStream) settleHedge() {
+	hedge := s.hedgeRequest
+	if hedge == nil {
+		return
+	}
+	s.hedgeRequest = nil
+	atomic.StoreInt32(&s.hedgeInflight, 0)
+	s.retryState.releaseHedge()
+
+	winner, loser := s.upstreamRequest, hedge
+	if hedge.responded || atomic.LoadUint32(&s.upstreamRequest.failed) == 1 {
+		winner, loser = hedge, s.upstreamRequest
+	}
+	loser.cancel()
+	s.upstreamRequest = winner
+
+	s.requestInfo.OnUpstreamHostSelected(winner.host)
+	s.requestInfo.SetUpstreamLocalAddress(winner.host.AddressString())
+	if winner.responded {
+		// the status variable may be overwritten by the loser's response, which shares the variables
+		if code := s.requestInfo.ResponseCode(); code > 0 {
+			variable.SetString(s.context, types.VarHeaderStatus, strconv.Itoa(code))
+		}
+	}
+}
+
+// giveHedgeContexts gives the buffers of the hedged requests back to the buffer pool,
+// it is called when the stream is cleaned, all of the upstream requests are finished or cancelled.
+// the same as the stream buffers, the buffers are not recycled if the stream is reset.
+func (s *downStream) giveHedgeContexts() {
+	contexts := s.hedgeContexts
+	s.hedgeContexts = nil
+	if atomic.LoadUint32(&s.upstreamReset) == 1 || atomic.LoadUint32(&s.downstreamReset) == 1 {
+		return
+	}
+	for _, ctx := range contexts {
+		if poolCtx := buffer.PoolContext(ctx); poolCtx != nil {
+			poolCtx.Give()
+		}
+	}
+}
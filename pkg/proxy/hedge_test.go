@@ -0,0 +1,307 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/streamfilter"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+// countResponseSender counts the responses sent to the downstream
+type countResponseSender struct {
+	mockResponseSender
+	count int32
+}
+
+func (s *countResponseSender) AppendHeaders(ctx context.Context, headers api.HeaderMap, endStream bool) error {
+	atomic.AddInt32(&s.count, 1)
+	return s.mockResponseSender.AppendHeaders(ctx, headers, endStream)
+}
+
+func gomockHedgeClusterInfo(ctrl *gomock.Controller) types.ClusterInfo {
+	info := mock.NewMockClusterInfo(ctrl)
+	s := metrics.NewClusterStats("mockcluster")
+	info.EXPECT().Name().Return("mockcluster").AnyTimes()
+	info.EXPECT().Stats().Return(types.ClusterStats{
+		UpstreamRequestDuration:      s.Histogram(metrics.UpstreamRequestDuration),
+		UpstreamRequestDurationTotal: s.Counter(metrics.UpstreamRequestDurationTotal),
+		UpstreamResponseSuccess:      s.Counter(metrics.UpstreamResponseSuccess),
+		UpstreamResponseFailed:       s.Counter(metrics.UpstreamResponseFailed),
+		UpstreamRequestRetry:         s.Counter(metrics.UpstreamRequestRetry),
+		UpstreamRequestRetryOverflow: s.Counter(metrics.UpstreamRequestRetryOverflow),
+		UpstreamRequestTimeout:       s.Counter(metrics.UpstreamRequestTimeout),
+
+		UpstreamRequestConnectFailureRetry: s.Counter(metrics.UpstreamRequestConnectFailureRetry),
+	}).AnyTimes()
+	r := mock.NewMockResource(ctrl)
+	r.EXPECT().CanCreate().Return(true).AnyTimes()
+	r.EXPECT().Increase().AnyTimes()
+	r.EXPECT().Decrease().AnyTimes()
+	mng := mock.NewMockResourceManager(ctrl)
+	mng.EXPECT().Retries().Return(r).AnyTimes()
+	info.EXPECT().ResourceManager().Return(mng).AnyTimes()
+	return info
+}
+
+func gomockHedgeHost(ctrl *gomock.Controller, addr string, info types.ClusterInfo) types.Host {
+	h := mock.NewMockHost(ctrl)
+	s := metrics.NewHostStats("mockcluster", addr)
+	h.EXPECT().HostStats().Return(types.HostStats{
+		UpstreamRequestDuration:      s.Histogram(metrics.UpstreamRequestDuration),
+		UpstreamRequestDurationTotal: s.Counter(metrics.UpstreamRequestDurationTotal),
+		UpstreamResponseFailed:       s.Counter(metrics.UpstreamResponseFailed),
+		UpstreamResponseSuccess:      s.Counter(metrics.UpstreamResponseSuccess),
+		UpstreamRequestTimeout:       s.Counter(metrics.UpstreamRequestTimeout),
+	}).AnyTimes()
+	h.EXPECT().AddressString().Return(addr).AnyTimes()
+	h.EXPECT().ClusterInfo().Return(info).AnyTimes()
+	return h
+}
+
+// gomockHedgeSender returns a request sender, reset records whether the stream is reset
+func gomockHedgeSender(ctrl *gomock.Controller, reset *int32) types.StreamSender {
+	stream := mock.NewMockStream(ctrl)
+	stream.EXPECT().AddEventListener(gomock.Any()).AnyTimes()
+	stream.EXPECT().RemoveEventListener(gomock.Any()).AnyTimes()
+	stream.EXPECT().ResetStream(gomock.Any()).Do(func(_ types.StreamResetReason) {
+		atomic.AddInt32(reset, 1)
+	}).AnyTimes()
+	sender := mock.NewMockStreamSender(ctrl)
+	sender.EXPECT().GetStream().Return(stream).AnyTimes()
+	sender.EXPECT().AppendHeaders(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	sender.EXPECT().AppendData(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	return sender
+}
+
+// newHedgeDownstream creates a downstream whose request is sent to the slow host,
+// the hedged request is sent to the fast host.
+func newHedgeDownstream(ctrl *gomock.Controller, hedgeDelay time.Duration, slowReset, fastReset *int32) (*downStream, *countResponseSender) {
+	ctx := variable.NewVariableContext(context.Background())
+	info := gomockHedgeClusterInfo(ctrl)
+	slowHost := gomockHedgeHost(ctrl, "127.0.0.1:8080", info)
+	fastHost := gomockHedgeHost(ctrl, "127.0.0.1:8081", info)
+
+	fastPool := mock.NewMockConnectionPool(ctrl)
+	fastPool.EXPECT().NewStream(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ types.StreamReceiveListener) (types.Host, types.StreamSender, types.PoolFailureReason) {
+			return fastHost, gomockHedgeSender(ctrl, fastReset), ""
+		}).AnyTimes()
+	clusterManager := mock.NewMockClusterManager(ctrl)
+	clusterManager.EXPECT().ConnPoolForCluster(gomock.Any(), gomock.Any(), gomock.Any()).Return(fastPool, fastHost).AnyTimes()
+
+	responseSender := &countResponseSender{}
+	s := &downStream{
+		ID:                   1,
+		context:              ctx,
+		cluster:              info,
+		responseSender:       responseSender,
+		requestInfo:          &network.RequestInfo{},
+		notify:               make(chan struct{}, 1),
+		downstreamReqHeaders: protocol.CommonHeader{},
+		proxy: &proxy{
+			config: &v2.Proxy{
+				DownstreamProtocol: "Http1",
+			},
+			clusterManager: clusterManager,
+			stats:          globalStats,
+			listenerStats:  newListenerStats("test"),
+		},
+		streamFilterChain: streamFilterChain{
+			DefaultStreamFilterChainImpl: &streamfilter.DefaultStreamFilterChainImpl{},
+		},
+	}
+	s.retryState = &retryState{
+		cluster:         info,
+		retryOn:         true,
+		retiesRemaining: 1,
+		hedgeDelay:      hedgeDelay,
+	}
+	s.upstreamRequest = &upstreamRequest{
+		downStream:    s,
+		proxy:         s.proxy,
+		host:          slowHost,
+		protocol:      protocol.HTTP1,
+		requestSender: gomockHedgeSender(ctrl, slowReset),
+	}
+	return s, responseSender
+}
+
+func TestHedgeFastHostWins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var slowReset, fastReset int32
+	s, responseSender := newHedgeDownstream(ctrl, 10*time.Millisecond, &slowReset, &fastReset)
+	s.setupHedgeTimer()
+
+	done := make(chan types.Phase)
+	go func() {
+		done <- s.receive(s.context, 1, types.WaitNotify)
+	}()
+
+	// wait the hedged request sent to the fast host
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&s.hedgeInflight) == 2
+	}, time.Second, 5*time.Millisecond)
+	hedge := s.hedgeRequest
+	slow := s.upstreamRequest
+	assert.Equal(t, "127.0.0.1:8081", hedge.host.AddressString())
+	assert.NotEqual(t, s.context, hedge.streamContext())
+
+	// the fast host responds first
+	variable.SetString(s.context, types.VarHeaderStatus, "200")
+	hedge.OnReceive(s.context, protocol.CommonHeader{"from": "fast"}, buffer.NewIoBufferString("fast"), nil)
+	// the slow host responds later, which should be ignored
+	slow.OnReceive(s.context, protocol.CommonHeader{"from": "slow"}, buffer.NewIoBufferString("slow"), nil)
+
+	select {
+	case phase := <-done:
+		assert.Equal(t, types.End, phase)
+	case <-time.After(time.Second):
+		t.Fatal("hedged response is not sent")
+	}
+
+	// only one response is sent to the downstream
+	assert.Equal(t, int32(1), atomic.LoadInt32(&responseSender.count))
+	from, _ := responseSender.headers.Get("from")
+	assert.Equal(t, "fast", from)
+	assert.Equal(t, "fast", responseSender.data.String())
+	assert.Equal(t, "127.0.0.1:8081", s.requestInfo.UpstreamLocalAddress())
+	// the losing request is cancelled
+	assert.Equal(t, int32(1), atomic.LoadInt32(&slowReset))
+	assert.True(t, slow.isCancelled())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fastReset))
+}
+
+func TestHedgeReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var slowReset, fastReset int32
+	s, _ := newHedgeDownstream(ctrl, 10*time.Millisecond, &slowReset, &fastReset)
+	atomic.StoreUint32(&s.hedgeTriggered, 1)
+	require.True(t, s.shouldHedge())
+	s.doHedge()
+	require.NotNil(t, s.hedgeRequest)
+	slow, hedge := s.upstreamRequest, s.hedgeRequest
+
+	// no retries remain for another hedged request
+	assert.False(t, s.retryState.hedge(s.context))
+
+	// the reset of the slow request is ignored, waits for the hedged request
+	slow.OnResetStream(types.StreamConnectionFailed)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&s.upstreamReset))
+	// the hedged request is reset too
+	hedge.OnResetStream(types.StreamConnectionTermination)
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&s.upstreamReset))
+	assert.Equal(t, types.StreamConnectionTermination, types.StreamResetReason(s.resetReason.Load()))
+
+	// the last reset request is kept to handle the reset
+	s.settleHedge()
+	assert.Equal(t, hedge, s.upstreamRequest)
+	assert.Nil(t, s.hedgeRequest)
+	assert.True(t, slow.isCancelled())
+}
+
+func TestHedgeTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var slowReset, fastReset int32
+	s, _ := newHedgeDownstream(ctrl, 10*time.Millisecond, &slowReset, &fastReset)
+	atomic.StoreUint32(&s.hedgeTriggered, 1)
+	require.True(t, s.shouldHedge())
+	s.doHedge()
+	slow, hedge := s.upstreamRequest, s.hedgeRequest
+
+	// the timeout covers both of the hedged requests
+	slow.OnResetStream(types.UpstreamPerTryTimeout)
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&s.upstreamReset))
+	s.settleHedge()
+	assert.Equal(t, slow, s.upstreamRequest)
+	assert.True(t, hedge.isCancelled())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fastReset))
+}
+
+func TestNoHedge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var slowReset, fastReset int32
+	s, _ := newHedgeDownstream(ctrl, 0, &slowReset, &fastReset)
+	s.setupHedgeTimer()
+	assert.Nil(t, s.hedgeTimer)
+
+	// the response is received before the hedge timer
+	s, _ = newHedgeDownstream(ctrl, 10*time.Millisecond, &slowReset, &fastReset)
+	atomic.StoreUint32(&s.upstreamResponseReceived, 1)
+	atomic.StoreUint32(&s.hedgeTriggered, 1)
+	assert.False(t, s.shouldHedge())
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&s.hedgeTriggered))
+}
+
+func TestHedgeRelease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var slowReset, fastReset int32
+	s, _ := newHedgeDownstream(ctrl, 10*time.Millisecond, &slowReset, &fastReset)
+	clusterInfo := &fakeClusterInfo{
+		mgr: cluster.NewResourceManager(v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{{MaxRetries: 10}},
+		}),
+	}
+	s.retryState.cluster = clusterInfo
+	retries := clusterInfo.ResourceManager().Retries()
+
+	atomic.StoreUint32(&s.hedgeTriggered, 1)
+	require.True(t, s.shouldHedge())
+	s.doHedge()
+	require.NotNil(t, s.hedgeRequest)
+	assert.Equal(t, int64(1), retries.Cur())
+	require.Len(t, s.hedgeContexts, 1)
+	assert.Equal(t, s.hedgeContexts[0], s.hedgeRequest.streamContext())
+
+	// the retry slot of the hedged request is released once it is settled
+	s.settleHedge()
+	assert.Equal(t, int64(0), retries.Cur())
+	s.retryState.releaseHedge()
+	s.retryState.reset()
+	assert.Equal(t, int64(0), retries.Cur())
+
+	// the buffers of the hedged request are given back
+	s.giveHedgeContexts()
+	assert.Nil(t, s.hedgeContexts)
+}
@@ -0,0 +1,97 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"strings"
+
+	"mosn.io/api"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+// hopByHopHeaders are meaningful only for a single transport-level connection,
+// they are not forwarded by the proxies, see RFC 7230 section 6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// isHTTPProtocol returns true if the headers of the protocol may carry the hop-by-hop headers
+func isHTTPProtocol(proto types.ProtocolName) bool {
+	return proto == protocol.HTTP1 || proto == protocol.HTTP2
+}
+
+// removeHopByHopHeaders removes the hop-by-hop headers and the headers listed in the Connection header.
+// The Connection and Upgrade headers of a protocol upgrade are kept, so that the upgrade can be accepted
+// by the peer, and "te: trailers" is kept as it is required by grpc.
+func removeHopByHopHeaders(headers api.HeaderMap) {
+	if headers == nil {
+		return
+	}
+	var connectionUpgrade bool
+	if connection, ok := headers.Get("Connection"); ok {
+		for _, token := range strings.Split(connection, ",") {
+			token = strings.TrimSpace(token)
+			if token == "" {
+				continue
+			}
+			if strings.EqualFold(token, "upgrade") {
+				connectionUpgrade = true
+				continue
+			}
+			headers.Del(token)
+		}
+	}
+	upgrade, _ := headers.Get("Upgrade")
+	te, _ := headers.Get("Te")
+
+	for _, key := range hopByHopHeaders {
+		headers.Del(key)
+	}
+
+	if connectionUpgrade && upgrade != "" {
+		headers.Set("Connection", "Upgrade")
+		headers.Set("Upgrade", upgrade)
+	}
+	if strings.EqualFold(strings.TrimSpace(te), "trailers") {
+		headers.Set("Te", "trailers")
+	}
+}
+
+// removeRequestHopByHopHeaders removes the hop-by-hop headers before the request is sent to the upstream
+func (s *downStream) removeRequestHopByHopHeaders() {
+	if isHTTPProtocol(s.getDownstreamProtocol()) {
+		removeHopByHopHeaders(s.downstreamReqHeaders)
+	}
+}
+
+// removeResponseHopByHopHeaders removes the hop-by-hop headers before the response is sent to the downstream
+func (s *downStream) removeResponseHopByHopHeaders() {
+	if s.upstreamRequest != nil && isHTTPProtocol(s.upstreamRequest.protocol) {
+		removeHopByHopHeaders(s.downstreamRespHeaders)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	mosnhttp2 "mosn.io/mosn/pkg/protocol/http2"
+)
+
+func TestRemoveHopByHopHeaders(t *testing.T) {
+	h := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	h.Set("X-Request-Id", "1")
+	h.Set("Connection", "keep-alive, X-Foo")
+	h.Set("Keep-Alive", "timeout=5")
+	h.Set("X-Foo", "bar")
+	h.Set("Proxy-Authorization", "Basic xxx")
+	h.Set("Te", "gzip")
+	removeHopByHopHeaders(h)
+
+	v, ok := h.Get("X-Request-Id")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	for _, key := range []string{"Connection", "Keep-Alive", "X-Foo", "Proxy-Authorization", "Te"} {
+		_, ok := h.Get(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestRemoveHopByHopHeadersKeepUpgrade(t *testing.T) {
+	h := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	h.Set("Connection", "keep-alive, Upgrade")
+	h.Set("Upgrade", "websocket")
+	h.Set("Keep-Alive", "timeout=5")
+	removeHopByHopHeaders(h)
+
+	v, _ := h.Get("Connection")
+	assert.Equal(t, "Upgrade", v)
+	v, _ = h.Get("Upgrade")
+	assert.Equal(t, "websocket", v)
+	_, ok := h.Get("Keep-Alive")
+	assert.False(t, ok)
+}
+
+func TestRemoveHopByHopHeadersKeepTrailers(t *testing.T) {
+	req := &http.Request{Header: http.Header{}}
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("Transfer-Encoding", "chunked")
+	req.Header.Set("Content-Type", "application/grpc")
+	h := mosnhttp2.NewReqHeader(req)
+	removeHopByHopHeaders(h)
+
+	v, _ := h.Get("te")
+	assert.Equal(t, "trailers", v)
+	v, _ = h.Get("content-type")
+	assert.Equal(t, "application/grpc", v)
+	_, ok := h.Get("transfer-encoding")
+	assert.False(t, ok)
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// headerUpstreamHost is the trusted request header to choose the upstream host, the value is "ip:port"
+const headerUpstreamHost = "x-mosn-upstream-host"
+
+// upstreamHostOverride returns the host of the cluster chosen by the trusted request header,
+// nil means the host is chosen by the load balancer.
+func (s *downStream) upstreamHostOverride() types.Host {
+	if s.proxy == nil || s.proxy.config == nil || !s.proxy.config.TrustUpstreamHostHeader || s.downstreamReqHeaders == nil {
+		return nil
+	}
+	addr, ok := s.downstreamReqHeaders.Get(headerUpstreamHost)
+	if !ok || addr == "" {
+		return nil
+	}
+	// the header is only used by the proxy
+	s.downstreamReqHeaders.Del(headerUpstreamHost)
+	var host types.Host
+	s.snapshot.HostSet().Range(func(h types.Host) bool {
+		if h.AddressString() == addr {
+			host = h
+			return false
+		}
+		return true
+	})
+	if host == nil {
+		if log.Proxy.GetLogLevel() >= log.WARN {
+			log.Proxy.Warnf(s.context, "[proxy] [downstream] upstream host %s is not in cluster %s, ignore the override", addr, s.cluster.Name())
+		}
+		return nil
+	}
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(s.context, "[proxy] [downstream] upstream host is overridden to %s, proxyId: %d", addr, s.ID)
+	}
+	return host
+}
+
+// hostOverrideSnapshot makes the cluster snapshot always choose the overridden host
+type hostOverrideSnapshot struct {
+	types.ClusterSnapshot
+	host types.Host
+}
+
+func newHostOverrideSnapshot(snapshot types.ClusterSnapshot, host types.Host) *hostOverrideSnapshot {
+	return &hostOverrideSnapshot{
+		ClusterSnapshot: snapshot,
+		host:            host,
+	}
+}
+
+func (s *hostOverrideSnapshot) LoadBalancer() types.LoadBalancer {
+	return &hostOverrideLoadBalancer{
+		LoadBalancer: s.ClusterSnapshot.LoadBalancer(),
+		host:         s.host,
+	}
+}
+
+func (s *hostOverrideSnapshot) IsExistsHosts(metadata api.MetadataMatchCriteria) bool {
+	return true
+}
+
+func (s *hostOverrideSnapshot) HostNum(metadata api.MetadataMatchCriteria) int {
+	return 1
+}
+
+type hostOverrideLoadBalancer struct {
+	types.LoadBalancer
+	host types.Host
+}
+
+func (lb *hostOverrideLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	return lb.host
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestUpstreamHostOverride(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	info := mock.NewMockClusterInfo(ctrl)
+	info.EXPECT().Name().Return("mockcluster").AnyTimes()
+	hosts := []types.Host{
+		gomockHedgeHost(ctrl, "127.0.0.1:8080", info),
+		gomockHedgeHost(ctrl, "127.0.0.1:8081", info),
+	}
+	hostSet := mock.NewMockHostSet(ctrl)
+	hostSet.EXPECT().Range(gomock.Any()).Do(func(f func(types.Host) bool) {
+		for _, h := range hosts {
+			if !f(h) {
+				return
+			}
+		}
+	}).AnyTimes()
+	snapshot := mock.NewMockClusterSnapshot(ctrl)
+	snapshot.EXPECT().HostSet().Return(hostSet).AnyTimes()
+	// the overridden host is chosen without the load balancer
+	snapshot.EXPECT().LoadBalancer().Return(nil).AnyTimes()
+
+	newDownstream := func(trusted bool, headers types.HeaderMap) *downStream {
+		return &downStream{
+			context: context.Background(),
+			proxy: &proxy{
+				config: &v2.Proxy{
+					TrustUpstreamHostHeader: trusted,
+				},
+			},
+			downstreamReqHeaders: headers,
+			snapshot:             snapshot,
+			cluster:              info,
+		}
+	}
+
+	t.Run("trusted", func(t *testing.T) {
+		headers := protocol.CommonHeader{headerUpstreamHost: "127.0.0.1:8081"}
+		s := newDownstream(true, headers)
+		host := s.upstreamHostOverride()
+		assert.Equal(t, hosts[1], host)
+		// the header is not sent to the upstream
+		_, ok := headers.Get(headerUpstreamHost)
+		assert.False(t, ok)
+
+		overridden := newHostOverrideSnapshot(snapshot, host)
+		assert.Equal(t, hosts[1], overridden.LoadBalancer().ChooseHost(s))
+		assert.Equal(t, 1, overridden.HostNum(nil))
+		assert.True(t, overridden.IsExistsHosts(nil))
+		assert.Equal(t, hostSet, overridden.HostSet())
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		headers := protocol.CommonHeader{headerUpstreamHost: "127.0.0.1:8081"}
+		s := newDownstream(false, headers)
+		assert.Nil(t, s.upstreamHostOverride())
+		_, ok := headers.Get(headerUpstreamHost)
+		assert.True(t, ok)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		s := newDownstream(true, protocol.CommonHeader{headerUpstreamHost: "127.0.0.1:9090"})
+		assert.Nil(t, s.upstreamHostOverride())
+	})
+
+	t.Run("no header", func(t *testing.T) {
+		s := newDownstream(true, protocol.CommonHeader{})
+		assert.Nil(t, s.upstreamHostOverride())
+	})
+}
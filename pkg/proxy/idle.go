@@ -0,0 +1,109 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/utils"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/network"
+)
+
+func (p *proxy) downstreamIdleTimeout() time.Duration {
+	if p.config == nil || p.config.DownstreamIdleTimeout == nil {
+		return 0
+	}
+	return p.config.DownstreamIdleTimeout.Duration
+}
+
+// startIdleTimer starts the idle timer if the downstream idle timeout is configured,
+// must be called with the asMux locked
+func (p *proxy) startIdleTimer() {
+	timeout := p.downstreamIdleTimeout()
+	if timeout <= 0 {
+		return
+	}
+	p.stopIdleTimer()
+	p.idleGeneration++
+	generation := p.idleGeneration
+	p.idleTimer = utils.NewTimer(timeout, func() {
+		p.onIdleTimeout(generation)
+	})
+}
+
+// stopIdleTimer must be called with the asMux locked
+func (p *proxy) stopIdleTimer() {
+	if p.idleTimer != nil {
+		p.idleTimer.Stop()
+		p.idleTimer = nil
+	}
+}
+
+// onIdleTimeout wakes up the read loop of the connection, the connection is closed
+// in the OnReadTimeout event, so that it never races with the dispatching of a new request.
+// the connection in netpoll mode has no read loop to wake up, it is read by the event loop and
+// the read timeout event is fired periodically, so it is checked and closed in the timer.
+func (p *proxy) onIdleTimeout(generation uint64) {
+	atomic.StoreUint64(&p.idleTimeout, generation)
+	if network.UseNetpollMode {
+		p.closeIfIdle()
+		return
+	}
+	if rawConn := p.readCallbacks.Connection().RawConn(); rawConn != nil {
+		if err := rawConn.SetReadDeadline(time.Now()); err != nil {
+			log.DefaultLogger.Warnf("[proxy] downstream idle timeout, wake up connection %d failed: %v",
+				p.readCallbacks.Connection().ID(), err)
+		}
+	}
+}
+
+// closeIfIdle closes the connection if it is idle, returns true if it is closed
+func (p *proxy) closeIfIdle() bool {
+	if !p.shouldCloseIdle() {
+		return false
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[proxy] downstream idle timeout, close connection %d", p.readCallbacks.Connection().ID())
+	}
+	p.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+	return true
+}
+
+// shouldCloseIdle returns true if the idle timer is fired, and no stream is created since then.
+func (p *proxy) shouldCloseIdle() bool {
+	generation := atomic.SwapUint64(&p.idleTimeout, 0)
+	if generation == 0 {
+		return false
+	}
+	p.asMux.Lock()
+	defer p.asMux.Unlock()
+	// a stream is created after the timer is fired
+	if generation != p.idleGeneration || p.activeStreams.Len() > 0 {
+		return false
+	}
+	// a request is partially received, waits for another idle timeout
+	if buf := p.readCallbacks.Connection().GetReadBuffer(); buf != nil && buf.Len() > 0 {
+		p.startIdleTimer()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+
+	"mosn.io/pkg/buffer"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+const (
+	localReplyResponseCode = "%RESPONSE_CODE%"
+	localReplyReqPrefix    = "%REQ("
+	localReplyReqSuffix    = ")%"
+)
+
+// localReplySegment is a part of the local reply body template,
+// it is either a literal text, the response code or a request header.
+type localReplySegment struct {
+	text         string
+	responseCode bool
+	header       string
+}
+
+type localReplyFormat struct {
+	segments    []localReplySegment
+	contentType string
+}
+
+type localReplyMapper struct {
+	codes  map[int]struct{}
+	format *localReplyFormat
+}
+
+// localReplyFormatter generates the body of the hijack replies
+type localReplyFormatter struct {
+	mappers       []localReplyMapper
+	defaultFormat *localReplyFormat
+}
+
+// newLocalReplyFormatter creates a formatter by the config, returns nil if the config is nil
+func newLocalReplyFormatter(config *v2.LocalReplyConfig) *localReplyFormatter {
+	if config == nil {
+		return nil
+	}
+	f := &localReplyFormatter{
+		mappers: make([]localReplyMapper, 0, len(config.Mappers)),
+	}
+	for _, m := range config.Mappers {
+		mapper := localReplyMapper{
+			format: parseLocalReplyFormat(m.BodyFormat),
+		}
+		if len(m.StatusCodes) > 0 {
+			mapper.codes = make(map[int]struct{}, len(m.StatusCodes))
+			for _, code := range m.StatusCodes {
+				mapper.codes[code] = struct{}{}
+			}
+		}
+		f.mappers = append(f.mappers, mapper)
+	}
+	if config.BodyFormat != nil {
+		f.defaultFormat = parseLocalReplyFormat(*config.BodyFormat)
+	}
+	return f
+}
+
+// parseLocalReplyFormat parses the template text into segments,
+// the unknown commands are kept as literal text.
+func parseLocalReplyFormat(config v2.LocalReplyBodyFormat) *localReplyFormat {
+	format := &localReplyFormat{
+		contentType: config.ContentType,
+	}
+	text := config.Text
+	var literal strings.Builder
+	for len(text) > 0 {
+		switch {
+		case strings.HasPrefix(text, localReplyResponseCode):
+			format.appendLiteral(&literal)
+			format.segments = append(format.segments, localReplySegment{responseCode: true})
+			text = text[len(localReplyResponseCode):]
+			continue
+		case strings.HasPrefix(text, localReplyReqPrefix):
+			if end := strings.Index(text, localReplyReqSuffix); end > len(localReplyReqPrefix) {
+				format.appendLiteral(&literal)
+				format.segments = append(format.segments, localReplySegment{
+					header: text[len(localReplyReqPrefix):end],
+				})
+				text = text[end+len(localReplyReqSuffix):]
+				continue
+			}
+		}
+		literal.WriteByte(text[0])
+		text = text[1:]
+	}
+	format.appendLiteral(&literal)
+	return format
+}
+
+func (format *localReplyFormat) appendLiteral(literal *strings.Builder) {
+	if literal.Len() == 0 {
+		return
+	}
+	format.segments = append(format.segments, localReplySegment{text: literal.String()})
+	literal.Reset()
+}
+
+func (format *localReplyFormat) body(code int, reqHeaders api.HeaderMap) string {
+	var b strings.Builder
+	for _, seg := range format.segments {
+		switch {
+		case seg.responseCode:
+			b.WriteString(strconv.Itoa(code))
+		case seg.header != "":
+			if reqHeaders != nil {
+				v, _ := reqHeaders.Get(seg.header)
+				b.WriteString(v)
+			}
+		default:
+			b.WriteString(seg.text)
+		}
+	}
+	return b.String()
+}
+
+// match returns the body format of the status code, nil means no body is configured
+func (f *localReplyFormatter) match(code int) *localReplyFormat {
+	for _, m := range f.mappers {
+		if m.codes == nil {
+			return m.format
+		}
+		if _, ok := m.codes[code]; ok {
+			return m.format
+		}
+	}
+	return f.defaultFormat
+}
+
+// format returns the local reply body and content type of the status code
+func (f *localReplyFormatter) format(code int, reqHeaders api.HeaderMap) (body string, contentType string, ok bool) {
+	if f == nil {
+		return "", "", false
+	}
+	format := f.match(code)
+	if format == nil {
+		return "", "", false
+	}
+	return format.body(code, reqHeaders), format.contentType, true
+}
+
+// setLocalReplyBody sets the formatted body of the hijack reply, the request headers
+// are used as the response headers in the hijack scene, so the content type is set on them.
+// only the http protocols support the local reply body.
+func (s *downStream) setLocalReplyBody(code int, headers api.HeaderMap) {
+	if s.proxy == nil || s.proxy.localReply == nil || !isHTTPProtocol(s.getDownstreamProtocol()) {
+		return
+	}
+	body, contentType, ok := s.proxy.localReply.format(code, s.downstreamReqHeaders)
+	if !ok {
+		return
+	}
+	if contentType != "" {
+		headers.Set(headerContentType, contentType)
+	}
+	s.downstreamRespDataBuf = buffer.NewIoBufferString(body)
+}
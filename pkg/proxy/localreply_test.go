@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+)
+
+func TestLocalReplyFormat(t *testing.T) {
+	f := newLocalReplyFormatter(&v2.LocalReplyConfig{
+		Mappers: []v2.LocalReplyMapper{
+			{
+				StatusCodes: []int{429},
+				BodyFormat: v2.LocalReplyBodyFormat{
+					Text:        "too many requests: %REQ(x-request-id)%",
+					ContentType: "text/plain",
+				},
+			},
+		},
+		BodyFormat: &v2.LocalReplyBodyFormat{
+			Text:        `{"code":%RESPONSE_CODE%,"id":"%REQ(x-request-id)%","unknown":"%REQ(%"}`,
+			ContentType: "application/json",
+		},
+	})
+	headers := protocol.CommonHeader{"x-request-id": "abc"}
+
+	body, contentType, ok := f.format(429, headers)
+	assert.True(t, ok)
+	assert.Equal(t, "too many requests: abc", body)
+	assert.Equal(t, "text/plain", contentType)
+
+	body, contentType, ok = f.format(504, headers)
+	assert.True(t, ok)
+	assert.Equal(t, `{"code":504,"id":"abc","unknown":"%REQ(%"}`, body)
+	assert.Equal(t, "application/json", contentType)
+
+	// missing header is formatted as empty
+	body, _, _ = f.format(504, protocol.CommonHeader{})
+	assert.Equal(t, `{"code":504,"id":"","unknown":"%REQ(%"}`, body)
+
+	// no default format
+	f = newLocalReplyFormatter(&v2.LocalReplyConfig{
+		Mappers: []v2.LocalReplyMapper{
+			{StatusCodes: []int{429}, BodyFormat: v2.LocalReplyBodyFormat{Text: "limited"}},
+		},
+	})
+	_, _, ok = f.format(504, headers)
+	assert.False(t, ok)
+	_, _, ok = (*localReplyFormatter)(nil).format(504, headers)
+	assert.False(t, ok)
+}
+
+func TestHijackReplyLocalReplyBody(t *testing.T) {
+	config := &v2.Proxy{
+		DownstreamProtocol: string(protocol.HTTP1),
+		LocalReply: &v2.LocalReplyConfig{
+			Mappers: []v2.LocalReplyMapper{
+				{
+					StatusCodes: []int{api.TimeoutExceptionCode},
+					BodyFormat: v2.LocalReplyBodyFormat{
+						Text:        `{"code":%RESPONSE_CODE%,"message":"timeout","id":"%REQ(x-request-id)%"}`,
+						ContentType: "application/json",
+					},
+				},
+				{
+					StatusCodes: []int{429},
+					BodyFormat: v2.LocalReplyBodyFormat{
+						Text:        "%RESPONSE_CODE% rate limited: %REQ(x-request-id)%",
+						ContentType: "text/plain",
+					},
+				},
+			},
+		},
+	}
+	newStream := func(config *v2.Proxy) *downStream {
+		headers := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+		headers.Set("x-request-id", "req-1")
+		return &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			proxy: &proxy{
+				config:        config,
+				stats:         globalStats,
+				listenerStats: newListenerStats("test_local_reply"),
+				localReply:    newLocalReplyFormatter(config.LocalReply),
+			},
+			requestInfo:          &network.RequestInfo{},
+			downstreamReqHeaders: headers,
+		}
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		s := newStream(config)
+		s.onReceiverFilterTimeout(api.BeforeRoute, time.Second)
+		assert.True(t, s.directResponse)
+		assert.Equal(t, api.TimeoutExceptionCode, s.requestInfo.ResponseCode())
+		if !assert.NotNil(t, s.downstreamRespDataBuf) {
+			return
+		}
+		assert.Equal(t, `{"code":504,"message":"timeout","id":"req-1"}`, s.downstreamRespDataBuf.String())
+		ct, _ := s.downstreamRespHeaders.Get(headerContentType)
+		assert.Equal(t, "application/json", ct)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		s := newStream(config)
+		handler := newStreamReceiverFilterHandler(s)
+		handler.SendHijackReply(429, s.downstreamReqHeaders)
+		assert.True(t, s.directResponse)
+		if !assert.NotNil(t, s.downstreamRespDataBuf) {
+			return
+		}
+		assert.Equal(t, "429 rate limited: req-1", s.downstreamRespDataBuf.String())
+		ct, _ := s.downstreamRespHeaders.Get(headerContentType)
+		assert.Equal(t, "text/plain", ct)
+	})
+
+	t.Run("no matched format", func(t *testing.T) {
+		s := newStream(config)
+		s.sendHijackReply(api.NoHealthUpstreamCode, s.downstreamReqHeaders)
+		assert.True(t, s.directResponse)
+		assert.Nil(t, s.downstreamRespDataBuf)
+	})
+
+	t.Run("not http", func(t *testing.T) {
+		rpcConfig := *config
+		rpcConfig.DownstreamProtocol = "bolt"
+		s := newStream(&rpcConfig)
+		s.sendHijackReply(api.TimeoutExceptionCode, s.downstreamReqHeaders)
+		assert.Nil(t, s.downstreamRespDataBuf)
+	})
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"strings"
+	"sync"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	// otherLabelValue is the label value of the header values beyond the cardinality bound
+	otherLabelValue          = "other"
+	defaultMaxLabelValues    = 64
+	labeledStatsKeySeparator = "\x00"
+)
+
+// metricsLabelers caches the labelers by listener name, so the cardinality of the label
+// values is bounded for all the connections of the listener
+var metricsLabelers sync.Map
+
+// metricsLabeler records the request metrics with the labels derived from the request headers
+type metricsLabeler struct {
+	config       *v2.Proxy
+	listenerName string
+	labels       []*metricsLabel
+	// stats caches the labeled stats by the label values
+	stats sync.Map
+}
+
+type metricsLabel struct {
+	header    string
+	label     string
+	allowed   map[string]struct{}
+	maxValues int
+
+	mux    sync.Mutex
+	values map[string]struct{}
+}
+
+// getMetricsLabeler returns the labeler of the listener, nil means no metrics labels
+func getMetricsLabeler(listenerName string, config *v2.Proxy) *metricsLabeler {
+	if len(config.MetricsLabels) == 0 {
+		metricsLabelers.Delete(listenerName)
+		return nil
+	}
+	if v, ok := metricsLabelers.Load(listenerName); ok {
+		if l := v.(*metricsLabeler); l.config == config {
+			return l
+		}
+	}
+	l := newMetricsLabeler(listenerName, config)
+	metricsLabelers.Store(listenerName, l)
+	return l
+}
+
+func newMetricsLabeler(listenerName string, config *v2.Proxy) *metricsLabeler {
+	l := &metricsLabeler{
+		config:       config,
+		listenerName: listenerName,
+		labels:       make([]*metricsLabel, 0, len(config.MetricsLabels)),
+	}
+	for _, cfg := range config.MetricsLabels {
+		if cfg.Header == "" || cfg.Label == "" {
+			continue
+		}
+		// the listener label is kept, and the total label count is limited by the metrics
+		if cfg.Label == "listener" || len(l.labels) >= metrics.MaxLabelCount-1 {
+			continue
+		}
+		ml := &metricsLabel{
+			header:    cfg.Header,
+			label:     cfg.Label,
+			maxValues: cfg.MaxValues,
+			values:    map[string]struct{}{},
+		}
+		if ml.maxValues <= 0 {
+			ml.maxValues = defaultMaxLabelValues
+		}
+		if len(cfg.AllowedValues) > 0 {
+			ml.allowed = make(map[string]struct{}, len(cfg.AllowedValues))
+			for _, v := range cfg.AllowedValues {
+				ml.allowed[v] = struct{}{}
+			}
+		}
+		l.labels = append(l.labels, ml)
+	}
+	return l
+}
+
+// value returns the label value of the header value,
+// the missing header and the header values beyond the bound are recorded as "other"
+func (l *metricsLabel) value(headers types.HeaderMap) string {
+	if headers == nil {
+		return otherLabelValue
+	}
+	v, ok := headers.Get(l.header)
+	if !ok || v == "" || v == otherLabelValue {
+		return otherLabelValue
+	}
+	if l.allowed != nil {
+		if _, ok := l.allowed[v]; ok {
+			return v
+		}
+		return otherLabelValue
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if _, ok := l.values[v]; ok {
+		return v
+	}
+	if len(l.values) >= l.maxValues {
+		return otherLabelValue
+	}
+	l.values[v] = struct{}{}
+	return v
+}
+
+// getStats returns the stats of the label values derived from the request headers
+func (m *metricsLabeler) getStats(headers types.HeaderMap) *Stats {
+	if len(m.labels) == 0 {
+		return nil
+	}
+	values := make([]string, len(m.labels))
+	for i, l := range m.labels {
+		values[i] = l.value(headers)
+	}
+	key := strings.Join(values, labeledStatsKeySeparator)
+	if s, ok := m.stats.Load(key); ok {
+		return s.(*Stats)
+	}
+	labels := make(map[string]string, len(m.labels))
+	for i, l := range m.labels {
+		labels[l.label] = values[i]
+	}
+	s, _ := m.stats.LoadOrStore(key, newStats(metrics.NewListenerLabeledStats(m.listenerName, labels)))
+	return s.(*Stats)
+}
@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/pkg/variable"
+)
+
+func TestMetricsLabeler(t *testing.T) {
+	metrics.ResetAll()
+	config := &v2.Proxy{
+		MetricsLabels: []v2.MetricsLabel{
+			{Header: "x-tenant", Label: "tenant", MaxValues: 2},
+			{Header: "x-env", Label: "env", AllowedValues: []string{"prod", "gray"}},
+		},
+	}
+	labeler := getMetricsLabeler("labeled_listener", config)
+	// the labeler is shared by the connections of the listener
+	assert.Same(t, labeler, getMetricsLabeler("labeled_listener", config))
+
+	newDownstream := func(headers map[string]string, code int) *downStream {
+		requestInfo := network.NewRequestInfo()
+		requestInfo.SetRequestFinishedDuration(time.Now().Add(time.Millisecond))
+		requestInfo.SetResponseCode(code)
+		return &downStream{
+			context:              variable.NewVariableContext(context.Background()),
+			requestInfo:          requestInfo,
+			downstreamReqHeaders: protocol.CommonHeader(headers),
+			proxy: &proxy{
+				stats:          newProxyStats("labeled_proxy"),
+				listenerStats:  newListenerStats("labeled_listener"),
+				metricsLabeler: labeler,
+			},
+		}
+	}
+	for _, r := range []struct {
+		tenant, env string
+		code        int
+	}{
+		{"alice", "prod", 200},
+		{"alice", "prod", 500},
+		{"bob", "gray", 200},
+		// the tenant values beyond the max values and the env values not allowed are "other"
+		{"carol", "prod", 200},
+		{"alice", "test", 200},
+		{"", "", 200},
+	} {
+		headers := map[string]string{}
+		if r.tenant != "" {
+			headers["x-tenant"] = r.tenant
+		}
+		if r.env != "" {
+			headers["x-env"] = r.env
+		}
+		newDownstream(headers, r.code).requestMetrics()
+	}
+
+	stats := func(tenant, env string) *Stats {
+		return newStats(metrics.NewListenerLabeledStats("labeled_listener", map[string]string{
+			"tenant": tenant,
+			"env":    env,
+		}))
+	}
+	s := stats("alice", "prod")
+	assert.Equal(t, int64(2), s.DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(1), s.DownstreamRequest200Total.Count())
+	assert.Equal(t, int64(1), s.DownstreamRequest500Total.Count())
+	assert.Equal(t, int64(2), s.DownstreamRequestTime.Count())
+	assert.Equal(t, int64(1), stats("bob", "gray").DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(1), stats("other", "prod").DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(1), stats("alice", "other").DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(1), stats("other", "other").DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(0), stats("carol", "prod").DownstreamRequestTotal.Count())
+	// the listener metrics without labels are recorded as well
+	assert.Equal(t, int64(6), newListenerStats("labeled_listener").DownstreamRequestTime.Count())
+
+	// the cardinality is bounded
+	for i := 0; i < 100; i++ {
+		labeler.getStats(protocol.CommonHeader{"x-tenant": fmt.Sprintf("tenant-%d", i), "x-env": "prod"})
+	}
+	count := 0
+	labeler.stats.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 5, count)
+
+	// the labeler is recreated when the config changed, and removed if no labels
+	newConfig := &v2.Proxy{MetricsLabels: config.MetricsLabels}
+	assert.NotSame(t, labeler, getMetricsLabeler("labeled_listener", newConfig))
+	assert.Nil(t, getMetricsLabeler("labeled_listener", &v2.Proxy{}))
+	_, ok := metricsLabelers.Load("labeled_listener")
+	assert.False(t, ok)
+}
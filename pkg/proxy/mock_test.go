@@ -63,8 +63,9 @@ func (r *mockRouters) MatchRoute(context.Context, types.HeaderMap) types.Route {
 
 type mockRoute struct {
 	api.Route
-	rule   api.RouteRule
-	direct api.DirectResponseRule
+	rule     api.RouteRule
+	direct   api.DirectResponseRule
+	redirect api.RedirectRule
 }
 
 func (r *mockRoute) RouteRule() api.RouteRule {
@@ -81,6 +82,10 @@ func (r *mockRoute) DirectResponseRule() api.DirectResponseRule {
 	return nil
 }
 
+func (r *mockRoute) RedirectRule() api.RedirectRule {
+	return r.redirect
+}
+
 type mockRouteRule struct {
 	api.RouteRule
 	upstreamProtocol string
@@ -123,8 +128,9 @@ func (p *mockRetryPolicy) TryTimeout() time.Duration {
 }
 
 type mockDirectRule struct {
-	status int
-	body   string
+	status  int
+	body    string
+	headers map[string]string
 }
 
 func (r *mockDirectRule) StatusCode() int {
@@ -135,6 +141,33 @@ func (r *mockDirectRule) Body() string {
 	return r.body
 }
 
+func (r *mockDirectRule) ResponseHeaders() map[string]string {
+	return r.headers
+}
+
+type mockRedirectRule struct {
+	code   int
+	path   string
+	host   string
+	scheme string
+}
+
+func (r *mockRedirectRule) RedirectCode() int {
+	return r.code
+}
+
+func (r *mockRedirectRule) RedirectPath() string {
+	return r.path
+}
+
+func (r *mockRedirectRule) RedirectHost() string {
+	return r.host
+}
+
+func (r *mockRedirectRule) RedirectScheme() string {
+	return r.scheme
+}
+
 type mockClusterManager struct {
 	types.ClusterManager
 }
@@ -223,10 +256,11 @@ func (tracer *mockTracer) Start(ctx context.Context, request interface{}, startT
 type mockSpan struct {
 	inject   bool
 	finished bool
+	traceId  string
 }
 
 func (s *mockSpan) TraceId() string {
-	return ""
+	return s.traceId
 }
 
 func (s *mockSpan) SpanId() string {
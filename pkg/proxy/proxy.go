@@ -22,6 +22,8 @@ import (
 	"context"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"mosn.io/api"
@@ -37,6 +39,7 @@ import (
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/mosn/pkg/upstream/cluster"
 	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
 	"mosn.io/pkg/variable"
 )
 
@@ -96,8 +99,28 @@ type proxy struct {
 	accessLogs          []api.AccessLog
 	streamFilterFactory streamfilter.StreamFilterFactory
 	routeHandlerFactory router.MakeHandlerFunc
+	listenerName        string
+	// time at the downstream connection is created
+	createdAt time.Time
 
 	protocols []api.ProtocolName
+	// sniffTimer wakes up or closes the connection if the protocol is not detected in the sniff timeout
+	sniffTimer    *utils.Timer
+	sniffTimeout  uint32
+	sniffReceived uint32
+	sniffDone     uint32
+	// idleTimer wakes up the connection if there are no active streams in the downstream idle timeout,
+	// idleTimer and idleGeneration are protected by asMux
+	idleTimer      *utils.Timer
+	idleGeneration uint64
+	idleTimeout    uint64
+	// localReply generates the body of the hijack replies, nil means no body
+	localReply *localReplyFormatter
+	// metricsLabeler records the request metrics with the labels derived from the request headers,
+	// nil means no metrics labels
+	metricsLabeler *metricsLabeler
+	// debugHeaders checks the debug request header of the requests, nil means no request header is trusted
+	debugHeaders *debugHeadersTrust
 
 	// configure the proxy level worker pool
 	// eg. if we want the requests on one connection to keep serial,
@@ -116,6 +139,7 @@ func NewProxy(ctx context.Context, config *v2.Proxy) Proxy {
 		stats:          globalStats,
 		context:        ctx,
 		accessLogs:     aclog.([]api.AccessLog),
+		localReply:     newLocalReplyFormatter(config.LocalReply),
 	}
 
 	if pi, err := variable.Get(ctx, types.VarProtocolConfig); err == nil {
@@ -132,7 +156,10 @@ func NewProxy(ctx context.Context, config *v2.Proxy) Proxy {
 
 	lv, _ := variable.Get(ctx, types.VariableListenerName)
 	listenerName := lv.(string)
+	proxy.listenerName = listenerName
 	proxy.listenerStats = newListenerStats(listenerName)
+	proxy.metricsLabeler = getMetricsLabeler(listenerName, config)
+	proxy.debugHeaders = getDebugHeadersTrust(listenerName, config)
 
 	if routersWrapper := router.GetRoutersMangerInstance().GetRouterWrapperByName(proxy.config.RouterConfigName); routersWrapper != nil {
 		proxy.routersWrapper = routersWrapper
@@ -144,7 +171,10 @@ func NewProxy(ctx context.Context, config *v2.Proxy) Proxy {
 		proxy: proxy,
 	}
 
-	proxy.streamFilterFactory = streamfilter.GetStreamFilterManager().GetStreamFilterFactory(listenerName)
+	// the factory is updated in place when the listener's stream filters changed,
+	// see streamfilter.UpdateStreamFilters. an empty factory is added if the listener
+	// has no stream filters yet, so the filters added later take effect on this proxy
+	proxy.streamFilterFactory = streamfilter.GetStreamFilterManager().GetOrCreateStreamFilterFactory(listenerName)
 	proxy.routeHandlerFactory = router.GetMakeHandlerFunc(proxy.config.RouterHandlerName)
 
 	return proxy
@@ -161,17 +191,20 @@ func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
 			prot = conn.ConnectionState().NegotiatedProtocol
 		}
 
-		scopes := p.protocols
-		// if protocols is Auto only, match all registered protocols
-		if len(scopes) == 1 && scopes[0] == protocol.Auto {
-			scopes = nil
+		if buf.Len() > 0 {
+			atomic.StoreUint32(&p.sniffReceived, 1)
 		}
-
-		proto, err := stream.SelectStreamFactoryProtocol(p.context, prot, buf.Bytes(), scopes)
+		proto, err := p.sniffProtocol(prot, p.sniffBytes(buf))
 
 		if err == stream.EAGAIN {
-			return api.Stop
+			// wait for more bytes until the sniff timeout or the max bytes is reached
+			if !p.sniffExhausted(buf) {
+				return api.Stop
+			}
+			proto, err = p.sniffDefaultProtocol()
 		}
+		atomic.StoreUint32(&p.sniffDone, 1)
+		p.stopSniffTimer()
 		if err == stream.FAILED {
 			if p.config.FallbackForUnknownProtocol {
 				p.fallback = true
@@ -193,20 +226,43 @@ func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
 			log.DefaultLogger.Debugf("[proxy] Protoctol Auto: %v", proto)
 		}
 
-		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, proto, p.readCallbacks.Connection(), p)
+		ssc := stream.CreateServerStreamConnection(p.context, proto, p.readCallbacks.Connection(), p)
+		// the connection is read by DumpConnections under the lock
+		p.asMux.Lock()
+		p.serverStreamConn = ssc
+		p.asMux.Unlock()
 	}
 	p.serverStreamConn.Dispatch(buf)
 
 	return api.Stop
 }
 
+// releaseDownstreamConnPools closes the upstream connection pools bound to the closed downstream connection
+func (p *proxy) releaseDownstreamConnPools() {
+	releaser, ok := p.clusterManager.(types.DownstreamConnPoolReleaser)
+	if !ok || p.readCallbacks == nil {
+		return
+	}
+	conn := p.readCallbacks.Connection()
+	if conn == nil || conn.RemoteAddr() == nil || conn.LocalAddr() == nil {
+		return
+	}
+	releaser.ReleaseDownstreamConnPools(conn.RemoteAddr().String(), conn.LocalAddr().String())
+}
+
 //rpc realize upstream on event
 func (p *proxy) onDownstreamEvent(event api.ConnectionEvent) {
 	if event.IsClose() {
+		p.stopSniffTimer()
+		p.asMux.Lock()
+		p.stopIdleTimer()
+		p.asMux.Unlock()
+		unregisterActiveProxy(p)
 		p.stats.DownstreamConnectionDestroy.Inc(1)
 		p.stats.DownstreamConnectionActive.Dec(1)
 		p.listenerStats.DownstreamConnectionDestroy.Inc(1)
 		p.listenerStats.DownstreamConnectionActive.Dec(1)
+		p.releaseDownstreamConnPools()
 		var urEleNext *list.Element
 
 		p.asMux.RLock()
@@ -226,6 +282,19 @@ func (p *proxy) onDownstreamEvent(event api.ConnectionEvent) {
 		return
 	}
 	if event == api.OnReadTimeout {
+		if p.closeIfIdle() {
+			return
+		}
+		if p.shouldSniffDefault() {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[proxy] protocol sniff timeout, select the protocol with the received bytes")
+			}
+			if p.OnData(p.readCallbacks.Connection().GetReadBuffer()) == api.Continue {
+				// fallback to the next read filter
+				p.readCallbacks.ContinueReading()
+			}
+			return
+		}
 		if p.shouldFallback() {
 			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 				log.DefaultLogger.Debugf("[proxy] wait for fallback timeout, do fallback")
@@ -284,9 +353,17 @@ func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
 	p.listenerStats.DownstreamConnectionActive.Inc(1)
 
 	p.readCallbacks.Connection().AddConnectionEventListener(p.downstreamListener)
+	p.createdAt = time.Now()
+	p.asMux.Lock()
+	p.startIdleTimer()
+	p.asMux.Unlock()
 	if len(p.protocols) == 1 && p.protocols[0] != protocol.Auto {
 		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, api.ProtocolName(p.protocols[0]), p.readCallbacks.Connection(), p)
+		registerActiveProxy(p)
+		return
 	}
+	registerActiveProxy(p)
+	p.startSniffTimer()
 }
 
 func (p *proxy) OnGoAway() {}
@@ -300,6 +377,7 @@ func (p *proxy) NewStreamDetect(ctx context.Context, responseSender types.Stream
 
 	p.asMux.Lock()
 	stream.element = p.activeStreams.PushBack(stream)
+	p.stopIdleTimer()
 	p.asMux.Unlock()
 
 	return stream
@@ -319,7 +397,7 @@ func (p *proxy) streamResetReasonToResponseFlag(reason types.StreamResetReason)
 		return api.UpstreamLocalReset
 	case types.StreamOverflow:
 		return api.UpstreamOverflow
-	case types.StreamRemoteReset:
+	case types.StreamRemoteReset, types.StreamRefused:
 		return api.UpstreamRemoteReset
 	case types.UpstreamGlobalTimeout, types.UpstreamPerTryTimeout:
 		return api.UpstreamRequestTimeout
@@ -332,6 +410,9 @@ func (p *proxy) deleteActiveStream(s *downStream) {
 	if s.element != nil {
 		p.asMux.Lock()
 		p.activeStreams.Remove(s.element)
+		if p.activeStreams.Len() == 0 {
+			p.startIdleTimer()
+		}
 		p.asMux.Unlock()
 		s.element = nil
 	}
@@ -199,7 +199,7 @@ func TestProxyWithFilters(t *testing.T) {
 			}
 		})
 		filterManager := streamfilter.NewMockStreamFilterManager(ctrl)
-		filterManager.EXPECT().GetStreamFilterFactory(gomock.Any()).Return(factory).AnyTimes()
+		filterManager.EXPECT().GetOrCreateStreamFilterFactory(gomock.Any()).Return(factory).AnyTimes()
 		return filterManager
 	})
 	// mock stream connection
@@ -18,9 +18,13 @@
 package proxy
 
 import (
+	"container/list"
 	"context"
+	"net"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	monkey "github.com/cch123/supermonkey"
 	"github.com/golang/mock/gomock"
@@ -28,11 +32,15 @@ import (
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
 	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/stream"
 	"mosn.io/mosn/pkg/stream/http"
 	"mosn.io/mosn/pkg/stream/http2"
+	xstream "mosn.io/mosn/pkg/stream/xprotocol"
 	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
@@ -43,6 +51,9 @@ import (
 func TestMain(m *testing.M) {
 	// mock register variable
 	variable.Register(variable.NewVariable(types.VarProtocolConfig, nil, nil, variable.DefaultSetter, 0))
+	// register the bolt protocol for the protocol auto detection
+	xprotocol.RegisterXProtocolAction(xstream.NewConnPool, xstream.NewStreamFactory, nil)
+	_ = xprotocol.RegisterXProtocolCodec(&bolt.XCodec{})
 	os.Exit(m.Run())
 }
 
@@ -287,6 +298,48 @@ func TestNewProxy(t *testing.T) {
 	})
 }
 
+type mockUpdatedStreamFilterFactory struct {
+	created *int32
+}
+
+func (f *mockUpdatedStreamFilterFactory) CreateFilterChain(ctx context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	atomic.AddInt32(f.created, 1)
+}
+
+// TestNewProxyUpdateStreamFilters verifies the stream filters added to a listener without stream filters
+// take effect on the proxy created before
+func TestNewProxyUpdateStreamFilters(t *testing.T) {
+	var created int32
+	api.RegisterStream("test_proxy_updated_filter", func(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+		return &mockUpdatedStreamFilterFactory{created: &created}, nil
+	})
+	listenerName := "test_update_filters_listener"
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableAccessLogs, []api.AccessLog{})
+	_ = variable.Set(ctx, types.VariableListenerName, listenerName)
+	pv := NewProxy(ctx, &v2.Proxy{
+		Name:               "test",
+		DownstreamProtocol: "Http1",
+		RouterConfigName:   "test_router",
+	})
+	p := pv.(*proxy)
+	p.serverStreamConn = &mockServerConn{}
+	p.readCallbacks = &mockReadFilterCallbacks{}
+	newStream := func() {
+		streamCtx := buffer.NewBufferPoolContext(variable.NewVariableContext(context.Background()))
+		p.NewStreamDetect(streamCtx, nil, nil)
+	}
+
+	newStream()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&created))
+
+	assert.Nil(t, streamfilter.UpdateStreamFilters(listenerName, streamfilter.StreamFiltersConfig{
+		{Type: "test_proxy_updated_filter"},
+	}))
+	newStream()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&created))
+}
+
 // TestNewProxyRequest mocks a connection received a request and create a new proxy to handle it.
 // NewProxy -> InitializeReadFilterCallbacks -> OnData -> NewStreamDetect(in stream.Dispatch)
 func TestNewProxyRequest(t *testing.T) {
@@ -308,7 +361,7 @@ func TestNewProxyRequest(t *testing.T) {
 			callCreateFilterChain = true
 		}).AnyTimes()
 		filterManager := streamfilter.NewMockStreamFilterManager(ctrl)
-		filterManager.EXPECT().GetStreamFilterFactory(gomock.Any()).Return(factory).AnyTimes()
+		filterManager.EXPECT().GetOrCreateStreamFilterFactory(gomock.Any()).Return(factory).AnyTimes()
 		return filterManager
 	})
 	ctx := genctx()
@@ -569,3 +622,354 @@ func TestProxyFallbackTimeoutWithoutData(t *testing.T) {
 	assert.False(t, proxy.fallback)
 	assert.False(t, continueReading)
 }
+
+func TestProxyAutoProtocol(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer monkey.UnpatchAll()
+
+	readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+	readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+		c := mock.NewMockConnection(ctrl)
+		c.EXPECT().RawConn().AnyTimes().Return(nil)
+		return c
+	})
+
+	var prot types.ProtocolName
+	monkey.Patch(stream.CreateServerStreamConnection, func(ctx context.Context, p types.ProtocolName, conn api.Connection,
+		l types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+		prot = p
+		ret := mock.NewMockServerStreamConnection(ctrl)
+		ret.EXPECT().Dispatch(gomock.Any()).AnyTimes().Return()
+		return ret
+	})
+
+	for _, tc := range []struct {
+		data     []byte
+		expected types.ProtocolName
+	}{
+		{[]byte("GET / HTTP/1.1\r\n"), protocol.HTTP1},
+		{[]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), protocol.HTTP2},
+		{[]byte{bolt.ProtocolCode, bolt.CmdTypeRequest, 0x00, 0x01}, bolt.ProtocolName},
+	} {
+		prot = ""
+		proxy := &proxy{
+			config:        &v2.Proxy{},
+			readCallbacks: readCallback,
+			context:       context.TODO(),
+		}
+		assert.Equal(t, api.Stop, proxy.OnData(buffer.NewIoBufferBytes(tc.data)))
+		assert.Equal(t, tc.expected, prot)
+		assert.NotNil(t, proxy.serverStreamConn)
+	}
+}
+
+func TestProxySniffMaxBytes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer monkey.UnpatchAll()
+
+	readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+	readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+		c := mock.NewMockConnection(ctrl)
+		c.EXPECT().RawConn().AnyTimes().Return(nil)
+		return c
+	})
+	var prot types.ProtocolName
+	monkey.Patch(stream.CreateServerStreamConnection, func(ctx context.Context, p types.ProtocolName, conn api.Connection,
+		l types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+		prot = p
+		ret := mock.NewMockServerStreamConnection(ctrl)
+		ret.EXPECT().Dispatch(gomock.Any()).AnyTimes().Return()
+		return ret
+	})
+
+	proxy := &proxy{
+		config: &v2.Proxy{
+			ProtocolSniff: &v2.ProtocolSniffConfig{
+				MaxBytes:        8,
+				DefaultProtocol: string(protocol.HTTP1),
+			},
+		},
+		readCallbacks: readCallback,
+		context:       context.TODO(),
+	}
+	// the http2 preface is not completed
+	assert.Equal(t, api.Stop, proxy.OnData(buffer.NewIoBufferBytes([]byte("PRI * H"))))
+	assert.Nil(t, proxy.serverStreamConn)
+	// the max bytes is reached, use the default protocol
+	assert.Equal(t, api.Stop, proxy.OnData(buffer.NewIoBufferBytes([]byte("PRI * HT"))))
+	assert.Equal(t, protocol.HTTP1, prot)
+}
+
+func TestProxySniffTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer monkey.UnpatchAll()
+
+	buff := buffer.NewIoBuffer(0)
+	// the read loop is woken up by the read deadline
+	rawConn, peer := net.Pipe()
+	defer rawConn.Close()
+	defer peer.Close()
+	readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+	readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+		c := mock.NewMockConnection(ctrl)
+		c.EXPECT().RawConn().AnyTimes().Return(rawConn)
+		c.EXPECT().GetReadBuffer().AnyTimes().Return(buff)
+		return c
+	})
+	readCallback.EXPECT().ContinueReading().AnyTimes()
+	var prot types.ProtocolName
+	monkey.Patch(stream.CreateServerStreamConnection, func(ctx context.Context, p types.ProtocolName, conn api.Connection,
+		l types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+		prot = p
+		ret := mock.NewMockServerStreamConnection(ctrl)
+		ret.EXPECT().Dispatch(gomock.Any()).AnyTimes().Return()
+		return ret
+	})
+
+	proxy := &proxy{
+		config: &v2.Proxy{
+			ProtocolSniff: &v2.ProtocolSniffConfig{
+				Timeout:         api.DurationConfig{Duration: 50 * time.Millisecond},
+				DefaultProtocol: string(protocol.HTTP2),
+			},
+		},
+		readCallbacks: readCallback,
+		context:       context.TODO(),
+	}
+	proxy.downstreamListener = &downstreamCallbacks{
+		proxy: proxy,
+	}
+	proxy.startSniffTimer()
+
+	// wait for more data
+	_, _ = buff.Write([]byte("G"))
+	assert.Equal(t, api.Stop, proxy.OnData(buff))
+	assert.Nil(t, proxy.serverStreamConn)
+
+	// the read timeout before the sniff timeout does not select the protocol
+	proxy.downstreamListener.OnEvent(api.OnReadTimeout)
+	assert.Nil(t, proxy.serverStreamConn)
+
+	// the default protocol is selected after the sniff timeout, the connection is not closed
+	time.Sleep(100 * time.Millisecond)
+	_, err := rawConn.Read(make([]byte, 1))
+	assert.True(t, err.(net.Error).Timeout())
+	proxy.downstreamListener.OnEvent(api.OnReadTimeout)
+	assert.Equal(t, protocol.HTTP2, prot)
+	assert.NotNil(t, proxy.serverStreamConn)
+	assert.False(t, proxy.fallback)
+}
+
+func TestProxySniffTimeoutNoDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	buff := buffer.NewIoBuffer(0)
+	closed := false
+	readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+	readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+		c := mock.NewMockConnection(ctrl)
+		c.EXPECT().RawConn().AnyTimes().Return(nil)
+		c.EXPECT().GetReadBuffer().AnyTimes().Return(buff)
+		c.EXPECT().Close(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+			closed = true
+			return nil
+		})
+		return c
+	})
+	readCallback.EXPECT().ContinueReading().AnyTimes()
+
+	proxy := &proxy{
+		config:        &v2.Proxy{},
+		readCallbacks: readCallback,
+		context:       context.TODO(),
+	}
+	_, _ = buff.Write([]byte("G"))
+	assert.Equal(t, api.Stop, proxy.OnData(buff))
+
+	// no default protocol, the connection is closed
+	proxy.onSniffTimeout()
+	assert.Equal(t, api.Stop, proxy.OnData(buff))
+	assert.True(t, closed)
+	assert.Nil(t, proxy.serverStreamConn)
+}
+
+func TestProxySniffTimeoutClose(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		netpoll bool
+	}{
+		{name: "no bytes received"},
+		{name: "no bytes received in netpoll mode", netpoll: true},
+		{name: "bytes received in netpoll mode", data: []byte("G"), netpoll: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			network.UseNetpollMode = tc.netpoll
+			defer func() {
+				network.UseNetpollMode = false
+			}()
+			rawConn, peer := net.Pipe()
+			defer rawConn.Close()
+			defer peer.Close()
+			buff := buffer.NewIoBuffer(0)
+			var closed uint32
+			readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+			readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+				c := mock.NewMockConnection(ctrl)
+				c.EXPECT().ID().AnyTimes().Return(uint64(1))
+				c.EXPECT().RawConn().AnyTimes().Return(rawConn)
+				c.EXPECT().GetReadBuffer().AnyTimes().Return(buff)
+				c.EXPECT().Close(api.NoFlush, api.LocalClose).DoAndReturn(func(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+					atomic.StoreUint32(&closed, 1)
+					return nil
+				}).AnyTimes()
+				return c
+			})
+
+			proxy := &proxy{
+				config: &v2.Proxy{
+					ProtocolSniff: &v2.ProtocolSniffConfig{
+						Timeout:         api.DurationConfig{Duration: 50 * time.Millisecond},
+						DefaultProtocol: string(protocol.HTTP1),
+					},
+				},
+				readCallbacks: readCallback,
+				context:       context.TODO(),
+			}
+			proxy.startSniffTimer()
+			if len(tc.data) > 0 {
+				_, _ = buff.Write(tc.data)
+				assert.Equal(t, api.Stop, proxy.OnData(buff))
+			}
+			// the connection is closed by the timer without the read timeout event
+			assert.Eventually(t, func() bool {
+				return atomic.LoadUint32(&closed) == 1
+			}, time.Second, 10*time.Millisecond)
+			assert.Nil(t, proxy.serverStreamConn)
+		})
+	}
+}
+
+func TestProxySniffTimeoutDetected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer monkey.UnpatchAll()
+
+	readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+	readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+		// the connection is not closed
+		c := mock.NewMockConnection(ctrl)
+		c.EXPECT().RawConn().AnyTimes().Return(nil)
+		return c
+	})
+	monkey.Patch(stream.CreateServerStreamConnection, func(ctx context.Context, p types.ProtocolName, conn api.Connection,
+		l types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+		ret := mock.NewMockServerStreamConnection(ctrl)
+		ret.EXPECT().Dispatch(gomock.Any()).AnyTimes().Return()
+		return ret
+	})
+
+	proxy := &proxy{
+		config: &v2.Proxy{
+			ProtocolSniff: &v2.ProtocolSniffConfig{
+				Timeout: api.DurationConfig{Duration: 50 * time.Millisecond},
+			},
+		},
+		readCallbacks: readCallback,
+		context:       context.TODO(),
+	}
+	proxy.startSniffTimer()
+	assert.Equal(t, api.Stop, proxy.OnData(buffer.NewIoBufferBytes([]byte("GET / HTTP/1.1\r\n"))))
+	assert.NotNil(t, proxy.serverStreamConn)
+	// the late timer does nothing
+	proxy.onSniffTimeout()
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestProxyDownstreamIdleTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	buff := buffer.NewIoBuffer(0)
+	var closed uint32
+	readCallback := mock.NewMockReadFilterCallbacks(ctrl)
+	readCallback.EXPECT().Connection().AnyTimes().DoAndReturn(func() api.Connection {
+		c := mock.NewMockConnection(ctrl)
+		c.EXPECT().ID().AnyTimes().Return(uint64(1))
+		c.EXPECT().RawConn().AnyTimes().Return(nil)
+		c.EXPECT().GetReadBuffer().AnyTimes().Return(buff)
+		c.EXPECT().Close(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+			atomic.StoreUint32(&closed, 1)
+			return nil
+		})
+		return c
+	})
+
+	proxy := &proxy{
+		config: &v2.Proxy{
+			DownstreamIdleTimeout: &api.DurationConfig{Duration: 100 * time.Millisecond},
+		},
+		readCallbacks: readCallback,
+		context:       context.TODO(),
+		activeStreams: list.New(),
+		fallback:      true,
+	}
+	proxy.downstreamListener = &downstreamCallbacks{
+		proxy: proxy,
+	}
+	newStream := func() *downStream {
+		proxy.asMux.Lock()
+		defer proxy.asMux.Unlock()
+		s := &downStream{}
+		s.element = proxy.activeStreams.PushBack(s)
+		proxy.stopIdleTimer()
+		return s
+	}
+	proxy.asMux.Lock()
+	proxy.startIdleTimer()
+	proxy.asMux.Unlock()
+
+	// the connection with active streams is kept
+	s := newStream()
+	time.Sleep(150 * time.Millisecond)
+	proxy.downstreamListener.OnEvent(api.OnReadTimeout)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&closed))
+
+	// the idle timer is restarted after the streams are finished
+	proxy.deleteActiveStream(s)
+	time.Sleep(50 * time.Millisecond)
+	proxy.downstreamListener.OnEvent(api.OnReadTimeout)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&closed))
+
+	// the timer fired before a new stream does not close the connection
+	time.Sleep(100 * time.Millisecond)
+	proxy.deleteActiveStream(newStream())
+	proxy.downstreamListener.OnEvent(api.OnReadTimeout)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&closed))
+
+	// the connection is closed after the idle timeout
+	time.Sleep(150 * time.Millisecond)
+	proxy.downstreamListener.OnEvent(api.OnReadTimeout)
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&closed))
+
+	// the connection in netpoll mode is closed by the timer without the read timeout event
+	network.UseNetpollMode = true
+	defer func() {
+		network.UseNetpollMode = false
+	}()
+	atomic.StoreUint32(&closed, 0)
+	proxy.deleteActiveStream(newStream())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&closed))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&closed) == 1
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"reflect"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// addPreviousHost records the host tried by the upstream request
+func (s *downStream) addPreviousHost(host types.Host) {
+	if host == nil || containsHost(s.previousHosts, host) {
+		return
+	}
+	s.previousHosts = append(s.previousHosts, host)
+}
+
+// retryExcludedHosts returns the predicate of the hosts a retry should avoid, nil means all the hosts are available.
+// The hosts failed to connect are always avoided. The previous hosts are avoided until all the hosts are tried,
+// then the retry chooses from all the hosts again, so a request to the single host cluster is retried on the same host.
+func (s *downStream) retryExcludedHosts() types.HostPredicate {
+	var hosts []types.Host
+	if s.retryState != nil {
+		hosts = append(hosts, s.retryState.connectFailureHosts...)
+	}
+	if len(s.previousHosts) > 0 && s.snapshot != nil && !reflect.ValueOf(s.snapshot).IsNil() {
+		if len(s.previousHosts) >= s.snapshot.HostNum(s.MetadataMatchCriteria()) {
+			// all the hosts are tried, starts a new round
+			s.previousHosts = s.previousHosts[:0]
+		} else {
+			hosts = append(hosts, s.previousHosts...)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	return hostsPredicate(hosts)
+}
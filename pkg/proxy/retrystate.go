@@ -19,6 +19,10 @@ package proxy
 
 import (
 	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/protocol"
@@ -34,8 +38,43 @@ type retryState struct {
 	retryOn          bool
 	retiesRemaining  uint32
 	upstreamProtocol types.ProtocolName
+	hedgeDelay       time.Duration
+	// retryNonIdempotent allows to retry the http requests with non-idempotent methods
+	retryNonIdempotent bool
+	// grpcStatusCodes are the grpc status codes to retry
+	grpcStatusCodes []uint32
+	// retriableHeaders are the response headers to retry
+	retriableHeaders []types.HeaderMatcher
+	// the exponential back off between the retries
+	backOffBase   time.Duration
+	backOffMax    time.Duration
+	retryAttempts uint32
+	// the connect failures are retried with their own budget,
+	// the failed hosts are excluded when choosing the host to retry
+	connectFailureRetry     bool
+	connectFailureRemaining uint32
+	connectFailureHosts     []types.Host
+	// the retry or the hedged request of the stream holds a slot of the cluster's retry resource
+	retryHeld bool
+	hedgeHeld bool
 }
 
+// defaultRetryInterval is the interval between the retries if the back off is not configured
+const defaultRetryInterval = 10 * time.Millisecond
+
+// idempotentMethods can be retried safely, the other methods are retried only if the
+// retry policy allows or the request carries the idempotency header.
+var idempotentMethods = map[string]struct{}{
+	"GET":     {},
+	"HEAD":    {},
+	"PUT":     {},
+	"DELETE":  {},
+	"OPTIONS": {},
+	"TRACE":   {},
+}
+
+const headerIdempotencyKey = "Idempotency-Key"
+
 func newRetryState(retryPolicy api.RetryPolicy,
 	requestHeaders api.HeaderMap, cluster types.ClusterInfo, proto api.ProtocolName) *retryState {
 	rs := &retryState{
@@ -51,12 +90,49 @@ func newRetryState(retryPolicy api.RetryPolicy,
 		rs.retiesRemaining = retryPolicy.NumRetries()
 	}
 
+	if hp, ok := retryPolicy.(types.HedgePolicy); ok && rs.retryOn {
+		rs.hedgeDelay = hp.HedgeDelay()
+	}
+
+	if ip, ok := retryPolicy.(types.IdempotentRetryPolicy); ok {
+		rs.retryNonIdempotent = ip.RetryNonIdempotent()
+	}
+
+	if gp, ok := retryPolicy.(types.GrpcRetryPolicy); ok {
+		rs.grpcStatusCodes = gp.RetryableGrpcStatusCodes()
+	}
+
+	if hp, ok := retryPolicy.(types.HeaderRetryPolicy); ok {
+		rs.retriableHeaders = hp.RetriableHeaders()
+	}
+
+	if bp, ok := retryPolicy.(types.BackOffRetryPolicy); ok {
+		rs.backOffBase, rs.backOffMax = bp.RetryBackOff()
+	}
+
+	if cp, ok := retryPolicy.(types.ConnectFailureRetryPolicy); ok && cp.RetryOnConnectFailure() {
+		rs.connectFailureRetry = true
+		rs.connectFailureRemaining = rs.retiesRemaining
+	}
+
 	return rs
 }
 
 func (r *retryState) retry(ctx context.Context, headers api.HeaderMap, reason types.StreamResetReason) api.RetryCheckStatus {
 	r.reset()
 
+	if r.connectFailureRetry && reason == types.StreamConnectionFailed {
+		check := r.shouldRetryConnectFailure(ctx)
+		if check != api.ShouldRetry {
+			return check
+		}
+		r.cluster.ResourceManager().Retries().Increase()
+		r.retryHeld = true
+		r.cluster.Stats().UpstreamRequestRetry.Inc(1)
+		r.cluster.Stats().UpstreamRequestConnectFailureRetry.Inc(1)
+		return api.ShouldRetry
+	}
+
 	check := r.shouldRetry(ctx, headers, reason)
 
 	if check != 0 {
@@ -64,11 +140,89 @@ func (r *retryState) retry(ctx context.Context, headers api.HeaderMap, reason ty
 	}
 
 	r.cluster.ResourceManager().Retries().Increase()
+	r.retryHeld = true
 	r.cluster.Stats().UpstreamRequestRetry.Inc(1)
 
 	return 0
 }
 
+// shouldRetryConnectFailure checks the retry of the request failed to connect the upstream.
+// No byte of the request is sent, so it is retried whatever the method is.
+func (r *retryState) shouldRetryConnectFailure(ctx context.Context) api.RetryCheckStatus {
+	if r.connectFailureRemaining == 0 {
+		return api.NoRetry
+	}
+
+	r.connectFailureRemaining--
+
+	if retryDisabled(ctx) {
+		return api.NoRetry
+	}
+
+	if !r.cluster.ResourceManager().Retries().CanCreate() {
+		r.cluster.Stats().UpstreamRequestRetryOverflow.Inc(1)
+
+		return api.RetryOverflow
+	}
+
+	return api.ShouldRetry
+}
+
+// onConnectFailure records the host failed to connect, the retries are sent to the other hosts
+func (r *retryState) onConnectFailure(host types.Host) {
+	if !r.connectFailureRetry || host == nil {
+		return
+	}
+	r.connectFailureHosts = append(r.connectFailureHosts, host)
+}
+
+// retryDisabled checks whether the retry is disabled by the variable
+func retryDisabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if disable, err := variable.Get(ctx, types.VarProxyDisableRetry); err == nil {
+		if retryDisable, ok := disable.(bool); ok && retryDisable {
+			return true
+		}
+	}
+	return false
+}
+
+// hedge checks whether a hedged request can be sent, a hedged request costs a retry
+func (r *retryState) hedge(ctx context.Context) bool {
+	if r.hedgeDelay <= 0 || r.retiesRemaining == 0 {
+		return false
+	}
+	if retryDisabled(ctx) {
+		return false
+	}
+	// the hedged request duplicates the request as a retry
+	if !r.idempotent(ctx) {
+		return false
+	}
+	if !r.cluster.ResourceManager().Retries().CanCreate() {
+		r.cluster.Stats().UpstreamRequestRetryOverflow.Inc(1)
+		return false
+	}
+
+	r.retiesRemaining--
+	r.cluster.ResourceManager().Retries().Increase()
+	r.hedgeHeld = true
+	r.cluster.Stats().UpstreamRequestRetry.Inc(1)
+
+	return true
+}
+
+// releaseHedge releases the retry slot held by the hedged request
+func (r *retryState) releaseHedge() {
+	if !r.hedgeHeld {
+		return
+	}
+	r.hedgeHeld = false
+	r.cluster.ResourceManager().Retries().Decrease()
+}
+
 func (r *retryState) shouldRetry(ctx context.Context, headers api.HeaderMap, reason types.StreamResetReason) api.RetryCheckStatus {
 	if r.retiesRemaining == 0 {
 		return api.NoRetry
@@ -90,19 +244,29 @@ func (r *retryState) shouldRetry(ctx context.Context, headers api.HeaderMap, rea
 }
 
 func (r *retryState) doRetryCheck(ctx context.Context, headers types.HeaderMap, reason types.StreamResetReason) bool {
-	if ctx != nil {
-		if disable, err := variable.Get(ctx, types.VarProxyDisableRetry); err == nil {
-			if retryDisable, ok := disable.(bool); ok && retryDisable {
-				return false
-			}
-		}
+	if retryDisabled(ctx) {
+		return false
 	}
 
 	if reason == types.StreamOverflow {
 		return false
 	}
 
+	// the request is not sent if the connection failed, or not processed if the stream is refused,
+	// so it is safe to retry
+	if reason != types.StreamConnectionFailed && reason != types.StreamRefused && !r.idempotent(ctx) {
+		return false
+	}
+
 	if r.retryOn {
+		// the grpc status is checked first, the http status of a grpc response is always 200
+		if retry, ok := r.grpcRetryCheck(ctx, headers); ok {
+			return retry
+		}
+		// the backend asks for a retry by the response headers, whatever the status is
+		if r.headerRetryCheck(ctx, headers) {
+			return true
+		}
 		// TODO: add retry policy to decide retry or not. use default policy now
 		if ctx != nil {
 			code, err := protocol.MappingHeaderStatusCode(ctx, r.upstreamProtocol, headers)
@@ -119,7 +283,7 @@ func (r *retryState) doRetryCheck(ctx context.Context, headers types.HeaderMap,
 				return code >= http.InternalServerError
 			}
 		}
-		if reason == types.StreamConnectionFailed {
+		if reason == types.StreamConnectionFailed || reason == types.StreamRefused {
 			return true
 		}
 
@@ -132,8 +296,8 @@ func (r *retryState) doRetryCheck(ctx context.Context, headers types.HeaderMap,
 		}
 		// more policy
 	} else {
-		// default support connectionFailed retry
-		if reason == types.StreamConnectionFailed {
+		// default support connectionFailed and refused stream retry
+		if reason == types.StreamConnectionFailed || reason == types.StreamRefused {
 			return true
 		}
 	}
@@ -141,6 +305,144 @@ func (r *retryState) doRetryCheck(ctx context.Context, headers types.HeaderMap,
 	return false
 }
 
+// grpcRetryCheck checks the grpc status of the response if the retry policy has grpc status codes,
+// ok is false if no grpc response is received.
+func (r *retryState) grpcRetryCheck(ctx context.Context, headers types.HeaderMap) (retry bool, ok bool) {
+	if ctx == nil || headers == nil || len(r.grpcStatusCodes) == 0 {
+		return false, false
+	}
+	value, err := variable.GetString(ctx, types.VarGrpcStatus)
+	if err != nil || value == "" {
+		return false, false
+	}
+	status, err := strconv.Atoi(value)
+	if err != nil {
+		return false, false
+	}
+	for _, code := range r.grpcStatusCodes {
+		if status == int(code) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// headerRetryCheck checks whether the response has any of the retriable headers
+func (r *retryState) headerRetryCheck(ctx context.Context, headers types.HeaderMap) bool {
+	if headers == nil {
+		return false
+	}
+	for _, matcher := range r.retriableHeaders {
+		if matcher.Matches(ctx, headers) {
+			return true
+		}
+	}
+	return false
+}
+
+// idempotent checks whether the request can be retried without duplicate side effects,
+// only the http requests are checked, the other protocols have no method semantics.
+func (r *retryState) idempotent(ctx context.Context) bool {
+	if r.retryNonIdempotent || ctx == nil {
+		return true
+	}
+	if r.upstreamProtocol != protocol.HTTP1 && r.upstreamProtocol != protocol.HTTP2 {
+		return true
+	}
+	method, err := variable.GetString(ctx, types.VarMethod)
+	if err != nil || method == "" {
+		return true
+	}
+	if _, ok := idempotentMethods[strings.ToUpper(method)]; ok {
+		return true
+	}
+	if r.requestHeaders != nil {
+		if key, ok := r.requestHeaders.Get(headerIdempotencyKey); ok && key != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// backOff returns the interval to wait before the next retry. With the back off configured,
+// the interval is a random duration in [0, min(base * 2^attempts, max)), known as the full jitter.
+func (r *retryState) backOff() time.Duration {
+	if r.backOffBase <= 0 {
+		return defaultRetryInterval
+	}
+	ceiling := r.backOffBase
+	for i := uint32(0); i < r.retryAttempts && ceiling < r.backOffMax; i++ {
+		ceiling *= 2
+	}
+	if ceiling > r.backOffMax {
+		ceiling = r.backOffMax
+	}
+	r.retryAttempts++
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// reset releases the retry slot held by the stream, the stream without retry holds nothing
 func (r *retryState) reset() {
+	if !r.retryHeld {
+		return
+	}
+	r.retryHeld = false
 	r.cluster.ResourceManager().Retries().Decrease()
 }
+
+// excludeHostsSnapshot makes the load balancer avoid the hosts matched the predicate
+type excludeHostsSnapshot struct {
+	types.ClusterSnapshot
+	excluded types.HostPredicate
+}
+
+func newExcludeHostsSnapshot(snapshot types.ClusterSnapshot, excluded types.HostPredicate) *excludeHostsSnapshot {
+	return &excludeHostsSnapshot{
+		ClusterSnapshot: snapshot,
+		excluded:        excluded,
+	}
+}
+
+func (s *excludeHostsSnapshot) LoadBalancer() types.LoadBalancer {
+	return &excludeHostsLoadBalancer{
+		LoadBalancer: s.ClusterSnapshot.LoadBalancer(),
+		snapshot:     s.ClusterSnapshot,
+		excluded:     s.excluded,
+	}
+}
+
+type excludeHostsLoadBalancer struct {
+	types.LoadBalancer
+	snapshot types.ClusterSnapshot
+	excluded types.HostPredicate
+}
+
+// ChooseHost chooses the host at most the number of hosts times to skip the excluded hosts,
+// the last chosen host is used if all of them are excluded.
+func (lb *excludeHostsLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	try := lb.snapshot.HostNum(context.MetadataMatchCriteria())
+	var host types.Host
+	for i := 0; i < try || i == 0; i++ {
+		host = lb.LoadBalancer.ChooseHost(context)
+		if host == nil || !lb.excluded(host) {
+			return host
+		}
+	}
+	return host
+}
+
+// hostsPredicate matches the hosts in the list by the address
+func hostsPredicate(hosts []types.Host) types.HostPredicate {
+	return func(host types.Host) bool {
+		return containsHost(hosts, host)
+	}
+}
+
+func containsHost(hosts []types.Host, host types.Host) bool {
+	for _, h := range hosts {
+		if h.AddressString() == host.AddressString() {
+			return true
+		}
+	}
+	return false
+}
@@ -22,9 +22,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/types"
@@ -45,6 +49,8 @@ func (ci *fakeClusterInfo) Stats() types.ClusterStats {
 	return types.ClusterStats{
 		UpstreamRequestRetryOverflow: metrics.NewCounter(),
 		UpstreamRequestRetry:         metrics.NewCounter(),
+
+		UpstreamRequestConnectFailureRetry: metrics.NewCounter(),
 	}
 }
 
@@ -183,3 +189,493 @@ func TestRetryStateStatusCode(t *testing.T) {
 		}
 	}
 }
+
+func TestRetryNonIdempotent(t *testing.T) {
+	variable.Register(variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0))
+	variable.Register(variable.NewStringVariable(types.VarMethod, nil, nil, variable.DefaultStringSetter, 0))
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	newPolicy := func(retryNonIdempotent bool) api.RetryPolicy {
+		rcfg := &v2.Router{}
+		rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+			RetryPolicyConfig: v2.RetryPolicyConfig{
+				RetryOn:            true,
+				NumRetries:         10,
+				RetryNonIdempotent: retryNonIdempotent,
+			},
+		}
+		r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+		return r.Policy().RetryPolicy()
+	}
+	newContext := func(method string) context.Context {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarHeaderStatus, "500")
+		if method != "" {
+			variable.SetString(ctx, types.VarMethod, method)
+		}
+		return ctx
+	}
+
+	testcases := []struct {
+		method             string
+		headers            api.HeaderMap
+		proto              types.ProtocolName
+		retryNonIdempotent bool
+		reason             types.StreamResetReason
+		expected           api.RetryCheckStatus
+	}{
+		// POST without opt-in
+		{"POST", nil, protocol.HTTP1, false, "", api.NoRetry},
+		{"POST", protocol.CommonHeader{}, protocol.HTTP2, false, types.StreamConnectionTermination, api.NoRetry},
+		{"PATCH", nil, protocol.HTTP1, false, "", api.NoRetry},
+		// POST with the idempotency header
+		{"POST", protocol.CommonHeader{headerIdempotencyKey: "key"}, protocol.HTTP1, false, "", api.ShouldRetry},
+		// POST with opt-in
+		{"POST", nil, protocol.HTTP1, true, "", api.ShouldRetry},
+		// the request is not sent
+		{"POST", nil, protocol.HTTP1, false, types.StreamConnectionFailed, api.ShouldRetry},
+		// the request is refused by the goaway, not processed
+		{"POST", nil, protocol.HTTP2, false, types.StreamRefused, api.ShouldRetry},
+		// idempotent methods
+		{"GET", nil, protocol.HTTP1, false, "", api.ShouldRetry},
+		{"put", nil, protocol.HTTP2, false, "", api.ShouldRetry},
+		{"DELETE", nil, protocol.HTTP1, false, "", api.ShouldRetry},
+		// no method semantics
+		{"", nil, protocol.HTTP1, false, "", api.ShouldRetry},
+		{"POST", nil, api.ProtocolName("bolt"), false, types.StreamConnectionTermination, api.ShouldRetry},
+	}
+	for i, tc := range testcases {
+		rs := newRetryState(newPolicy(tc.retryNonIdempotent), tc.headers, clusterInfo, tc.proto)
+		if status := rs.retry(newContext(tc.method), nil, tc.reason); status != tc.expected {
+			t.Errorf("#%d retry state expected %v, but got %v", i, tc.expected, status)
+		}
+	}
+
+	// hedged request is not sent for the non-idempotent request
+	rs := newRetryState(newPolicy(false), nil, clusterInfo, protocol.HTTP1)
+	rs.hedgeDelay = time.Millisecond
+	if rs.hedge(newContext("POST")) {
+		t.Error("hedged request should not be sent for POST")
+	}
+	if !rs.hedge(newContext("GET")) {
+		t.Error("hedged request should be sent for GET")
+	}
+}
+
+func TestRetryStateGrpcStatusCode(t *testing.T) {
+	rcfg := &v2.Router{}
+	pcfg := &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryOn:         true,
+			NumRetries:      10,
+			GrpcStatusCodes: []uint32{14},
+		},
+		RetryTimeout: time.Second,
+	}
+	rcfg.Route = v2.RouteAction{}
+	rcfg.Route.RetryPolicy = pcfg
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	policy := r.Policy().RetryPolicy()
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	rs := newRetryState(policy, nil, clusterInfo, protocol.HTTP2)
+
+	variable.Register(variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0))
+	newContext := func(grpcStatus string) context.Context {
+		ctx := variable.NewVariableContext(context.Background())
+		// the http status of a grpc response is always 200
+		variable.SetString(ctx, types.VarHeaderStatus, "200")
+		if grpcStatus != "" {
+			variable.SetString(ctx, types.VarGrpcStatus, grpcStatus)
+		}
+		return ctx
+	}
+	headers := protocol.CommonHeader{"content-type": "application/grpc"}
+
+	testcases := []struct {
+		ctx      context.Context
+		headers  types.HeaderMap
+		Reason   types.StreamResetReason
+		Expected api.RetryCheckStatus
+	}{
+		{newContext("14"), headers, "", api.ShouldRetry},
+		{newContext("0"), headers, "", api.NoRetry},
+		{newContext("13"), headers, "", api.NoRetry},
+		// not a grpc response, the http status is checked
+		{newContext(""), headers, "", api.NoRetry},
+		// the grpc status of the previous try is ignored if the stream is reset
+		{newContext("14"), nil, types.StreamRemoteReset, api.NoRetry},
+	}
+
+	for i, tc := range testcases {
+		if rs.retry(tc.ctx, tc.headers, tc.Reason) != tc.Expected {
+			t.Errorf("#%d retry state failed", i)
+		}
+	}
+}
+
+func TestRetryStateRetriableHeaders(t *testing.T) {
+	variable.Register(variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0))
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryOn:     true,
+			NumRetries:  10,
+			StatusCodes: []uint32{503},
+			RetriableHeaders: []v2.HeaderMatcher{
+				{Name: "x-envoy-overloaded"},
+				{Name: "x-shed", Value: "^(true|1)$", Regex: true},
+			},
+		},
+		RetryTimeout: time.Second,
+	}
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	rs := newRetryState(r.Policy().RetryPolicy(), nil, clusterInfo, protocol.HTTP1)
+	testcases := []struct {
+		status   string
+		headers  map[string]string
+		Expected api.RetryCheckStatus
+	}{
+		// the retriable headers trigger a retry whatever the status is
+		{"200", map[string]string{"x-envoy-overloaded": "true"}, api.ShouldRetry},
+		{"200", map[string]string{"x-envoy-overloaded": ""}, api.ShouldRetry},
+		{"200", map[string]string{"x-shed": "1"}, api.ShouldRetry},
+		{"429", map[string]string{"x-shed": "true"}, api.ShouldRetry},
+		// no retriable header
+		{"200", map[string]string{"x-shed": "false"}, api.NoRetry},
+		{"200", map[string]string{"content-type": "text/plain"}, api.NoRetry},
+		// composes with the status codes
+		{"503", map[string]string{"content-type": "text/plain"}, api.ShouldRetry},
+		{"500", map[string]string{}, api.NoRetry},
+	}
+	for i, tc := range testcases {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarHeaderStatus, tc.status)
+		if rs.retry(ctx, protocol.CommonHeader(tc.headers), "") != tc.Expected {
+			t.Errorf("#%d retry state failed", i)
+		}
+	}
+}
+
+func TestRetryStateBackOff(t *testing.T) {
+	newState := func(backOff *v2.RetryBackOff) *retryState {
+		rcfg := &v2.Router{}
+		rcfg.Route = v2.RouteAction{
+			RetryPolicy: &v2.RetryPolicy{
+				RetryPolicyConfig: v2.RetryPolicyConfig{
+					RetryOn:      true,
+					NumRetries:   10,
+					RetryBackOff: backOff,
+				},
+				RetryTimeout: time.Second,
+			},
+		}
+		r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+		clusterInfo := &fakeClusterInfo{
+			mgr: &fakeResourceManager{},
+		}
+		return newRetryState(r.Policy().RetryPolicy(), nil, clusterInfo, protocol.HTTP1)
+	}
+	// no back off configured
+	rs := newState(nil)
+	for i := 0; i < 3; i++ {
+		if d := rs.backOff(); d != defaultRetryInterval {
+			t.Fatalf("expected default retry interval, but got %v", d)
+		}
+	}
+	// exponential back off with full jitter
+	base := 10 * time.Millisecond
+	max := 80 * time.Millisecond
+	ceilings := []time.Duration{base, 2 * base, 4 * base, max, max, max}
+	for round := 0; round < 100; round++ {
+		rs = newState(&v2.RetryBackOff{
+			BaseInterval: api.DurationConfig{base},
+			MaxInterval:  api.DurationConfig{max},
+		})
+		for i, ceiling := range ceilings {
+			if d := rs.backOff(); d < 0 || d >= ceiling {
+				t.Fatalf("#%d back off %v is out of [0, %v)", i, d, ceiling)
+			}
+		}
+	}
+	// the intervals grow with the attempts
+	var first, last time.Duration
+	for round := 0; round < 100; round++ {
+		rs = newState(&v2.RetryBackOff{
+			BaseInterval: api.DurationConfig{base},
+			MaxInterval:  api.DurationConfig{max},
+		})
+		first += rs.backOff()
+		for i := 1; i < len(ceilings)-1; i++ {
+			rs.backOff()
+		}
+		last += rs.backOff()
+	}
+	if last <= first {
+		t.Fatalf("expected back off grows, first total: %v, last total: %v", first, last)
+	}
+	// max interval defaults to 10 times of the base interval
+	rs = newState(&v2.RetryBackOff{
+		BaseInterval: api.DurationConfig{base},
+	})
+	if rs.backOffBase != base || rs.backOffMax != 10*base {
+		t.Fatalf("unexpected back off config, base: %v, max: %v", rs.backOffBase, rs.backOffMax)
+	}
+}
+
+func newConnectFailureRetryPolicy(t *testing.T, retryOn bool) api.RetryPolicy {
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryOn:         retryOn,
+			NumRetries:      3,
+			RetryConditions: []string{types.RetryOnConnectFailure},
+		},
+	}
+	r, err := router.NewRouteRuleImplBase(nil, rcfg)
+	if err != nil {
+		t.Fatalf("create route rule failed: %v", err)
+	}
+	return r.Policy().RetryPolicy()
+}
+
+func TestRetryStateConnectFailure(t *testing.T) {
+	variable.Register(variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0))
+	variable.Register(variable.NewStringVariable(types.VarMethod, nil, nil, variable.DefaultStringSetter, 0))
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarMethod, "POST")
+	variable.SetString(ctx, types.VarHeaderStatus, "500")
+
+	rs := newRetryState(newConnectFailureRetryPolicy(t, true), nil, clusterInfo, protocol.HTTP1)
+	if !rs.connectFailureRetry || rs.connectFailureRemaining != 3 {
+		t.Fatalf("unexpected connect failure retry: %v, %d", rs.connectFailureRetry, rs.connectFailureRemaining)
+	}
+	// the connect failures have their own budget
+	for i := 0; i < 3; i++ {
+		if status := rs.retry(ctx, nil, types.StreamConnectionFailed); status != api.ShouldRetry {
+			t.Errorf("#%d connect failure expected retry, but got %v", i, status)
+		}
+	}
+	if status := rs.retry(ctx, nil, types.StreamConnectionFailed); status != api.NoRetry {
+		t.Errorf("connect failure budget is exhausted, but got %v", status)
+	}
+	// the response retries are not affected, and the POST is not retried
+	if rs.retiesRemaining != 3 {
+		t.Errorf("unexpected retries remaining: %d", rs.retiesRemaining)
+	}
+	if status := rs.retry(ctx, nil, ""); status != api.NoRetry {
+		t.Errorf("POST should not be retried on response, but got %v", status)
+	}
+
+	// the retry is disabled
+	rs = newRetryState(newConnectFailureRetryPolicy(t, false), nil, clusterInfo, protocol.HTTP1)
+	disabled := variable.NewVariableContext(context.Background())
+	variable.Set(disabled, types.VarProxyDisableRetry, true)
+	if status := rs.retry(disabled, nil, types.StreamConnectionFailed); status != api.NoRetry {
+		t.Errorf("retry is disabled, but got %v", status)
+	}
+
+	// unknown condition
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryConditions: []string{"unknown"},
+		},
+	}
+	if _, err := router.NewRouteRuleImplBase(nil, rcfg); err == nil {
+		t.Error("unknown retry condition should be rejected")
+	}
+}
+
+// sequenceLoadBalancer chooses the hosts in order
+type sequenceLoadBalancer struct {
+	types.LoadBalancer
+	hosts []types.Host
+	index int
+}
+
+func (lb *sequenceLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	host := lb.hosts[lb.index%len(lb.hosts)]
+	lb.index++
+	return host
+}
+
+func TestRetryConnectFailureOtherHost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, method := range []string{"GET", "POST"} {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarMethod, method)
+		info := gomockHedgeClusterInfo(ctrl)
+		refused := gomockHedgeHost(ctrl, "127.0.0.1:8080", info)
+		available := gomockHedgeHost(ctrl, "127.0.0.1:8081", info)
+
+		// the connection to the first host is refused
+		refusedPool := mock.NewMockConnectionPool(ctrl)
+		refusedPool.EXPECT().NewStream(gomock.Any(), gomock.Any()).Return(refused, nil, types.ConnectionFailure).AnyTimes()
+		var reset int32
+		availablePool := mock.NewMockConnectionPool(ctrl)
+		availablePool.EXPECT().NewStream(gomock.Any(), gomock.Any()).Return(available, gomockHedgeSender(ctrl, &reset), types.PoolFailureReason("")).AnyTimes()
+		pools := map[string]types.ConnectionPool{
+			refused.AddressString():   refusedPool,
+			available.AddressString(): availablePool,
+		}
+		clusterManager := mock.NewMockClusterManager(ctrl)
+		clusterManager.EXPECT().ConnPoolForCluster(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(lbCtx types.LoadBalancerContext, snapshot types.ClusterSnapshot, _ types.ProtocolName) (types.ConnectionPool, types.Host) {
+				host := snapshot.LoadBalancer().ChooseHost(lbCtx)
+				return pools[host.AddressString()], host
+			}).AnyTimes()
+		// the load balancer always chooses the refused host first
+		lb := &sequenceLoadBalancer{hosts: []types.Host{refused, available}}
+		snapshot := mock.NewMockClusterSnapshot(ctrl)
+		snapshot.EXPECT().LoadBalancer().DoAndReturn(func() types.LoadBalancer {
+			lb.index = 0
+			return lb
+		}).AnyTimes()
+		snapshot.EXPECT().HostNum(gomock.Any()).Return(2).AnyTimes()
+
+		s := &downStream{
+			ID:                   1,
+			context:              ctx,
+			cluster:              info,
+			snapshot:             snapshot,
+			requestInfo:          &network.RequestInfo{},
+			notify:               make(chan struct{}, 1),
+			downstreamReqHeaders: protocol.CommonHeader{},
+			proxy: &proxy{
+				config: &v2.Proxy{
+					DownstreamProtocol: "Http1",
+				},
+				clusterManager: clusterManager,
+				stats:          globalStats,
+				listenerStats:  newListenerStats("test"),
+			},
+		}
+		s.retryState = newRetryState(newConnectFailureRetryPolicy(t, false), nil, info, protocol.HTTP1)
+		host, pool, err := s.initializeUpstreamConnectionPool(s)
+		assert.Nil(t, err)
+		assert.Equal(t, refused, host)
+		s.upstreamRequest = &upstreamRequest{
+			downStream: s,
+			proxy:      s.proxy,
+			host:       host,
+			connPool:   pool,
+			protocol:   protocol.HTTP1,
+		}
+
+		retries := info.Stats().UpstreamRequestConnectFailureRetry.Count()
+		s.upstreamRequest.appendHeaders(true)
+		assert.Equal(t, uint32(1), s.upstreamReset, method)
+		s.onUpstreamReset(types.StreamConnectionFailed)
+		assert.True(t, s.upstreamRequest.setupRetry, method)
+		assert.Equal(t, retries+1, info.Stats().UpstreamRequestConnectFailureRetry.Count(), method)
+
+		// the retry is sent to the other host
+		s.doRetry()
+		assert.Equal(t, available, s.upstreamRequest.host, method)
+		assert.NotNil(t, s.upstreamRequest.requestSender, method)
+		assert.Equal(t, uint32(0), s.upstreamReset, method)
+	}
+}
+
+func TestRetryPreviousHosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newDownstream := func(addrs ...string) *downStream {
+		ctx := variable.NewVariableContext(context.Background())
+		variable.SetString(ctx, types.VarMethod, "GET")
+		info := gomockHedgeClusterInfo(ctrl)
+		var reset int32
+		hosts := make([]types.Host, 0, len(addrs))
+		pools := map[string]types.ConnectionPool{}
+		for _, addr := range addrs {
+			host := gomockHedgeHost(ctrl, addr, info)
+			pool := mock.NewMockConnectionPool(ctrl)
+			pool.EXPECT().NewStream(gomock.Any(), gomock.Any()).Return(host, gomockHedgeSender(ctrl, &reset), types.PoolFailureReason("")).AnyTimes()
+			hosts = append(hosts, host)
+			pools[addr] = pool
+		}
+		clusterManager := mock.NewMockClusterManager(ctrl)
+		clusterManager.EXPECT().ConnPoolForCluster(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(lbCtx types.LoadBalancerContext, snapshot types.ClusterSnapshot, _ types.ProtocolName) (types.ConnectionPool, types.Host) {
+				host := snapshot.LoadBalancer().ChooseHost(lbCtx)
+				return pools[host.AddressString()], host
+			}).AnyTimes()
+		// the load balancer always chooses the first host without the previous hosts predicate
+		lb := &sequenceLoadBalancer{hosts: hosts}
+		snapshot := mock.NewMockClusterSnapshot(ctrl)
+		snapshot.EXPECT().LoadBalancer().DoAndReturn(func() types.LoadBalancer {
+			lb.index = 0
+			return lb
+		}).AnyTimes()
+		snapshot.EXPECT().HostNum(gomock.Any()).Return(len(hosts)).AnyTimes()
+
+		s := &downStream{
+			ID:                   1,
+			context:              ctx,
+			cluster:              info,
+			snapshot:             snapshot,
+			requestInfo:          &network.RequestInfo{},
+			notify:               make(chan struct{}, 1),
+			downstreamReqHeaders: protocol.CommonHeader{},
+			proxy: &proxy{
+				config: &v2.Proxy{
+					DownstreamProtocol: "Http1",
+				},
+				clusterManager: clusterManager,
+				stats:          globalStats,
+				listenerStats:  newListenerStats("test"),
+			},
+		}
+		s.retryState = newRetryState(newConnectFailureRetryPolicy(t, true), nil, info, protocol.HTTP1)
+		host, pool, err := s.initializeUpstreamConnectionPool(s)
+		assert.Nil(t, err)
+		s.upstreamRequest = &upstreamRequest{
+			downStream: s,
+			proxy:      s.proxy,
+			host:       host,
+			connPool:   pool,
+			protocol:   protocol.HTTP1,
+		}
+		return s
+	}
+
+	t.Run("multiple hosts", func(t *testing.T) {
+		s := newDownstream("127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082")
+		assert.Equal(t, "127.0.0.1:8080", s.upstreamRequest.host.AddressString())
+		// the retries are sent to the hosts not tried before
+		tried := map[string]bool{s.upstreamRequest.host.AddressString(): true}
+		for i := 0; i < 2; i++ {
+			s.doRetry()
+			addr := s.upstreamRequest.host.AddressString()
+			assert.False(t, tried[addr], "host %s is retried", addr)
+			tried[addr] = true
+		}
+		assert.Len(t, tried, 3)
+		// all the hosts are tried, the retry chooses from all the hosts
+		s.doRetry()
+		assert.Equal(t, "127.0.0.1:8080", s.upstreamRequest.host.AddressString())
+		s.doRetry()
+		assert.Equal(t, "127.0.0.1:8081", s.upstreamRequest.host.AddressString())
+	})
+
+	t.Run("single host", func(t *testing.T) {
+		s := newDownstream("127.0.0.1:8080")
+		for i := 0; i < 3; i++ {
+			s.doRetry()
+			assert.Equal(t, "127.0.0.1:8080", s.upstreamRequest.host.AddressString())
+			assert.NotNil(t, s.upstreamRequest.requestSender)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/stream"
+)
+
+// defaultSniffMaxBytes is the default max bytes peeked to detect the protocol,
+// it is enough for the magic of the registered protocols.
+const defaultSniffMaxBytes = 1024
+
+// protocolSniffer detects the protocol with the negotiated protocol of the TLS connection
+// and the first bytes of the connection, returns nil if the protocol is detected,
+// stream.EAGAIN if more bytes are needed, otherwise stream.FAILED.
+type protocolSniffer func(alpn string, data []byte) error
+
+// protocolSniffers detects the protocols usually served on one port, they are tried before
+// the protocol matchers of the other registered protocols.
+var protocolSniffers = []struct {
+	name  api.ProtocolName
+	sniff protocolSniffer
+}{
+	{protocol.HTTP2, sniffHTTP2},
+	{protocol.HTTP1, sniffHTTP1},
+	{bolt.ProtocolName, sniffBolt},
+}
+
+const (
+	alpnHTTP1 = "http/1.1"
+	alpnHTTP2 = "h2"
+
+	http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	// the shortest methods are GET and PUT, the longest method is CONNECT
+	minHTTPMethodLength = 3
+	maxHTTPMethodLength = 7
+)
+
+var httpMethods = map[string]bool{
+	"OPTIONS": true,
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"DELETE":  true,
+	"TRACE":   true,
+	"CONNECT": true,
+	"PATCH":   true,
+	"LINK":    true,
+	"UNLINK":  true,
+}
+
+// sniffHTTP1 detects the HTTP/1 request line by the method followed by a space,
+// waits for more bytes if the bytes are shorter than any method.
+func sniffHTTP1(alpn string, data []byte) error {
+	switch alpn {
+	case alpnHTTP1:
+		return nil
+	case alpnHTTP2:
+		return stream.FAILED
+	}
+	if len(data) < minHTTPMethodLength {
+		return stream.EAGAIN
+	}
+	sp := bytes.IndexByte(data, ' ')
+	if sp < 0 {
+		if len(data) > maxHTTPMethodLength {
+			return stream.FAILED
+		}
+		for method := range httpMethods {
+			if len(data) <= len(method) && method[:len(data)] == string(data) {
+				return stream.EAGAIN
+			}
+		}
+		return stream.FAILED
+	}
+	if httpMethods[string(data[:sp])] {
+		return nil
+	}
+	return stream.FAILED
+}
+
+// sniffHTTP2 detects the HTTP/2 connection preface
+func sniffHTTP2(alpn string, data []byte) error {
+	switch alpn {
+	case alpnHTTP2:
+		return nil
+	case alpnHTTP1:
+		return stream.FAILED
+	}
+	if len(data) < len(http2Preface) {
+		if string(data) == http2Preface[:len(data)] {
+			return stream.EAGAIN
+		}
+		return stream.FAILED
+	}
+	if string(data[:len(http2Preface)]) == http2Preface {
+		return nil
+	}
+	return stream.FAILED
+}
+
+// sniffBolt detects the bolt magic, the protocol code, the cmd type and the cmd code
+func sniffBolt(alpn string, data []byte) error {
+	if len(data) == 0 {
+		return stream.EAGAIN
+	}
+	if data[0] != bolt.ProtocolCode {
+		return stream.FAILED
+	}
+	if len(data) < 2 {
+		return stream.EAGAIN
+	}
+	switch data[1] {
+	case bolt.CmdTypeRequest, bolt.CmdTypeRequestOneway, bolt.CmdTypeResponse:
+	default:
+		return stream.FAILED
+	}
+	if len(data) < 4 {
+		return stream.EAGAIN
+	}
+	switch binary.BigEndian.Uint16(data[2:4]) {
+	case bolt.CmdCodeHeartbeat, bolt.CmdCodeRpcRequest, bolt.CmdCodeRpcResponse, bolt.CmdCodeGoAway:
+		return nil
+	}
+	return stream.FAILED
+}
+
+// sniffProtocol detects the protocol in the scopes of the proxy, the protocols with the sniffers
+// are tried first, the other protocols are detected by the registered protocol matchers.
+func (p *proxy) sniffProtocol(alpn string, data []byte) (api.ProtocolName, error) {
+	scopes := p.protocols
+	// if protocols is Auto only, match all registered protocols
+	if len(scopes) == 0 || (len(scopes) == 1 && scopes[0] == protocol.Auto) {
+		scopes = nil
+		protocol.RangeAllRegisteredProtocol(func(name api.ProtocolName) {
+			scopes = append(scopes, name)
+		})
+	}
+	inScopes := make(map[api.ProtocolName]bool, len(scopes))
+	for _, scope := range scopes {
+		inScopes[scope] = true
+	}
+
+	var again bool
+	for _, sniffer := range protocolSniffers {
+		if !inScopes[sniffer.name] {
+			continue
+		}
+		delete(inScopes, sniffer.name)
+		switch sniffer.sniff(alpn, data) {
+		case nil:
+			return sniffer.name, nil
+		case stream.EAGAIN:
+			again = true
+		}
+	}
+	others := make([]api.ProtocolName, 0, len(inScopes))
+	for _, scope := range scopes {
+		if inScopes[scope] {
+			others = append(others, scope)
+		}
+	}
+	if len(others) > 0 {
+		proto, err := stream.SelectStreamFactoryProtocol(p.context, alpn, data, others)
+		if err == nil {
+			return proto, nil
+		}
+		if err == stream.EAGAIN {
+			again = true
+		}
+	}
+	if again {
+		return "", stream.EAGAIN
+	}
+	return "", stream.FAILED
+}
+
+func (p *proxy) sniffMaxBytes() int {
+	if p.config != nil && p.config.ProtocolSniff != nil && p.config.ProtocolSniff.MaxBytes > 0 {
+		return p.config.ProtocolSniff.MaxBytes
+	}
+	return defaultSniffMaxBytes
+}
+
+// sniffBytes returns the bytes peeked to detect the protocol
+func (p *proxy) sniffBytes(buf buffer.IoBuffer) []byte {
+	data := buf.Bytes()
+	if max := p.sniffMaxBytes(); len(data) > max {
+		return data[:max]
+	}
+	return data
+}
+
+// sniffExhausted returns true if no more bytes should be waited to detect the protocol
+func (p *proxy) sniffExhausted(buf buffer.IoBuffer) bool {
+	return atomic.LoadUint32(&p.sniffTimeout) == 1 || buf.Len() >= p.sniffMaxBytes()
+}
+
+// sniffDefaultProtocol returns the protocol used if the protocol can not be detected
+func (p *proxy) sniffDefaultProtocol() (api.ProtocolName, error) {
+	if p.config == nil || p.config.ProtocolSniff == nil || p.config.ProtocolSniff.DefaultProtocol == "" {
+		return "", stream.FAILED
+	}
+	return api.ProtocolName(p.config.ProtocolSniff.DefaultProtocol), nil
+}
+
+// startSniffTimer starts the sniff timer if the sniff timeout is configured
+func (p *proxy) startSniffTimer() {
+	if p.config == nil || p.config.ProtocolSniff == nil || p.config.ProtocolSniff.Timeout.Duration <= 0 {
+		return
+	}
+	p.sniffTimer = utils.NewTimer(p.config.ProtocolSniff.Timeout.Duration, p.onSniffTimeout)
+}
+
+func (p *proxy) stopSniffTimer() {
+	if p.sniffTimer != nil {
+		p.sniffTimer.Stop()
+	}
+}
+
+// onSniffTimeout handles the sniff timeout if the protocol is not detected yet.
+// if some bytes are received, the read loop of the connection is woken up, and the protocol is selected
+// with the received bytes when the OnReadTimeout event is triggered. the read loop can not be woken up
+// in netpoll mode, or no bytes are received, the connection is closed in the timer.
+func (p *proxy) onSniffTimeout() {
+	atomic.StoreUint32(&p.sniffTimeout, 1)
+	if atomic.LoadUint32(&p.sniffDone) == 1 {
+		return
+	}
+	conn := p.readCallbacks.Connection()
+	if atomic.LoadUint32(&p.sniffReceived) == 1 && !network.UseNetpollMode {
+		if rawConn := conn.RawConn(); rawConn != nil {
+			err := rawConn.SetReadDeadline(time.Now())
+			if err == nil {
+				return
+			}
+			log.DefaultLogger.Warnf("[proxy] protocol sniff timeout, wake up connection %d failed: %v", conn.ID(), err)
+		}
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[proxy] protocol sniff timeout, close connection %d", conn.ID())
+	}
+	conn.Close(api.NoFlush, api.LocalClose)
+}
+
+// shouldSniffDefault returns true if the protocol is not detected in the sniff timeout,
+// and some bytes are received.
+func (p *proxy) shouldSniffDefault() bool {
+	if p.serverStreamConn != nil || p.fallback || atomic.LoadUint32(&p.sniffTimeout) == 0 {
+		return false
+	}
+	buf := p.readCallbacks.Connection().GetReadBuffer()
+	return buf != nil && buf.Len() > 0
+}
@@ -0,0 +1,146 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/stream"
+)
+
+func TestSniffHTTP1(t *testing.T) {
+	testCases := []struct {
+		alpn string
+		data string
+		want error
+	}{
+		{data: "GET / HTTP/1.1\r\n", want: nil},
+		{data: "CONNECT example.com:443 HTTP/1.1\r\n", want: nil},
+		{data: "UNLINK ", want: nil},
+		{data: "", want: stream.EAGAIN},
+		{data: "PO", want: stream.EAGAIN},
+		{data: "DELETE", want: stream.EAGAIN},
+		{data: "GE", want: stream.EAGAIN},
+		{data: "GETX / HTTP/1.1\r\n", want: stream.FAILED},
+		{data: "GEX", want: stream.FAILED},
+		{data: "get / HTTP/1.1\r\n", want: stream.FAILED},
+		{data: "PRI * HTTP/2.0\r\n", want: stream.FAILED},
+		{data: "\x01\x01\x00\x01", want: stream.FAILED},
+		{alpn: "http/1.1", data: "", want: nil},
+		{alpn: "h2", data: "GET / HTTP/1.1\r\n", want: stream.FAILED},
+	}
+	for i, tc := range testCases {
+		assert.Equal(t, tc.want, sniffHTTP1(tc.alpn, []byte(tc.data)), "#%d %q", i, tc.data)
+	}
+}
+
+func TestSniffHTTP2(t *testing.T) {
+	testCases := []struct {
+		alpn string
+		data string
+		want error
+	}{
+		{data: "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", want: nil},
+		{data: "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n\x00\x00\x12\x04", want: nil},
+		{data: "", want: stream.EAGAIN},
+		{data: "PRI * HTTP/2.0\r\n", want: stream.EAGAIN},
+		{data: "PRI * HTTP/1.1\r\n", want: stream.FAILED},
+		{data: "GET / HTTP/1.1\r\n", want: stream.FAILED},
+		{alpn: "h2", data: "", want: nil},
+		{alpn: "http/1.1", data: "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", want: stream.FAILED},
+	}
+	for i, tc := range testCases {
+		assert.Equal(t, tc.want, sniffHTTP2(tc.alpn, []byte(tc.data)), "#%d %q", i, tc.data)
+	}
+}
+
+func TestSniffBolt(t *testing.T) {
+	testCases := []struct {
+		data []byte
+		want error
+	}{
+		{data: []byte{bolt.ProtocolCode, bolt.CmdTypeRequest, 0x00, 0x01, 0x01}, want: nil},
+		{data: []byte{bolt.ProtocolCode, bolt.CmdTypeRequestOneway, 0x00, 0x01}, want: nil},
+		{data: []byte{bolt.ProtocolCode, bolt.CmdTypeRequest, 0x00, 0x00}, want: nil},
+		{data: []byte{bolt.ProtocolCode, bolt.CmdTypeResponse, 0x00, 0x02}, want: nil},
+		{data: []byte{}, want: stream.EAGAIN},
+		{data: []byte{bolt.ProtocolCode}, want: stream.EAGAIN},
+		{data: []byte{bolt.ProtocolCode, bolt.CmdTypeRequest, 0x00}, want: stream.EAGAIN},
+		{data: []byte{0x02, bolt.CmdTypeRequest, 0x00, 0x01}, want: stream.FAILED},
+		{data: []byte{bolt.ProtocolCode, 0x03}, want: stream.FAILED},
+		{data: []byte{bolt.ProtocolCode, bolt.CmdTypeRequest, 0x00, 0x03}, want: stream.FAILED},
+		{data: []byte("GET / HTTP/1.1\r\n"), want: stream.FAILED},
+	}
+	for i, tc := range testCases {
+		assert.Equal(t, tc.want, sniffBolt("", tc.data), "#%d %v", i, tc.data)
+	}
+}
+
+func TestSniffProtocol(t *testing.T) {
+	testCases := []struct {
+		protocols []api.ProtocolName
+		data      []byte
+		wantProto api.ProtocolName
+		wantErr   error
+	}{
+		{
+			data:      []byte("GET / HTTP/1.1\r\n"),
+			wantProto: protocol.HTTP1,
+		},
+		{
+			protocols: []api.ProtocolName{protocol.Auto},
+			data:      []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"),
+			wantProto: protocol.HTTP2,
+		},
+		{
+			data:      []byte{bolt.ProtocolCode, bolt.CmdTypeRequest, 0x00, 0x01},
+			wantProto: bolt.ProtocolName,
+		},
+		{
+			data:    []byte("PRI * HTTP/2.0\r\n"),
+			wantErr: stream.EAGAIN,
+		},
+		{
+			data:    []byte("GETX / HTTP/1.1\r\n"),
+			wantErr: stream.FAILED,
+		},
+		{
+			// the protocol out of the scopes is not detected
+			protocols: []api.ProtocolName{protocol.HTTP2, bolt.ProtocolName},
+			data:      []byte("GET / HTTP/1.1\r\n"),
+			wantErr:   stream.FAILED,
+		},
+	}
+	for i, tc := range testCases {
+		p := &proxy{
+			config:    &v2.Proxy{},
+			context:   context.TODO(),
+			protocols: tc.protocols,
+		}
+		proto, err := p.sniffProtocol("", tc.data)
+		assert.Equal(t, tc.wantErr, err, "#%d", i)
+		assert.Equal(t, tc.wantProto, proto, "#%d", i)
+	}
+}
@@ -18,8 +18,11 @@
 package proxy
 
 import (
+	"context"
+
 	gometrics "github.com/rcrowley/go-metrics"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -51,6 +54,11 @@ type Stats struct {
 	DownstreamRequest503Total   gometrics.Counter
 	DownstreamRequest504Total   gometrics.Counter
 	DownstreamRequestOtherTotal gometrics.Counter
+	DownstreamFilterTimeout     gometrics.Counter
+	ReMatchRouteExceeded        gometrics.Counter
+	ReChooseHostExceeded        gometrics.Counter
+	RequestBodyExceeded         gometrics.Counter
+	ResponseBodyExceeded        gometrics.Counter
 }
 
 func newListenerStats(listenerName string) *Stats {
@@ -91,6 +99,11 @@ func newStats(s types.Metrics) *Stats {
 		DownstreamRequest503Total:   s.Counter(metrics.DownstreamRequest503Total),
 		DownstreamRequest504Total:   s.Counter(metrics.DownstreamRequest504Total),
 		DownstreamRequestOtherTotal: s.Counter(metrics.DownstreamRequestOtherTotal),
+		DownstreamFilterTimeout:     s.Counter(metrics.DownstreamFilterTimeout),
+		ReMatchRouteExceeded:        s.Counter(metrics.DownstreamReMatchRouteExceed),
+		ReChooseHostExceeded:        s.Counter(metrics.DownstreamReChooseHostExceed),
+		RequestBodyExceeded:         s.Counter(metrics.DownstreamRequestBodyExceed),
+		ResponseBodyExceeded:        s.Counter(metrics.DownstreamResponseBodyExceed),
 	}
 }
 
@@ -126,3 +139,19 @@ func (s *Stats) DownstreamUpdateRequestCode(returnCode int) {
 		s.DownstreamRequestOtherTotal.Inc(1)
 	}
 }
+
+// exemplarTraceId returns the trace id that attached to the latency histograms as exemplar,
+// returns empty string if the request is not traced or the span is not sampled
+func exemplarTraceId(ctx context.Context) string {
+	if !trace.IsEnabled() {
+		return ""
+	}
+	span := trace.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	if s, ok := span.(interface{ Sampled() bool }); ok && !s.Sampled() {
+		return ""
+	}
+	return span.TraceId()
+}
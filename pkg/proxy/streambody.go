@@ -0,0 +1,191 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/streamfilter"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+	"mosn.io/pkg/variable"
+)
+
+const streamBodyReadSize = 16 * 1024
+
+// defaultMaxBufferedRequestBodyBytes limits the streamed request body buffered by the proxy
+// if the route does not limit the request body size
+var defaultMaxBufferedRequestBodyBytes uint64 = 64 * 1024 * 1024
+
+var errRequestBodyTooLarge = errors.New("request body is too large")
+
+// requestBodyStreamed returns true if the request body is received as a stream, the body is
+// written by the downstream connection while the request is proxied.
+func (s *downStream) requestBodyStreamed() bool {
+	if s.downstreamReqDataBuf == nil {
+		return false
+	}
+	v, err := variable.Get(s.context, types.VarHttp2RequestUseStream)
+	if err != nil {
+		return false
+	}
+	streamed, ok := v.(bool)
+	return ok && streamed
+}
+
+// routeBufferRequestBody returns true if the route requires the streamed request body to be buffered,
+// the route which limits the request body size buffers it, so the limit is checked while the body is read.
+func (s *downStream) routeBufferRequestBody() bool {
+	if rule := s.bodyLimitRule(); rule != nil && rule.MaxRequestBodyBytes() > 0 {
+		return true
+	}
+	if s.route == nil || s.route.RouteRule() == nil {
+		return false
+	}
+	rule, ok := s.route.RouteRule().(types.StreamRequestBodyRule)
+	if !ok {
+		return false
+	}
+	stream, ok := rule.StreamRequestBody()
+	return ok && !stream
+}
+
+// requestBodyLimit returns the max size of the request body buffered by the proxy
+func (s *downStream) requestBodyLimit() uint64 {
+	if rule := s.bodyLimitRule(); rule != nil && rule.MaxRequestBodyBytes() > 0 {
+		return rule.MaxRequestBodyBytes()
+	}
+	return defaultMaxBufferedRequestBodyBytes
+}
+
+// filterNeedRequestBody returns true if any receiver filter requires the full request body
+func (s *downStream) filterNeedRequestBody() bool {
+	need := false
+	s.streamFilterChain.RangeReceiverFilter(s.context, s.downstreamReqHeaders, s.downstreamReqDataBuf, s.downstreamReqTrailers,
+		func(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap, filter api.StreamReceiverFilter) {
+			if f, ok := filter.(streamfilter.StreamReceiverFilterBodyBuffering); ok && !need {
+				need = f.NeedRequestBody(ctx, headers)
+			}
+		})
+	return need
+}
+
+// bufferRequestBody reads the streamed request body until the end, so the filters and
+// the upstream request see the full body. it returns false and sends a hijack reply with 413
+// if the body exceeds the limit, the rest of the body is not read.
+func (s *downStream) bufferRequestBody() bool {
+	if !s.requestBodyStreamed() {
+		return true
+	}
+	stream := s.downstreamReqDataBuf
+	limit := s.requestBodyLimit()
+	body, err := readStreamedBody(stream, limit)
+	s.downstreamReqDataBuf = body
+	// the upstream request is not sent as a stream any more
+	_ = variable.Set(s.context, types.VarHttp2RequestUseStream, false)
+	if err == errRequestBodyTooLarge {
+		// stops the downstream writing the rest of the body
+		stream.CloseWithError(err)
+		s.sendRequestBodyTooLarge(uint64(body.Len()), limit)
+		return false
+	}
+	if err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] read streamed request body failed: %v, proxyId: %d", err, s.ID)
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] streamed request body is buffered, size: %d", body.Len())
+	}
+	return true
+}
+
+// peekRequestBody returns the request body if it is not larger than the limit, the streamed body is
+// read at most limit + 1 bytes, the bytes read from the larger body are streamed to the upstream
+// with the rest of the body.
+func (s *downStream) peekRequestBody(limit uint64) (types.IoBuffer, bool) {
+	if !s.requestBodyStreamed() {
+		return s.downstreamReqDataBuf, uint64(s.downstreamReqDataBuf.Len()) <= limit
+	}
+	stream := s.downstreamReqDataBuf
+	body, err := readStreamedBody(stream, limit)
+	if err == errRequestBodyTooLarge {
+		s.downstreamReqDataBuf = prependStreamedBody(body, stream)
+		return nil, false
+	}
+	s.downstreamReqDataBuf = body
+	// the whole body is read, the upstream request is not sent as a stream any more
+	_ = variable.Set(s.context, types.VarHttp2RequestUseStream, false)
+	if err != nil {
+		log.Proxy.Errorf(s.context, "[proxy] [downstream] read streamed request body failed: %v, proxyId: %d", err, s.ID)
+		return body, false
+	}
+	return body, true
+}
+
+// prependStreamedBody returns a streamed body which reads the head first and then the rest of the stream
+func prependStreamedBody(head types.IoBuffer, stream types.IoBuffer) types.IoBuffer {
+	body := buffer.NewPipeBuffer(head.Len())
+	_, _ = body.Write(head.Bytes())
+	utils.GoWithRecover(func() {
+		p := make([]byte, streamBodyReadSize)
+		for {
+			n, err := stream.Read(p)
+			if n > 0 {
+				if _, werr := body.Write(p[:n]); werr != nil {
+					stream.CloseWithError(werr)
+					return
+				}
+			}
+			if err != nil {
+				body.CloseWithError(err)
+				return
+			}
+		}
+	}, nil)
+	return body
+}
+
+// readStreamedBody reads the streamed body until io.EOF, the streamed body blocks the read until
+// new data is written or the stream ends. it stops reading and returns errRequestBodyTooLarge once
+// the body exceeds the limit, so at most limit + 1 bytes are held.
+func readStreamedBody(stream types.IoBuffer, limit uint64) (types.IoBuffer, error) {
+	body := buffer.GetIoBuffer(stream.Len())
+	p := make([]byte, streamBodyReadSize)
+	for {
+		if remain := limit + 1 - uint64(body.Len()); remain < uint64(len(p)) {
+			p = p[:remain]
+		}
+		n, err := stream.Read(p)
+		if n > 0 {
+			_, _ = body.Write(p[:n])
+		}
+		if uint64(body.Len()) > limit {
+			return body, errRequestBodyTooLarge
+		}
+		if err == io.EOF {
+			return body, nil
+		}
+		if err != nil {
+			return body, err
+		}
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+const (
+	largeBodyChunk  = 64 * 1024
+	largeBodyChunks = 64
+)
+
+type streamBodyRouteRule struct {
+	mockRouteRule
+	stream bool
+	ok     bool
+}
+
+func (r *streamBodyRouteRule) StreamRequestBody() (bool, bool) {
+	return r.stream, r.ok
+}
+
+// bodyBufferingFilter requires the full request body
+type bodyBufferingFilter struct {
+	handler api.StreamReceiverFilterHandler
+	body    int
+}
+
+func (f *bodyBufferingFilter) OnDestroy() {}
+
+func (f *bodyBufferingFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	f.body = buf.Len()
+	return api.StreamFilterContinue
+}
+
+func (f *bodyBufferingFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *bodyBufferingFilter) NeedRequestBody(ctx context.Context, headers types.HeaderMap) bool {
+	return true
+}
+
+// newStreamedBody writes the first chunk of a large body, the rest is written after release is closed
+func newStreamedBody() (body types.IoBuffer, release chan struct{}) {
+	body = buffer.NewPipeBuffer(largeBodyChunk)
+	release = make(chan struct{})
+	chunk := make([]byte, largeBodyChunk)
+	_, _ = body.Write(chunk)
+	go func() {
+		<-release
+		for i := 1; i < largeBodyChunks; i++ {
+			_, _ = body.Write(chunk)
+		}
+		body.CloseWithError(io.EOF)
+	}()
+	return body, release
+}
+
+func newStreamedDownstream(rule api.RouteRule) (*downStream, chan struct{}) {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VarHttp2RequestUseStream, true)
+	body, release := newStreamedBody()
+	s := &downStream{
+		context: ctx,
+		proxy: &proxy{
+			config: &v2.Proxy{},
+			routersWrapper: &mockRouterWrapper{
+				routers: &mockRouters{
+					route: &mockRoute{rule: rule},
+				},
+			},
+			clusterManager:      &mockClusterManager{},
+			readCallbacks:       &mockReadFilterCallbacks{},
+			stats:               globalStats,
+			listenerStats:       newListenerStats("test"),
+			serverStreamConn:    &mockServerConn{},
+			routeHandlerFactory: router.DefaultMakeHandler,
+		},
+		responseSender:       &mockResponseSender{},
+		requestInfo:          &network.RequestInfo{},
+		snapshot:             &mockClusterSnapshot{},
+		downstreamReqHeaders: protocol.CommonHeader{},
+		downstreamReqDataBuf: body,
+	}
+	s.initStreamFilterChain()
+	return s, release
+}
+
+func requestUseStream(ctx context.Context) bool {
+	v, err := variable.Get(ctx, types.VarHttp2RequestUseStream)
+	if err != nil {
+		return false
+	}
+	useStream, _ := v.(bool)
+	return useStream
+}
+
+func TestStreamRequestBody(t *testing.T) {
+	for _, rule := range []api.RouteRule{
+		&streamBodyRouteRule{stream: true, ok: true},
+		// the body is streamed by default if the downstream protocol receives it as a stream
+		&streamBodyRouteRule{},
+		&mockRouteRule{},
+	} {
+		s, release := newStreamedDownstream(rule)
+		body := s.downstreamReqDataBuf
+
+		// the route is matched without waiting for the body
+		done := make(chan struct{})
+		go func() {
+			s.matchRoute()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("streamed request body should not block the route")
+		}
+		// the body is streamed to the upstream as it arrives, only the unread chunks are held
+		assert.True(t, body == s.downstreamReqDataBuf)
+		assert.True(t, requestUseStream(s.context))
+		assert.Equal(t, largeBodyChunk, s.downstreamReqDataBuf.Len())
+		close(release)
+	}
+}
+
+func TestBufferRequestBody(t *testing.T) {
+	s, release := newStreamedDownstream(&streamBodyRouteRule{stream: false, ok: true})
+
+	// the route disables stream_request_body waits for the whole body
+	done := make(chan struct{})
+	go func() {
+		s.matchRoute()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("request body should be buffered before the route is matched")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("request body is not buffered")
+	}
+	// the whole body is held in memory
+	assert.Equal(t, largeBodyChunk*largeBodyChunks, s.downstreamReqDataBuf.Len())
+	assert.False(t, requestUseStream(s.context))
+}
+
+func TestBufferRequestBodyLimit(t *testing.T) {
+	limit := uint64(2 * largeBodyChunk)
+	s, release := newStreamedDownstream(&mockBodyLimitRouteRule{maxRequestBodyBytes: limit})
+	body := s.downstreamReqDataBuf
+	close(release)
+
+	// the route limits the request body, so the body is buffered and checked while it is read
+	s.matchRoute()
+	assert.True(t, s.directResponse)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, s.requestInfo.ResponseCode())
+	assert.True(t, s.requestInfo.GetResponseFlag(api.ReqEntityTooLarge))
+	// the rest of the body is not read
+	assert.Equal(t, int(limit)+1, s.downstreamReqDataBuf.Len())
+	assert.False(t, requestUseStream(s.context))
+	_, err := body.Write([]byte("more"))
+	assert.NotNil(t, err)
+
+	// the body within the limit is proxied
+	s, release = newStreamedDownstream(&mockBodyLimitRouteRule{maxRequestBodyBytes: largeBodyChunk * largeBodyChunks})
+	close(release)
+	s.matchRoute()
+	assert.False(t, s.directResponse)
+	assert.Equal(t, largeBodyChunk*largeBodyChunks, s.downstreamReqDataBuf.Len())
+}
+
+func TestBufferRequestBodyDefaultLimit(t *testing.T) {
+	defer func(limit uint64) {
+		defaultMaxBufferedRequestBodyBytes = limit
+	}(defaultMaxBufferedRequestBodyBytes)
+	defaultMaxBufferedRequestBodyBytes = largeBodyChunk
+
+	// the body buffered for the filters is limited even if the route does not limit it
+	s, release := newStreamedDownstream(&streamBodyRouteRule{stream: true, ok: true})
+	s.streamFilterChain.AddStreamReceiverFilter(&bodyBufferingFilter{}, api.BeforeRoute)
+	close(release)
+	require.True(t, s.filterNeedRequestBody())
+	assert.False(t, s.bufferRequestBody())
+	assert.True(t, s.directResponse)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, s.requestInfo.ResponseCode())
+	assert.Equal(t, largeBodyChunk+1, s.downstreamReqDataBuf.Len())
+}
+
+func TestFilterNeedRequestBody(t *testing.T) {
+	s, release := newStreamedDownstream(&streamBodyRouteRule{stream: true, ok: true})
+	assert.False(t, s.filterNeedRequestBody())
+
+	filter := &bodyBufferingFilter{}
+	s.streamFilterChain.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	require.True(t, s.requestBodyStreamed())
+	require.True(t, s.filterNeedRequestBody())
+
+	// the filter reads the full body even if the route streams the body
+	close(release)
+	require.True(t, s.bufferRequestBody())
+	s.runReceiverFilter(api.BeforeRoute)
+	assert.Equal(t, largeBodyChunk*largeBodyChunks, filter.body)
+	assert.False(t, s.requestBodyStreamed())
+}
+
+// bodyRouters matches the request body up to the limit
+type bodyRouters struct {
+	*mockRouters
+	limit uint64
+	body  types.IoBuffer
+}
+
+func (r *bodyRouters) MaxRequestBodyMatchBytes(ctx context.Context) uint64 {
+	return r.limit
+}
+
+func (r *bodyRouters) MatchRoute(ctx context.Context, headers types.HeaderMap) types.Route {
+	if v, err := variable.Get(ctx, types.VarRouterRequestBody); err == nil && v != nil {
+		r.body, _ = v.(types.IoBuffer)
+	}
+	return r.mockRouters.MatchRoute(ctx, headers)
+}
+
+func newBodyRoutersDownstream(limit uint64) (*downStream, *bodyRouters, chan struct{}) {
+	s, release := newStreamedDownstream(&streamBodyRouteRule{})
+	routers := &bodyRouters{
+		mockRouters: s.proxy.routersWrapper.GetRouters().(*mockRouters),
+		limit:       limit,
+	}
+	s.proxy.routersWrapper = &mockRouterWrapper{routers: routers}
+	return s, routers, release
+}
+
+func TestMatchRouteRequestBody(t *testing.T) {
+	// the whole body is buffered for the body matchers
+	s, routers, release := newBodyRoutersDownstream(largeBodyChunk * largeBodyChunks)
+	close(release)
+	s.matchRoute()
+	require.NotNil(t, routers.body)
+	assert.Equal(t, largeBodyChunk*largeBodyChunks, routers.body.Len())
+	assert.False(t, requestUseStream(s.context))
+
+	// the larger body is not matched, and it is still streamed to the upstream as a whole
+	s, routers, release = newBodyRoutersDownstream(largeBodyChunk)
+	close(release)
+	s.matchRoute()
+	assert.Nil(t, routers.body)
+	assert.True(t, requestUseStream(s.context))
+	body, err := readStreamedBody(s.downstreamReqDataBuf, largeBodyChunk*largeBodyChunks)
+	require.Nil(t, err)
+	assert.Equal(t, largeBodyChunk*largeBodyChunks, body.Len())
+
+	// the buffered body larger than the limit is not matched
+	s, routers, release = newBodyRoutersDownstream(4)
+	close(release)
+	_ = variable.Set(s.context, types.VarHttp2RequestUseStream, false)
+	s.downstreamReqDataBuf = buffer.NewIoBufferString("hello")
+	s.matchRoute()
+	assert.Nil(t, routers.body)
+	assert.Equal(t, "hello", s.downstreamReqDataBuf.String())
+}
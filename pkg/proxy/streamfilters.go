@@ -18,6 +18,7 @@
 package proxy
 
 import (
+	"reflect"
 	"sync/atomic"
 
 	"mosn.io/api"
@@ -29,10 +30,17 @@ import (
 // proxy-specified implementation of interface StreamFilterChain.
 type streamFilterChain struct {
 	downStream *downStream
+	// handlers of the receiver filters, used to find the handler of the filter holds the stream
+	receiverHandlers []receiverFilterHandlerEntry
 
 	*streamfilter.DefaultStreamFilterChainImpl
 }
 
+type receiverFilterHandlerEntry struct {
+	filter  api.StreamReceiverFilter
+	handler *streamReceiverFilterHandler
+}
+
 func (sfc *streamFilterChain) init(s *downStream) {
 	sfc.downStream = s
 	sfc.DefaultStreamFilterChainImpl = streamfilter.GetDefaultStreamFilterChain()
@@ -47,9 +55,27 @@ func (sfc *streamFilterChain) AddStreamSenderFilter(filter api.StreamSenderFilte
 func (sfc *streamFilterChain) AddStreamReceiverFilter(filter api.StreamReceiverFilter, phase api.ReceiverFilterPhase) {
 	handler := newStreamReceiverFilterHandler(sfc.downStream)
 	filter.SetReceiveFilterHandler(handler)
+	sfc.receiverHandlers = append(sfc.receiverHandlers, receiverFilterHandlerEntry{
+		filter:  filter,
+		handler: handler,
+	})
 	sfc.DefaultStreamFilterChainImpl.AddStreamReceiverFilter(filter, phase)
 }
 
+// stoppedReceiverHandler returns the handler of the receiver filter returns StreamFilterStop
+func (sfc *streamFilterChain) stoppedReceiverHandler() *streamReceiverFilterHandler {
+	filter := sfc.StoppedReceiverFilter()
+	if filter == nil || !reflect.TypeOf(filter).Comparable() {
+		return nil
+	}
+	for _, entry := range sfc.receiverHandlers {
+		if reflect.TypeOf(entry.filter).Comparable() && entry.filter == filter {
+			return entry.handler
+		}
+	}
+	return nil
+}
+
 func (sfc *streamFilterChain) AddStreamAccessLog(accessLog api.AccessLog) {
 	if sfc.downStream.proxy != nil {
 		sfc.DefaultStreamFilterChainImpl.AddStreamAccessLog(accessLog)
@@ -64,6 +90,7 @@ func (sfc *streamFilterChain) destroy() {
 	// reset fields
 	streamfilter.PutStreamFilterChain(sfc.DefaultStreamFilterChainImpl)
 	sfc.downStream = nil
+	sfc.receiverHandlers = nil
 	sfc.DefaultStreamFilterChainImpl = nil
 }
 
@@ -84,11 +111,32 @@ func (f *streamFilterHandlerBase) RequestInfo() types.RequestInfo {
 	return f.activeStream.requestInfo
 }
 
+// implement streamfilter.StreamFilterDynamicMetadata.
+func (f *streamFilterHandlerBase) GetDynamicMetadata(namespace, key string) (interface{}, bool) {
+	value, ok := f.activeStream.dynamicMetadata[namespace][key]
+	return value, ok
+}
+
+func (f *streamFilterHandlerBase) SetDynamicMetadata(namespace, key string, value interface{}) {
+	s := f.activeStream
+	if s.dynamicMetadata == nil {
+		s.dynamicMetadata = make(map[string]map[string]interface{}, 4)
+	}
+	md, ok := s.dynamicMetadata[namespace]
+	if !ok {
+		md = make(map[string]interface{}, 4)
+		s.dynamicMetadata[namespace] = md
+	}
+	md[key] = value
+}
+
 // implement api.StreamReceiverFilterHandler.
 type streamReceiverFilterHandler struct {
 	streamFilterHandlerBase
 
 	id uint32
+	// the stream held by the filter is ended by the timeout or others, callbacks are dropped
+	holdEnded bool
 }
 
 func newStreamReceiverFilterHandler(activeStream *downStream) *streamReceiverFilterHandler {
@@ -118,19 +166,53 @@ func (f *streamReceiverFilterHandler) AppendTrailers(trailers types.HeaderMap) {
 }
 
 func (f *streamReceiverFilterHandler) SendHijackReply(code int, headers types.HeaderMap) {
-	f.activeStream.sendHijackReply(code, headers)
+	f.endHold(func() {
+		f.activeStream.sendHijackReply(code, headers)
+	})
 }
 
 func (f *streamReceiverFilterHandler) SendHijackReplyWithBody(code int, headers types.HeaderMap, body string) {
-	f.activeStream.sendHijackReplyWithBody(code, headers, body)
+	f.endHold(func() {
+		f.activeStream.sendHijackReplyWithBody(code, headers, body)
+	})
 }
 
 func (f *streamReceiverFilterHandler) SendDirectResponse(headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) {
-	atomic.StoreUint32(&f.activeStream.reuseBuffer, 0)
-	f.activeStream.downstreamRespHeaders = headers
-	f.activeStream.downstreamRespDataBuf = buf
-	f.activeStream.downstreamRespTrailers = trailers
-	f.activeStream.directResponse = true
+	f.endHold(func() {
+		s := f.activeStream
+		atomic.StoreUint32(&s.reuseBuffer, 0)
+		s.downstreamRespHeaders = headers
+		s.downstreamRespDataBuf = buf
+		s.downstreamRespTrailers = trailers
+		s.directResponse = true
+	})
+}
+
+// endHold ends the stream by the reply, the reply is dropped if the stream is already ended,
+// or the stream is held by another filter.
+func (f *streamReceiverFilterHandler) endHold(reply func()) {
+	s := f.activeStream
+	s.receiverFilterMux.Lock()
+	defer s.receiverFilterMux.Unlock()
+
+	if f.holdEnded || f.id != atomic.LoadUint32(&s.ID) || atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+		return
+	}
+	switch s.receiverFilterState {
+	case receiverFilterHeld:
+		if s.heldReceiverFilter != f {
+			return
+		}
+		reply()
+		s.receiverFilterState = receiverFilterResumed
+		s.sendNotify()
+	case receiverFilterResumed:
+		// the held stream is already resumed or ended
+		return
+	default:
+		// called by the filter itself while running, or by a filter outside the receiver phases
+		reply()
+	}
 }
 
 func (f *streamReceiverFilterHandler) TerminateStream(code int) bool {
@@ -163,6 +245,35 @@ func (f *streamReceiverFilterHandler) TerminateStream(code int) bool {
 	return true
 }
 
+// CanHoldReceiving returns true if the receiver filter timeout of the current phase is configured,
+// the stream is held only with the timeout.
+func (f *streamReceiverFilterHandler) CanHoldReceiving() bool {
+	return f.activeStream.receiverFilterTimeout(f.GetFilterCurrentPhase()) > 0
+}
+
+// ContinueReceiving resumes the stream held by a receiver filter which returns StreamFilterStop,
+// the following receiver filters of the phase are invoked after the stream resumed.
+func (f *streamReceiverFilterHandler) ContinueReceiving() {
+	s := f.activeStream
+	s.receiverFilterMux.Lock()
+	defer s.receiverFilterMux.Unlock()
+
+	if f.holdEnded || f.id != atomic.LoadUint32(&s.ID) || atomic.LoadUint32(&s.downstreamCleaned) == 1 {
+		return
+	}
+	switch s.receiverFilterState {
+	case receiverFilterHeld:
+		if s.heldReceiverFilter != f {
+			return
+		}
+		s.receiverFilterState = receiverFilterResumed
+		s.sendNotify()
+	case receiverFilterRunning:
+		// called before the filter returns StreamFilterStop
+		s.resumedReceiverFilter = f
+	}
+}
+
 // DEPRECATED: remove me
 func (f *streamReceiverFilterHandler) SetConvert(on bool) {
 }
@@ -207,6 +318,28 @@ func (f *streamReceiverFilterHandler) SetRequestData(data types.IoBuffer) {
 	f.activeStream.downstreamReqDataBuf.ReadFrom(data)
 }
 
+// InjectData appends the buf to the buffered request body, or replaces it if replace is true
+func (f *streamReceiverFilterHandler) InjectData(buf types.IoBuffer, replace bool) {
+	s := f.activeStream
+	if s.downstreamReqDataBuf == nil {
+		s.downstreamReqDataBuf = buffer.NewIoBuffer(0)
+	}
+	if s.downstreamReqDataBuf == buf {
+		// replace with the original data. do nothing
+		if replace {
+			return
+		}
+		buf = buf.Clone()
+	}
+	if replace {
+		s.downstreamReqDataBuf.Reset()
+	}
+	if buf != nil {
+		s.downstreamReqDataBuf.Write(buf.Bytes())
+	}
+	s.checkRequestBodyLimit()
+}
+
 func (f *streamReceiverFilterHandler) GetRequestTrailers() types.HeaderMap {
 	return f.activeStream.downstreamReqTrailers
 }
@@ -230,11 +363,25 @@ func newStreamSenderFilterHandler(activeStream *downStream) *streamSenderFilterH
 	return f
 }
 
+// GetFilterCurrentPhase get current phase for filter
+func (f *streamSenderFilterHandler) GetFilterCurrentPhase() api.SenderFilterPhase {
+	return f.activeStream.senderFilterPhase
+}
+
+// responseFlushed returns true if the response is already sent to the downstream,
+// modifies to the response are ignored.
+func (f *streamSenderFilterHandler) responseFlushed() bool {
+	return f.activeStream.senderFilterPhase == streamfilter.AfterSend
+}
+
 func (f *streamSenderFilterHandler) GetResponseHeaders() types.HeaderMap {
 	return f.activeStream.downstreamRespHeaders
 }
 
 func (f *streamSenderFilterHandler) SetResponseHeaders(headers types.HeaderMap) {
+	if f.responseFlushed() {
+		return
+	}
 	f.activeStream.downstreamRespHeaders = headers
 }
 
@@ -243,6 +390,9 @@ func (f *streamSenderFilterHandler) GetResponseData() types.IoBuffer {
 }
 
 func (f *streamSenderFilterHandler) SetResponseData(data types.IoBuffer) {
+	if f.responseFlushed() {
+		return
+	}
 	// data is the original data. do nothing
 	if f.activeStream.downstreamRespDataBuf == data {
 		return
@@ -259,5 +409,8 @@ func (f *streamSenderFilterHandler) GetResponseTrailers() types.HeaderMap {
 }
 
 func (f *streamSenderFilterHandler) SetResponseTrailers(trailers types.HeaderMap) {
+	if f.responseFlushed() {
+		return
+	}
 	f.activeStream.downstreamRespTrailers = trailers
 }
@@ -28,6 +28,7 @@ import (
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/variable"
@@ -300,6 +301,405 @@ func TestRunReiverFilterHandler(t *testing.T) {
 	}
 }
 
+func TestRunReiverFiltersTimeout(t *testing.T) {
+	testCases := []struct {
+		name     string
+		timeout  *v2.ReceiverFilterTimeout
+		resume   bool
+		wantCode int
+		wantStat int64
+	}{
+		{
+			name: "stuck filter triggers hijack",
+			timeout: &v2.ReceiverFilterTimeout{
+				BeforeRoute: api.DurationConfig{Duration: 50 * time.Millisecond},
+			},
+			wantCode: api.TimeoutExceptionCode,
+			wantStat: 1,
+		},
+		{
+			name: "resumed filter continues the stream",
+			timeout: &v2.ReceiverFilterTimeout{
+				BeforeRoute: api.DurationConfig{Duration: time.Second},
+			},
+			resume:   true,
+			wantCode: api.RouterUnavailableCode,
+		},
+		{
+			name: "timeout in other phase",
+			timeout: &v2.ReceiverFilterTimeout{
+				AfterRoute: api.DurationConfig{Duration: 50 * time.Millisecond},
+			},
+			wantCode: api.RouterUnavailableCode,
+		},
+		{
+			name:     "timeout disabled",
+			wantCode: api.RouterUnavailableCode,
+		},
+	}
+	for _, tc := range testCases {
+		sender := &mockResponseSender{}
+		s := &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			proxy: &proxy{
+				config: &v2.Proxy{
+					ReceiverFilterTimeout: tc.timeout,
+				},
+				routersWrapper:      &mockRouterWrapper{},
+				clusterManager:      &mockClusterManager{},
+				readCallbacks:       &mockReadFilterCallbacks{},
+				stats:               globalStats,
+				listenerStats:       newListenerStats("test_filter_timeout"),
+				serverStreamConn:    &mockServerConn{},
+				routeHandlerFactory: router.DefaultMakeHandler,
+			},
+			responseSender: sender,
+			requestInfo:    &network.RequestInfo{},
+			notify:         make(chan struct{}, 1),
+		}
+		s.initStreamFilterChain()
+		f := &mockStuckReceiverFilter{
+			resume: tc.resume,
+		}
+		s.streamFilterChain.AddStreamReceiverFilter(f, api.BeforeRoute)
+
+		statBefore := s.proxy.listenerStats.DownstreamFilterTimeout.Count()
+		s.OnReceive(s.context, protocol.CommonHeader{}, buffer.NewIoBuffer(0), nil)
+
+		time.Sleep(200 * time.Millisecond)
+
+		if sender.headers == nil {
+			t.Fatalf("%s: want a response but got nothing", tc.name)
+		}
+		if code := s.requestInfo.ResponseCode(); code != tc.wantCode {
+			t.Errorf("%s: want response code %d, but got %d", tc.name, tc.wantCode, code)
+		}
+		if stat := s.proxy.listenerStats.DownstreamFilterTimeout.Count() - statBefore; stat != tc.wantStat {
+			t.Errorf("%s: want filter timeout stat %d, but got %d", tc.name, tc.wantStat, stat)
+		}
+	}
+}
+
+func TestRunReiverFiltersHold(t *testing.T) {
+	testCases := []struct {
+		name string
+		// the action of the stopped filter
+		action func(handler api.StreamReceiverFilterHandler)
+		// the action is called after the filter returns StreamFilterStop
+		async     bool
+		wantCode  int
+		wantStat  int64
+		wantAfter int
+	}{
+		{
+			name: "resume asynchronously runs the following filters",
+			action: func(handler api.StreamReceiverFilterHandler) {
+				handler.(streamfilter.StreamReceiverFilterContinuer).ContinueReceiving()
+			},
+			async:     true,
+			wantCode:  api.RouterUnavailableCode,
+			wantAfter: 1,
+		},
+		{
+			name: "resume before stop runs the following filters",
+			action: func(handler api.StreamReceiverFilterHandler) {
+				handler.(streamfilter.StreamReceiverFilterContinuer).ContinueReceiving()
+			},
+			wantCode:  api.RouterUnavailableCode,
+			wantAfter: 1,
+		},
+		{
+			name: "hijack asynchronously is not overwritten by the timeout",
+			action: func(handler api.StreamReceiverFilterHandler) {
+				handler.SendHijackReply(403, nil)
+			},
+			async:    true,
+			wantCode: 403,
+		},
+		{
+			name: "callback after the timeout is dropped",
+			action: func(handler api.StreamReceiverFilterHandler) {
+				time.Sleep(100 * time.Millisecond)
+				handler.SendHijackReply(403, nil)
+				handler.(streamfilter.StreamReceiverFilterContinuer).ContinueReceiving()
+			},
+			async:    true,
+			wantCode: api.TimeoutExceptionCode,
+			wantStat: 1,
+		},
+	}
+	for _, tc := range testCases {
+		sender := &mockResponseSender{}
+		s := &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			proxy: &proxy{
+				config: &v2.Proxy{
+					ReceiverFilterTimeout: &v2.ReceiverFilterTimeout{
+						BeforeRoute: api.DurationConfig{Duration: 50 * time.Millisecond},
+					},
+				},
+				routersWrapper:      &mockRouterWrapper{},
+				clusterManager:      &mockClusterManager{},
+				readCallbacks:       &mockReadFilterCallbacks{},
+				stats:               globalStats,
+				listenerStats:       newListenerStats("test_filter_hold"),
+				serverStreamConn:    &mockServerConn{},
+				routeHandlerFactory: router.DefaultMakeHandler,
+			},
+			responseSender: sender,
+			requestInfo:    &network.RequestInfo{},
+			notify:         make(chan struct{}, 1),
+		}
+		s.initStreamFilterChain()
+		f := &mockHoldReceiverFilter{
+			action: tc.action,
+			async:  tc.async,
+		}
+		after := &mockRetryReceiverFilter{
+			status: api.StreamFilterContinue,
+		}
+		s.streamFilterChain.AddStreamReceiverFilter(f, api.BeforeRoute)
+		s.streamFilterChain.AddStreamReceiverFilter(after, api.BeforeRoute)
+
+		statBefore := s.proxy.listenerStats.DownstreamFilterTimeout.Count()
+		s.OnReceive(s.context, protocol.CommonHeader{}, buffer.NewIoBuffer(0), nil)
+
+		time.Sleep(200 * time.Millisecond)
+
+		if sender.headers == nil {
+			t.Fatalf("%s: want a response but got nothing", tc.name)
+		}
+		if code := s.requestInfo.ResponseCode(); code != tc.wantCode {
+			t.Errorf("%s: want response code %d, but got %d", tc.name, tc.wantCode, code)
+		}
+		if stat := s.proxy.listenerStats.DownstreamFilterTimeout.Count() - statBefore; stat != tc.wantStat {
+			t.Errorf("%s: want filter timeout stat %d, but got %d", tc.name, tc.wantStat, stat)
+		}
+		if after.on != tc.wantAfter {
+			t.Errorf("%s: want the following filter called %d times, but got %d", tc.name, tc.wantAfter, after.on)
+		}
+	}
+}
+
+func TestReceiverFilterHandlerCanHold(t *testing.T) {
+	timeout := &v2.ReceiverFilterTimeout{
+		AfterRoute: api.DurationConfig{Duration: time.Second},
+	}
+	testCases := []struct {
+		timeout *v2.ReceiverFilterTimeout
+		phase   types.Phase
+		want    bool
+	}{
+		{timeout: nil, phase: types.DownFilterAfterRoute, want: false},
+		{timeout: timeout, phase: types.DownFilter, want: false},
+		{timeout: timeout, phase: types.DownFilterAfterRoute, want: true},
+		{timeout: timeout, phase: types.DownFilterAfterChooseHost, want: false},
+	}
+	for i, tc := range testCases {
+		s := &downStream{
+			proxy: &proxy{
+				config: &v2.Proxy{
+					ReceiverFilterTimeout: tc.timeout,
+				},
+			},
+			phase: tc.phase,
+		}
+		handler := newStreamReceiverFilterHandler(s)
+		if got := handler.CanHoldReceiving(); got != tc.want {
+			t.Errorf("#%d want can hold %v, but got %v", i, tc.want, got)
+		}
+	}
+}
+
+func TestRunReiverFiltersRetryBudget(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  api.StreamFilterStatus
+		phase   api.ReceiverFilterPhase
+		again   types.Phase
+		config  *v2.Proxy
+		wantRun int
+	}{
+		{
+			name:    "rechoose host default budget",
+			status:  api.StreamFilterReChooseHost,
+			phase:   api.AfterChooseHost,
+			again:   types.ChooseHost,
+			config:  &v2.Proxy{},
+			wantRun: defaultMaxReChooseHost + 1,
+		},
+		{
+			name:    "rechoose host configured budget",
+			status:  api.StreamFilterReChooseHost,
+			phase:   api.AfterChooseHost,
+			again:   types.ChooseHost,
+			config:  &v2.Proxy{MaxReChooseHost: 5},
+			wantRun: 6,
+		},
+		{
+			name:    "rematch route default budget",
+			status:  api.StreamFilterReMatchRoute,
+			phase:   api.AfterRoute,
+			again:   types.MatchRoute,
+			config:  &v2.Proxy{},
+			wantRun: defaultMaxReMatchRoute + 1,
+		},
+		{
+			name:    "rematch route configured budget",
+			status:  api.StreamFilterReMatchRoute,
+			phase:   api.AfterRoute,
+			again:   types.MatchRoute,
+			config:  &v2.Proxy{MaxReMatchRoute: 1},
+			wantRun: 2,
+		},
+		{
+			name:    "rechoose host in unexpected phase",
+			status:  api.StreamFilterReChooseHost,
+			phase:   api.BeforeRoute,
+			again:   types.ChooseHost,
+			config:  &v2.Proxy{},
+			wantRun: 1,
+		},
+	}
+	for _, tc := range testCases {
+		s := &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			proxy: &proxy{
+				config:        tc.config,
+				stats:         globalStats,
+				listenerStats: newListenerStats("test_retry_budget"),
+			},
+		}
+		s.initStreamFilterChain()
+		retry := &mockRetryReceiverFilter{
+			status: tc.status,
+		}
+		next := &mockStreamReceiverFilter{
+			status: api.StreamFilterContinue,
+			s:      s,
+		}
+		s.streamFilterChain.AddStreamReceiverFilter(retry, tc.phase)
+		s.streamFilterChain.AddStreamReceiverFilter(next, tc.phase)
+
+		exceeded := s.proxy.listenerStats.ReChooseHostExceeded
+		if tc.status == api.StreamFilterReMatchRoute {
+			exceeded = s.proxy.listenerStats.ReMatchRouteExceeded
+		}
+		// mock the receive loop, runs the phase again until the filter gives up
+		for i := 0; i < 10; i++ {
+			s.runReceiverFilter(tc.phase)
+			if s.receiverFiltersAgainPhase != tc.again {
+				break
+			}
+			s.receiverFiltersAgainPhase = types.InitPhase
+		}
+
+		if retry.on != tc.wantRun {
+			t.Errorf("%s: want filter runs %d times, but got %d", tc.name, tc.wantRun, retry.on)
+		}
+		if next.on != 1 {
+			t.Errorf("%s: want the following filter runs once, but got %d", tc.name, next.on)
+		}
+		if s.receiverFiltersAgainPhase != types.InitPhase {
+			t.Errorf("%s: unexpected again phase %v", tc.name, s.receiverFiltersAgainPhase)
+		}
+		wantStat := int64(1)
+		if tc.phase == api.BeforeRoute {
+			wantStat = 0
+		}
+		if exceeded.Count() != wantStat {
+			t.Errorf("%s: want exceeded stat %d, but got %d", tc.name, wantStat, exceeded.Count())
+		}
+	}
+}
+
+func TestRunReiverFiltersInjectData(t *testing.T) {
+	testCases := []struct {
+		name    string
+		phase   api.ReceiverFilterPhase
+		body    string
+		inject  string
+		replace bool
+		self    bool
+		wantLen int
+	}{
+		{
+			name:    "append before route",
+			phase:   api.BeforeRoute,
+			body:    "12345",
+			inject:  "abc",
+			wantLen: 8,
+		},
+		{
+			name:    "append after route",
+			phase:   api.AfterRoute,
+			body:    "12345",
+			inject:  "abc",
+			wantLen: 8,
+		},
+		{
+			name:    "replace after route",
+			phase:   api.AfterRoute,
+			body:    "12345",
+			inject:  "ab",
+			replace: true,
+			wantLen: 2,
+		},
+		{
+			name:    "append to empty body before route",
+			phase:   api.BeforeRoute,
+			inject:  "abc",
+			wantLen: 3,
+		},
+		{
+			name:    "append the body itself",
+			phase:   api.AfterRoute,
+			body:    "12345",
+			self:    true,
+			wantLen: 10,
+		},
+	}
+	for _, tc := range testCases {
+		s := &downStream{
+			context: variable.NewVariableContext(context.Background()),
+			proxy: &proxy{
+				config:        &v2.Proxy{},
+				stats:         globalStats,
+				listenerStats: newListenerStats("test_inject_data"),
+			},
+			requestInfo: &network.RequestInfo{},
+		}
+		if tc.body != "" {
+			s.downstreamReqDataBuf = buffer.NewIoBufferString(tc.body)
+		}
+		s.initStreamFilterChain()
+		injector := &mockInjectReceiverFilter{
+			inject:  tc.inject,
+			replace: tc.replace,
+			self:    tc.self,
+		}
+		observer := &mockInjectReceiverFilter{}
+		s.streamFilterChain.AddStreamReceiverFilter(injector, tc.phase)
+		s.streamFilterChain.AddStreamReceiverFilter(observer, tc.phase)
+
+		s.runReceiverFilter(tc.phase)
+		if observer.seen != tc.wantLen {
+			t.Errorf("%s: want the following filter sees %d bytes, but got %d", tc.name, tc.wantLen, observer.seen)
+		}
+
+		// send the request body to upstream
+		sender := &mockResponseSender{}
+		s.upstreamRequest = &upstreamRequest{
+			downStream:    s,
+			requestSender: sender,
+		}
+		s.upstreamRequest.appendData(true)
+		if sender.data == nil || sender.data.Len() != tc.wantLen {
+			t.Errorf("%s: want upstream request body length %d, but got %v", tc.name, tc.wantLen, sender.data)
+		}
+	}
+}
+
 // StreamSenderFilter
 // MOSN receive the upstream response, run StreamSenderFilters, and send repsonse to downstream
 
@@ -359,6 +759,69 @@ func TestRunSenderFilters(t *testing.T) {
 	}
 }
 
+func TestRunSenderFiltersAfterSend(t *testing.T) {
+	sender := &mockResponseSender{}
+	s := &downStream{
+		context: variable.NewVariableContext(context.Background()),
+		proxy: &proxy{
+			config:              &v2.Proxy{},
+			routersWrapper:      &mockRouterWrapper{},
+			clusterManager:      &mockClusterManager{},
+			readCallbacks:       &mockReadFilterCallbacks{},
+			stats:               globalStats,
+			listenerStats:       newListenerStats("test_after_send"),
+			serverStreamConn:    &mockServerConn{},
+			routeHandlerFactory: router.DefaultMakeHandler,
+		},
+		responseSender: sender,
+		requestInfo:    &network.RequestInfo{},
+		notify:         make(chan struct{}, 1),
+	}
+	s.initStreamFilterChain()
+	beforeSend := &mockStreamSenderFilter{
+		status: api.StreamFilterContinue,
+		s:      s,
+	}
+	afterSend := &mockStreamSenderFilter{
+		status: api.StreamFilterContinue,
+		s:      s,
+	}
+	// registers in reverse order, the phase decides the order
+	s.streamFilterChain.AddStreamSenderFilter(afterSend, streamfilter.AfterSend)
+	s.streamFilterChain.AddStreamSenderFilter(beforeSend, api.BeforeSend)
+
+	// no route matched, the hijack response is sent
+	s.OnReceive(s.context, protocol.CommonHeader{}, buffer.NewIoBuffer(0), nil)
+	time.Sleep(200 * time.Millisecond)
+
+	if sender.headers == nil {
+		t.Fatal("want a response but got nothing")
+	}
+	if len(beforeSend.phases) != 1 || beforeSend.phases[0] != api.BeforeSend {
+		t.Errorf("unexpected BeforeSend filter phases: %v", beforeSend.phases)
+	}
+	if len(afterSend.phases) != 1 || afterSend.phases[0] != streamfilter.AfterSend {
+		t.Errorf("unexpected AfterSend filter phases: %v", afterSend.phases)
+	}
+
+	// response modified in AfterSend phase is ignored
+	headers := protocol.CommonHeader{"key": "value"}
+	ds := &downStream{
+		downstreamRespHeaders: headers,
+		senderFilterPhase:     streamfilter.AfterSend,
+	}
+	handler := newStreamSenderFilterHandler(ds)
+	handler.SetResponseHeaders(protocol.CommonHeader{})
+	handler.SetResponseTrailers(protocol.CommonHeader{})
+	handler.SetResponseData(buffer.NewIoBufferString("body"))
+	if v, _ := ds.downstreamRespHeaders.Get("key"); v != "value" {
+		t.Error("response headers should not be modified after send")
+	}
+	if ds.downstreamRespTrailers != nil || ds.downstreamRespDataBuf != nil {
+		t.Error("response should not be modified after send")
+	}
+}
+
 func TestRunSenderFiltersStop(t *testing.T) {
 	tc := struct {
 		filters []*mockStreamSenderFilter
@@ -491,6 +954,8 @@ type mockStreamSenderFilter struct {
 	handler api.StreamSenderFilterHandler
 	// api called count
 	on int
+	// called phases
+	phases []api.SenderFilterPhase
 	// returns status
 	status api.StreamFilterStatus
 	// mock for test
@@ -501,6 +966,9 @@ func (f *mockStreamSenderFilter) OnDestroy() {}
 
 func (f *mockStreamSenderFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
 	f.on++
+	if getter, ok := f.handler.(streamfilter.StreamSenderFilterPhaseGetter); ok {
+		f.phases = append(f.phases, getter.GetFilterCurrentPhase())
+	}
 	f.handler.SetResponseHeaders(protocol.CommonHeader{})
 	f.handler.SetResponseData(buffer.NewIoBuffer(1))
 	if f.status == api.StreamFilterStop || f.status == api.StreamFiltertermination {
@@ -512,3 +980,212 @@ func (f *mockStreamSenderFilter) Append(ctx context.Context, headers types.Heade
 func (f *mockStreamSenderFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
 	f.handler = handler
 }
+
+// mockStuckReceiverFilter returns StreamFilterStop and never ends the stream,
+// like a filter waiting for a hung dependency.
+type mockStuckReceiverFilter struct {
+	handler api.StreamReceiverFilterHandler
+	// resume the stream asynchronously
+	resume bool
+}
+
+func (f *mockStuckReceiverFilter) OnDestroy() {}
+
+func (f *mockStuckReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	if f.resume {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			f.handler.(streamfilter.StreamReceiverFilterContinuer).ContinueReceiving()
+		}()
+	}
+	return api.StreamFilterStop
+}
+
+func (f *mockStuckReceiverFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+// mockHoldReceiverFilter returns StreamFilterStop, and resumes or ends the stream by the action
+type mockHoldReceiverFilter struct {
+	handler api.StreamReceiverFilterHandler
+	action  func(handler api.StreamReceiverFilterHandler)
+	// calls the action asynchronously
+	async bool
+}
+
+func (f *mockHoldReceiverFilter) OnDestroy() {}
+
+func (f *mockHoldReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	if f.async {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			f.action(f.handler)
+		}()
+	} else {
+		f.action(f.handler)
+	}
+	return api.StreamFilterStop
+}
+
+func (f *mockHoldReceiverFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+// mockRetryReceiverFilter always asks the stream to redo the route match or the host choose
+type mockRetryReceiverFilter struct {
+	handler api.StreamReceiverFilterHandler
+	// api called count
+	on int
+	// returns status
+	status api.StreamFilterStatus
+}
+
+func (f *mockRetryReceiverFilter) OnDestroy() {}
+
+func (f *mockRetryReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	f.on++
+	return f.status
+}
+
+func (f *mockRetryReceiverFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+// mockInjectReceiverFilter injects data into the request body, and records the body length it sees
+type mockInjectReceiverFilter struct {
+	handler api.StreamReceiverFilterHandler
+	// injects data
+	inject  string
+	replace bool
+	// injects the request body itself
+	self bool
+	// the body length seen
+	seen int
+}
+
+func (f *mockInjectReceiverFilter) OnDestroy() {}
+
+func (f *mockInjectReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	if data := f.handler.GetRequestData(); data != nil {
+		f.seen = data.Len()
+	}
+	injector := f.handler.(streamfilter.StreamReceiverFilterDataInjector)
+	if f.self {
+		injector.InjectData(f.handler.GetRequestData(), f.replace)
+	} else if f.inject != "" {
+		injector.InjectData(buffer.NewIoBufferString(f.inject), f.replace)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *mockInjectReceiverFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func TestStreamFiltersDynamicMetadata(t *testing.T) {
+	sender := &mockResponseSender{}
+	s := &downStream{
+		context: variable.NewVariableContext(context.Background()),
+		proxy: &proxy{
+			config:              &v2.Proxy{},
+			routersWrapper:      &mockRouterWrapper{},
+			clusterManager:      &mockClusterManager{},
+			readCallbacks:       &mockReadFilterCallbacks{},
+			stats:               globalStats,
+			listenerStats:       newListenerStats("test_dynamic_metadata"),
+			serverStreamConn:    &mockServerConn{},
+			routeHandlerFactory: router.DefaultMakeHandler,
+		},
+		responseSender: sender,
+		requestInfo:    &network.RequestInfo{},
+		notify:         make(chan struct{}, 1),
+	}
+	s.initStreamFilterChain()
+	claims := map[string]string{"sub": "mosn"}
+	writer := &mockMetadataReceiverFilter{
+		namespace: "auth",
+		key:       "claims",
+		value:     claims,
+	}
+	reader := &mockMetadataReceiverFilter{
+		namespace: "auth",
+		key:       "claims",
+	}
+	senderReader := &mockMetadataSenderFilter{
+		namespace: "auth",
+		key:       "claims",
+	}
+	s.streamFilterChain.AddStreamReceiverFilter(writer, api.BeforeRoute)
+	s.streamFilterChain.AddStreamReceiverFilter(reader, api.BeforeRoute)
+	s.streamFilterChain.AddStreamSenderFilter(senderReader, api.BeforeSend)
+
+	// no route matched, the hijack response is sent
+	s.OnReceive(s.context, protocol.CommonHeader{}, buffer.NewIoBuffer(0), nil)
+	time.Sleep(200 * time.Millisecond)
+
+	if sender.headers == nil {
+		t.Fatal("want a response but got nothing")
+	}
+	if v, ok := reader.got.(map[string]string); !ok || v["sub"] != "mosn" {
+		t.Errorf("receiver filter gets unexpected metadata: %v", reader.got)
+	}
+	if v, ok := senderReader.got.(map[string]string); !ok || v["sub"] != "mosn" {
+		t.Errorf("sender filter gets unexpected metadata: %v", senderReader.got)
+	}
+	if writer.got != nil {
+		t.Errorf("metadata should be got after set, but got: %v", writer.got)
+	}
+	// other namespace is not visible
+	handler := newStreamReceiverFilterHandler(&downStream{})
+	handler.SetDynamicMetadata("auth", "claims", claims)
+	if _, ok := handler.GetDynamicMetadata("other", "claims"); ok {
+		t.Error("metadata should be namespaced")
+	}
+	// cleared when the stream is cleaned
+	if s.dynamicMetadata != nil {
+		t.Errorf("metadata should be cleared, but got: %v", s.dynamicMetadata)
+	}
+}
+
+// mockMetadataReceiverFilter gets the metadata, and sets it if value is not nil
+type mockMetadataReceiverFilter struct {
+	handler   api.StreamReceiverFilterHandler
+	namespace string
+	key       string
+	value     interface{}
+	got       interface{}
+}
+
+func (f *mockMetadataReceiverFilter) OnDestroy() {}
+
+func (f *mockMetadataReceiverFilter) OnReceive(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	md := f.handler.(streamfilter.StreamFilterDynamicMetadata)
+	f.got, _ = md.GetDynamicMetadata(f.namespace, f.key)
+	if f.value != nil {
+		md.SetDynamicMetadata(f.namespace, f.key, f.value)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *mockMetadataReceiverFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+// mockMetadataSenderFilter gets the metadata in the sender phase
+type mockMetadataSenderFilter struct {
+	handler   api.StreamSenderFilterHandler
+	namespace string
+	key       string
+	got       interface{}
+}
+
+func (f *mockMetadataSenderFilter) OnDestroy() {}
+
+func (f *mockMetadataSenderFilter) Append(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) api.StreamFilterStatus {
+	f.got, _ = f.handler.(streamfilter.StreamFilterDynamicMetadata).GetDynamicMetadata(f.namespace, f.key)
+	return api.StreamFilterContinue
+}
+
+func (f *mockMetadataSenderFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.handler = handler
+}
@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// tunnel transfers the raw bytes between the downstream and upstream connections
+// after the protocol upgrade is accepted by the upstream, such as the websocket handshake,
+// or the http CONNECT request is accepted.
+type tunnel struct {
+	downstream  api.Connection
+	upstream    api.Connection
+	idleTimeout time.Duration
+	// lastActive is the unix nano of the last bytes transferred
+	lastActive int64
+	closed     uint32
+	// bytesReceived is the bytes received from the downstream, bytesSent is the bytes sent to the downstream
+	bytesReceived uint64
+	bytesSent     uint64
+	// onClose is called once the tunnel is closed
+	onClose func(t *tunnel)
+}
+
+func newTunnel(downstream, upstream api.Connection, idleTimeout time.Duration, onClose func(t *tunnel)) *tunnel {
+	t := &tunnel{
+		downstream:  downstream,
+		upstream:    upstream,
+		idleTimeout: idleTimeout,
+		lastActive:  time.Now().UnixNano(),
+		onClose:     onClose,
+	}
+	if idleTimeout > 0 {
+		utils.NewTimer(idleTimeout, t.onIdleCheck)
+	}
+	return t
+}
+
+// onIdleCheck closes the tunnel if no bytes are transferred in the idle timeout,
+// otherwise checks again when the idle timeout is reached.
+func (t *tunnel) onIdleCheck() {
+	if atomic.LoadUint32(&t.closed) == 1 {
+		return
+	}
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&t.lastActive)))
+	if idle >= t.idleTimeout {
+		log.DefaultLogger.Infof("[proxy] [tunnel] tunnel is idle for %v, close it. downstream: %d, upstream: %d",
+			idle, t.downstream.ID(), t.upstream.ID())
+		t.close()
+		return
+	}
+	utils.NewTimer(t.idleTimeout-idle, t.onIdleCheck)
+}
+
+func (t *tunnel) close() {
+	if !atomic.CompareAndSwapUint32(&t.closed, 0, 1) {
+		return
+	}
+	t.downstream.Close(api.FlushWrite, api.LocalClose)
+	t.upstream.Close(api.FlushWrite, api.LocalClose)
+	if t.onClose != nil {
+		t.onClose(t)
+	}
+}
+
+// tunnelReceiver writes the bytes received by one side of the tunnel to the other side
+type tunnelReceiver struct {
+	t    *tunnel
+	peer api.Connection
+	// fromDownstream is true if the bytes are received from the downstream
+	fromDownstream bool
+}
+
+func (r *tunnelReceiver) OnTunnelData(data buffer.IoBuffer) {
+	atomic.StoreInt64(&r.t.lastActive, time.Now().UnixNano())
+	if r.fromDownstream {
+		atomic.AddUint64(&r.t.bytesReceived, uint64(data.Len()))
+	} else {
+		atomic.AddUint64(&r.t.bytesSent, uint64(data.Len()))
+	}
+	if err := r.peer.Write(data); err != nil {
+		log.DefaultLogger.Errorf("[proxy] [tunnel] write to connection %d failed: %v", r.peer.ID(), err)
+		r.t.close()
+	}
+}
+
+// OnTunnelClose closes the tunnel if either side is closed
+func (r *tunnelReceiver) OnTunnelClose() {
+	r.t.close()
+}
+
+// tunnelIdleTimeout returns the idle timeout of the tunnel
+func (s *downStream) tunnelIdleTimeout() time.Duration {
+	if s.proxy != nil && s.proxy.config != nil && s.proxy.config.TunnelIdleTimeout != nil {
+		return s.proxy.config.TunnelIdleTimeout.Duration
+	}
+	return types.DefaultIdleTimeout
+}
+
+// upstreamTunnel returns the upstream side of the tunnel, which is the upstream connection
+// of the CONNECT request, or the upstream stream accepts the protocol upgrade.
+func (s *downStream) upstreamTunnel() types.TunnelStream {
+	if s.connectUpstream != nil {
+		return s.connectUpstream
+	}
+	if s.upstreamRequest == nil || s.requestInfo.ResponseCode() != http.StatusSwitchingProtocols {
+		return nil
+	}
+	up, _ := s.upstreamRequest.requestSender.(types.TunnelStream)
+	return up
+}
+
+// startTunnel switches the downstream and upstream connections to a tunnel after the
+// 101 Switching Protocols response or the CONNECT response is sent to the downstream.
+// returns true if the tunnel is started, the stream ends when the tunnel is closed.
+func (s *downStream) startTunnel() bool {
+	up := s.upstreamTunnel()
+	if up == nil {
+		return false
+	}
+	var downConn api.Connection
+	var downUpgraded bool
+	down, ok := s.responseSender.(types.TunnelStream)
+	if ok {
+		downConn, downUpgraded = down.UpgradedConnection()
+	}
+	upConn, upUpgraded := up.UpgradedConnection()
+	if !downUpgraded || !upUpgraded {
+		// the upgraded connection can not be used as a tunnel alone
+		if downUpgraded {
+			log.Proxy.Errorf(s.context, "[proxy] [downstream] the upstream does not accept the protocol upgrade, close the downstream connection")
+			downConn.Close(api.FlushWrite, api.LocalClose)
+		}
+		if upUpgraded {
+			log.Proxy.Errorf(s.context, "[proxy] [downstream] the downstream does not accept the protocol upgrade, close the upstream connection")
+			upConn.Close(api.NoFlush, api.LocalClose)
+		}
+		return false
+	}
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.context, "[proxy] [downstream] start tunnel, downstream: %d, upstream: %d", downConn.ID(), upConn.ID())
+	}
+	// the stream is in use until the tunnel is closed
+	atomic.StoreUint32(&s.reuseBuffer, 0)
+	t := newTunnel(downConn, upConn, s.tunnelIdleTimeout(), s.onTunnelClose)
+	down.Tunnel(&tunnelReceiver{t: t, peer: upConn, fromDownstream: true})
+	up.Tunnel(&tunnelReceiver{t: t, peer: downConn})
+	return true
+}
+
+// onTunnelClose records the bytes transferred by the tunnel, and ends the stream
+func (s *downStream) onTunnelClose(t *tunnel) {
+	s.requestInfo.SetBytesReceived(s.requestInfo.BytesReceived() + atomic.LoadUint64(&t.bytesReceived))
+	s.requestInfo.SetBytesSent(s.requestInfo.BytesSent() + atomic.LoadUint64(&t.bytesSent))
+	s.endStream()
+}
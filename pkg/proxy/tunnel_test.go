@@ -0,0 +1,171 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+// mockTunnelStream is a stream sender which can be upgraded to a tunnel
+type mockTunnelStream struct {
+	mockResponseSender
+	conn     api.Connection
+	upgraded bool
+	receiver types.TunnelReceiver
+}
+
+func (s *mockTunnelStream) UpgradedConnection() (api.Connection, bool) {
+	return s.conn, s.upgraded
+}
+
+func (s *mockTunnelStream) Tunnel(receiver types.TunnelReceiver) {
+	s.receiver = receiver
+}
+
+func newMockTunnelConnection(ctrl *gomock.Controller, id uint64, closed *int32) *mock.MockConnection {
+	conn := mock.NewMockConnection(ctrl)
+	conn.EXPECT().ID().Return(id).AnyTimes()
+	conn.EXPECT().Close(gomock.Any(), gomock.Any()).DoAndReturn(func(api.ConnectionCloseType, api.ConnectionEvent) error {
+		atomic.AddInt32(closed, 1)
+		return nil
+	}).AnyTimes()
+	return conn
+}
+
+func newTunnelDownstream(down, up *mockTunnelStream, code int, idleTimeout *api.DurationConfig) *downStream {
+	info := &network.RequestInfo{}
+	info.SetResponseCode(code)
+	s := &downStream{
+		context: context.Background(),
+		proxy: &proxy{
+			config: &v2.Proxy{
+				TunnelIdleTimeout: idleTimeout,
+			},
+		},
+		requestInfo:    info,
+		responseSender: down,
+		// the stream end is not tested
+		downstreamCleaned: 1,
+	}
+	s.upstreamRequest = &upstreamRequest{
+		downStream:    s,
+		requestSender: up,
+	}
+	return s
+}
+
+func TestStartTunnel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var downClosed, upClosed int32
+	downConn := newMockTunnelConnection(ctrl, 1, &downClosed)
+	upConn := newMockTunnelConnection(ctrl, 2, &upClosed)
+	var toUpstream, toDownstream string
+	upConn.EXPECT().Write(gomock.Any()).DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+		toUpstream += bufs[0].String()
+		return nil
+	}).AnyTimes()
+	downConn.EXPECT().Write(gomock.Any()).DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+		toDownstream += bufs[0].String()
+		return nil
+	}).AnyTimes()
+
+	down := &mockTunnelStream{conn: downConn, upgraded: true}
+	up := &mockTunnelStream{conn: upConn, upgraded: true}
+	s := newTunnelDownstream(down, up, http.StatusSwitchingProtocols, &api.DurationConfig{})
+	assert.True(t, s.startTunnel())
+	if !assert.NotNil(t, down.receiver) || !assert.NotNil(t, up.receiver) {
+		return
+	}
+
+	down.receiver.OnTunnelData(buffer.NewIoBufferString("from downstream"))
+	up.receiver.OnTunnelData(buffer.NewIoBufferString("from upstream"))
+	assert.Equal(t, "from downstream", toUpstream)
+	assert.Equal(t, "from upstream", toDownstream)
+
+	// the tunnel is closed if either side is closed
+	up.receiver.OnTunnelClose()
+	down.receiver.OnTunnelClose()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downClosed))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&upClosed))
+	// the bytes transferred are recorded when the tunnel is closed
+	assert.Equal(t, uint64(len("from downstream")), s.requestInfo.BytesReceived())
+	assert.Equal(t, uint64(len("from upstream")), s.requestInfo.BytesSent())
+}
+
+func TestStartTunnelNotUpgraded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// not a upgrade response
+	var downClosed, upClosed int32
+	down := &mockTunnelStream{conn: newMockTunnelConnection(ctrl, 1, &downClosed)}
+	up := &mockTunnelStream{conn: newMockTunnelConnection(ctrl, 2, &upClosed)}
+	assert.False(t, newTunnelDownstream(down, up, http.StatusOK, nil).startTunnel())
+	assert.Nil(t, down.receiver)
+	assert.Nil(t, up.receiver)
+
+	// the upgraded downstream connection can not be used alone
+	down.upgraded = true
+	assert.False(t, newTunnelDownstream(down, up, http.StatusSwitchingProtocols, nil).startTunnel())
+	assert.Nil(t, down.receiver)
+	assert.Nil(t, up.receiver)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downClosed))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&upClosed))
+}
+
+func TestTunnelIdleTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var downClosed, upClosed int32
+	downConn := newMockTunnelConnection(ctrl, 1, &downClosed)
+	upConn := newMockTunnelConnection(ctrl, 2, &upClosed)
+	upConn.EXPECT().Write(gomock.Any()).Return(nil).AnyTimes()
+
+	var onClose int32
+	tn := newTunnel(downConn, upConn, 200*time.Millisecond, func(*tunnel) {
+		atomic.AddInt32(&onClose, 1)
+	})
+	receiver := &tunnelReceiver{t: tn, peer: upConn, fromDownstream: true}
+	// the active tunnel is not closed
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		receiver.OnTunnelData(buffer.NewIoBufferString("keep alive"))
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&downClosed))
+
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downClosed))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&upClosed))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&onClose))
+}
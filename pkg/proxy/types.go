@@ -48,6 +48,26 @@ type Timeout struct {
 	TryTimeout    time.Duration
 }
 
+// default max times of a receiver filter can redo the route match or the host choose
+const (
+	defaultMaxReMatchRoute = 3
+	defaultMaxReChooseHost = 3
+)
+
+// receiverFilterState is the state of the receiver filters holding the stream
+type receiverFilterState int
+
+const (
+	// no receiver filter is running
+	receiverFilterIdle receiverFilterState = iota
+	// the receiver filters of a phase are running
+	receiverFilterRunning
+	// a receiver filter returns StreamFilterStop and holds the stream
+	receiverFilterHeld
+	// the held stream is resumed or ended by the filter
+	receiverFilterResumed
+)
+
 // UpstreamFailureReason
 type UpstreamFailureReason string
 
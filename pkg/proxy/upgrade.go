@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	mosnhttp2 "mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+)
+
+// hop-by-hop headers of http1 are not allowed in http2, see RFC 7540 section 8.1.2.2.
+// 'Expect: 100-continue' is already answered by the http1 server stream, the body is received completely.
+var http1HopHeaders = map[string]struct{}{
+	"connection":        {},
+	"keep-alive":        {},
+	"proxy-connection":  {},
+	"transfer-encoding": {},
+	"upgrade":           {},
+	"expect":            {},
+}
+
+// clusterHTTP2Upgrade returns true if the cluster forces the http1 requests to be sent as h2c
+func (s *downStream) clusterHTTP2Upgrade() bool {
+	if s.snapshot == nil {
+		return false
+	}
+	if c, ok := s.snapshot.ClusterInfo().(types.HTTP2UpgradeCluster); ok {
+		return c.HTTP2Upgrade()
+	}
+	return false
+}
+
+// http2Upgrade returns true if the http1 request is sent to the upstream by http2
+func (r *upstreamRequest) http2Upgrade() bool {
+	return r.protocol == protocol.HTTP2 &&
+		r.downStream.getDownstreamProtocol() == protocol.HTTP1 &&
+		r.downStream.clusterHTTP2Upgrade()
+}
+
+// http1ToHTTP2RequestHeader converts the http1 request header to a common header,
+// the http2 client stream encodes the common header and the request variables to a http2 request.
+func http1ToHTTP2RequestHeader(headers types.HeaderMap) types.HeaderMap {
+	h1, ok := headers.(mosnhttp.RequestHeader)
+	if !ok {
+		return headers
+	}
+	out := make(map[string]string, h1.Len())
+	h1.VisitAll(func(key, value []byte) {
+		k := strings.ToLower(string(key))
+		if _, hop := http1HopHeaders[k]; hop {
+			return
+		}
+		// only "te: trailers" is allowed in http2
+		if k == "te" && !strings.EqualFold(string(value), "trailers") {
+			return
+		}
+		out[k] = string(value)
+	})
+	return protocol.CommonHeader(out)
+}
+
+// http2ToHTTP1ResponseHeader converts the http2 response header to a http1 response header,
+// the status code is set by the http1 server stream from the response variable.
+func http2ToHTTP1ResponseHeader(headers types.HeaderMap) types.HeaderMap {
+	if _, ok := headers.(*mosnhttp2.RspHeader); !ok {
+		return headers
+	}
+	h1 := mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	mosnhttp2.DecodeHeader(headers).Range(func(key, value string) bool {
+		h1.Set(key, value)
+		return true
+	})
+	return h1
+}
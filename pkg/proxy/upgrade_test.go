@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	mosnhttp2 "mosn.io/mosn/pkg/protocol/http2"
+)
+
+func TestHTTP1ToHTTP2RequestHeader(t *testing.T) {
+	h1 := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	h1.Set("X-Request-Id", "1")
+	h1.Set("Connection", "keep-alive")
+	h1.Set("Keep-Alive", "timeout=5")
+	h1.Set("Transfer-Encoding", "chunked")
+	h1.Set("Expect", "100-continue")
+	h1.Set("Te", "trailers")
+
+	headers := http1ToHTTP2RequestHeader(h1)
+	ch, ok := headers.(protocol.CommonHeader)
+	if !assert.True(t, ok) {
+		return
+	}
+	v, _ := ch.Get("x-request-id")
+	assert.Equal(t, "1", v)
+	v, _ = ch.Get("te")
+	assert.Equal(t, "trailers", v)
+	for _, key := range []string{"connection", "keep-alive", "transfer-encoding", "expect"} {
+		_, ok := ch.Get(key)
+		assert.False(t, ok, key)
+	}
+
+	// te is not allowed except trailers
+	h1.Set("Te", "gzip")
+	ch = http1ToHTTP2RequestHeader(h1).(protocol.CommonHeader)
+	_, ok = ch.Get("te")
+	assert.False(t, ok)
+
+	// not http1 header
+	common := protocol.CommonHeader{"k": "v"}
+	assert.Equal(t, common, http1ToHTTP2RequestHeader(common))
+}
+
+func TestHTTP2ToHTTP1ResponseHeader(t *testing.T) {
+	rsp := mosnhttp2.NewRspHeader(&http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"X-Response-Id": []string{"1"},
+			"Content-Type":  []string{"text/plain"},
+		},
+	})
+	headers := http2ToHTTP1ResponseHeader(rsp)
+	h1, ok := headers.(mosnhttp.ResponseHeader)
+	if !assert.True(t, ok) {
+		return
+	}
+	v, _ := h1.Get("X-Response-Id")
+	assert.Equal(t, "1", v)
+	assert.Equal(t, "text/plain", string(h1.ContentType()))
+
+	// not http2 header
+	common := protocol.CommonHeader{"k": "v"}
+	assert.Equal(t, common, http2ToHTTP1ResponseHeader(common))
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
@@ -40,11 +41,20 @@ type upstreamRequest struct {
 	requestSender types.StreamSender
 	connPool      types.ConnectionPool
 
+	// context of the upstream stream, the hedged request has its own buffers
+	context context.Context
+
 	//~~~ state
 	sendComplete bool
 	dataSent     bool
 	trailerSent  bool
 	setupRetry   bool
+	// the response of this request is received first
+	responded bool
+	// the request is reset while the hedged one is still in flight
+	failed uint32
+	// the request loses the hedging, all the events are ignored
+	cancelled uint32
 
 	// time at send upstream request
 	startTime time.Time
@@ -66,10 +76,37 @@ func (r *upstreamRequest) resetStream() {
 	}
 }
 
+// cancel resets the upstream request which loses the hedging
+func (r *upstreamRequest) cancel() {
+	if !atomic.CompareAndSwapUint32(&r.cancelled, 0, 1) {
+		return
+	}
+	r.resetStream()
+}
+
+func (r *upstreamRequest) isCancelled() bool {
+	return atomic.LoadUint32(&r.cancelled) == 1
+}
+
+func (r *upstreamRequest) streamContext() context.Context {
+	if r.context != nil {
+		return r.context
+	}
+	return r.downStream.context
+}
+
 // types.StreamEventListener
 // Called by stream layer normally
 func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
-	if r.setupRetry {
+	if r.setupRetry || r.isCancelled() {
+		return
+	}
+	// the timeouts are triggered by the downstream, which cover all the hedged requests.
+	// if one of the hedged requests is reset, waits for the other one.
+	if reason != types.UpstreamGlobalTimeout && reason != types.UpstreamPerTryTimeout &&
+		atomic.CompareAndSwapInt32(&r.downStream.hedgeInflight, 2, 1) {
+		log.Proxy.Warnf(r.downStream.context, "[proxy] [upstream] hedged request reset, host: %s, reason: %v", r.host.AddressString(), reason)
+		atomic.StoreUint32(&r.failed, 1)
 		return
 	}
 	// todo: check if we get a reset on encode request headers. e.g. send failed
@@ -85,9 +122,10 @@ func (r *upstreamRequest) OnDestroyStream() {}
 
 func (r *upstreamRequest) endStream() {
 	upstreamResponseDurationNs := time.Now().Sub(r.startTime).Nanoseconds()
-	r.host.HostStats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
+	traceId := exemplarTraceId(r.downStream.context)
+	metrics.UpdateWithExemplar(r.host.HostStats().UpstreamRequestDuration, upstreamResponseDurationNs, traceId)
 	r.host.HostStats().UpstreamRequestDurationTotal.Inc(upstreamResponseDurationNs)
-	r.host.ClusterInfo().Stats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
+	metrics.UpdateWithExemplar(r.host.ClusterInfo().Stats().UpstreamRequestDuration, upstreamResponseDurationNs, traceId)
 	r.host.ClusterInfo().Stats().UpstreamRequestDurationTotal.Inc(upstreamResponseDurationNs)
 
 	// todo: record upstream process time in request info
@@ -96,23 +134,31 @@ func (r *upstreamRequest) endStream() {
 // types.StreamReceiveListener
 // Method to decode upstream's response message
 func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
-	if r.downStream.processDone() || r.setupRetry {
+	if r.downStream.processDone() || r.setupRetry || r.isCancelled() {
 		return
 	}
 	if !atomic.CompareAndSwapUint32(&r.downStream.upstreamResponseReceived, 0, 1) {
 		return
 	}
+	r.responded = true
 
 	r.endStream()
 
-	if code, err := protocol.MappingHeaderStatusCode(r.downStream.context, r.protocol, headers); err == nil {
+	if code, err := protocol.MappingHeaderStatusCode(r.streamContext(), r.protocol, headers); err == nil {
 		r.downStream.requestInfo.SetResponseCode(code)
 	}
+	r.downStream.recordGrpcStatus(headers, trailers)
+
+	// translate the http2 response for the http1 downstream
+	if r.http2Upgrade() {
+		headers = http2ToHTTP1ResponseHeader(headers)
+	}
 
 	r.downStream.requestInfo.SetResponseReceivedDuration(time.Now())
 	r.downStream.downstreamRespHeaders = headers
 	r.downStream.downstreamRespDataBuf = data
 	r.downStream.downstreamRespTrailers = trailers
+	r.downStream.checkResponseBodyLimit()
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] OnReceive headers: %+v, data: %+v, trailers: %+v", headers, data, trailers)
@@ -121,6 +167,23 @@ func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap
 	r.downStream.sendNotify()
 }
 
+// OnReceiveInformational forwards the informational (1xx) response to the downstream,
+// the final response is received by OnReceive later.
+func (r *upstreamRequest) OnReceiveInformational(ctx context.Context, headers types.HeaderMap) {
+	if r.downStream.processDone() || r.setupRetry || r.isCancelled() {
+		return
+	}
+	if atomic.LoadUint32(&r.downStream.upstreamResponseReceived) != 0 {
+		return
+	}
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] OnReceiveInformational headers: %+v", headers)
+	}
+
+	r.downStream.sendInformational(headers)
+}
+
 func (r *upstreamRequest) receiveHeaders(endStream bool) {
 	if r.downStream.processDone() || r.setupRetry {
 		return
@@ -168,9 +231,9 @@ func (r *upstreamRequest) appendHeaders(endStream bool) {
 	)
 
 	if r.downStream.oneway {
-		_, streamSender, failReason = r.connPool.NewStream(r.downStream.context, nil)
+		_, streamSender, failReason = r.connPool.NewStream(r.streamContext(), nil)
 	} else {
-		_, streamSender, failReason = r.connPool.NewStream(r.downStream.context, r)
+		_, streamSender, failReason = r.connPool.NewStream(r.streamContext(), r)
 	}
 
 	if failReason != "" {
@@ -192,7 +255,7 @@ func (r *upstreamRequest) appendData(endStream bool) {
 	data := r.downStream.downstreamReqDataBuf
 	r.sendComplete = endStream
 	r.dataSent = true
-	r.requestSender.AppendData(r.downStream.context, data, endStream)
+	r.requestSender.AppendData(r.streamContext(), data, endStream)
 }
 
 func (r *upstreamRequest) appendTrailers() {
@@ -205,7 +268,7 @@ func (r *upstreamRequest) appendTrailers() {
 	trailers := r.downStream.downstreamReqTrailers
 	r.sendComplete = true
 	r.trailerSent = true
-	r.requestSender.AppendTrailers(r.downStream.context, trailers)
+	r.requestSender.AppendTrailers(r.streamContext(), trailers)
 }
 
 // types.PoolEventListener
@@ -232,6 +295,7 @@ func (r *upstreamRequest) OnReady(sender types.StreamSender) {
 
 	r.requestSender = sender
 	r.requestSender.GetStream().AddEventListener(r)
+	r.downStream.circuitBreakerReady()
 	// start a upstream send
 	r.startTime = time.Now()
 
@@ -242,8 +306,14 @@ func (r *upstreamRequest) OnReady(sender types.StreamSender) {
 		}
 	}
 
+	headers := r.downStream.downstreamReqHeaders
+	// translate the http1 request for the http2 upstream
+	if r.http2Upgrade() {
+		headers = http1ToHTTP2RequestHeader(headers)
+	}
+
 	endStream := r.sendComplete && !r.dataSent && !r.trailerSent
-	r.requestSender.AppendHeaders(r.downStream.context, r.downStream.downstreamReqHeaders, endStream)
+	r.requestSender.AppendHeaders(r.streamContext(), headers, endStream)
 
 	// todo: check if we get a reset on send headers
 }
@@ -29,10 +29,26 @@ import (
 
 var bitSize64 = 1 << 6
 
-func parseProxyTimeout(ctx context.Context, timeout *Timeout, route types.Route, headers types.HeaderMap) {
+// parseProxyTimeout parses the timeout of the request, the timeout in the request headers and the variables
+// takes precedence over the route timeout, and the route timeout takes precedence over the cluster default.
+// a route timeout of zero means no timeout, while zero in the request headers and the variables is ignored,
+// so the global timeout can not be disabled by the client.
+func parseProxyTimeout(ctx context.Context, timeout *Timeout, route types.Route, cluster types.ClusterInfo, headers types.HeaderMap) {
+	timeoutSet := false
 	if route != nil {
-		timeout.GlobalTimeout = route.RouteRule().GlobalTimeout()
-		timeout.TryTimeout = route.RouteRule().Policy().RetryPolicy().TryTimeout()
+		rule := route.RouteRule()
+		if rt, ok := rule.(types.RequestTimeoutRule); ok {
+			timeout.GlobalTimeout, timeoutSet = rt.RequestTimeout()
+		} else if gto := rule.GlobalTimeout(); gto > 0 {
+			timeout.GlobalTimeout, timeoutSet = gto, true
+		}
+		timeout.TryTimeout = rule.Policy().RetryPolicy().TryTimeout()
+	}
+
+	if !timeoutSet && cluster != nil {
+		if rc, ok := cluster.(types.RequestTimeoutCluster); ok && rc.RequestTimeout() > 0 {
+			timeout.GlobalTimeout, timeoutSet = rc.RequestTimeout(), true
+		}
 	}
 
 	// todo: check global timeout in request headers
@@ -45,8 +61,8 @@ func parseProxyTimeout(ctx context.Context, timeout *Timeout, route types.Route,
 	}
 
 	if gto, ok := headers.Get(types.HeaderGlobalTimeout); ok {
-		if globaltimeout, err := strconv.ParseInt(gto, 10, bitSize64); err == nil {
-			timeout.GlobalTimeout = time.Duration(globaltimeout) * time.Millisecond
+		if globaltimeout, err := strconv.ParseInt(gto, 10, bitSize64); err == nil && globaltimeout > 0 {
+			timeout.GlobalTimeout, timeoutSet = time.Duration(globaltimeout)*time.Millisecond, true
 		}
 	}
 
@@ -58,16 +74,17 @@ func parseProxyTimeout(ctx context.Context, timeout *Timeout, route types.Route,
 	}
 
 	if gto, err := variable.GetString(ctx, types.VarProxyGlobalTimeout); err == nil {
-		if globaltimeout, err := strconv.ParseInt(gto, 10, bitSize64); err == nil {
-			timeout.GlobalTimeout = time.Duration(globaltimeout) * time.Millisecond
+		if globaltimeout, err := strconv.ParseInt(gto, 10, bitSize64); err == nil && globaltimeout > 0 {
+			timeout.GlobalTimeout, timeoutSet = time.Duration(globaltimeout)*time.Millisecond, true
 		}
 	}
 
-	if timeout.GlobalTimeout == 0 {
+	if !timeoutSet {
 		timeout.GlobalTimeout = types.GlobalTimeout
 	}
 
-	if timeout.TryTimeout >= timeout.GlobalTimeout {
+	// the try timeout is useless if it is not shorter than the global timeout
+	if timeout.GlobalTimeout > 0 && timeout.TryTimeout >= timeout.GlobalTimeout {
 		timeout.TryTimeout = 0
 	}
 }
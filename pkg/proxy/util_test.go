@@ -19,9 +19,15 @@ package proxy
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/variable"
@@ -32,7 +38,7 @@ func TestParseProxyTimeout(t *testing.T) {
 	headers := make(protocol.CommonHeader)
 	ctx := variable.NewVariableContext(context.Background())
 
-	parseProxyTimeout(ctx, &to, nil, headers)
+	parseProxyTimeout(ctx, &to, nil, nil, headers)
 	if to.TryTimeout != 0 || to.GlobalTimeout != types.GlobalTimeout {
 		t.Errorf("parseProxyTimeout error")
 	}
@@ -40,7 +46,7 @@ func TestParseProxyTimeout(t *testing.T) {
 	variable.SetString(ctx, types.VarProxyTryTimeout, "10000")
 	variable.SetString(ctx, types.VarProxyGlobalTimeout, "100000")
 
-	parseProxyTimeout(ctx, &to, nil, headers)
+	parseProxyTimeout(ctx, &to, nil, nil, headers)
 	if to.TryTimeout != 10000*time.Millisecond || to.GlobalTimeout != 100000*time.Millisecond {
 		t.Errorf("parseProxyTimeout error")
 	}
@@ -48,21 +54,130 @@ func TestParseProxyTimeout(t *testing.T) {
 	variable.SetString(ctx, types.VarProxyTryTimeout, "1000000")
 	variable.SetString(ctx, types.VarProxyGlobalTimeout, "100000")
 
-	parseProxyTimeout(ctx, &to, nil, headers)
+	parseProxyTimeout(ctx, &to, nil, nil, headers)
 	if to.TryTimeout != 0 || to.GlobalTimeout != 100000*time.Millisecond {
 		t.Errorf("parseProxyTimeout error")
 	}
 
 	ctx = variable.NewVariableContext(context.Background())
-	parseProxyTimeout(ctx, &to, &mockRoute{}, headers)
+	parseProxyTimeout(ctx, &to, &mockRoute{}, nil, headers)
 	if to.TryTimeout != time.Millisecond || to.GlobalTimeout != 10^6*time.Millisecond {
 		t.Errorf("parseProxyTimeout error")
 	}
 
 	headers.Set(types.HeaderGlobalTimeout, "1000")
 	headers.Set(types.HeaderTryTimeout, "100")
-	parseProxyTimeout(ctx, &to, nil, headers)
+	parseProxyTimeout(ctx, &to, nil, nil, headers)
 	if to.TryTimeout != 100*time.Millisecond || to.GlobalTimeout != 1000*time.Millisecond {
 		t.Errorf("parseProxyTimeout error")
 	}
 }
+
+// requestTimeoutRouteRule is a route rule with the request timeout
+type requestTimeoutRouteRule struct {
+	mockRouteRule
+	timeout time.Duration
+	set     bool
+}
+
+func (r *requestTimeoutRouteRule) RequestTimeout() (time.Duration, bool) {
+	return r.timeout, r.set
+}
+
+// requestTimeoutCluster is a cluster info with the default request timeout
+type requestTimeoutCluster struct {
+	types.ClusterInfo
+	timeout time.Duration
+}
+
+func (c *requestTimeoutCluster) RequestTimeout() time.Duration {
+	return c.timeout
+}
+
+func TestParseProxyTimeoutPrecedence(t *testing.T) {
+	headers := make(protocol.CommonHeader)
+	ctx := variable.NewVariableContext(context.Background())
+	cluster := &requestTimeoutCluster{timeout: 10 * time.Second}
+	newRoute := func(timeout time.Duration, set bool) api.Route {
+		return &mockRoute{rule: &requestTimeoutRouteRule{timeout: timeout, set: set}}
+	}
+
+	// the route timeout overrides the cluster timeout
+	var to Timeout
+	parseProxyTimeout(ctx, &to, newRoute(time.Second, true), cluster, headers)
+	assert.Equal(t, time.Second, to.GlobalTimeout)
+	assert.Equal(t, time.Millisecond, to.TryTimeout)
+
+	// the cluster timeout is used if the route timeout is not set
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(0, false), cluster, headers)
+	assert.Equal(t, 10*time.Second, to.GlobalTimeout)
+
+	// the global default is used if neither is set
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(0, false), &requestTimeoutCluster{}, headers)
+	assert.Equal(t, types.GlobalTimeout, to.GlobalTimeout)
+
+	// zero route timeout means no timeout, the try timeout is kept
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(0, true), cluster, headers)
+	assert.Equal(t, time.Duration(0), to.GlobalTimeout)
+	assert.Equal(t, time.Millisecond, to.TryTimeout)
+
+	// the request header takes precedence over the route
+	headers.Set(types.HeaderGlobalTimeout, "500")
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(0, true), cluster, headers)
+	assert.Equal(t, 500*time.Millisecond, to.GlobalTimeout)
+
+	// zero in the request header or the variable does not disable the timeout
+	headers.Set(types.HeaderGlobalTimeout, "0")
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(time.Second, true), cluster, headers)
+	assert.Equal(t, time.Second, to.GlobalTimeout)
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(0, false), &requestTimeoutCluster{}, headers)
+	assert.Equal(t, types.GlobalTimeout, to.GlobalTimeout)
+	headers.Del(types.HeaderGlobalTimeout)
+	_ = variable.SetString(ctx, types.VarProxyGlobalTimeout, "0")
+	to = Timeout{}
+	parseProxyTimeout(ctx, &to, newRoute(0, false), cluster, headers)
+	assert.Equal(t, 10*time.Second, to.GlobalTimeout)
+}
+
+func TestRouteTimeoutShorterThanCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var reset, unused int32
+	s, responseSender := newHedgeDownstream(ctrl, 0, &reset, &unused)
+	s.retryState = nil
+	cluster := &requestTimeoutCluster{ClusterInfo: s.cluster, timeout: 2 * time.Second}
+	s.cluster = cluster
+	parseProxyTimeout(s.context, &s.timeout, &mockRoute{
+		rule: &requestTimeoutRouteRule{timeout: 50 * time.Millisecond, set: true},
+	}, cluster, s.downstreamReqHeaders)
+	require.Equal(t, 50*time.Millisecond, s.timeout.GlobalTimeout)
+
+	timeouts := s.cluster.Stats().UpstreamRequestTimeout.Count()
+	start := time.Now()
+	s.onUpstreamRequestSent()
+	done := make(chan types.Phase)
+	go func() {
+		done <- s.receive(s.context, 1, types.WaitNotify)
+	}()
+	select {
+	case phase := <-done:
+		assert.Equal(t, types.End, phase)
+	case <-time.After(time.Second):
+		t.Fatal("the route timeout is not fired")
+	}
+
+	// the route timeout fires before the cluster timeout
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, api.TimeoutExceptionCode, s.requestInfo.ResponseCode())
+	assert.True(t, s.requestInfo.GetResponseFlag(api.UpstreamRequestTimeout))
+	assert.Equal(t, timeouts+1, s.cluster.Stats().UpstreamRequestTimeout.Count())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reset))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&responseSender.count))
+}
@@ -57,9 +57,11 @@ var (
 		variable.NewStringVariable(types.VarProxyHijackStatus, nil, nil, variable.DefaultStringSetter, 0),
 		variable.NewStringVariable(types.VarProxyGzipSwitch, nil, nil, variable.DefaultStringSetter, 0),
 		variable.NewStringVariable(types.VarProxyIsDirectResponse, nil, nil, variable.DefaultStringSetter, 0),
+		variable.NewStringVariable(types.VarProxyClientAddress, nil, nil, variable.DefaultStringSetter, 0),
 		variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0),
 		variable.NewStringVariable(types.VarHeaderRPCMethod, nil, nil, variable.DefaultStringSetter, 0),
 		variable.NewStringVariable(types.VarHeaderRPCService, nil, nil, variable.DefaultStringSetter, 0),
+		variable.NewStringVariable(types.VarGrpcStatus, nil, nil, variable.DefaultStringSetter, 0),
 	}
 
 	prefixVariables = []variable.Variable{
@@ -234,6 +236,10 @@ func upstreamClusterGetter(ctx context.Context, value *variable.IndexedValue, da
 	if stream.cluster != nil {
 		return stream.cluster.Name(), nil
 	}
+	// the cluster is chosen but the upstream request is not initialized yet
+	if name := proxyBuffers.info.UpstreamClusterName(); name != "" {
+		return name, nil
+	}
 	return variable.ValueNotFound, errors.New("not found clustername")
 }
 
@@ -46,6 +46,7 @@ type RouteRuleImplBase struct {
 	routerMatch v2.RouterMatch
 	// rewrite
 	prefixRewrite         string
+	prefixRewriteTemplate *captureTemplate
 	regexRewrite          v2.RegexRewrite
 	regexPattern          *regexp.Regexp
 	hostRewrite           string
@@ -56,6 +57,8 @@ type RouteRuleImplBase struct {
 	// information
 	upstreamProtocol string
 	perFilterConfig  map[string]interface{}
+	// parsedFilterConfig keeps the per filter configs parsed by the registered parsers
+	parsedFilterConfig map[string]interface{}
 	// policy
 	policy *policy
 	// direct response
@@ -67,8 +70,14 @@ type RouteRuleImplBase struct {
 	defaultCluster     *weightedClusterEntry // cluster name and metadata
 	weightedClusters   map[string]weightedClusterEntry
 	totalClusterWeight uint32
-	lock               sync.Mutex
-	randInstance       *rand.Rand
+	// weightedClusterList keeps the config order, so the same random value always chooses the same cluster
+	weightedClusterList []weightedClusterEntry
+	lock                sync.Mutex
+	randInstance        *rand.Rand
+	// canaryPolicy is not nil if a part of the requests is sent to the canary cluster
+	canaryPolicy *canaryPolicyImpl
+	// accessLog overrides the listener access logs, nil means no override
+	accessLog *v2.RouteAccessLog
 }
 
 func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleImplBase, error) {
@@ -76,6 +85,7 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 		vHost:                 vHost,
 		routerMatch:           route.Match,
 		prefixRewrite:         route.Route.PrefixRewrite,
+		prefixRewriteTemplate: parseCaptureTemplate(route.Route.PrefixRewrite),
 		hostRewrite:           route.Route.HostRewrite,
 		autoHostRewrite:       route.Route.AutoHostRewrite,
 		autoHostRewriteHeader: route.Route.AutoHostRewriteHeader,
@@ -85,6 +95,7 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 		perFilterConfig:       route.PerFilterConfig,
 		policy:                &policy{},
 		routerAction:          route.Route,
+		accessLog:             route.AccessLog,
 		defaultCluster: &weightedClusterEntry{
 			clusterName: route.Route.ClusterName,
 		},
@@ -101,18 +112,56 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 		base.regexPattern = regexPattern
 	}
 
+	parsedFilterConfig, err := parsePerFilterConfig(route.PerFilterConfig)
+	if err != nil {
+		return nil, err
+	}
+	base.parsedFilterConfig = parsedFilterConfig
+
 	// add clusters
 	base.weightedClusters, base.totalClusterWeight = getWeightedClusterEntry(route.Route.WeightedClusters)
+	base.weightedClusterList = getWeightedClusterList(route.Route.WeightedClusters)
 	if len(route.Route.MetadataMatch) > 0 {
 		base.defaultCluster.clusterMetadataMatchCriteria = NewMetadataMatchCriteriaImpl(route.Route.MetadataMatch)
 	}
 	// add policy
 	if route.Route.RetryPolicy != nil {
 		base.policy.retryPolicy = &retryPolicyImpl{
-			retryOn:      route.Route.RetryPolicy.RetryOn,
-			retryTimeout: route.Route.RetryPolicy.RetryTimeout,
-			numRetries:   route.Route.RetryPolicy.NumRetries,
-			statusCodes:  route.Route.RetryPolicy.StatusCodes,
+			retryOn:            route.Route.RetryPolicy.RetryOn,
+			retryTimeout:       route.Route.RetryPolicy.RetryTimeout,
+			numRetries:         route.Route.RetryPolicy.NumRetries,
+			statusCodes:        route.Route.RetryPolicy.StatusCodes,
+			hedgeDelay:         route.Route.RetryPolicy.HedgeDelay,
+			retryNonIdempotent: route.Route.RetryPolicy.RetryNonIdempotent,
+			grpcStatusCodes:    route.Route.RetryPolicy.GrpcStatusCodes,
+		}
+		if bo := route.Route.RetryPolicy.RetryBackOff; bo != nil && bo.BaseInterval.Duration > 0 {
+			baseInterval, maxInterval := bo.BaseInterval.Duration, bo.MaxInterval.Duration
+			// the max interval defaults to 10 times of the base interval
+			if maxInterval == 0 {
+				maxInterval = 10 * baseInterval
+			}
+			if maxInterval < baseInterval {
+				maxInterval = baseInterval
+			}
+			base.policy.retryPolicy.backOffBase = baseInterval
+			base.policy.retryPolicy.backOffMax = maxInterval
+		}
+		for _, h := range route.Route.RetryPolicy.RetriableHeaders {
+			// the header is only required to be present
+			if h.Value == "" && !h.Regex {
+				h.Value, h.Regex = ".*", true
+			}
+			base.policy.retryPolicy.retriableHeaders = append(base.policy.retryPolicy.retriableHeaders,
+				CreateCommonHeaderMatcher([]v2.HeaderMatcher{h}))
+		}
+		for _, cond := range route.Route.RetryPolicy.RetryConditions {
+			switch cond {
+			case types.RetryOnConnectFailure:
+				base.policy.retryPolicy.retryOnConnectFailure = true
+			default:
+				return nil, fmt.Errorf("unknown retry condition: %s", cond)
+			}
 		}
 	}
 	// add hash policy
@@ -135,11 +184,20 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 	if base.policy.hashPolicy == nil {
 		base.policy.hashPolicy = &sourceIPHashPolicyImpl{}
 	}
+	// add canary policy
+	if route.Route.Canary != nil {
+		canaryPolicy, err := newCanaryPolicy(route.Route.Canary)
+		if err != nil {
+			return nil, err
+		}
+		base.canaryPolicy = canaryPolicy
+	}
 	// add direct repsonse rule
 	if route.DirectResponse != nil {
 		base.directResponseRule = &directResponseImpl{
-			status: route.DirectResponse.StatusCode,
-			body:   route.DirectResponse.Body,
+			status:  route.DirectResponse.StatusCode,
+			body:    route.DirectResponse.Body,
+			headers: route.DirectResponse.Headers,
 		}
 	}
 	// add redirect rule
@@ -147,6 +205,12 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 		r := route.Redirect
 
 		scheme := r.SchemeRedirect
+		if r.HttpsRedirect {
+			if len(scheme) > 0 && !strings.EqualFold(scheme, "https") {
+				return nil, fmt.Errorf("https redirect conflicts with scheme redirect: %s", scheme)
+			}
+			scheme = "https"
+		}
 		if len(scheme) > 0 {
 			scheme = strings.ToLower(scheme)
 			if !schemeValidator.MatchString(scheme) {
@@ -176,11 +240,7 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 
 	// add mirror policies
 	if route.RequestMirrorPolicies != nil {
-		base.policy.mirrorPolicy = &mirrorImpl{
-			cluster: route.RequestMirrorPolicies.Cluster,
-			percent: int(route.RequestMirrorPolicies.Percent),
-			rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
-		}
+		base.policy.mirrorPolicy = newMirrorImpl(route.RequestMirrorPolicies)
 	}
 	if base.policy.mirrorPolicy == nil {
 		base.policy.mirrorPolicy = &mirrorImpl{}
@@ -206,9 +266,9 @@ func (rri *RouteRuleImplBase) RedirectRule() api.RedirectRule {
 // types.RouteRule
 // Select Cluster for Routing
 // if weighted cluster is nil, return clusterName directly, else
-// select cluster from weighted-clusters
+// select cluster from weighted-clusters, each cluster is chosen in proportion to its weight
 func (rri *RouteRuleImplBase) ClusterName(ctx context.Context) string {
-	if len(rri.weightedClusters) == 0 {
+	if len(rri.weightedClusterList) == 0 || rri.totalClusterWeight == 0 {
 		// If both 'cluster_name' and 'cluster_variable' are configured, 'cluster_name' is preferred.
 		if rri.defaultCluster.clusterName != "" {
 			return rri.defaultCluster.clusterName
@@ -228,11 +288,12 @@ func (rri *RouteRuleImplBase) ClusterName(ctx context.Context) string {
 	selectedValue := rri.randInstance.Intn(int(rri.totalClusterWeight))
 	rri.lock.Unlock()
 
-	for _, weightCluster := range rri.weightedClusters {
-		selectedValue = selectedValue - int(weightCluster.clusterWeight)
-		if selectedValue <= 0 {
+	// selectedValue is in [0, totalClusterWeight), each cluster owns a range as long as its weight
+	for _, weightCluster := range rri.weightedClusterList {
+		if selectedValue < int(weightCluster.clusterWeight) {
 			return weightCluster.clusterName
 		}
+		selectedValue = selectedValue - int(weightCluster.clusterWeight)
 	}
 
 	return rri.defaultCluster.clusterName
@@ -246,6 +307,14 @@ func (rri *RouteRuleImplBase) GlobalTimeout() time.Duration {
 	return rri.routerAction.Timeout
 }
 
+// RequestTimeout implements types.RequestTimeoutRule
+func (rri *RouteRuleImplBase) RequestTimeout() (time.Duration, bool) {
+	if rri.routerAction.TimeoutConfig == nil && rri.routerAction.Timeout <= 0 {
+		return 0, false
+	}
+	return rri.routerAction.Timeout, true
+}
+
 func (rri *RouteRuleImplBase) Policy() api.Policy {
 	return rri.policy
 }
@@ -264,10 +333,71 @@ func (rri *RouteRuleImplBase) MetadataMatchCriteria(clusterName string) api.Meta
 
 }
 
+// MaxRequestBodyBytes returns the max bytes of the request body, zero means no limit
+func (rri *RouteRuleImplBase) MaxRequestBodyBytes() uint64 {
+	return rri.routerAction.MaxRequestBodyBytes
+}
+
+// MaxResponseBodyBytes returns the max bytes of the response body, zero means no limit
+func (rri *RouteRuleImplBase) MaxResponseBodyBytes() uint64 {
+	return rri.routerAction.MaxResponseBodyBytes
+}
+
+// AdmissionPriority implements types.AdmissionPriorityRule
+func (rri *RouteRuleImplBase) AdmissionPriority() (int, bool) {
+	if rri.routerAction.AdmissionPriority == nil {
+		return 0, false
+	}
+	return int(*rri.routerAction.AdmissionPriority), true
+}
+
+// AccessLogDisabled implements types.AccessLogRule
+func (rri *RouteRuleImplBase) AccessLogDisabled() bool {
+	return rri.accessLog != nil && rri.accessLog.Disable
+}
+
+// AccessLogFormatName implements types.AccessLogRule
+func (rri *RouteRuleImplBase) AccessLogFormatName() string {
+	if rri.accessLog == nil {
+		return ""
+	}
+	return rri.accessLog.FormatName
+}
+
+// StreamRequestBody returns whether the request body is streamed to the upstream as it arrives
+func (rri *RouteRuleImplBase) StreamRequestBody() (bool, bool) {
+	if rri.routerAction.StreamRequestBody == nil {
+		return false, false
+	}
+	return *rri.routerAction.StreamRequestBody, true
+}
+
+// Priority returns the priority of the requests matched the route, the default priority is used if not configured
+func (rri *RouteRuleImplBase) Priority() types.RoutingPriority {
+	if rri.routerAction.Priority == "" {
+		return types.DefaultPriority
+	}
+	return types.RoutingPriority(rri.routerAction.Priority)
+}
+
+// CanaryPolicy returns the canary policy of the route, nil means no canary cluster
+func (rri *RouteRuleImplBase) CanaryPolicy() types.CanaryPolicy {
+	if rri.canaryPolicy == nil {
+		return nil
+	}
+	return rri.canaryPolicy
+}
+
 func (rri *RouteRuleImplBase) PerFilterConfig() map[string]interface{} {
 	return rri.perFilterConfig
 }
 
+// ParsedPerFilterConfig returns the per filter config parsed by the parser registered by RegisterPerFilterConfigParser
+func (rri *RouteRuleImplBase) ParsedPerFilterConfig(name string) (interface{}, bool) {
+	cfg, ok := rri.parsedFilterConfig[name]
+	return cfg, ok
+}
+
 func (rri *RouteRuleImplBase) FinalizePathHeader(ctx context.Context, headers api.HeaderMap, matchedPath string) {
 	rri.finalizePathHeader(ctx, headers, matchedPath)
 }
@@ -285,9 +415,13 @@ func (rri *RouteRuleImplBase) finalizePathHeader(ctx context.Context, headers ap
 		//prefix rewrite by default
 		if len(rri.prefixRewrite) != 0 {
 			if strings.HasPrefix(path, matchedPath) {
+				prefixRewrite := rri.prefixRewrite
+				if rri.prefixRewriteTemplate != nil {
+					prefixRewrite = rri.prefixRewriteTemplate.render(ctx)
+				}
 				// origin path need to save in the header
 				headers.Set(types.HeaderOriginalPath, path)
-				variable.SetString(ctx, types.VarPath, rri.prefixRewrite+path[len(matchedPath):])
+				variable.SetString(ctx, types.VarPath, prefixRewrite+path[len(matchedPath):])
 				if log.DefaultLogger.GetLogLevel() >= log.INFO {
 					log.DefaultLogger.Infof(RouterLogFormat, "routerule", "finalizePathHeader", "add prefix to path, prefix is "+rri.prefixRewrite)
 				}
@@ -315,8 +449,9 @@ func (rri *RouteRuleImplBase) FinalizeRequestHeaders(ctx context.Context, header
 }
 
 func (rri *RouteRuleImplBase) finalizeRequestHeaders(ctx context.Context, headers api.HeaderMap, requestInfo api.RequestInfo) {
-	rri.requestHeadersParser.evaluateHeaders(ctx, headers)
+	// the route headers are evaluated after the virtual host, so the route takes precedence
 	rri.vHost.FinalizeRequestHeaders(ctx, headers, requestInfo)
+	rri.requestHeadersParser.evaluateHeaders(ctx, headers)
 	if len(rri.hostRewrite) > 0 {
 		variable.SetString(ctx, types.VarIstioHeaderHost, rri.hostRewrite)
 	} else if len(rri.autoHostRewriteHeader) > 0 {
@@ -325,13 +460,14 @@ func (rri *RouteRuleImplBase) finalizeRequestHeaders(ctx context.Context, header
 		}
 	} else if rri.autoHostRewrite {
 		clusterSnapshot := cluster.GetClusterMngAdapterInstance().GetClusterSnapshot(context.TODO(), rri.routerAction.ClusterName)
-		if clusterSnapshot != nil && (clusterSnapshot.ClusterInfo().ClusterType() == v2.STRICT_DNS_CLUSTER) {
+		if clusterSnapshot != nil && (clusterSnapshot.ClusterInfo().ClusterType() == v2.STRICT_DNS_CLUSTER ||
+			clusterSnapshot.ClusterInfo().ClusterType() == v2.LOGICAL_DNS_CLUSTER) {
 			variable.SetString(ctx, types.VarIstioHeaderHost, requestInfo.UpstreamHost().Hostname())
 		}
 	}
 }
 
 func (rri *RouteRuleImplBase) FinalizeResponseHeaders(ctx context.Context, headers api.HeaderMap, requestInfo api.RequestInfo) {
-	rri.responseHeadersParser.evaluateHeaders(ctx, headers)
 	rri.vHost.FinalizeResponseHeaders(ctx, headers, requestInfo)
+	rri.responseHeadersParser.evaluateHeaders(ctx, headers)
 }
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/protocol"
@@ -156,6 +157,144 @@ func TestWeightedClusterSelect(t *testing.T) {
 	}
 }
 
+func TestWeightedClusterSelectRatio(t *testing.T) {
+	newWeightedRouter := func(weights map[string]uint32, names ...string) *v2.Router {
+		r := &v2.Router{}
+		r.Route.ClusterName = "defaultCluster"
+		for _, name := range names {
+			r.Route.WeightedClusters = append(r.Route.WeightedClusters, v2.WeightedCluster{
+				Cluster: v2.ClusterWeight{
+					ClusterWeightConfig: v2.ClusterWeightConfig{
+						Name:   name,
+						Weight: weights[name],
+					},
+				},
+			})
+		}
+		return r
+	}
+	testCases := []struct {
+		weights map[string]uint32
+		names   []string
+	}{
+		{
+			weights: map[string]uint32{"cluster-v1": 90, "cluster-v2": 10},
+			names:   []string{"cluster-v1", "cluster-v2"},
+		},
+		// the weights need not sum to 100
+		{
+			weights: map[string]uint32{"a": 1, "b": 2, "c": 5},
+			names:   []string{"a", "b", "c"},
+		},
+		// the cluster without weight is never chosen
+		{
+			weights: map[string]uint32{"a": 1, "b": 0, "c": 1},
+			names:   []string{"a", "b", "c"},
+		},
+		{
+			weights: map[string]uint32{"a": 1},
+			names:   []string{"a"},
+		},
+	}
+	const total = 100000
+	for _, tc := range testCases {
+		rule, err := NewRouteRuleImplBase(nil, newWeightedRouter(tc.weights, tc.names...))
+		require.Nil(t, err)
+		var totalWeight uint32
+		for _, w := range tc.weights {
+			totalWeight += w
+		}
+		counts := map[string]int{}
+		for i := 0; i < total; i++ {
+			counts[rule.ClusterName(context.Background())]++
+		}
+		require.Equal(t, 0, counts["defaultCluster"])
+		for name, w := range tc.weights {
+			expected := float64(w) / float64(totalWeight)
+			actual := float64(counts[name]) / total
+			require.InDelta(t, expected, actual, 0.01, "cluster %s, weights: %v", name, tc.weights)
+		}
+	}
+	// no weight is configured, use the cluster name
+	rule, err := NewRouteRuleImplBase(nil, newWeightedRouter(map[string]uint32{"a": 0}, "a"))
+	require.Nil(t, err)
+	require.Equal(t, "defaultCluster", rule.ClusterName(context.Background()))
+}
+
+func TestCanaryPolicy(t *testing.T) {
+	newCanaryRouter := func(canary *v2.CanaryConfig) *v2.Router {
+		r := &v2.Router{}
+		r.Route.ClusterName = "stable"
+		r.Route.Canary = canary
+		return r
+	}
+	// invalid config
+	_, err := NewRouteRuleImplBase(nil, newCanaryRouter(&v2.CanaryConfig{Percentage: 10}))
+	require.NotNil(t, err)
+	_, err = NewRouteRuleImplBase(nil, newCanaryRouter(&v2.CanaryConfig{ClusterName: "canary", Percentage: 101}))
+	require.NotNil(t, err)
+	// no canary
+	rule, err := NewRouteRuleImplBase(nil, newCanaryRouter(nil))
+	require.Nil(t, err)
+	require.Nil(t, rule.CanaryPolicy())
+
+	rule, err = NewRouteRuleImplBase(nil, newCanaryRouter(&v2.CanaryConfig{
+		ClusterName: "canary",
+		Headers: []v2.HeaderMatcher{
+			{Name: "x-canary", Value: "true"},
+		},
+		Percentage: 20,
+		HashHeader: "x-user-id",
+	}))
+	require.Nil(t, err)
+	policy := rule.CanaryPolicy()
+	require.NotNil(t, policy)
+	require.Equal(t, "canary", policy.ClusterName())
+	require.Equal(t, "x-user-id", policy.HashHeader())
+	// header forced canary
+	require.True(t, policy.MatchHeaders(context.Background(), protocol.CommonHeader{"x-canary": "true"}))
+	require.False(t, policy.MatchHeaders(context.Background(), protocol.CommonHeader{"x-canary": "false"}))
+	require.False(t, policy.MatchHeaders(context.Background(), protocol.CommonHeader{}))
+	// percentage split and stable assignment
+	const total = 100000
+	canary := 0
+	for i := 0; i < total; i++ {
+		key := strconv.Itoa(i)
+		in := policy.InPercentage(key)
+		require.Equal(t, in, policy.InPercentage(key), "key %s", key)
+		if in {
+			canary++
+		}
+	}
+	require.InDelta(t, 0.2, float64(canary)/total, 0.01)
+	// the empty key is assigned randomly
+	canary = 0
+	for i := 0; i < total; i++ {
+		if policy.InPercentage("") {
+			canary++
+		}
+	}
+	require.InDelta(t, 0.2, float64(canary)/total, 0.01)
+	// the boundaries
+	for _, tc := range []struct {
+		percentage uint32
+		expected   bool
+	}{
+		{0, false},
+		{100, true},
+	} {
+		rule, err = NewRouteRuleImplBase(nil, newCanaryRouter(&v2.CanaryConfig{
+			ClusterName: "canary",
+			Percentage:  tc.percentage,
+		}))
+		require.Nil(t, err)
+		require.False(t, rule.CanaryPolicy().MatchHeaders(context.Background(), protocol.CommonHeader{"x-canary": "true"}))
+		for i := 0; i < 100; i++ {
+			require.Equal(t, tc.expected, rule.CanaryPolicy().InPercentage(strconv.Itoa(i)))
+		}
+	}
+}
+
 type finalizeResult struct {
 	variables map[string]string // the variables should be setted
 	headers   api.HeaderMap
@@ -470,7 +609,7 @@ func Test_RouteRuleImplBase_FinalizeRequestHeaders(t *testing.T) {
 				},
 				headers: protocol.CommonHeader{
 					"host":  "xxx.default.svc.cluster.local",
-					"level": "1,2,3",
+					"level": "3,2,1",
 					"route": "true", "vhost": "true", "global": "true",
 					"remove_route_level": "", "remove_host_level": "",
 				},
@@ -528,7 +667,7 @@ func Test_RouteRuleImplBase_FinalizeRequestHeaders(t *testing.T) {
 			want: &finalizeResult{
 				variables: map[string]string{},
 				headers: protocol.CommonHeader{
-					"host": "xxx.default.svc.cluster.local", "level": "1,3", "route": "true", "global": "true",
+					"host": "xxx.default.svc.cluster.local", "level": "3,1", "route": "true", "global": "true",
 				},
 			},
 		},
@@ -591,7 +730,7 @@ func Test_RouteRuleImplBase_FinalizeRequestHeaders(t *testing.T) {
 				},
 				headers: protocol.CommonHeader{
 					"realyHost": "mosn.io.rewrited.host",
-					"level":     "1,3", "route": "true", "global": "true",
+					"level":     "3,1", "route": "true", "global": "true",
 				},
 			},
 		},
@@ -690,7 +829,7 @@ func Test_RouteRuleImplBase_FinalizeResponseHeaders(t *testing.T) {
 				headers:     protocol.CommonHeader{"status": "ready", "username": "xx", "ver": "0.1", "x-mosn": "100"},
 				requestInfo: nil,
 			},
-			want: protocol.CommonHeader{"level": "1,2,3", "route": "true", "vhost": "true", "global": "true"},
+			want: protocol.CommonHeader{"level": "3,2,1", "route": "true", "vhost": "true", "global": "true"},
 		},
 		{
 			name: "case2",
@@ -742,7 +881,7 @@ func Test_RouteRuleImplBase_FinalizeResponseHeaders(t *testing.T) {
 				headers:     protocol.CommonHeader{"status": "ready", "username": "xx", "ver": "0.1", "x-mosn": "100"},
 				requestInfo: nil,
 			},
-			want: protocol.CommonHeader{"ver": "0.1", "level": "1,3", "route": "true", "global": "true"},
+			want: protocol.CommonHeader{"ver": "0.1", "level": "3,1", "route": "true", "global": "true"},
 		},
 	}
 
@@ -1028,3 +1167,101 @@ func TestRouterClusterVariable(t *testing.T) {
 		}
 	}
 }
+
+func TestMirrorPolicyMultiTargets(t *testing.T) {
+	route := &v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName: "primary",
+				},
+			},
+			RequestMirrorPolicies: &v2.RequestMirrorPolicy{
+				Targets: []v2.RequestMirrorTarget{
+					{Cluster: "shadow1", Percent: 30},
+					{Cluster: "shadow2", Percent: 70},
+					{Cluster: "shadow3"}, // zero percent is ignored
+				},
+			},
+		},
+	}
+	base, err := NewRouteRuleImplBase(nil, route)
+	require.Nil(t, err)
+	policy := base.Policy().MirrorPolicy()
+	// the single cluster is not configured
+	assert.False(t, policy.IsMirror())
+	mp, ok := policy.(types.MultiMirrorPolicy)
+	require.True(t, ok)
+	// use a fixed seed to make the test stable
+	policy.(*mirrorImpl).rand = rand.New(rand.NewSource(1))
+
+	total := 10000
+	counts := map[string]int{}
+	both := 0
+	for i := 0; i < total; i++ {
+		clusters := mp.MirrorClusters()
+		for _, c := range clusters {
+			counts[c]++
+		}
+		if len(clusters) == 2 {
+			both++
+		}
+	}
+	assert.Equal(t, 0, counts["shadow3"])
+	assert.InDelta(t, 0.3, float64(counts["shadow1"])/float64(total), 0.03)
+	assert.InDelta(t, 0.7, float64(counts["shadow2"])/float64(total), 0.03)
+	// the targets are sampled independently
+	assert.InDelta(t, 0.21, float64(both)/float64(total), 0.03)
+
+	// the single cluster is a target as well
+	route.RequestMirrorPolicies = &v2.RequestMirrorPolicy{
+		Cluster: "shadow",
+		Percent: 100,
+		Targets: []v2.RequestMirrorTarget{
+			{Cluster: "shadow1", Percent: 100},
+		},
+	}
+	base, err = NewRouteRuleImplBase(nil, route)
+	require.Nil(t, err)
+	policy = base.Policy().MirrorPolicy()
+	assert.True(t, policy.IsMirror())
+	assert.Equal(t, "shadow", policy.ClusterName())
+	assert.Equal(t, []string{"shadow", "shadow1"}, policy.(types.MultiMirrorPolicy).MirrorClusters())
+}
+
+func TestRouteRulePerFilterConfigParser(t *testing.T) {
+	RegisterPerFilterConfigParser("test_parsed", func(cfg interface{}) (interface{}, error) {
+		s, ok := cfg.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid config: %v", cfg)
+		}
+		return "parsed_" + s, nil
+	})
+	newRouter := func(cfg interface{}) *v2.Router {
+		r := &v2.Router{}
+		r.Route.ClusterName = "test"
+		r.PerFilterConfig = map[string]interface{}{
+			"test_parsed":     cfg,
+			"test_not_parsed": "raw",
+		}
+		return r
+	}
+	// the route with invalid per filter config is rejected
+	_, err := NewRouteRuleImplBase(nil, newRouter(1))
+	require.NotNil(t, err)
+
+	rule, err := NewRouteRuleImplBase(nil, newRouter("config"))
+	require.Nil(t, err)
+	cfg, ok, err := ParsedPerFilterConfig(rule, "test_parsed")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "parsed_config", cfg)
+	// no parser registered, returns the raw config
+	cfg, ok, err = ParsedPerFilterConfig(rule, "test_not_parsed")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "raw", cfg)
+	_, ok, err = ParsedPerFilterConfig(rule, "test_absent")
+	require.Nil(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	rawjson "encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// defaultBodyMatchMaxBytes is the default max size of the request body to match
+const defaultBodyMatchMaxBytes = 64 * 1024
+
+// bodyJSON keeps the json numbers as they are, so the numbers can be matched as strings
+var bodyJSON = jsoniter.Config{UseNumber: true}.Froze()
+
+// bodyMatcherData represents a body matcher config
+type bodyMatcherData struct {
+	// keys is the parsed json path, the whole body is matched if it is empty
+	keys     []string
+	value    StringMatch
+	maxBytes int
+}
+
+// CreateBodyMatcher creates a body matcher as a types.BodyMatcher,
+// returns nil if no body matcher is configured
+func CreateBodyMatcher(matchers []v2.BodyMatcher) types.BodyMatcher {
+	if len(matchers) == 0 {
+		return nil
+	}
+	matcher := make(bodyMatcherImpl, 0, len(matchers))
+	for _, m := range matchers {
+		keys, err := parseJSONPath(m.JSONPath)
+		if err != nil {
+			log.DefaultLogger.Errorf("parse route body matcher config failed, ignore it, error: %v", err)
+			continue
+		}
+		kv, err := NewKeyValueData(v2.HeaderMatcher{
+			Value: m.Value,
+			Regex: m.Regex,
+		})
+		if err != nil {
+			continue
+		}
+		maxBytes := int(m.MaxBodyBytes)
+		if maxBytes == 0 {
+			maxBytes = defaultBodyMatchMaxBytes
+		}
+		matcher = append(matcher, &bodyMatcherData{
+			keys:     keys,
+			value:    kv.Value,
+			maxBytes: maxBytes,
+		})
+	}
+	return matcher
+}
+
+// parseJSONPath parses the json path such as "$.a.b[0]" into keys ["a", "b", "0"]
+func parseJSONPath(path string) ([]string, error) {
+	var keys []string
+	for _, part := range strings.Split(strings.TrimPrefix(path, "$"), ".") {
+		for part != "" {
+			i := strings.IndexByte(part, '[')
+			if i < 0 {
+				keys = append(keys, part)
+				break
+			}
+			if i > 0 {
+				keys = append(keys, part[:i])
+			}
+			j := strings.IndexByte(part, ']')
+			if j < i {
+				return nil, fmt.Errorf("invalid json path: %s", path)
+			}
+			index := part[i+1 : j]
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, fmt.Errorf("invalid json path index: %s", path)
+			}
+			keys = append(keys, index)
+			part = part[j+1:]
+		}
+	}
+	return keys, nil
+}
+
+// bodyMatcherImpl implements a types.BodyMatcher
+type bodyMatcherImpl []*bodyMatcherData
+
+// maxBodyBytes returns the largest size of the request body matched by the body matchers
+func (m bodyMatcherImpl) maxBodyBytes() int {
+	max := 0
+	for _, matcher := range m {
+		if matcher.maxBytes > max {
+			max = matcher.maxBytes
+		}
+	}
+	return max
+}
+
+func (m bodyMatcherImpl) Matches(ctx context.Context, body []byte) bool {
+	var (
+		doc    interface{}
+		parsed bool
+	)
+	for _, matcher := range m {
+		// the oversized body is not matched, the request falls through to the other routes
+		if len(body) > matcher.maxBytes {
+			return false
+		}
+		value := string(body)
+		if len(matcher.keys) > 0 {
+			if !parsed {
+				if err := bodyJSON.Unmarshal(body, &doc); err != nil {
+					if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+						log.DefaultLogger.Debugf(RouterLogFormat, "config utility", "match body", err)
+					}
+					return false
+				}
+				parsed = true
+			}
+			v, ok := lookupJSON(doc, matcher.keys)
+			if !ok {
+				return false
+			}
+			value = v
+		}
+		if !matcher.value.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupJSON returns the scalar value of the keys in the json document as string
+func lookupJSON(doc interface{}, keys []string) (string, bool) {
+	for _, key := range keys {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			doc = v
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			doc = node[index]
+		default:
+			return "", false
+		}
+	}
+	switch v := doc.(type) {
+	case string:
+		return v, true
+	case rawjson.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"regexp"
 	"sort"
+	"strings"
 
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -132,6 +133,27 @@ func (m commonHeaderMatcherImpl) Matches(_ context.Context, headers api.HeaderMa
 	return true
 }
 
+// headerCaptures returns the capture groups of the regex header matchers, keyed by the lower case header name
+func (m commonHeaderMatcherImpl) headerCaptures(headers api.HeaderMap) map[string][]string {
+	var captures map[string][]string
+	for _, headerData := range m {
+		if headerData.Value.RegexPattern == nil {
+			continue
+		}
+		value, exists := headers.Get(headerData.Name)
+		if !exists {
+			continue
+		}
+		if groups := headerData.Value.RegexPattern.FindStringSubmatch(value); len(groups) > 0 {
+			if captures == nil {
+				captures = make(map[string][]string, len(m))
+			}
+			captures[strings.ToLower(headerData.Name)] = groups
+		}
+	}
+	return captures
+}
+
 func CreateCommonHeaderMatcher(headers []v2.HeaderMatcher) types.HeaderMatcher {
 	hm := make(commonHeaderMatcherImpl, 0, len(headers))
 	for _, header := range headers {
@@ -173,6 +195,10 @@ func (m *httpHeaderMatcherImpl) Matches(ctx context.Context, headers api.HeaderM
 	return m.headers.Matches(ctx, headers)
 }
 
+func (m *httpHeaderMatcherImpl) headerCaptures(headers api.HeaderMap) map[string][]string {
+	return m.headers.headerCaptures(headers)
+}
+
 // CreateHTTPHeaderMatcher creates a http header matcher as a types.HeaderMatcher
 func CreateHTTPHeaderMatcher(headers []v2.HeaderMatcher) types.HeaderMatcher {
 	matcher := &httpHeaderMatcherImpl{
@@ -193,9 +219,39 @@ func CreateHTTPHeaderMatcher(headers []v2.HeaderMatcher) types.HeaderMatcher {
 
 }
 
-// TODO: support query params match
+// anyValuePattern matches any value of a present query parameter
+var anyValuePattern = regexp.MustCompile(".*")
+
+// CreateQueryParameterMatcher creates a query parameter matcher as a types.QueryParameterMatcher,
+// returns nil if no query parameter is configured
+func CreateQueryParameterMatcher(params []v2.QueryParameterMatcher) types.QueryParameterMatcher {
+	if len(params) == 0 {
+		return nil
+	}
+	matcher := make(queryParameterMatcherImpl, 0, len(params))
+	for _, param := range params {
+		if param.Present {
+			matcher = append(matcher, &KeyValueData{
+				Name: param.Name,
+				Value: StringMatch{
+					IsRegex:      true,
+					RegexPattern: anyValuePattern,
+				},
+			})
+			continue
+		}
+		if kv, err := NewKeyValueData(v2.HeaderMatcher{
+			Name:  param.Name,
+			Value: param.Value,
+			Regex: param.Regex,
+		}); err == nil {
+			matcher = append(matcher, kv)
+		}
+	}
+	return matcher
+}
+
 // queryParameterMatcherImpl implements a types.QueryParamsMatcher
-// nolint: unused
 type queryParameterMatcherImpl []*KeyValueData
 
 func (m queryParameterMatcherImpl) Matches(ctx context.Context, queryParams types.QueryParams) bool {
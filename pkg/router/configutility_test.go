@@ -323,7 +323,6 @@ func TestHTTPHeaderMatch(t *testing.T) {
 	})
 }
 
-// TODO: support query match in routers
 func TestMatchQueryParams(t *testing.T) {
 	qpm := queryParameterMatcherImpl{}
 	configs := []v2.HeaderMatcher{
@@ -18,8 +18,9 @@
 package router
 
 type directResponseImpl struct {
-	status int
-	body   string
+	status  int
+	body    string
+	headers map[string]string
 }
 
 func (rule *directResponseImpl) StatusCode() int {
@@ -29,3 +30,7 @@ func (rule *directResponseImpl) StatusCode() int {
 func (rule *directResponseImpl) Body() string {
 	return rule.body
 }
+
+func (rule *directResponseImpl) ResponseHeaders() map[string]string {
+	return rule.headers
+}
@@ -23,6 +23,7 @@ import (
 
 	jsoniter "github.com/json-iterator/go"
 	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -37,7 +38,10 @@ func TestDirectResponse(t *testing.T) {
 		},
 		"direct_response": {
 			"status": 500,
-			"body": "test"
+			"body": "test",
+			"headers": {
+				"content-type": "text/plain"
+			}
 		}
 	}`
 	routeCfg := &v2.Router{}
@@ -52,6 +56,9 @@ func TestDirectResponse(t *testing.T) {
 	if dr.StatusCode() != 500 || dr.Body() != "test" {
 		t.Error("direct response rule is not exepcted")
 	}
+	if hr, ok := dr.(types.DirectResponseHeadersRule); !ok || hr.ResponseHeaders()["content-type"] != "text/plain" {
+		t.Error("direct response headers is not exepcted")
+	}
 	// Test No Direct response by default
 	noDirectCfgStr := `{
 		"match": {
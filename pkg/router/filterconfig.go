@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"fmt"
+	"sync"
+
+	"mosn.io/api"
+
+	"mosn.io/mosn/pkg/log"
+)
+
+// PerFilterConfigParser parses the per filter config of a route into the form used by the stream filter
+type PerFilterConfigParser func(cfg interface{}) (interface{}, error)
+
+var perFilterConfigParsers = struct {
+	mutex   sync.RWMutex
+	parsers map[string]PerFilterConfigParser
+}{
+	parsers: map[string]PerFilterConfigParser{},
+}
+
+// RegisterPerFilterConfigParser registers the parser of the per filter config named name,
+// the per filter configs are parsed once when the route is created, the route is rejected if the config is invalid.
+func RegisterPerFilterConfigParser(name string, parser PerFilterConfigParser) {
+	log.DefaultLogger.Infof("register a new per filter config parser, name is %s", name)
+	perFilterConfigParsers.mutex.Lock()
+	defer perFilterConfigParsers.mutex.Unlock()
+	perFilterConfigParsers.parsers[name] = parser
+}
+
+func getPerFilterConfigParser(name string) PerFilterConfigParser {
+	perFilterConfigParsers.mutex.RLock()
+	defer perFilterConfigParsers.mutex.RUnlock()
+	return perFilterConfigParsers.parsers[name]
+}
+
+// parsePerFilterConfig parses the per filter configs which have registered parsers
+func parsePerFilterConfig(configs map[string]interface{}) (map[string]interface{}, error) {
+	var parsed map[string]interface{}
+	for name, cfg := range configs {
+		parser := getPerFilterConfigParser(name)
+		if parser == nil {
+			continue
+		}
+		v, err := parser(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid per filter config of %s: %v", name, err)
+		}
+		if parsed == nil {
+			parsed = make(map[string]interface{}, len(configs))
+		}
+		parsed[name] = v
+	}
+	return parsed, nil
+}
+
+// ParsedPerFilterConfig returns the per filter config named name of the route rule parsed by the registered parser.
+// the config is parsed once by the routes created by this package, and parsed on each call by other routes.
+// ok is false if the route has no config of the filter.
+func ParsedPerFilterConfig(rule api.RouteRule, name string) (cfg interface{}, ok bool, err error) {
+	if rule == nil {
+		return nil, false, nil
+	}
+	if r, isParsed := rule.(interface {
+		ParsedPerFilterConfig(name string) (interface{}, bool)
+	}); isParsed {
+		if cfg, ok = r.ParsedPerFilterConfig(name); ok {
+			return cfg, true, nil
+		}
+	}
+	raw, ok := rule.PerFilterConfig()[name]
+	if !ok {
+		return nil, false, nil
+	}
+	parser := getPerFilterConfigParser(name)
+	if parser == nil {
+		return raw, true, nil
+	}
+	cfg, err = parser(raw)
+	return cfg, true, err
+}
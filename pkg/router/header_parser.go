@@ -20,7 +20,10 @@ package router
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
+	"mosn.io/api"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -44,4 +47,74 @@ func (h *headerParser) evaluateHeaders(ctx context.Context, headers types.Header
 	for _, toRemove := range h.headersToRemove {
 		headers.Del(toRemove)
 	}
+	if len(h.headersToRemove) > 0 {
+		removeHeadersIgnoreCase(headers, h.headersToRemove)
+	}
+}
+
+// removeHeadersIgnoreCase removes the headers whose keys are not in lower case,
+// the header map may be case sensitive, such as protocol.CommonHeader
+func removeHeadersIgnoreCase(headers types.HeaderMap, headersToRemove []string) {
+	var keys []string
+	headers.Range(func(key, value string) bool {
+		for _, toRemove := range headersToRemove {
+			if key != toRemove && strings.EqualFold(key, toRemove) {
+				keys = append(keys, key)
+				break
+			}
+		}
+		return true
+	})
+	for _, key := range keys {
+		headers.Del(key)
+	}
+}
+
+// clusterHeaderParsers is the parsed headers manipulation of a cluster,
+// it is rebuilt if the cluster is updated.
+type clusterHeaderParsers struct {
+	info                  types.ClusterInfo
+	requestHeadersParser  *headerParser
+	responseHeadersParser *headerParser
+}
+
+// clusterHeaderParsersCache stores the cluster name and the *clusterHeaderParsers
+var clusterHeaderParsersCache sync.Map
+
+func getClusterHeaderParsers(info types.ClusterInfo) *clusterHeaderParsers {
+	if info == nil {
+		return nil
+	}
+	getter, ok := info.(types.ClusterHeadersGetter)
+	if !ok {
+		return nil
+	}
+	if v, ok := clusterHeaderParsersCache.Load(info.Name()); ok {
+		if parsers := v.(*clusterHeaderParsers); parsers.info == info {
+			return parsers
+		}
+	}
+	parsers := &clusterHeaderParsers{
+		info:                  info,
+		requestHeadersParser:  getHeaderParser(getter.RequestHeadersToAdd(), getter.RequestHeadersToRemove()),
+		responseHeadersParser: getHeaderParser(getter.ResponseHeadersToAdd(), getter.ResponseHeadersToRemove()),
+	}
+	clusterHeaderParsersCache.Store(info.Name(), parsers)
+	return parsers
+}
+
+// FinalizeClusterRequestHeaders manipulates the request headers by the cluster config.
+// It should be called before the route's FinalizeRequestHeaders, so the route and the virtual host take precedence.
+func FinalizeClusterRequestHeaders(ctx context.Context, headers api.HeaderMap, info types.ClusterInfo) {
+	if parsers := getClusterHeaderParsers(info); parsers != nil {
+		parsers.requestHeadersParser.evaluateHeaders(ctx, headers)
+	}
+}
+
+// FinalizeClusterResponseHeaders manipulates the response headers by the cluster config.
+// It should be called before the route's FinalizeResponseHeaders, so the route and the virtual host take precedence.
+func FinalizeClusterResponseHeaders(ctx context.Context, headers api.HeaderMap, info types.ClusterInfo) {
+	if parsers := getClusterHeaderParsers(info); parsers != nil {
+		parsers.responseHeadersParser.evaluateHeaders(ctx, headers)
+	}
 }
@@ -19,18 +19,31 @@ package router
 
 import (
 	"context"
-	"mosn.io/pkg/variable"
+	"strconv"
 	"strings"
+
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/types"
 )
 
 func getHeaderFormatter(value string, append bool) headerFormatter {
+	if template := parseCaptureTemplate(value); template != nil {
+		return &captureHeaderFormatter{
+			isAppend: append,
+			template: template,
+		}
+	}
 	if len(value) > 2 && strings.HasPrefix(value, "%") && strings.HasSuffix(value, "%") {
 		variableName := strings.Trim(value, "%")
 		// todo cache the variable so we don't need to find it in format method
-		if _, err := variable.Check(variableName); err == nil {
-			return &variableHeaderFormatter{
-				isAppend:     append,
-				variableName: variableName,
+		// the upper case name such as %UPSTREAM_HOST% references the variable upstream_host
+		for _, name := range []string{variableName, strings.ToLower(variableName)} {
+			if _, err := variable.Check(name); err == nil {
+				return &variableHeaderFormatter{
+					isAppend:     append,
+					variableName: name,
+				}
 			}
 		}
 	}
@@ -69,3 +82,94 @@ func (v *variableHeaderFormatter) format(ctx context.Context) string {
 func (v *variableHeaderFormatter) append() bool {
 	return v.isAppend
 }
+
+type captureHeaderFormatter struct {
+	isAppend bool
+	template *captureTemplate
+}
+
+func (c *captureHeaderFormatter) format(ctx context.Context) string {
+	return c.template.render(ctx)
+}
+
+func (c *captureHeaderFormatter) append() bool {
+	return c.isAppend
+}
+
+const (
+	headerCapturePrefix = "%HEADER_CAPTURE("
+	headerCaptureSuffix = ")%"
+)
+
+// captureTemplate is a string which references the capture groups of the route header matchers,
+// such as "/tenants/%HEADER_CAPTURE(host,1)%/"
+type captureTemplate struct {
+	segments []captureSegment
+}
+
+// captureSegment is a static text, or a reference to a capture group if the header is not empty
+type captureSegment struct {
+	text   string
+	header string
+	index  int
+}
+
+// parseCaptureTemplate returns nil if the value does not reference any capture group
+func parseCaptureTemplate(value string) *captureTemplate {
+	if !strings.Contains(value, headerCapturePrefix) {
+		return nil
+	}
+	t := &captureTemplate{}
+	for len(value) > 0 {
+		start := strings.Index(value, headerCapturePrefix)
+		if start < 0 {
+			t.segments = append(t.segments, captureSegment{text: value})
+			break
+		}
+		end := strings.Index(value[start:], headerCaptureSuffix)
+		if end < 0 {
+			t.segments = append(t.segments, captureSegment{text: value})
+			break
+		}
+		end += start
+		if start > 0 {
+			t.segments = append(t.segments, captureSegment{text: value[:start]})
+		}
+		ref := value[start+len(headerCapturePrefix) : end]
+		// the reference is "header,index"
+		if i := strings.LastIndexByte(ref, ','); i > 0 {
+			if index, err := strconv.Atoi(strings.TrimSpace(ref[i+1:])); err == nil && index >= 0 {
+				t.segments = append(t.segments, captureSegment{
+					header: strings.ToLower(strings.TrimSpace(ref[:i])),
+					index:  index,
+				})
+				value = value[end+len(headerCaptureSuffix):]
+				continue
+			}
+		}
+		// invalid reference is kept as static text
+		t.segments = append(t.segments, captureSegment{text: value[start : end+len(headerCaptureSuffix)]})
+		value = value[end+len(headerCaptureSuffix):]
+	}
+	return t
+}
+
+// render replaces the references with the capture groups stored in the context,
+// the missing capture group is replaced with an empty string
+func (t *captureTemplate) render(ctx context.Context) string {
+	var captures map[string][]string
+	if v, err := variable.Get(ctx, types.VarRouterHeaderCaptures); err == nil && v != nil {
+		captures, _ = v.(map[string][]string)
+	}
+	var b strings.Builder
+	for _, seg := range t.segments {
+		if seg.header == "" {
+			b.WriteString(seg.text)
+			continue
+		}
+		if groups, ok := captures[seg.header]; ok && seg.index < len(groups) {
+			b.WriteString(groups[seg.index])
+		}
+	}
+	return b.String()
+}
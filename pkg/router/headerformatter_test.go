@@ -80,6 +80,17 @@ func Test_getHeaderFormatter(t *testing.T) {
 				staticValue: "%address",
 			},
 		},
+		{
+			name: "upper case variable name",
+			args: args{
+				value:  "%ADDRESS%",
+				append: true,
+			},
+			want: &variableHeaderFormatter{
+				isAppend:     true,
+				variableName: "address",
+			},
+		},
 		{
 			name: "case5",
 			args: args{
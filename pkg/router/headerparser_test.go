@@ -22,8 +22,10 @@ import (
 	"reflect"
 	"testing"
 
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
 )
 
 func Test_headerParser_evaluateHeaders(t *testing.T) {
@@ -67,3 +69,128 @@ func Test_headerParser_evaluateHeaders(t *testing.T) {
 		})
 	}
 }
+
+func Test_headerParser_RemoveIgnoreCase(t *testing.T) {
+	parser := getHeaderParser(nil, []string{"X-Remove"})
+	headers := protocol.CommonHeader{"X-Remove": "1", "x-remove": "2", "X-REMOVE": "3", "x-keep": "4"}
+	parser.evaluateHeaders(context.Background(), headers)
+	want := protocol.CommonHeader{"x-keep": "4"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("remove headers ignore case, got %v, want %v", headers, want)
+	}
+}
+
+// mockHeadersClusterInfo is a cluster info which manipulates the headers
+type mockHeadersClusterInfo struct {
+	types.ClusterInfo
+	name                    string
+	requestHeadersToAdd     []*v2.HeaderValueOption
+	requestHeadersToRemove  []string
+	responseHeadersToAdd    []*v2.HeaderValueOption
+	responseHeadersToRemove []string
+}
+
+func (ci *mockHeadersClusterInfo) Name() string {
+	return ci.name
+}
+
+func (ci *mockHeadersClusterInfo) RequestHeadersToAdd() []*v2.HeaderValueOption {
+	return ci.requestHeadersToAdd
+}
+
+func (ci *mockHeadersClusterInfo) RequestHeadersToRemove() []string {
+	return ci.requestHeadersToRemove
+}
+
+func (ci *mockHeadersClusterInfo) ResponseHeadersToAdd() []*v2.HeaderValueOption {
+	return ci.responseHeadersToAdd
+}
+
+func (ci *mockHeadersClusterInfo) ResponseHeadersToRemove() []string {
+	return ci.responseHeadersToRemove
+}
+
+func headerOption(key, value string, isAppend bool) *v2.HeaderValueOption {
+	return &v2.HeaderValueOption{
+		Header: &v2.HeaderValue{
+			Key:   key,
+			Value: value,
+		},
+		Append: &isAppend,
+	}
+}
+
+func TestHeadersPrecedence(t *testing.T) {
+	_ = variable.Register(variable.NewVariable("upstream_host", nil, nil, variable.DefaultSetter, 0))
+	ci := &mockHeadersClusterInfo{
+		name: "precedence_cluster",
+		requestHeadersToAdd: []*v2.HeaderValueOption{
+			headerOption("x-overwrite", "cluster", false),
+			headerOption("x-append", "cluster", true),
+			headerOption("x-cluster-only", "cluster", false),
+			headerOption("X-Upstream", "%UPSTREAM_HOST%", false),
+			headerOption("x-removed-by-route", "cluster", false),
+		},
+		requestHeadersToRemove: []string{"x-added-by-route"},
+		responseHeadersToAdd: []*v2.HeaderValueOption{
+			headerOption("x-overwrite", "cluster", false),
+		},
+	}
+	vh := &VirtualHostImpl{
+		requestHeadersParser: getHeaderParser([]*v2.HeaderValueOption{
+			headerOption("x-overwrite", "vhost", false),
+			headerOption("x-append", "vhost", true),
+			headerOption("x-vhost-only", "vhost", false),
+		}, nil),
+		responseHeadersParser: getHeaderParser([]*v2.HeaderValueOption{
+			headerOption("x-overwrite", "vhost", false),
+		}, nil),
+		globalRouteConfig: &configImpl{},
+	}
+	rri := &RouteRuleImplBase{
+		vHost: vh,
+		requestHeadersParser: getHeaderParser([]*v2.HeaderValueOption{
+			headerOption("x-overwrite", "route", false),
+			headerOption("x-append", "route", true),
+			headerOption("x-added-by-route", "route", false),
+		}, []string{"X-Removed-By-Route"}),
+	}
+
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, "upstream_host", "127.0.0.1:8080")
+	headers := protocol.CommonHeader{"x-append": "client"}
+	FinalizeClusterRequestHeaders(ctx, headers, ci)
+	rri.FinalizeRequestHeaders(ctx, headers, nil)
+	want := protocol.CommonHeader{
+		"x-overwrite":      "route",
+		"x-append":         "client,cluster,vhost,route",
+		"x-cluster-only":   "cluster",
+		"x-vhost-only":     "vhost",
+		"x-upstream":       "127.0.0.1:8080",
+		"x-added-by-route": "route",
+	}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("request headers got %v, want %v", headers, want)
+	}
+
+	respHeaders := protocol.CommonHeader{}
+	FinalizeClusterResponseHeaders(ctx, respHeaders, ci)
+	if v, _ := respHeaders.Get("x-overwrite"); v != "cluster" {
+		t.Errorf("cluster response header got %s", v)
+	}
+	rri.FinalizeResponseHeaders(ctx, respHeaders, nil)
+	if v, _ := respHeaders.Get("x-overwrite"); v != "vhost" {
+		t.Errorf("vhost response header should take precedence, got %s", v)
+	}
+
+	// the parsers are cached until the cluster is updated
+	parsers := getClusterHeaderParsers(ci)
+	if getClusterHeaderParsers(ci) != parsers {
+		t.Error("cluster header parsers should be cached")
+	}
+	updated := &mockHeadersClusterInfo{name: "precedence_cluster"}
+	if p := getClusterHeaderParsers(updated); p == parsers || p.requestHeadersParser != nil {
+		t.Error("cluster header parsers should be rebuilt after the cluster is updated")
+	}
+	FinalizeClusterRequestHeaders(ctx, headers, nil)
+}
@@ -27,20 +27,27 @@ import (
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol/http"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/variable"
 )
 
 type BaseHTTPRouteRule struct {
 	*RouteRuleImplBase
 	configHeaders         types.HeaderMatcher
-	configQueryParameters types.QueryParameterMatcher //TODO: not implement yet
+	configQueryParameters types.QueryParameterMatcher
+	configBody            types.BodyMatcher
 }
 
 func NewBaseHTTPRouteRule(base *RouteRuleImplBase, headers []v2.HeaderMatcher) *BaseHTTPRouteRule {
-	return &BaseHTTPRouteRule{
+	rule := &BaseHTTPRouteRule{
 		RouteRuleImplBase: base,
 		configHeaders:     CreateHTTPHeaderMatcher(headers),
 	}
+	if base != nil {
+		rule.configQueryParameters = CreateQueryParameterMatcher(base.routerMatch.QueryParameters)
+		rule.configBody = CreateBodyMatcher(base.routerMatch.Body)
+	}
+	return rule
 }
 
 func (rri *BaseHTTPRouteRule) HeaderMatchCriteria() api.KeyValueMatchCriteria {
@@ -65,18 +72,62 @@ func (rri *BaseHTTPRouteRule) matchRoute(ctx context.Context, headers api.Header
 		if err == nil && QueryString != "" {
 			queryParams = http.ParseQueryString(QueryString)
 		}
-		if len(queryParams) != 0 {
-			if !rri.configQueryParameters.Matches(ctx, queryParams) {
-				if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
-					log.DefaultLogger.Debugf(RouterLogFormat, "routerule", "match query params", queryParams)
-				}
-				return false
+		// the request without query string does not match the configured query parameters
+		if !rri.configQueryParameters.Matches(ctx, queryParams) {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf(RouterLogFormat, "routerule", "match query params", queryParams)
 			}
+			return false
+		}
+	}
+	// 3. match body, the body is buffered by the proxy before the route is matched,
+	// the body is not buffered if it is larger than the body matchers accept.
+	if rri.configBody != nil {
+		var body []byte
+		if v, err := variable.Get(ctx, types.VarRouterRequestBody); err == nil && v != nil {
+			if buf, ok := v.(buffer.IoBuffer); ok && buf != nil {
+				body = buf.Bytes()
+			}
+		}
+		if body == nil {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf(RouterLogFormat, "routerule", "match body", "no request body")
+			}
+			return false
+		}
+		if !rri.configBody.Matches(ctx, body) {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf(RouterLogFormat, "routerule", "match body", len(body))
+			}
+			return false
 		}
 	}
 	return true
 }
 
+// bodyMatchBytes returns the largest size of the request body matched by the rule
+func (rri *BaseHTTPRouteRule) bodyMatchBytes() int {
+	if m, ok := rri.configBody.(bodyMatcherImpl); ok {
+		return m.maxBodyBytes()
+	}
+	return 0
+}
+
+// headerCapturer is implemented by the header matcher which captures the regex groups of the headers
+type headerCapturer interface {
+	headerCaptures(headers api.HeaderMap) map[string][]string
+}
+
+// storeHeaderCaptures stores the capture groups of the regex header matchers in the context after the route is matched,
+// so the prefix rewrite and the added headers can reference them by "%HEADER_CAPTURE(header,index)%"
+func (rri *BaseHTTPRouteRule) storeHeaderCaptures(ctx context.Context, headers api.HeaderMap) {
+	if capturer, ok := rri.configHeaders.(headerCapturer); ok {
+		if captures := capturer.headerCaptures(headers); captures != nil {
+			_ = variable.Set(ctx, types.VarRouterHeaderCaptures, captures)
+		}
+	}
+}
+
 type PathRouteRuleImpl struct {
 	*BaseHTTPRouteRule
 	path string
@@ -113,6 +164,7 @@ func (prri *PathRouteRuleImpl) Match(ctx context.Context, headers api.HeaderMap)
 			// TODO: config to support case sensitive
 			// case insensitive
 			if strings.EqualFold(headerPathValue, prri.path) {
+				prri.storeHeaderCaptures(ctx, headers)
 				return prri
 			}
 		}
@@ -158,6 +210,7 @@ func (prei *PrefixRouteRuleImpl) Match(ctx context.Context, headers api.HeaderMa
 		headerPathValue, err := variable.GetString(ctx, types.VarPath)
 		if err == nil && headerPathValue != "" {
 			if strings.HasPrefix(headerPathValue, prei.prefix) {
+				prei.storeHeaderCaptures(ctx, headers)
 				return prei
 			}
 		}
@@ -201,6 +254,7 @@ func (rrei *RegexRouteRuleImpl) Match(ctx context.Context, headers api.HeaderMap
 		headerPathValue, err := variable.GetString(ctx, types.VarPath)
 		if err == nil && headerPathValue != "" {
 			if rrei.regexPattern.MatchString(headerPathValue) {
+				rrei.storeHeaderCaptures(ctx, headers)
 				return rrei
 			}
 		}
@@ -215,3 +215,73 @@ func TestRegexRouteRuleImpl(t *testing.T) {
 		}
 	}
 }
+
+func TestHeaderCaptureRewrite(t *testing.T) {
+	vh, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Name:    "test",
+		Domains: []string{"*"},
+		Routers: []v2.Router{
+			{
+				RouterConfig: v2.RouterConfig{
+					Match: v2.RouterMatch{
+						Prefix: "/api",
+						Headers: []v2.HeaderMatcher{
+							{
+								Name:  "host",
+								Value: `^(\w+)\.example\.com$`,
+								Regex: true,
+							},
+						},
+					},
+					Route: v2.RouteAction{
+						RouterActionConfig: v2.RouterActionConfig{
+							ClusterName:   "test",
+							PrefixRewrite: "/tenants/%HEADER_CAPTURE(host,1)%",
+							RequestHeadersToAdd: []*v2.HeaderValueOption{
+								{
+									Header: &v2.HeaderValue{
+										Key:   "x-tenant",
+										Value: "%HEADER_CAPTURE(host,1)%",
+									},
+								},
+								{
+									Header: &v2.HeaderValue{
+										Key:   "x-missing",
+										Value: "tenant-%HEADER_CAPTURE(host,2)%",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	vh.globalRouteConfig = &configImpl{}
+
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarPath, "/api/users")
+	// the header does not match the regex
+	headers := protocol.CommonHeader(map[string]string{"host": "example.com"})
+	assert.Nil(t, vh.GetRouteFromEntries(ctx, headers))
+
+	headers = protocol.CommonHeader(map[string]string{"host": "foo.example.com"})
+	route := vh.GetRouteFromEntries(ctx, headers)
+	if !assert.NotNil(t, route) {
+		t.FailNow()
+	}
+	route.RouteRule().FinalizeRequestHeaders(ctx, headers, nil)
+
+	path, err := variable.GetString(ctx, types.VarPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "/tenants/foo/users", path)
+	tenant, _ := headers.Get("x-tenant")
+	assert.Equal(t, "foo", tenant)
+	missing, _ := headers.Get("x-missing")
+	assert.Equal(t, "tenant-", missing)
+	originalPath, _ := headers.Get(types.HeaderOriginalPath)
+	assert.Equal(t, "/api/users", originalPath)
+}
@@ -89,6 +89,24 @@ func TestRedirectResponse(t *testing.T) {
 				scheme: "https",
 			},
 		},
+		{
+			name: "https redirect",
+			redirectAction: &v2.RedirectAction{
+				HttpsRedirect: true,
+			},
+			expectedRule: &redirectImpl{
+				code:   http.StatusMovedPermanently,
+				scheme: "https",
+			},
+		},
+		{
+			name: "https redirect conflicts with scheme redirect",
+			redirectAction: &v2.RedirectAction{
+				HttpsRedirect:  true,
+				SchemeRedirect: "http",
+			},
+			expectError: true,
+		},
 	}
 
 	match := v2.RouterMatch{
@@ -88,6 +88,14 @@ func (ri *routersImpl) MatchRoute(ctx context.Context, headers api.HeaderMap) ap
 	return router
 }
 
+// MaxRequestBodyMatchBytes implements types.RequestBodyRouters
+func (ri *routersImpl) MaxRequestBodyMatchBytes(ctx context.Context) uint64 {
+	if vh, ok := ri.findVirtualHost(ctx).(*VirtualHostImpl); ok && vh != nil {
+		return uint64(vh.MaxRequestBodyMatchBytes())
+	}
+	return 0
+}
+
 func (ri *routersImpl) MatchAllRoutes(ctx context.Context, headers api.HeaderMap) []api.Route {
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 		log.DefaultLogger.Debugf(RouterLogFormat, "routers", "MatchAllRoutes", headers)
@@ -176,6 +176,100 @@ func (rm *routersManagerImpl) RemoveAllRoutes(routerConfigName, domain string) e
 	return nil
 }
 
+var _ types.RouteUpdater = (*routersManagerImpl)(nil)
+
+// SetRoute replaces the route at the index of the virtual host, a negative index appends the route.
+// the routers are rebuilt by a copy of the config and swapped atomically, so the in-flight matches
+// still use the previous routers.
+func (rm *routersManagerImpl) SetRoute(routerConfigName, virtualHost string, index int, route *v2.Router) error {
+	if err := validateRoute(route); err != nil {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", "SetRoute", err)
+		return err
+	}
+	return rm.updateRoutes(routerConfigName, virtualHost, "SetRoute", func(routes []v2.Router) ([]v2.Router, error) {
+		if index < 0 {
+			return append(routes, *route), nil
+		}
+		if index >= len(routes) {
+			return nil, ErrRouteIndexOutOfRange
+		}
+		routes[index] = *route
+		return routes, nil
+	})
+}
+
+// DeleteRoute removes the route at the index of the virtual host
+func (rm *routersManagerImpl) DeleteRoute(routerConfigName, virtualHost string, index int) error {
+	return rm.updateRoutes(routerConfigName, virtualHost, "DeleteRoute", func(routes []v2.Router) ([]v2.Router, error) {
+		if index < 0 || index >= len(routes) {
+			return nil, ErrRouteIndexOutOfRange
+		}
+		return append(routes[:index], routes[index+1:]...), nil
+	})
+}
+
+// updateRoutes applies the change to a copy of the virtual host routes, and swaps the routers
+// built by the new config. the virtual host is found by name, an empty name matches the only virtual host.
+func (rm *routersManagerImpl) updateRoutes(routerConfigName, virtualHost, action string, change func([]v2.Router) ([]v2.Router, error)) error {
+	v, ok := rm.routersWrapperMap.Load(routerConfigName)
+	if !ok {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", action, "error: "+ErrNoRouterConfig.Error()+": "+routerConfigName)
+		return ErrNoRouterConfig
+	}
+	rw, ok := v.(*RoutersWrapper)
+	if !ok {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", action, "unexpected object in routers map")
+		return ErrUnexpected
+	}
+	rw.mux.Lock()
+	defer rw.mux.Unlock()
+	cfg := *rw.routersConfig
+	index := -1
+	for i := range cfg.VirtualHosts {
+		if cfg.VirtualHosts[i].Name == virtualHost || (virtualHost == "" && len(cfg.VirtualHosts) == 1) {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", action, "no virtual host found: "+virtualHost)
+		return ErrNoVirtualHost
+	}
+	// make new slices to avoid the config referenced by the previous routers is changed
+	cfg.VirtualHosts = append([]v2.VirtualHost(nil), cfg.VirtualHosts...)
+	routes, err := change(append([]v2.Router(nil), cfg.VirtualHosts[index].Routers...))
+	if err != nil {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", action, err)
+		return err
+	}
+	cfg.VirtualHosts[index].Routers = routes
+	routers, err := NewRouters(&cfg)
+	if err != nil {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", action, err)
+		return fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	rw.routers = routers
+	rw.routersConfig = &cfg
+	configmanager.SetRouter(cfg)
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof(RouterLogFormat, "routers_manager", action, fmt.Sprintf("update routes of router: %s, virtual host: %s", routerConfigName, cfg.VirtualHosts[index].Name))
+	}
+	return nil
+}
+
+// validateRoute checks the route has an action
+func validateRoute(route *v2.Router) error {
+	if route == nil {
+		return fmt.Errorf("%w: route is nil", ErrInvalidRoute)
+	}
+	action := route.Route
+	if action.ClusterName == "" && action.ClusterVariable == "" && action.ClusterHeader == "" &&
+		len(action.WeightedClusters) == 0 && route.Redirect == nil && route.DirectResponse == nil {
+		return fmt.Errorf("%w: no cluster, redirect or direct response is configured", ErrInvalidRoute)
+	}
+	return nil
+}
+
 var (
 	singletonMutex         sync.Mutex
 	routersManagerInstance *routersManagerImpl
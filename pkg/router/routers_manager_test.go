@@ -25,6 +25,7 @@ package router
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -455,3 +456,106 @@ func Test_routersManager_RemoveAllRouter(t *testing.T) {
 		t.Fatal("remove route, but still can matched")
 	}
 }
+
+func Test_routersManager_SetRoute(t *testing.T) {
+	routerManager := NewRouterManager()
+	updater := routerManager.(types.RouteUpdater)
+	routerCfg := &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "test_setroute",
+		},
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "test_setroute_vh",
+				Domains: []string{"*"},
+			},
+		},
+	}
+	if err := routerManager.AddOrUpdateRouters(routerCfg); err != nil {
+		t.Fatal("init router config failed")
+	}
+	rw := routerManager.GetRouterWrapperByName("test_setroute")
+	newRoute := func(value, cluster string) *v2.Router {
+		return &v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Match: v2.RouterMatch{
+					Headers: []v2.HeaderMatcher{
+						{
+							Name:  "service",
+							Value: value,
+						},
+					},
+				},
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{
+						ClusterName: cluster,
+					},
+				},
+			},
+		}
+	}
+	ctx := variable.NewVariableContext(context.Background())
+	match := func(value string) string {
+		r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", value)
+		if r == nil {
+			return ""
+		}
+		return r.RouteRule().ClusterName(ctx)
+	}
+	// add a route
+	before := rw.GetRouters()
+	if err := updater.SetRoute("test_setroute", "", -1, newRoute("test", "cluster1")); err != nil {
+		t.Fatal("set route failed", err)
+	}
+	if c := match("test"); c != "cluster1" {
+		t.Fatalf("added route is not matched: %s", c)
+	}
+	// the previous routers is not changed
+	if before != nil && before.MatchRouteFromHeaderKV(ctx, nil, "service", "test") != nil {
+		t.Fatal("previous routers is changed")
+	}
+	// update the route
+	if err := updater.SetRoute("test_setroute", "test_setroute_vh", 0, newRoute("test", "cluster2")); err != nil {
+		t.Fatal("set route failed", err)
+	}
+	if c := match("test"); c != "cluster2" {
+		t.Fatalf("updated route is not matched: %s", c)
+	}
+	cfg := rw.GetRoutersConfig()
+	if len(cfg.VirtualHosts[0].Routers) != 1 || cfg.VirtualHosts[0].Routers[0].Route.ClusterName != "cluster2" {
+		t.Fatalf("route config is not changed: %+v", cfg.VirtualHosts[0].Routers)
+	}
+	// invalid routes are rejected, and the routers are not changed
+	invalid := newRoute("test", "cluster3")
+	invalid.Match = v2.RouterMatch{Regex: "("}
+	for _, tc := range []struct {
+		name        string
+		virtualHost string
+		index       int
+		route       *v2.Router
+		err         error
+	}{
+		{name: "test_setroute", index: -1, route: newRoute("test", ""), err: ErrInvalidRoute},
+		{name: "test_setroute", index: -1, route: invalid, err: ErrInvalidRoute},
+		{name: "test_setroute", index: 1, route: newRoute("test", "cluster3"), err: ErrRouteIndexOutOfRange},
+		{name: "test_setroute", virtualHost: "unknown", index: -1, route: newRoute("test", "cluster3"), err: ErrNoVirtualHost},
+		{name: "unknown", index: -1, route: newRoute("test", "cluster3"), err: ErrNoRouterConfig},
+	} {
+		if err := updater.SetRoute(tc.name, tc.virtualHost, tc.index, tc.route); !errors.Is(err, tc.err) {
+			t.Fatalf("expected error %v, but got %v", tc.err, err)
+		}
+	}
+	if c := match("test"); c != "cluster2" {
+		t.Fatalf("routers is changed by invalid route: %s", c)
+	}
+	// delete the route
+	if err := updater.DeleteRoute("test_setroute", "", 1); err != ErrRouteIndexOutOfRange {
+		t.Fatalf("expected error %v, but got %v", ErrRouteIndexOutOfRange, err)
+	}
+	if err := updater.DeleteRoute("test_setroute", "", 0); err != nil {
+		t.Fatal("delete route failed", err)
+	}
+	if c := match("test"); c != "" {
+		t.Fatalf("deleted route is still matched: %s", c)
+	}
+}
@@ -19,12 +19,16 @@ package router
 
 import (
 	"context"
+	"encoding/binary"
 	"testing"
 
+	hessian "github.com/apache/dubbo-go-hessian2"
 	"github.com/golang/mock/gomock"
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
 )
 
 func TestRPCRouteRuleSimple(t *testing.T) {
@@ -97,3 +101,57 @@ func TestSofaRouteRuleHeaderMatch(t *testing.T) {
 	}
 
 }
+
+func TestDubboAttachmentRoute(t *testing.T) {
+	newRouter := func(cluster string, headers ...v2.HeaderMatcher) v2.Router {
+		return v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Match: v2.RouterMatch{Headers: headers},
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{ClusterName: cluster},
+				},
+			},
+		}
+	}
+	service := v2.HeaderMatcher{Name: dubbo.ServiceNameHeader, Value: "com.foo.Greeter"}
+	vh, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Domains: []string{"*"},
+		Routers: []v2.Router{
+			newRouter("gray", service, v2.HeaderMatcher{Name: dubbo.AttachmentHeaderPrefix + "group", Value: "gray"}),
+			newRouter("v2", service, v2.HeaderMatcher{Name: dubbo.AttachmentHeaderPrefix + "version", Value: "2.0.0"}),
+			newRouter("default", service),
+		},
+	})
+	if err != nil {
+		t.Fatalf("create virtual host failed: %v", err)
+	}
+	for _, tc := range []struct {
+		attachments map[string]string
+		cluster     string
+	}{
+		{map[string]string{"group": "gray", "version": "2.0.0"}, "gray"},
+		{map[string]string{"version": "2.0.0"}, "v2"},
+		{map[string]string{"version": "1.0.0"}, "default"},
+		{nil, "default"},
+	} {
+		encoder := hessian.NewEncoder()
+		for _, field := range []interface{}{"2.0.2", "com.foo.Greeter", "1.0.0", "sayHello", "Ljava/lang/String;", "world", tc.attachments} {
+			if err := encoder.Encode(field); err != nil {
+				t.Fatalf("encode dubbo request failed: %v", err)
+			}
+		}
+		payload := encoder.Buffer()
+		data := make([]byte, dubbo.HeaderLen, dubbo.HeaderLen+len(payload))
+		copy(data, dubbo.MagicTag)
+		data[dubbo.FlagIdx] = 0xc2
+		binary.BigEndian.PutUint32(data[dubbo.DataLenIdx:], uint32(len(payload)))
+		request := dubbo.NewRpcRequest(nil, buffer.NewIoBufferBytes(append(data, payload...)))
+		if request == nil {
+			t.Fatalf("decode dubbo request failed")
+		}
+		route := vh.GetRouteFromEntries(context.Background(), request)
+		if route == nil || route.RouteRule().ClusterName(context.Background()) != tc.cluster {
+			t.Errorf("attachments %v route to unexpected cluster, expected: %s", tc.attachments, tc.cluster)
+		}
+	}
+}
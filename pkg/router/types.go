@@ -22,6 +22,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"net"
 	"sync"
@@ -45,6 +46,9 @@ var (
 	ErrDuplicateHostPort    = errors.New("duplicate virtual host port")
 	ErrNoVirtualHostPort    = errors.New("virtual host port is invalid")
 	ErrUnexpected           = errors.New("an unexpected error occurs")
+	ErrNoRouterConfig       = errors.New("router config is not found")
+	ErrRouteIndexOutOfRange = errors.New("route index is out of range")
+	ErrInvalidRoute         = errors.New("invalid route")
 )
 
 type headerFormatter interface {
@@ -94,6 +98,18 @@ type retryPolicyImpl struct {
 	retryTimeout time.Duration
 	numRetries   uint32
 	statusCodes  []uint32
+	hedgeDelay   time.Duration
+	// retryNonIdempotent allows to retry the non-idempotent requests
+	retryNonIdempotent bool
+	// grpcStatusCodes are the grpc status codes to retry
+	grpcStatusCodes []uint32
+	// the exponential back off between the retries
+	backOffBase time.Duration
+	backOffMax  time.Duration
+	// retriableHeaders are the response headers to retry
+	retriableHeaders []types.HeaderMatcher
+	// retryOnConnectFailure retries the connect failures with a separate budget
+	retryOnConnectFailure bool
 }
 
 func (p *retryPolicyImpl) RetryOn() bool {
@@ -124,6 +140,48 @@ func (p *retryPolicyImpl) RetryableStatusCodes() []uint32 {
 	return p.statusCodes
 }
 
+func (p *retryPolicyImpl) HedgeDelay() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.hedgeDelay
+}
+
+func (p *retryPolicyImpl) RetryNonIdempotent() bool {
+	if p == nil {
+		return false
+	}
+	return p.retryNonIdempotent
+}
+
+func (p *retryPolicyImpl) RetryableGrpcStatusCodes() []uint32 {
+	if p == nil {
+		return nil
+	}
+	return p.grpcStatusCodes
+}
+
+func (p *retryPolicyImpl) RetriableHeaders() []types.HeaderMatcher {
+	if p == nil {
+		return nil
+	}
+	return p.retriableHeaders
+}
+
+func (p *retryPolicyImpl) RetryOnConnectFailure() bool {
+	if p == nil {
+		return false
+	}
+	return p.retryOnConnectFailure
+}
+
+func (p *retryPolicyImpl) RetryBackOff() (time.Duration, time.Duration) {
+	if p == nil {
+		return 0, 0
+	}
+	return p.backOffBase, p.backOffMax
+}
+
 type shadowPolicyImpl struct {
 	cluster    string
 	runtimeKey string
@@ -137,6 +195,60 @@ func (spi *shadowPolicyImpl) RuntimeKey() string {
 	return spi.runtimeKey
 }
 
+// canaryPolicyImpl chooses the canary cluster by the headers first, and then by the percentage
+type canaryPolicyImpl struct {
+	clusterName string
+	headers     types.HeaderMatcher
+	percentage  uint32
+	hashHeader  string
+}
+
+func newCanaryPolicy(cfg *v2.CanaryConfig) (*canaryPolicyImpl, error) {
+	if cfg.ClusterName == "" {
+		return nil, errors.New("canary cluster name is required")
+	}
+	if cfg.Percentage > 100 {
+		return nil, fmt.Errorf("invalid canary percentage: %d", cfg.Percentage)
+	}
+	p := &canaryPolicyImpl{
+		clusterName: cfg.ClusterName,
+		percentage:  cfg.Percentage,
+		hashHeader:  cfg.HashHeader,
+	}
+	if len(cfg.Headers) > 0 {
+		p.headers = CreateHTTPHeaderMatcher(cfg.Headers)
+	}
+	return p, nil
+}
+
+func (p *canaryPolicyImpl) ClusterName() string {
+	return p.clusterName
+}
+
+// MatchHeaders returns false if no header is configured
+func (p *canaryPolicyImpl) MatchHeaders(ctx context.Context, headers api.HeaderMap) bool {
+	return p.headers != nil && p.headers.Matches(ctx, headers)
+}
+
+func (p *canaryPolicyImpl) HashHeader() string {
+	return p.hashHeader
+}
+
+// InPercentage maps the key into [0, 100) by the hash, so the same key is always assigned to the same cluster
+func (p *canaryPolicyImpl) InPercentage(key string) bool {
+	switch {
+	case p.percentage == 0:
+		return false
+	case p.percentage >= 100:
+		return true
+	case key == "":
+		return uint32(rand.Intn(100)) < p.percentage
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()%100 < p.percentage
+}
+
 // RouterRuleFactory creates a RouteBase
 type RouterRuleFactory func(base *RouteRuleImplBase, header []v2.HeaderMatcher) RouteBase
 
@@ -253,19 +365,62 @@ func getHashByString(str string) uint64 {
 	return siphash.Hash(0xbeefcafebabedead, 0, []byte(str))
 }
 
+type mirrorTarget struct {
+	cluster string
+	percent int
+}
+
 type mirrorImpl struct {
 	cluster string
 	percent int
+	// targets contains the cluster and the other shadow clusters
+	targets []mirrorTarget
+	mux     sync.Mutex
 	rand    *rand.Rand
 }
 
+func newMirrorImpl(config *v2.RequestMirrorPolicy) *mirrorImpl {
+	m := &mirrorImpl{
+		cluster: config.Cluster,
+		percent: int(config.Percent),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if m.cluster != "" {
+		m.targets = append(m.targets, mirrorTarget{cluster: m.cluster, percent: m.percent})
+	}
+	for _, t := range config.Targets {
+		if t.Cluster != "" && t.Percent > 0 {
+			m.targets = append(m.targets, mirrorTarget{cluster: t.Cluster, percent: int(t.Percent)})
+		}
+	}
+	return m
+}
+
+// sampled returns true in the percent, the rand is shared by the requests matched the route
+func (m *mirrorImpl) sampled(percent int) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return percent > m.rand.Intn(100)
+}
+
 func (m *mirrorImpl) IsMirror() (isTrans bool) {
 	if m.cluster == "" || m.percent == 0 {
 		return false
 	}
-	return m.percent > m.rand.Intn(100)
+	return m.sampled(m.percent)
 }
 
 func (m *mirrorImpl) ClusterName() string {
 	return m.cluster
 }
+
+// MirrorClusters implements types.MultiMirrorPolicy
+func (m *mirrorImpl) MirrorClusters() []string {
+	var clusters []string
+	for _, t := range m.targets {
+		if t.percent > 0 && m.sampled(t.percent) {
+			clusters = append(clusters, t.cluster)
+		}
+	}
+	return clusters
+}
@@ -37,6 +37,22 @@ func getWeightedClusterEntry(weightedClusters []v2.WeightedCluster) (map[string]
 	return weightedClusterEntries, totalWeight
 }
 
+// getWeightedClusterList returns the weighted clusters in the config order, the clusters without weight
+// are never chosen. the weights are relative to the total weight, so they need not sum to 100.
+func getWeightedClusterList(weightedClusters []v2.WeightedCluster) []weightedClusterEntry {
+	weightedClusterList := make([]weightedClusterEntry, 0, len(weightedClusters))
+	for _, weightedCluster := range weightedClusters {
+		if weightedCluster.Cluster.Weight == 0 {
+			continue
+		}
+		weightedClusterList = append(weightedClusterList, weightedClusterEntry{
+			clusterName:   weightedCluster.Cluster.Name,
+			clusterWeight: weightedCluster.Cluster.Weight,
+		})
+	}
+	return weightedClusterList
+}
+
 func getHeaderParser(headersToAdd []*v2.HeaderValueOption, headersToRemove []string) *headerParser {
 	if headersToAdd == nil && headersToRemove == nil {
 		return nil
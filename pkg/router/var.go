@@ -26,6 +26,10 @@ var (
 	builtinVariables = []variable.Variable{
 		// value type of VarRouterMeta should be map[string]string
 		variable.NewVariable(types.VarRouterMeta, nil, nil, variable.DefaultSetter, 0),
+		// value type of VarRouterHeaderCaptures should be map[string][]string
+		variable.NewVariable(types.VarRouterHeaderCaptures, nil, nil, variable.DefaultSetter, 0),
+		// value type of VarRouterRequestBody should be buffer.IoBuffer
+		variable.NewVariable(types.VarRouterRequestBody, nil, nil, variable.DefaultSetter, 0),
 	}
 )
 
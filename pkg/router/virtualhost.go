@@ -36,6 +36,13 @@ type VirtualHostImpl struct {
 	requestHeadersParser  *headerParser
 	responseHeadersParser *headerParser
 	perFilterConfig       map[string]interface{}
+	// the largest size of the request body matched by the routes
+	bodyMatchBytes int
+}
+
+// bodyMatchRule is implemented by the route rule which may match the request body
+type bodyMatchRule interface {
+	bodyMatchBytes() int
 }
 
 func (vh *VirtualHostImpl) Name() string {
@@ -49,6 +56,9 @@ func (vh *VirtualHostImpl) addRouteBase(route api.RouteBase) {
 	vh.mutex.Lock()
 	defer vh.mutex.Unlock()
 	vh.routes = append(vh.routes, route)
+	if r, ok := route.(bodyMatchRule); ok && r.bodyMatchBytes() > vh.bodyMatchBytes {
+		vh.bodyMatchBytes = r.bodyMatchBytes()
+	}
 	// make fast index, used in certain scenarios
 	// TODO: rule can be extended
 	hmc := route.RouteRule().HeaderMatchCriteria()
@@ -113,21 +123,32 @@ func (vh *VirtualHostImpl) RemoveAllRoutes() {
 	vh.fastIndex = make(map[string]map[string]api.Route)
 	// clear the routes
 	vh.routes = vh.routes[:0]
+	vh.bodyMatchBytes = 0
 	return
 }
 
+// MaxRequestBodyMatchBytes returns the largest size of the request body matched by the routes,
+// zero means no route matches the request body
+func (vh *VirtualHostImpl) MaxRequestBodyMatchBytes() int {
+	vh.mutex.RLock()
+	defer vh.mutex.RUnlock()
+	return vh.bodyMatchBytes
+}
+
 func (vh *VirtualHostImpl) PerFilterConfig() map[string]interface{} {
 	return vh.perFilterConfig
 }
 
+// FinalizeRequestHeaders evaluates the headers of the route config first, the virtual host takes precedence
 func (vh *VirtualHostImpl) FinalizeRequestHeaders(ctx context.Context, headers api.HeaderMap, requestInfo api.RequestInfo) {
-	vh.requestHeadersParser.evaluateHeaders(ctx, headers)
 	vh.globalRouteConfig.requestHeadersParser.evaluateHeaders(ctx, headers)
+	vh.requestHeadersParser.evaluateHeaders(ctx, headers)
 }
 
+// FinalizeResponseHeaders evaluates the headers of the route config first, the virtual host takes precedence
 func (vh *VirtualHostImpl) FinalizeResponseHeaders(ctx context.Context, headers api.HeaderMap, requestInfo api.RequestInfo) {
-	vh.responseHeadersParser.evaluateHeaders(ctx, headers)
 	vh.globalRouteConfig.responseHeadersParser.evaluateHeaders(ctx, headers)
+	vh.responseHeadersParser.evaluateHeaders(ctx, headers)
 }
 
 // NewVirtualHostImpl convert mosn VirtualHost config to actual virtual host object
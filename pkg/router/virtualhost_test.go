@@ -19,8 +19,10 @@ package router
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/variable"
 
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -141,6 +143,186 @@ func TestRouterOrder(t *testing.T) {
 
 }
 
+func TestRouterQueryParameters(t *testing.T) {
+	newRouter := func(match v2.RouterMatch, cluster string) v2.Router {
+		r := v2.Router{}
+		r.Match = match
+		r.Route = v2.RouteAction{
+			RouterActionConfig: v2.RouterActionConfig{
+				ClusterName: cluster,
+			},
+		}
+		return r
+	}
+	exactRouter := newRouter(v2.RouterMatch{
+		Path: "/foo",
+		QueryParameters: []v2.QueryParameterMatcher{
+			{Name: "version", Value: "v1"},
+			{Name: "debug", Present: true},
+		},
+	}, "exact")
+	regexRouter := newRouter(v2.RouterMatch{
+		Prefix: "/foo",
+		QueryParameters: []v2.QueryParameterMatcher{
+			{Name: "user id", Value: "^[0-9]+$", Regex: true},
+		},
+	}, "regex")
+	pathRouter := newRouter(v2.RouterMatch{
+		Path: "/foo",
+	}, "path")
+	virtualHost, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Name:    "test",
+		Domains: []string{"*"},
+		Routers: []v2.Router{exactRouter, regexRouter, pathRouter},
+	})
+	if err != nil {
+		t.Fatalf("create virtual host failed: %v", err)
+	}
+	testCases := []struct {
+		path        string
+		query       string
+		clustername string
+	}{
+		{"/foo", "version=v1&debug", "exact"},
+		{"/foo", "debug=&version=v1&other=1", "exact"},
+		// the first one of the repeated parameters is used
+		{"/foo", "version=v1&version=v2&debug=true", "exact"},
+		{"/foo", "version=v2&version=v1&debug=true", "path"},
+		// url encoded
+		{"/foo", "version=%76%31&de%62ug=1", "exact"},
+		{"/foo", "user+id=12345", "regex"},
+		{"/foo", "user%20id=12345&version=v1", "regex"},
+		{"/foo", "user+id=12a45", "path"},
+		// query parameters are matched together with the path
+		{"/foo/bar", "version=v1&debug", ""},
+		{"/foo", "version=v1", "path"},
+		{"/foo", "", "path"},
+		{"/bar", "version=v1&debug", ""},
+	}
+	for i, tc := range testCases {
+		ctx := variable.NewVariableContext(context.Background())
+		headers := protocol.CommonHeader(map[string]string{})
+		variable.SetString(ctx, types.VarPath, tc.path)
+		variable.SetString(ctx, types.VarQueryString, tc.query)
+		rt := virtualHost.GetRouteFromEntries(ctx, headers)
+		if tc.clustername == "" {
+			if rt != nil {
+				t.Errorf("#%d expected no route matched, but got %s", i, rt.RouteRule().ClusterName(context.TODO()))
+			}
+			continue
+		}
+		if rt == nil || rt.RouteRule().ClusterName(context.TODO()) != tc.clustername {
+			t.Errorf("#%d route unexpected result, expected %s", i, tc.clustername)
+		}
+	}
+}
+
+func TestRouterBody(t *testing.T) {
+	newRouter := func(match v2.RouterMatch, cluster string) v2.Router {
+		r := v2.Router{}
+		r.Match = match
+		r.Route = v2.RouteAction{
+			RouterActionConfig: v2.RouterActionConfig{
+				ClusterName: cluster,
+			},
+		}
+		return r
+	}
+	exactRouter := newRouter(v2.RouterMatch{
+		Prefix: "/",
+		Body: []v2.BodyMatcher{
+			{JSONPath: "$.tenant.id", Value: "a"},
+		},
+	}, "clusterA")
+	regexRouter := newRouter(v2.RouterMatch{
+		Prefix: "/",
+		Body: []v2.BodyMatcher{
+			{JSONPath: "$.items[1].name", Value: "^b.*", Regex: true, MaxBodyBytes: 80},
+		},
+	}, "clusterB")
+	rawRouter := newRouter(v2.RouterMatch{
+		Prefix: "/",
+		Body: []v2.BodyMatcher{
+			{Value: "^tenant=c", Regex: true},
+		},
+	}, "clusterC")
+	defaultRouter := newRouter(v2.RouterMatch{
+		Prefix: "/",
+	}, "default")
+	virtualHost, err := NewVirtualHostImpl(&v2.VirtualHost{
+		Name:    "test",
+		Domains: []string{"*"},
+		Routers: []v2.Router{exactRouter, regexRouter, rawRouter, defaultRouter},
+	})
+	if err != nil {
+		t.Fatalf("create virtual host failed: %v", err)
+	}
+	// the largest max body bytes of the routes is buffered
+	if virtualHost.MaxRequestBodyMatchBytes() != defaultBodyMatchMaxBytes {
+		t.Fatalf("unexpected max request body match bytes: %d", virtualHost.MaxRequestBodyMatchBytes())
+	}
+	testCases := []struct {
+		body        string
+		clustername string
+	}{
+		{`{"tenant": {"id": "a"}}`, "clusterA"},
+		{`{"tenant": {"id": "b"}}`, "default"},
+		{`{"tenant": {"id": 1}, "items": [{"name": "a"}, {"name": "bar"}]}`, "clusterB"},
+		{`{"items": [{"name": "bar"}]}`, "default"},
+		// the regex matches the raw body which is not a json
+		{"tenant=c&id=1", "clusterC"},
+		{"tenant=a", "default"},
+		{`{"tenant": {"id": "a"`, "default"},
+		// the body larger than the max body bytes falls to the other routes
+		{`{"items": [{"name": "a"}, {"name": "bar"}], "padding": "0123456789012345678901234567890123456789"}`, "default"},
+		{"", "default"},
+	}
+	for i, tc := range testCases {
+		ctx := variable.NewVariableContext(context.Background())
+		headers := protocol.CommonHeader(map[string]string{})
+		variable.SetString(ctx, types.VarPath, "/foo")
+		variable.Set(ctx, types.VarRouterRequestBody, buffer.NewIoBufferString(tc.body))
+		rt := virtualHost.GetRouteFromEntries(ctx, headers)
+		if rt == nil || rt.RouteRule().ClusterName(context.TODO()) != tc.clustername {
+			t.Errorf("#%d route unexpected result, expected %s", i, tc.clustername)
+		}
+	}
+	// the request without body only matches the default route
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarPath, "/foo")
+	if rt := virtualHost.GetRouteFromEntries(ctx, protocol.CommonHeader{}); rt == nil || rt.RouteRule().ClusterName(context.TODO()) != "default" {
+		t.Error("request without body should match the default route")
+	}
+	virtualHost.RemoveAllRoutes()
+	if virtualHost.MaxRequestBodyMatchBytes() != 0 {
+		t.Error("virtual host without routes should not need the request body")
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	testCases := []struct {
+		path string
+		keys []string
+	}{
+		{"$.a.b", []string{"a", "b"}},
+		{"a.b", []string{"a", "b"}},
+		{"$.items[0].name", []string{"items", "0", "name"}},
+		{"$[1][2]", []string{"1", "2"}},
+		{"", nil},
+	}
+	for i, tc := range testCases {
+		keys, err := parseJSONPath(tc.path)
+		if err != nil || !reflect.DeepEqual(keys, tc.keys) {
+			t.Errorf("#%d parse json path unexpected result: %v, %v", i, keys, err)
+		}
+	}
+	for _, path := range []string{"$.items[0", "$.items[a]", "$.items]0["} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parse invalid json path %s should be failed", path)
+		}
+	}
+}
+
 // All Matched Router will be returned
 func TestAllRouter(t *testing.T) {
 	prefixrouter := v2.Router{}
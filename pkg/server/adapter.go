@@ -132,3 +132,12 @@ func (adapter *ListenerAdapter) DeleteListener(serverName string, listenerName s
 	connHandler.RemoveListeners(listenerName)
 	return nil
 }
+
+// UpdateTLSContext rotates the certificate of the listener, the existing connections are not affected
+func (adapter *ListenerAdapter) UpdateTLSContext(serverName string, listenerName string, cfg v2.TLSConfig) error {
+	connHandler := adapter.findHandler(serverName)
+	if connHandler == nil {
+		return fmt.Errorf("UpdateTLSContext error, servername = %s not found", serverName)
+	}
+	return connHandler.UpdateTLSContext(listenerName, cfg)
+}
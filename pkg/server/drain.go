@@ -0,0 +1,198 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/pkg/utils"
+)
+
+// DrainState is the state of the drain sequence
+type DrainState string
+
+// Group of drain states
+const (
+	DrainNotStarted         DrainState = "not_started"
+	DrainFailingHealthCheck DrainState = "failing_health_check"
+	DrainDraining           DrainState = "draining"
+	DrainDrained            DrainState = "drained"
+)
+
+var (
+	// health check fail time, the health check keeps failing for a while
+	// before the listeners stop accepting, so the upstreams and load balancers
+	// can stop sending new traffic. default 0, means no waiting.
+	healthCheckFailTime time.Duration
+
+	drain = &drainer{
+		state: DrainNotStarted,
+	}
+)
+
+func SetHealthCheckFailTime(time time.Duration) {
+	healthCheckFailTime = time
+}
+
+// DrainProgress describes the progress of the drain sequence
+type DrainProgress struct {
+	State             DrainState `json:"state"`
+	StartTime         time.Time  `json:"start_time,omitempty"`
+	ActiveConnections uint64     `json:"active_connections"`
+	ActiveRequests    int        `json:"active_requests"`
+}
+
+type drainer struct {
+	mutex     sync.RWMutex
+	state     DrainState
+	startTime time.Time
+}
+
+// begin returns false if the drain sequence is already started
+func (d *drainer) begin() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.state != DrainNotStarted {
+		return false
+	}
+	d.state = DrainFailingHealthCheck
+	d.startTime = time.Now()
+	return true
+}
+
+func (d *drainer) setState(state DrainState) {
+	d.mutex.Lock()
+	d.state = state
+	d.mutex.Unlock()
+	log.DefaultLogger.Infof("[server] [drain] drain state changed to %s", state)
+}
+
+func (d *drainer) getState() (DrainState, time.Time) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.state, d.startTime
+}
+
+// HealthCheckFailed returns true if the drain sequence is started,
+// the health check should report failure since then.
+func HealthCheckFailed() bool {
+	state, _ := drain.getState()
+	return state != DrainNotStarted
+}
+
+// GetDrainProgress returns the drain progress of the servers
+func GetDrainProgress() DrainProgress {
+	state, startTime := drain.getState()
+	progress := DrainProgress{
+		State:     state,
+		StartTime: startTime,
+	}
+	for _, server := range servers {
+		progress.ActiveConnections += server.handler.NumConnections()
+		if ch, ok := server.handler.(*connHandler); ok {
+			for _, al := range ch.listeners {
+				progress.ActiveRequests += al.activeStreamSize()
+			}
+		}
+	}
+	return progress
+}
+
+// DrainListeners drains the servers in sequence:
+// 1. fails the health check, and waits the health check fail time.
+// 2. stops accepting new connections, the listeners are closed.
+// 3. waits the in-flight requests to be completed, the drain time at most.
+// 4. force closes the remaining connections.
+// DrainListeners runs only once, the later calls return an error.
+func DrainListeners() error {
+	if !drain.begin() {
+		return errors.New("drain listeners is already started")
+	}
+	log.DefaultLogger.Infof("[server] [drain] start failing health check, wait %v", healthCheckFailTime)
+	time.Sleep(healthCheckFailTime)
+
+	drain.setState(DrainDraining)
+	var failed bool
+	for _, server := range servers {
+		ch, ok := server.handler.(*connHandler)
+		if !ok {
+			if err := server.Shutdown(); err != nil {
+				failed = true
+			}
+			continue
+		}
+		if err := ch.drainListeners(); err != nil {
+			failed = true
+		}
+	}
+	drain.setState(DrainDrained)
+
+	if failed {
+		return errors.New("failed to drain listeners")
+	}
+	return nil
+}
+
+// drainListeners closes all the listeners and drains the existing connections
+func (ch *connHandler) drainListeners() error {
+	var failed bool
+	listeners := ch.listeners
+	wg := sync.WaitGroup{}
+	wg.Add(len(listeners))
+	for _, l := range listeners {
+		al := l
+		log.DefaultLogger.Infof("[server] [drain] drain listener %v", al.listener.Name())
+		// drain listener in parallel
+		utils.GoWithRecover(func() {
+			defer wg.Done()
+			// closes the listener socket, so the new connections are refused
+			if err := al.listener.Close(nil); err != nil {
+				log.DefaultLogger.Errorf("[server] [drain] failed to close listener %v: %v", al.listener.Name(), err)
+				failed = true
+			}
+			al.OnShutdown()
+			al.closeConnections()
+		}, nil)
+	}
+	wg.Wait()
+
+	if failed {
+		return errors.New("failed to drain listeners")
+	}
+	return nil
+}
+
+// closeConnections force closes the connections which are still alive after draining
+func (al *activeListener) closeConnections() {
+	var conns []api.Connection
+	// the connection is removed from the list when it is closed,
+	// so collects them first
+	al.conns.VisitSafe(func(v interface{}) {
+		conns = append(conns, v.(*activeConnection).conn)
+	})
+	if len(conns) > 0 {
+		log.DefaultLogger.Infof("[server] [drain] listener %s force close %d connections", al.listener.Name(), len(conns))
+	}
+	for _, conn := range conns {
+		conn.Close(api.NoFlush, api.LocalClose)
+	}
+}
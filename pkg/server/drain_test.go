@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+func resetDrain() {
+	drain = &drainer{
+		state: DrainNotStarted,
+	}
+}
+
+func TestDrainListeners(t *testing.T) {
+	setup()
+	defer tearDown()
+	resetDrain()
+	oldServers, oldDrainTime, oldFailTime := servers, drainTime, healthCheckFailTime
+	defer func() {
+		servers, drainTime, healthCheckFailTime = oldServers, oldDrainTime, oldFailTime
+		resetDrain()
+	}()
+	servers = []*server{
+		{
+			serverName: testServerName,
+			handler:    listenerAdapterInstance.defaultConnHandler,
+		},
+	}
+	SetDrainTime(3 * time.Second)
+	SetHealthCheckFailTime(500 * time.Millisecond)
+
+	addrStr := "127.0.0.1:8084"
+	name := "drain_listener"
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, baseListenerConfig(addrStr, name)); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+
+	conn, err := tls.Dial("tcp", addrStr, &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	require.Nil(t, err)
+	defer conn.Close()
+	time.Sleep(100 * time.Millisecond) // wait connection accepted
+	require.False(t, HealthCheckFailed())
+	require.Equal(t, DrainNotStarted, GetDrainProgress().State)
+	require.Equal(t, uint64(1), GetDrainProgress().ActiveConnections)
+
+	// mock an in-flight request, which is completed after 1 second draining
+	active := metrics.NewListenerStats(name).Counter(metrics.DownstreamRequestActive)
+	active.Inc(1)
+	requestDone := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(healthCheckFailTime + time.Second)
+		active.Dec(1)
+		requestDone <- time.Now()
+	}()
+
+	drainDone := make(chan error, 1)
+	go func() {
+		drainDone <- DrainListeners()
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	// health check fails, but still accepting
+	require.True(t, HealthCheckFailed())
+	require.Equal(t, DrainFailingHealthCheck, GetDrainProgress().State)
+	c, err := net.Dial("tcp", addrStr)
+	require.Nil(t, err)
+	c.Close()
+
+	time.Sleep(healthCheckFailTime)
+	// new connections are refused while draining
+	progress := GetDrainProgress()
+	require.Equal(t, DrainDraining, progress.State)
+	require.Equal(t, 1, progress.ActiveRequests)
+	_, err = net.Dial("tcp", addrStr)
+	require.NotNil(t, err)
+	// the existing connection is kept
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	nerr, ok := err.(net.Error)
+	require.True(t, ok && nerr.Timeout(), "connection should be kept while draining, error: %v", err)
+
+	select {
+	case err := <-drainDone:
+		require.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain listeners is not finished")
+	}
+	// the in-flight request is completed before the force close
+	drained := time.Now()
+	done := <-requestDone
+	require.False(t, drained.Before(done))
+	require.True(t, drained.Sub(done) < drainTime)
+	require.Equal(t, DrainDrained, GetDrainProgress().State)
+
+	// the existing connection is force closed
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	nerr, ok = err.(net.Error)
+	require.False(t, ok && nerr.Timeout(), "connection should be closed, error: %v", err)
+	require.Equal(t, uint64(0), GetDrainProgress().ActiveConnections)
+
+	// drain only once
+	require.NotNil(t, DrainListeners())
+}
+
+func TestDrainListenersTimeout(t *testing.T) {
+	setup()
+	defer tearDown()
+	resetDrain()
+	oldServers, oldDrainTime, oldFailTime := servers, drainTime, healthCheckFailTime
+	defer func() {
+		servers, drainTime, healthCheckFailTime = oldServers, oldDrainTime, oldFailTime
+		resetDrain()
+	}()
+	servers = []*server{
+		{
+			serverName: testServerName,
+			handler:    listenerAdapterInstance.defaultConnHandler,
+		},
+	}
+	SetDrainTime(time.Second)
+	SetHealthCheckFailTime(0)
+
+	addrStr := "127.0.0.1:8085"
+	name := "drain_listener_timeout"
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, baseListenerConfig(addrStr, name)); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+
+	conn, err := tls.Dial("tcp", addrStr, &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// the in-flight request is never completed
+	active := metrics.NewListenerStats(name).Counter(metrics.DownstreamRequestActive)
+	active.Inc(1)
+	defer active.Dec(1)
+
+	start := time.Now()
+	require.Nil(t, DrainListeners())
+	cost := time.Since(start)
+	require.True(t, cost >= drainTime && cost < 2*drainTime, "unexpected drain cost: %v", cost)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	nerr, ok := err.(net.Error)
+	require.False(t, ok && nerr.Timeout(), "connection should be force closed, error: %v", err)
+}
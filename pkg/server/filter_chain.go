@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/types"
+)
+
+var errNoFilterChainMatched = errors.New("no filter chain matched")
+
+// activeFilterChain is a filter chain of the listener, the connections are
+// dispatched to the filter chains by the server name (SNI) before decryption.
+type activeFilterChain struct {
+	serverNames             []string
+	networkFiltersFactories []api.NetworkFilterChainFactory
+	tlsMng                  types.TLSContextManager
+}
+
+// filterChainSelector selects the filter chain by the server name, the exact server name
+// is matched first, then the longest wildcard server name, and then the default filter chain.
+type filterChainSelector struct {
+	exact map[string]*activeFilterChain
+	// wildcard is keyed by the suffix of the wildcard server name, such as ".example.com"
+	wildcard     map[string]*activeFilterChain
+	defaultChain *activeFilterChain
+}
+
+// needFilterChainSelector returns true if the listener has multiple filter chains or any server names
+func needFilterChainSelector(lc *v2.Listener) bool {
+	if len(lc.FilterChains) > 1 {
+		return true
+	}
+	return len(lc.FilterChains) == 1 && len(lc.FilterChains[0].ServerNames) > 0
+}
+
+func newFilterChainSelector(lc *v2.Listener) (*filterChainSelector, error) {
+	s := &filterChainSelector{
+		exact:    make(map[string]*activeFilterChain),
+		wildcard: make(map[string]*activeFilterChain),
+	}
+	for i := range lc.FilterChains {
+		c := &lc.FilterChains[i]
+		// the tls context manager of the filter chain only contains the filter chain's certificates
+		chainConfig := *lc
+		chainConfig.FilterChains = []v2.FilterChain{*c}
+		mgr, err := mtls.NewTLSServerContextManager(&chainConfig)
+		if err != nil {
+			return nil, err
+		}
+		chain := &activeFilterChain{
+			serverNames:             c.ServerNames,
+			networkFiltersFactories: configmanager.NewNetworkFilterFactories(lc, c),
+			tlsMng:                  mgr,
+		}
+		if len(c.ServerNames) == 0 {
+			if s.defaultChain != nil {
+				return nil, errors.New("multiple filter chains without server names")
+			}
+			s.defaultChain = chain
+			continue
+		}
+		for _, name := range c.ServerNames {
+			name = strings.ToLower(name)
+			m := s.exact
+			if strings.HasPrefix(name, "*.") {
+				m, name = s.wildcard, name[1:]
+			}
+			if _, ok := m[name]; ok {
+				return nil, fmt.Errorf("duplicate server name %s in filter chains", name)
+			}
+			m[name] = chain
+		}
+	}
+	return s, nil
+}
+
+// match returns the filter chain of the server name, nil means no filter chain matched
+func (s *filterChainSelector) match(serverName string) *activeFilterChain {
+	serverName = strings.ToLower(serverName)
+	if serverName != "" {
+		if chain, ok := s.exact[serverName]; ok {
+			return chain
+		}
+		// the longest suffix is matched first
+		for i := strings.IndexByte(serverName, '.'); i >= 0; {
+			if chain, ok := s.wildcard[serverName[i:]]; ok {
+				return chain
+			}
+			next := strings.IndexByte(serverName[i+1:], '.')
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	return s.defaultChain
+}
+
+// selectFilterChain peeks the server name of the connection and returns the matched filter chain,
+// the returned connection should be used instead of the raw connection.
+func (s *filterChainSelector) selectFilterChain(rawc net.Conn) (*activeFilterChain, net.Conn, error) {
+	serverName, conn, err := mtls.PeekServerName(rawc)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain := s.match(serverName)
+	if chain == nil {
+		return nil, nil, fmt.Errorf("%w, server name: %s", errNoFilterChainMatched, serverName)
+	}
+	return chain, conn, nil
+}
+
+// tlsContextManagers returns the tls context managers of all the filter chains
+func (s *filterChainSelector) tlsContextManagers() []types.TLSContextManager {
+	var managers []types.TLSContextManager
+	seen := make(map[*activeFilterChain]bool)
+	add := func(chain *activeFilterChain) {
+		if chain != nil && !seen[chain] {
+			seen[chain] = true
+			managers = append(managers, chain.tlsMng)
+		}
+	}
+	for _, chain := range s.exact {
+		add(chain)
+	}
+	for _, chain := range s.wildcard {
+		add(chain)
+	}
+	add(s.defaultChain)
+	return managers
+}
@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func mockSNIListenerConfig(serverNames ...[]string) *v2.Listener {
+	lc := &v2.Listener{}
+	lc.Name = "sni_listener"
+	for _, names := range serverNames {
+		lc.FilterChains = append(lc.FilterChains, v2.FilterChain{
+			FilterChainConfig: v2.FilterChainConfig{
+				ServerNames: names,
+				Filters: []v2.Filter{
+					{
+						Type: "mock_network",
+					},
+				},
+			},
+		})
+	}
+	return lc
+}
+
+func TestFilterChainSelectorMatch(t *testing.T) {
+	lc := mockSNIListenerConfig(
+		[]string{"a.example.com"},
+		[]string{"b.example.com", "*.example.com"},
+		[]string{"*.c.example.com"},
+		nil,
+	)
+	require.True(t, needFilterChainSelector(lc))
+	s, err := newFilterChainSelector(lc)
+	require.Nil(t, err)
+	chainA, chainB, chainC := s.exact["a.example.com"], s.exact["b.example.com"], s.wildcard[".c.example.com"]
+	require.NotNil(t, chainA)
+	require.NotNil(t, chainB)
+	require.NotNil(t, chainC)
+	require.NotNil(t, s.defaultChain)
+	assert.Len(t, chainA.networkFiltersFactories, 1)
+
+	for serverName, expected := range map[string]*activeFilterChain{
+		"a.example.com":       chainA,
+		"A.Example.com":       chainA,
+		"b.example.com":       chainB,
+		"other.example.com":   chainB,
+		"x.y.example.com":     chainB,
+		"x.c.example.com":     chainC,
+		"example.com":         s.defaultChain,
+		"a.example.org":       s.defaultChain,
+		"":                    s.defaultChain,
+		"a.example.com.other": s.defaultChain,
+	} {
+		assert.True(t, expected == s.match(serverName), "server name: %s", serverName)
+	}
+
+	// no default chain
+	s, err = newFilterChainSelector(mockSNIListenerConfig([]string{"a.example.com"}))
+	require.Nil(t, err)
+	assert.Nil(t, s.match("b.example.com"))
+
+	// a single filter chain without server names
+	assert.False(t, needFilterChainSelector(mockSNIListenerConfig(nil)))
+}
+
+func TestFilterChainSelectorInvalid(t *testing.T) {
+	_, err := newFilterChainSelector(mockSNIListenerConfig(nil, nil))
+	assert.NotNil(t, err)
+	_, err = newFilterChainSelector(mockSNIListenerConfig([]string{"a.example.com"}, []string{"A.example.com"}))
+	assert.NotNil(t, err)
+	_, err = newFilterChainSelector(mockSNIListenerConfig([]string{"*.example.com"}, []string{"*.example.com"}))
+	assert.NotNil(t, err)
+}
+
+func TestSelectFilterChain(t *testing.T) {
+	s, err := newFilterChainSelector(mockSNIListenerConfig(
+		[]string{"a.example.com"},
+		[]string{"b.example.com"},
+	))
+	require.Nil(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+
+	for _, tc := range []struct {
+		serverName string
+		expected   *activeFilterChain
+	}{
+		{"a.example.com", s.exact["a.example.com"]},
+		{"b.example.com", s.exact["b.example.com"]},
+		{"c.example.com", nil},
+	} {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		require.Nil(t, err)
+		// sends a ClientHello, the handshake is never completed
+		go tls.Client(client, &tls.Config{
+			ServerName:         tc.serverName,
+			InsecureSkipVerify: true,
+		}).Handshake()
+
+		rawc, err := ln.Accept()
+		require.Nil(t, err)
+		rawc.SetDeadline(time.Now().Add(3 * time.Second))
+		chain, conn, err := s.selectFilterChain(rawc)
+		if tc.expected == nil {
+			assert.NotNil(t, err)
+		} else {
+			require.Nil(t, err)
+			assert.True(t, tc.expected == chain, "server name: %s", tc.serverName)
+			// the ClientHello can be read again
+			b := make([]byte, 1)
+			_, err = conn.Read(b)
+			require.Nil(t, err)
+			assert.Equal(t, byte(0x16), b[0])
+		}
+		rawc.Close()
+		client.Close()
+	}
+}
@@ -110,9 +110,18 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 	} else {
 		listenerName = lc.Name
 	}
-	// currently, we just support one filter chain
-	if len(lc.FilterChains) != 1 {
-		return nil, errors.New("error updating listener, listener have filter chains count is not 1")
+	if len(lc.FilterChains) == 0 {
+		return nil, errors.New("error updating listener, listener has no filter chains")
+	}
+	// multiple filter chains are selected by the server names
+	var filterChains *filterChainSelector
+	if needFilterChainSelector(lc) {
+		selector, err := newFilterChainSelector(lc)
+		if err != nil {
+			log.DefaultLogger.Errorf("[server] [conn handler] create filter chain selector failed, %v", err)
+			return nil, err
+		}
+		filterChains = selector
 	}
 	// set listener filter , network filter and stream filter
 	var listenerFiltersFactories []api.ListenerFilterChainFactory
@@ -147,6 +156,9 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 		rawConfig.FilterChains[0].TLSContexts = lc.FilterChains[0].TLSContexts
 		rawConfig.FilterChains[0].TLSConfig = lc.FilterChains[0].TLSConfig
 		rawConfig.FilterChains[0].TLSConfigs = lc.FilterChains[0].TLSConfigs
+		rawConfig.FilterChains[0].ServerNames = lc.FilterChains[0].ServerNames
+		rawConfig.FilterChains = append(rawConfig.FilterChains[:1], lc.FilterChains[1:]...)
+		al.filterChains.Store(filterChains)
 		rawConfig.Inspector = lc.Inspector
 		mgr, err := mtls.NewTLSServerContextManager(rawConfig)
 		if err != nil {
@@ -187,7 +199,7 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 				alConfig.Path = types.MosnLogBasePath + string(os.PathSeparator) + lc.Name + "_access.log"
 			}
 
-			if al, err := log.NewAccessLog(alConfig.Path, alConfig.Format); err == nil {
+			if al, err := newAccessLog(alConfig); err == nil {
 				als = append(als, al)
 			} else {
 				return nil, fmt.Errorf("initialize listener access logger %s failed: %v", alConfig.Path, err.Error())
@@ -201,6 +213,7 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 		if err != nil {
 			return al, err
 		}
+		al.filterChains.Store(filterChains)
 		l.SetListenerCallbacks(al)
 		ch.listeners = append(ch.listeners, al)
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
@@ -213,6 +226,35 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 	return al, nil
 }
 
+// newAccessLog creates a json access log if the json format is configured, otherwise a text access log
+func newAccessLog(alConfig v2.AccessLog) (api.AccessLog, error) {
+	var formatter log.LogFormatter
+	var err error
+	if len(alConfig.JSONFormat) == 0 {
+		formatter, err = log.NewTextFormatter(alConfig.Format)
+	} else {
+		formatter, err = log.NewJSONFormatter(alConfig.JSONFormat, alConfig.JSONNullMissing)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var formatters map[string]log.LogFormatter
+	if len(alConfig.Formats) > 0 {
+		formatters = make(map[string]log.LogFormatter, len(alConfig.Formats))
+		for name, format := range alConfig.Formats {
+			if format == "" {
+				return nil, fmt.Errorf("access log format %s: %v", name, log.ErrLogFormatUndefined)
+			}
+			f, err := log.NewTextFormatter(format)
+			if err != nil {
+				return nil, fmt.Errorf("access log format %s: %v", name, err)
+			}
+			formatters[name] = f
+		}
+	}
+	return log.NewAccessLogWithFormatters(alConfig.Path, formatter, formatters)
+}
+
 func (ch *connHandler) StartListener(lctx context.Context, listenerTag uint64) {
 	for _, l := range ch.listeners {
 		if l.listener.ListenerTag() == listenerTag {
@@ -340,6 +382,18 @@ func (ch *connHandler) ListListenersFile(lctx context.Context) []*os.File {
 	return files
 }
 
+func (ch *connHandler) UpdateTLSContext(listenerName string, cfg v2.TLSConfig) error {
+	al := ch.findActiveListenerByName(listenerName)
+	if al == nil {
+		return fmt.Errorf("update tls context error, listener %s is not found", listenerName)
+	}
+	if err := al.updateTLSContext(&cfg); err != nil {
+		return err
+	}
+	configmanager.SetListenerConfig(*al.listener.Config())
+	return nil
+}
+
 func (ch *connHandler) findActiveListenerByAddress(addr net.Addr) *activeListener {
 	for _, l := range ch.listeners {
 		if l.listener != nil {
@@ -385,6 +439,9 @@ type activeListener struct {
 	updatedLabel             bool
 	idleTimeout              *api.DurationConfig
 	tlsMng                   types.TLSContextManager
+	// filterChains keeps the *filterChainSelector, which is not nil if the connections are dispatched
+	// to multiple filter chains. it is updated with the listener while the connections are accepted.
+	filterChains atomic.Value
 }
 
 func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers []api.AccessLog,
@@ -427,6 +484,46 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 	return al, nil
 }
 
+// filterChainSelector returns the filter chain selector of the listener, nil means the listener has one filter chain
+func (al *activeListener) filterChainSelector() *filterChainSelector {
+	selector, _ := al.filterChains.Load().(*filterChainSelector)
+	return selector
+}
+
+// updateTLSContext rotates the certificate of the tls context with the same server name,
+// the tls contexts of all the filter chains are tried.
+func (al *activeListener) updateTLSContext(cfg *v2.TLSConfig) error {
+	managers := []types.TLSContextManager{al.tlsMng}
+	if selector := al.filterChainSelector(); selector != nil {
+		managers = selector.tlsContextManagers()
+	}
+	err := mtls.ErrorNoTLSContextMatched
+	for _, mng := range managers {
+		updater, ok := mng.(types.TLSContextUpdater)
+		if !ok {
+			continue
+		}
+		if err = updater.UpdateTLSContext(cfg); err != mtls.ErrorNoTLSContextMatched {
+			break
+		}
+	}
+	if err != nil {
+		log.DefaultLogger.Errorf("[server] [listener] update tls context of listener %s failed: %v", al.listener.Name(), err)
+		return err
+	}
+	// keeps the config of the listener updated
+	rawConfig := al.listener.Config()
+	for i := range rawConfig.FilterChains {
+		contexts := rawConfig.FilterChains[i].TLSContexts
+		for j := range contexts {
+			if contexts[j].ServerName == cfg.ServerName {
+				contexts[j] = *cfg
+			}
+		}
+	}
+	return nil
+}
+
 func (al *activeListener) GoStart(lctx context.Context) {
 	utils.GoWithRecover(func() {
 		al.listener.Start(lctx, false)
@@ -440,6 +537,7 @@ func (al *activeListener) GoStart(lctx context.Context) {
 // ListenerEventListener
 func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, ch chan api.Connection, buf []byte, listeners []api.ConnectionEventListener) {
 	var rawf *os.File
+	networkFiltersFactories := al.networkFiltersFactories
 
 	// only store fd and tls conn handshake in final working listener
 	if !useOriginalDst {
@@ -462,13 +560,25 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 			}
 		}
 		// if ch is not nil, the conn has been initialized in func transferNewConn
-		if al.tlsMng != nil && ch == nil {
-			conn, err := al.tlsMng.Conn(rawc)
+		tlsMng := al.tlsMng
+		if selector := al.filterChainSelector(); selector != nil && ch == nil {
+			chain, conn, err := selector.selectFilterChain(rawc)
+			if err != nil {
+				if log.DefaultLogger.GetLogLevel() >= log.INFO {
+					log.DefaultLogger.Infof("[server] [listener] select filter chain failed, error: %v", err)
+				}
+				closeRawConn(rawc, listeners)
+				return
+			}
+			rawc, tlsMng, networkFiltersFactories = conn, chain.tlsMng, chain.networkFiltersFactories
+		}
+		if tlsMng != nil && ch == nil {
+			conn, err := tlsMng.Conn(rawc)
 			if err != nil {
 				if log.DefaultLogger.GetLogLevel() >= log.INFO {
 					log.DefaultLogger.Infof("[server] [listener] accept connection failed, error: %v", err)
 				}
-				rawc.Close()
+				closeRawConn(rawc, listeners)
 				return
 			}
 			rawc = conn
@@ -494,7 +604,7 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 	_ = variable.Set(ctx, types.VariableListenerType, al.listener.Config().Type)
 	_ = variable.Set(ctx, types.VariableListenerName, al.listener.Name())
 	_ = variable.Set(ctx, types.VariableConnDefaultReadBufferSize, al.defaultReadBufferSize)
-	_ = variable.Set(ctx, types.VariableNetworkFilterChainFactories, al.networkFiltersFactories)
+	_ = variable.Set(ctx, types.VariableNetworkFilterChainFactories, networkFiltersFactories)
 	_ = variable.Set(ctx, types.VariableAccessLogs, al.accessLogs)
 	if rawf != nil {
 		_ = variable.Set(ctx, types.VariableConnectionFd, rawf)
@@ -519,10 +629,26 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 	arc.ContinueFilterChain(ctx, true)
 }
 
+// closeRawConn closes the raw connection before the connection is created,
+// the connection event listeners are notified as the connection is closed.
+func closeRawConn(rawc net.Conn, listeners []api.ConnectionEventListener) {
+	rawc.Close()
+	for _, listener := range listeners {
+		listener.OnEvent(api.LocalClose)
+	}
+}
+
 func (al *activeListener) OnNewConnection(ctx context.Context, conn api.Connection) {
+	// the network filters of the selected filter chain are used if the listener has multiple filter chains
+	networkFiltersFactories := al.networkFiltersFactories
+	if v, err := variable.Get(ctx, types.VariableNetworkFilterChainFactories); err == nil {
+		if factories, ok := v.([]api.NetworkFilterChainFactory); ok {
+			networkFiltersFactories = factories
+		}
+	}
 	//Register Proxy's Filter
 	filterManager := conn.FilterManager()
-	for _, nfcf := range al.networkFiltersFactories {
+	for _, nfcf := range networkFiltersFactories {
 		nfcf.CreateFilterChain(ctx, filterManager)
 	}
 
@@ -740,12 +866,17 @@ func (arc *activeRawConn) UseOriginalDst(ctx context.Context) {
 			buf = val.([]byte)
 		}
 	}
+	// the connection event listeners are kept, such as the connection limit of the accepting listener
+	var listeners []api.ConnectionEventListener
+	if val, err := variable.Get(ctx, types.VariableConnectionEventListeners); err == nil && val != nil {
+		listeners = val.([]api.ConnectionEventListener)
+	}
 
 	if listener != nil {
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[server] [conn] found original dest listener :%s:%d", listener.listenIP, listener.listenPort)
 		}
-		listener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf, nil)
+		listener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf, listeners)
 		return
 	}
 
@@ -753,7 +884,7 @@ func (arc *activeRawConn) UseOriginalDst(ctx context.Context) {
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[server] [conn] use fallback listener for original dest:%s:%d", localListener.listenIP, localListener.listenPort)
 		}
-		localListener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf, nil)
+		localListener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf, listeners)
 		return
 	}
 
@@ -761,12 +892,22 @@ func (arc *activeRawConn) UseOriginalDst(ctx context.Context) {
 	if log.DefaultLogger.GetLogLevel() >= log.INFO {
 		log.DefaultLogger.Infof("[server] [conn] no listener found for original dest, fallback to listener filter: %s:%d", arc.activeListener.listenIP, arc.activeListener.listenPort)
 	}
-	arc.activeListener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf, nil)
+	arc.activeListener.OnAccept(arc.rawc, false, arc.oriRemoteAddr, ch, buf, listeners)
 }
 
+// ContinueFilterChain runs the following listener filters, the connection is created after all of them are passed.
+// a listener filter rejects the connection with success false, the raw connection is closed, and the resources
+// held by the connection, such as the connection limit token of the listener, are released.
 func (arc *activeRawConn) ContinueFilterChain(ctx context.Context, success bool) {
 
 	if !success {
+		var listeners []api.ConnectionEventListener
+		if arc.ctx != nil {
+			if val, err := variable.Get(arc.ctx, types.VariableConnectionEventListeners); err == nil && val != nil {
+				listeners = val.([]api.ConnectionEventListener)
+			}
+		}
+		closeRawConn(arc.rawc, listeners)
 		return
 	}
 
@@ -19,12 +19,21 @@ package server
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/types"
 
 	"mosn.io/mosn/pkg/configmanager"
@@ -214,3 +223,63 @@ const mosnConfig = `{
     "log_base": ""
   }
 }`
+
+// tokenReleaser releases the connection limit token once the connection is closed
+type tokenReleaser struct {
+	tokens   *int32
+	released uint32
+}
+
+func (r *tokenReleaser) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() && atomic.CompareAndSwapUint32(&r.released, 0, 1) {
+		atomic.AddInt32(r.tokens, -1)
+	}
+}
+
+// rejectListenerFilter rejects the connection in OnAccept, or after OnAccept returns if async is true
+type rejectListenerFilter struct {
+	async bool
+}
+
+func (f *rejectListenerFilter) OnAccept(cb api.ListenerFilterChainFactoryCallbacks) api.FilterStatus {
+	if f.async {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cb.ContinueFilterChain(cb.GetOriContext(), false)
+		}()
+		return api.Stop
+	}
+	cb.ContinueFilterChain(cb.GetOriContext(), false)
+	return api.Stop
+}
+
+func TestListenerFilterRejectReleaseToken(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+		al := &activeListener{
+			listener: network.NewListener(&v2.Listener{
+				ListenerConfig: v2.ListenerConfig{
+					Name:    "test_listener_filter_reject",
+					Network: "tcp",
+				},
+				Addr: addr,
+			}),
+			listenerFiltersFactories: []api.ListenerFilterChainFactory{
+				&rejectListenerFilter{async: async},
+			},
+		}
+
+		var tokens int32 = 1
+		rawc, peer := net.Pipe()
+		al.OnAccept(rawc, false, nil, nil, nil, []api.ConnectionEventListener{&tokenReleaser{tokens: &tokens}})
+
+		// the raw connection is closed, and the token is released
+		peer.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := peer.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err, "async: %v", async)
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&tokens) == 0
+		}, time.Second, 10*time.Millisecond, "async: %v", async)
+		peer.Close()
+	}
+}
@@ -19,11 +19,13 @@ package http
 
 import (
 	"context"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol"
 	str "mosn.io/mosn/pkg/stream"
@@ -47,11 +49,20 @@ type connPool struct {
 	clientMux        sync.Mutex
 	availableClients []*activeClient // available clients
 	totalClientCount uint64          // total clients
+	// closed is set if the pool is closed, guarded by clientMux
+	closed bool
+
+	// prefetching is set while the connections are prefetched
+	prefetching uint32
+	// connCtx keeps the connection variables of the request which creates the pool,
+	// the prefetched connections are created with it
+	connCtx context.Context
 }
 
 func NewConnPool(ctx context.Context, host types.Host) types.ConnectionPool {
 	pool := &connPool{
 		tlsHash: host.TLSHashValue(),
+		connCtx: types.NewConnectionContext(ctx),
 	}
 	pool.host.Store(host)
 
@@ -59,6 +70,8 @@ func NewConnPool(ctx context.Context, host types.Host) types.ConnectionPool {
 		pool.report()
 	}
 
+	pool.prefetch()
+
 	return pool
 }
 
@@ -112,6 +125,9 @@ func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveLi
 
 	streamEncoder := c.client.NewStream(ctx, receiver)
 	streamEncoder.GetStream().AddEventListener(c)
+
+	// the demand grows, warm up the connections for the following requests
+	p.prefetch()
 	return host, streamEncoder, ""
 }
 
@@ -128,7 +144,10 @@ func (p *connPool) getAvailableClient(ctx context.Context) (*activeClient, types
 		if maxConns == 0 || atomic.LoadUint64(&p.totalClientCount) <= maxConns {
 			// Unlock immediately, allowing concurrent connections
 			p.clientMux.Unlock()
+			// the request is pending until the new connection is established
+			host.ClusterInfo().Stats().UpstreamRequestPending.Inc(1)
 			ac, reason := newActiveClient(ctx, p)
+			host.ClusterInfo().Stats().UpstreamRequestPending.Dec(1)
 			if ac == nil || reason != "" {
 				// To subtract a signed positive constant value c from x, do AddUint64(&x, ^uint64(c-1)).
 				atomic.AddUint64(&p.totalClientCount, ^uint64(0))
@@ -157,6 +176,8 @@ func (p *connPool) getAvailableClient(ctx context.Context) (*activeClient, types
 		c := p.availableClients[n]
 		p.availableClients[n] = nil
 		p.availableClients = p.availableClients[:n]
+		host.ClusterInfo().Stats().UpstreamConnectionIdle.Dec(1)
+		c.stopIdleTimer()
 		return c, ""
 	}
 }
@@ -165,6 +186,8 @@ func (p *connPool) Close() {
 	p.clientMux.Lock()
 	defer p.clientMux.Unlock()
 
+	p.closed = true
+
 	for _, c := range p.availableClients {
 		c.client.Close()
 	}
@@ -174,6 +197,8 @@ func (p *connPool) Shutdown() {
 	p.clientMux.Lock()
 	defer p.clientMux.Unlock()
 
+	p.closed = true
+
 	for _, client := range p.availableClients {
 		client.OnGoAway()
 	}
@@ -193,6 +218,11 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 			}
 		}
 
+		host.HostStats().UpstreamConnectionClose.Inc(1)
+		host.HostStats().UpstreamConnectionActive.Dec(1)
+		host.ClusterInfo().Stats().UpstreamConnectionClose.Inc(1)
+		host.ClusterInfo().Stats().UpstreamConnectionActive.Dec(1)
+
 		// check if closed connection is available
 		p.clientMux.Lock()
 		defer p.clientMux.Unlock()
@@ -204,12 +234,17 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 			if c == client {
 				p.availableClients[i] = nil
 				p.availableClients = append(p.availableClients[:i], p.availableClients[i+1:]...)
+				host.ClusterInfo().Stats().UpstreamConnectionIdle.Dec(1)
 				break
 			}
 		}
 
 		// set closed flag if not available
 		client.closed = true
+		client.stopIdleTimer()
+
+		// replace the closed connection if the pool keeps warm connections
+		p.prefetch()
 	} else if event == api.ConnectTimeout {
 		host.HostStats().UpstreamRequestTimeout.Inc(1)
 		host.ClusterInfo().Stats().UpstreamRequestTimeout.Inc(1)
@@ -226,12 +261,130 @@ func (p *connPool) onStreamDestroy(client *activeClient) {
 	host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
 	host.ClusterInfo().ResourceManager().Requests().Decrease()
 
-	// return to pool
+	// return to pool, the upgraded connection is closed by the tunnel
 	p.clientMux.Lock()
-	if !client.closed {
+	if !client.closed && !client.upgraded {
 		p.availableClients = append(p.availableClients, client)
+		host.ClusterInfo().Stats().UpstreamConnectionIdle.Inc(1)
+		p.startIdleTimer(client)
+	}
+	p.clientMux.Unlock()
+}
+
+// startIdleTimer closes the available client if it is not used in the upstream idle timeout,
+// it is called with the clientMux locked
+func (p *connPool) startIdleTimer(client *activeClient) {
+	ci, ok := p.Host().ClusterInfo().(types.UpstreamIdleTimeoutCluster)
+	if !ok || ci.UpstreamIdleTimeout() <= 0 {
+		return
+	}
+	client.stopIdleTimer()
+	client.idleTimer = utils.NewTimer(ci.UpstreamIdleTimeout(), func() {
+		p.onIdleTimeout(client)
+	})
+}
+
+func (p *connPool) onIdleTimeout(client *activeClient) {
+	p.clientMux.Lock()
+	// the client may be taken by a new stream before the lock is acquired, it is not idle any more
+	idle := false
+	for i, c := range p.availableClients {
+		if c == client {
+			// the min idle connections are kept, check it again in the next idle timeout
+			if cfg := p.prefetchConfig(); cfg != nil && len(p.availableClients) <= int(cfg.MinIdleConnections) {
+				p.startIdleTimer(client)
+				break
+			}
+			p.availableClients[i] = nil
+			p.availableClients = append(p.availableClients[:i], p.availableClients[i+1:]...)
+			p.Host().ClusterInfo().Stats().UpstreamConnectionIdle.Dec(1)
+			idle = true
+			break
+		}
 	}
 	p.clientMux.Unlock()
+
+	if idle {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[stream] [http] [connpool] close idle connection, Connection = %d", client.client.ConnID())
+		}
+		client.client.Close()
+	}
+}
+
+// prefetchConfig returns the prefetch config of the cluster, nil means no connection is prefetched
+func (p *connPool) prefetchConfig() *v2.ConnPoolPrefetchConfig {
+	if ci, ok := p.Host().ClusterInfo().(types.ConnPoolPrefetchCluster); ok {
+		return ci.ConnPoolPrefetch()
+	}
+	return nil
+}
+
+// prefetch creates the connections ahead of the demand in background, so the requests are not delayed
+// by the connecting. the connections are created one by one until the pool has enough idle connections.
+func (p *connPool) prefetch() {
+	cfg := p.prefetchConfig()
+	if cfg == nil {
+		return
+	}
+	if !atomic.CompareAndSwapUint32(&p.prefetching, 0, 1) {
+		return
+	}
+	utils.GoWithRecover(func() {
+		defer atomic.StoreUint32(&p.prefetching, 0)
+		for p.reservePrefetch(cfg) {
+			ac, reason := newActiveClient(p.connCtx, p)
+			if ac == nil || reason != "" {
+				// To subtract a signed positive constant value c from x, do AddUint64(&x, ^uint64(c-1)).
+				atomic.AddUint64(&p.totalClientCount, ^uint64(0))
+				return
+			}
+			p.clientMux.Lock()
+			if p.closed {
+				p.clientMux.Unlock()
+				ac.client.Close()
+				return
+			}
+			p.availableClients = append(p.availableClients, ac)
+			p.Host().ClusterInfo().Stats().UpstreamConnectionIdle.Inc(1)
+			p.startIdleTimer(ac)
+			p.clientMux.Unlock()
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[stream] [http] [connpool] prefetch connection, Connection = %d", ac.client.ConnID())
+			}
+		}
+	}, nil)
+}
+
+// reservePrefetch counts a new connection into the pool if more idle connections are needed.
+// no connection is prefetched for the unhealthy host, or if the host reaches the max connections.
+func (p *connPool) reservePrefetch(cfg *v2.ConnPoolPrefetchConfig) bool {
+	host := p.Host()
+	if !host.Health() || types.IsHostConnectionOverflow(host) {
+		return false
+	}
+	p.clientMux.Lock()
+	defer p.clientMux.Unlock()
+	if p.closed {
+		return false
+	}
+	total := atomic.LoadUint64(&p.totalClientCount)
+	if maxConns := host.ClusterInfo().ResourceManager().Connections().Max(); maxConns != 0 && total >= maxConns {
+		return false
+	}
+	idle := len(p.availableClients)
+	want := int(cfg.MinIdleConnections)
+	if cfg.PreconnectRatio > 1 {
+		active := int(total) - idle
+		if n := int(math.Ceil(float64(active)*cfg.PreconnectRatio)) - active; n > want {
+			want = n
+		}
+	}
+	if idle >= want {
+		return false
+	}
+	atomic.AddUint64(&p.totalClientCount, 1)
+	return true
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
@@ -278,6 +431,10 @@ type activeClient struct {
 	closeWithActiveReq bool
 	closed             bool
 	closeConn          bool
+	// upgraded is set if the connection is a tunnel after the protocol upgrade
+	upgraded bool
+	// idleTimer closes the client staying in the available clients, guarded by the pool's clientMux
+	idleTimer *utils.Timer
 }
 
 func newActiveClient(ctx context.Context, pool *connPool) (*activeClient, types.PoolFailureReason) {
@@ -286,7 +443,12 @@ func newActiveClient(ctx context.Context, pool *connPool) (*activeClient, types.
 	}
 
 	host := pool.Host()
-	data := host.CreateConnection(ctx)
+	// the connections to the host reach the cluster's max connections per host
+	data, ok := types.CreateHostConnection(ctx, host)
+	if !ok {
+		host.ClusterInfo().Stats().UpstreamConnectionHostOverflow.Inc(1)
+		return nil, types.Overflow
+	}
 	codecClient := pool.createStreamClient(ctx, data)
 	codecClient.AddConnectionEventListener(ac)
 	codecClient.SetStreamConnectionEventListener(ac)
@@ -309,6 +471,13 @@ func newActiveClient(ctx context.Context, pool *connPool) (*activeClient, types.
 	return ac, ""
 }
 
+func (ac *activeClient) stopIdleTimer() {
+	if ac.idleTimer != nil {
+		ac.idleTimer.Stop()
+		ac.idleTimer = nil
+	}
+}
+
 // types.ConnectionEventListener
 func (ac *activeClient) OnEvent(event api.ConnectionEvent) {
 	ac.pool.onConnectionEvent(ac, event)
@@ -337,3 +506,8 @@ func (ac *activeClient) OnResetStream(reason types.StreamResetReason) {
 func (ac *activeClient) OnGoAway() {
 	ac.closeConn = true
 }
+
+// OnUpgrade is called when the connection is upgraded, the connection is not returned to the pool
+func (ac *activeClient) OnUpgrade() {
+	ac.upgraded = true
+}
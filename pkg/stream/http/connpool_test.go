@@ -19,11 +19,14 @@ package http
 
 import (
 	"context"
+	"net"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	metrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/types"
@@ -32,7 +35,33 @@ import (
 
 type fakeClusterInfo struct {
 	types.ClusterInfo
-	mgr types.ResourceManager
+	mgr             types.ResourceManager
+	stats           types.ClusterStats
+	maxConnsPerHost uint32
+	idleTimeout     time.Duration
+	prefetch        *v2.ConnPoolPrefetchConfig
+}
+
+func newFakeClusterInfo(max uint64) *fakeClusterInfo {
+	return &fakeClusterInfo{
+		mgr: &fakeResourceManager{max: max},
+		stats: types.ClusterStats{
+			UpstreamRequestPendingOverflow:                 metrics.NewCounter(),
+			UpstreamConnectionLocalCloseWithActiveRequest:  metrics.NewCounter(),
+			UpstreamConnectionRemoteCloseWithActiveRequest: metrics.NewCounter(),
+			UpstreamConnectionTotal:                        metrics.NewCounter(),
+			UpstreamConnectionClose:                        metrics.NewCounter(),
+			UpstreamConnectionActive:                       metrics.NewCounter(),
+			UpstreamConnectionConFail:                      metrics.NewCounter(),
+			UpstreamConnectionIdle:                         metrics.NewCounter(),
+			UpstreamBytesReadTotal:                         metrics.NewCounter(),
+			UpstreamBytesWriteTotal:                        metrics.NewCounter(),
+			UpstreamRequestActive:                          metrics.NewCounter(),
+			UpstreamRequestPending:                         metrics.NewCounter(),
+			UpstreamRequestTimeout:                         metrics.NewCounter(),
+			UpstreamConnectionHostOverflow:                 metrics.NewCounter(),
+		},
+	}
 }
 
 func (ci *fakeClusterInfo) ResourceManager() types.ResourceManager {
@@ -43,6 +72,18 @@ func (ci *fakeClusterInfo) Name() string {
 	return "test"
 }
 
+func (ci *fakeClusterInfo) MaxConnectionsPerHost() uint32 {
+	return ci.maxConnsPerHost
+}
+
+func (ci *fakeClusterInfo) UpstreamIdleTimeout() time.Duration {
+	return ci.idleTimeout
+}
+
+func (ci *fakeClusterInfo) ConnPoolPrefetch() *v2.ConnPoolPrefetchConfig {
+	return ci.prefetch
+}
+
 type fakeTLSContextManager struct {
 	types.TLSContextManager
 }
@@ -76,13 +117,7 @@ func (ci *fakeClusterInfo) ConnBufferLimitBytes() uint32 {
 }
 
 func (ci *fakeClusterInfo) Stats() types.ClusterStats {
-	return types.ClusterStats{
-		UpstreamRequestPendingOverflow:                 metrics.NewCounter(),
-		UpstreamConnectionRemoteCloseWithActiveRequest: metrics.NewCounter(),
-		UpstreamConnectionTotal:                        metrics.NewCounter(),
-		UpstreamConnectionActive:                       metrics.NewCounter(),
-		UpstreamConnectionConFail:                      metrics.NewCounter(),
-	}
+	return ci.stats
 }
 
 type fakeResourceManager struct {
@@ -94,6 +129,10 @@ func (mgr *fakeResourceManager) Connections() types.Resource {
 	return &fakeResource{max: mgr.max}
 }
 
+func (mgr *fakeResourceManager) Requests() types.Resource {
+	return &fakeResource{}
+}
+
 type fakeResource struct {
 	max uint64
 }
@@ -110,9 +149,7 @@ func (r *fakeResource) Max() uint64     { return r.max }
 func TestGetAvailableClient(t *testing.T) {
 
 	var max uint64 = 2
-	ci := &fakeClusterInfo{
-		mgr: &fakeResourceManager{max: max},
-	}
+	ci := newFakeClusterInfo(max)
 
 	hc := v2.Host{
 		HostConfig: v2.HostConfig{
@@ -136,3 +173,309 @@ func TestGetAvailableClient(t *testing.T) {
 		t.Fatal("limit max connections failed")
 	}
 }
+
+func TestConnPoolStats(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ci := newFakeClusterInfo(0)
+	hc := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address:  ln.Addr().String(),
+			Hostname: ln.Addr().String(),
+		},
+	}
+	host := cluster.NewSimpleHost(hc, ci)
+	pool := NewConnPool(context.TODO(), host).(*connPool)
+	stats := ci.Stats()
+
+	check := func(total, active, idle int64) {
+		t.Helper()
+		if stats.UpstreamConnectionTotal.Count() != total ||
+			stats.UpstreamConnectionActive.Count() != active ||
+			stats.UpstreamConnectionIdle.Count() != idle ||
+			stats.UpstreamRequestPending.Count() != 0 {
+			t.Fatalf("unexpected pool stats: total=%d, active=%d, idle=%d, pending=%d", stats.UpstreamConnectionTotal.Count(),
+				stats.UpstreamConnectionActive.Count(), stats.UpstreamConnectionIdle.Count(), stats.UpstreamRequestPending.Count())
+		}
+	}
+
+	// open two connections
+	c1, reason := pool.getAvailableClient(context.Background())
+	if c1 == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	c2, reason := pool.getAvailableClient(context.Background())
+	if c2 == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	check(2, 2, 0)
+
+	// release the connections to the pool
+	pool.onStreamDestroy(c1)
+	pool.onStreamDestroy(c2)
+	check(2, 2, 2)
+
+	// reuse an idle connection
+	c, _ := pool.getAvailableClient(context.Background())
+	if c != c2 {
+		t.Fatal("expected to reuse the idle connection")
+	}
+	check(2, 2, 1)
+
+	// close a connection in use and an idle connection
+	c.client.Close()
+	check(2, 1, 1)
+	c1.client.Close()
+	check(2, 0, 0)
+	if stats.UpstreamConnectionConFail.Count() != 0 {
+		t.Fatalf("unexpected connect failures: %d", stats.UpstreamConnectionConFail.Count())
+	}
+}
+
+func TestConnPoolMaxConnectionsPerHost(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ci := newFakeClusterInfo(0)
+	ci.maxConnsPerHost = 2
+	hc := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address:  ln.Addr().String(),
+			Hostname: ln.Addr().String(),
+		},
+	}
+	host := cluster.NewSimpleHost(hc, ci)
+	pool := NewConnPool(context.TODO(), host).(*connPool)
+	overflow := ci.Stats().UpstreamConnectionHostOverflow
+
+	// open connections up to the limit
+	c1, reason := pool.getAvailableClient(context.Background())
+	if c1 == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	c2, reason := pool.getAvailableClient(context.Background())
+	if c2 == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	if !types.IsHostConnectionOverflow(host) {
+		t.Fatal("expected the host reaches the max connections")
+	}
+
+	// the new connection is refused
+	c, reason := pool.getAvailableClient(context.Background())
+	if c != nil || reason != types.Overflow {
+		t.Fatalf("expected overflow, got reason: %v", reason)
+	}
+	if overflow.Count() != 1 {
+		t.Fatalf("unexpected host overflow stats: %d", overflow.Count())
+	}
+
+	// the idle connection is still reused
+	pool.onStreamDestroy(c2)
+	c, reason = pool.getAvailableClient(context.Background())
+	if c != c2 || reason != "" {
+		t.Fatalf("expected to reuse the idle connection, reason: %v", reason)
+	}
+
+	// a new connection is allowed after a connection is closed
+	c1.client.Close()
+	if types.IsHostConnectionOverflow(host) {
+		t.Fatal("expected the host does not reach the max connections")
+	}
+	c, reason = pool.getAvailableClient(context.Background())
+	if c == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	if overflow.Count() != 1 {
+		t.Fatalf("unexpected host overflow stats: %d", overflow.Count())
+	}
+
+	// the concurrent new connections never exceed the limit
+	pool.onStreamDestroy(c)
+	pool.onStreamDestroy(c2)
+	c.client.Close()
+	c2.client.Close()
+	var wg sync.WaitGroup
+	var created int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c, _ := newActiveClient(context.Background(), pool); c != nil {
+				atomic.AddInt32(&created, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if created != 2 {
+		t.Fatalf("unexpected created connections: %d", created)
+	}
+}
+
+func TestConnPoolUpstreamIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ci := newFakeClusterInfo(0)
+	ci.idleTimeout = 100 * time.Millisecond
+	hc := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address:  ln.Addr().String(),
+			Hostname: ln.Addr().String(),
+		},
+	}
+	host := cluster.NewSimpleHost(hc, ci)
+	pool := NewConnPool(context.TODO(), host).(*connPool)
+	isClosed := func(c *activeClient) bool {
+		pool.clientMux.Lock()
+		defer pool.clientMux.Unlock()
+		return c.closed
+	}
+
+	c, reason := pool.getAvailableClient(context.Background())
+	if c == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	// the connection in use is not closed
+	time.Sleep(150 * time.Millisecond)
+	if isClosed(c) {
+		t.Fatal("expected the connection in use is kept")
+	}
+	// the idle timer is reset by the new stream
+	pool.onStreamDestroy(c)
+	time.Sleep(50 * time.Millisecond)
+	if ac, _ := pool.getAvailableClient(context.Background()); ac != c {
+		t.Fatal("expected to reuse the idle connection")
+	}
+	pool.onStreamDestroy(c)
+	time.Sleep(50 * time.Millisecond)
+	if isClosed(c) {
+		t.Fatal("expected the connection is kept before the idle timeout")
+	}
+	// the idle connection is closed after the idle timeout
+	time.Sleep(100 * time.Millisecond)
+	if !isClosed(c) {
+		t.Fatal("expected the idle connection is closed")
+	}
+	if ci.Stats().UpstreamConnectionIdle.Count() != 0 {
+		t.Fatalf("unexpected idle connections: %d", ci.Stats().UpstreamConnectionIdle.Count())
+	}
+	if ac, _ := pool.getAvailableClient(context.Background()); ac == nil || ac == c {
+		t.Fatal("expected a new connection after the idle connection is closed")
+	}
+}
+
+func TestConnPoolPrefetch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ci := newFakeClusterInfo(0)
+	ci.idleTimeout = 100 * time.Millisecond
+	ci.prefetch = &v2.ConnPoolPrefetchConfig{
+		MinIdleConnections: 2,
+		PreconnectRatio:    3,
+	}
+	hc := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address:  ln.Addr().String(),
+			Hostname: ln.Addr().String(),
+		},
+	}
+	idleCount := func(pool *connPool) int {
+		pool.clientMux.Lock()
+		defer pool.clientMux.Unlock()
+		return len(pool.availableClients)
+	}
+	waitIdle := func(pool *connPool, n int) {
+		for i := 0; i < 50; i++ {
+			if idleCount(pool) == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d idle connections, but got %d", n, idleCount(pool))
+	}
+
+	// the pool is warmed up with the min idle connections
+	pool := NewConnPool(context.TODO(), cluster.NewSimpleHost(hc, ci)).(*connPool)
+	waitIdle(pool, 2)
+	// the pool keeps 3 connections for each request in flight
+	c1, _ := pool.getAvailableClient(context.Background())
+	c2, _ := pool.getAvailableClient(context.Background())
+	if c1 == nil || c2 == nil {
+		t.Fatal("expected to get the prefetched connections")
+	}
+	pool.prefetch()
+	waitIdle(pool, 4)
+	if ci.Stats().UpstreamConnectionTotal.Count() != 6 {
+		t.Fatalf("unexpected connections: %d", ci.Stats().UpstreamConnectionTotal.Count())
+	}
+	// the idle connections beyond the min idle connections are closed by the idle timeout
+	pool.onStreamDestroy(c1)
+	pool.onStreamDestroy(c2)
+	time.Sleep(300 * time.Millisecond)
+	if n := idleCount(pool); n != 2 {
+		t.Fatalf("expected the min idle connections are kept, but got %d", n)
+	}
+	if ci.Stats().UpstreamConnectionIdle.Count() != 2 {
+		t.Fatalf("unexpected idle connections: %d", ci.Stats().UpstreamConnectionIdle.Count())
+	}
+
+	// no connection is prefetched for the unhealthy host
+	host := cluster.NewSimpleHost(hc, ci)
+	host.SetHealthFlag(api.FAILED_ACTIVE_HC)
+	pool = NewConnPool(context.TODO(), host).(*connPool)
+	time.Sleep(50 * time.Millisecond)
+	if n := idleCount(pool); n != 0 {
+		t.Fatalf("expected no connection is prefetched for the unhealthy host, but got %d", n)
+	}
+}
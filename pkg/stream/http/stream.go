@@ -34,6 +34,7 @@ import (
 	"github.com/valyala/fasthttp"
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/protocol"
 	mosnhttp "mosn.io/mosn/pkg/protocol/http"
 	str "mosn.io/mosn/pkg/stream"
@@ -52,12 +53,18 @@ func init() {
 
 const defaultMaxRequestBodySize = 4 * 1024 * 1024
 const defaultMaxHeaderSize = 8 * 1024
+const defaultTunnelBufferSize = 16 * 1024
 
 var (
 	errConnClose = errors.New("connection closed")
 
-	strResponseContinue = []byte("HTTP/1.1 100 Continue\r\n\r\n")
-	strErrorResponse    = []byte("HTTP/1.1 400 Bad Request\r\n\r\n")
+	errHeaderTooLarge = errors.New("header fields too large")
+
+	errNotInformational = errors.New("not an informational response")
+
+	strResponseContinue       = []byte("HTTP/1.1 100 Continue\r\n\r\n")
+	strErrorResponse          = []byte("HTTP/1.1 400 Bad Request\r\n\r\n")
+	strHeaderTooLargeResponse = []byte("HTTP/1.1 431 Request Header Fields Too Large\r\nConnection: close\r\n\r\n")
 
 	HKConnection = []byte("Connection") // header key 'Connection'
 	HVKeepAlive  = []byte("keep-alive") // header value 'keep-alive'
@@ -134,6 +141,10 @@ type streamConnection struct {
 	connClosed chan bool
 
 	br *bufio.Reader
+
+	// upgraded is set if the protocol upgrade is accepted, the connection is a tunnel afterwards
+	upgraded   bool
+	tunnelChan chan types.TunnelReceiver
 }
 
 // types.StreamConnection
@@ -200,6 +211,43 @@ func (conn *streamConnection) Write(p []byte) (n int, err error) {
 	return
 }
 
+// serveTunnel passes the bytes received by the upgraded connection to the tunnel receiver
+// until the connection is closed, the bytes already buffered by the reader are passed first.
+func (conn *streamConnection) serveTunnel() {
+	var receiver types.TunnelReceiver
+	select {
+	case receiver = <-conn.tunnelChan:
+	case <-conn.connClosed:
+		return
+	}
+	defer receiver.OnTunnelClose()
+
+	buf := make([]byte, defaultTunnelBufferSize)
+	for {
+		n, err := conn.br.Read(buf)
+		if n > 0 {
+			// the buffer is written by the peer connection asynchronously
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			receiver.OnTunnelData(buffer.NewIoBufferBytes(data))
+		}
+		if err != nil {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[stream] [http] tunnel closed. Connection = %d, err = %v", conn.conn.ID(), err)
+			}
+			return
+		}
+	}
+}
+
+func (conn *streamConnection) tunnel(receiver types.TunnelReceiver) {
+	select {
+	case conn.tunnelChan <- receiver:
+	default:
+		log.DefaultLogger.Errorf("[stream] [http] connection is tunneled already. Connection = %d", conn.conn.ID())
+	}
+}
+
 func (conn *streamConnection) Reset(reason types.StreamResetReason) {
 	// We need to set 'conn.resetReason' before 'close(conn.bufChan)'
 	// because streamConnection's Read will do some processing depends on it.
@@ -231,6 +279,7 @@ func newClientStreamConnection(ctx context.Context, connection types.ClientConne
 			bufChan:    make(chan buffer.IoBuffer),
 			endRead:    make(chan struct{}),
 			connClosed: make(chan bool, 1),
+			tunnelChan: make(chan types.TunnelReceiver, 1),
 		},
 		connectionEventListener:       connCallbacks,
 		streamConnectionEventListener: streamConnCallbacks,
@@ -287,8 +336,13 @@ func (conn *clientStreamConnection) serve() {
 			s.response.SkipBody = true
 		}
 
-		// 1. blocking read using fasthttp.Response.Read
+		// 1. blocking read using fasthttp.Response.Read,
+		// the informational responses are forwarded before the final response is read
 		err := s.response.Read(conn.br)
+		for err == nil && isInformational(s.response.StatusCode()) {
+			s.handleInformational()
+			err = s.response.Read(conn.br)
+		}
 		if err != nil {
 			if s != nil {
 				log.Proxy.Errorf(s.connection.context, "[stream] [http] client stream connection wait response error: %s", err)
@@ -305,6 +359,14 @@ func (conn *clientStreamConnection) serve() {
 			log.Proxy.Debugf(s.stream.ctx, "[stream] [http] receive response, requestId = %v", s.stream.id)
 		}
 
+		// the connection is closed by the connpool after the local reset
+		if parseStreamConfig(s.ctx).headerExceeded(s.response.Header.VisitAll) {
+			log.Proxy.Errorf(s.connection.context, "[stream] [http] response header exceeds the limits, requestId = %v", s.stream.id)
+			incHeaderExceeded(s.ctx, metrics.DownstreamRespHeaderExceed)
+			s.ResetStream(types.StreamLocalReset)
+			return
+		}
+
 		// 2. response processing
 		resetConn := false
 		if s.response.ConnectionClose() {
@@ -317,12 +379,31 @@ func (conn *clientStreamConnection) serve() {
 			s.connection.streamConnectionEventListener.OnGoAway()
 		}
 
+		// 4. the upgraded connection is a tunnel, which is never reused by the connpool
+		upgraded := s.response.StatusCode() == http.StatusSwitchingProtocols && s.request.Header.ConnectionUpgrade()
+		if upgraded {
+			conn.upgraded = true
+			if listener, ok := conn.streamConnectionEventListener.(upgradeListener); ok {
+				listener.OnUpgrade()
+			}
+		}
+
 		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
 			s.handleResponse()
 		}
+
+		if upgraded {
+			conn.serveTunnel()
+			return
+		}
 	}
 }
 
+// upgradeListener is implemented by the connpool, which is notified that the connection is upgraded
+type upgradeListener interface {
+	OnUpgrade()
+}
+
 func (conn *clientStreamConnection) GoAway() {}
 
 func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver types.StreamReceiveListener) types.StreamSender {
@@ -376,6 +457,11 @@ func (conn *clientStreamConnection) CheckReasonError(connected bool, event api.C
 type StreamConfig struct {
 	MaxHeaderSize      int `json:"max_header_size,omitempty"`
 	MaxRequestBodySize int `json:"max_request_body_size,omitempty"`
+	// MaxHeaderCount limits the number of the request and response headers, 0 means no limit.
+	MaxHeaderCount int `json:"max_header_count,omitempty"`
+	// MaxHeaderBytes limits the total bytes of the request and response header fields, 0 means no limit.
+	// The header block is still limited by MaxHeaderSize when it is read.
+	MaxHeaderBytes int `json:"max_header_bytes,omitempty"`
 }
 
 var defaultStreamConfig = StreamConfig{
@@ -392,6 +478,30 @@ var defaultStreamConfig = StreamConfig{
 func SetDefaultStreamConfig(c StreamConfig) {
 	defaultStreamConfig.MaxHeaderSize = c.MaxHeaderSize
 	defaultStreamConfig.MaxRequestBodySize = c.MaxRequestBodySize
+	defaultStreamConfig.MaxHeaderCount = c.MaxHeaderCount
+	defaultStreamConfig.MaxHeaderBytes = c.MaxHeaderBytes
+}
+
+// headerExceeded reports whether the headers visited exceed the limits
+func (c StreamConfig) headerExceeded(visitAll func(f func(key, value []byte))) bool {
+	if c.MaxHeaderCount <= 0 && c.MaxHeaderBytes <= 0 {
+		return false
+	}
+	count, size := 0, 0
+	visitAll(func(key, value []byte) {
+		count++
+		size += len(key) + len(value)
+	})
+	return (c.MaxHeaderCount > 0 && count > c.MaxHeaderCount) ||
+		(c.MaxHeaderBytes > 0 && size > c.MaxHeaderBytes)
+}
+
+// incHeaderExceeded counts the headers exceeded in the listener stats
+func incHeaderExceeded(ctx context.Context, key string) {
+	listenerName, _ := variable.Get(ctx, types.VariableListenerName)
+	if name, ok := listenerName.(string); ok {
+		metrics.NewListenerStats(name).Counter(key).Inc(1)
+	}
 }
 
 func streamConfigHandler(v interface{}) interface{} {
@@ -454,6 +564,7 @@ func newServerStreamConnection(ctx context.Context, connection api.Connection,
 			bufChan:    make(chan buffer.IoBuffer),
 			endRead:    make(chan struct{}),
 			connClosed: make(chan bool, 1),
+			tunnelChan: make(chan types.TunnelReceiver, 1),
 		},
 		config:                   parseStreamConfig(ctx),
 		contextManager:           str.NewContextManager(ctx),
@@ -510,9 +621,11 @@ func (conn *serverStreamConnection) serve() {
 		// 2. blocking read using fasthttp.Request.Read
 		err := request.ReadLimitBody(conn.br, maxRequestBodySize)
 		if err == nil {
-			// 3. 'Expect: 100-continue' request handling.
-			// See http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html for details.
-			if request.MayContinue() {
+			if conn.config.headerExceeded(request.Header.VisitAll) {
+				err = errHeaderTooLarge
+			} else if request.MayContinue() {
+				// 3. 'Expect: 100-continue' request handling.
+				// See http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html for details.
 				// Send 'HTTP/1.1 100 Continue' response.
 				conn.conn.Write(buffer.NewIoBufferBytes(strResponseContinue))
 
@@ -535,7 +648,12 @@ func (conn *serverStreamConnection) serve() {
 					conn.conn.ID(), conn.conn.LocalAddr(), conn.conn.RemoteAddr(), err)
 
 				// write error response
-				conn.conn.Write(buffer.NewIoBufferBytes(strErrorResponse))
+				if err == errHeaderTooLarge {
+					incHeaderExceeded(ctx, metrics.DownstreamReqHeaderExceed)
+					conn.conn.Write(buffer.NewIoBufferBytes(strHeaderTooLargeResponse))
+				} else {
+					conn.conn.Write(buffer.NewIoBufferBytes(strErrorResponse))
+				}
 
 				// close connection with flush
 				conn.conn.Close(api.FlushWrite, api.LocalClose)
@@ -543,6 +661,12 @@ func (conn *serverStreamConnection) serve() {
 			return
 		}
 
+		// the authority of the CONNECT request is the destination of the tunnel,
+		// use it as the host, so the route and the upstream host are chosen by it
+		if isConnect(&request.Header) {
+			request.Header.SetHostBytes(request.Header.RequestURI())
+		}
+
 		id := protocol.GenerateID()
 		s := &buffers.serverStream
 
@@ -599,6 +723,12 @@ func (conn *serverStreamConnection) serve() {
 			return
 		}
 
+		// 6. the upgraded connection is a tunnel, no more requests
+		if conn.upgraded {
+			conn.serveTunnel()
+			return
+		}
+
 		conn.contextManager.Next()
 	}
 }
@@ -770,10 +900,41 @@ func (s *clientStream) handleResponse() {
 	}
 }
 
+// handleInformational passes the informational response to the receiver which accepts it,
+// the stream is not ended.
+func (s *clientStream) handleInformational() {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "[stream] [http] receive informational response %d, requestId = %v", s.response.StatusCode(), s.id)
+	}
+	listener, ok := s.receiver.(types.StreamInformationalReceiveListener)
+	if !ok {
+		return
+	}
+	// the response is reused by the final response
+	header := &fasthttp.ResponseHeader{}
+	s.response.Header.CopyTo(header)
+	listener.OnReceiveInformational(s.ctx, mosnhttp.ResponseHeader{ResponseHeader: header})
+}
+
+// isInformational returns true if the status is an informational (1xx) status except 101 Switching Protocols,
+// which is the final response of the protocol upgrade.
+func isInformational(statusCode int) bool {
+	return statusCode >= http.StatusContinue && statusCode < http.StatusOK && statusCode != http.StatusSwitchingProtocols
+}
+
 func (s *clientStream) GetStream() types.Stream {
 	return s
 }
 
+// types.TunnelStream
+func (s *clientStream) UpgradedConnection() (api.Connection, bool) {
+	return s.connection.conn, s.connection.upgraded
+}
+
+func (s *clientStream) Tunnel(receiver types.TunnelReceiver) {
+	s.connection.tunnel(receiver)
+}
+
 // types.StreamSender for response
 type serverStream struct {
 	stream
@@ -823,6 +984,36 @@ func (s *serverStream) AppendHeaders(context context.Context, headersIn types.He
 	return nil
 }
 
+// AppendInformationalHeaders sends the informational response before the final response,
+// the response is dropped if the client does not support it.
+func (s *serverStream) AppendInformationalHeaders(context context.Context, headersIn types.HeaderMap) error {
+	headers, ok := headersIn.(mosnhttp.ResponseHeader)
+	if !ok || !isInformational(headers.StatusCode()) {
+		return errNotInformational
+	}
+	statusCode := headers.StatusCode()
+	// the informational responses are not defined by http/1.0, and the 100 Continue is replied
+	// already if the client expects it.
+	if !s.request.Header.IsHTTP11() || (statusCode == http.StatusContinue && s.request.MayContinue()) {
+		return nil
+	}
+
+	buf := buffer.GetIoBuffer(256)
+	buf.WriteString("HTTP/1.1 " + strconv.Itoa(statusCode) + " " + http.StatusText(statusCode) + "\r\n")
+	headers.VisitAll(func(key, value []byte) {
+		// the informational response has no body
+		if k := string(key); k == "Content-Type" || k == "Content-Length" {
+			return
+		}
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(value)
+		buf.WriteString("\r\n")
+	})
+	buf.WriteString("\r\n")
+	return s.connection.conn.Write(buf)
+}
+
 func (s *serverStream) AppendData(context context.Context, data buffer.IoBuffer, endStream bool) error {
 	// SetBodyRaw sets response body and could avoid copying it
 	s.response.SetBodyRaw(data.Bytes())
@@ -847,6 +1038,12 @@ func (s *serverStream) endStream() {
 	if s.request.Header.IsHead() {
 		s.response.SkipBody = true
 	}
+	// the successful CONNECT response has no body, the connection is a tunnel afterwards
+	connected := isConnect(&s.request.Header) && s.response.StatusCode() >= http.StatusOK &&
+		s.response.StatusCode() < http.StatusMultipleChoices
+	if connected {
+		s.response.SkipBody = true
+	}
 
 	// check if we need close connection
 	if s.connection.close || s.request.Header.ConnectionClose() {
@@ -864,6 +1061,11 @@ func (s *serverStream) endStream() {
 	}
 	defer s.DestroyStream()
 
+	// the connection is a tunnel if the protocol upgrade or the CONNECT is accepted
+	if connected || (s.response.StatusCode() == http.StatusSwitchingProtocols && s.request.Header.ConnectionUpgrade()) {
+		s.connection.upgraded = true
+	}
+
 	s.doSend()
 	s.responseDoneChan <- true
 
@@ -923,6 +1125,20 @@ func (s *serverStream) GetStream() types.Stream {
 	return s
 }
 
+// types.TunnelStream
+func (s *serverStream) UpgradedConnection() (api.Connection, bool) {
+	return s.connection.conn, s.connection.upgraded
+}
+
+func (s *serverStream) Tunnel(receiver types.TunnelReceiver) {
+	s.connection.tunnel(receiver)
+}
+
+// isConnect returns true if the request is a CONNECT request
+func isConnect(header *fasthttp.RequestHeader) bool {
+	return string(header.Method()) == http.MethodConnect
+}
+
 // consider host, method, path are necessary, but check querystring
 func injectCtxVarFromProtocolHeaders(ctx context.Context, header mosnhttp.RequestHeader, uri *fasthttp.URI) {
 	// 1. host
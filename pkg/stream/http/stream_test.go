@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -523,3 +524,224 @@ func randomBytes(t *testing.T, n int, rand *rand.Rand) []byte {
 	}
 	return r
 }
+
+func TestStreamConfigHeaderExceeded(t *testing.T) {
+	v := map[string]interface{}{
+		"max_header_count": 3,
+		"max_header_bytes": 64,
+	}
+	cfg := streamConfigHandler(v).(StreamConfig)
+	assert.Equal(t, 3, cfg.MaxHeaderCount)
+	assert.Equal(t, 64, cfg.MaxHeaderBytes)
+	assert.Equal(t, defaultMaxHeaderSize, cfg.MaxHeaderSize)
+
+	header := &fasthttp.ResponseHeader{}
+	header.Set("k1", "v1")
+	header.Set("k2", "v2")
+	assert.False(t, cfg.headerExceeded(header.VisitAll))
+	// too many headers
+	header.Set("k3", "v3")
+	header.Set("k4", "v4")
+	assert.True(t, cfg.headerExceeded(header.VisitAll))
+	// too large headers
+	header = &fasthttp.ResponseHeader{}
+	header.Set("k1", string(bytes.Repeat([]byte("v"), 64)))
+	assert.True(t, cfg.headerExceeded(header.VisitAll))
+	// no limits
+	assert.False(t, defaultStreamConfig.headerExceeded(header.VisitAll))
+}
+
+type mockStreamDetect struct {
+	received chan api.HeaderMap
+}
+
+func (m *mockStreamDetect) OnGoAway() {}
+
+func (m *mockStreamDetect) NewStreamDetect(ctx context.Context, sender types.StreamSender, span api.Span) types.StreamReceiveListener {
+	return m
+}
+
+func (m *mockStreamDetect) OnReceive(ctx context.Context, headers api.HeaderMap, data buffer.IoBuffer, trailers api.HeaderMap) {
+	m.received <- headers
+}
+
+func (m *mockStreamDetect) OnDecodeError(ctx context.Context, err error, headers api.HeaderMap) {}
+
+func TestRequestHeaderExceeded(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error %v", err)
+	}
+	defer l.Close()
+
+	httpConfig := map[string]interface{}{
+		"max_header_count": 3,
+		"max_header_bytes": 64,
+	}
+	proxyGeneralExtendConfig := make(map[api.ProtocolName]interface{})
+	proxyGeneralExtendConfig[protocol.HTTP1] = streamConfigHandler(httpConfig)
+
+	serve := func(request string) (api.Connection, net.Conn, *mockStreamDetect) {
+		rawc, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("net.Dial error %v", err)
+		}
+		peer, err := l.Accept()
+		if err != nil {
+			t.Fatalf("accept error %v", err)
+		}
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.Set(ctx, types.VariableProxyGeneralConfig, proxyGeneralExtendConfig)
+		connection := network.NewServerConnection(context.Background(), rawc, nil)
+		detect := &mockStreamDetect{received: make(chan api.HeaderMap, 1)}
+		ssc := newServerStreamConnection(ctx, connection, detect)
+		ssc.Dispatch(buffer.NewIoBufferString(request))
+		return connection, peer, detect
+	}
+
+	t.Run("compliant request", func(t *testing.T) {
+		connection, peer, detect := serve("GET / HTTP/1.1\r\nHost: test.com\r\nK1: v1\r\n\r\n")
+		defer peer.Close()
+		select {
+		case headers := <-detect.received:
+			v, _ := headers.Get("K1")
+			assert.Equal(t, "v1", v)
+		case <-time.After(time.Second):
+			t.Fatal("compliant request should be received")
+		}
+		assert.NotEqual(t, api.ConnClosed, connection.State())
+	})
+
+	for name, request := range map[string]string{
+		"too many headers":  "GET / HTTP/1.1\r\nHost: test.com\r\nK1: v1\r\nK2: v2\r\nK3: v3\r\n\r\n",
+		"too large headers": "GET / HTTP/1.1\r\nHost: test.com\r\nK1: " + strings.Repeat("v", 64) + "\r\n\r\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			connection, peer, detect := serve(request)
+			defer peer.Close()
+			peer.SetReadDeadline(time.Now().Add(time.Second))
+			resp, err := bufio.NewReader(peer).ReadString('\n')
+			assert.Nil(t, err)
+			assert.Equal(t, "HTTP/1.1 431 Request Header Fields Too Large\r\n", resp)
+			assert.Equal(t, api.ConnClosed, connection.State())
+			assert.Len(t, detect.received, 0)
+		})
+	}
+}
+
+// informationalReceiver records the status of the responses in order
+type informationalReceiver struct {
+	received chan api.HeaderMap
+}
+
+func (r *informationalReceiver) OnReceiveInformational(ctx context.Context, headers api.HeaderMap) {
+	r.received <- headers
+}
+
+func (r *informationalReceiver) OnReceive(ctx context.Context, headers api.HeaderMap, data buffer.IoBuffer, trailers api.HeaderMap) {
+	r.received <- headers
+}
+
+func (r *informationalReceiver) OnDecodeError(ctx context.Context, err error, headers api.HeaderMap) {
+}
+
+func TestClientStreamInformational(t *testing.T) {
+	csc := &clientStreamConnection{
+		streamConnection: streamConnection{
+			bufChan:    make(chan buffer.IoBuffer),
+			endRead:    make(chan struct{}),
+			connClosed: make(chan bool, 1),
+		},
+		requestSent: make(chan bool, 1),
+	}
+	csc.br = bufio.NewReaderSize(csc, defaultMaxHeaderSize)
+	receiver := &informationalReceiver{received: make(chan api.HeaderMap, 3)}
+	csc.NewStream(variable.NewVariableContext(context.Background()), receiver)
+	csc.requestSent <- true
+	go csc.serve()
+	defer func() {
+		csc.connClosed <- true
+	}()
+
+	go csc.Dispatch(buffer.NewIoBufferString("HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello"))
+
+	for _, expected := range []int{103, 200} {
+		select {
+		case headers := <-receiver.received:
+			assert.Equal(t, expected, headers.(http.ResponseHeader).StatusCode())
+			if expected == 103 {
+				link, _ := headers.Get("Link")
+				assert.Equal(t, "</style.css>; rel=preload", link)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("response %d is not received", expected)
+		}
+	}
+}
+
+func TestServerStreamInformational(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error %v", err)
+	}
+	defer l.Close()
+	rawc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial error %v", err)
+	}
+	peer, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept error %v", err)
+	}
+	defer peer.Close()
+	connection := network.NewServerConnection(context.Background(), rawc, nil)
+	defer connection.Close(api.NoFlush, api.LocalClose)
+
+	newStream := func(request string) *serverStream {
+		var hb httpBuffers
+		assert.Nil(t, hb.serverRequest.Read(bufio.NewReader(strings.NewReader(request))))
+		return &serverStream{
+			stream: stream{
+				request:  &hb.serverRequest,
+				response: &hb.serverResponse,
+			},
+			connection: &serverStreamConnection{
+				streamConnection: streamConnection{conn: connection},
+			},
+		}
+	}
+	newHeaders := func(status int) http.ResponseHeader {
+		header := &fasthttp.ResponseHeader{}
+		header.SetStatusCode(status)
+		header.Set("Link", "</style.css>; rel=preload")
+		return http.ResponseHeader{ResponseHeader: header}
+	}
+	ctx := context.Background()
+
+	// the final response is not an informational response
+	s := newStream("GET / HTTP/1.1\r\nHost: test.com\r\n\r\n")
+	assert.Equal(t, errNotInformational, s.AppendInformationalHeaders(ctx, newHeaders(200)))
+	assert.Equal(t, errNotInformational, s.AppendInformationalHeaders(ctx, newHeaders(101)))
+	// the http/1.0 client does not support the informational responses
+	s = newStream("GET / HTTP/1.0\r\nHost: test.com\r\n\r\n")
+	assert.Nil(t, s.AppendInformationalHeaders(ctx, newHeaders(103)))
+	// the 100 Continue is replied already
+	s = newStream("POST / HTTP/1.1\r\nHost: test.com\r\nExpect: 100-continue\r\nContent-Length: 0\r\n\r\n")
+	assert.Nil(t, s.AppendInformationalHeaders(ctx, newHeaders(100)))
+
+	s = newStream("GET / HTTP/1.1\r\nHost: test.com\r\n\r\n")
+	assert.Nil(t, s.AppendInformationalHeaders(ctx, newHeaders(103)))
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	br := bufio.NewReader(peer)
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		assert.Nil(t, err)
+		if line == "\r\n" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"HTTP/1.1 103 Early Hints\r\n", "Link: </style.css>; rel=preload\r\n"}, lines)
+}
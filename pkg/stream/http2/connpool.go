@@ -21,24 +21,34 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol"
 	str "mosn.io/mosn/pkg/stream"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
 	"mosn.io/pkg/variable"
 )
 
+// defaultPendingTimeout is how long a request waits for an available stream if the pending timeout is not configured
+const defaultPendingTimeout = time.Second
+
 // types.ConnectionPool
-// activeClient used as connected client
+// activeClients used as connected clients, the streams are multiplexed on the clients
 // host is the upstream
 type connPool struct {
-	activeClient *activeClient
-	host         atomic.Value
-	tlsHash      *types.HashValue
+	activeClients []*activeClient
+	host          atomic.Value
+	tlsHash       *types.HashValue
 
 	mux sync.Mutex
+	// waiters are the requests waiting for an available stream
+	waiters []chan struct{}
+	// shutdown closes the clients once their streams are destroyed, protected by the lock
+	shutdown bool
 }
 
 // NewConnPool
@@ -76,31 +86,20 @@ func (p *connPool) CheckAndInit(ctx context.Context) bool {
 }
 
 func (p *connPool) NewStream(ctx context.Context, responseDecoder types.StreamReceiveListener) (types.Host, types.StreamSender, types.PoolFailureReason) {
-	activeClient := func() *activeClient {
-		p.mux.Lock()
-		defer p.mux.Unlock()
-		if p.activeClient != nil && atomic.LoadUint32(&p.activeClient.goaway) == 1 {
-			p.activeClient = nil
-		}
-		if p.activeClient == nil {
-			p.activeClient = newActiveClient(ctx, p)
-		}
-		return p.activeClient
-	}()
-
 	host := p.Host()
-	if activeClient == nil {
-		return host, nil, types.ConnectionFailure
-	}
-
-	_ = variable.Set(ctx, types.VariableUpstreamConnectionID, activeClient.client.ConnID())
-
 	if !host.ClusterInfo().ResourceManager().Requests().CanCreate() {
 		host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
 		return host, nil, types.Overflow
 	}
 
+	activeClient, reason := p.getAvailableClient(ctx)
+	if activeClient == nil {
+		return host, nil, reason
+	}
+
+	_ = variable.Set(ctx, types.VariableUpstreamConnectionID, activeClient.client.ConnID())
+
 	atomic.AddUint64(&activeClient.totalStream, 1)
 	host.HostStats().UpstreamRequestTotal.Inc(1)
 	host.HostStats().UpstreamRequestActive.Inc(1)
@@ -113,15 +112,161 @@ func (p *connPool) NewStream(ctx context.Context, responseDecoder types.StreamRe
 	return host, streamEncoder, ""
 }
 
+// getAvailableClient returns a client whose streams do not reach the max concurrent streams,
+// a new client is created if all the clients are saturated and the max connections is not reached,
+// otherwise the request waits for an available stream up to the max pending requests.
+// The returned client has reserved a stream, which is released in onStreamDestroy.
+func (p *connPool) getAvailableClient(ctx context.Context) (*activeClient, types.PoolFailureReason) {
+	host := p.Host()
+	var cfg v2.Http2ConnPoolConfig
+	if hc, ok := host.ClusterInfo().(types.Http2ConnPoolCluster); ok && hc.Http2ConnPool() != nil {
+		cfg = *hc.Http2ConnPool()
+	}
+	pendingTimeout := cfg.PendingTimeout.Duration
+	if pendingTimeout <= 0 {
+		pendingTimeout = defaultPendingTimeout
+	}
+	var timer *time.Timer
+	for {
+		p.mux.Lock()
+		for _, ac := range p.activeClients {
+			if atomic.LoadUint32(&ac.goaway) == 1 {
+				continue
+			}
+			if cfg.MaxConcurrentStreams == 0 || ac.activeStream < cfg.MaxConcurrentStreams {
+				ac.activeStream++
+				ac.stopIdleTimer()
+				p.mux.Unlock()
+				return ac, ""
+			}
+		}
+		if p.canCreateClient(host, &cfg) {
+			ac := newActiveClient(ctx, p)
+			if ac == nil {
+				p.mux.Unlock()
+				return nil, types.ConnectionFailure
+			}
+			ac.activeStream++
+			p.activeClients = append(p.activeClients, ac)
+			p.mux.Unlock()
+			return ac, ""
+		}
+		// all the clients are saturated, waits for an available stream
+		if uint32(len(p.waiters)) >= cfg.MaxPendingRequests {
+			p.mux.Unlock()
+			host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+			host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+			return nil, types.Overflow
+		}
+		waiter := make(chan struct{})
+		p.waiters = append(p.waiters, waiter)
+		p.mux.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(pendingTimeout)
+			defer timer.Stop()
+		}
+		host.ClusterInfo().Stats().UpstreamRequestPending.Inc(1)
+		select {
+		case <-waiter:
+			host.ClusterInfo().Stats().UpstreamRequestPending.Dec(1)
+		case <-timer.C:
+			host.ClusterInfo().Stats().UpstreamRequestPending.Dec(1)
+			p.mux.Lock()
+			p.removeWaiter(waiter)
+			p.mux.Unlock()
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("http2 connPool wait for an available stream timeout, host: %s", host.AddressString())
+			}
+			host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
+			host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
+			return nil, types.Overflow
+		}
+	}
+}
+
+// canCreateClient checks whether a new client can be created, must be called with the lock held
+func (p *connPool) canCreateClient(host types.Host, cfg *v2.Http2ConnPoolConfig) bool {
+	var clients uint32
+	for _, ac := range p.activeClients {
+		if atomic.LoadUint32(&ac.goaway) == 0 {
+			clients++
+		}
+	}
+	if clients == 0 {
+		return true
+	}
+	// all the streams share one connection
+	if cfg.MaxConcurrentStreams == 0 {
+		return false
+	}
+	if cfg.MaxConnections != 0 && clients >= cfg.MaxConnections {
+		return false
+	}
+	if types.IsHostConnectionOverflow(host) {
+		host.ClusterInfo().Stats().UpstreamConnectionHostOverflow.Inc(1)
+		return false
+	}
+	return true
+}
+
+// notifyWaiter wakes up the first waiting request, must be called with the lock held
+func (p *connPool) notifyWaiter() {
+	if len(p.waiters) == 0 {
+		return
+	}
+	close(p.waiters[0])
+	p.waiters = p.waiters[1:]
+}
+
+// removeWaiter removes the timeout waiter, must be called with the lock held
+func (p *connPool) removeWaiter(waiter chan struct{}) {
+	for i, w := range p.waiters {
+		if w == waiter {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+	// the waiter is notified before removed, passes the notification to the next one
+	p.notifyWaiter()
+}
+
+// removeClient removes the closed client, must be called with the lock held
+func (p *connPool) removeClient(client *activeClient) {
+	for i, ac := range p.activeClients {
+		if ac == client {
+			p.activeClients = append(p.activeClients[:i], p.activeClients[i+1:]...)
+			return
+		}
+	}
+}
+
 func (p *connPool) Close() {
-	activeClient := p.activeClient
-	if activeClient != nil {
-		activeClient.client.Close()
+	p.mux.Lock()
+	clients := p.activeClients
+	p.mux.Unlock()
+	for _, ac := range clients {
+		ac.client.Close()
 	}
 }
 
+// Shutdown closes the idle clients, the clients with streams in use are closed
+// once their streams are destroyed, no more streams are created on them.
 func (p *connPool) Shutdown() {
-	//TODO: http2 connpool do nothing for shutdown
+	var idle []*activeClient
+	p.mux.Lock()
+	p.shutdown = true
+	for _, ac := range p.activeClients {
+		atomic.StoreUint32(&ac.goaway, 1)
+		if ac.activeStream == 0 {
+			ac.stopIdleTimer()
+			idle = append(idle, ac)
+		}
+	}
+	p.mux.Unlock()
+	for _, ac := range idle {
+		ac.client.Close()
+	}
 }
 
 func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionEvent) {
@@ -140,11 +285,11 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 				host.ClusterInfo().Stats().UpstreamConnectionRemoteCloseWithActiveRequest.Inc(1)
 			}
 		}
-		if atomic.LoadUint32(&client.goaway) == 1 {
-			return
-		}
 		p.mux.Lock()
-		p.activeClient = nil
+		p.removeClient(client)
+		client.stopIdleTimer()
+		// a new client can be created for the waiting request
+		p.notifyWaiter()
 		p.mux.Unlock()
 	} else if event == api.ConnectTimeout {
 		host.HostStats().UpstreamRequestTimeout.Inc(1)
@@ -156,12 +301,69 @@ func (p *connPool) onConnectionEvent(client *activeClient, event api.ConnectionE
 }
 
 func (p *connPool) onStreamDestroy(client *activeClient) {
+	closeClient := false
+	p.mux.Lock()
+	if client.activeStream > 0 {
+		client.activeStream--
+	}
+	if client.activeStream == 0 {
+		if p.shutdown {
+			closeClient = true
+		} else {
+			p.startIdleTimer(client)
+		}
+	}
+	p.notifyWaiter()
+	p.mux.Unlock()
+	if closeClient {
+		client.client.Close()
+	}
+
 	host := p.Host()
 	host.HostStats().UpstreamRequestActive.Dec(1)
 	host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
 	host.ClusterInfo().ResourceManager().Requests().Decrease()
 }
 
+// startIdleTimer closes the client if no stream is created on it in the upstream idle timeout,
+// must be called with the lock held
+func (p *connPool) startIdleTimer(client *activeClient) {
+	ci, ok := p.Host().ClusterInfo().(types.UpstreamIdleTimeoutCluster)
+	if !ok || ci.UpstreamIdleTimeout() <= 0 {
+		return
+	}
+	client.stopIdleTimer()
+	client.idleTimer = utils.NewTimer(ci.UpstreamIdleTimeout(), func() {
+		p.onIdleTimeout(client)
+	})
+}
+
+func (p *connPool) onIdleTimeout(client *activeClient) {
+	p.mux.Lock()
+	// a stream may be created on the client before the lock is acquired
+	if client.activeStream != 0 {
+		p.mux.Unlock()
+		return
+	}
+	idle := false
+	for _, ac := range p.activeClients {
+		if ac == client {
+			idle = true
+			break
+		}
+	}
+	// removes the client in the lock, so that no more streams are created on it
+	p.removeClient(client)
+	p.mux.Unlock()
+
+	if idle {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("http2 connPool close idle connection, host: %s", p.Host().AddressString())
+		}
+		client.client.Close()
+	}
+}
+
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
 	host := p.Host()
 	if reason == types.StreamConnectionTermination || reason == types.StreamConnectionFailed {
@@ -191,6 +393,10 @@ type activeClient struct {
 	closeWithActiveReq bool
 	totalStream        uint64
 	goaway             uint32
+	// activeStream is the streams in use, protected by the pool's lock
+	activeStream uint32
+	// idleTimer closes the client without streams in use, protected by the pool's lock
+	idleTimer *utils.Timer
 }
 
 func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
@@ -226,6 +432,13 @@ func newActiveClient(ctx context.Context, pool *connPool) *activeClient {
 	return ac
 }
 
+func (ac *activeClient) stopIdleTimer() {
+	if ac.idleTimer != nil {
+		ac.idleTimer.Stop()
+		ac.idleTimer = nil
+	}
+}
+
 func (ac *activeClient) OnEvent(event api.ConnectionEvent) {
 	ac.pool.onConnectionEvent(ac, event)
 }
@@ -241,5 +454,12 @@ func (ac *activeClient) OnResetStream(reason types.StreamResetReason) {
 
 // types.StreamConnectionEventListener
 func (ac *activeClient) OnGoAway() {
-	atomic.StoreUint32(&ac.goaway, 1)
+	if !atomic.CompareAndSwapUint32(&ac.goaway, 0, 1) {
+		return
+	}
+	ac.pool.Host().ClusterInfo().Stats().UpstreamConnectionGoAway.Inc(1)
+	// a new client can be created for the waiting request
+	ac.pool.mux.Lock()
+	ac.pool.notifyWaiter()
+	ac.pool.mux.Unlock()
 }
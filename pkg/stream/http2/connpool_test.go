@@ -0,0 +1,334 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+type fakeClusterInfo struct {
+	types.ClusterInfo
+	stats       types.ClusterStats
+	poolConf    *v2.Http2ConnPoolConfig
+	idleTimeout time.Duration
+}
+
+func newFakeClusterInfo(poolConf *v2.Http2ConnPoolConfig) *fakeClusterInfo {
+	return &fakeClusterInfo{
+		poolConf: poolConf,
+		stats: types.ClusterStats{
+			UpstreamRequestPendingOverflow:                 metrics.NewCounter(),
+			UpstreamConnectionLocalCloseWithActiveRequest:  metrics.NewCounter(),
+			UpstreamConnectionRemoteCloseWithActiveRequest: metrics.NewCounter(),
+			UpstreamConnectionTotal:                        metrics.NewCounter(),
+			UpstreamConnectionActive:                       metrics.NewCounter(),
+			UpstreamConnectionConFail:                      metrics.NewCounter(),
+			UpstreamBytesReadTotal:                         metrics.NewCounter(),
+			UpstreamBytesWriteTotal:                        metrics.NewCounter(),
+			UpstreamRequestTotal:                           metrics.NewCounter(),
+			UpstreamRequestActive:                          metrics.NewCounter(),
+			UpstreamRequestPending:                         metrics.NewCounter(),
+			UpstreamRequestTimeout:                         metrics.NewCounter(),
+			UpstreamConnectionHostOverflow:                 metrics.NewCounter(),
+			UpstreamConnectionGoAway:                       metrics.NewCounter(),
+		},
+	}
+}
+
+func (ci *fakeClusterInfo) Name() string {
+	return "test"
+}
+
+func (ci *fakeClusterInfo) Http2ConnPool() *v2.Http2ConnPoolConfig {
+	return ci.poolConf
+}
+
+func (ci *fakeClusterInfo) UpstreamIdleTimeout() time.Duration {
+	return ci.idleTimeout
+}
+
+func (ci *fakeClusterInfo) TLSMng() types.TLSClientContextManager {
+	return &fakeTLSContextManager{}
+}
+
+func (ci *fakeClusterInfo) ConnectTimeout() time.Duration {
+	return network.DefaultConnectTimeout
+}
+
+func (ci *fakeClusterInfo) IdleTimeout() time.Duration {
+	return 0
+}
+
+func (ci *fakeClusterInfo) ConnBufferLimitBytes() uint32 {
+	return 0
+}
+
+func (ci *fakeClusterInfo) Stats() types.ClusterStats {
+	return ci.stats
+}
+
+type fakeTLSContextManager struct {
+	types.TLSContextManager
+}
+
+func (mg *fakeTLSContextManager) Enabled() bool {
+	return false
+}
+
+func (mg *fakeTLSContextManager) HashValue() *types.HashValue {
+	return nil
+}
+
+func (mg *fakeTLSContextManager) Fallback() bool {
+	return false
+}
+
+// newTestConnPool creates a pool connects to a tcp server, the server and the pool are closed by the returned func
+func newTestConnPool(t *testing.T, poolConf *v2.Http2ConnPoolConfig) (*connPool, *fakeClusterInfo, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	ci := newFakeClusterInfo(poolConf)
+	hc := v2.Host{
+		HostConfig: v2.HostConfig{
+			Address:  ln.Addr().String(),
+			Hostname: ln.Addr().String(),
+		},
+	}
+	host := cluster.NewSimpleHost(hc, ci)
+	pool := NewConnPool(context.TODO(), host).(*connPool)
+	return pool, ci, func() {
+		pool.Close()
+		ln.Close()
+	}
+}
+
+func TestConnPoolSingleConnection(t *testing.T) {
+	pool, ci, closeFunc := newTestConnPool(t, nil)
+	defer closeFunc()
+	// all the streams share one connection by default
+	c, reason := pool.getAvailableClient(context.Background())
+	if c == nil || reason != "" {
+		t.Fatalf("get client failed: %v", reason)
+	}
+	for i := 0; i < 10; i++ {
+		if ac, _ := pool.getAvailableClient(context.Background()); ac != c {
+			t.Fatalf("#%d expected to share the connection", i)
+		}
+	}
+	if len(pool.activeClients) != 1 {
+		t.Fatalf("expected one connection, but got %d", len(pool.activeClients))
+	}
+	// a new connection is created after goaway
+	c.OnGoAway()
+	c.OnGoAway()
+	if ac, _ := pool.getAvailableClient(context.Background()); ac == nil || ac == c {
+		t.Fatal("expected a new connection after goaway")
+	}
+	if n := ci.Stats().UpstreamConnectionGoAway.Count(); n != 1 {
+		t.Fatalf("expected the goaway is counted once, but got %d", n)
+	}
+}
+
+func TestConnPoolMaxConcurrentStreams(t *testing.T) {
+	pool, ci, closeFunc := newTestConnPool(t, &v2.Http2ConnPoolConfig{
+		MaxConcurrentStreams: 2,
+		MaxConnections:       2,
+	})
+	defer closeFunc()
+	// saturates the streams of the first connection
+	c1, _ := pool.getAvailableClient(context.Background())
+	c2, _ := pool.getAvailableClient(context.Background())
+	if c1 == nil || c1 != c2 {
+		t.Fatal("expected the streams share the first connection")
+	}
+	// a second connection is created
+	c3, _ := pool.getAvailableClient(context.Background())
+	if c3 == nil || c3 == c1 {
+		t.Fatal("expected a new connection if the streams are saturated")
+	}
+	c4, _ := pool.getAvailableClient(context.Background())
+	if c4 != c3 {
+		t.Fatal("expected the streams share the second connection")
+	}
+	if len(pool.activeClients) != 2 || ci.Stats().UpstreamConnectionTotal.Count() != 2 {
+		t.Fatalf("expected two connections, but got %d", len(pool.activeClients))
+	}
+	// the max connections is reached, and no pending request is allowed
+	if c, reason := pool.getAvailableClient(context.Background()); c != nil || reason != types.Overflow {
+		t.Fatalf("expected overflow, but got %v", reason)
+	}
+	// a released stream is reused
+	pool.onStreamDestroy(c1)
+	if c, _ := pool.getAvailableClient(context.Background()); c != c1 {
+		t.Fatal("expected to reuse the released stream")
+	}
+	if ci.Stats().UpstreamRequestPendingOverflow.Count() != 1 {
+		t.Fatalf("unexpected pending overflow: %d", ci.Stats().UpstreamRequestPendingOverflow.Count())
+	}
+}
+
+func TestConnPoolPendingRequests(t *testing.T) {
+	pool, ci, closeFunc := newTestConnPool(t, &v2.Http2ConnPoolConfig{
+		MaxConcurrentStreams: 1,
+		MaxConnections:       1,
+		MaxPendingRequests:   1,
+		PendingTimeout:       api.DurationConfig{Duration: 200 * time.Millisecond},
+	})
+	defer closeFunc()
+	stats := ci.Stats()
+	c1, _ := pool.getAvailableClient(context.Background())
+	if c1 == nil {
+		t.Fatal("get client failed")
+	}
+
+	type result struct {
+		client *activeClient
+		reason types.PoolFailureReason
+	}
+	// the request is queued until a stream is released
+	queued := make(chan result, 1)
+	go func() {
+		c, reason := pool.getAvailableClient(context.Background())
+		queued <- result{c, reason}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if stats.UpstreamRequestPending.Count() != 1 {
+		t.Fatalf("expected a pending request, but got %d", stats.UpstreamRequestPending.Count())
+	}
+	// exceeds the max pending requests, rejected immediately
+	if c, reason := pool.getAvailableClient(context.Background()); c != nil || reason != types.Overflow {
+		t.Fatalf("expected overflow, but got %v", reason)
+	}
+	pool.onStreamDestroy(c1)
+	select {
+	case r := <-queued:
+		if r.client != c1 || r.reason != "" {
+			t.Fatalf("expected the queued request gets the released stream, reason: %v", r.reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the queued request is not notified")
+	}
+
+	// the queued request is rejected after the pending timeout
+	start := time.Now()
+	c, reason := pool.getAvailableClient(context.Background())
+	if c != nil || reason != types.Overflow {
+		t.Fatalf("expected overflow, but got %v", reason)
+	}
+	if cost := time.Since(start); cost < 200*time.Millisecond {
+		t.Fatalf("expected waiting for the pending timeout, but cost %v", cost)
+	}
+	if stats.UpstreamRequestPending.Count() != 0 || stats.UpstreamRequestPendingOverflow.Count() != 2 {
+		t.Fatalf("unexpected pending stats: pending=%d, overflow=%d",
+			stats.UpstreamRequestPending.Count(), stats.UpstreamRequestPendingOverflow.Count())
+	}
+	if len(pool.waiters) != 0 {
+		t.Fatalf("expected no waiter, but got %d", len(pool.waiters))
+	}
+}
+
+func TestConnPoolUpstreamIdleTimeout(t *testing.T) {
+	pool, ci, closeFunc := newTestConnPool(t, nil)
+	defer closeFunc()
+	ci.idleTimeout = 100 * time.Millisecond
+	c, _ := pool.getAvailableClient(context.Background())
+	if c == nil {
+		t.Fatal("get client failed")
+	}
+	// the connection with the stream in use is not closed
+	time.Sleep(150 * time.Millisecond)
+	if len(pool.activeClients) != 1 {
+		t.Fatal("expected the connection in use is kept")
+	}
+	// the idle timer is reset by the new stream
+	pool.onStreamDestroy(c)
+	time.Sleep(50 * time.Millisecond)
+	if ac, _ := pool.getAvailableClient(context.Background()); ac != c {
+		t.Fatal("expected to reuse the idle connection")
+	}
+	pool.onStreamDestroy(c)
+	time.Sleep(50 * time.Millisecond)
+	pool.mux.Lock()
+	n := len(pool.activeClients)
+	pool.mux.Unlock()
+	if n != 1 {
+		t.Fatal("expected the connection is kept before the idle timeout")
+	}
+	// the idle connection is closed after the idle timeout
+	time.Sleep(100 * time.Millisecond)
+	pool.mux.Lock()
+	n = len(pool.activeClients)
+	pool.mux.Unlock()
+	if n != 0 {
+		t.Fatal("expected the idle connection is closed")
+	}
+	if ac, _ := pool.getAvailableClient(context.Background()); ac == nil || ac == c {
+		t.Fatal("expected a new connection after the idle connection is closed")
+	}
+}
+
+func TestConnPoolShutdown(t *testing.T) {
+	pool, _, closeFunc := newTestConnPool(t, &v2.Http2ConnPoolConfig{
+		MaxConcurrentStreams: 1,
+	})
+	defer closeFunc()
+	busy, _ := pool.getAvailableClient(context.Background())
+	idle, _ := pool.getAvailableClient(context.Background())
+	if busy == nil || idle == nil || busy == idle {
+		t.Fatal("expected two connections")
+	}
+	pool.onStreamDestroy(idle)
+
+	activeClients := func() []*activeClient {
+		time.Sleep(50 * time.Millisecond)
+		pool.mux.Lock()
+		defer pool.mux.Unlock()
+		return append([]*activeClient{}, pool.activeClients...)
+	}
+	// the idle connection is closed at once, the connection in use is kept
+	pool.Shutdown()
+	if clients := activeClients(); len(clients) != 1 || clients[0] != busy {
+		t.Fatalf("expected the connection in use is kept, but got %d connections", len(clients))
+	}
+	// the connection is closed once its streams are destroyed
+	pool.onStreamDestroy(busy)
+	if clients := activeClients(); len(clients) != 0 {
+		t.Fatalf("expected the connections are closed, but got %d connections", len(clients))
+	}
+}
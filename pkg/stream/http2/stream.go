@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"mosn.io/api"
@@ -144,6 +145,35 @@ func (s *stream) GetStream() types.Stream {
 
 type StreamConfig struct {
 	Http2UseStream bool `json:"http2_use_stream,omitempty"`
+	// Http2SuppressPush refuses the pushed streams of the upstream, the push is never forwarded to the downstream
+	Http2SuppressPush bool `json:"http2_suppress_push,omitempty"`
+	// Http2StreamWeight is the priority weight of the upstream streams, between 2 and 256.
+	// zero means no priority is sent, and the upstream uses the default weight 16.
+	Http2StreamWeight uint32 `json:"http2_stream_weight,omitempty"`
+	// Http2MaxConcurrentStreams is the max concurrent streams of a downstream connection,
+	// the exceeding streams are refused with REFUSED_STREAM. zero means the default limit.
+	Http2MaxConcurrentStreams uint32 `json:"http2_max_concurrent_streams,omitempty"`
+}
+
+const (
+	// the weight 1 is encoded as the zero PriorityParam which is not sent
+	minStreamWeight = 2
+	maxStreamWeight = 256
+)
+
+// streamPriority returns the priority of the upstream streams
+func (c StreamConfig) streamPriority() http2.PriorityParam {
+	w := c.Http2StreamWeight
+	if w == 0 {
+		return http2.PriorityParam{}
+	}
+	if w < minStreamWeight || w > maxStreamWeight {
+		log.DefaultLogger.Errorf("[stream] [http2] invalid stream weight %d, should be between %d and %d", w, minStreamWeight, maxStreamWeight)
+		return http2.PriorityParam{}
+	}
+	return http2.PriorityParam{
+		Weight: uint8(w - 1),
+	}
 }
 
 var defaultStreamConfig = StreamConfig{
@@ -221,6 +251,9 @@ func newServerStreamConnection(ctx context.Context, connection api.Connection, s
 	}
 
 	sc.useStream = sc.config.Http2UseStream
+	if sc.config.Http2MaxConcurrentStreams > 0 {
+		h2sc.SetMaxConcurrentStreams(sc.config.Http2MaxConcurrentStreams)
+	}
 
 	// init first context
 	sc.cm.Next()
@@ -629,7 +662,9 @@ func (s *serverStream) endStream() {
 
 type clientStreamConnection struct {
 	streamConnection
+	// lastStream is the last stream processed by the server, it is valid if goaway is set
 	lastStream                    uint32
+	goaway                        uint32
 	mutex                         sync.RWMutex
 	streams                       map[uint32]*clientStream
 	mClientConn                   *http2.MClientConn
@@ -657,7 +692,10 @@ func newClientStreamConnection(ctx context.Context, connection api.Connection,
 		streamConnectionEventListener: clientCallbacks,
 	}
 
-	sc.useStream = parseStreamConfig(ctx).Http2UseStream
+	config := parseStreamConfig(ctx)
+	sc.useStream = config.Http2UseStream
+	h2cc.SuppressPush = config.Http2SuppressPush
+	h2cc.StreamPriority = config.streamPriority()
 
 	// init first context
 	sc.cm.Next()
@@ -685,6 +723,35 @@ func (conn *clientStreamConnection) OnEvent(event api.ConnectionEvent) {
 	}
 }
 
+// handleGoAway stops creating streams on the connection, and resets the streams above the last stream id.
+// these streams are not processed by the server, so they are retried on a new connection.
+func (conn *clientStreamConnection) handleGoAway(lastStream uint32) {
+	conn.mutex.Lock()
+	atomic.StoreUint32(&conn.lastStream, lastStream)
+	atomic.StoreUint32(&conn.goaway, 1)
+	var refused []*clientStream
+	for id, stream := range conn.streams {
+		if id > lastStream {
+			refused = append(refused, stream)
+		}
+	}
+	conn.mutex.Unlock()
+
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("http2 client receive goaway lastStreamID = %d, refused streams = %d", lastStream, len(refused))
+	}
+	// the pool creates the new connection for the retried streams
+	conn.streamConnectionEventListener.OnGoAway()
+	for _, stream := range refused {
+		stream.ResetStream(types.StreamRefused)
+	}
+}
+
+// refused returns true if the stream is not processed by the server because of goaway
+func (conn *clientStreamConnection) refused(id uint32) bool {
+	return atomic.LoadUint32(&conn.goaway) == 1 && id > atomic.LoadUint32(&conn.lastStream)
+}
+
 // types.StreamConnection
 func (conn *clientStreamConnection) Dispatch(buf types.IoBuffer) {
 	for {
@@ -765,25 +832,21 @@ func (conn *clientStreamConnection) handleFrame(ctx context.Context, i interface
 	var data []byte
 	var trailer http.Header
 	var rsp *http.Response
-	var lastStream uint32
 
-	rsp, data, trailer, endStream, lastStream, err = conn.mClientConn.HandleFrame(ctx, f)
+	rsp, data, trailer, endStream, _, err = conn.mClientConn.HandleFrame(ctx, f)
 
 	if err != nil {
 		conn.handleError(ctx, f, err)
 		return
 	}
 
-	if rsp == nil && trailer == nil && data == nil && !endStream && lastStream == 0 {
+	// the last stream id of a goaway frame may be zero, it is handled by the frame type
+	if goaway, ok := f.(*http2.GoAwayFrame); ok {
+		conn.handleGoAway(goaway.LastStreamID)
 		return
 	}
 
-	if lastStream != 0 {
-		conn.lastStream = lastStream
-		conn.streamConnectionEventListener.OnGoAway()
-		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
-			log.DefaultLogger.Debugf("http2 client receive goaway lastStreamID = %d", conn.lastStream)
-		}
+	if rsp == nil && trailer == nil && data == nil && !endStream {
 		return
 	}
 
@@ -1038,6 +1101,15 @@ func (s *clientStream) AppendPing(context context.Context) {
 func (s *clientStream) endStream() {
 	// send header
 	s.sc.mutex.Lock()
+	// the stream created before the goaway is known is retried on a new connection
+	if atomic.LoadUint32(&s.sc.goaway) == 1 {
+		s.sc.mutex.Unlock()
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("http2 client connection is going away, retry the stream")
+		}
+		s.ResetStream(types.StreamRefused)
+		return
+	}
 	_, err := s.sc.protocol.Encode(s.ctx, s.h2s)
 	if err == nil {
 		s.id = s.h2s.GetID()
@@ -1086,11 +1158,11 @@ func (s *clientStream) GetStream() types.Stream {
 
 func (s *clientStream) ResetStream(reason types.StreamResetReason) {
 	// reset by goaway, support retry.
-	if s.sc.lastStream > 0 && s.id > s.sc.lastStream {
+	if s.id > 0 && s.sc.refused(s.id) {
 		if log.DefaultLogger.GetLogLevel() >= log.WARN {
-			log.DefaultLogger.Warnf("http2 client reset by goaway, retry it, lastStream = %d, streamId = %d", s.sc.lastStream, s.id)
+			log.DefaultLogger.Warnf("http2 client reset by goaway, retry it, lastStream = %d, streamId = %d", atomic.LoadUint32(&s.sc.lastStream), s.id)
 		}
-		reason = types.StreamConnectionFailed
+		reason = types.StreamRefused
 	}
 
 	if s.h2s != nil {
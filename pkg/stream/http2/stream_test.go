@@ -18,6 +18,7 @@
 package http2
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -560,3 +561,293 @@ func TestServerH2RespUseStream(t *testing.T) {
 		serverStream.AppendTrailers(sctx, nil)
 	}
 }
+
+func TestClientH2SuppressPush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, suppress := range []bool{true, false} {
+		http2Config := map[string]interface{}{
+			"http2_suppress_push": suppress,
+		}
+		proxyGeneralExtendConfig := make(map[api.ProtocolName]interface{})
+		proxyGeneralExtendConfig[protocol.HTTP2] = streamConfigHandler(http2Config)
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.Set(ctx, types.VariableProxyGeneralConfig, proxyGeneralExtendConfig)
+
+		written := buffer.NewIoBuffer(64)
+		closed := false
+		connection := mock.NewMockConnection(ctrl)
+		connection.EXPECT().AddConnectionEventListener(gomock.Any()).AnyTimes()
+		connection.EXPECT().RawConn().Return(nil).AnyTimes()
+		connection.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+			for _, b := range bufs {
+				written.Write(b.Bytes())
+			}
+			return nil
+		})
+		connection.EXPECT().Close(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+			closed = true
+			return nil
+		})
+		connection.EXPECT().RemoteAddr().AnyTimes().Return(&net.TCPAddr{net.ParseIP("127.0.0.1"), 80, ""})
+
+		clientCallbacks := mock.NewMockStreamConnectionEventListener(ctrl)
+		sc := newClientStreamConnection(ctx, connection, clientCallbacks).(*clientStreamConnection)
+
+		// the upstream pushes a stream associated with stream 1
+		upstream := &bytes.Buffer{}
+		framer := mhttp2.NewFramer(upstream, nil)
+		hbuf := &bytes.Buffer{}
+		enc := mhpack.NewEncoder(hbuf)
+		enc.WriteField(mhpack.HeaderField{Name: ":method", Value: "GET"})
+		enc.WriteField(mhpack.HeaderField{Name: ":path", Value: "/pushed.css"})
+		enc.WriteField(mhpack.HeaderField{Name: "x-pushed", Value: "true"})
+		assert.Nil(t, framer.WritePushPromise(mhttp2.PushPromiseParam{
+			StreamID:      1,
+			PromiseID:     2,
+			BlockFragment: hbuf.Bytes(),
+			EndHeaders:    true,
+		}))
+		sc.Dispatch(buffer.NewIoBufferBytes(upstream.Bytes()))
+
+		if !suppress {
+			assert.True(t, closed, "the push promise should be a connection error")
+			continue
+		}
+		assert.False(t, closed)
+
+		// the pushed stream is refused
+		reader := mhttp2.NewFramer(nil, bytes.NewReader(written.Bytes()))
+		var rst *mhttp2.RSTStreamFrame
+		for {
+			f, err := reader.ReadFrame()
+			if err != nil {
+				break
+			}
+			if r, ok := f.(*mhttp2.RSTStreamFrame); ok {
+				rst = r
+			}
+		}
+		if assert.NotNil(t, rst) {
+			assert.Equal(t, uint32(2), rst.StreamID)
+			assert.Equal(t, mhttp2.ErrCodeRefusedStream, rst.ErrCode)
+		}
+
+		// the header block of the push promise is decoded, the next headers refer to the dynamic table
+		upstream.Reset()
+		hbuf.Reset()
+		enc.WriteField(mhpack.HeaderField{Name: ":status", Value: "200"})
+		enc.WriteField(mhpack.HeaderField{Name: "x-pushed", Value: "true"})
+		assert.Nil(t, framer.WriteHeaders(mhttp2.HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: hbuf.Bytes(),
+			EndHeaders:    true,
+		}))
+		f, _, err := sc.mClientConn.Framer.ReadFrame(ctx, buffer.NewIoBufferBytes(upstream.Bytes()), 0)
+		if assert.Nil(t, err) {
+			mh := f.(*mhttp2.MetaHeadersFrame)
+			assert.Equal(t, "200", mh.PseudoValue("status"))
+			assert.Equal(t, "true", mh.RegularFields()[0].Value)
+		}
+	}
+}
+
+func TestStreamConfigPriority(t *testing.T) {
+	testcases := []struct {
+		weight   uint32
+		expected mhttp2.PriorityParam
+	}{
+		{weight: 0, expected: mhttp2.PriorityParam{}},
+		{weight: 1, expected: mhttp2.PriorityParam{}},
+		{weight: 2, expected: mhttp2.PriorityParam{Weight: 1}},
+		{weight: 16, expected: mhttp2.PriorityParam{Weight: 15}},
+		{weight: 256, expected: mhttp2.PriorityParam{Weight: 255}},
+		{weight: 257, expected: mhttp2.PriorityParam{}},
+	}
+	for _, tc := range testcases {
+		config := StreamConfig{Http2StreamWeight: tc.weight}
+		assert.Equal(t, tc.expected, config.streamPriority(), "weight %d", tc.weight)
+	}
+}
+
+func TestServerH2MaxConcurrentStreams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	proxyGeneralExtendConfig := make(map[api.ProtocolName]interface{})
+	proxyGeneralExtendConfig[protocol.HTTP2] = streamConfigHandler(map[string]interface{}{
+		"http2_max_concurrent_streams": 2,
+	})
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableProxyGeneralConfig, proxyGeneralExtendConfig)
+
+	written := buffer.NewIoBuffer(64)
+	connection := mock.NewMockConnection(ctrl)
+	connection.EXPECT().SetTransferEventListener(gomock.Any()).AnyTimes()
+	connection.EXPECT().AddConnectionEventListener(gomock.Any()).AnyTimes()
+	connection.EXPECT().RawConn().Return(nil).AnyTimes()
+	connection.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+		for _, b := range bufs {
+			written.Write(b.Bytes())
+		}
+		return nil
+	})
+
+	received := 0
+	streamReceiver := mock.NewMockStreamReceiveListener(ctrl)
+	streamReceiver.EXPECT().OnReceive(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Do(
+		func(ctx context.Context, headers api.HeaderMap, data buffer.IoBuffer, trailers api.HeaderMap) {
+			received++
+		})
+	serverCallbacks := mock.NewMockServerStreamConnectionEventListener(ctrl)
+	serverCallbacks.EXPECT().NewStreamDetect(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(streamReceiver)
+
+	sc := newServerStreamConnection(ctx, connection, serverCallbacks).(*serverStreamConnection)
+	assert.Nil(t, sc.sc.Init())
+
+	readFrames := func() []mhttp2.Frame {
+		var frames []mhttp2.Frame
+		reader := mhttp2.NewFramer(nil, bytes.NewReader(written.Bytes()))
+		for {
+			f, err := reader.ReadFrame()
+			if err != nil {
+				break
+			}
+			frames = append(frames, f)
+		}
+		written.Reset()
+		return frames
+	}
+	// the limit is advertised in the settings
+	frames := readFrames()
+	if assert.NotEmpty(t, frames) {
+		settings := frames[0].(*mhttp2.SettingsFrame)
+		v, ok := settings.Value(mhttp2.SettingMaxConcurrentStreams)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(2), v)
+	}
+
+	headers := func(id uint32, flags mhttp2.Flags, fields ...mhpack.HeaderField) *mhttp2.MetaHeadersFrame {
+		return &mhttp2.MetaHeadersFrame{
+			HeadersFrame: &mhttp2.HeadersFrame{
+				FrameHeader: mhttp2.FrameHeader{
+					Type:     mhttp2.FrameHeaders,
+					Flags:    flags,
+					Length:   1,
+					StreamID: id,
+				},
+			},
+			Fields: fields,
+		}
+	}
+	// open more streams than the limit
+	for _, id := range []uint32{1, 3, 5} {
+		sc.handleFrame(sc.cm.Get(), headers(id, 0,
+			mhpack.HeaderField{Name: ":method", Value: "POST"},
+			mhpack.HeaderField{Name: ":path", Value: "/"},
+			mhpack.HeaderField{Name: ":scheme", Value: "http"},
+		), nil)
+		sc.cm.Next()
+	}
+	assert.Len(t, sc.streams, 2)
+	// the exceeding stream is refused
+	var rst []*mhttp2.RSTStreamFrame
+	for _, f := range readFrames() {
+		if r, ok := f.(*mhttp2.RSTStreamFrame); ok {
+			rst = append(rst, r)
+		}
+	}
+	if assert.Len(t, rst, 1) {
+		assert.Equal(t, uint32(5), rst[0].StreamID)
+		assert.Equal(t, mhttp2.ErrCodeRefusedStream, rst[0].ErrCode)
+	}
+
+	// the existing streams continue
+	for _, id := range []uint32{1, 3} {
+		sc.handleFrame(sc.cm.Get(), headers(id, mhttp2.FlagHeadersEndStream), nil)
+		sc.cm.Next()
+	}
+	assert.Equal(t, 2, received)
+}
+
+func TestClientH2GoAway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	connection := mock.NewMockConnection(ctrl)
+	connection.EXPECT().AddConnectionEventListener(gomock.Any()).AnyTimes()
+	connection.EXPECT().RawConn().Return(nil).AnyTimes()
+	connection.EXPECT().Write(gomock.Any()).AnyTimes().Return(nil)
+	connection.EXPECT().Close(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	connection.EXPECT().RemoteAddr().AnyTimes().Return(&net.TCPAddr{net.ParseIP("127.0.0.1"), 80, ""})
+
+	ctx := variable.NewVariableContext(context.Background())
+	clientCallbacks := mock.NewMockStreamConnectionEventListener(ctrl)
+	clientCallbacks.EXPECT().OnGoAway().Times(1)
+	sc := newClientStreamConnection(ctx, connection, clientCallbacks).(*clientStreamConnection)
+	sc.cm.Next()
+
+	streamReceiver := mock.NewMockStreamReceiveListener(ctrl)
+	received := 0
+	streamReceiver.EXPECT().OnReceive(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Do(
+		func(ctx context.Context, headers api.HeaderMap, data buffer.IoBuffer, trailers api.HeaderMap) {
+			received++
+		})
+	resets := map[*clientStream]types.StreamResetReason{}
+	newStream := func(send bool) *clientStream {
+		ctx := sc.cm.Get()
+		s := sc.NewStream(ctx, streamReceiver).(*clientStream)
+		listener := mock.NewMockStreamEventListener(ctrl)
+		listener.EXPECT().OnResetStream(gomock.Any()).AnyTimes().Do(func(reason types.StreamResetReason) {
+			resets[s] = reason
+		})
+		listener.EXPECT().OnDestroyStream().AnyTimes()
+		s.AddEventListener(listener)
+		if send {
+			req, _ := http.NewRequest("GET", "http://127.0.0.1:80/", nil)
+			h2s, _ := sc.mClientConn.WriteHeaders(ctx, req, "", false)
+			s.id = h2s.ID
+			sc.streams[s.id] = s
+		}
+		sc.cm.Next()
+		return s
+	}
+	processed := newStream(true)
+	refused := newStream(true)
+
+	// the server processes the first stream only
+	upstream := &bytes.Buffer{}
+	framer := mhttp2.NewFramer(upstream, nil)
+	assert.Nil(t, framer.WriteGoAway(processed.id, mhttp2.ErrCodeNo, nil))
+	sc.Dispatch(buffer.NewIoBufferBytes(upstream.Bytes()))
+
+	assert.Equal(t, types.StreamRefused, resets[refused], "the refused stream should be retried")
+	_, ok := resets[processed]
+	assert.False(t, ok, "the processed stream should not be reset")
+	sc.mutex.RLock()
+	assert.NotNil(t, sc.streams[processed.id])
+	assert.Nil(t, sc.streams[refused.id])
+	sc.mutex.RUnlock()
+
+	// the stream created on the going away connection is retried
+	pending := newStream(false)
+	pending.endStream()
+	assert.Equal(t, types.StreamRefused, resets[pending])
+
+	// the processed stream completes
+	upstream.Reset()
+	hbuf := &bytes.Buffer{}
+	enc := mhpack.NewEncoder(hbuf)
+	enc.WriteField(mhpack.HeaderField{Name: ":status", Value: "200"})
+	assert.Nil(t, framer.WriteHeaders(mhttp2.HeadersFrameParam{
+		StreamID:      processed.id,
+		BlockFragment: hbuf.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+	}))
+	sc.Dispatch(buffer.NewIoBufferBytes(upstream.Bytes()))
+	assert.Equal(t, 1, received)
+	_, ok = resets[processed]
+	assert.False(t, ok)
+}
@@ -22,7 +22,6 @@ import (
 	"errors"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/stream"
@@ -119,8 +118,10 @@ func (p *poolBinding) GetActiveClient(ctx context.Context) (*activeClientBinding
 		return c, ""
 	}
 
-	// no available client
+	// no available client, the request is pending until the new connection is established
+	host.ClusterInfo().Stats().UpstreamRequestPending.Inc(1)
 	c, reason := p.newActiveClient(ctx)
+	host.ClusterInfo().Stats().UpstreamRequestPending.Dec(1)
 	if c != nil && reason == "" {
 		p.idleClients[connID] = c
 
@@ -190,8 +191,7 @@ func (p *poolBinding) newActiveClient(ctx context.Context) (*activeClientBinding
 	proto := p.connpool.codec.NewXProtocol(ctx)
 	if heartbeater, ok := proto.(api.Heartbeater); ok && heartbeater.Trigger(ctx, 0) != nil {
 		// create keepalive
-		rpcKeepAlive := NewKeepAlive(ac.codecClient, proto, time.Second)
-		rpcKeepAlive.StartIdleTimeout()
+		rpcKeepAlive := newClusterKeepAlive(ac.codecClient, proto, host.ClusterInfo())
 
 		ac.SetHeartBeater(rpcKeepAlive)
 	}
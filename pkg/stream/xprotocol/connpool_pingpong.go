@@ -21,7 +21,6 @@ import (
 	"context"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	atomicex "go.uber.org/atomic"
 	"mosn.io/api"
@@ -111,7 +110,10 @@ func (p *poolPingPong) GetActiveClient(ctx context.Context) (*activeClientPingPo
 			// connection not multiplex,
 			// so we can concurrently build connections here
 			p.clientMux.Unlock()
+			// the request is pending until the new connection is established
+			host.ClusterInfo().Stats().UpstreamRequestPending.Inc(1)
 			c, reason = p.newActiveClient(ctx, proto)
+			host.ClusterInfo().Stats().UpstreamRequestPending.Dec(1)
 			if c != nil && reason == "" {
 				p.totalClientCount.Inc()
 			}
@@ -142,6 +144,7 @@ func (p *poolPingPong) GetActiveClient(ctx context.Context) (*activeClientPingPo
 		c = p.idleClients[lastIdx]
 		p.idleClients[lastIdx] = nil
 		p.idleClients = p.idleClients[:lastIdx]
+		host.ClusterInfo().Stats().UpstreamConnectionIdle.Dec(1)
 
 		goto RET
 	}
@@ -182,17 +185,24 @@ func (p *poolPingPong) putClientToPoolLocked(client *activeClientPingPong) {
 
 	if !client.closed {
 		p.idleClients = append(p.idleClients, client)
+		p.Host().ClusterInfo().Stats().UpstreamConnectionIdle.Inc(1)
 	}
 }
 
 func (p *poolPingPong) newActiveClient(ctx context.Context, subProtocol api.ProtocolName) (*activeClientPingPong, types.PoolFailureReason) {
+	host := p.Host()
+	// the connections to the host reach the cluster's max connections per host
+	data, ok := types.CreateHostConnection(ctx, host)
+	if !ok {
+		host.ClusterInfo().Stats().UpstreamConnectionHostOverflow.Inc(1)
+		return nil, types.Overflow
+	}
 	ac := &activeClientPingPong{
 		pool:        p,
 		subProtocol: subProtocol,
-		host:        p.Host().CreateConnection(ctx),
+		host:        data,
 	}
 
-	host := p.Host()
 	connCtx := ctx
 
 	ac.host.Connection.AddConnectionEventListener(ac)
@@ -215,8 +225,7 @@ func (p *poolPingPong) newActiveClient(ctx context.Context, subProtocol api.Prot
 	proto := p.connpool.codec.NewXProtocol(ctx)
 	if heartbeater, ok := proto.(api.Heartbeater); ok && heartbeater.Trigger(ctx, 0) != nil {
 		// create keepalive
-		rpcKeepAlive := NewKeepAlive(ac.codecClient, proto, time.Second)
-		rpcKeepAlive.StartIdleTimeout()
+		rpcKeepAlive := newClusterKeepAlive(ac.codecClient, proto, host.ClusterInfo())
 
 		ac.SetHeartBeater(rpcKeepAlive)
 	}
@@ -260,6 +269,12 @@ func (ac *activeClientPingPong) Close(err error) {
 		return
 	}
 
+	// the connection goes away, close it instead of returning to pool
+	if ac.shouldCloseConn {
+		ac.host.Connection.Close(api.NoFlush, api.LocalClose)
+		return
+	}
+
 	// return to pool
 	ac.pool.clientMux.Lock()
 	defer ac.pool.clientMux.Unlock()
@@ -284,6 +299,7 @@ func (ac *activeClientPingPong) removeFromPool() {
 			p.idleClients[lastIdx] = nil
 			// 	3. remove the last
 			p.idleClients = p.idleClients[:lastIdx]
+			p.Host().ClusterInfo().Stats().UpstreamConnectionIdle.Dec(1)
 		}
 	}
 	ac.closed = true
@@ -77,6 +77,13 @@ func TestPingPong(t *testing.T) {
 	}
 
 	assert.Equal(t, len(xsList), len(pInst.idleClients))
+	assert.Equal(t, int64(len(xsList)), host.ClusterInfo().Stats().UpstreamConnectionIdle.Count())
+	assert.Equal(t, int64(0), host.ClusterInfo().Stats().UpstreamRequestPending.Count())
+
+	// an idle client is taken away by the next stream
+	_, _, failReason := pInst.NewStream(ctx, &receiver{})
+	assert.Equal(t, types.PoolFailureReason(""), failReason)
+	assert.Equal(t, int64(len(xsList)-1), host.ClusterInfo().Stats().UpstreamConnectionIdle.Count())
 
 }
 
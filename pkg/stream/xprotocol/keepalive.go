@@ -24,6 +24,7 @@ import (
 
 	atomicex "go.uber.org/atomic"
 	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	str "mosn.io/mosn/pkg/stream"
 	"mosn.io/mosn/pkg/types"
@@ -43,6 +44,12 @@ type xprotocolKeepAlive struct {
 
 	idleFree *idleFree
 
+	// heartbeatInterval is the interval to send heartbeats on the idle connection,
+	// the heartbeats are sent on the read timeout of the connection if it is zero
+	heartbeatInterval time.Duration
+	// maxFailures overrides the FailCountToClose of the keepalive config if it is not zero
+	maxFailures uint32
+
 	// once protects stop channel
 	once sync.Once
 	// stop channel will stop all keep alive action
@@ -94,6 +101,45 @@ func NewKeepAlive(codec str.Client, proto api.XProtocol, timeout time.Duration)
 	return kp
 }
 
+// newClusterKeepAlive creates a keepalive with the keepalive config of the cluster
+func newClusterKeepAlive(codec str.Client, proto api.XProtocol, info types.ClusterInfo) types.KeepAlive {
+	kp := NewKeepAlive(codec, proto, time.Second).(*xprotocolKeepAlive)
+	kp.StartIdleTimeout()
+	if getter, ok := info.(types.KeepAliveConfigGetter); ok {
+		kp.setConfig(getter.KeepAliveConfig())
+	}
+	return kp
+}
+
+// setConfig applies the keepalive config of the cluster
+func (kp *xprotocolKeepAlive) setConfig(c *v2.KeepAliveConfig) {
+	if c == nil {
+		return
+	}
+	kp.maxFailures = c.MaxFailures
+	if c.HeartbeatInterval.Duration > 0 {
+		kp.heartbeatInterval = c.HeartbeatInterval.Duration
+		utils.GoWithRecover(kp.runHeartbeat, nil)
+	}
+}
+
+// runHeartbeat sends a heartbeat every heartbeat interval if the connection is idle
+func (kp *xprotocolKeepAlive) runHeartbeat() {
+	ticker := time.NewTicker(kp.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-kp.stop:
+			return
+		case <-ticker.C:
+			// the connection with running requests, including the heartbeat, is not idle
+			if kp.Codec.ActiveRequestsNum() == 0 {
+				kp.sendKeepAlive()
+			}
+		}
+	}
+}
+
 // keepalive should stop when connection closed
 func (kp *xprotocolKeepAlive) OnEvent(event api.ConnectionEvent) {
 	if event.IsClose() || event.ConnectFailure() {
@@ -122,6 +168,11 @@ func (kp *xprotocolKeepAlive) SendKeepAlive() {
 	default:
 	}
 
+	// the heartbeats are driven by the heartbeat interval
+	if kp.heartbeatInterval > 0 {
+		return
+	}
+
 	var (
 		c         = xprotoKeepaliveConfig.Load().(KeepaliveConfig)
 		tickCount = kp.tickCount.Inc()
@@ -162,7 +213,9 @@ func (kp *xprotocolKeepAlive) sendKeepAlive() {
 
 	// we send sofa rpc cmd as "header", but it maybe contains "body"
 	hb := kp.Protocol.Trigger(ctx, id)
-	kp.store(id, startTimeout(id, kp)) // store request before send, in case receive response too quick but not data in store
+	timeout := startTimeout(id, kp)
+	timeout.stream = sender.GetStream()
+	kp.store(id, timeout) // store request before send, in case receive response too quick but not data in store
 	sender.AppendHeaders(ctx, hb.GetHeader(), true)
 	// start a timer for request
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
@@ -182,9 +235,14 @@ func (kp *xprotocolKeepAlive) HandleTimeout(id uint64) {
 	default:
 	}
 
-	if _, ok := kp.loadAndDelete(id); !ok {
+	timeout, ok := kp.loadAndDelete(id)
+	if !ok {
 		return
 	}
+	// reset the heartbeat stream, so that it is not counted as a running request
+	if timeout.stream != nil {
+		timeout.stream.ResetStream(types.StreamLocalReset)
+	}
 
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 		log.DefaultLogger.Debugf("[stream] [xprotocol] [keepalive] connection %d receive a request timeout %d", kp.Codec.ConnID(), id)
@@ -193,13 +251,33 @@ func (kp *xprotocolKeepAlive) HandleTimeout(id uint64) {
 	kp.heartbeatFailCount.Inc()
 	kp.previousIsSucc.Store(false)
 
+	failCountToClose := c.FailCountToClose
+	if kp.maxFailures > 0 {
+		failCountToClose = kp.maxFailures
+	}
 	// close the connection, stop keep alive
-	if kp.heartbeatFailCount.Load() >= c.FailCountToClose {
-		kp.Codec.Close()
+	if kp.heartbeatFailCount.Load() >= failCountToClose {
+		kp.closeConnection()
 	}
 	kp.runCallback(types.KeepAliveTimeout)
 }
 
+// closeConnection closes the stale connection. If there are running requests on the connection,
+// the connection goes away, and it will be closed by the connection pool after the requests finish.
+func (kp *xprotocolKeepAlive) closeConnection() {
+	if kp.Codec.ActiveRequestsNum() == 0 {
+		kp.Codec.Close()
+		return
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[stream] [xprotocol] [keepalive] connection %d goes away with running requests", kp.Codec.ConnID())
+	}
+	kp.Stop()
+	if listener, ok := kp.Codec.(types.StreamConnectionEventListener); ok {
+		listener.OnGoAway()
+	}
+}
+
 func (kp *xprotocolKeepAlive) HandleSuccess(id uint64) {
 	select {
 	case <-kp.stop:
@@ -250,6 +328,7 @@ type keepAliveTimeout struct {
 	ID        uint64
 	timer     *utils.Timer
 	KeepAlive types.KeepAlive
+	stream    types.Stream
 }
 
 func startTimeout(id uint64, keep types.KeepAlive) *keepAliveTimeout {
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
@@ -257,3 +258,79 @@ func TestKeepAliveIdleFreeWithData(t *testing.T) {
 	close(ch)
 	wg.Wait()
 }
+
+type connEventRecorder struct {
+	closed uint32
+	goAway uint32
+}
+
+func (r *connEventRecorder) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() {
+		atomic.AddUint32(&r.closed, 1)
+	}
+}
+
+func (r *connEventRecorder) OnGoAway() {
+	atomic.AddUint32(&r.goAway, 1)
+}
+
+func TestKeepAliveHeartbeatInterval(t *testing.T) {
+	tc := newTestCase(t, 0, time.Second)
+	defer tc.Server.Close()
+	defer tc.KeepAlive.Stop()
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.setConfig(&v2.KeepAliveConfig{
+		HeartbeatInterval: api.DurationConfig{Duration: 50 * time.Millisecond},
+	})
+	// heartbeats are sent by the interval without the read timeout
+	time.Sleep(320 * time.Millisecond)
+	success := atomic.LoadUint32(&testStats.success)
+	if success < 3 || success > 6 {
+		t.Errorf("unexpected heartbeat count: %d", success)
+	}
+}
+
+func TestKeepAliveMaxFailures(t *testing.T) {
+	tc := newTestCase(t, 100*time.Millisecond, 20*time.Millisecond)
+	defer tc.Server.Close()
+	recorder := &connEventRecorder{}
+	tc.KeepAlive.Codec.AddConnectionEventListener(recorder)
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.setConfig(&v2.KeepAliveConfig{
+		HeartbeatInterval: api.DurationConfig{Duration: 50 * time.Millisecond},
+		MaxFailures:       2,
+	})
+	select {
+	case <-tc.KeepAlive.stop:
+	case <-time.After(2 * time.Second):
+		t.Fatal("keepalive is not stopped")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&testStats.timeout))
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&recorder.closed))
+}
+
+func TestKeepAliveGoAwayWithRunningRequests(t *testing.T) {
+	tc := newTestCase(t, 100*time.Millisecond, 20*time.Millisecond)
+	defer tc.Server.Close()
+	recorder := &connEventRecorder{}
+	tc.KeepAlive.Codec.AddConnectionEventListener(recorder)
+	tc.KeepAlive.Codec.SetStreamConnectionEventListener(recorder)
+	testStats := &testStats{}
+	tc.KeepAlive.AddCallback(testStats.Record)
+	tc.KeepAlive.setConfig(&v2.KeepAliveConfig{
+		MaxFailures: 1,
+	})
+	// a running request on the connection
+	tc.KeepAlive.Codec.NewStream(context.Background(), &receiver{})
+	tc.KeepAlive.SendKeepAlive()
+	time.Sleep(200 * time.Millisecond)
+	// the connection goes away instead of being closed
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&testStats.timeout))
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&recorder.goAway))
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&recorder.closed))
+	assert.Equal(t, 1, tc.KeepAlive.Codec.ActiveRequestsNum())
+	tc.KeepAlive.Codec.Close()
+}
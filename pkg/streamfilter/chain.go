@@ -26,6 +26,10 @@ import (
 	"mosn.io/mosn/pkg/types"
 )
 
+// AfterSend is the sender filter phase that runs after the response is flushed to the downstream,
+// the response modified by filters in this phase is ignored.
+const AfterSend = api.BeforeSend + 1
+
 // StreamReceiverFilterStatusHandler allow users to deal with the receiver filter status.
 type StreamReceiverFilterStatusHandler func(phase api.ReceiverFilterPhase, status api.StreamFilterStatus)
 
@@ -38,6 +42,58 @@ type StreamReceiverFilterIteratorHandler func(context.Context, types.HeaderMap,
 // StreamSenderFilterIteratorHandler is used to implement iterator handler for send filter.
 type StreamSenderFilterIteratorHandler func(context.Context, types.HeaderMap, types.IoBuffer, types.HeaderMap, api.StreamSenderFilter)
 
+// StreamSenderFilterPhaseGetter is implemented by the sender filter handler which
+// allows a filter registered at multiple phases to know the current one.
+type StreamSenderFilterPhaseGetter interface {
+	// GetFilterCurrentPhase returns the current sender filter phase
+	GetFilterCurrentPhase() api.SenderFilterPhase
+}
+
+// StreamReceiverFilterContinuer is implemented by the receiver filter handler which
+// allows a filter returns api.StreamFilterStop to resume the stream asynchronously.
+type StreamReceiverFilterContinuer interface {
+	// CanHoldReceiving returns true if the stream is held by the filter returns api.StreamFilterStop
+	// in the current phase, otherwise the stream is not held, and the filter should not stop asynchronously.
+	CanHoldReceiving() bool
+	// ContinueReceiving resumes the stream held by the filter
+	ContinueReceiving()
+}
+
+// StreamReceiverFilterDataInjector is implemented by the receiver filter handler which
+// allows a filter to rewrite the buffered request body, such as decompress or protocol translate.
+type StreamReceiverFilterDataInjector interface {
+	// InjectData appends the buf to the buffered request body, or replaces it if replace is true.
+	// the filters after it and the upstream request see the modified body, if the request
+	// has no body before, the filters in the same phase should get it by GetRequestData.
+	InjectData(buf types.IoBuffer, replace bool)
+}
+
+// StreamReceiverFilterBodyBuffering is implemented by the receiver filter which requires the full
+// request body, the streamed request body is buffered before the receiver filters run if any filter requires it.
+type StreamReceiverFilterBodyBuffering interface {
+	// NeedRequestBody returns true if the filter reads the full request body of the request
+	NeedRequestBody(ctx context.Context, headers types.HeaderMap) bool
+}
+
+// StreamFilterDynamicMetadata is implemented by the receiver and the sender filter handlers which
+// allows the filters to pass structured state down the chain, such as an auth filter stashes the
+// decoded claims for a later filter. the metadata is namespaced by the filter name, and cleared
+// when the stream is cleaned.
+type StreamFilterDynamicMetadata interface {
+	// GetDynamicMetadata returns the value of the key in the namespace
+	GetDynamicMetadata(namespace, key string) (interface{}, bool)
+	// SetDynamicMetadata sets the value of the key in the namespace
+	SetDynamicMetadata(namespace, key string, value interface{})
+}
+
+// StreamFilterPrioritySetter is implemented by the stream filter chain which inserts
+// the filters in priority order within each phase.
+type StreamFilterPrioritySetter interface {
+	// SetFilterPriority sets the priority of the filters added after it, the filters with
+	// higher priority run first, and the filters with the same priority keep the added order.
+	SetFilterPriority(priority int)
+}
+
 // StreamFilterChain manages the lifecycle of streamFilters.
 type StreamFilterChain interface {
 	// register StreamSenderFilter, StreamReceiverFilter and AccessLog.
@@ -76,14 +132,21 @@ type StreamFilterChain interface {
 // DefaultStreamFilterChainImpl is default implementation of the StreamFilterChain.
 type DefaultStreamFilterChainImpl struct {
 	// use two slice to avoid the allocation of small object
-	senderFilters      []api.StreamSenderFilter
-	senderFiltersPhase []api.SenderFilterPhase
-	senderFiltersIndex int
+	senderFilters         []api.StreamSenderFilter
+	senderFiltersPhase    []api.SenderFilterPhase
+	senderFiltersPriority []int
+	senderFiltersIndex    int
 
 	// use two slice to avoid the allocation of small object
-	receiverFilters      []api.StreamReceiverFilter
-	receiverFiltersPhase []api.ReceiverFilterPhase
-	receiverFiltersIndex int
+	receiverFilters         []api.StreamReceiverFilter
+	receiverFiltersPhase    []api.ReceiverFilterPhase
+	receiverFiltersPriority []int
+	receiverFiltersIndex    int
+	// the index of the receiver filter returns StreamFilterStop plus one, zero means no filter stops
+	receiverFiltersStopped int
+
+	// priority of the filters being added
+	priority int
 
 	streamAccessLogs []api.AccessLog
 }
@@ -104,21 +167,47 @@ func GetDefaultStreamFilterChain() *DefaultStreamFilterChainImpl {
 func PutStreamFilterChain(chain *DefaultStreamFilterChainImpl) {
 	chain.senderFilters = chain.senderFilters[:0]
 	chain.senderFiltersPhase = chain.senderFiltersPhase[:0]
+	chain.senderFiltersPriority = chain.senderFiltersPriority[:0]
 	chain.senderFiltersIndex = 0
 
 	chain.receiverFilters = chain.receiverFilters[:0]
 	chain.receiverFiltersPhase = chain.receiverFiltersPhase[:0]
+	chain.receiverFiltersPriority = chain.receiverFiltersPriority[:0]
 	chain.receiverFiltersIndex = 0
+	chain.receiverFiltersStopped = 0
+	chain.priority = 0
 
 	chain.streamAccessLogs = chain.streamAccessLogs[:0]
 
 	streamFilterChainPool.Put(chain)
 }
 
-// AddStreamSenderFilter registers senderFilters.
+// SetFilterPriority sets the priority of the filters added after it.
+func (d *DefaultStreamFilterChainImpl) SetFilterPriority(priority int) {
+	d.priority = priority
+}
+
+// AddStreamSenderFilter registers senderFilters, the filter is inserted
+// before the filters with lower priority in the same phase.
 func (d *DefaultStreamFilterChainImpl) AddStreamSenderFilter(filter api.StreamSenderFilter, p api.SenderFilterPhase) {
-	d.senderFilters = append(d.senderFilters, filter)
-	d.senderFiltersPhase = append(d.senderFiltersPhase, p)
+	idx := len(d.senderFilters)
+	for i := range d.senderFilters {
+		if d.senderFiltersPhase[i] == p && d.senderFiltersPriority[i] < d.priority {
+			idx = i
+			break
+		}
+	}
+	d.senderFilters = append(d.senderFilters, nil)
+	copy(d.senderFilters[idx+1:], d.senderFilters[idx:])
+	d.senderFilters[idx] = filter
+
+	d.senderFiltersPhase = append(d.senderFiltersPhase, 0)
+	copy(d.senderFiltersPhase[idx+1:], d.senderFiltersPhase[idx:])
+	d.senderFiltersPhase[idx] = p
+
+	d.senderFiltersPriority = append(d.senderFiltersPriority, 0)
+	copy(d.senderFiltersPriority[idx+1:], d.senderFiltersPriority[idx:])
+	d.senderFiltersPriority[idx] = d.priority
 }
 
 // SetSenderFilterHandler set filter handler for each filter in this chain
@@ -132,10 +221,27 @@ func (d *DefaultStreamFilterChainImpl) SetSenderFilterHandler(handler api.Stream
 	}
 }
 
-// AddStreamReceiverFilter registers receiver filters.
+// AddStreamReceiverFilter registers receiver filters, the filter is inserted
+// before the filters with lower priority in the same phase.
 func (d *DefaultStreamFilterChainImpl) AddStreamReceiverFilter(filter api.StreamReceiverFilter, p api.ReceiverFilterPhase) {
-	d.receiverFilters = append(d.receiverFilters, filter)
-	d.receiverFiltersPhase = append(d.receiverFiltersPhase, p)
+	idx := len(d.receiverFilters)
+	for i := range d.receiverFilters {
+		if d.receiverFiltersPhase[i] == p && d.receiverFiltersPriority[i] < d.priority {
+			idx = i
+			break
+		}
+	}
+	d.receiverFilters = append(d.receiverFilters, nil)
+	copy(d.receiverFilters[idx+1:], d.receiverFilters[idx:])
+	d.receiverFilters[idx] = filter
+
+	d.receiverFiltersPhase = append(d.receiverFiltersPhase, 0)
+	copy(d.receiverFiltersPhase[idx+1:], d.receiverFiltersPhase[idx:])
+	d.receiverFiltersPhase[idx] = p
+
+	d.receiverFiltersPriority = append(d.receiverFiltersPriority, 0)
+	copy(d.receiverFiltersPriority[idx+1:], d.receiverFiltersPriority[idx:])
+	d.receiverFiltersPriority[idx] = d.priority
 }
 
 // SetReceiveFilterHandler set filter handler for each filter in this chain
@@ -175,6 +281,7 @@ func (d *DefaultStreamFilterChainImpl) RunReceiverFilter(ctx context.Context, ph
 	headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap,
 	statusHandler StreamReceiverFilterStatusHandler) (filterStatus api.StreamFilterStatus) {
 	filterStatus = api.StreamFilterContinue
+	d.receiverFiltersStopped = 0
 
 	for ; d.receiverFiltersIndex < len(d.receiverFilters); d.receiverFiltersIndex++ {
 		filter := d.receiverFilters[d.receiverFiltersIndex]
@@ -198,6 +305,7 @@ func (d *DefaultStreamFilterChainImpl) RunReceiverFilter(ctx context.Context, ph
 		case api.StreamFilterContinue:
 			continue
 		case api.StreamFilterStop, api.StreamFiltertermination:
+			d.receiverFiltersStopped = d.receiverFiltersIndex + 1
 			d.receiverFiltersIndex = 0
 			return
 		case api.StreamFilterReMatchRoute, api.StreamFilterReChooseHost:
@@ -210,6 +318,33 @@ func (d *DefaultStreamFilterChainImpl) RunReceiverFilter(ctx context.Context, ph
 	return
 }
 
+// SkipReceiverFilter skips the receiver filter which returns StreamFilterReMatchRoute or
+// StreamFilterReChooseHost, the next RunReceiverFilter continues from the following filter.
+func (d *DefaultStreamFilterChainImpl) SkipReceiverFilter() {
+	if d.receiverFiltersIndex < len(d.receiverFilters) {
+		d.receiverFiltersIndex++
+	}
+}
+
+// StoppedReceiverFilter returns the receiver filter which returns StreamFilterStop in the last
+// RunReceiverFilter, nil means no filter stops.
+func (d *DefaultStreamFilterChainImpl) StoppedReceiverFilter() api.StreamReceiverFilter {
+	if d.receiverFiltersStopped == 0 || d.receiverFiltersStopped > len(d.receiverFilters) {
+		return nil
+	}
+	return d.receiverFilters[d.receiverFiltersStopped-1]
+}
+
+// ResumeReceiverFilter resumes the receiver filters stopped by StreamFilterStop,
+// the next RunReceiverFilter continues from the filter after the stopped one.
+func (d *DefaultStreamFilterChainImpl) ResumeReceiverFilter() {
+	if d.receiverFiltersStopped == 0 {
+		return
+	}
+	d.receiverFiltersIndex = d.receiverFiltersStopped
+	d.receiverFiltersStopped = 0
+}
+
 // RunSenderFilter invokes the sender filter chain.
 func (d *DefaultStreamFilterChainImpl) RunSenderFilter(ctx context.Context, phase api.SenderFilterPhase,
 	headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap,
@@ -84,3 +84,55 @@ func TestStreamFilterChainSetFilterHandler(t *testing.T) {
 
 	assert.Equal(t, setHandlerCount, 10)
 }
+
+func TestStreamFilterChainPriority(t *testing.T) {
+	chain := GetDefaultStreamFilterChain()
+	defer PutStreamFilterChain(chain)
+
+	f1, f2, f3, f4 := &orderFilter{name: "f1"}, &orderFilter{name: "f2"}, &orderFilter{name: "f3"}, &orderFilter{name: "f4"}
+	chain.AddStreamReceiverFilter(f1, api.BeforeRoute)
+	chain.AddStreamReceiverFilter(f2, api.AfterRoute)
+	chain.SetFilterPriority(10)
+	chain.AddStreamReceiverFilter(f3, api.AfterRoute)
+	chain.SetFilterPriority(5)
+	chain.AddStreamReceiverFilter(f4, api.AfterRoute)
+
+	// the filters are ordered by priority within each phase only
+	assert.Equal(t, []api.StreamReceiverFilter{f1, f3, f4, f2}, chain.receiverFilters)
+	assert.Equal(t, []api.ReceiverFilterPhase{api.BeforeRoute, api.AfterRoute, api.AfterRoute, api.AfterRoute}, chain.receiverFiltersPhase)
+	assert.Equal(t, []int{0, 10, 5, 0}, chain.receiverFiltersPriority)
+}
+
+func TestStreamFilterChainResumeReceiverFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	chain := GetDefaultStreamFilterChain()
+	defer PutStreamFilterChain(chain)
+
+	var order []int
+	for i, status := range []api.StreamFilterStatus{api.StreamFilterContinue, api.StreamFilterStop, api.StreamFilterContinue} {
+		i, status := i, status
+		receiverFilter := mock.NewMockStreamReceiverFilter(ctrl)
+		receiverFilter.EXPECT().OnReceive(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_, _, _, _ interface{}) api.StreamFilterStatus {
+				order = append(order, i)
+				return status
+			})
+		chain.AddStreamReceiverFilter(receiverFilter, api.BeforeRoute)
+	}
+
+	assert.Nil(t, chain.StoppedReceiverFilter())
+
+	status := chain.RunReceiverFilter(nil, api.BeforeRoute, nil, nil, nil, nil)
+	assert.Equal(t, api.StreamFilterStop, status)
+	assert.Equal(t, chain.receiverFilters[1], chain.StoppedReceiverFilter())
+	assert.Equal(t, []int{0, 1}, order)
+
+	// the resumed chain runs the filters after the stopped one
+	chain.ResumeReceiverFilter()
+	status = chain.RunReceiverFilter(nil, api.BeforeRoute, nil, nil, nil, nil)
+	assert.Equal(t, api.StreamFilterContinue, status)
+	assert.Nil(t, chain.StoppedReceiverFilter())
+	assert.Equal(t, []int{0, 1, 2}, order)
+}
@@ -127,6 +127,12 @@ func createStreamFilterFactoryFromConfig(configs []v2.Filter) (factories []api.S
 			log.DefaultLogger.Errorf("[streamfilter] createStreamFilterFactoryFromConfig api call return nil factory")
 			continue
 		}
+		if c.Priority != 0 {
+			sfcc = &priorityStreamFilterFactory{
+				StreamFilterChainFactory: sfcc,
+				priority:                 c.Priority,
+			}
+		}
 		factories = append(factories, sfcc)
 	}
 
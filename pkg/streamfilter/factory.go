@@ -57,9 +57,20 @@ type StreamFilterFactoryImpl struct {
 func (s *StreamFilterFactoryImpl) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
 	factories, ok := s.factories.Load().([]api.StreamFilterChainFactory)
 	if ok {
+		setter, _ := callbacks.(StreamFilterPrioritySetter)
 		for _, factory := range factories {
+			if setter != nil {
+				priority := 0
+				if pf, ok := factory.(*priorityStreamFilterFactory); ok {
+					priority = pf.priority
+				}
+				setter.SetFilterPriority(priority)
+			}
 			factory.CreateFilterChain(context, callbacks)
 		}
+		if setter != nil {
+			setter.SetFilterPriority(0)
+		}
 	} else {
 		log.DefaultLogger.Errorf("[streamfilter] CreateFilterChain unexpected object type in atomic.Value")
 	}
@@ -71,6 +82,12 @@ func (s *StreamFilterFactoryImpl) UpdateFactory(config StreamFiltersConfig) {
 	s.factories.Store(sff)
 }
 
+// priorityStreamFilterFactory attaches the priority in the config to the factory
+type priorityStreamFilterFactory struct {
+	api.StreamFilterChainFactory
+	priority int
+}
+
 func CreateFactoryByPlugin(pluginConfig *v2.StreamFilterGoPluginConfig, factoryConfig map[string]interface{}) (api.StreamFilterChainFactory, error) {
 	if pluginConfig.SoPath == "" {
 		return nil, errors.New("so file path could not be found")
@@ -19,6 +19,7 @@ package streamfilter
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	monkey "github.com/cch123/supermonkey"
@@ -26,6 +27,7 @@ import (
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/mock"
+	"mosn.io/pkg/buffer"
 )
 
 func TestStreamFilterFactory(t *testing.T) {
@@ -49,3 +51,64 @@ func TestStreamFilterFactory(t *testing.T) {
 		t.Errorf("createFilterChainCount=%v, want=2", createFilterChainCount)
 	}
 }
+
+// orderFilter records the name when the filter runs
+type orderFilter struct {
+	name  string
+	order *[]string
+}
+
+func (f *orderFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	*f.order = append(*f.order, f.name)
+	return api.StreamFilterContinue
+}
+
+func (f *orderFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	*f.order = append(*f.order, f.name)
+	return api.StreamFilterContinue
+}
+
+func (f *orderFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {}
+
+func (f *orderFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {}
+
+func (f *orderFilter) OnDestroy() {}
+
+type orderFilterFactory struct {
+	name  string
+	order *[]string
+}
+
+func (f *orderFilterFactory) CreateFilterChain(ctx context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := &orderFilter{name: f.name, order: f.order}
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	callbacks.AddStreamSenderFilter(filter, api.BeforeSend)
+}
+
+func TestStreamFilterFactoryPriority(t *testing.T) {
+	var order []string
+	api.RegisterStream("test_order", func(cfg map[string]interface{}) (api.StreamFilterChainFactory, error) {
+		return &orderFilterFactory{name: cfg["name"].(string), order: &order}, nil
+	})
+	factory := NewStreamFilterFactory([]v2.Filter{
+		{Type: "test_order", Config: map[string]interface{}{"name": "ratelimit"}, Priority: 10},
+		{Type: "test_order", Config: map[string]interface{}{"name": "log"}},
+		{Type: "test_order", Config: map[string]interface{}{"name": "authn"}, Priority: 100},
+		{Type: "test_order", Config: map[string]interface{}{"name": "quota"}, Priority: 10},
+		{Type: "test_order", Config: map[string]interface{}{"name": "fault"}, Priority: -1},
+	})
+	chain := GetDefaultStreamFilterChain()
+	defer PutStreamFilterChain(chain)
+	factory.CreateFilterChain(context.TODO(), chain)
+
+	expected := []string{"authn", "ratelimit", "quota", "log", "fault"}
+	chain.RunReceiverFilter(context.TODO(), api.BeforeRoute, nil, nil, nil, nil)
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("unexpected receiver filter order: %v, want: %v", order, expected)
+	}
+	order = nil
+	chain.RunSenderFilter(context.TODO(), api.BeforeSend, nil, nil, nil, nil)
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("unexpected sender filter order: %v, want: %v", order, expected)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"sync"
 
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 )
 
@@ -47,6 +48,10 @@ type StreamFilterManager interface {
 
 	// GetStreamFilterFactory return StreamFilterFactory indexed by key.
 	GetStreamFilterFactory(key string) StreamFilterFactory
+
+	// GetOrCreateStreamFilterFactory return StreamFilterFactory indexed by key,
+	// an empty StreamFilterFactory is added if the key is not found.
+	GetOrCreateStreamFilterFactory(key string) StreamFilterFactory
 }
 
 // StreamFilterManagerImpl is an implementation of interface StreamFilterManager.
@@ -73,12 +78,25 @@ func (s *StreamFilterManagerImpl) AddOrUpdateStreamFilterConfig(key string, conf
 		log.DefaultLogger.Infof("[streamfilter] AddOrUpdateStreamFilterConfig update filter chain key: %v", key)
 	} else {
 		factory := NewStreamFilterFactory(config)
-		s.streamFilterChainMap.LoadOrStore(key, factory)
+		if v, loaded := s.streamFilterChainMap.LoadOrStore(key, factory); loaded {
+			// added concurrently, update the stored one instead
+			if stored, ok := v.(StreamFilterFactory); ok {
+				stored.UpdateFactory(config)
+			}
+		}
 		log.DefaultLogger.Infof("[streamfilter] AddOrUpdateStreamFilterConfig add filter chain key: %v", key)
 	}
 	return nil
 }
 
+// UpdateStreamFilters swaps the stream filter chain of the listener at runtime.
+// The proxies of the listener hold the same StreamFilterFactory, so the new chain
+// takes effect on the next created stream without restarting the listener,
+// and the in-flight streams finish with their original chain.
+func UpdateStreamFilters(listenerName string, filters []v2.Filter) error {
+	return GetStreamFilterManager().AddOrUpdateStreamFilterConfig(listenerName, filters)
+}
+
 // GetStreamFilterFactory return StreamFilterFactory indexed by key.
 func (s *StreamFilterManagerImpl) GetStreamFilterFactory(key string) StreamFilterFactory {
 	if v, ok := s.streamFilterChainMap.Load(key); ok {
@@ -94,3 +112,23 @@ func (s *StreamFilterManagerImpl) GetStreamFilterFactory(key string) StreamFilte
 	log.DefaultLogger.Errorf("[streamfilter] GetStreamFilterFactory stream filter factory not found in map, name: %v", key)
 	return nil
 }
+
+// GetOrCreateStreamFilterFactory return StreamFilterFactory indexed by key,
+// an empty StreamFilterFactory is added if the key is not found, so the stream filters
+// added by UpdateStreamFilters later take effect on the holder of the factory.
+func (s *StreamFilterManagerImpl) GetOrCreateStreamFilterFactory(key string) StreamFilterFactory {
+	if key == "" {
+		log.DefaultLogger.Errorf("[streamfilter] GetOrCreateStreamFilterFactory invalid key: %v", key)
+		return nil
+	}
+	v, ok := s.streamFilterChainMap.Load(key)
+	if !ok {
+		v, _ = s.streamFilterChainMap.LoadOrStore(key, NewStreamFilterFactory(nil))
+	}
+	factory, ok := v.(StreamFilterFactory)
+	if !ok {
+		log.DefaultLogger.Errorf("[streamfilter] GetOrCreateStreamFilterFactory unexpected object in map")
+		return nil
+	}
+	return factory
+}
@@ -82,6 +82,89 @@ func TestStreamFilterManager(t *testing.T) {
 	}
 }
 
+type mockTaggedStreamFilterFactory struct {
+	tag string
+}
+
+func (m *mockTaggedStreamFilterFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	callbacks.AddStreamReceiverFilter(&mockTaggedStreamFilter{tag: m.tag}, api.BeforeRoute)
+}
+
+type mockTaggedStreamFilter struct {
+	mockStreamFilter
+	tag string
+}
+
+func TestUpdateStreamFilters(t *testing.T) {
+	for _, tag := range []string{"reloadA", "reloadB"} {
+		name := tag
+		api.RegisterStream(name, func(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+			return &mockTaggedStreamFilterFactory{tag: name}, nil
+		})
+	}
+	filterTags := func(chain *DefaultStreamFilterChainImpl) []string {
+		var tags []string
+		for _, f := range chain.receiverFilters {
+			tags = append(tags, f.(*mockTaggedStreamFilter).tag)
+		}
+		return tags
+	}
+
+	listenerName := "test_reload_listener"
+	assert.Nil(t, UpdateStreamFilters(listenerName, StreamFiltersConfig{
+		{Type: "reloadA"},
+	}))
+	// the factory is held by the proxy
+	factory := GetStreamFilterManager().GetStreamFilterFactory(listenerName)
+	assert.NotNil(t, factory)
+
+	inflight := &DefaultStreamFilterChainImpl{}
+	factory.CreateFilterChain(context.TODO(), inflight)
+	assert.Equal(t, []string{"reloadA"}, filterTags(inflight))
+
+	assert.Nil(t, UpdateStreamFilters(listenerName, StreamFiltersConfig{
+		{Type: "reloadB"},
+		{Type: "reloadA"},
+	}))
+	// the held factory is swapped in place
+	assert.Equal(t, factory, GetStreamFilterManager().GetStreamFilterFactory(listenerName))
+
+	// in-flight stream keeps the original chain
+	assert.Equal(t, []string{"reloadA"}, filterTags(inflight))
+	// new stream uses the new chain
+	newStream := &DefaultStreamFilterChainImpl{}
+	factory.CreateFilterChain(context.TODO(), newStream)
+	assert.Equal(t, []string{"reloadB", "reloadA"}, filterTags(newStream))
+
+	assert.Equal(t, ErrInvalidKey, UpdateStreamFilters("", nil))
+}
+
+func TestGetOrCreateStreamFilterFactory(t *testing.T) {
+	api.RegisterStream("createdLater", func(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+		return &mockTaggedStreamFilterFactory{tag: "createdLater"}, nil
+	})
+	manager := GetStreamFilterManager()
+	assert.Nil(t, manager.GetOrCreateStreamFilterFactory(""))
+
+	listenerName := "test_no_filters_listener"
+	// the listener has no stream filters
+	factory := manager.GetOrCreateStreamFilterFactory(listenerName)
+	assert.NotNil(t, factory)
+	assert.Equal(t, factory, manager.GetOrCreateStreamFilterFactory(listenerName))
+	assert.Equal(t, factory, manager.GetStreamFilterFactory(listenerName))
+	chain := &DefaultStreamFilterChainImpl{}
+	factory.CreateFilterChain(context.TODO(), chain)
+	assert.Len(t, chain.receiverFilters, 0)
+
+	// the filters added later take effect on the factory created before
+	assert.Nil(t, UpdateStreamFilters(listenerName, StreamFiltersConfig{
+		{Type: "createdLater"},
+	}))
+	chain = &DefaultStreamFilterChainImpl{}
+	factory.CreateFilterChain(context.TODO(), chain)
+	assert.Len(t, chain.receiverFilters, 1)
+}
+
 type mockStreamFilter struct {
 	appendReturn    api.StreamFilterStatus
 	onReceiveReturn api.StreamFilterStatus
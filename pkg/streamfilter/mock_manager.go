@@ -48,6 +48,20 @@ func (mr *MockStreamFilterManagerMockRecorder) AddOrUpdateStreamFilterConfig(key
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrUpdateStreamFilterConfig", reflect.TypeOf((*MockStreamFilterManager)(nil).AddOrUpdateStreamFilterConfig), key, config)
 }
 
+// GetOrCreateStreamFilterFactory mocks base method.
+func (m *MockStreamFilterManager) GetOrCreateStreamFilterFactory(key string) StreamFilterFactory {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrCreateStreamFilterFactory", key)
+	ret0, _ := ret[0].(StreamFilterFactory)
+	return ret0
+}
+
+// GetOrCreateStreamFilterFactory indicates an expected call of GetOrCreateStreamFilterFactory.
+func (mr *MockStreamFilterManagerMockRecorder) GetOrCreateStreamFilterFactory(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrCreateStreamFilterFactory", reflect.TypeOf((*MockStreamFilterManager)(nil).GetOrCreateStreamFilterFactory), key)
+}
+
 // GetStreamFilterFactory mocks base method.
 func (m *MockStreamFilterManager) GetStreamFilterFactory(key string) StreamFilterFactory {
 	m.ctrl.T.Helper()
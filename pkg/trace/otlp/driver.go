@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"fmt"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/otlp"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/trace"
+	"mosn.io/mosn/pkg/trace/w3c"
+	"mosn.io/mosn/pkg/types"
+)
+
+const DriverName = "otlp"
+
+func init() {
+	trace.RegisterDriver(DriverName, NewOtlpDriverImpl())
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP1, NewTracer)
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP2, NewTracer)
+}
+
+type holder struct {
+	api.Tracer
+	api.TracerBuilder
+}
+
+// otlpDriver propagates the w3c trace context, and exports the spans to the OTLP collector
+type otlpDriver struct {
+	tracers  map[types.ProtocolName]*holder
+	exporter *spanExporter
+}
+
+func (d *otlpDriver) Init(config map[string]interface{}) error {
+	otlpConfig, err := otlp.ParseConfig(config)
+	if err != nil {
+		return err
+	}
+	exporter, err := newSpanExporter(otlpConfig)
+	if err != nil {
+		return err
+	}
+	if d.exporter != nil {
+		d.exporter.Stop()
+	}
+	d.exporter = exporter
+	for proto, holder := range d.tracers {
+		tracer, err := holder.TracerBuilder(config)
+		if err != nil {
+			return fmt.Errorf("build tracer for %v error, %s", proto, err)
+		}
+		if w3cTracer, ok := tracer.(*w3c.Tracer); ok {
+			// injection the span exporter
+			w3cTracer.SetExporter(d.exporter)
+		}
+		holder.Tracer = tracer
+	}
+	return nil
+}
+
+func (d *otlpDriver) Register(proto types.ProtocolName, builder api.TracerBuilder) {
+	d.tracers[proto] = &holder{
+		TracerBuilder: builder,
+	}
+}
+
+func (d *otlpDriver) Get(proto types.ProtocolName) api.Tracer {
+	if holder, ok := d.tracers[proto]; ok {
+		return holder.Tracer
+	}
+	return nil
+}
+
+// NewOtlpDriverImpl creates the OTLP driver
+func NewOtlpDriverImpl() api.Driver {
+	return &otlpDriver{
+		tracers: make(map[types.ProtocolName]*holder),
+	}
+}
+
+// NewTracer creates the w3c tracer, the span exporter is injected by the driver
+func NewTracer(config map[string]interface{}) (api.Tracer, error) {
+	return w3c.NewExportTracer(config, nil)
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/otlp"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/trace/w3c"
+)
+
+func newMockReceiver(t *testing.T) (*httptest.Server, chan otlp.ExportTraceServiceRequest) {
+	received := make(chan otlp.ExportTraceServiceRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, otlp.TracesPath, r.URL.Path)
+		var request otlp.ExportTraceServiceRequest
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		received <- request
+	}))
+	return server, received
+}
+
+func receiveSpans(t *testing.T, received chan otlp.ExportTraceServiceRequest) (otlp.Resource, []otlp.Span) {
+	select {
+	case request := <-received:
+		require.Len(t, request.ResourceSpans, 1)
+		require.Len(t, request.ResourceSpans[0].ScopeSpans, 1)
+		return request.ResourceSpans[0].Resource, request.ResourceSpans[0].ScopeSpans[0].Spans
+	case <-time.After(3 * time.Second):
+		t.Fatal("spans are not exported")
+	}
+	return otlp.Resource{}, nil
+}
+
+func TestOtlpDriverExportSpans(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server, received := newMockReceiver(t)
+	defer server.Close()
+
+	driver := NewOtlpDriverImpl()
+	driver.Register(protocol.HTTP1, NewTracer)
+	require.Nil(t, driver.Init(map[string]interface{}{
+		"endpoint":        server.URL,
+		"export_interval": "50ms",
+		"service_name":    "test_service",
+		"instance":        "instance-1",
+	}))
+	defer driver.(*otlpDriver).exporter.Stop()
+
+	header := protocol.CommonHeader{
+		w3c.TraceParentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	span := driver.Get(protocol.HTTP1).Start(context.Background(), header, time.Now())
+	host := mock.NewMockHost(ctrl)
+	host.EXPECT().AddressString().Return("127.0.0.1:8080").AnyTimes()
+	reqinfo := network.NewRequestInfo()
+	reqinfo.OnUpstreamHostSelected(host)
+	reqinfo.SetResponseCode(http.StatusServiceUnavailable)
+	span.SetRequestInfo(reqinfo)
+	span.FinishSpan()
+
+	resource, spans := receiveSpans(t, received)
+	assert.Contains(t, resource.Attributes, otlp.StringAttribute("service.name", "test_service"))
+	assert.Contains(t, resource.Attributes, otlp.StringAttribute("service.instance.id", "instance-1"))
+	require.Len(t, spans, 1)
+	exported := spans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exported.TraceId)
+	assert.Equal(t, "00f067aa0ba902b7", exported.ParentSpanId)
+	assert.Equal(t, span.SpanId(), exported.SpanId)
+	assert.Equal(t, otlp.SpanKindServer, exported.Kind)
+	assert.Equal(t, otlp.StatusCodeError, exported.Status.Code)
+	assert.Contains(t, exported.Attributes, otlp.StringAttribute("mosn.upstream_host", "127.0.0.1:8080"))
+	assert.Contains(t, exported.Attributes, otlp.IntAttribute("mosn.response_code", http.StatusServiceUnavailable))
+	assert.NotEmpty(t, exported.StartTimeUnixNano)
+	assert.NotEmpty(t, exported.EndTimeUnixNano)
+
+	// the span is not sampled
+	header = protocol.CommonHeader{
+		w3c.TraceParentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+	}
+	span = driver.Get(protocol.HTTP1).Start(context.Background(), header, time.Now())
+	span.FinishSpan()
+	select {
+	case <-received:
+		t.Fatal("the span is not sampled")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestOtlpExporterBatch(t *testing.T) {
+	server, received := newMockReceiver(t)
+	defer server.Close()
+
+	config, err := otlp.ParseConfig(map[string]interface{}{
+		"endpoint":        server.URL,
+		"export_interval": "1h",
+		"batch_size":      2,
+	})
+	require.Nil(t, err)
+	exporter, err := newSpanExporter(config)
+	require.Nil(t, err)
+	tracer, err := w3c.NewExportTracer(map[string]interface{}{}, exporter)
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		tracer.Start(context.Background(), protocol.CommonHeader{}, time.Now()).FinishSpan()
+	}
+	// the full batch is exported
+	_, spans := receiveSpans(t, received)
+	assert.Len(t, spans, 2)
+	// the remaining spans are exported when the exporter stops
+	exporter.Stop()
+	_, spans = receiveSpans(t, received)
+	assert.Len(t, spans, 1)
+}
+
+func TestOtlpDriverConfig(t *testing.T) {
+	driver := NewOtlpDriverImpl()
+	assert.NotNil(t, driver.Init(map[string]interface{}{}))
+	assert.NotNil(t, driver.Init(map[string]interface{}{
+		"endpoint": "127.0.0.1:4317",
+		"protocol": "unknown",
+	}))
+	// the grpc connection is established in the background
+	require.Nil(t, driver.Init(map[string]interface{}{
+		"endpoint": "127.0.0.1:4317",
+		"protocol": "grpc",
+	}))
+	driver.(*otlpDriver).exporter.Stop()
+}
@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/otlp"
+	"mosn.io/mosn/pkg/trace/w3c"
+	"mosn.io/pkg/utils"
+)
+
+const defaultSpanName = "mosn.proxy"
+
+// spanExporter batches the finished spans, and exports them when the batch is full
+// or the export interval is reached
+type spanExporter struct {
+	client   *otlp.Client
+	resource otlp.Resource
+	spans    chan otlp.Span
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newSpanExporter(config *otlp.Config) (*spanExporter, error) {
+	client, err := otlp.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	e := &spanExporter{
+		client:   client,
+		resource: config.Resource(),
+		spans:    make(chan otlp.Span, config.BatchSize*2),
+		stop:     make(chan struct{}),
+	}
+	utils.GoWithRecover(e.run, nil)
+	return e, nil
+}
+
+// ExportSpan converts the span, the span is discarded if the exporter is busy
+func (e *spanExporter) ExportSpan(span *w3c.Span) {
+	select {
+	case e.spans <- convertSpan(span):
+	default:
+		if log.DefaultLogger.GetLogLevel() >= log.WARN {
+			log.DefaultLogger.Warnf("[otlp] [tracer] channel is full, discard span, trace id is %s, span id is %s", span.TraceId(), span.SpanId())
+		}
+	}
+}
+
+// Stop exports the batched spans and stops the exporter
+func (e *spanExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+}
+
+func (e *spanExporter) run() {
+	config := e.client.Config()
+	ticker := time.NewTicker(config.ExportInterval.Duration)
+	defer ticker.Stop()
+	batch := make([]otlp.Span, 0, config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		e.export(batch)
+		batch = make([]otlp.Span, 0, config.BatchSize)
+	}
+	for {
+		select {
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					flush()
+					e.client.Close()
+					return
+				}
+			}
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (e *spanExporter) export(spans []otlp.Span) {
+	request := otlp.ExportTraceServiceRequest{
+		ResourceSpans: []otlp.ResourceSpans{
+			{
+				Resource: e.resource,
+				ScopeSpans: []otlp.ScopeSpans{
+					{
+						Scope: otlp.InstrumentationScope{Name: otlp.InstrumentationScopeName},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+	if err := e.client.ExportTraces(&request); err != nil {
+		log.DefaultLogger.Errorf("[otlp] [tracer] export %d spans to %s failed: %v", len(spans), e.client.Config().Endpoint, err)
+	}
+}
+
+func convertSpan(span *w3c.Span) otlp.Span {
+	record := span.Record()
+	name := record.Operation
+	if name == "" {
+		name = defaultSpanName
+	}
+	attrs := []otlp.KeyValue{
+		otlp.IntAttribute("mosn.response_code", int64(record.ResponseCode)),
+		otlp.IntAttribute("mosn.duration_us", record.Duration),
+	}
+	if record.Protocol != "" {
+		attrs = append(attrs, otlp.StringAttribute("mosn.protocol", record.Protocol))
+	}
+	if record.Cluster != "" {
+		attrs = append(attrs, otlp.StringAttribute("mosn.upstream_cluster", record.Cluster))
+	}
+	if record.UpstreamHost != "" {
+		attrs = append(attrs, otlp.StringAttribute("mosn.upstream_host", record.UpstreamHost))
+	}
+	if record.DownstreamHost != "" {
+		attrs = append(attrs, otlp.StringAttribute("mosn.downstream_host", record.DownstreamHost))
+	}
+	status := otlp.Status{Code: otlp.StatusCodeUnset}
+	if record.ResponseCode >= http.StatusInternalServerError {
+		status.Code = otlp.StatusCodeError
+	}
+	return otlp.Span{
+		TraceId:           span.TraceId(),
+		SpanId:            span.SpanId(),
+		TraceState:        span.TraceState(),
+		ParentSpanId:      span.ParentSpanId(),
+		Name:              name,
+		Kind:              otlp.SpanKindServer,
+		StartTimeUnixNano: otlp.UnixNano(span.StartTime()),
+		EndTimeUnixNano:   otlp.UnixNano(span.EndTime()),
+		Attributes:        attrs,
+		Status:            status,
+	}
+}
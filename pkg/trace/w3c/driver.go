@@ -0,0 +1,33 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package w3c
+
+import (
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/trace"
+)
+
+const DriverName = "w3c"
+
+func init() {
+	trace.RegisterDriver(DriverName, trace.NewDefaultDriverImpl())
+	// the tracer reads the trace context from the request headers, so it can be
+	// registered for the other protocols with header maps too, such as the xprotocol
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP1, NewTracer)
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP2, NewTracer)
+}
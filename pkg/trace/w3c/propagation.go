@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package w3c
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// W3C trace context headers, see https://www.w3.org/TR/trace-context/
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+const (
+	traceParentVersion = "00"
+	// the length of version 00: 2 + 1 + 32 + 1 + 16 + 1 + 2
+	traceParentLength = 55
+	traceIdLength     = 32
+	spanIdLength      = 16
+
+	flagSampled byte = 0x01
+)
+
+// TraceParent is the parsed traceparent header
+type TraceParent struct {
+	TraceId string
+	SpanId  string
+	Flags   byte
+}
+
+// Sampled returns true if the caller may have recorded the trace
+func (p TraceParent) Sampled() bool {
+	return p.Flags&flagSampled == flagSampled
+}
+
+// String formats the traceparent header in version 00
+func (p TraceParent) String() string {
+	return traceParentVersion + "-" + p.TraceId + "-" + p.SpanId + "-" + hex.EncodeToString([]byte{p.Flags})
+}
+
+// ParseTraceParent parses the traceparent header, the header of the higher versions is parsed
+// as version 00 if it is compatible, and the invalid header is ignored.
+func ParseTraceParent(value string) (TraceParent, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < traceParentLength {
+		return TraceParent{}, false
+	}
+	version := value[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return TraceParent{}, false
+	}
+	// version 00 has exactly 4 fields, the higher versions may append more fields
+	if len(value) > traceParentLength && (version == traceParentVersion || value[traceParentLength] != '-') {
+		return TraceParent{}, false
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return TraceParent{}, false
+	}
+	traceId, spanId, flags := value[3:35], value[36:52], value[53:55]
+	if !isLowerHex(traceId) || isZero(traceId) ||
+		!isLowerHex(spanId) || isZero(spanId) ||
+		!isLowerHex(flags) {
+		return TraceParent{}, false
+	}
+	f, _ := hex.DecodeString(flags)
+	return TraceParent{
+		TraceId: traceId,
+		SpanId:  spanId,
+		Flags:   f[0],
+	}, true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
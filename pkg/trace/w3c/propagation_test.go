@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package w3c
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	testcases := []struct {
+		value  string
+		valid  bool
+		parent TraceParent
+	}{
+		{
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			valid: true,
+			parent: TraceParent{
+				TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanId:  "00f067aa0ba902b7",
+				Flags:   0x01,
+			},
+		},
+		{
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			valid: true,
+			parent: TraceParent{
+				TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanId:  "00f067aa0ba902b7",
+			},
+		},
+		{
+			// the higher version with more fields
+			value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc",
+			valid: true,
+			parent: TraceParent{
+				TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanId:  "00f067aa0ba902b7",
+				Flags:   0x01,
+			},
+		},
+		// version 00 with more fields
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc"},
+		// invalid version
+		{value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		// upper case
+		{value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		// all zero trace id
+		{value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		// all zero span id
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		// invalid delimiter
+		{value: "00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+		{value: ""},
+	}
+	for _, tc := range testcases {
+		parent, ok := ParseTraceParent(tc.value)
+		assert.Equal(t, tc.valid, ok, tc.value)
+		assert.Equal(t, tc.parent, parent, tc.value)
+	}
+}
+
+func TestTraceParentString(t *testing.T) {
+	parent := TraceParent{
+		TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanId:  "00f067aa0ba902b7",
+		Flags:   0x01,
+	}
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", parent.String())
+	assert.True(t, parent.Sampled())
+	parsed, ok := ParseTraceParent(parent.String())
+	assert.True(t, ok)
+	assert.Equal(t, parent, parsed)
+}
@@ -0,0 +1,183 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package w3c
+
+import (
+	"encoding/json"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// Span is the span of a request proxied by mosn, the upstream request carries
+// the span as the parent in the traceparent header.
+type Span struct {
+	tracer       *Tracer
+	traceId      string
+	spanId       string
+	parentSpanId string
+	flags        byte
+	traceState   string
+	operation    string
+	startTime    time.Time
+	endTime      time.Time
+	tags         map[uint64]string
+	record       SpanRecord
+}
+
+// SpanRecord is the span written to the trace log or exported
+type SpanRecord struct {
+	TraceId      string `json:"trace_id"`
+	SpanId       string `json:"span_id"`
+	ParentSpanId string `json:"parent_span_id,omitempty"`
+	Operation    string `json:"operation,omitempty"`
+	StartTime    string `json:"start_time"`
+	Duration     int64  `json:"duration_us"`
+	// UpstreamDuration is the time from the request is received to the upstream's response is received
+	UpstreamDuration int64  `json:"upstream_duration_us,omitempty"`
+	Protocol         string `json:"protocol,omitempty"`
+	Cluster          string `json:"cluster,omitempty"`
+	UpstreamHost     string `json:"upstream_host,omitempty"`
+	DownstreamHost   string `json:"downstream_host,omitempty"`
+	ResponseCode     int    `json:"response_code"`
+}
+
+func (s *Span) TraceId() string {
+	return s.traceId
+}
+
+func (s *Span) SpanId() string {
+	return s.spanId
+}
+
+func (s *Span) ParentSpanId() string {
+	return s.parentSpanId
+}
+
+// Sampled returns true if the span is recorded
+func (s *Span) Sampled() bool {
+	return s.flags&flagSampled == flagSampled
+}
+
+// TraceState returns the tracestate header from the downstream
+func (s *Span) TraceState() string {
+	return s.traceState
+}
+
+func (s *Span) StartTime() time.Time {
+	return s.startTime
+}
+
+func (s *Span) EndTime() time.Time {
+	return s.endTime
+}
+
+// Record returns the record of the finished span
+func (s *Span) Record() SpanRecord {
+	return s.record
+}
+
+func (s *Span) SetOperation(operation string) {
+	s.operation = operation
+}
+
+func (s *Span) SetTag(key uint64, value string) {
+	s.tags[key] = value
+}
+
+func (s *Span) Tag(key uint64) string {
+	return s.tags[key]
+}
+
+// SetRequestInfo records the upstream host, the response code and the durations of the request
+func (s *Span) SetRequestInfo(reqinfo api.RequestInfo) {
+	r := &s.record
+	if !reqinfo.StartTime().IsZero() {
+		s.startTime = reqinfo.StartTime()
+	}
+	r.Duration = reqinfo.Duration().Microseconds()
+	if d := reqinfo.ResponseReceivedDuration(); d > 0 {
+		r.UpstreamDuration = d.Microseconds()
+	}
+	r.Protocol = string(reqinfo.Protocol())
+	if recorder, ok := reqinfo.(types.UpstreamClusterRecorder); ok {
+		r.Cluster = recorder.UpstreamClusterName()
+	}
+	if reqinfo.UpstreamHost() != nil {
+		r.UpstreamHost = reqinfo.UpstreamHost().AddressString()
+	}
+	if reqinfo.DownstreamRemoteAddress() != nil {
+		r.DownstreamHost = reqinfo.DownstreamRemoteAddress().String()
+	}
+	r.ResponseCode = reqinfo.ResponseCode()
+}
+
+// FinishSpan writes the sampled span to the trace log, or exports it by the tracer's exporter
+func (s *Span) FinishSpan() {
+	s.endTime = time.Now()
+	if !s.Sampled() || s.tracer == nil {
+		return
+	}
+	r := &s.record
+	r.TraceId = s.traceId
+	r.SpanId = s.spanId
+	r.ParentSpanId = s.parentSpanId
+	r.Operation = s.operation
+	r.StartTime = s.startTime.Format("2006-01-02 15:04:05.000")
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(s)
+		return
+	}
+	if s.tracer.logger == nil {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		log.DefaultLogger.Errorf("[w3c] [tracer] marshal span %s failed: %v", s.spanId, err)
+		return
+	}
+	buf := log.GetLogBuffer(len(data) + 1)
+	buf.Write(data)
+	buf.WriteString("\n")
+	if err := s.tracer.logger.Print(buf, true); err == types.ErrChanFull {
+		if log.DefaultLogger.GetLogLevel() >= log.WARN {
+			log.DefaultLogger.Warnf("[w3c] [tracer] channel is full, discard span, trace id is %s, span id is %s", s.traceId, s.spanId)
+		}
+	}
+}
+
+// InjectContext propagates the trace context to the upstream, the span becomes the parent of the upstream
+func (s *Span) InjectContext(requestHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	if requestHeaders == nil {
+		return
+	}
+	requestHeaders.Set(TraceParentHeader, TraceParent{
+		TraceId: s.traceId,
+		SpanId:  s.spanId,
+		Flags:   s.flags,
+	}.String())
+	if s.traceState != "" {
+		requestHeaders.Set(TraceStateHeader, s.traceState)
+	}
+}
+
+func (s *Span) SpawnChild(operationName string, startTime time.Time) api.Span {
+	return nil
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package w3c
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/log"
+)
+
+const (
+	samplingRateKey = "sampling_rate"
+	logPathKey      = "log_path"
+
+	defaultLogPath = "w3c-trace.log"
+)
+
+// Tracer starts a span for each request, the span continues the trace of the
+// incoming traceparent header, or starts a new trace if it is absent.
+type Tracer struct {
+	// samplingRate is the probability of sampling the new traces, between 0 and 1,
+	// the trace from the downstream keeps the downstream's sampling decision.
+	samplingRate float64
+	logger       *log.Logger
+	exporter     SpanExporter
+}
+
+// SpanExporter exports the sampled spans to the tracing backend instead of the trace log
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// NewTracer creates the w3c tracer, the sampled spans are written to the log_path
+func NewTracer(config map[string]interface{}) (api.Tracer, error) {
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	logPath := path.Join(types.MosnLogBasePath, defaultLogPath)
+	if v, ok := config[logPathKey]; ok {
+		if lp, ok := v.(string); ok && lp != "" {
+			logPath = lp
+		}
+	}
+	logger, err := log.GetOrCreateLogger(logPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	tracer.logger = logger
+	return tracer, nil
+}
+
+// NewExportTracer creates the w3c tracer, the sampled spans are exported by the exporter
+func NewExportTracer(config map[string]interface{}, exporter SpanExporter) (api.Tracer, error) {
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	tracer.exporter = exporter
+	return tracer, nil
+}
+
+// SetExporter makes the tracer export the sampled spans by the exporter
+func (t *Tracer) SetExporter(exporter SpanExporter) {
+	t.exporter = exporter
+}
+
+func newTracer(config map[string]interface{}) (*Tracer, error) {
+	tracer := &Tracer{
+		samplingRate: 1,
+	}
+	if v, ok := config[samplingRateKey]; ok {
+		rate, ok := v.(float64)
+		if !ok || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid %s: %v, should be between 0 and 1", samplingRateKey, v)
+		}
+		tracer.samplingRate = rate
+	}
+	return tracer, nil
+}
+
+func (t *Tracer) Start(ctx context.Context, request interface{}, startTime time.Time) api.Span {
+	span := &Span{
+		tracer:    t,
+		spanId:    newId(8),
+		startTime: startTime,
+		tags:      make(map[uint64]string),
+	}
+	traceParent, traceState := extractContext(request)
+	if parent, ok := ParseTraceParent(traceParent); ok {
+		span.traceId = parent.TraceId
+		span.parentSpanId = parent.SpanId
+		span.flags = parent.Flags
+		span.traceState = traceState
+	} else {
+		span.traceId = newId(16)
+		if t.sample() {
+			span.flags = flagSampled
+		}
+	}
+	return span
+}
+
+func (t *Tracer) sample() bool {
+	if t.samplingRate >= 1 {
+		return true
+	}
+	return randFloat64() < t.samplingRate
+}
+
+// extractContext gets the trace context headers from the request of http1, http2 and
+// the other protocols with header maps
+func extractContext(request interface{}) (traceParent string, traceState string) {
+	switch req := request.(type) {
+	case api.HeaderMap:
+		traceParent, _ = req.Get(TraceParentHeader)
+		traceState, _ = req.Get(TraceStateHeader)
+	case *http.Request:
+		traceParent = req.Header.Get(TraceParentHeader)
+		traceState = req.Header.Get(TraceStateHeader)
+	}
+	return
+}
+
+var (
+	randMux sync.Mutex
+	random  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func randFloat64() float64 {
+	randMux.Lock()
+	defer randMux.Unlock()
+	return random.Float64()
+}
+
+// newId generates a random id in lower hex, the id with all zero is invalid
+func newId(size int) string {
+	b := make([]byte, size)
+	randMux.Lock()
+	defer randMux.Unlock()
+	for {
+		random.Read(b)
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package w3c
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func newTestTracer(t *testing.T, config map[string]interface{}) *Tracer {
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	if _, ok := config[logPathKey]; !ok {
+		config[logPathKey] = "/tmp/mosn_w3c_test/trace.log"
+	}
+	tracer, err := NewTracer(config)
+	require.Nil(t, err)
+	return tracer.(*Tracer)
+}
+
+func TestTracerPropagateTraceParent(t *testing.T) {
+	tracer := newTestTracer(t, nil)
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header := protocol.CommonHeader{
+		TraceParentHeader: incoming,
+		TraceStateHeader:  "congo=t61rcWkgMzE",
+	}
+	span := tracer.Start(context.Background(), header, time.Now())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceId())
+	assert.Equal(t, "00f067aa0ba902b7", span.ParentSpanId())
+	assert.NotEqual(t, "00f067aa0ba902b7", span.SpanId())
+
+	span.InjectContext(header, network.NewRequestInfo())
+	parent, ok := ParseTraceParent(header[TraceParentHeader])
+	require.True(t, ok)
+	// the trace id and the sampling decision are kept, the span id is updated
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parent.TraceId)
+	assert.Equal(t, span.SpanId(), parent.SpanId)
+	assert.True(t, parent.Sampled())
+	assert.Equal(t, "congo=t61rcWkgMzE", header[TraceStateHeader])
+
+	// the downstream's sampling decision is kept
+	header = protocol.CommonHeader{
+		TraceParentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+	}
+	span = tracer.Start(context.Background(), header, time.Now())
+	span.InjectContext(header, network.NewRequestInfo())
+	parent, ok = ParseTraceParent(header[TraceParentHeader])
+	require.True(t, ok)
+	assert.False(t, parent.Sampled())
+}
+
+func TestTracerCreateTraceParent(t *testing.T) {
+	tracer := newTestTracer(t, nil)
+	for _, header := range []protocol.CommonHeader{
+		{},
+		// the invalid traceparent is replaced
+		{TraceParentHeader: "invalid", TraceStateHeader: "congo=t61rcWkgMzE"},
+	} {
+		span := tracer.Start(context.Background(), header, time.Now())
+		assert.Len(t, span.TraceId(), traceIdLength)
+		assert.Len(t, span.SpanId(), spanIdLength)
+		assert.Empty(t, span.ParentSpanId())
+
+		span.InjectContext(header, network.NewRequestInfo())
+		parent, ok := ParseTraceParent(header[TraceParentHeader])
+		require.True(t, ok)
+		assert.Equal(t, span.TraceId(), parent.TraceId)
+		assert.Equal(t, span.SpanId(), parent.SpanId)
+		assert.True(t, parent.Sampled())
+	}
+	// the http2 request
+	request := &http.Request{Header: http.Header{}}
+	request.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	span := tracer.Start(context.Background(), request, time.Now())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceId())
+}
+
+func TestTracerSampling(t *testing.T) {
+	_, err := NewTracer(map[string]interface{}{samplingRateKey: 2.0})
+	assert.NotNil(t, err)
+	_, err = NewTracer(map[string]interface{}{samplingRateKey: "1"})
+	assert.NotNil(t, err)
+
+	tracer := newTestTracer(t, map[string]interface{}{samplingRateKey: 0.0})
+	header := protocol.CommonHeader{}
+	span := tracer.Start(context.Background(), header, time.Now())
+	assert.False(t, span.(*Span).Sampled())
+	span.InjectContext(header, network.NewRequestInfo())
+	assert.True(t, strings.HasSuffix(header[TraceParentHeader], "-00"))
+}
+
+func TestSpanRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logPath := "/tmp/mosn_w3c_test/span_record.log"
+	os.Remove(logPath)
+	tracer := newTestTracer(t, map[string]interface{}{logPathKey: logPath})
+	header := protocol.CommonHeader{
+		TraceParentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	span := tracer.Start(context.Background(), header, time.Now())
+
+	host := mock.NewMockHost(ctrl)
+	host.EXPECT().AddressString().Return("127.0.0.1:8080").AnyTimes()
+	reqinfo := network.NewRequestInfo()
+	reqinfo.SetProtocol(protocol.HTTP1)
+	reqinfo.(types.UpstreamClusterRecorder).SetUpstreamClusterName("test_cluster")
+	reqinfo.OnUpstreamHostSelected(host)
+	reqinfo.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 12345})
+	reqinfo.SetResponseCode(http.StatusOK)
+	time.Sleep(10 * time.Millisecond)
+	span.SetRequestInfo(reqinfo)
+	span.FinishSpan()
+
+	var record SpanRecord
+	require.Eventually(t, func() bool {
+		data, err := ioutil.ReadFile(logPath)
+		if err != nil || len(data) == 0 {
+			return false
+		}
+		return json.Unmarshal(data, &record) == nil
+	}, 3*time.Second, 50*time.Millisecond)
+	assert.Equal(t, span.TraceId(), record.TraceId)
+	assert.Equal(t, span.SpanId(), record.SpanId)
+	assert.Equal(t, "00f067aa0ba902b7", record.ParentSpanId)
+	assert.Equal(t, "test_cluster", record.Cluster)
+	assert.Equal(t, "127.0.0.1:8080", record.UpstreamHost)
+	assert.Equal(t, "127.0.0.2:12345", record.DownstreamHost)
+	assert.Equal(t, http.StatusOK, record.ResponseCode)
+	assert.True(t, record.Duration >= (10*time.Millisecond).Microseconds())
+}
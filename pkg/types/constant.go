@@ -59,6 +59,7 @@ var reason2code = map[StreamResetReason]int{
 	UpstreamReset:             api.NoHealthUpstreamCode,
 	StreamLocalReset:          api.NoHealthUpstreamCode,
 	StreamConnectionFailed:    api.NoHealthUpstreamCode,
+	StreamRefused:             api.NoHealthUpstreamCode,
 }
 
 // ConvertReasonToCode is convert the reason to a spec code.
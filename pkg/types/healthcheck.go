@@ -44,6 +44,22 @@ type HealthChecker interface {
 	SetHealthCheckerHostSet(HostSet)
 }
 
+// OutlierDetector is a passive health checker, it records the results of the real traffic,
+// and ejects the host which fails continuously for a while.
+type OutlierDetector interface {
+	// PutResult records the result of a request to the host
+	PutResult(host Host, success bool)
+	// SetHostSet resets the outlier detector's hostset
+	SetHostSet(HostSet)
+	// Stop terminates outlier detector
+	Stop()
+}
+
+// OutlierDetectorGetter is implemented by the ClusterInfo which has an outlier detector
+type OutlierDetectorGetter interface {
+	OutlierDetector() OutlierDetector
+}
+
 // HealthCheckSession is an interface for health check logic
 // The health checker framework support register different session for different protocol.
 // The default session implementation is tcp dial, for all non-registered protocol.
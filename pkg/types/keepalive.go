@@ -17,7 +17,11 @@
 
 package types
 
-import "time"
+import (
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
 
 type KeepAlive interface {
 	// SendKeepAlive sends a heartbeat request for keepalive
@@ -41,3 +45,9 @@ const (
 
 // KeepAliveCallback is a callback when keep alive handle response/timeout
 type KeepAliveCallback func(KeepAliveStatus)
+
+// KeepAliveConfigGetter is implemented by the cluster info which configures the heartbeats on the upstream connections
+type KeepAliveConfigGetter interface {
+	// KeepAliveConfig returns the keepalive config, nil means the default keepalive is used
+	KeepAliveConfig() *v2.KeepAliveConfig
+}
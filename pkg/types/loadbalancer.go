@@ -22,6 +22,8 @@ import (
 	"net"
 
 	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
 )
 
 // LoadBalancerType is the load balancer's type
@@ -36,6 +38,9 @@ const (
 	LeastActiveRequest LoadBalancerType = "LB_LEAST_REQUEST"
 	Maglev             LoadBalancerType = "LB_MAGLEV"
 	RequestRoundRobin  LoadBalancerType = "LB_REQUEST_ROUNDROBIN"
+	RingHash           LoadBalancerType = "LB_RING_HASH"
+	// WeightedLeastActiveRequest chooses the host with the least active request divided by weight
+	WeightedLeastActiveRequest LoadBalancerType = "LB_WEIGHTED_LEAST_REQUEST"
 )
 
 // LoadBalancer is a upstream load balancer.
@@ -50,6 +55,18 @@ type LoadBalancer interface {
 	HostNum(api.MetadataMatchCriteria) int
 }
 
+// StickySessionCluster is implemented by the cluster info which enables the cookie based session affinity
+type StickySessionCluster interface {
+	// StickySessionConfig returns the sticky session config, nil means the sticky session is disabled
+	StickySessionConfig() *v2.StickySessionConfig
+}
+
+// LocalityLbCluster is implemented by the cluster info which prefers the hosts in the same locality as mosn
+type LocalityLbCluster interface {
+	// LocalityLbConfig returns the locality config, nil means the locality is ignored
+	LocalityLbConfig() *v2.LocalityLbConfig
+}
+
 // LoadBalancerContext contains the information for choose a host
 type LoadBalancerContext interface {
 
@@ -187,6 +187,19 @@ type ClientConnection interface {
 	Connect() error
 }
 
+// ProxyProtocolConnection is implemented by the ClientConnection which can send the proxy protocol header
+type ProxyProtocolConnection interface {
+	// SetProxyProtocolHeader sets the header written as the first bytes after the connection is established,
+	// it should be called before Connect
+	SetProxyProtocolHeader(header []byte)
+}
+
+// ServerNameConnection is implemented by the ClientConnection which can choose the tls server name (SNI)
+type ServerNameConnection interface {
+	// SetServerName sets the server name of the tls handshake, it should be called before Connect
+	SetServerName(serverName string)
+}
+
 // Default connection arguments
 var (
 	DefaultConnReadTimeout  = 15 * time.Second
@@ -236,6 +249,10 @@ type ConnectionHandler interface {
 
 	// StopConnection Stop Connection
 	StopConnection()
+
+	// UpdateTLSContext rotates the certificate of a listener by listener name,
+	// the new connections use the new certificate and the existing connections are not affected.
+	UpdateTLSContext(listenerName string, cfg v2.TLSConfig) error
 }
 
 type FilterChainFactory interface {
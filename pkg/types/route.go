@@ -65,6 +65,14 @@ type RouterManager interface {
 	RemoveAllRoutes(routerConfigName, domain string) error
 }
 
+// RouteUpdater is implemented by the RouterManager which updates a single route at runtime
+type RouteUpdater interface {
+	// SetRoute replaces the route at the index of the virtual host, a negative index appends the route
+	SetRoute(routerConfigName, virtualHost string, index int, route *v2.Router) error
+	// DeleteRoute removes the route at the index of the virtual host
+	DeleteRoute(routerConfigName, virtualHost string, index int) error
+}
+
 // HandlerStatus returns the Handler's available status
 type HandlerStatus int
 
@@ -84,6 +92,165 @@ type RouteHandler interface {
 	// Route returns handler's route
 	Route() api.Route
 }
+
+// BodyLimitRule is implemented by the route rule which limits the body size of the request and the response
+type BodyLimitRule interface {
+	// MaxRequestBodyBytes returns the max bytes of the request body, zero means no limit
+	MaxRequestBodyBytes() uint64
+	// MaxResponseBodyBytes returns the max bytes of the response body, zero means no limit
+	MaxResponseBodyBytes() uint64
+}
+
+// HedgePolicy is implemented by the retry policy which sends hedged requests
+type HedgePolicy interface {
+	// HedgeDelay returns the delay to send a hedged request if the try has not responded, zero means no hedging
+	HedgeDelay() time.Duration
+}
+
+// IdempotentRetryPolicy is implemented by the retry policy which decides whether to retry the non-idempotent requests
+type IdempotentRetryPolicy interface {
+	// RetryNonIdempotent returns true if the requests with non-idempotent methods, such as POST, can be retried
+	RetryNonIdempotent() bool
+}
+
+// RequestTimeoutRule is implemented by the route rule whose request timeout overrides the cluster default
+type RequestTimeoutRule interface {
+	// RequestTimeout returns the request timeout of the route, ok is false if it is not set.
+	// zero means no timeout.
+	RequestTimeout() (timeout time.Duration, ok bool)
+}
+
+// StreamRequestBodyRule is implemented by the route rule which chooses whether the request body is streamed
+// to the upstream as it arrives or buffered before the request is proxied. the body is streamed only if the
+// downstream protocol receives it as a stream, such as http2 with http2_use_stream, http1 always receives
+// the whole body. the streamed body is still buffered if the route limits the request body size, or
+// any receiver filter requires the whole body.
+type StreamRequestBodyRule interface {
+	// StreamRequestBody returns whether the request body is streamed, ok is false if the route
+	// does not choose it, and the body is streamed if the downstream protocol receives it as a stream.
+	StreamRequestBody() (stream bool, ok bool)
+}
+
+// PriorityRule is implemented by the route rule which routes the requests with a priority
+type PriorityRule interface {
+	// Priority returns the priority of the requests matched the route
+	Priority() RoutingPriority
+}
+
+// AdmissionPriorityRule is implemented by the route rule which sets the priority level of the requests
+// in the admission queue of the cluster
+type AdmissionPriorityRule interface {
+	// AdmissionPriority returns the priority level of the route, ok is false if it is not set
+	AdmissionPriority() (level int, ok bool)
+}
+
+// CanaryRule is implemented by the route rule which sends a part of the requests to a canary cluster
+type CanaryRule interface {
+	// CanaryPolicy returns the canary policy of the route, nil means no canary cluster
+	CanaryPolicy() CanaryPolicy
+}
+
+// CanaryPolicy decides whether a request is sent to the canary cluster,
+// the headers are evaluated before the percentage.
+type CanaryPolicy interface {
+	// ClusterName returns the canary cluster name
+	ClusterName() string
+	// MatchHeaders returns true if the request headers force the canary cluster
+	MatchHeaders(ctx context.Context, headers api.HeaderMap) bool
+	// HashHeader returns the header whose value is the hash key of the percentage
+	HashHeader() string
+	// InPercentage returns true if the hash key falls into the canary percentage,
+	// an empty key is assigned randomly.
+	InPercentage(key string) bool
+}
+
+// CanaryDecision is the reason why the request is sent to the canary cluster or not
+type CanaryDecision string
+
+const (
+	// CanaryByHeader means the request headers force the canary cluster
+	CanaryByHeader CanaryDecision = "header"
+	// CanaryByPercentage means the request falls into the canary percentage
+	CanaryByPercentage CanaryDecision = "percentage"
+	// CanaryPrimary means the request is sent to the route cluster
+	CanaryPrimary CanaryDecision = "primary"
+)
+
+// CanaryRecorder is implemented by the request info which records the canary decision of the request
+type CanaryRecorder interface {
+	// CanaryDecision returns the canary decision, it is empty if the route has no canary cluster
+	CanaryDecision() CanaryDecision
+	// SetCanaryDecision records the canary decision
+	SetCanaryDecision(decision CanaryDecision)
+}
+
+// UpstreamClusterRecorder is implemented by the request info which records the cluster chosen for the request,
+// the cluster may be chosen randomly from the weighted clusters of the route
+type UpstreamClusterRecorder interface {
+	// UpstreamClusterName returns the name of the chosen cluster
+	UpstreamClusterName() string
+	// SetUpstreamClusterName records the name of the chosen cluster
+	SetUpstreamClusterName(name string)
+}
+
+// GrpcStatusRecorder is implemented by the request info which records the grpc status of the response,
+// the grpc status is carried in the grpc-status trailer while the http status is always 200
+type GrpcStatusRecorder interface {
+	// GrpcStatus returns the grpc status of the response, ok is false if the response is not a grpc response
+	GrpcStatus() (status int, ok bool)
+	// SetGrpcStatus records the grpc status of the response
+	SetGrpcStatus(status int)
+}
+
+// GrpcRetryPolicy is implemented by the retry policy which retries the grpc requests by the grpc status
+type GrpcRetryPolicy interface {
+	// RetryableGrpcStatusCodes returns the grpc status codes to retry, such as 14 (UNAVAILABLE)
+	RetryableGrpcStatusCodes() []uint32
+}
+
+// HeaderRetryPolicy is implemented by the retry policy which retries on the response headers
+type HeaderRetryPolicy interface {
+	// RetriableHeaders returns the matchers of the response headers, the response matching any of them is retried
+	RetriableHeaders() []HeaderMatcher
+}
+
+// RetryOnConnectFailure is the retry condition of the requests failed to connect the upstream,
+// such as the host is unreachable or the connection is refused.
+const RetryOnConnectFailure = "connect-failure"
+
+// ConnectFailureRetryPolicy is implemented by the retry policy which retries the connect failures separately
+type ConnectFailureRetryPolicy interface {
+	// RetryOnConnectFailure returns true if the requests failed to connect the upstream are retried on
+	// the other hosts whatever the method is, the retries have their own budget of NumRetries.
+	RetryOnConnectFailure() bool
+}
+
+// BackOffRetryPolicy is implemented by the retry policy which backs off exponentially between the retries
+type BackOffRetryPolicy interface {
+	// RetryBackOff returns the base and max interval of the back off, zero base interval means no back off
+	RetryBackOff() (base time.Duration, max time.Duration)
+}
+
+// MultiMirrorPolicy is implemented by the mirror policy which has multiple shadow clusters
+type MultiMirrorPolicy interface {
+	// MirrorClusters returns the shadow clusters sampled for a request, each cluster is sampled independently
+	MirrorClusters() []string
+}
+
+// AccessLogRule is implemented by the route rule which overrides the access logs
+type AccessLogRule interface {
+	// AccessLogDisabled returns true if the requests matched the route are not logged
+	AccessLogDisabled() bool
+	// AccessLogFormatName returns the name of the access log format, empty means use the default format
+	AccessLogFormatName() string
+}
+
+// DirectResponseHeadersRule is implemented by the direct response rule which has the response headers
+type DirectResponseHeadersRule interface {
+	// ResponseHeaders returns the headers of the direct response
+	ResponseHeaders() map[string]string
+}
+
 type RouterWrapper interface {
 	// GetRouters returns the routers in the wrapper
 	GetRouters() Routers
@@ -106,6 +273,21 @@ type QueryParameterMatcher interface {
 	Matches(ctx context.Context, requestQueryParams QueryParams) bool
 }
 
+// BodyMatcher match request's body
+type BodyMatcher interface {
+	// Matches check whether the request body matches the body matchers in the config.
+	Matches(ctx context.Context, body []byte) bool
+}
+
+// RequestBodyRouters is implemented by the routers whose routes match the request body,
+// the request body should be buffered before the route is matched.
+type RequestBodyRouters interface {
+	// MaxRequestBodyMatchBytes returns the largest size of the request body matched by the routes of
+	// the request's virtual host, zero means no route matches the request body. the larger body is
+	// not buffered, and only matches the routes without the body matchers.
+	MaxRequestBodyMatchBytes(ctx context.Context) uint64
+}
+
 // HeaderMatcher match request's headers
 type HeaderMatcher interface {
 	// HeaderMatchCriteria returns the route's HeaderMatchCriteria
@@ -109,6 +109,9 @@ const (
 	UpstreamReset               StreamResetReason = "UpstreamReset"
 	UpstreamGlobalTimeout       StreamResetReason = "UpstreamGlobalTimeout"
 	UpstreamPerTryTimeout       StreamResetReason = "UpstreamPerTryTimeout"
+	// StreamRefused means the stream is not processed by the upstream, such as the streams above the
+	// last stream id of a goaway frame. it is safe to retry and is not counted as a host failure.
+	StreamRefused StreamResetReason = "StreamRefused"
 )
 
 // Stream is a generic protocol stream, it is the core model in stream layer
@@ -170,6 +173,40 @@ type StreamReceiveListener interface {
 	OnDecodeError(ctx context.Context, err error, headers api.HeaderMap)
 }
 
+// StreamInformationalReceiveListener is implemented by the stream receive listener which
+// accepts the informational (1xx) responses before the final response
+type StreamInformationalReceiveListener interface {
+	// OnReceiveInformational is called with the informational response, the stream is not ended
+	OnReceiveInformational(ctx context.Context, headers api.HeaderMap)
+}
+
+// StreamInformationalSender is implemented by the stream sender which sends
+// the informational (1xx) responses before the final response
+type StreamInformationalSender interface {
+	// AppendInformationalHeaders sends the informational response, the stream is not ended
+	AppendInformationalHeaders(ctx context.Context, headers api.HeaderMap) error
+}
+
+// TunnelStream is implemented by the stream whose connection can be switched to a raw bytes tunnel
+// after the protocol upgrade, such as the http1 websocket handshake.
+type TunnelStream interface {
+	// UpgradedConnection returns the connection of the stream if the protocol upgrade is accepted,
+	// the upgraded connection stops decoding the protocol and is never reused.
+	UpgradedConnection() (api.Connection, bool)
+
+	// Tunnel passes the bytes received by the upgraded connection to the receiver
+	Tunnel(receiver TunnelReceiver)
+}
+
+// TunnelReceiver receives the raw bytes of an upgraded connection
+type TunnelReceiver interface {
+	// OnTunnelData is called with the bytes received
+	OnTunnelData(data buffer.IoBuffer)
+
+	// OnTunnelClose is called when the upgraded connection is closed
+	OnTunnelClose()
+}
+
 // StreamConnection is a connection runs multiple streams
 type StreamConnection interface {
 	// Dispatch incoming data
@@ -274,3 +311,9 @@ type ProtocolStreamFactory interface {
 
 	ProtocolMatch(context context.Context, prot string, magic []byte) error
 }
+
+// ProtocolMatcher is an optional interface of the codec registered by xprotocol.RegisterProtocol,
+// the protocol is detected by the first bytes of the connection if the listener uses the auto protocol
+type ProtocolMatcher interface {
+	Match(data []byte) api.MatchResult
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net"
 
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/mtls/crypto/tls"
 )
 
@@ -56,6 +57,14 @@ type TLSContextManager interface {
 	Enabled() bool
 }
 
+// TLSContextUpdater is implemented by the TLSContextManager whose certificates can be rotated,
+// the new handshakes use the new certificates and the established connections are not affected.
+type TLSContextUpdater interface {
+	// UpdateTLSContext replaces the certificate of the tls context with the same server name,
+	// the old certificate is kept if an error is returned.
+	UpdateTLSContext(cfg *v2.TLSConfig) error
+}
+
 // TLSClientContextManager manages the cluster tls config
 type TLSClientContextManager interface {
 	TLSContextManager
@@ -66,6 +75,15 @@ type TLSClientContextManager interface {
 	Fallback() bool
 }
 
+// TLSServerNameContextManager is implemented by the TLSClientContextManager which can choose
+// the server name (SNI) of each connection
+type TLSServerNameContextManager interface {
+	// AutoServerName returns true means the server name should be chosen by the host of the request
+	AutoServerName() bool
+	// ConnWithServerName is the same as Conn, except the handshake uses the server name if it is not empty
+	ConnWithServerName(c net.Conn, serverName string) (net.Conn, error)
+}
+
 // TLSConfigContext contains a tls.Config and a HashValue represents the tls.Config
 type TLSConfigContext struct {
 	config *tls.Config
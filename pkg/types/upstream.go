@@ -87,6 +87,10 @@ type ClusterManager interface {
 	// RemoveClusterHosts, remove the host by address string
 	RemoveClusterHosts(clusterName string, hosts []string) error
 
+	// DrainHost marks the host as draining, a draining host is not chosen for new requests,
+	// and its connection pools are closed after the in-flight requests are finished or the drain timeout is reached
+	DrainHost(clusterName string, addr string) error
+
 	// TLSManager is used to cluster tls config
 	GetTLSManager() TLSClientContextManager
 	// UpdateTLSManager updates the tls manager which is used to cluster tls config
@@ -174,6 +178,54 @@ type Host interface {
 	Config() v2.Host
 }
 
+// HTTP2UpgradeCluster is an optional interface of ClusterInfo.
+// If HTTP2Upgrade returns true, the http1 requests are sent to the cluster by h2c.
+type HTTP2UpgradeCluster interface {
+	HTTP2Upgrade() bool
+}
+
+// RequestTimeoutCluster is an optional interface of ClusterInfo.
+// RequestTimeout returns the default request timeout of the routes to the cluster, zero means the global default.
+type RequestTimeoutCluster interface {
+	RequestTimeout() time.Duration
+}
+
+// DrainableHost is an optional interface of Host that supports graceful draining.
+type DrainableHost interface {
+	// Draining returns true if the host is draining
+	Draining() bool
+	// SetDraining marks the host as draining
+	SetDraining(draining bool)
+}
+
+// SlowStartHost is an optional interface of Host that records the time the host is added or becomes healthy,
+// which the slow start begins at.
+type SlowStartHost interface {
+	// HealthyTime returns the time the host is added or becomes healthy again
+	HealthyTime() time.Time
+	// SetHealthyTime sets the time the slow start begins at
+	SetHealthyTime(t time.Time)
+}
+
+// PENDING_ACTIVE_HC is set on the new host if the warm up of the health check is enabled,
+// the host is not chosen by the load balancer until it passes the health checks.
+const PENDING_ACTIVE_HC api.HealthFlag = 0x08
+
+// NewAddressHost is implemented by the Host which knows whether its address is seen for the first time.
+// the health flags are shared by the hosts with the same address, so only the host of a new address
+// is pending for the health checks, the hosts inherited by the cluster update keep their health state.
+type NewAddressHost interface {
+	NewAddress() bool
+}
+
+// IsHostDraining returns true if the host supports draining and is draining
+func IsHostDraining(host Host) bool {
+	if dh, ok := host.(DrainableHost); ok {
+		return dh.Draining()
+	}
+	return false
+}
+
 // ClusterInfo defines a cluster's information
 type ClusterInfo interface {
 	// Name returns the cluster name
@@ -234,6 +286,162 @@ type ResourceManager interface {
 	Retries() Resource
 }
 
+// RoutingPriority is the priority of a request, the circuit breakers of a cluster are grouped by it
+type RoutingPriority string
+
+const (
+	DefaultPriority RoutingPriority = "default"
+	HighPriority    RoutingPriority = "high"
+)
+
+// CircuitBreaker rejects the requests of a priority for a cooldown when the priority saturates the cluster
+type CircuitBreaker interface {
+	// Allow returns false if the circuit breaker is open, or the half open circuit breaker has sent enough probes.
+	// the allowed request is pending until Ready is called, and it should be finished by Release.
+	// probe is true if the request probes the half open circuit breaker.
+	Allow() (allowed bool, probe bool)
+	// Ready is called when the allowed request gets the upstream stream and is not pending any more
+	Ready()
+	// Release finishes the allowed request, pending is true if Ready is not called.
+	// the result of a probe request closes the circuit breaker or opens it again.
+	Release(pending, probe, success bool)
+}
+
+// CircuitBreakerManager is implemented by the cluster info which has circuit breakers
+type CircuitBreakerManager interface {
+	// CircuitBreaker returns the circuit breaker of the priority, nil means no circuit breaker
+	CircuitBreaker(priority RoutingPriority) CircuitBreaker
+}
+
+// ConcurrencyLimiter limits the in-flight requests to a cluster, the limit adapts to the latency of the requests
+type ConcurrencyLimiter interface {
+	// TryAcquire returns false if the in-flight requests reach the current limit
+	TryAcquire() bool
+	// Release finishes an in-flight request and samples its latency
+	Release(latency time.Duration)
+	// Limit returns the current concurrency limit
+	Limit() uint32
+}
+
+// ConcurrencyLimiterGetter is implemented by the ClusterInfo which has a concurrency limiter
+type ConcurrencyLimiterGetter interface {
+	ConcurrencyLimiter() ConcurrencyLimiter
+}
+
+// AdmissionQueue admits the requests to a cluster by priority if the cluster is near capacity,
+// level 0 is the highest priority
+type AdmissionQueue interface {
+	// Level returns the priority level of the request by the headers
+	Level(headers api.HeaderMap) int
+	// Admit blocks until the request of the level is admitted, it returns false if the queue
+	// of the level is full or the request is not admitted in the queue timeout
+	Admit(level int) bool
+	// Release finishes an admitted request, and admits the waiting request of the highest priority
+	Release()
+}
+
+// AdmissionQueueGetter is implemented by the ClusterInfo which has an admission queue
+type AdmissionQueueGetter interface {
+	AdmissionQueue() AdmissionQueue
+}
+
+// ClusterHeadersGetter is implemented by the ClusterInfo which manipulates the request and response headers,
+// the headers configured in the route and the virtual host take precedence over the cluster's
+type ClusterHeadersGetter interface {
+	RequestHeadersToAdd() []*v2.HeaderValueOption
+	RequestHeadersToRemove() []string
+	ResponseHeadersToAdd() []*v2.HeaderValueOption
+	ResponseHeadersToRemove() []string
+}
+
+// ProxyProtocolCluster is implemented by the ClusterInfo which sends the proxy protocol header
+// on the new upstream connections
+type ProxyProtocolCluster interface {
+	// SendProxyProtocol returns the proxy protocol version, empty means the header is not sent
+	SendProxyProtocol() string
+}
+
+// DownstreamConnPoolReleaser is implemented by the ClusterManager which binds connection pools to
+// the downstream connections, such as the pools sending the proxy protocol header
+type DownstreamConnPoolReleaser interface {
+	// ReleaseDownstreamConnPools closes the connection pools bound to the closed downstream connection
+	ReleaseDownstreamConnPools(remoteAddr, localAddr string)
+}
+
+// SlowStartCluster is implemented by the ClusterInfo which enables the slow start of the hosts
+type SlowStartCluster interface {
+	// SlowStart returns the slow start config, nil means the slow start is disabled
+	SlowStart() *v2.SlowStartConfig
+}
+
+// HostConnectionLimitCluster is implemented by the ClusterInfo which limits the connections to a single host
+type HostConnectionLimitCluster interface {
+	// MaxConnectionsPerHost returns the max connections to a single host, zero means no limit
+	MaxConnectionsPerHost() uint32
+}
+
+// MaintenanceModeCluster is implemented by the ClusterInfo which can be put into the maintenance mode,
+// the requests routed to the cluster in maintenance mode are rejected with 503
+type MaintenanceModeCluster interface {
+	// MaintenanceMode returns true if the cluster is in maintenance mode
+	MaintenanceMode() bool
+	// SetMaintenanceMode enables or disables the maintenance mode, it is reset when the cluster config is updated
+	SetMaintenanceMode(enable bool)
+}
+
+// Http2ConnPoolCluster is implemented by the ClusterInfo which tunes the http2 connection pool
+type Http2ConnPoolCluster interface {
+	// Http2ConnPool returns the http2 connection pool config, nil means the default pool
+	Http2ConnPool() *v2.Http2ConnPoolConfig
+}
+
+// UpstreamIdleTimeoutCluster is implemented by the ClusterInfo which closes the idle pooled connections
+type UpstreamIdleTimeoutCluster interface {
+	// UpstreamIdleTimeout returns how long a pooled connection can be idle, zero means never closed
+	UpstreamIdleTimeout() time.Duration
+}
+
+// ConnPoolPrefetchCluster is implemented by the ClusterInfo which creates the pooled connections ahead of the demand
+type ConnPoolPrefetchCluster interface {
+	// ConnPoolPrefetch returns the prefetch config of the connection pools, nil means no connection is prefetched
+	ConnPoolPrefetch() *v2.ConnPoolPrefetchConfig
+}
+
+// ConnectionCountHost is an optional interface of Host that counts the connections created by the host
+type ConnectionCountHost interface {
+	// ActiveConnections returns the number of the connections created by the host and not closed yet
+	ActiveConnections() int64
+}
+
+// ConnectionLimitHost is an optional interface of Host that creates the connections within the cluster's
+// max connections per host, the connection is counted before it is dialed
+type ConnectionLimitHost interface {
+	// CreateLimitedConnection creates a connection as CreateConnection, ok is false and no connection
+	// is created if the connections to the host reach the limit
+	CreateLimitedConnection(ctx context.Context) (data CreateConnectionData, ok bool)
+}
+
+// CreateHostConnection creates a connection to the host within the cluster's max connections per host
+func CreateHostConnection(ctx context.Context, host Host) (CreateConnectionData, bool) {
+	if lh, ok := host.(ConnectionLimitHost); ok {
+		return lh.CreateLimitedConnection(ctx)
+	}
+	return host.CreateConnection(ctx), true
+}
+
+// IsHostConnectionOverflow returns true if the connections to the host reach the cluster's max connections per host
+func IsHostConnectionOverflow(host Host) bool {
+	ch, ok := host.(ConnectionCountHost)
+	if !ok {
+		return false
+	}
+	lc, ok := host.ClusterInfo().(HostConnectionLimitCluster)
+	if !ok || lc.MaxConnectionsPerHost() == 0 {
+		return false
+	}
+	return ch.ActiveConnections() >= int64(lc.MaxConnectionsPerHost())
+}
+
 // Resource is an interface to statistics information
 type Resource interface {
 	CanCreate() bool
@@ -297,6 +505,16 @@ type ClusterStats struct {
 	UpstreamResponseFailed                         metrics.Counter
 	LBSubSetsFallBack                              metrics.Counter
 	LBSubsetsCreated                               metrics.Gauge
+	UpstreamConnectionIdle                         metrics.Counter
+	UpstreamRequestPending                         metrics.Counter
+	UpstreamRequestCircuitBreakerOpen              metrics.Counter
+	UpstreamRequestConcurrencyLimited              metrics.Counter
+	UpstreamDnsResolveFailure                      metrics.Counter
+	UpstreamConnectionHostOverflow                 metrics.Counter
+	UpstreamRequestMaintenanceMode                 metrics.Counter
+	UpstreamRequestAdmissionRejected               metrics.Counter
+	UpstreamConnectionGoAway                       metrics.Counter
+	UpstreamRequestConnectFailureRetry             metrics.Counter
 }
 
 type CreateConnectionData struct {
@@ -47,6 +47,7 @@ const (
 	VarRequestedServerName            string = "requested_server_name"
 	VarRouteName                      string = "route_name"
 	VarProtocolConfig                 string = "protocol_config"
+	VarGrpcStatus                     string = "grpc_status"
 
 	// ReqHeaderPrefix is the prefix of request header's formatter
 	VarPrefixReqHeader string = "request_header_"
@@ -62,6 +63,7 @@ const (
 	VarProxyGzipSwitch       string = "proxy_gzip_switch"
 	VarProxyIsDirectResponse string = "proxy_direct_response"
 	VarProxyDisableRetry     string = "proxy_disable_retry"
+	VarProxyClientAddress    string = "proxy_client_address"
 	VarDirection             string = "x-mosn-direction"
 	VarScheme                string = "x-mosn-scheme"
 	VarHost                  string = "x-mosn-host"
@@ -88,6 +90,10 @@ const (
 // [Route]: internal
 const (
 	VarRouterMeta string = "x-mosn-router-meta"
+	// VarRouterHeaderCaptures stores the regex capture groups of the route header matchers
+	VarRouterHeaderCaptures string = "x-mosn-router-header-captures"
+	// VarRouterRequestBody stores the buffered request body for the route body matchers
+	VarRouterRequestBody string = "x-mosn-router-request-body"
 )
 
 // [Protocol]: common
@@ -166,6 +172,7 @@ const (
 	VarDownStreamReqHeaders        = "downstream_req_headers"
 	VarDownStreamRespHeaders       = "downstream_resp_headers"
 	VarTraceSpan                   = "trace_span"
+	VarStickySessionCookie         = "sticky_session_cookie"
 )
 
 var (
@@ -193,6 +200,7 @@ var (
 	VariableDownStreamReqHeaders        = variable.NewVariable(VarDownStreamReqHeaders, nil, nil, variable.DefaultSetter, 0)
 	VariableDownStreamRespHeaders       = variable.NewVariable(VarDownStreamRespHeaders, nil, nil, variable.DefaultSetter, 0)
 	VariableTraceSpan                   = variable.NewVariable(VarTraceSpan, nil, nil, variable.DefaultSetter, 0)
+	VariableStickySessionCookie         = variable.NewVariable(VarStickySessionCookie, nil, nil, variable.DefaultSetter, 0)
 )
 
 func init() {
@@ -203,6 +211,7 @@ func init() {
 		VariableTraceSpankey, VariableTraceId, VariableProxyGeneralConfig, VariableConnectionEventListeners,
 		VariableUpstreamConnectionID, VariableOriRemoteAddr,
 		VariableDownStreamProtocol, VariableUpstreamProtocol, VariableDownStreamReqHeaders, VariableDownStreamRespHeaders, VariableTraceSpan,
+		VariableStickySessionCookie,
 	}
 	for _, v := range builtinVariables {
 		variable.Register(v)
@@ -219,3 +228,39 @@ func init() {
 		return api.ProtocolName("-"), errors.New("invalid protocol name")
 	}
 }
+
+// connectionVariables are the variables of the request which decide how the upstream connection is created,
+// such as the tls server name and the proxy protocol header
+var connectionVariables = []string{
+	VarHost,
+	VarDownstreamRemoteAddress,
+	VarDownstreamLocalAddress,
+}
+
+type connectionContextKey struct{}
+
+// NewConnectionContext returns a context keeps the connection variables of the request. the context does not
+// refer to the request, so the connection pool can create the connections with it after the request is finished.
+func NewConnectionContext(ctx context.Context) context.Context {
+	values := make(map[string]string, len(connectionVariables))
+	if ctx != nil {
+		for _, name := range connectionVariables {
+			if v, err := variable.GetString(ctx, name); err == nil {
+				values[name] = v
+			}
+		}
+	}
+	return context.WithValue(context.Background(), connectionContextKey{}, values)
+}
+
+// GetConnectionVariable returns the connection variable from the context created by NewConnectionContext,
+// or from the variables of the request.
+func GetConnectionVariable(ctx context.Context, name string) (string, error) {
+	if values, ok := ctx.Value(connectionContextKey{}).(map[string]string); ok {
+		if v, ok := values[name]; ok {
+			return v, nil
+		}
+		return "", errors.New("connection variable not found: " + name)
+	}
+	return variable.GetString(ctx, name)
+}
@@ -0,0 +1,219 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// defaultAdmissionQueueTimeout is how long a request waits to be admitted if the queue timeout is not configured
+const defaultAdmissionQueueTimeout = time.Second
+
+// admissionQueue admits the requests up to the max concurrency, the other requests wait in the
+// queues of their priority levels. When an admitted request finishes, the waiting request of the
+// highest effective level is admitted, the effective level of a waiting request is promoted one
+// level every aging interval, the earlier request is admitted if the effective levels are equal.
+type admissionQueue struct {
+	maxConcurrency uint32
+	maxQueued      []uint32
+	priorityHeader string
+	headerValues   map[string]uint32
+	defaultLevel   int
+	queueTimeout   time.Duration
+	agingInterval  time.Duration
+
+	mutex  sync.Mutex
+	active uint32
+	queues []*list.List
+	now    func() time.Time
+}
+
+type admissionWaiter struct {
+	level    int
+	enqueued time.Time
+	admitted bool
+	ready    chan struct{}
+}
+
+// newAdmissionQueue returns nil if the max concurrency is not configured
+func newAdmissionQueue(cfg *v2.AdmissionQueue) *admissionQueue {
+	if cfg == nil || cfg.MaxConcurrency == 0 {
+		return nil
+	}
+	q := &admissionQueue{
+		maxConcurrency: cfg.MaxConcurrency,
+		priorityHeader: cfg.PriorityHeader,
+		headerValues:   cfg.HeaderValues,
+		queueTimeout:   cfg.QueueTimeout.Duration,
+		agingInterval:  cfg.AgingInterval.Duration,
+		now:            time.Now,
+	}
+	levels := len(cfg.Levels)
+	if levels == 0 {
+		levels = 1
+	}
+	q.maxQueued = make([]uint32, levels)
+	q.queues = make([]*list.List, levels)
+	for i := range q.queues {
+		if i < len(cfg.Levels) {
+			q.maxQueued[i] = cfg.Levels[i].MaxQueued
+		}
+		q.queues[i] = list.New()
+	}
+	q.defaultLevel = levels - 1
+	if cfg.DefaultLevel != nil {
+		q.defaultLevel = q.clamp(int(*cfg.DefaultLevel))
+	}
+	if q.queueTimeout <= 0 {
+		q.queueTimeout = defaultAdmissionQueueTimeout
+	}
+	return q
+}
+
+func (q *admissionQueue) clamp(level int) int {
+	if level < 0 {
+		return 0
+	}
+	if level >= len(q.queues) {
+		return len(q.queues) - 1
+	}
+	return level
+}
+
+func (q *admissionQueue) Level(headers api.HeaderMap) int {
+	if q.priorityHeader == "" || headers == nil {
+		return q.defaultLevel
+	}
+	value, ok := headers.Get(q.priorityHeader)
+	if !ok {
+		return q.defaultLevel
+	}
+	if level, ok := q.headerValues[value]; ok {
+		return q.clamp(int(level))
+	}
+	if level, err := strconv.Atoi(value); err == nil {
+		return q.clamp(level)
+	}
+	return q.defaultLevel
+}
+
+func (q *admissionQueue) Admit(level int) bool {
+	level = q.clamp(level)
+
+	q.mutex.Lock()
+	if q.active < q.maxConcurrency && q.waiting() == 0 {
+		q.active++
+		q.mutex.Unlock()
+		return true
+	}
+	queue := q.queues[level]
+	if max := q.maxQueued[level]; max != 0 && uint32(queue.Len()) >= max {
+		q.mutex.Unlock()
+		return false
+	}
+	w := &admissionWaiter{
+		level:    level,
+		enqueued: q.now(),
+		ready:    make(chan struct{}),
+	}
+	e := queue.PushBack(w)
+	q.mutex.Unlock()
+
+	timer := time.NewTimer(q.queueTimeout)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return true
+	case <-timer.C:
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		// the request is admitted before the lock is acquired
+		if w.admitted {
+			return true
+		}
+		queue.Remove(e)
+		return false
+	}
+}
+
+func (q *admissionQueue) Release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.active > 0 {
+		q.active--
+	}
+	for q.active < q.maxConcurrency {
+		e := q.next()
+		if e == nil {
+			return
+		}
+		w := e.Value.(*admissionWaiter)
+		q.queues[w.level].Remove(e)
+		w.admitted = true
+		q.active++
+		close(w.ready)
+	}
+}
+
+// waiting returns the number of the waiting requests, must be called with the lock held
+func (q *admissionQueue) waiting() int {
+	n := 0
+	for _, queue := range q.queues {
+		n += queue.Len()
+	}
+	return n
+}
+
+// next returns the waiting request to be admitted, must be called with the lock held.
+// the requests in a queue are in order, so only the first one of each queue is compared.
+func (q *admissionQueue) next() *list.Element {
+	now := q.now()
+	var best *list.Element
+	bestLevel := 0
+	for _, queue := range q.queues {
+		e := queue.Front()
+		if e == nil {
+			continue
+		}
+		w := e.Value.(*admissionWaiter)
+		level := q.effectiveLevel(w, now)
+		if best == nil || level < bestLevel ||
+			(level == bestLevel && w.enqueued.Before(best.Value.(*admissionWaiter).enqueued)) {
+			best = e
+			bestLevel = level
+		}
+	}
+	return best
+}
+
+func (q *admissionQueue) effectiveLevel(w *admissionWaiter, now time.Time) int {
+	if q.agingInterval <= 0 {
+		return w.level
+	}
+	level := w.level - int(now.Sub(w.enqueued)/q.agingInterval)
+	if level < 0 {
+		return 0
+	}
+	return level
+}
@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+// admitAsync admits the request in a goroutine, the level is sent to the channel once admitted
+func admitAsync(q *admissionQueue, level int, admitted chan<- int) {
+	go func() {
+		if q.Admit(level) {
+			admitted <- level
+		}
+	}()
+}
+
+// waitQueued waits until the number of the waiting requests is n
+func waitQueued(t *testing.T, q *admissionQueue, n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mutex.Lock()
+		waiting := q.waiting()
+		q.mutex.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiting requests", n)
+}
+
+func expectAdmitted(t *testing.T, admitted <-chan int, level int) {
+	select {
+	case l := <-admitted:
+		if l != level {
+			t.Fatalf("expected level %d admitted, but got %d", level, l)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected level %d admitted", level)
+	}
+}
+
+func TestNewAdmissionQueue(t *testing.T) {
+	if q := newAdmissionQueue(nil); q != nil {
+		t.Fatal("expected no queue without config")
+	}
+	if q := newAdmissionQueue(&v2.AdmissionQueue{}); q != nil {
+		t.Fatal("expected no queue without max concurrency")
+	}
+	q := newAdmissionQueue(&v2.AdmissionQueue{MaxConcurrency: 1})
+	if len(q.queues) != 1 || q.defaultLevel != 0 || q.queueTimeout != defaultAdmissionQueueTimeout {
+		t.Fatalf("unexpected default queue: %+v", q)
+	}
+}
+
+func TestAdmissionQueueLevel(t *testing.T) {
+	defaultLevel := uint32(1)
+	q := newAdmissionQueue(&v2.AdmissionQueue{
+		MaxConcurrency: 1,
+		Levels:         make([]v2.AdmissionQueueLevel, 3),
+		PriorityHeader: "x-priority",
+		HeaderValues:   map[string]uint32{"high": 0, "low": 2},
+		DefaultLevel:   &defaultLevel,
+	})
+	for value, level := range map[string]int{
+		"high":    0,
+		"low":     2,
+		"2":       2,
+		"10":      2,
+		"-1":      0,
+		"unknown": 1,
+	} {
+		headers := protocol.CommonHeader{"x-priority": value}
+		if l := q.Level(headers); l != level {
+			t.Fatalf("header %s expected level %d, but got %d", value, level, l)
+		}
+	}
+	if l := q.Level(protocol.CommonHeader{}); l != 1 {
+		t.Fatalf("expected the default level, but got %d", l)
+	}
+}
+
+func TestAdmissionQueuePriority(t *testing.T) {
+	q := newAdmissionQueue(&v2.AdmissionQueue{
+		MaxConcurrency: 1,
+		Levels:         make([]v2.AdmissionQueueLevel, 3),
+	})
+	if !q.Admit(2) {
+		t.Fatal("expected admitted under the max concurrency")
+	}
+	admitted := make(chan int, 3)
+	admitAsync(q, 2, admitted)
+	waitQueued(t, q, 1)
+	admitAsync(q, 0, admitted)
+	waitQueued(t, q, 2)
+	admitAsync(q, 1, admitted)
+	waitQueued(t, q, 3)
+
+	// the waiting requests are admitted by priority
+	for _, level := range []int{0, 1, 2} {
+		q.Release()
+		expectAdmitted(t, admitted, level)
+	}
+	q.Release()
+	if q.active != 0 {
+		t.Fatalf("unexpected active requests: %d", q.active)
+	}
+}
+
+func TestAdmissionQueueAging(t *testing.T) {
+	now := time.Now().UnixNano()
+	q := newAdmissionQueue(&v2.AdmissionQueue{
+		MaxConcurrency: 1,
+		Levels:         make([]v2.AdmissionQueueLevel, 3),
+		AgingInterval:  api.DurationConfig{Duration: time.Second},
+	})
+	q.now = func() time.Time {
+		return time.Unix(0, atomic.LoadInt64(&now))
+	}
+	if !q.Admit(0) {
+		t.Fatal("expected admitted under the max concurrency")
+	}
+	admitted := make(chan int, 2)
+	admitAsync(q, 2, admitted)
+	waitQueued(t, q, 1)
+	// the low priority request is promoted to the highest level after waiting two intervals
+	atomic.AddInt64(&now, int64(2*time.Second))
+	admitAsync(q, 0, admitted)
+	waitQueued(t, q, 2)
+
+	// the earlier request is admitted if the levels are equal
+	q.Release()
+	expectAdmitted(t, admitted, 2)
+	q.Release()
+	expectAdmitted(t, admitted, 0)
+}
+
+func TestAdmissionQueueReject(t *testing.T) {
+	q := newAdmissionQueue(&v2.AdmissionQueue{
+		MaxConcurrency: 1,
+		Levels:         []v2.AdmissionQueueLevel{{MaxQueued: 1}},
+		QueueTimeout:   api.DurationConfig{Duration: 100 * time.Millisecond},
+	})
+	if !q.Admit(0) {
+		t.Fatal("expected admitted under the max concurrency")
+	}
+	timeout := make(chan bool, 1)
+	go func() {
+		timeout <- q.Admit(0)
+	}()
+	waitQueued(t, q, 1)
+	// the queue of the level is full
+	if q.Admit(0) {
+		t.Fatal("expected rejected if the queue is full")
+	}
+	// the waiting request is rejected after the queue timeout
+	select {
+	case ok := <-timeout:
+		if ok {
+			t.Fatal("expected rejected after the queue timeout")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the queue timeout")
+	}
+	waitQueued(t, q, 0)
+	// the released concurrency is available again
+	q.Release()
+	if !q.Admit(0) {
+		t.Fatal("expected admitted after released")
+	}
+}
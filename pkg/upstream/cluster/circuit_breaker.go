@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"sync/atomic"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	circuitBreakerClosed uint32 = iota
+	circuitBreakerOpen
+	circuitBreakerHalfOpen
+)
+
+const defaultHalfOpenRequests = 1
+
+// circuitBreaker opens when the requests of a priority saturate the cluster, the saturation is measured by
+// the active and pending requests of the priority, and the connections of the cluster. An open circuit breaker
+// rejects all the requests until the cooldown ends, and then it half opens to let a few requests probe the cluster.
+type circuitBreaker struct {
+	maxConnections     int64
+	maxPendingRequests int64
+	maxRequests        int64
+	halfOpenRequests   int64
+	cooldown           time.Duration
+	stats              types.ClusterStats
+
+	// requests and pending are the active and pending requests of the priority
+	requests int64
+	pending  int64
+
+	state uint32
+	// openTime is the unix nano time when the circuit breaker opens
+	openTime int64
+	// probes and probeSuccesses are the probe requests sent and succeeded in the half open state
+	probes         int64
+	probeSuccesses int64
+}
+
+// newCircuitBreakers creates a circuit breaker for each priority which configures a cooldown
+func newCircuitBreakers(circuitBreakers v2.CircuitBreakers, stats types.ClusterStats) map[types.RoutingPriority]*circuitBreaker {
+	var breakers map[types.RoutingPriority]*circuitBreaker
+	for _, t := range circuitBreakers.Thresholds {
+		if t.Cooldown.Duration <= 0 {
+			continue
+		}
+		priority := types.RoutingPriority(t.Priority)
+		if priority == "" {
+			priority = types.DefaultPriority
+		}
+		if breakers == nil {
+			breakers = make(map[types.RoutingPriority]*circuitBreaker)
+		}
+		halfOpenRequests := int64(t.HalfOpenRequests)
+		if halfOpenRequests == 0 {
+			halfOpenRequests = defaultHalfOpenRequests
+		}
+		breakers[priority] = &circuitBreaker{
+			maxConnections:     int64(t.MaxConnections),
+			maxPendingRequests: int64(t.MaxPendingRequests),
+			maxRequests:        int64(t.MaxRequests),
+			halfOpenRequests:   halfOpenRequests,
+			cooldown:           t.Cooldown.Duration,
+			stats:              stats,
+		}
+	}
+	return breakers
+}
+
+func (cb *circuitBreaker) Allow() (bool, bool) {
+	state := atomic.LoadUint32(&cb.state)
+	if state == circuitBreakerClosed {
+		if cb.saturated() {
+			cb.open(circuitBreakerClosed)
+			return false, false
+		}
+		cb.admit()
+		return true, false
+	}
+	if state == circuitBreakerOpen {
+		if time.Now().UnixNano()-atomic.LoadInt64(&cb.openTime) < int64(cb.cooldown) {
+			return false, false
+		}
+		atomic.CompareAndSwapUint32(&cb.state, circuitBreakerOpen, circuitBreakerHalfOpen)
+	}
+	// half open, only the first halfOpenRequests requests are allowed to probe the cluster
+	if cb.saturated() {
+		cb.open(circuitBreakerHalfOpen)
+		return false, false
+	}
+	if atomic.AddInt64(&cb.probes, 1) > cb.halfOpenRequests {
+		return false, false
+	}
+	cb.admit()
+	return true, true
+}
+
+func (cb *circuitBreaker) Ready() {
+	atomic.AddInt64(&cb.pending, -1)
+}
+
+func (cb *circuitBreaker) Release(pending, probe, success bool) {
+	atomic.AddInt64(&cb.requests, -1)
+	if pending {
+		atomic.AddInt64(&cb.pending, -1)
+	}
+	if !probe || atomic.LoadUint32(&cb.state) != circuitBreakerHalfOpen {
+		return
+	}
+	if !success {
+		cb.open(circuitBreakerHalfOpen)
+		return
+	}
+	// closes if all the probes succeed
+	if atomic.AddInt64(&cb.probeSuccesses, 1) >= cb.halfOpenRequests {
+		atomic.CompareAndSwapUint32(&cb.state, circuitBreakerHalfOpen, circuitBreakerClosed)
+	}
+}
+
+func (cb *circuitBreaker) admit() {
+	atomic.AddInt64(&cb.requests, 1)
+	atomic.AddInt64(&cb.pending, 1)
+}
+
+// open opens the circuit breaker, the probes are reset for the next half open state
+func (cb *circuitBreaker) open(from uint32) {
+	atomic.StoreInt64(&cb.openTime, time.Now().UnixNano())
+	if atomic.CompareAndSwapUint32(&cb.state, from, circuitBreakerOpen) {
+		atomic.StoreInt64(&cb.probes, 0)
+		atomic.StoreInt64(&cb.probeSuccesses, 0)
+	}
+}
+
+// saturated returns true if any of the thresholds is exceeded, zero means no threshold
+func (cb *circuitBreaker) saturated() bool {
+	return exceeded(cb.stats.UpstreamConnectionActive.Count(), cb.maxConnections) ||
+		exceeded(atomic.LoadInt64(&cb.pending), cb.maxPendingRequests) ||
+		exceeded(atomic.LoadInt64(&cb.requests), cb.maxRequests)
+}
+
+func exceeded(cur, max int64) bool {
+	return max > 0 && cur >= max
+}
@@ -0,0 +1,212 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestNewCircuitBreakers(t *testing.T) {
+	info := NewClusterInfo(v2.Cluster{
+		Name: "test_circuit_breakers",
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{
+				{
+					MaxRequests: 10,
+					Cooldown:    api.DurationConfig{Duration: time.Second},
+				},
+				{
+					Priority:    string(types.HighPriority),
+					MaxRequests: 100,
+				},
+			},
+		},
+	})
+	cbm, ok := info.(types.CircuitBreakerManager)
+	if !ok {
+		t.Fatal("cluster info should implement circuit breaker manager")
+	}
+	if cbm.CircuitBreaker(types.DefaultPriority) == nil {
+		t.Fatal("expected a circuit breaker of the default priority")
+	}
+	// no cooldown, no circuit breaker
+	if cbm.CircuitBreaker(types.HighPriority) != nil {
+		t.Fatal("expected no circuit breaker of the high priority")
+	}
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	stats := newClusterStats("test_circuit_breaker_states")
+	cooldown := 100 * time.Millisecond
+	cb := newCircuitBreakers(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{
+				MaxPendingRequests: 1,
+				MaxRequests:        2,
+				Cooldown:           api.DurationConfig{Duration: cooldown},
+				HalfOpenRequests:   2,
+			},
+		},
+	}, stats)[types.DefaultPriority]
+
+	// closed
+	if allowed, probe := cb.Allow(); !allowed || probe || cb.state != circuitBreakerClosed {
+		t.Fatal("closed circuit breaker should allow the request")
+	}
+	// the pending requests exceed the threshold, opens
+	if allowed, _ := cb.Allow(); allowed || cb.state != circuitBreakerOpen {
+		t.Fatal("saturated circuit breaker should open")
+	}
+	// stays open in the cooldown even if the cluster is not saturated
+	cb.Release(true, false, true)
+	if allowed, _ := cb.Allow(); allowed || cb.state != circuitBreakerOpen {
+		t.Fatal("open circuit breaker should reject the request in cooldown")
+	}
+	// half opens after the cooldown to let the probes through
+	time.Sleep(cooldown)
+	if allowed, probe := cb.Allow(); !allowed || !probe || cb.state != circuitBreakerHalfOpen {
+		t.Fatal("circuit breaker should half open after cooldown")
+	}
+	cb.Ready()
+	if allowed, probe := cb.Allow(); !allowed || !probe {
+		t.Fatal("half open circuit breaker should allow the second probe")
+	}
+	cb.Ready()
+	// no more probes are allowed
+	cb.Release(false, true, true)
+	if allowed, _ := cb.Allow(); allowed || cb.state != circuitBreakerHalfOpen {
+		t.Fatal("half open circuit breaker should reject the requests beyond the probes")
+	}
+	// a failed probe opens it again
+	cb.Release(false, true, false)
+	if cb.state != circuitBreakerOpen {
+		t.Fatal("failed probe should open the circuit breaker")
+	}
+	time.Sleep(cooldown)
+	for i := 0; i < 2; i++ {
+		if allowed, probe := cb.Allow(); !allowed || !probe {
+			t.Fatal("circuit breaker should half open after cooldown")
+		}
+		cb.Ready()
+	}
+	cb.Release(false, true, true)
+	if cb.state != circuitBreakerHalfOpen {
+		t.Fatal("circuit breaker should close after all the probes succeed")
+	}
+	cb.Release(false, true, true)
+	if cb.state != circuitBreakerClosed {
+		t.Fatal("circuit breaker should close after all the probes succeed")
+	}
+	if allowed, probe := cb.Allow(); !allowed || probe {
+		t.Fatal("closed circuit breaker should allow the request")
+	}
+}
+
+func TestCircuitBreakerPriorityStats(t *testing.T) {
+	stats := newClusterStats("test_circuit_breaker_priority_stats")
+	breakers := newCircuitBreakers(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{
+				MaxRequests: 1,
+				Cooldown:    api.DurationConfig{Duration: time.Second},
+			},
+			{
+				Priority:    string(types.HighPriority),
+				MaxRequests: 1,
+				Cooldown:    api.DurationConfig{Duration: time.Second},
+			},
+		},
+	}, stats)
+	if allowed, _ := breakers[types.DefaultPriority].Allow(); !allowed {
+		t.Fatal("default priority should allow the request")
+	}
+	// the requests of the default priority do not saturate the high priority
+	if allowed, _ := breakers[types.HighPriority].Allow(); !allowed {
+		t.Fatal("high priority should allow the request")
+	}
+	if allowed, _ := breakers[types.DefaultPriority].Allow(); allowed {
+		t.Fatal("default priority should be saturated")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	rm := NewResourceManager(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{
+				MaxRetries: 1,
+				RetryBudget: &v2.RetryBudget{
+					BudgetPercent:       25,
+					MinRetryConcurrency: 2,
+				},
+			},
+		},
+	})
+	retries := rm.Retries()
+	// no active requests, the min retry concurrency is allowed
+	for i := 0; i < 2; i++ {
+		if !retries.CanCreate() {
+			t.Fatalf("#%d retry should be allowed by the min retry concurrency", i)
+		}
+		retries.Increase()
+	}
+	if retries.CanCreate() {
+		t.Fatal("retry should be throttled by the retry budget")
+	}
+	// 20 active requests, 25% of them can be retries
+	for i := 0; i < 20; i++ {
+		rm.Requests().Increase()
+	}
+	if retries.Max() != 5 {
+		t.Fatalf("expected retry budget 5, but got %d", retries.Max())
+	}
+	for i := 2; i < 5; i++ {
+		if !retries.CanCreate() {
+			t.Fatalf("#%d retry should be allowed by the retry budget", i)
+		}
+		retries.Increase()
+	}
+	if retries.CanCreate() {
+		t.Fatal("retry should be throttled by the retry budget")
+	}
+	// the active requests decrease, the budget shrinks
+	for i := 0; i < 10; i++ {
+		rm.Requests().Decrease()
+	}
+	retries.Decrease()
+	if retries.CanCreate() {
+		t.Fatal("retry should be throttled by the shrunk retry budget")
+	}
+	// update the budget, the active retries are kept
+	updateResourceValue(rm, NewResourceManager(v2.CircuitBreakers{
+		Thresholds: []v2.Thresholds{
+			{
+				RetryBudget: &v2.RetryBudget{
+					BudgetPercent: 100,
+				},
+			},
+		},
+	}))
+	if rm.Retries().Cur() != 4 || !rm.Retries().CanCreate() {
+		t.Fatalf("unexpected retries after update, current: %d, max: %d", rm.Retries().Cur(), rm.Retries().Max())
+	}
+}
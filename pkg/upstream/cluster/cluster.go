@@ -57,17 +57,31 @@ func NewCluster(clusterConfig v2.Cluster) types.Cluster {
 
 func NewClusterInfo(clusterConfig v2.Cluster) types.ClusterInfo {
 	info := &clusterInfo{
-		name:                 clusterConfig.Name,
-		clusterType:          clusterConfig.ClusterType,
-		subType:              clusterConfig.SubType,
-		maxRequestsPerConn:   clusterConfig.MaxRequestPerConn,
-		connBufferLimitBytes: clusterConfig.ConnBufferLimitBytes,
-		stats:                newClusterStats(clusterConfig.Name),
-		lbSubsetInfo:         NewLBSubsetInfo(&clusterConfig.LBSubSetConfig), // new subset load balancer info
-		lbOriDstInfo:         NewLBOriDstInfo(&clusterConfig.LBOriDstConfig), // new oridst load balancer info
-		lbType:               types.LoadBalancerType(clusterConfig.LbType),
-		resourceManager:      NewResourceManager(clusterConfig.CirBreThresholds),
-		clusterManagerTLS:    clusterConfig.ClusterManagerTLS,
+		name:                    clusterConfig.Name,
+		clusterType:             clusterConfig.ClusterType,
+		subType:                 clusterConfig.SubType,
+		maxRequestsPerConn:      clusterConfig.MaxRequestPerConn,
+		connBufferLimitBytes:    clusterConfig.ConnBufferLimitBytes,
+		stats:                   newClusterStats(clusterConfig.Name),
+		lbSubsetInfo:            NewLBSubsetInfo(&clusterConfig.LBSubSetConfig), // new subset load balancer info
+		lbOriDstInfo:            NewLBOriDstInfo(&clusterConfig.LBOriDstConfig), // new oridst load balancer info
+		lbType:                  types.LoadBalancerType(clusterConfig.LbType),
+		lbConfig:                clusterConfig.LbConfig,
+		resourceManager:         NewResourceManager(clusterConfig.CirBreThresholds),
+		clusterManagerTLS:       clusterConfig.ClusterManagerTLS,
+		http2Upgrade:            clusterConfig.HTTP2Upgrade,
+		keepAlive:               clusterConfig.KeepAlive,
+		stickySession:           clusterConfig.StickySession,
+		requestHeadersToAdd:     clusterConfig.RequestHeadersToAdd,
+		requestHeadersToRemove:  clusterConfig.RequestHeadersToRemove,
+		responseHeadersToAdd:    clusterConfig.ResponseHeadersToAdd,
+		responseHeadersToRemove: clusterConfig.ResponseHeadersToRemove,
+		sendProxyProtocol:       clusterConfig.SendProxyProtocol,
+		slowStart:               clusterConfig.SlowStart,
+		localityLbConfig:        clusterConfig.LocalityLbConfig,
+		maxConnectionsPerHost:   clusterConfig.MaxConnectionsPerHost,
+		http2ConnPool:           clusterConfig.Http2ConnPool,
+		connPoolPrefetch:        clusterConfig.ConnPoolPrefetch,
 	}
 	// set ConnectTimeout
 	if clusterConfig.ConnectTimeout != nil {
@@ -81,6 +95,22 @@ func NewClusterInfo(clusterConfig v2.Cluster) types.ClusterInfo {
 		info.idleTimeout = clusterConfig.IdleTimeout.Duration
 	}
 
+	if clusterConfig.UpstreamIdleTimeout != nil {
+		info.upstreamIdleTimeout = clusterConfig.UpstreamIdleTimeout.Duration
+	}
+
+	// set RequestTimeout
+	if clusterConfig.RequestTimeout != nil {
+		info.requestTimeout = clusterConfig.RequestTimeout.Duration
+	}
+
+	// set DrainTimeout
+	if clusterConfig.DrainTimeout != nil {
+		info.drainTimeout = clusterConfig.DrainTimeout.Duration
+	} else {
+		info.drainTimeout = defaultDrainTimeout
+	}
+
 	// tls mng
 	if !info.clusterManagerTLS {
 		mgr, err := mtls.NewTLSClientContextManager(clusterConfig.Name, &clusterConfig.TLS)
@@ -89,6 +119,28 @@ func NewClusterInfo(clusterConfig v2.Cluster) types.ClusterInfo {
 		}
 		info.tlsMng = mgr
 	}
+
+	// outlier detection
+	if clusterConfig.OutlierDetection != nil {
+		info.outlierDetector = newOutlierDetector(clusterConfig.OutlierDetection)
+	}
+
+	// adaptive concurrency
+	info.concurrencyLimiter = newAdaptiveConcurrencyLimiter(clusterConfig.AdaptiveConcurrency)
+
+	// priority admission
+	info.admissionQueue = newAdmissionQueue(clusterConfig.AdmissionQueue)
+
+	// proxy protocol
+	switch info.sendProxyProtocol {
+	case "", v2.ProxyProtocolV1, v2.ProxyProtocolV2:
+	default:
+		log.DefaultLogger.Alertf("cluster.config", "[upstream] [cluster] [new cluster] unsupported proxy protocol version %s, the header is not sent", info.sendProxyProtocol)
+		info.sendProxyProtocol = ""
+	}
+
+	info.circuitBreakers = newCircuitBreakers(clusterConfig.CirBreThresholds, info.stats)
+	info.SetMaintenanceMode(clusterConfig.MaintenanceMode)
 	return info
 }
 
@@ -97,9 +149,11 @@ type simpleCluster struct {
 	info          types.ClusterInfo
 	mutex         sync.Mutex
 	healthChecker types.HealthChecker
-	lbInstance    types.LoadBalancer // load balancer used for this cluster
-	hostSet       types.HostSet
-	snapshot      atomic.Value
+	// outlierDetector ejects the hosts by the results of the real requests
+	outlierDetector *outlierDetector
+	lbInstance      types.LoadBalancer // load balancer used for this cluster
+	hostSet         types.HostSet
+	snapshot        atomic.Value
 }
 
 func newSimpleCluster(clusterConfig v2.Cluster) types.Cluster {
@@ -116,7 +170,7 @@ func newSimpleCluster(clusterConfig v2.Cluster) types.Cluster {
 	cluster.snapshot.Store(&clusterSnapshot{
 		info:    info,
 		hostSet: hostSet,
-		lb:      NewLoadBalancer(info, hostSet),
+		lb:      newStickySessionLoadBalancer(info, hostSet, NewLoadBalancer(info, hostSet)),
 	})
 	if clusterConfig.HealthCheck.ServiceName != "" {
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
@@ -124,11 +178,21 @@ func newSimpleCluster(clusterConfig v2.Cluster) types.Cluster {
 		}
 		cluster.healthChecker = healthcheck.CreateHealthCheck(clusterConfig.HealthCheck)
 	}
+	if ci, ok := info.(*clusterInfo); ok && ci.outlierDetector != nil {
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[upstream] [cluster] [new cluster] cluster %s have outlier detection", clusterConfig.Name)
+		}
+		cluster.outlierDetector = ci.outlierDetector
+		cluster.outlierDetector.Start()
+	}
 	return cluster
 }
 
 func (sc *simpleCluster) UpdateHosts(hostSet types.HostSet) {
 	info := sc.info
+	if snap := sc.Snapshot(); snap != nil {
+		inheritHealthyTime(snap.HostSet(), hostSet)
+	}
 	// load balance
 	var lb types.LoadBalancer
 	if info.LbSubsetInfo().IsEnabled() {
@@ -139,7 +203,9 @@ func (sc *simpleCluster) UpdateHosts(hostSet types.HostSet) {
 		}
 	} else {
 		lb = NewLoadBalancer(info, hostSet)
+		lb = newLocalityLoadBalancer(info, hostSet, lb)
 	}
+	lb = newStickySessionLoadBalancer(info, hostSet, lb)
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
 	sc.lbInstance = lb
@@ -152,6 +218,9 @@ func (sc *simpleCluster) UpdateHosts(hostSet types.HostSet) {
 	if sc.healthChecker != nil {
 		sc.healthChecker.SetHealthCheckerHostSet(hostSet)
 	}
+	if sc.outlierDetector != nil {
+		sc.outlierDetector.SetHostSet(hostSet)
+	}
 }
 
 func (sc *simpleCluster) Snapshot() types.ClusterSnapshot {
@@ -176,24 +245,48 @@ func (sc *simpleCluster) StopHealthChecking() {
 	if sc.healthChecker != nil {
 		sc.healthChecker.Stop()
 	}
+	if sc.outlierDetector != nil {
+		sc.outlierDetector.Stop()
+	}
 }
 
 type clusterInfo struct {
-	name                 string
-	clusterType          v2.ClusterType
-	subType              string
-	lbType               types.LoadBalancerType // if use subset lb , lbType is used as inner LB algorithm for choosing subset's host
-	connBufferLimitBytes uint32
-	maxRequestsPerConn   uint32
-	resourceManager      types.ResourceManager
-	stats                types.ClusterStats
-	lbSubsetInfo         types.LBSubsetInfo
-	lbOriDstInfo         types.LBOriDstInfo
-	clusterManagerTLS    bool
-	tlsMng               types.TLSClientContextManager
-	connectTimeout       time.Duration
-	idleTimeout          time.Duration
-	lbConfig             v2.IsCluster_LbConfig
+	name                    string
+	clusterType             v2.ClusterType
+	subType                 string
+	lbType                  types.LoadBalancerType // if use subset lb , lbType is used as inner LB algorithm for choosing subset's host
+	connBufferLimitBytes    uint32
+	maxRequestsPerConn      uint32
+	resourceManager         types.ResourceManager
+	stats                   types.ClusterStats
+	lbSubsetInfo            types.LBSubsetInfo
+	lbOriDstInfo            types.LBOriDstInfo
+	clusterManagerTLS       bool
+	tlsMng                  types.TLSClientContextManager
+	connectTimeout          time.Duration
+	idleTimeout             time.Duration
+	drainTimeout            time.Duration
+	http2Upgrade            bool
+	lbConfig                v2.IsCluster_LbConfig
+	outlierDetector         *outlierDetector
+	circuitBreakers         map[types.RoutingPriority]*circuitBreaker
+	keepAlive               *v2.KeepAliveConfig
+	stickySession           *v2.StickySessionConfig
+	requestTimeout          time.Duration
+	concurrencyLimiter      *adaptiveConcurrencyLimiter
+	admissionQueue          *admissionQueue
+	requestHeadersToAdd     []*v2.HeaderValueOption
+	requestHeadersToRemove  []string
+	responseHeadersToAdd    []*v2.HeaderValueOption
+	responseHeadersToRemove []string
+	sendProxyProtocol       string
+	slowStart               *v2.SlowStartConfig
+	localityLbConfig        *v2.LocalityLbConfig
+	maxConnectionsPerHost   uint32
+	http2ConnPool           *v2.Http2ConnPoolConfig
+	maintenanceMode         uint32
+	upstreamIdleTimeout     time.Duration
+	connPoolPrefetch        *v2.ConnPoolPrefetchConfig
 }
 
 func (ci *clusterInfo) Name() string {
@@ -224,6 +317,24 @@ func (ci *clusterInfo) ResourceManager() types.ResourceManager {
 	return ci.resourceManager
 }
 
+// CircuitBreaker returns the circuit breaker of the priority, nil means no circuit breaker
+func (ci *clusterInfo) CircuitBreaker(priority types.RoutingPriority) types.CircuitBreaker {
+	if cb, ok := ci.circuitBreakers[priority]; ok {
+		return cb
+	}
+	return nil
+}
+
+// KeepAliveConfig returns the keepalive config of the upstream connections
+func (ci *clusterInfo) KeepAliveConfig() *v2.KeepAliveConfig {
+	return ci.keepAlive
+}
+
+// StickySessionConfig implements types.StickySessionCluster
+func (ci *clusterInfo) StickySessionConfig() *v2.StickySessionConfig {
+	return ci.stickySession
+}
+
 func (ci *clusterInfo) TLSMng() types.TLSClientContextManager {
 	if ci.clusterManagerTLS {
 		return clusterManagerInstance.GetTLSManager()
@@ -243,6 +354,11 @@ func (ci *clusterInfo) IdleTimeout() time.Duration {
 	return ci.idleTimeout
 }
 
+// RequestTimeout implements types.RequestTimeoutCluster
+func (ci *clusterInfo) RequestTimeout() time.Duration {
+	return ci.requestTimeout
+}
+
 func (ci *clusterInfo) LbOriDstInfo() types.LBOriDstInfo {
 	return ci.lbOriDstInfo
 }
@@ -251,6 +367,107 @@ func (ci *clusterInfo) LbConfig() v2.IsCluster_LbConfig {
 	return ci.lbConfig
 }
 
+// HTTP2Upgrade implements types.HTTP2UpgradeCluster
+func (ci *clusterInfo) HTTP2Upgrade() bool {
+	return ci.http2Upgrade
+}
+
+// OutlierDetector implements types.OutlierDetectorGetter
+func (ci *clusterInfo) OutlierDetector() types.OutlierDetector {
+	// avoid returning a typed nil
+	if ci.outlierDetector == nil {
+		return nil
+	}
+	return ci.outlierDetector
+}
+
+// ConcurrencyLimiter implements types.ConcurrencyLimiterGetter
+func (ci *clusterInfo) ConcurrencyLimiter() types.ConcurrencyLimiter {
+	// avoid returning a typed nil
+	if ci.concurrencyLimiter == nil {
+		return nil
+	}
+	return ci.concurrencyLimiter
+}
+
+// AdmissionQueue implements types.AdmissionQueueGetter
+func (ci *clusterInfo) AdmissionQueue() types.AdmissionQueue {
+	// avoid returning a typed nil
+	if ci.admissionQueue == nil {
+		return nil
+	}
+	return ci.admissionQueue
+}
+
+// RequestHeadersToAdd implements types.ClusterHeadersGetter
+func (ci *clusterInfo) RequestHeadersToAdd() []*v2.HeaderValueOption {
+	return ci.requestHeadersToAdd
+}
+
+// RequestHeadersToRemove implements types.ClusterHeadersGetter
+func (ci *clusterInfo) RequestHeadersToRemove() []string {
+	return ci.requestHeadersToRemove
+}
+
+// ResponseHeadersToAdd implements types.ClusterHeadersGetter
+func (ci *clusterInfo) ResponseHeadersToAdd() []*v2.HeaderValueOption {
+	return ci.responseHeadersToAdd
+}
+
+// ResponseHeadersToRemove implements types.ClusterHeadersGetter
+func (ci *clusterInfo) ResponseHeadersToRemove() []string {
+	return ci.responseHeadersToRemove
+}
+
+// SendProxyProtocol implements types.ProxyProtocolCluster
+func (ci *clusterInfo) SendProxyProtocol() string {
+	return ci.sendProxyProtocol
+}
+
+// SlowStart implements types.SlowStartCluster
+func (ci *clusterInfo) SlowStart() *v2.SlowStartConfig {
+	return ci.slowStart
+}
+
+// MaxConnectionsPerHost implements types.HostConnectionLimitCluster
+func (ci *clusterInfo) MaxConnectionsPerHost() uint32 {
+	return ci.maxConnectionsPerHost
+}
+
+// Http2ConnPool implements types.Http2ConnPoolCluster
+func (ci *clusterInfo) Http2ConnPool() *v2.Http2ConnPoolConfig {
+	return ci.http2ConnPool
+}
+
+// UpstreamIdleTimeout implements types.UpstreamIdleTimeoutCluster
+func (ci *clusterInfo) UpstreamIdleTimeout() time.Duration {
+	return ci.upstreamIdleTimeout
+}
+
+// ConnPoolPrefetch implements types.ConnPoolPrefetchCluster
+func (ci *clusterInfo) ConnPoolPrefetch() *v2.ConnPoolPrefetchConfig {
+	return ci.connPoolPrefetch
+}
+
+// MaintenanceMode implements types.MaintenanceModeCluster
+func (ci *clusterInfo) MaintenanceMode() bool {
+	return atomic.LoadUint32(&ci.maintenanceMode) == 1
+}
+
+// SetMaintenanceMode implements types.MaintenanceModeCluster
+func (ci *clusterInfo) SetMaintenanceMode(enable bool) {
+	var v uint32
+	if enable {
+		v = 1
+	}
+	atomic.StoreUint32(&ci.maintenanceMode, v)
+}
+
+// LocalityLbConfig implements types.LocalityLbCluster
+func (ci *clusterInfo) LocalityLbConfig() *v2.LocalityLbConfig {
+	return ci.localityLbConfig
+}
+
 func (ci *clusterInfo) SubType() string {
 	return ci.subType
 }
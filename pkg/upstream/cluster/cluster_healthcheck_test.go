@@ -334,3 +334,83 @@ func listHostSet(hs types.HostSet) []types.Host {
 	})
 	return ret
 }
+
+func TestClusterHealthCheckWarmUp(t *testing.T) {
+	old := newHealthCheckTestServer()
+	defer old.server.Close()
+	snew := newHealthCheckTestServer()
+	defer snew.server.Close()
+
+	cluster := NewCluster(v2.Cluster{
+		Name:        "warmup",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+		HealthCheck: v2.HealthCheck{
+			HealthCheckConfig: v2.HealthCheckConfig{
+				ServiceName:         "warmup",
+				HealthyThreshold:    3,
+				UnhealthyThreshold:  1,
+				InitialDelaySeconds: api.DurationConfig{Duration: 10 * time.Millisecond},
+				WarmUp:              true,
+			},
+			Interval: 100 * time.Millisecond,
+		},
+	})
+	defer cluster.StopHealthChecking()
+	// the number of checks when the new host becomes healthy
+	var checks, healthyAt int32
+	cluster.AddHealthCheckCallbacks(func(host types.Host, changed bool, isHealthy bool) {
+		if host.AddressString() != snew.hostConfig.Address {
+			return
+		}
+		n := atomic.AddInt32(&checks, 1)
+		if changed && isHealthy {
+			atomic.StoreInt32(&healthyAt, n)
+		}
+	})
+	info := cluster.Snapshot().ClusterInfo()
+	oldHost := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: old.hostConfig.Address}}, info)
+	cluster.UpdateHosts(NewHostSet([]types.Host{oldHost}))
+	if host := cluster.Snapshot().LoadBalancer().ChooseHost(nil); host != nil {
+		t.Fatalf("the pending host %s is chosen", host.AddressString())
+	}
+	for i := 0; i < 100 && !oldHost.Health(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !oldHost.Health() {
+		t.Fatal("the host does not pass the warm up")
+	}
+
+	// add a new host
+	newHost := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: snew.hostConfig.Address}}, info)
+	added := time.Now()
+	cluster.UpdateHosts(NewHostSet([]types.Host{oldHost, newHost}))
+	oldChosen := 0
+	newChosen := false
+	for i := 0; i < 200 && !newChosen; i++ {
+		host := cluster.Snapshot().LoadBalancer().ChooseHost(nil)
+		if host == nil {
+			t.Fatal("no host is chosen")
+		}
+		switch host.AddressString() {
+		case snew.hostConfig.Address:
+			newChosen = true
+		default:
+			oldChosen++
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !newChosen || oldChosen == 0 {
+		t.Fatalf("the new host receives traffic unexpected, new chosen: %v, old chosen: %d", newChosen, oldChosen)
+	}
+	for i := 0; i < 50 && atomic.LoadInt32(&healthyAt) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&healthyAt); n != 3 {
+		t.Errorf("the new host should become healthy after 3 checks, but got %d", n)
+	}
+	// the slow start begins after the warm up
+	if !newHost.(types.SlowStartHost).HealthyTime().After(added) {
+		t.Error("the healthy time is not reset after the warm up")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,6 +35,7 @@ import (
 	"mosn.io/mosn/pkg/mtls"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
 )
 
 var errNilCluster = errors.New("cannot update nil cluster")
@@ -66,6 +68,27 @@ type clusterManager struct {
 	tlsMetrics           *mtls.TLSStats
 	tlsMng               atomic.Value // store types.TLSClientContextManager
 	mux                  sync.Mutex
+	// downstreamConnPools records the connection pools bound to the downstream connections, guarded by mux
+	downstreamConnPools map[string][]downstreamConnPool
+	// serverNameConnPools records the keys of the connection pools of the different server names
+	// of each address, in the order they are created, guarded by mux
+	serverNameConnPools map[serverNameConnPoolsKey][]string
+}
+
+// maxServerNameConnPools limits the connection pools of the different server names of an address,
+// the oldest one is shut down if the limit is reached
+var maxServerNameConnPools = 64
+
+// serverNameConnPoolsKey represents the address in the connection pools of a protocol
+type serverNameConnPoolsKey struct {
+	pools *sync.Map
+	addr  string
+}
+
+// downstreamConnPool is a connection pool bound to a downstream connection
+type downstreamConnPool struct {
+	pools *sync.Map
+	key   string
 }
 
 type clusterManagerSingleton struct {
@@ -319,13 +342,235 @@ func (cm *clusterManager) UpdateHosts(clusterName string, hostConfigs []v2.Host,
 		return fmt.Errorf("cluster %s is not exists", clusterName)
 	}
 	c := ci.(types.Cluster)
+	oldHostSet := c.Snapshot().HostSet()
 	if hostHandler != nil {
 		hostHandler(c, hostConfigs)
 	}
 	refreshHostsConfig(c)
+	cm.drainRemovedHosts(c, oldHostSet)
+	return nil
+}
+
+// drainRemovedHosts drains the hosts in the old host set but not in the cluster's current host set
+func (cm *clusterManager) drainRemovedHosts(c types.Cluster, oldHostSet types.HostSet) {
+	newHosts := make(map[string]struct{}, c.Snapshot().HostSet().Size())
+	c.Snapshot().HostSet().Range(func(host types.Host) bool {
+		// the host reported as draining by the control plane is drained too
+		if !types.IsHostDraining(host) {
+			newHosts[host.AddressString()] = struct{}{}
+		}
+		return true
+	})
+	oldHostSet.Range(func(host types.Host) bool {
+		if _, ok := newHosts[host.AddressString()]; !ok {
+			cm.drainHost(c, host)
+		}
+		return true
+	})
+}
+
+// DrainHost marks the host as draining, and closes its connection pools after
+// the in-flight requests are finished or the cluster's drain timeout is reached
+func (cm *clusterManager) DrainHost(clusterName string, addr string) error {
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		log.DefaultLogger.Errorf("[upstream] [cluster manager] cluster %s is not found", clusterName)
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	c := ci.(types.Cluster)
+	var target types.Host
+	c.Snapshot().HostSet().Range(func(host types.Host) bool {
+		if host.AddressString() == addr {
+			target = host
+			return false
+		}
+		return true
+	})
+	if target == nil {
+		return fmt.Errorf("host %s is not exists in cluster %s", addr, clusterName)
+	}
+	cm.drainHost(c, target)
 	return nil
 }
 
+const defaultDrainTimeout = 30 * time.Second
+
+// drainCheckInterval is the interval to check whether the draining host's requests are finished
+var drainCheckInterval = 100 * time.Millisecond
+
+func (cm *clusterManager) drainHost(c types.Cluster, host types.Host) {
+	dh, ok := host.(types.DrainableHost)
+	if !ok || dh.Draining() {
+		return
+	}
+	dh.SetDraining(true)
+	timeout := defaultDrainTimeout
+	if info, ok := c.Snapshot().ClusterInfo().(*clusterInfo); ok {
+		timeout = info.drainTimeout
+	}
+	clusterName := c.Snapshot().ClusterInfo().Name()
+	addr := host.AddressString()
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[upstream] [cluster manager] cluster %s host %s is draining, timeout: %s", clusterName, addr, timeout)
+	}
+	utils.GoWithRecover(func() {
+		deadline := time.Now().Add(timeout)
+		for host.HostStats().UpstreamRequestActive.Count() > 0 && time.Now().Before(deadline) {
+			time.Sleep(drainCheckInterval)
+		}
+		// the address is added back during draining, keeps the connection pools
+		inUse := false
+		c.Snapshot().HostSet().Range(func(h types.Host) bool {
+			if h.AddressString() == addr && !types.IsHostDraining(h) {
+				inUse = true
+				return false
+			}
+			return true
+		})
+		if inUse {
+			return
+		}
+		cm.closeConnectionPool(addr)
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[upstream] [cluster manager] cluster %s host %s is drained, active requests: %d",
+				clusterName, addr, host.HostStats().UpstreamRequestActive.Count())
+		}
+	}, nil)
+}
+
+// closeConnectionPool closes the addr's connection pools of all protocols
+func (cm *clusterManager) closeConnectionPool(addr string) {
+	cm.protocolConnPool.Range(func(_, value interface{}) bool {
+		cm.deleteServerNameConnPools(value.(*sync.Map), addr)
+		for _, pool := range deleteConnPools(value.(*sync.Map), addr) {
+			pool.Close()
+		}
+		return true
+	})
+}
+
+// connPoolKeySeparator separates the address and the server name in the key of the connection pool
+const connPoolKeySeparator = "#"
+
+// connPoolKey returns the key of the host's connection pool, the connections of different
+// tls server names chosen by the request are not shared, neither are the connections which
+// send the proxy protocol header of different downstream connections.
+func connPoolKey(ctx context.Context, host types.Host) string {
+	key := host.AddressString()
+	if serverName := connPoolServerName(ctx, host); serverName != "" {
+		key += connPoolKeySeparator + serverName
+	}
+	if downstream := proxyProtocolDownstream(ctx, host.ClusterInfo()); downstream != "" {
+		key += connPoolKeySeparator + downstream
+	}
+	return key
+}
+
+// connPoolServerName returns the server name chosen by the request if the host supports tls
+func connPoolServerName(ctx context.Context, host types.Host) string {
+	if !host.SupportTLS() {
+		return ""
+	}
+	return upstreamServerName(ctx, host.ClusterInfo().TLSMng())
+}
+
+// addServerNameConnPool records the connection pool of the server name, and shuts down the oldest one
+// if the pools of the address reach the limit, the caller should hold the mux.
+func (cm *clusterManager) addServerNameConnPool(pools *sync.Map, addr string, key string) {
+	if cm.serverNameConnPools == nil {
+		cm.serverNameConnPools = make(map[serverNameConnPoolsKey][]string)
+	}
+	k := serverNameConnPoolsKey{pools: pools, addr: addr}
+	keys := append(cm.serverNameConnPools[k], key)
+	for len(keys) > maxServerNameConnPools {
+		oldest := keys[0]
+		keys = keys[1:]
+		if pool, ok := pools.Load(oldest); ok {
+			pools.Delete(oldest)
+			// the streams in use are not interrupted
+			pool.(types.ConnectionPool).Shutdown()
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[upstream] [cluster manager] connection pool %s is shutdown, the server names of %s reach the limit %d",
+					oldest, addr, maxServerNameConnPools)
+			}
+		}
+	}
+	cm.serverNameConnPools[k] = keys
+}
+
+// deleteServerNameConnPools forgets the connection pools of the server names of the address
+func (cm *clusterManager) deleteServerNameConnPools(pools *sync.Map, addr string) {
+	cm.mux.Lock()
+	defer cm.mux.Unlock()
+	delete(cm.serverNameConnPools, serverNameConnPoolsKey{pools: pools, addr: addr})
+}
+
+// downstreamConnKey returns the key of the downstream connection
+func downstreamConnKey(remoteAddr, localAddr string) string {
+	return remoteAddr + ">" + localAddr
+}
+
+// proxyProtocolDownstream returns the key of the downstream connection in the context
+// if the cluster sends the proxy protocol header, otherwise returns empty.
+func proxyProtocolDownstream(ctx context.Context, info types.ClusterInfo) string {
+	pc, ok := info.(types.ProxyProtocolCluster)
+	if !ok || pc.SendProxyProtocol() == "" || ctx == nil {
+		return ""
+	}
+	src, err := types.GetConnectionVariable(ctx, types.VarDownstreamRemoteAddress)
+	if err != nil || src == "" {
+		return ""
+	}
+	dst, _ := types.GetConnectionVariable(ctx, types.VarDownstreamLocalAddress)
+	return downstreamConnKey(src, dst)
+}
+
+// bindDownstreamConnPool records the connection pool bound to the downstream connection,
+// the caller should hold the mux.
+func (cm *clusterManager) bindDownstreamConnPool(downstream string, pools *sync.Map, key string) {
+	if cm.downstreamConnPools == nil {
+		cm.downstreamConnPools = make(map[string][]downstreamConnPool)
+	}
+	cm.downstreamConnPools[downstream] = append(cm.downstreamConnPools[downstream], downstreamConnPool{
+		pools: pools,
+		key:   key,
+	})
+}
+
+// ReleaseDownstreamConnPools closes the connection pools bound to the closed downstream connection
+func (cm *clusterManager) ReleaseDownstreamConnPools(remoteAddr, localAddr string) {
+	var closed []types.ConnectionPool
+	func() {
+		cm.mux.Lock()
+		defer cm.mux.Unlock()
+		downstream := downstreamConnKey(remoteAddr, localAddr)
+		for _, bound := range cm.downstreamConnPools[downstream] {
+			if pool, ok := bound.pools.Load(bound.key); ok {
+				bound.pools.Delete(bound.key)
+				closed = append(closed, pool.(types.ConnectionPool))
+			}
+		}
+		delete(cm.downstreamConnPools, downstream)
+	}()
+	// the pools are only used by the closed downstream connection
+	for _, pool := range closed {
+		pool.Close()
+	}
+}
+
+// deleteConnPools deletes the addr's connection pools of all server names and returns them
+func deleteConnPools(connectionPool *sync.Map, addr string) []types.ConnectionPool {
+	var pools []types.ConnectionPool
+	connectionPool.Range(func(key, value interface{}) bool {
+		if k := key.(string); k == addr || strings.HasPrefix(k, addr+connPoolKeySeparator) {
+			connectionPool.Delete(key)
+			pools = append(pools, value.(types.ConnectionPool))
+		}
+		return true
+	})
+	return pools
+}
+
 // GetClusterSnapshot returns cluster snap
 func (cm *clusterManager) GetClusterSnapshot(ctx context.Context, clusterName string) types.ClusterSnapshot {
 	ci, ok := cm.clustersMap.Load(clusterName)
@@ -441,11 +686,21 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 	if try > maxHostsCounts {
 		try = maxHostsCounts
 	}
+	chosen := 0
 	for i := 0; i < try; i++ {
 		host := clusterSnapshot.LoadBalancer().ChooseHost(balancerContext)
 		if host == nil {
 			return nil, nil, errNilHostChoose
 		}
+		// draining host does not accept new requests
+		if types.IsHostDraining(host) {
+			continue
+		}
+		// fail over to another host if the connections to the host reach the limit,
+		// the last chosen host is still used, its idle connections may serve the request
+		if types.IsHostConnectionOverflow(host) && i < try-1 {
+			continue
+		}
 
 		addr := host.AddressString()
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
@@ -457,21 +712,27 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 		}
 
 		connectionPool := value.(*sync.Map)
+		key := connPoolKey(balancerContext.DownstreamContext(), host)
 		// we cannot use sync.Map.LoadOrStore directly, because we do not want to new a connpool every time
 		loadOrStoreConnPool := func() (types.ConnectionPool, bool) {
 			// avoid locking if it is already exists
-			if connPool, ok := connectionPool.Load(addr); ok {
+			if connPool, ok := connectionPool.Load(key); ok {
 				pool := connPool.(types.ConnectionPool)
 				return pool, true
 			}
 			cm.mux.Lock()
 			defer cm.mux.Unlock()
-			if connPool, ok := connectionPool.Load(addr); ok {
+			if connPool, ok := connectionPool.Load(key); ok {
 				pool := connPool.(types.ConnectionPool)
 				return pool, true
 			}
 			pool := factory(balancerContext.DownstreamContext(), host)
-			connectionPool.Store(addr, pool)
+			connectionPool.Store(key, pool)
+			if downstream := proxyProtocolDownstream(balancerContext.DownstreamContext(), host.ClusterInfo()); downstream != "" {
+				cm.bindDownstreamConnPool(downstream, connectionPool, key)
+			} else if connPoolServerName(balancerContext.DownstreamContext(), host) != "" {
+				cm.addServerNameConnPool(connectionPool, addr, key)
+			}
 			return pool, false
 		}
 		pool, loaded := loadOrStoreConnPool()
@@ -485,15 +746,15 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 					cm.mux.Lock()
 					defer cm.mux.Unlock()
 					// recheck whether the pool is changed
-					if connPool, ok := connectionPool.Load(addr); ok {
+					if connPool, ok := connectionPool.Load(key); ok {
 						pool = connPool.(types.ConnectionPool)
 						if pool.TLSHashValue().Equal(host.TLSHashValue()) {
 							return
 						}
-						connectionPool.Delete(addr)
+						connectionPool.Delete(key)
 						pool.Shutdown()
 						pool = factory(balancerContext.DownstreamContext(), host)
-						connectionPool.Store(addr, pool)
+						connectionPool.Store(key, pool)
 						cm.tlsMetrics.TLSConnpoolChanged.Inc(1)
 					}
 				}()
@@ -505,6 +766,12 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 		}
 		pools[i] = pool
 		hosts[i] = host
+		chosen++
+	}
+
+	// all of the chosen hosts are draining
+	if chosen == 0 {
+		return nil, nil, errNoHealthyHost
 	}
 
 	// perhaps the first request, wait for tcp handshaking.
@@ -525,10 +792,8 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 
 func (cm *clusterManager) ShutdownConnectionPool(proto types.ProtocolName, addr string) {
 	shutdown := func(value interface{}) {
-		connectionPool := value.(*sync.Map)
-		if connPool, ok := connectionPool.Load(addr); ok {
-			pool := connPool.(types.ConnectionPool)
-			connectionPool.Delete(addr)
+		cm.deleteServerNameConnPools(value.(*sync.Map), addr)
+		for _, pool := range deleteConnPools(value.(*sync.Map), addr) {
 			pool.Shutdown()
 			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 				log.DefaultLogger.Debugf("[upstream] [cluster manager] protocol %s address %s connections shutdown", proto, addr)
@@ -19,10 +19,15 @@ package cluster
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
 )
 
 func TestClusterUpdateAndHosts(t *testing.T) {
@@ -69,3 +74,190 @@ func TestClusterUpdateAndHosts(t *testing.T) {
 	require.Equal(t, uint64(20), snap2.ClusterInfo().ResourceManager().Connections().Max())
 
 }
+
+func createDrainClusterManager(t *testing.T, drainTimeout time.Duration) (types.Host, types.Host) {
+	clusterManagerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:         "drain_test",
+			LbType:       v2.LB_ROUNDROBIN,
+			DrainTimeout: &api.DurationConfig{Duration: drainTimeout},
+		},
+	}, map[string][]v2.Host{
+		"drain_test": {
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10100"}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10101"}},
+		},
+	}, nil)
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "drain_test")
+	require.Equal(t, 2, snap.HostSet().Size())
+	return snap.HostSet().Get(0), snap.HostSet().Get(1)
+}
+
+func loadMockConnPool(addr string) *mockConnPool {
+	value, ok := clusterManagerInstance.protocolConnPool.Load(mockProtocol)
+	if !ok {
+		return nil
+	}
+	if pool, ok := value.(*sync.Map).Load(addr); ok {
+		return pool.(*mockConnPool)
+	}
+	return nil
+}
+
+func TestDrainHost(t *testing.T) {
+	drainHost, otherHost := createDrainClusterManager(t, time.Minute)
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "drain_test")
+	// create connection pools for all hosts
+	for i := 0; i < 2; i++ {
+		_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+		require.NotNil(t, host)
+	}
+	pool := loadMockConnPool(drainHost.AddressString())
+	require.NotNil(t, pool)
+	// an in-flight request on the draining host
+	drainHost.HostStats().UpstreamRequestActive.Inc(1)
+	require.Nil(t, clusterManagerInstance.DrainHost("drain_test", drainHost.AddressString()))
+	require.True(t, types.IsHostDraining(drainHost))
+	// new requests avoid the draining host
+	for i := 0; i < 10; i++ {
+		_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+		require.Equal(t, otherHost.AddressString(), host.AddressString())
+	}
+	// the in-flight request is not interrupted
+	time.Sleep(3 * drainCheckInterval)
+	require.Equal(t, uint32(0), atomic.LoadUint32(&pool.closed))
+	require.Equal(t, pool, loadMockConnPool(drainHost.AddressString()))
+	// the connection pool is closed after the in-flight request finished
+	drainHost.HostStats().UpstreamRequestActive.Dec(1)
+	time.Sleep(3 * drainCheckInterval)
+	require.Equal(t, uint32(1), atomic.LoadUint32(&pool.closed))
+	require.Nil(t, loadMockConnPool(drainHost.AddressString()))
+	// unknown host
+	require.NotNil(t, clusterManagerInstance.DrainHost("drain_test", "127.0.0.1:10102"))
+	require.NotNil(t, clusterManagerInstance.DrainHost("unknown", drainHost.AddressString()))
+}
+
+func TestDrainRemovedHostTimeout(t *testing.T) {
+	removedHost, otherHost := createDrainClusterManager(t, 5*drainCheckInterval)
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "drain_test")
+	for i := 0; i < 2; i++ {
+		clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+	}
+	pool := loadMockConnPool(removedHost.AddressString())
+	require.NotNil(t, pool)
+	removedHost.HostStats().UpstreamRequestActive.Inc(1)
+	defer removedHost.HostStats().UpstreamRequestActive.Dec(1)
+	// eds update removes the host
+	require.Nil(t, clusterManagerInstance.UpdateClusterHosts("drain_test", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: otherHost.AddressString()}},
+	}))
+	require.True(t, types.IsHostDraining(removedHost))
+	// requests with the old snapshot avoid the removed host too
+	for i := 0; i < 10; i++ {
+		_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+		require.Equal(t, otherHost.AddressString(), host.AddressString())
+	}
+	time.Sleep(2 * drainCheckInterval)
+	require.Equal(t, uint32(0), atomic.LoadUint32(&pool.closed))
+	// the connection pool is closed when the drain timeout reached, even if the request is not finished
+	time.Sleep(5 * drainCheckInterval)
+	require.Equal(t, uint32(1), atomic.LoadUint32(&pool.closed))
+	require.Nil(t, loadMockConnPool(removedHost.AddressString()))
+	// the kept host is not drained
+	require.False(t, types.IsHostDraining(otherHost))
+	require.NotNil(t, loadMockConnPool(otherHost.AddressString()))
+}
+
+func TestEdsHostHealthStatus(t *testing.T) {
+	clusterManagerInstance.Destroy() // Destroy for test
+	addrs := []string{"127.0.0.1:10110", "127.0.0.1:10111", "127.0.0.1:10112", "127.0.0.1:10113"}
+	defer func() {
+		for _, addr := range addrs {
+			ClearHealthFlag(GetHealthFlagPointer(addr), FAILED_EDS_HEALTH|api.FAILED_ACTIVE_HC)
+		}
+	}()
+	NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:        "eds_health_test",
+			ClusterType: v2.EDS_CLUSTER,
+			LbType:      v2.LB_ROUNDROBIN,
+		},
+	}, map[string][]v2.Host{
+		"eds_health_test": {
+			{HostConfig: v2.HostConfig{Address: addrs[0], HealthStatus: v2.HostHealthy}},
+			{HostConfig: v2.HostConfig{Address: addrs[1], HealthStatus: v2.HostUnhealthy}},
+			{HostConfig: v2.HostConfig{Address: addrs[2], HealthStatus: v2.HostDraining}},
+			{HostConfig: v2.HostConfig{Address: addrs[3]}},
+		},
+	}, nil)
+	chooseHosts := func() map[string]bool {
+		snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "eds_health_test")
+		chosen := map[string]bool{}
+		for i := 0; i < 20; i++ {
+			_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+			require.NotNil(t, host)
+			chosen[host.AddressString()] = true
+		}
+		return chosen
+	}
+	// the unhealthy and draining hosts are not chosen
+	require.Equal(t, map[string]bool{addrs[0]: true, addrs[3]: true}, chooseHosts())
+	// the host is unhealthy if the local health check fails, even if the control plane reports it is healthy
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "eds_health_test")
+	snap.HostSet().Get(0).SetHealthFlag(api.FAILED_ACTIVE_HC)
+	require.Equal(t, map[string]bool{addrs[3]: true}, chooseHosts())
+	pool := loadMockConnPool(addrs[3])
+	require.NotNil(t, pool)
+	// eds update recovers the unhealthy host, and drains the available host
+	require.Nil(t, clusterManagerInstance.UpdateClusterHosts("eds_health_test", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: addrs[0], HealthStatus: v2.HostHealthy}},
+		{HostConfig: v2.HostConfig{Address: addrs[1], HealthStatus: v2.HostHealthy}},
+		{HostConfig: v2.HostConfig{Address: addrs[2], HealthStatus: v2.HostDraining}},
+		{HostConfig: v2.HostConfig{Address: addrs[3], HealthStatus: v2.HostDraining}},
+	}))
+	require.True(t, types.IsHostDraining(snap.HostSet().Get(3)))
+	require.Equal(t, map[string]bool{addrs[1]: true}, chooseHosts())
+	// the draining host's connection pool is closed after the requests finished
+	time.Sleep(3 * drainCheckInterval)
+	require.Equal(t, uint32(1), atomic.LoadUint32(&pool.closed))
+	// the local health check recovers
+	newSnap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "eds_health_test")
+	newSnap.HostSet().Get(0).ClearHealthFlag(api.FAILED_ACTIVE_HC)
+	require.Equal(t, map[string]bool{addrs[0]: true, addrs[1]: true}, chooseHosts())
+}
+
+func TestMaxConnectionsPerHostFailover(t *testing.T) {
+	clusterManagerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:                  "max_conns_per_host",
+			LbType:                v2.LB_ROUNDROBIN,
+			MaxConnectionsPerHost: 1,
+		},
+	}, map[string][]v2.Host{
+		"max_conns_per_host": {
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10200"}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10201"}},
+		},
+	}, nil)
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "max_conns_per_host")
+	fullHost, otherHost := snap.HostSet().Get(0), snap.HostSet().Get(1)
+	// the connections are counted by cluster and address
+	fullConns := getConnectionsPointer("max_conns_per_host", fullHost.AddressString())
+	atomic.AddInt64(fullConns, 1)
+	defer atomic.AddInt64(fullConns, -1)
+	require.True(t, types.IsHostConnectionOverflow(fullHost))
+	require.False(t, types.IsHostConnectionOverflow(otherHost))
+	// new requests fail over to the other host
+	for i := 0; i < 10; i++ {
+		_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+		require.Equal(t, otherHost.AddressString(), host.AddressString())
+	}
+	// all hosts reach the limit, the host is still chosen and the pool decides
+	otherConns := getConnectionsPointer("max_conns_per_host", otherHost.AddressString())
+	atomic.AddInt64(otherConns, 1)
+	defer atomic.AddInt64(otherConns, -1)
+	_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+	require.NotNil(t, host)
+}
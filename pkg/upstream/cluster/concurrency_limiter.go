@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// default adaptive concurrency config
+const (
+	defaultSampleWindow   = time.Second
+	defaultMinConcurrency = 1
+	defaultMaxConcurrency = 1000
+	// minGradient bounds the decrease of the limit in a sample window
+	minGradient = 0.5
+)
+
+// adaptiveConcurrencyLimiter limits the in-flight requests to a cluster. At the end of each
+// sample window, the limit is adjusted by the average latency of the requests in the window:
+// if the latency exceeds the target, the limit decreases by the gradient target/latency,
+// otherwise the limit increases by one.
+type adaptiveConcurrencyLimiter struct {
+	sampleWindow   time.Duration
+	minConcurrency uint32
+	maxConcurrency uint32
+	latencyTarget  time.Duration
+
+	limit    uint32
+	inflight int64
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	samples     int64
+	latencySum  time.Duration
+	now         func() time.Time
+}
+
+// newAdaptiveConcurrencyLimiter returns nil if the latency target is not configured
+func newAdaptiveConcurrencyLimiter(cfg *v2.AdaptiveConcurrency) *adaptiveConcurrencyLimiter {
+	if cfg == nil || cfg.LatencyTargetConfig.Duration <= 0 {
+		return nil
+	}
+	l := &adaptiveConcurrencyLimiter{
+		sampleWindow:   cfg.SampleWindowConfig.Duration,
+		minConcurrency: cfg.MinConcurrency,
+		maxConcurrency: cfg.MaxConcurrency,
+		latencyTarget:  cfg.LatencyTargetConfig.Duration,
+		now:            time.Now,
+	}
+	if l.sampleWindow <= 0 {
+		l.sampleWindow = defaultSampleWindow
+	}
+	if l.minConcurrency == 0 {
+		l.minConcurrency = defaultMinConcurrency
+	}
+	if l.maxConcurrency == 0 {
+		l.maxConcurrency = defaultMaxConcurrency
+	}
+	if l.maxConcurrency < l.minConcurrency {
+		l.maxConcurrency = l.minConcurrency
+	}
+	// starts with the max concurrency, the limit decreases only if the latency climbs
+	l.limit = l.maxConcurrency
+	l.windowStart = l.now()
+	return l
+}
+
+func (l *adaptiveConcurrencyLimiter) TryAcquire() bool {
+	limit := int64(atomic.LoadUint32(&l.limit))
+	if atomic.AddInt64(&l.inflight, 1) > limit {
+		atomic.AddInt64(&l.inflight, -1)
+		return false
+	}
+	return true
+}
+
+func (l *adaptiveConcurrencyLimiter) Release(latency time.Duration) {
+	atomic.AddInt64(&l.inflight, -1)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.samples++
+	l.latencySum += latency
+	now := l.now()
+	if now.Sub(l.windowStart) < l.sampleWindow {
+		return
+	}
+	l.update(l.latencySum / time.Duration(l.samples))
+	l.windowStart = now
+	l.samples = 0
+	l.latencySum = 0
+}
+
+func (l *adaptiveConcurrencyLimiter) Limit() uint32 {
+	return atomic.LoadUint32(&l.limit)
+}
+
+// update adjusts the limit by the average latency of a sample window
+func (l *adaptiveConcurrencyLimiter) update(latency time.Duration) {
+	limit := atomic.LoadUint32(&l.limit)
+	if latency > l.latencyTarget {
+		gradient := float64(l.latencyTarget) / float64(latency)
+		if gradient < minGradient {
+			gradient = minGradient
+		}
+		limit = uint32(float64(limit) * gradient)
+	} else {
+		limit++
+	}
+	if limit < l.minConcurrency {
+		limit = l.minConcurrency
+	}
+	if limit > l.maxConcurrency {
+		limit = l.maxConcurrency
+	}
+	atomic.StoreUint32(&l.limit, limit)
+}
@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func newTestConcurrencyLimiter(cfg *v2.AdaptiveConcurrency) (*adaptiveConcurrencyLimiter, *time.Time) {
+	now := time.Now()
+	l := newAdaptiveConcurrencyLimiter(cfg)
+	l.now = func() time.Time {
+		return now
+	}
+	l.windowStart = now
+	return l, &now
+}
+
+// runWindow sends the requests with the latency in a sample window
+func runWindow(l *adaptiveConcurrencyLimiter, now *time.Time, latency time.Duration, count int) {
+	for i := 0; i < count; i++ {
+		if l.TryAcquire() {
+			l.Release(latency)
+		}
+	}
+	*now = now.Add(l.sampleWindow)
+	if l.TryAcquire() {
+		l.Release(latency)
+	}
+}
+
+func TestNewAdaptiveConcurrencyLimiter(t *testing.T) {
+	if l := newAdaptiveConcurrencyLimiter(nil); l != nil {
+		t.Fatal("expected no limiter without config")
+	}
+	if l := newAdaptiveConcurrencyLimiter(&v2.AdaptiveConcurrency{MaxConcurrency: 10}); l != nil {
+		t.Fatal("expected no limiter without latency target")
+	}
+	l := newAdaptiveConcurrencyLimiter(&v2.AdaptiveConcurrency{
+		LatencyTargetConfig: api.DurationConfig{Duration: 10 * time.Millisecond},
+	})
+	if !(l.sampleWindow == defaultSampleWindow &&
+		l.minConcurrency == defaultMinConcurrency &&
+		l.maxConcurrency == defaultMaxConcurrency &&
+		l.Limit() == defaultMaxConcurrency) {
+		t.Fatalf("unexpected default config: %+v", l)
+	}
+}
+
+func TestAdaptiveConcurrencyLimiterReject(t *testing.T) {
+	l, _ := newTestConcurrencyLimiter(&v2.AdaptiveConcurrency{
+		MaxConcurrency:      2,
+		LatencyTargetConfig: api.DurationConfig{Duration: 10 * time.Millisecond},
+	})
+	if !l.TryAcquire() || !l.TryAcquire() {
+		t.Fatal("expected the requests under the limit are allowed")
+	}
+	if l.TryAcquire() {
+		t.Fatal("expected the request over the limit is rejected")
+	}
+	l.Release(time.Millisecond)
+	if !l.TryAcquire() {
+		t.Fatal("expected the request is allowed after a release")
+	}
+}
+
+func TestAdaptiveConcurrencyLimiterAdapt(t *testing.T) {
+	l, now := newTestConcurrencyLimiter(&v2.AdaptiveConcurrency{
+		SampleWindowConfig:  api.DurationConfig{Duration: time.Second},
+		MinConcurrency:      5,
+		MaxConcurrency:      100,
+		LatencyTargetConfig: api.DurationConfig{Duration: 10 * time.Millisecond},
+	})
+
+	// the latency is under the target, the limit keeps the max
+	runWindow(l, now, 5*time.Millisecond, 10)
+	if l.Limit() != 100 {
+		t.Fatalf("expected limit 100, got %d", l.Limit())
+	}
+
+	// the latency climbs a little, the limit decreases by the gradient
+	runWindow(l, now, 12500*time.Microsecond, 10)
+	if l.Limit() != 80 {
+		t.Fatalf("expected limit 80, got %d", l.Limit())
+	}
+
+	// the latency climbs a lot, the limit decreases by half at most
+	runWindow(l, now, 100*time.Millisecond, 10)
+	if l.Limit() != 40 {
+		t.Fatalf("expected limit 40, got %d", l.Limit())
+	}
+
+	// the limit never falls below the min concurrency
+	for i := 0; i < 10; i++ {
+		runWindow(l, now, time.Second, 10)
+	}
+	if l.Limit() != 5 {
+		t.Fatalf("expected limit 5, got %d", l.Limit())
+	}
+
+	// the latency recovers, the limit increases by one each window
+	for i := 0; i < 3; i++ {
+		runWindow(l, now, time.Millisecond, 10)
+	}
+	if l.Limit() != 8 {
+		t.Fatalf("expected limit 8, got %d", l.Limit())
+	}
+
+	// the limit never exceeds the max concurrency
+	for i := 0; i < 200; i++ {
+		runWindow(l, now, time.Millisecond, 1)
+	}
+	if l.Limit() != 100 {
+		t.Fatalf("expected limit 100, got %d", l.Limit())
+	}
+}
+
+func TestClusterInfoConcurrencyLimiter(t *testing.T) {
+	info := NewClusterInfo(v2.Cluster{
+		Name:        "concurrency_limiter_cluster",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		AdaptiveConcurrency: &v2.AdaptiveConcurrency{
+			LatencyTargetConfig: api.DurationConfig{Duration: 10 * time.Millisecond},
+		},
+	})
+	getter, ok := info.(types.ConcurrencyLimiterGetter)
+	if !ok || getter.ConcurrencyLimiter() == nil {
+		t.Fatal("expected a concurrency limiter")
+	}
+
+	info = NewClusterInfo(v2.Cluster{
+		Name:        "no_concurrency_limiter_cluster",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	if info.(types.ConcurrencyLimiterGetter).ConcurrencyLimiter() != nil {
+		t.Fatal("expected no concurrency limiter")
+	}
+}
@@ -24,17 +24,28 @@ import (
 	"mosn.io/api"
 )
 
+// FAILED_EDS_HEALTH is set if the control plane reports the host is unhealthy,
+// it is combined with the local health check flags, so the worst state wins
+const FAILED_EDS_HEALTH api.HealthFlag = 0x04
+
 // health flag reuse for same address
 // TODO: use one map for all reuse data
 var healthStore = sync.Map{}
 
 func GetHealthFlagPointer(addr string) *uint64 {
-	v, _ := healthStore.LoadOrStore(addr, func() *uint64 {
+	p, _ := getHealthFlagPointer(addr)
+	return p
+}
+
+// getHealthFlagPointer returns the health flags shared by the hosts of the address,
+// created is true if the address is seen for the first time
+func getHealthFlagPointer(addr string) (p *uint64, created bool) {
+	v, loaded := healthStore.LoadOrStore(addr, func() *uint64 {
 		f := uint64(0)
 		return &f
 	}())
-	p, _ := v.(*uint64)
-	return p
+	p, _ = v.(*uint64)
+	return p, !loaded
 }
 
 func SetHealthFlag(p *uint64, flag api.HealthFlag) {
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"net"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -42,7 +43,13 @@ type simpleHost struct {
 	metaData      api.Metadata
 	tlsDisable    bool
 	weight        uint32
+	healthStatus  v2.HostHealthStatus
 	healthFlags   *uint64
+	draining      uint32
+	healthyTime   int64 // unix nano
+	connections   *int64
+	// newAddress is true if the health flags of the address are created by the host
+	newAddress bool
 }
 
 func NewSimpleHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
@@ -56,12 +63,30 @@ func NewSimpleHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
 		metaData:      config.MetaData,
 		tlsDisable:    config.TLSDisable,
 		weight:        config.Weight,
-		healthFlags:   GetHealthFlagPointer(config.Address),
+		healthStatus:  config.HealthStatus,
+		healthyTime:   time.Now().UnixNano(),
+		connections:   getConnectionsPointer(clusterInfo.Name(), config.Address),
 	}
+	h.healthFlags, h.newAddress = getHealthFlagPointer(config.Address)
 	h.clusterInfo.Store(clusterInfo)
+	h.applyHealthStatus()
 	return h
 }
 
+// applyHealthStatus applies the health status reported by the control plane,
+// the host is unhealthy if either the control plane or the local health checks fail it
+func (sh *simpleHost) applyHealthStatus() {
+	if sh.healthStatus == v2.HostUnhealthy {
+		sh.SetHealthFlag(FAILED_EDS_HEALTH)
+	} else {
+		sh.ClearHealthFlag(FAILED_EDS_HEALTH)
+	}
+	// the draining host does not accept new requests
+	if sh.healthStatus == v2.HostDraining {
+		sh.SetDraining(true)
+	}
+}
+
 // types.HostInfo Implement
 func (sh *simpleHost) Hostname() string {
 	return sh.hostname
@@ -105,10 +130,11 @@ func (sh *simpleHost) Weight() uint32 {
 func (sh *simpleHost) Config() v2.Host {
 	return v2.Host{
 		HostConfig: v2.HostConfig{
-			Address:    sh.addressString,
-			Hostname:   sh.hostname,
-			TLSDisable: sh.tlsDisable,
-			Weight:     sh.weight,
+			Address:      sh.addressString,
+			Hostname:     sh.hostname,
+			TLSDisable:   sh.tlsDisable,
+			Weight:       sh.weight,
+			HealthStatus: sh.healthStatus,
 		},
 		MetaData: sh.metaData,
 	}
@@ -132,6 +158,19 @@ func (sh *simpleHost) TLSHashValue() *types.HashValue {
 
 // types.Host Implement
 func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConnectionData {
+	data, _ := sh.createConnection(context, false)
+	return data
+}
+
+// CreateLimitedConnection implements types.ConnectionLimitHost
+func (sh *simpleHost) CreateLimitedConnection(context context.Context) (types.CreateConnectionData, bool) {
+	return sh.createConnection(context, true)
+}
+
+func (sh *simpleHost) createConnection(context context.Context, limited bool) (types.CreateConnectionData, bool) {
+	if !sh.reserveConnection(limited) {
+		return types.CreateConnectionData{}, false
+	}
 	var tlsMng types.TLSClientContextManager
 	if sh.SupportTLS() {
 		tlsMng = sh.ClusterInfo().TLSMng()
@@ -143,10 +182,74 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 		clientConn.SetIdleTimeout(types.DefaultConnReadTimeout, sh.ClusterInfo().IdleTimeout())
 	}
 
+	setProxyProtocolHeader(context, sh.ClusterInfo(), clientConn)
+	if tlsMng != nil {
+		setServerName(context, tlsMng, clientConn)
+	}
+
+	sh.countConnection(clientConn)
+
 	return types.CreateConnectionData{
 		Connection: clientConn,
 		Host:       sh,
+	}, true
+}
+
+// setProxyProtocolHeader makes the new connection send the proxy protocol header with the addresses of
+// the downstream in the context. the header is only sent once, so the connection is only reused by the
+// same downstream connection, see connPoolKey.
+func setProxyProtocolHeader(ctx context.Context, info types.ClusterInfo, conn types.ClientConnection) {
+	pc, ok := info.(types.ProxyProtocolCluster)
+	if !ok || pc.SendProxyProtocol() == "" {
+		return
+	}
+	ppc, ok := conn.(types.ProxyProtocolConnection)
+	if !ok {
+		return
+	}
+	var src, dst string
+	if ctx != nil {
+		src, _ = types.GetConnectionVariable(ctx, types.VarDownstreamRemoteAddress)
+		dst, _ = types.GetConnectionVariable(ctx, types.VarDownstreamLocalAddress)
 	}
+	header, err := network.ProxyProtocolHeader(pc.SendProxyProtocol(), src, dst)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [host] cluster %s send proxy protocol failed: %v", info.Name(), err)
+		return
+	}
+	ppc.SetProxyProtocolHeader(header)
+}
+
+// setServerName makes the new tls connection use the host of the request in the context as the server name.
+func setServerName(ctx context.Context, tlsMng types.TLSClientContextManager, conn types.ClientConnection) {
+	sc, ok := conn.(types.ServerNameConnection)
+	if !ok {
+		return
+	}
+	if serverName := upstreamServerName(ctx, tlsMng); serverName != "" {
+		sc.SetServerName(serverName)
+	}
+}
+
+// upstreamServerName returns the server name chosen by the host of the request,
+// empty string means the configured server name is used.
+func upstreamServerName(ctx context.Context, tlsMng types.TLSClientContextManager) string {
+	mng, ok := tlsMng.(types.TLSServerNameContextManager)
+	if !ok || !mng.AutoServerName() || ctx == nil {
+		return ""
+	}
+	host, err := types.GetConnectionVariable(ctx, types.VarHost)
+	if err != nil || host == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	// ip address is not allowed in the server name
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
 }
 
 func (sh *simpleHost) CreateUDPConnection(context context.Context) types.CreateConnectionData {
@@ -160,7 +263,12 @@ func (sh *simpleHost) CreateUDPConnection(context context.Context) types.CreateC
 }
 
 func (sh *simpleHost) ClearHealthFlag(flag api.HealthFlag) {
+	healthy := sh.Health()
 	ClearHealthFlag(sh.healthFlags, flag)
+	// the slow start begins again if the host becomes healthy
+	if !healthy && sh.Health() {
+		sh.SetHealthyTime(time.Now())
+	}
 }
 
 func (sh *simpleHost) ContainHealthFlag(flag api.HealthFlag) bool {
@@ -171,6 +279,11 @@ func (sh *simpleHost) SetHealthFlag(flag api.HealthFlag) {
 	SetHealthFlag(sh.healthFlags, flag)
 }
 
+// NewAddress returns true if the address of the host is seen for the first time
+func (sh *simpleHost) NewAddress() bool {
+	return sh.newAddress
+}
+
 func (sh *simpleHost) HealthFlag() api.HealthFlag {
 	return api.HealthFlag(atomic.LoadUint64(sh.healthFlags))
 }
@@ -179,6 +292,100 @@ func (sh *simpleHost) Health() bool {
 	return atomic.LoadUint64(sh.healthFlags) == 0
 }
 
+// types.SlowStartHost Implement
+func (sh *simpleHost) HealthyTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&sh.healthyTime))
+}
+
+func (sh *simpleHost) SetHealthyTime(t time.Time) {
+	atomic.StoreInt64(&sh.healthyTime, t.UnixNano())
+}
+
+// types.DrainableHost Implement
+func (sh *simpleHost) Draining() bool {
+	return atomic.LoadUint32(&sh.draining) == 1
+}
+
+func (sh *simpleHost) SetDraining(draining bool) {
+	if draining {
+		atomic.StoreUint32(&sh.draining, 1)
+	} else {
+		atomic.StoreUint32(&sh.draining, 0)
+	}
+}
+
+// types.ConnectionCountHost Implement
+func (sh *simpleHost) ActiveConnections() int64 {
+	if sh.connections == nil {
+		return 0
+	}
+	return atomic.LoadInt64(sh.connections)
+}
+
+// reserveConnection counts a new connection before it is created, returns false if the connection
+// is limited and the connections reach the cluster's max connections per host
+func (sh *simpleHost) reserveConnection(limited bool) bool {
+	if sh.connections == nil {
+		return true
+	}
+	var max int64
+	if lc, ok := sh.ClusterInfo().(types.HostConnectionLimitCluster); ok && limited {
+		max = int64(lc.MaxConnectionsPerHost())
+	}
+	for {
+		connections := atomic.LoadInt64(sh.connections)
+		if max > 0 && connections >= max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(sh.connections, connections, connections+1) {
+			return true
+		}
+	}
+}
+
+// countConnection counts the reserved connection until it is closed
+func (sh *simpleHost) countConnection(conn types.ClientConnection) {
+	if sh.connections == nil {
+		return
+	}
+	conn.AddConnectionEventListener(&hostConnectionListener{
+		connections: sh.connections,
+	})
+}
+
+// hostConnectionListener decreases the connections of the host when the connection is closed or failed to connect
+type hostConnectionListener struct {
+	connections *int64
+	done        uint32
+}
+
+func (l *hostConnectionListener) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() && !event.ConnectFailure() {
+		return
+	}
+	if atomic.CompareAndSwapUint32(&l.done, 0, 1) {
+		atomic.AddInt64(l.connections, -1)
+	}
+}
+
+// connections count reuse for same cluster and address, the connections are still counted
+// after the host is updated by the cluster
+var connectionsStore = sync.Map{}
+
+type connectionsKey struct {
+	cluster string
+	addr    string
+}
+
+func getConnectionsPointer(clusterName string, addr string) *int64 {
+	v, _ := connectionsStore.LoadOrStore(connectionsKey{cluster: clusterName, addr: addr}, func() *int64 {
+		c := int64(0)
+		return &c
+	}())
+	p, _ := v.(*int64)
+	return p
+}
+
 // net.Addr reuse for same address, valid in simple type
 // Update DNS cache using asynchronous mode
 var AddrStore *utils.ExpiredMap = utils.NewExpiredMap(
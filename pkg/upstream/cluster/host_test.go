@@ -21,14 +21,17 @@ import (
 	"context"
 	"mosn.io/mosn/pkg/types"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/pkg/utils"
+	"mosn.io/pkg/variable"
 )
 
 // TestCreateConnectionIdleTimeout test the connection idle timeout feature
@@ -153,3 +156,302 @@ func (c *countConnServer) stop() {
 	c.listener.Close()
 	close(c.stopChan)
 }
+
+func TestCreateConnectionProxyProtocol(t *testing.T) {
+	_ = variable.Register(variable.NewStringVariable(types.VarDownstreamRemoteAddress, nil, nil, variable.DefaultStringSetter, 0))
+	_ = variable.Register(variable.NewStringVariable(types.VarDownstreamLocalAddress, nil, nil, variable.DefaultStringSetter, 0))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	received := make(chan []byte, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				b := make([]byte, 128)
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				n, _ := conn.Read(b)
+				received <- b[:n]
+			}()
+		}
+	}()
+
+	clusterConf := v2.Cluster{
+		Name:              "proxy_protocol",
+		ClusterType:       v2.SIMPLE_CLUSTER,
+		LbType:            v2.LB_ROUNDROBIN,
+		SendProxyProtocol: v2.ProxyProtocolV1,
+		Hosts: []v2.Host{
+			{
+				HostConfig: v2.HostConfig{
+					Address: ln.Addr().String(),
+				},
+			},
+		},
+	}
+	host := NewSimpleHost(clusterConf.Hosts[0], NewCluster(clusterConf).Snapshot().ClusterInfo())
+
+	receive := func() string {
+		select {
+		case b := <-received:
+			return string(b)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no bytes received")
+		}
+		return ""
+	}
+
+	// the header carries the addresses of the downstream
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.SetString(ctx, types.VarDownstreamRemoteAddress, "192.168.1.1:56324")
+	_ = variable.SetString(ctx, types.VarDownstreamLocalAddress, "10.0.0.1:443")
+	conn := host.CreateConnection(ctx).Connection
+	require.Nil(t, conn.Connect())
+	assert.Equal(t, "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n", receive())
+	conn.Close(api.NoFlush, api.LocalClose)
+
+	// the connection context keeps the addresses after the request is finished, such as the prefetch
+	connCtx := types.NewConnectionContext(ctx)
+	_ = variable.SetString(ctx, types.VarDownstreamRemoteAddress, "192.168.1.2:56325")
+	conn = host.CreateConnection(connCtx).Connection
+	require.Nil(t, conn.Connect())
+	assert.Equal(t, "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n", receive())
+	conn.Close(api.NoFlush, api.LocalClose)
+
+	// the connection without downstream, such as the health check connection
+	conn = host.CreateConnection(context.Background()).Connection
+	require.Nil(t, conn.Connect())
+	assert.Equal(t, "PROXY UNKNOWN\r\n", receive())
+	conn.Close(api.NoFlush, api.LocalClose)
+
+	// the header is not sent if the cluster does not enable it
+	clusterConf.SendProxyProtocol = "v3"
+	info := NewCluster(clusterConf).Snapshot().ClusterInfo()
+	assert.Equal(t, "", info.(types.ProxyProtocolCluster).SendProxyProtocol())
+}
+
+func TestHostActiveConnections(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	clusterConf := v2.Cluster{
+		Name:                  "active_connections",
+		ClusterType:           v2.SIMPLE_CLUSTER,
+		LbType:                v2.LB_ROUNDROBIN,
+		MaxConnectionsPerHost: 2,
+		Hosts: []v2.Host{
+			{
+				HostConfig: v2.HostConfig{
+					Address: ln.Addr().String(),
+				},
+			},
+		},
+	}
+	host := NewSimpleHost(clusterConf.Hosts[0], NewCluster(clusterConf).Snapshot().ClusterInfo())
+	ch := host.(types.ConnectionCountHost)
+
+	conn1 := host.CreateConnection(context.Background()).Connection
+	require.Nil(t, conn1.Connect())
+	conn2 := host.CreateConnection(context.Background()).Connection
+	require.Nil(t, conn2.Connect())
+	assert.Equal(t, int64(2), ch.ActiveConnections())
+	assert.True(t, types.IsHostConnectionOverflow(host))
+
+	// the limited connection is refused before it is dialed
+	_, ok := host.(types.ConnectionLimitHost).CreateLimitedConnection(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, int64(2), ch.ActiveConnections())
+
+	// the connections are counted by cluster and address, the updated host shares the count
+	updated := NewSimpleHost(clusterConf.Hosts[0], host.ClusterInfo())
+	assert.True(t, types.IsHostConnectionOverflow(updated))
+	otherConf := clusterConf
+	otherConf.Name = "active_connections_other"
+	other := NewSimpleHost(otherConf.Hosts[0], NewCluster(otherConf).Snapshot().ClusterInfo())
+	assert.False(t, types.IsHostConnectionOverflow(other))
+
+	conn1.Close(api.NoFlush, api.LocalClose)
+	assert.Equal(t, int64(1), ch.ActiveConnections())
+	assert.False(t, types.IsHostConnectionOverflow(host))
+	// close twice is counted once
+	conn1.Close(api.NoFlush, api.LocalClose)
+	assert.Equal(t, int64(1), ch.ActiveConnections())
+	conn2.Close(api.NoFlush, api.LocalClose)
+	assert.Equal(t, int64(0), ch.ActiveConnections())
+
+	// connect failed
+	ln.Close()
+	conn3 := host.CreateConnection(context.Background()).Connection
+	assert.NotNil(t, conn3.Connect())
+	assert.Equal(t, int64(0), ch.ActiveConnections())
+}
+
+func TestConnPoolKeyServerName(t *testing.T) {
+	_ = variable.Register(variable.NewStringVariable(types.VarHost, nil, nil, variable.DefaultStringSetter, 0))
+
+	clusterConf := v2.Cluster{
+		Name:        "auto_sni",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+		TLS: v2.TLSConfig{
+			Status:          true,
+			InsecureSkip:    true,
+			AutoSniFromHost: true,
+		},
+		Hosts: []v2.Host{
+			{
+				HostConfig: v2.HostConfig{
+					Address: "127.0.0.1:8443",
+				},
+			},
+		},
+	}
+	host := NewSimpleHost(clusterConf.Hosts[0], NewCluster(clusterConf).Snapshot().ClusterInfo())
+	require.True(t, host.SupportTLS())
+
+	newContext := func(h string) context.Context {
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.SetString(ctx, types.VarHost, h)
+		return ctx
+	}
+	// the connections of different server names are not shared
+	assert.Equal(t, "127.0.0.1:8443#www.example.com", connPoolKey(newContext("www.example.com:8443"), host))
+	assert.Equal(t, "127.0.0.1:8443#foo.example.com", connPoolKey(newContext("foo.example.com"), host))
+	// ip address is not a server name
+	assert.Equal(t, "127.0.0.1:8443", connPoolKey(newContext("127.0.0.1:8443"), host))
+	assert.Equal(t, "127.0.0.1:8443", connPoolKey(context.Background(), host))
+
+	clusterConf.TLS.AutoSniFromHost = false
+	host = NewSimpleHost(clusterConf.Hosts[0], NewCluster(clusterConf).Snapshot().ClusterInfo())
+	assert.Equal(t, "127.0.0.1:8443", connPoolKey(newContext("www.example.com"), host))
+
+	pools := &sync.Map{}
+	pools.Store("127.0.0.1:8443", &mockConnPool{})
+	pools.Store("127.0.0.1:8443#www.example.com", &mockConnPool{})
+	pools.Store("127.0.0.1:84430", &mockConnPool{})
+	assert.Len(t, deleteConnPools(pools, "127.0.0.1:8443"), 2)
+	_, ok := pools.Load("127.0.0.1:84430")
+	assert.True(t, ok)
+}
+
+func TestConnPoolProxyProtocolDownstream(t *testing.T) {
+	_ = variable.Register(variable.NewStringVariable(types.VarDownstreamRemoteAddress, nil, nil, variable.DefaultStringSetter, 0))
+	_ = variable.Register(variable.NewStringVariable(types.VarDownstreamLocalAddress, nil, nil, variable.DefaultStringSetter, 0))
+	clusterConf := v2.Cluster{
+		Name:              "proxy_protocol",
+		ClusterType:       v2.SIMPLE_CLUSTER,
+		LbType:            v2.LB_ROUNDROBIN,
+		SendProxyProtocol: v2.ProxyProtocolV1,
+	}
+	clusterManagerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{clusterConf}, map[string][]v2.Host{
+		"proxy_protocol": {
+			{
+				HostConfig: v2.HostConfig{
+					Address: "127.0.0.1:10000",
+				},
+			},
+		},
+	}, nil)
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), "proxy_protocol")
+
+	newLbContext := func(src string) types.LoadBalancerContext {
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.SetString(ctx, types.VarDownstreamRemoteAddress, src)
+		_ = variable.SetString(ctx, types.VarDownstreamLocalAddress, "10.0.0.1:443")
+		return newMockLbContextWithCtx(nil, ctx)
+	}
+	// the connections are not shared by different downstream connections
+	pool1, _ := GetClusterMngAdapterInstance().ConnPoolForCluster(newLbContext("192.168.1.1:56324"), snap, mockProtocol)
+	pool2, _ := GetClusterMngAdapterInstance().ConnPoolForCluster(newLbContext("192.168.1.1:56325"), snap, mockProtocol)
+	require.NotNil(t, pool1)
+	require.NotNil(t, pool2)
+	assert.True(t, pool1 != pool2)
+	pool, _ := GetClusterMngAdapterInstance().ConnPoolForCluster(newLbContext("192.168.1.1:56324"), snap, mockProtocol)
+	assert.True(t, pool1 == pool)
+	assert.Equal(t, "127.0.0.1:10000#192.168.1.1:56324>10.0.0.1:443", connPoolKey(newLbContext("192.168.1.1:56324").DownstreamContext(), pool1.Host()))
+
+	// the pools are closed with the downstream connection
+	releaser, ok := GetClusterMngAdapterInstance().ClusterManager.(types.DownstreamConnPoolReleaser)
+	require.True(t, ok)
+	releaser.ReleaseDownstreamConnPools("192.168.1.1:56324", "10.0.0.1:443")
+	assert.Equal(t, uint32(1), pool1.(*mockConnPool).closed)
+	assert.Equal(t, uint32(0), pool2.(*mockConnPool).closed)
+	pool, _ = GetClusterMngAdapterInstance().ConnPoolForCluster(newLbContext("192.168.1.1:56324"), snap, mockProtocol)
+	assert.True(t, pool1 != pool)
+}
+
+func TestConnPoolServerNameLimit(t *testing.T) {
+	_ = variable.Register(variable.NewStringVariable(types.VarHost, nil, nil, variable.DefaultStringSetter, 0))
+	defer func(max int) {
+		maxServerNameConnPools = max
+	}(maxServerNameConnPools)
+	maxServerNameConnPools = 2
+
+	clusterConf := v2.Cluster{
+		Name:        "auto_sni",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+		TLS: v2.TLSConfig{
+			Status:          true,
+			InsecureSkip:    true,
+			AutoSniFromHost: true,
+		},
+	}
+	clusterManagerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{clusterConf}, map[string][]v2.Host{
+		"auto_sni": {
+			{
+				HostConfig: v2.HostConfig{
+					Address: "127.0.0.1:8443",
+				},
+			},
+		},
+	}, nil)
+	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(context.Background(), "auto_sni")
+
+	newLbContext := func(h string) types.LoadBalancerContext {
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.SetString(ctx, types.VarHost, h)
+		return newMockLbContextWithCtx(nil, ctx)
+	}
+	value, ok := clusterManagerInstance.protocolConnPool.Load(mockProtocol)
+	require.True(t, ok)
+	pools := value.(*sync.Map)
+	for _, h := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		pool, _ := GetClusterMngAdapterInstance().ConnPoolForCluster(newLbContext(h), snap, mockProtocol)
+		require.NotNil(t, pool)
+	}
+	// the oldest pool of the server names is shut down
+	_, ok = pools.Load("127.0.0.1:8443#a.example.com")
+	assert.False(t, ok)
+	_, ok = pools.Load("127.0.0.1:8443#b.example.com")
+	assert.True(t, ok)
+	_, ok = pools.Load("127.0.0.1:8443#c.example.com")
+	assert.True(t, ok)
+	// the pool without the server name is not limited
+	pool, _ := GetClusterMngAdapterInstance().ConnPoolForCluster(newLbContext("127.0.0.1"), snap, mockProtocol)
+	require.NotNil(t, pool)
+	_, ok = pools.Load("127.0.0.1:8443#b.example.com")
+	assert.True(t, ok)
+
+	// the pools of the removed address are forgotten
+	clusterManagerInstance.closeConnectionPool("127.0.0.1:8443")
+	clusterManagerInstance.mux.Lock()
+	assert.Len(t, clusterManagerInstance.serverNameConnPools, 0)
+	clusterManagerInstance.mux.Unlock()
+}
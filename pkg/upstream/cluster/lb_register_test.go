@@ -169,3 +169,64 @@ func TestNewLBCluster(t *testing.T) {
 		t.Fatal("create cluster lb type not expected")
 	}
 }
+
+// lastHostLB is a trivial custom load balancer which chooses the last host,
+// and records the context of the request
+type lastHostLB struct {
+	hosts types.HostSet
+	ctx   types.LoadBalancerContext
+}
+
+func (lb *lastHostLB) ChooseHost(ctx types.LoadBalancerContext) types.Host {
+	lb.ctx = ctx
+	if lb.hosts.Size() == 0 {
+		return nil
+	}
+	return lb.hosts.Get(lb.hosts.Size() - 1)
+}
+
+func (lb *lastHostLB) IsExistsHosts(metadata api.MetadataMatchCriteria) bool {
+	return lb.hosts.Size() > 0
+}
+
+func (lb *lastHostLB) HostNum(metadata api.MetadataMatchCriteria) int {
+	return lb.hosts.Size()
+}
+
+func TestRegisterLoadBalancer(t *testing.T) {
+	var created *lastHostLB
+	factory := func(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer {
+		created = &lastHostLB{hosts: hosts}
+		return created
+	}
+	if err := RegisterLoadBalancer("test_last_host", factory); err != nil {
+		t.Fatalf("register load balancer failed: %v", err)
+	}
+	if err := RegisterLoadBalancer("test_last_host", factory); err != ErrDuplicateLoadBalancer {
+		t.Fatalf("expected duplicate error, but got: %v", err)
+	}
+	if err := RegisterLoadBalancer(string(types.RoundRobin), factory); err != ErrDuplicateLoadBalancer {
+		t.Fatalf("expected the builtin load balancer can not be replaced, but got: %v", err)
+	}
+
+	c := newSimpleCluster(v2.Cluster{
+		Name:        "test_custom_lb",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      "test_last_host",
+	})
+	hosts := makePool(3).MakeHosts(3, nil)
+	c.UpdateHosts(NewHostSet(hosts))
+	if created == nil || created.hosts.Size() != 3 {
+		t.Fatal("expected the custom load balancer is created with the hosts")
+	}
+	ctx := newMockLbContext(nil)
+	for i := 0; i < 10; i++ {
+		host := c.Snapshot().LoadBalancer().ChooseHost(ctx)
+		if host == nil || host.AddressString() != hosts[2].AddressString() {
+			t.Fatalf("expected the custom load balancer chooses the last host, but got: %v", host)
+		}
+	}
+	if created.ctx != ctx {
+		t.Fatal("expected the request context is passed to the custom load balancer")
+	}
+}
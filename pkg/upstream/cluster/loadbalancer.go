@@ -18,12 +18,15 @@
 package cluster
 
 import (
+	"errors"
 	"math/rand"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/dchest/siphash"
 	"github.com/trainyao/go-maglev"
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -32,16 +35,44 @@ import (
 	"mosn.io/pkg/variable"
 )
 
+// LoadBalancerFactory creates the load balancer of a cluster. The load balancer chooses a host from
+// the host set with the context of each request in ChooseHost, and it is recreated with the new
+// host set when the hosts of the cluster are updated.
+type LoadBalancerFactory func(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer
+
 // NewLoadBalancer can be register self defined type
-var lbFactories map[types.LoadBalancerType]func(types.ClusterInfo, types.HostSet) types.LoadBalancer
+var (
+	lbFactoriesMutex sync.RWMutex
+	lbFactories      map[types.LoadBalancerType]func(types.ClusterInfo, types.HostSet) types.LoadBalancer
+)
+
+var ErrDuplicateLoadBalancer = errors.New("load balancer is already registered")
 
 func RegisterLBType(lbType types.LoadBalancerType, f func(types.ClusterInfo, types.HostSet) types.LoadBalancer) {
+	lbFactoriesMutex.Lock()
+	defer lbFactoriesMutex.Unlock()
 	if lbFactories == nil {
 		lbFactories = make(map[types.LoadBalancerType]func(types.ClusterInfo, types.HostSet) types.LoadBalancer)
 	}
 	lbFactories[lbType] = f
 }
 
+// RegisterLoadBalancer registers a custom load balancer, the clusters use it by setting the lb_type to the name.
+// It should be called at startup before the clusters are created, and the registered name can not be replaced.
+func RegisterLoadBalancer(name string, factory LoadBalancerFactory) error {
+	lbFactoriesMutex.Lock()
+	defer lbFactoriesMutex.Unlock()
+	lbType := types.LoadBalancerType(name)
+	if _, ok := lbFactories[lbType]; ok {
+		return ErrDuplicateLoadBalancer
+	}
+	if lbFactories == nil {
+		lbFactories = make(map[types.LoadBalancerType]func(types.ClusterInfo, types.HostSet) types.LoadBalancer)
+	}
+	lbFactories[lbType] = factory
+	return nil
+}
+
 var rrFactory *roundRobinLoadBalancerFactory
 
 func init() {
@@ -54,6 +85,8 @@ func init() {
 	RegisterLBType(types.LeastActiveRequest, newleastActiveRequestLoadBalancer)
 	RegisterLBType(types.Maglev, newMaglevLoadBalancer)
 	RegisterLBType(types.RequestRoundRobin, newReqRoundRobinLoadBalancer)
+	RegisterLBType(types.WeightedLeastActiveRequest, newWeightedLeastActiveRequestLoadBalancer)
+	RegisterLBType(types.RingHash, newRingHashLoadBalancer)
 
 	registerVariables()
 }
@@ -76,9 +109,15 @@ func registerVariables() {
 
 func NewLoadBalancer(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer {
 	lbType := info.LbType()
-	if f, ok := lbFactories[lbType]; ok {
+	lbFactoriesMutex.RLock()
+	f, ok := lbFactories[lbType]
+	lbFactoriesMutex.RUnlock()
+	if ok {
 		return f(info, hosts)
 	}
+	if lbType != "" && log.DefaultLogger.GetLogLevel() >= log.WARN {
+		log.DefaultLogger.Warnf("[upstream] [loadbalancer] unknown load balancer type %s, use round robin instead", lbType)
+	}
 	return rrFactory.newRoundRobinLoadBalancer(info, hosts)
 }
 
@@ -191,12 +230,15 @@ func (lb *roundRobinLoadBalancer) HostNum(metadata api.MetadataMatchCriteria) in
 */
 type WRRLoadBalancer struct {
 	*EdfLoadBalancer
-	rrLB types.LoadBalancer
+	rrLB      types.LoadBalancer
+	slowStart *slowStart
 }
 
 func newWRRLoadBalancer(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer {
-	wrrLB := &WRRLoadBalancer{}
-	wrrLB.EdfLoadBalancer = newEdfLoadBalancerLoadBalancer(hosts, wrrLB.unweightChooseHost, wrrLB.hostWeight)
+	wrrLB := &WRRLoadBalancer{
+		slowStart: newSlowStart(info),
+	}
+	wrrLB.EdfLoadBalancer = newEdfLoadBalancerLoadBalancer(hosts, wrrLB.unweightChooseHost, wrrLB.hostWeight, wrrLB.slowStart != nil)
 	wrrLB.rrLB = rrFactory.newRoundRobinLoadBalancer(info, hosts)
 	return wrrLB
 }
@@ -211,7 +253,7 @@ func (lb *WRRLoadBalancer) HostNum(metadata api.MetadataMatchCriteria) int {
 
 func (lb *WRRLoadBalancer) hostWeight(item WeightItem) float64 {
 	host := item.(types.Host)
-	return float64(host.Weight())
+	return lb.slowStart.weight(host)
 }
 
 // do unweighted (fast) selection
@@ -221,26 +263,34 @@ func (lb *WRRLoadBalancer) unweightChooseHost(context types.LoadBalancerContext)
 
 const default_choice = 2
 
+const defaultMinimumRingSize = 1024
+
 // leastActiveRequestLoadBalancer choose the host with the least active request
 type leastActiveRequestLoadBalancer struct {
 	*EdfLoadBalancer
-	choice uint32
+	choice    uint32
+	slowStart *slowStart
 }
 
 func newleastActiveRequestLoadBalancer(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer {
-	lb := &leastActiveRequestLoadBalancer{}
+	lb := &leastActiveRequestLoadBalancer{
+		slowStart: newSlowStart(info),
+	}
 	if info != nil && info.LbConfig() != nil {
-		lb.choice = info.LbConfig().(*v2.LeastRequestLbConfig).ChoiceCount
-	} else {
+		if cfg, ok := info.LbConfig().(*v2.LeastRequestLbConfig); ok {
+			lb.choice = cfg.ChoiceCount
+		}
+	}
+	if lb.choice == 0 {
 		lb.choice = default_choice
 	}
-	lb.EdfLoadBalancer = newEdfLoadBalancerLoadBalancer(hosts, lb.unweightChooseHost, lb.hostWeight)
+	lb.EdfLoadBalancer = newEdfLoadBalancerLoadBalancer(hosts, lb.unweightChooseHost, lb.hostWeight, lb.slowStart != nil)
 	return lb
 }
 
 func (lb *leastActiveRequestLoadBalancer) hostWeight(item WeightItem) float64 {
 	host := item.(types.Host)
-	return float64(host.Weight()) / float64(host.HostStats().UpstreamRequestActive.Count()+1)
+	return lb.slowStart.weight(host) / float64(host.HostStats().UpstreamRequestActive.Count()+1)
 }
 
 func (lb *leastActiveRequestLoadBalancer) unweightChooseHost(context types.LoadBalancerContext) types.Host {
@@ -269,6 +319,92 @@ func (lb *leastActiveRequestLoadBalancer) unweightChooseHost(context types.LoadB
 
 }
 
+// weightedLeastActiveRequestLoadBalancer choose the host with the least effective load,
+// the effective load is (active request + 1) / weight, so the host with larger weight takes more requests.
+// If all the weights are equal, it works as the least active request load balancer.
+type weightedLeastActiveRequestLoadBalancer struct {
+	hosts     types.HostSet
+	rand      *rand.Rand
+	mutex     sync.Mutex
+	choice    uint32
+	rrLB      types.LoadBalancer // if no healthy host is picked, we'll degrade to rr load balancer
+	slowStart *slowStart
+}
+
+func newWeightedLeastActiveRequestLoadBalancer(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer {
+	lb := &weightedLeastActiveRequestLoadBalancer{
+		hosts:     hosts,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		choice:    default_choice,
+		rrLB:      rrFactory.newRoundRobinLoadBalancer(info, hosts),
+		slowStart: newSlowStart(info),
+	}
+	if info != nil {
+		if cfg, ok := info.LbConfig().(*v2.LeastRequestLbConfig); ok && cfg.ChoiceCount > 0 {
+			lb.choice = cfg.ChoiceCount
+		}
+	}
+	return lb
+}
+
+func (lb *weightedLeastActiveRequestLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	hs := lb.hosts
+	total := hs.Size()
+	if total == 0 {
+		return nil
+	}
+	if total == 1 {
+		if host := hs.Get(0); host.Health() {
+			return host
+		}
+		return nil
+	}
+
+	var candidate types.Host
+	lb.mutex.Lock()
+	// Choose `choice` times and return the best one
+	// See The Power of Two Random Choices: A Survey of Techniques and Results
+	//  http://www.eecs.harvard.edu/~michaelm/postscripts/handbook2001.pdf
+	for cur := 0; cur < int(lb.choice); cur++ {
+		host := hs.Get(lb.rand.Intn(total))
+		if !host.Health() {
+			continue
+		}
+		if candidate == nil || lb.slowStart.lessEffectiveLoad(host, candidate) {
+			candidate = host
+		}
+	}
+	lb.mutex.Unlock()
+
+	if candidate != nil {
+		return candidate
+	}
+	return lb.rrLB.ChooseHost(context)
+}
+
+func (lb *weightedLeastActiveRequestLoadBalancer) IsExistsHosts(metadata api.MetadataMatchCriteria) bool {
+	return lb.hosts.Size() > 0
+}
+
+func (lb *weightedLeastActiveRequestLoadBalancer) HostNum(metadata api.MetadataMatchCriteria) int {
+	return lb.hosts.Size()
+}
+
+// lessEffectiveLoad returns true if the (active + 1) / weight of host a is less than host b.
+// compares by cross multiplication to avoid the float division
+func lessEffectiveLoad(a, b types.Host) bool {
+	wa, wb := uint64(a.Weight()), uint64(b.Weight())
+	if wa == 0 {
+		wa = 1
+	}
+	if wb == 0 {
+		wb = 1
+	}
+	la := uint64(a.HostStats().UpstreamRequestActive.Count() + 1)
+	lb := uint64(b.HostStats().UpstreamRequestActive.Count() + 1)
+	return la*wb < lb*wa
+}
+
 type EdfLoadBalancer struct {
 	scheduler *edfScheduler
 	hosts     types.HostSet
@@ -319,20 +455,21 @@ func (lb *EdfLoadBalancer) HostNum(metadata api.MetadataMatchCriteria) int {
 	return lb.hosts.Size()
 }
 
-func newEdfLoadBalancerLoadBalancer(hosts types.HostSet, unWeightChoose func(types.LoadBalancerContext) types.Host, hostWeightFunc func(host WeightItem) float64) *EdfLoadBalancer {
+// if dynamicWeight is true, the weights of the hosts change over time, so the edf scheduler is always used
+func newEdfLoadBalancerLoadBalancer(hosts types.HostSet, unWeightChoose func(types.LoadBalancerContext) types.Host, hostWeightFunc func(host WeightItem) float64, dynamicWeight bool) *EdfLoadBalancer {
 	lb := &EdfLoadBalancer{
 		hosts:                  hosts,
 		rand:                   rand.New(rand.NewSource(time.Now().UnixNano())),
 		unweightChooseHostFunc: unWeightChoose,
 		hostWeightFunc:         hostWeightFunc,
 	}
-	lb.refresh(hosts)
+	lb.refresh(hosts, dynamicWeight)
 	return lb
 }
 
-func (lb *EdfLoadBalancer) refresh(hosts types.HostSet) {
+func (lb *EdfLoadBalancer) refresh(hosts types.HostSet, dynamicWeight bool) {
 	// Check if the original host weights are equal and skip EDF creation if they are
-	if hostWeightsAreEqual(hosts) {
+	if hostWeightsAreEqual(hosts) && (!dynamicWeight || hosts.Size() <= 1) {
 		return
 	}
 
@@ -529,3 +666,127 @@ func (lb *reqRoundRobinLoadBalancer) IsExistsHosts(metadata api.MetadataMatchCri
 func (lb *reqRoundRobinLoadBalancer) HostNum(metadata api.MetadataMatchCriteria) int {
 	return lb.hosts.Size()
 }
+
+type ringHashEntry struct {
+	hash  uint64
+	index int
+}
+
+// ringHashLoadBalancer is a consistent hash load balancer, the hash is generated by the route hash policy,
+// such as the value of a request header. Each host is mapped to virtual nodes in the ring, the number of
+// virtual nodes is proportional to the host weight.
+// If the hash key is absent, the load balancer picks a host randomly.
+type ringHashLoadBalancer struct {
+	hosts types.HostSet
+	ring  []ringHashEntry
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func newRingHashLoadBalancer(info types.ClusterInfo, hosts types.HostSet) types.LoadBalancer {
+	lb := &ringHashLoadBalancer{
+		hosts: hosts,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	ringSize := uint64(defaultMinimumRingSize)
+	if info != nil {
+		if cfg, ok := info.LbConfig().(*v2.RingHashLbConfig); ok && cfg.MinimumRingSize > 0 {
+			ringSize = cfg.MinimumRingSize
+		}
+	}
+	lb.ring = buildHashRing(hosts, ringSize)
+	return lb
+}
+
+func hostRingWeight(host types.Host) uint64 {
+	if w := host.Weight(); w > 0 {
+		return uint64(w)
+	}
+	return 1
+}
+
+func buildHashRing(hosts types.HostSet, minRingSize uint64) []ringHashEntry {
+	total := hosts.Size()
+	if total == 0 {
+		return nil
+	}
+	var totalWeight uint64
+	hosts.Range(func(host types.Host) bool {
+		totalWeight += hostRingWeight(host)
+		return true
+	})
+	ring := make([]ringHashEntry, 0, minRingSize+uint64(total))
+	for i := 0; i < total; i++ {
+		host := hosts.Get(i)
+		// every host has one virtual node at least
+		vnodes := (minRingSize*hostRingWeight(host) + totalWeight - 1) / totalWeight
+		addr := host.AddressString()
+		for j := uint64(0); j < vnodes; j++ {
+			ring = append(ring, ringHashEntry{
+				hash:  siphash.Hash(0xbeefcafebabedead, 0, []byte(addr+"_"+strconv.FormatUint(j, 10))),
+				index: i,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	return ring
+}
+
+func (lb *ringHashLoadBalancer) ChooseHost(ctx types.LoadBalancerContext) types.Host {
+	if len(lb.ring) == 0 {
+		return nil
+	}
+
+	var hash uint64
+	if ctx != nil {
+		if route := ctx.DownstreamRoute(); route != nil && route.RouteRule() != nil {
+			if hashPolicy := route.RouteRule().Policy().HashPolicy(); hashPolicy != nil {
+				hash = hashPolicy.GenerateHash(ctx.DownstreamContext())
+			}
+		}
+	}
+	// zero hash means the hash key is absent
+	if hash == 0 {
+		return lb.randomChooseHost()
+	}
+
+	// find the first virtual node clockwise, skip the unhealthy hosts
+	start := sort.Search(len(lb.ring), func(i int) bool {
+		return lb.ring[i].hash >= hash
+	})
+	for i := 0; i < len(lb.ring); i++ {
+		entry := lb.ring[(start+i)%len(lb.ring)]
+		host := lb.hosts.Get(entry.index)
+		if host.Health() {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[lb] [RingHash] hash %d choose host: %s", hash, host.AddressString())
+			}
+			return host
+		}
+	}
+	return nil
+}
+
+func (lb *ringHashLoadBalancer) randomChooseHost() types.Host {
+	total := lb.hosts.Size()
+	lb.mutex.Lock()
+	start := lb.rand.Intn(total)
+	lb.mutex.Unlock()
+	for i := 0; i < total; i++ {
+		host := lb.hosts.Get((start + i) % total)
+		if host.Health() {
+			return host
+		}
+	}
+	return nil
+}
+
+func (lb *ringHashLoadBalancer) IsExistsHosts(metadata api.MetadataMatchCriteria) bool {
+	return lb.hosts.Size() > 0
+}
+
+func (lb *ringHashLoadBalancer) HostNum(metadata api.MetadataMatchCriteria) int {
+	return lb.hosts.Size()
+}
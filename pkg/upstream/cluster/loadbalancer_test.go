@@ -19,6 +19,8 @@ package cluster
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"sync"
@@ -27,6 +29,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/variable"
 )
@@ -809,3 +812,249 @@ func TestWRRLoadBalancer(t *testing.T) {
 		}
 	}
 }
+
+func weightedHostConfigs(prefix string, weights ...uint32) []v2.Host {
+	hosts := make([]v2.Host, 0, len(weights))
+	for i, w := range weights {
+		hosts = append(hosts, v2.Host{
+			HostConfig: v2.HostConfig{
+				Address: fmt.Sprintf("%s:%d", prefix, 8080+i),
+				Weight:  w,
+			},
+		})
+	}
+	return hosts
+}
+
+func TestNewWLARBalancer(t *testing.T) {
+	balancer := NewLoadBalancer(&clusterInfo{lbType: types.WeightedLeastActiveRequest}, &hostSet{})
+	assert.NotNil(t, balancer)
+	assert.IsType(t, &weightedLeastActiveRequestLoadBalancer{}, balancer)
+	assert.Equal(t, uint32(default_choice), balancer.(*weightedLeastActiveRequestLoadBalancer).choice)
+
+	balancer = NewLoadBalancer(&clusterInfo{
+		lbType:   types.WeightedLeastActiveRequest,
+		lbConfig: &v2.LeastRequestLbConfig{ChoiceCount: 5},
+	}, &hostSet{})
+	assert.Equal(t, uint32(5), balancer.(*weightedLeastActiveRequestLoadBalancer).choice)
+}
+
+func TestWLARChooseHost(t *testing.T) {
+	// the chosen host keeps the request active, so the active requests
+	// of each host should be proportional to its weight
+	testCases := []struct {
+		name    string
+		weights []uint32
+	}{
+		{
+			name:    "mixed weights",
+			weights: []uint32{1, 2, 4, 8},
+		},
+		{
+			name:    "all host weight are equal",
+			weights: []uint32{3, 3, 3, 3},
+		},
+	}
+	for i, tc := range testCases {
+		hosts := createHostsetWithStats(weightedHostConfigs(fmt.Sprintf("127.0.1.%d", i), tc.weights...), fmt.Sprintf("wlar_%d", i))
+		balancer := NewLoadBalancer(&clusterInfo{
+			lbType:   types.WeightedLeastActiveRequest,
+			lbConfig: &v2.LeastRequestLbConfig{ChoiceCount: 8},
+		}, hosts)
+		totalWeight := uint32(0)
+		for _, w := range tc.weights {
+			totalWeight += w
+		}
+		requests := 1500
+		for j := 0; j < requests; j++ {
+			host := balancer.ChooseHost(newMockLbContext(nil))
+			if !assert.NotNil(t, host, tc.name) {
+				return
+			}
+			mockRequest(host, true, 1)
+		}
+		hosts.Range(func(host types.Host) bool {
+			expected := float64(requests) * float64(host.Weight()) / float64(totalWeight)
+			actual := float64(host.HostStats().UpstreamRequestActive.Count())
+			if math.Abs(actual-expected)/expected > 0.2 {
+				t.Errorf("%s: host %s with weight %d, expected active requests about %.0f, but got %.0f",
+					tc.name, host.AddressString(), host.Weight(), expected, actual)
+			}
+			return true
+		})
+	}
+}
+
+func TestWLARChooseHostUnhealthy(t *testing.T) {
+	hosts := createHostsetWithStats(weightedHostConfigs("127.0.2.1", 1, 100), "wlar_unhealthy")
+	balancer := NewLoadBalancer(&clusterInfo{lbType: types.WeightedLeastActiveRequest}, hosts)
+	heavy := hosts.Get(1)
+	heavy.SetHealthFlag(api.FAILED_ACTIVE_HC)
+	defer heavy.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+	for i := 0; i < 100; i++ {
+		host := balancer.ChooseHost(newMockLbContext(nil))
+		assert.Equal(t, hosts.Get(0), host)
+	}
+
+	// test only one host
+	hosts = createHostsetWithStats(weightedHostConfigs("127.0.2.2", 1), "wlar_unhealthy")
+	balancer = NewLoadBalancer(&clusterInfo{lbType: types.WeightedLeastActiveRequest}, hosts)
+	assert.Equal(t, hosts.Get(0), balancer.ChooseHost(nil))
+	hosts.Get(0).SetHealthFlag(api.FAILED_ACTIVE_HC)
+	defer hosts.Get(0).ClearHealthFlag(api.FAILED_ACTIVE_HC)
+	assert.Nil(t, balancer.ChooseHost(nil))
+
+	// test no host
+	balancer = NewLoadBalancer(&clusterInfo{lbType: types.WeightedLeastActiveRequest}, &hostSet{})
+	assert.Nil(t, balancer.ChooseHost(nil))
+}
+
+func BenchmarkWLARChooseHost(b *testing.B) {
+	testCases := []struct {
+		name       string
+		count      int
+		max_weight int
+	}{
+		{
+			name:       "WLAR_10_100",
+			count:      10,
+			max_weight: 100,
+		},
+		{
+			name:       "WLAR_1000_100",
+			count:      1000,
+			max_weight: 100,
+		},
+	}
+	rand := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for _, tc := range testCases {
+		weights := make([]uint32, 0, tc.count)
+		for i := 0; i < tc.count; i++ {
+			weights = append(weights, uint32(1+rand.Intn(tc.max_weight)))
+		}
+		hosts := createHostsetWithStats(weightedHostConfigs("127.0.3.1", weights...), tc.name)
+		lb := newWeightedLeastActiveRequestLoadBalancer(nil, hosts)
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				lb.ChooseHost(nil)
+			}
+		})
+	}
+}
+
+type mockKeyHashPolicy struct {
+	api.HashPolicy
+	key string
+}
+
+func (hp *mockKeyHashPolicy) GenerateHash(context context.Context) uint64 {
+	if hp.key == "" {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(hp.key))
+	return h.Sum64()
+}
+
+func newRingHashLbContext(key string) types.LoadBalancerContext {
+	return &mockLbContext{
+		context: variable.NewVariableContext(context.Background()),
+		route: &mockRoute{
+			routeRule: &mockRouteRule{
+				policy: &mockPolicy{
+					hashPolicy: &mockKeyHashPolicy{key: key},
+				},
+			},
+		},
+	}
+}
+
+func TestNewRingHashBalancer(t *testing.T) {
+	hostSet := getMockHostSet(10)
+	balancer := NewLoadBalancer(&clusterInfo{lbType: types.RingHash}, hostSet)
+	assert.IsType(t, &ringHashLoadBalancer{}, balancer)
+	assert.True(t, len(balancer.(*ringHashLoadBalancer).ring) >= defaultMinimumRingSize)
+
+	balancer = NewLoadBalancer(&clusterInfo{
+		lbType:   types.RingHash,
+		lbConfig: &v2.RingHashLbConfig{MinimumRingSize: 4096},
+	}, hostSet)
+	assert.True(t, len(balancer.(*ringHashLoadBalancer).ring) >= 4096)
+
+	// no host
+	balancer = NewLoadBalancer(&clusterInfo{lbType: types.RingHash}, &mockHostSet{})
+	assert.Nil(t, balancer.ChooseHost(newRingHashLbContext("key")))
+}
+
+func TestRingHashChooseHost(t *testing.T) {
+	hostSet := getMockHostSet(10)
+	lb := newRingHashLoadBalancer(nil, hostSet)
+
+	// the same key maps to the same host
+	chosen := map[string]int{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		host := lb.ChooseHost(newRingHashLbContext(key))
+		if !assert.NotNil(t, host) {
+			return
+		}
+		for j := 0; j < 5; j++ {
+			assert.Equal(t, host.AddressString(), lb.ChooseHost(newRingHashLbContext(key)).AddressString())
+		}
+		chosen[host.AddressString()]++
+	}
+	assert.True(t, len(chosen) > 1, "keys should be distributed to multiple hosts")
+
+	// the hash key is absent, choose randomly
+	chosen = map[string]int{}
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(newRingHashLbContext(""))
+		if !assert.NotNil(t, host) {
+			return
+		}
+		chosen[host.AddressString()]++
+	}
+	assert.True(t, len(chosen) > 1, "hosts should be chosen randomly")
+}
+
+func TestRingHashChooseHostUnhealthy(t *testing.T) {
+	hostSet := getMockHostSet(3)
+	lb := newRingHashLoadBalancer(nil, hostSet)
+	ctx := newRingHashLbContext("user-1")
+	host := lb.ChooseHost(ctx)
+	host.SetHealthFlag(api.FAILED_ACTIVE_HC)
+	defer host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+
+	fallback := lb.ChooseHost(ctx)
+	if assert.NotNil(t, fallback) {
+		assert.NotEqual(t, host.AddressString(), fallback.AddressString())
+		// the fallback host is stable too
+		assert.Equal(t, fallback.AddressString(), lb.ChooseHost(ctx).AddressString())
+	}
+}
+
+func TestRingHashHostRemoval(t *testing.T) {
+	hostSet := getMockHostSet(10)
+	removed := &mockHostSet{
+		hosts: hostSet.hosts[:9],
+	}
+	before := newRingHashLoadBalancer(nil, hostSet)
+	after := newRingHashLoadBalancer(nil, removed)
+
+	keys := 10000
+	remapped := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		hb := before.ChooseHost(newRingHashLbContext(key))
+		ha := after.ChooseHost(newRingHashLbContext(key))
+		if hb.AddressString() != ha.AddressString() {
+			remapped++
+		}
+	}
+	// about 1/10 keys are mapped to the removed host, the others should be mostly kept
+	fraction := float64(remapped) / float64(keys)
+	if fraction > 0.25 {
+		t.Errorf("too many keys are remapped after host removal: %.2f", fraction)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	defaultOverprovisioningFactor = 140
+	// the loads of the localities are refreshed periodically, as the health of the hosts changes
+	localityRefreshInterval = time.Second
+)
+
+// locality is a group of hosts with the same locality metadata
+type locality struct {
+	hosts  types.HostSet
+	lb     types.LoadBalancer
+	weight float64
+}
+
+// availability returns the healthy ratio multiplied by the overprovisioning factor, max 1
+func (l *locality) availability(factor float64) float64 {
+	if l.hosts.Size() == 0 {
+		return 0
+	}
+	healthy := 0
+	l.hosts.Range(func(host types.Host) bool {
+		if host.Health() {
+			healthy++
+		}
+		return true
+	})
+	availability := float64(healthy) / float64(l.hosts.Size()) * factor
+	if availability > 1 {
+		availability = 1
+	}
+	return availability
+}
+
+// localityLoadBalancer prefers the hosts in the same locality as mosn. The local locality takes
+// the requests in proportion to its availability, the rest spill over to the other localities
+// in proportion to their weights multiplied by their availabilities.
+type localityLoadBalancer struct {
+	// the wrapped load balancer chooses from all the hosts if the chosen locality has no healthy host
+	types.LoadBalancer
+	factor  float64
+	local   *locality
+	remotes []*locality
+
+	mutex       sync.Mutex
+	rand        *rand.Rand
+	refreshTime time.Time
+	localLoad   float64
+	// remoteWeights is the cumulative effective weights of the remote localities
+	remoteWeights []float64
+}
+
+// newLocalityLoadBalancer wraps the load balancer if the locality is configured
+// and the hosts are in both the local and the remote localities
+func newLocalityLoadBalancer(info types.ClusterInfo, hosts types.HostSet, lb types.LoadBalancer) types.LoadBalancer {
+	lc, ok := info.(types.LocalityLbCluster)
+	if !ok {
+		return lb
+	}
+	config := lc.LocalityLbConfig()
+	if config == nil || len(config.Locality) == 0 {
+		return lb
+	}
+	keys := make([]string, 0, len(config.Locality))
+	for k := range config.Locality {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	localKey := localityKey(keys, config.Locality)
+
+	groups := map[string][]types.Host{}
+	var order []string
+	hosts.Range(func(host types.Host) bool {
+		key := localityKey(keys, host.Metadata())
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], host)
+		return true
+	})
+	if _, ok := groups[localKey]; !ok || len(groups) == 1 {
+		return lb
+	}
+
+	factor := config.OverprovisioningFactor
+	if factor == 0 {
+		factor = defaultOverprovisioningFactor
+	}
+	llb := &localityLoadBalancer{
+		LoadBalancer: lb,
+		factor:       float64(factor) / 100,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, key := range order {
+		l := &locality{
+			hosts: NewNoDistinctHostSet(groups[key]),
+		}
+		l.lb = NewLoadBalancer(info, l.hosts)
+		for _, host := range groups[key] {
+			l.weight += float64(host.Weight())
+		}
+		if l.weight <= 0 {
+			l.weight = float64(len(groups[key]))
+		}
+		if key == localKey {
+			llb.local = l
+		} else {
+			llb.remotes = append(llb.remotes, l)
+		}
+	}
+	llb.remoteWeights = make([]float64, len(llb.remotes))
+	return llb
+}
+
+// localityKey joins the values of the keys in order
+func localityKey(keys []string, values map[string]string) string {
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(values[k])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+func (lb *localityLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	if host := lb.chooseLocality().lb.ChooseHost(context); host != nil {
+		return host
+	}
+	return lb.LoadBalancer.ChooseHost(context)
+}
+
+func (lb *localityLoadBalancer) chooseLocality() *locality {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	if time.Since(lb.refreshTime) >= localityRefreshInterval {
+		lb.refresh()
+	}
+	if lb.rand.Float64() < lb.localLoad {
+		return lb.local
+	}
+	total := lb.remoteWeights[len(lb.remoteWeights)-1]
+	r := lb.rand.Float64() * total
+	idx := sort.SearchFloat64s(lb.remoteWeights, r)
+	for idx < len(lb.remotes)-1 && lb.remoteWeights[idx] <= r {
+		idx++
+	}
+	return lb.remotes[idx]
+}
+
+// refresh computes the loads of the localities by the health of the hosts
+func (lb *localityLoadBalancer) refresh() {
+	lb.refreshTime = time.Now()
+	total := 0.0
+	for i, l := range lb.remotes {
+		total += l.weight * l.availability(lb.factor)
+		lb.remoteWeights[i] = total
+	}
+	lb.localLoad = lb.local.availability(lb.factor)
+	// all the requests stay local if no remote host is healthy
+	if total == 0 {
+		lb.localLoad = 1
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+// zoneHosts creates count hosts in the zone
+func zoneHosts(zone string, count int) []types.Host {
+	hosts := make([]types.Host, 0, count)
+	for i := 0; i < count; i++ {
+		host := &mockHost{
+			addr: fmt.Sprintf("%s:%d", zone, 8080+i),
+			meta: api.Metadata{"region": "r1", "zone": zone},
+			w:    1,
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func localityClusterInfo() *clusterInfo {
+	return &clusterInfo{
+		name:   "locality",
+		lbType: types.RoundRobin,
+		localityLbConfig: &v2.LocalityLbConfig{
+			Locality: map[string]string{"region": "r1", "zone": "zone-a"},
+		},
+	}
+}
+
+func TestNewLocalityLoadBalancer(t *testing.T) {
+	var hosts []types.Host
+	hosts = append(hosts, zoneHosts("zone-a", 2)...)
+	hosts = append(hosts, zoneHosts("zone-b", 2)...)
+	hs := NewHostSet(hosts)
+	lb := NewLoadBalancer(localityClusterInfo(), hs)
+
+	// no locality config
+	assert.Equal(t, lb, newLocalityLoadBalancer(&clusterInfo{}, hs, lb))
+	// no local hosts
+	info := localityClusterInfo()
+	info.localityLbConfig.Locality["zone"] = "zone-c"
+	assert.Equal(t, lb, newLocalityLoadBalancer(info, hs, lb))
+	// only local hosts
+	local := NewHostSet(zoneHosts("zone-a", 2))
+	assert.Equal(t, lb, newLocalityLoadBalancer(localityClusterInfo(), local, NewLoadBalancer(localityClusterInfo(), local)))
+
+	llb, ok := newLocalityLoadBalancer(localityClusterInfo(), hs, lb).(*localityLoadBalancer)
+	assert.True(t, ok)
+	assert.Equal(t, 2, llb.local.hosts.Size())
+	assert.Len(t, llb.remotes, 1)
+	assert.Equal(t, 1.4, llb.factor)
+	assert.Equal(t, 4, llb.HostNum(nil))
+}
+
+func TestLocalityLoadBalancerChooseHost(t *testing.T) {
+	local := zoneHosts("zone-a", 4)
+	remoteB := zoneHosts("zone-b", 4)
+	remoteC := zoneHosts("zone-c", 2)
+	var hosts []types.Host
+	hosts = append(hosts, local...)
+	hosts = append(hosts, remoteB...)
+	hosts = append(hosts, remoteC...)
+	hs := NewHostSet(hosts)
+	info := localityClusterInfo()
+	lb := newLocalityLoadBalancer(info, hs, NewLoadBalancer(info, hs)).(*localityLoadBalancer)
+
+	// countZones returns the ratio of the requests each zone takes
+	countZones := func() map[string]float64 {
+		// refresh the loads immediately
+		lb.refreshTime = time.Time{}
+		counts := map[string]float64{}
+		requests := 10000
+		for i := 0; i < requests; i++ {
+			host := lb.ChooseHost(newMockLbContext(nil))
+			if !assert.NotNil(t, host) {
+				return counts
+			}
+			counts[host.Metadata()["zone"]] += 1 / float64(requests)
+		}
+		return counts
+	}
+
+	// all the requests stay local
+	ratios := countZones()
+	assert.InDelta(t, 1.0, ratios["zone-a"], 0.001)
+
+	// 3/4 of the local hosts are healthy, 3/4 * 1.4 > 1, no spill over
+	local[0].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	ratios = countZones()
+	assert.InDelta(t, 1.0, ratios["zone-a"], 0.001)
+
+	// 1/2 of the local hosts are healthy, 70% local, the rest spill over by the weights of the remote zones
+	local[1].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	ratios = countZones()
+	assert.InDelta(t, 0.7, ratios["zone-a"], 0.03)
+	assert.InDelta(t, 0.2, ratios["zone-b"], 0.03)
+	assert.InDelta(t, 0.1, ratios["zone-c"], 0.03)
+
+	// the availability of the remote zones is considered too
+	remoteB[0].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	remoteB[1].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	remoteB[2].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	ratios = countZones()
+	assert.InDelta(t, 0.7, ratios["zone-a"], 0.03)
+	assert.InDelta(t, 0.3*(4*0.35)/(4*0.35+2), ratios["zone-b"], 0.03)
+
+	// no local host is healthy, all the requests fail over to the remote zones
+	local[2].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	local[3].SetHealthFlag(api.FAILED_ACTIVE_HC)
+	ratios = countZones()
+	assert.Equal(t, 0.0, ratios["zone-a"])
+
+	// the hosts recover
+	for _, host := range hosts {
+		host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+	}
+	ratios = countZones()
+	assert.InDelta(t, 1.0, ratios["zone-a"], 0.001)
+}
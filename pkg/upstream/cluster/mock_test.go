@@ -172,6 +172,7 @@ func makePool(size int) *ipPool {
 type mockConnPool struct {
 	host      atomic.Value
 	hashvalue *types.HashValue
+	closed    uint32
 	types.ConnectionPool
 }
 
@@ -193,6 +194,7 @@ func (p *mockConnPool) Shutdown() {
 }
 
 func (p *mockConnPool) Close() {
+	atomic.StoreUint32(&p.closed, 1)
 }
 
 func (p *mockConnPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener) (types.Host, types.StreamSender, types.PoolFailureReason) {
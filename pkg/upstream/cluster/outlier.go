@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+// default outlier detection config
+const (
+	defaultConsecutive5xx     = 5
+	defaultOutlierInterval    = 10 * time.Second
+	defaultBaseEjectionTime   = 30 * time.Second
+	defaultMaxEjectionTime    = 300 * time.Second
+	defaultMaxEjectionPercent = 10
+)
+
+type outlierHostState struct {
+	host types.Host
+	// consecutive failures since last success
+	consecutiveFailures uint32
+	// ejected times, the ejection time grows exponentially with it
+	ejections  uint32
+	ejected    bool
+	ejectUntil time.Time
+}
+
+// outlierDetector ejects the hosts with consecutive failures, the ejected hosts are
+// marked as api.FAILED_OUTLIER_CHECK, and re-admitted after the ejection time.
+type outlierDetector struct {
+	consecutive5xx     uint32
+	interval           time.Duration
+	baseEjectionTime   time.Duration
+	maxEjectionTime    time.Duration
+	maxEjectionPercent uint32
+
+	mutex    sync.Mutex
+	hostSet  types.HostSet
+	hosts    map[string]*outlierHostState
+	ejecting int
+	now      func() time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func newOutlierDetector(cfg *v2.OutlierDetection) *outlierDetector {
+	od := &outlierDetector{
+		consecutive5xx:     cfg.Consecutive5xx,
+		interval:           cfg.IntervalConfig.Duration,
+		baseEjectionTime:   cfg.BaseEjectionTimeConfig.Duration,
+		maxEjectionTime:    cfg.MaxEjectionTimeConfig.Duration,
+		maxEjectionPercent: cfg.MaxEjectionPercent,
+		hosts:              map[string]*outlierHostState{},
+		now:                time.Now,
+		stop:               make(chan struct{}),
+	}
+	if od.consecutive5xx == 0 {
+		od.consecutive5xx = defaultConsecutive5xx
+	}
+	if od.interval <= 0 {
+		od.interval = defaultOutlierInterval
+	}
+	if od.baseEjectionTime <= 0 {
+		od.baseEjectionTime = defaultBaseEjectionTime
+	}
+	if od.maxEjectionTime < od.baseEjectionTime {
+		od.maxEjectionTime = defaultMaxEjectionTime
+		if od.maxEjectionTime < od.baseEjectionTime {
+			od.maxEjectionTime = od.baseEjectionTime
+		}
+	}
+	if od.maxEjectionPercent == 0 || od.maxEjectionPercent > 100 {
+		od.maxEjectionPercent = defaultMaxEjectionPercent
+	}
+	return od
+}
+
+// Start runs the re-admit check every interval
+func (od *outlierDetector) Start() {
+	utils.GoWithRecover(func() {
+		ticker := time.NewTicker(od.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-od.stop:
+				return
+			case <-ticker.C:
+				od.check()
+			}
+		}
+	}, nil)
+}
+
+func (od *outlierDetector) Stop() {
+	od.once.Do(func() {
+		close(od.stop)
+	})
+}
+
+func (od *outlierDetector) SetHostSet(hostSet types.HostSet) {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+	od.hostSet = hostSet
+	hosts := make(map[string]*outlierHostState, hostSet.Size())
+	ejecting := 0
+	hostSet.Range(func(host types.Host) bool {
+		addr := host.AddressString()
+		if state, ok := od.hosts[addr]; ok {
+			state.host = host
+			hosts[addr] = state
+			if state.ejected {
+				ejecting++
+			}
+		} else {
+			hosts[addr] = &outlierHostState{host: host}
+		}
+		return true
+	})
+	// re-admit the removed hosts
+	for addr, state := range od.hosts {
+		if _, ok := hosts[addr]; !ok && state.ejected {
+			state.host.ClearHealthFlag(api.FAILED_OUTLIER_CHECK)
+		}
+	}
+	od.hosts = hosts
+	od.ejecting = ejecting
+}
+
+func (od *outlierDetector) PutResult(host types.Host, success bool) {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+	state, ok := od.hosts[host.AddressString()]
+	if !ok || state.ejected {
+		return
+	}
+	if success {
+		state.consecutiveFailures = 0
+		return
+	}
+	state.consecutiveFailures++
+	if state.consecutiveFailures >= od.consecutive5xx {
+		od.eject(state)
+	}
+}
+
+// eject the host if the ejected hosts do not exceed the max ejection percent
+func (od *outlierDetector) eject(state *outlierHostState) {
+	if (od.ejecting+1)*100 > int(od.maxEjectionPercent)*len(od.hosts) {
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[upstream] [outlier] host %s is not ejected, max ejection percent %d reached",
+				state.host.AddressString(), od.maxEjectionPercent)
+		}
+		return
+	}
+	state.ejections++
+	state.ejected = true
+	state.consecutiveFailures = 0
+	state.ejectUntil = od.now().Add(od.ejectionTime(state.ejections))
+	od.ejecting++
+	state.host.SetHealthFlag(api.FAILED_OUTLIER_CHECK)
+	log.DefaultLogger.Warnf("[upstream] [outlier] host %s is ejected until %s, ejections: %d",
+		state.host.AddressString(), state.ejectUntil, state.ejections)
+}
+
+// ejectionTime is base ejection time * 2^(ejections-1), and no more than the max ejection time
+func (od *outlierDetector) ejectionTime(ejections uint32) time.Duration {
+	d := od.baseEjectionTime
+	for i := uint32(1); i < ejections && d < od.maxEjectionTime; i++ {
+		d *= 2
+	}
+	if d > od.maxEjectionTime {
+		d = od.maxEjectionTime
+	}
+	return d
+}
+
+// check re-admits the hosts whose ejection time is passed, and decreases the ejections
+// of the hosts that work well, so the ejection time backs off if the host is recovered
+func (od *outlierDetector) check() {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+	now := od.now()
+	for _, state := range od.hosts {
+		if !state.ejected {
+			if state.ejections > 0 && state.consecutiveFailures == 0 {
+				state.ejections--
+			}
+			continue
+		}
+		if now.Before(state.ejectUntil) {
+			continue
+		}
+		state.ejected = false
+		od.ejecting--
+		state.host.ClearHealthFlag(api.FAILED_OUTLIER_CHECK)
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[upstream] [outlier] host %s is re-admitted", state.host.AddressString())
+		}
+	}
+}
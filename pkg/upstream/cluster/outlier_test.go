@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+// newOutlierHostSet creates hosts with unique addresses, so the global health flags are not shared with other tests
+func newOutlierHostSet(prefix string, count int) *mockHostSet {
+	set := &mockHostSet{}
+	for i := 0; i < count; i++ {
+		set.hosts = append(set.hosts, &mockHost{
+			name:    fmt.Sprintf("host-%d", i),
+			addr:    fmt.Sprintf("outlier-%s-%d:8080", prefix, i),
+			hostSet: set,
+		})
+	}
+	return set
+}
+
+func newTestOutlierDetector(hs types.HostSet, cfg *v2.OutlierDetection) (*outlierDetector, *time.Time) {
+	now := time.Now()
+	od := newOutlierDetector(cfg)
+	od.now = func() time.Time {
+		return now
+	}
+	od.SetHostSet(hs)
+	return od, &now
+}
+
+func putResults(od *outlierDetector, host types.Host, success bool, count int) {
+	for i := 0; i < count; i++ {
+		od.PutResult(host, success)
+	}
+}
+
+func TestNewOutlierDetectorDefault(t *testing.T) {
+	od := newOutlierDetector(&v2.OutlierDetection{})
+	if !(od.consecutive5xx == defaultConsecutive5xx &&
+		od.interval == defaultOutlierInterval &&
+		od.baseEjectionTime == defaultBaseEjectionTime &&
+		od.maxEjectionTime == defaultMaxEjectionTime &&
+		od.maxEjectionPercent == defaultMaxEjectionPercent) {
+		t.Fatalf("unexpected default config: %+v", od)
+	}
+}
+
+func TestOutlierDetectorEject(t *testing.T) {
+	hs := newOutlierHostSet("eject", 10)
+	od, _ := newTestOutlierDetector(hs, &v2.OutlierDetection{
+		Consecutive5xx: 3,
+	})
+	host := hs.Get(0)
+	putResults(od, host, false, 2)
+	if !host.Health() {
+		t.Fatal("host should not be ejected before reaching consecutive 5xx")
+	}
+	// a success resets the consecutive failures
+	od.PutResult(host, true)
+	putResults(od, host, false, 2)
+	if !host.Health() {
+		t.Fatal("host should not be ejected after failures are reset")
+	}
+	od.PutResult(host, false)
+	if host.Health() || host.HealthFlag()&api.FAILED_OUTLIER_CHECK == 0 {
+		t.Fatal("host should be ejected")
+	}
+	// other hosts are not affected
+	if !hs.Get(1).Health() {
+		t.Fatal("other hosts should be healthy")
+	}
+}
+
+func TestOutlierDetectorMaxEjectionPercent(t *testing.T) {
+	hs := newOutlierHostSet("percent", 10)
+	od, _ := newTestOutlierDetector(hs, &v2.OutlierDetection{
+		Consecutive5xx:     1,
+		MaxEjectionPercent: 30,
+	})
+	// all hosts are failed, but at most 30% of the hosts can be ejected
+	for _, host := range hs.Hosts() {
+		putResults(od, host, false, 5)
+	}
+	ejected := 0
+	for _, host := range hs.Hosts() {
+		if !host.Health() {
+			ejected++
+		}
+	}
+	if ejected != 3 {
+		t.Fatalf("expected 3 hosts ejected, but got %d", ejected)
+	}
+}
+
+func TestOutlierDetectorReadmit(t *testing.T) {
+	hs := newOutlierHostSet("readmit", 10)
+	od, now := newTestOutlierDetector(hs, &v2.OutlierDetection{
+		Consecutive5xx:         1,
+		BaseEjectionTimeConfig: api.DurationConfig{Duration: 10 * time.Second},
+		MaxEjectionTimeConfig:  api.DurationConfig{Duration: 30 * time.Second},
+	})
+	host := hs.Get(0)
+	// expected ejection time grows exponentially and is limited by max ejection time
+	for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		od.PutResult(host, false)
+		if host.Health() {
+			t.Fatal("host should be ejected")
+		}
+		*now = now.Add(expected - time.Second)
+		od.check()
+		if host.Health() {
+			t.Fatalf("host should be ejected before %s", expected)
+		}
+		*now = now.Add(time.Second)
+		od.check()
+		if !host.Health() {
+			t.Fatalf("host should be re-admitted after %s", expected)
+		}
+	}
+	// the ejections decrease when the host works well
+	for i := 0; i < 10; i++ {
+		od.check()
+	}
+	od.PutResult(host, false)
+	*now = now.Add(10 * time.Second)
+	od.check()
+	if !host.Health() {
+		t.Fatal("host should be re-admitted after base ejection time")
+	}
+}
+
+func TestOutlierDetectorHostRemoved(t *testing.T) {
+	hs := newOutlierHostSet("removed", 10)
+	od, _ := newTestOutlierDetector(hs, &v2.OutlierDetection{
+		Consecutive5xx: 1,
+	})
+	host := hs.Get(0)
+	od.PutResult(host, false)
+	if host.Health() {
+		t.Fatal("host should be ejected")
+	}
+	od.SetHostSet(&mockHostSet{hosts: hs.hosts[1:]})
+	if !host.Health() {
+		t.Fatal("removed host should be re-admitted")
+	}
+	// results of the removed host are ignored
+	od.PutResult(host, false)
+	if !host.Health() {
+		t.Fatal("removed host should not be ejected")
+	}
+}
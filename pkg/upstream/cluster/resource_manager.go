@@ -32,12 +32,18 @@ const (
 	DefaultMaxRetries         uint64 = 0
 )
 
+// default value of the retry budget
+const (
+	DefaultRetryBudgetPercent        float64 = 20
+	DefaultRetryBudgetMinConcurrency uint64  = 3
+)
+
 // ResourceManager
 type resourcemanager struct {
 	connections     *resource
 	pendingRequests *resource
 	requests        *resource
-	retries         *resource
+	retries         types.Resource
 }
 
 func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManager {
@@ -45,6 +51,7 @@ func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManage
 	maxPendingRequests := DefaultMaxPendingRequests
 	maxRequests := DefaultMaxRequests
 	maxRetries := DefaultMaxRetries
+	var retryBudget *v2.RetryBudget
 
 	// note: we don't support group cb by priority
 	if circuitBreakers.Thresholds != nil && len(circuitBreakers.Thresholds) > 0 {
@@ -52,9 +59,10 @@ func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManage
 		maxPendingRequests = uint64(circuitBreakers.Thresholds[0].MaxPendingRequests)
 		maxRequests = uint64(circuitBreakers.Thresholds[0].MaxRequests)
 		maxRetries = uint64(circuitBreakers.Thresholds[0].MaxRetries)
+		retryBudget = circuitBreakers.Thresholds[0].RetryBudget
 	}
 
-	return &resourcemanager{
+	rm := &resourcemanager{
 		connections: &resource{
 			max: maxConnections,
 		},
@@ -68,6 +76,13 @@ func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManage
 			max: maxRetries,
 		},
 	}
+	// the retry budget takes precedence over the max retries
+	if retryBudget != nil {
+		// the active requests are counted even if the max requests is not limited
+		rm.requests.counted = true
+		rm.retries = newRetryBudget(retryBudget, rm.requests)
+	}
+	return rm
 }
 
 func (rm *resourcemanager) Connections() types.Resource {
@@ -93,13 +108,34 @@ func updateResourceValue(oldRM, newRM types.ResourceManager) {
 	orm.connections.max = nrm.connections.max
 	orm.pendingRequests.max = nrm.pendingRequests.max
 	orm.requests.max = nrm.requests.max
-	orm.retries.max = nrm.retries.max
+	orm.requests.counted = nrm.requests.counted
+	// keep the active retries of the old resource
+	switch nr := nrm.retries.(type) {
+	case *resource:
+		if or, ok := orm.retries.(*resource); ok {
+			or.max = nr.max
+		} else {
+			nr.UpdateCur(orm.retries.Cur())
+			orm.retries = nr
+		}
+	case *retryBudget:
+		nr.requests = orm.requests
+		if ob, ok := orm.retries.(*retryBudget); ok {
+			ob.percent = nr.percent
+			ob.minConcurrency = nr.minConcurrency
+		} else {
+			nr.UpdateCur(orm.retries.Cur())
+			orm.retries = nr
+		}
+	}
 }
 
 // Resource
 type resource struct {
 	current int64
 	max     uint64
+	// counted makes the resource counted even if the max is zero
+	counted bool
 }
 
 func (r *resource) CanCreate() bool {
@@ -116,13 +152,13 @@ func (r *resource) CanCreate() bool {
 }
 
 func (r *resource) Increase() {
-	if r.max != 0 {
+	if r.max != 0 || r.counted {
 		atomic.AddInt64(&r.current, 1)
 	}
 }
 
 func (r *resource) Decrease() {
-	if r.max != 0 {
+	if r.max != 0 || r.counted {
 		atomic.AddInt64(&r.current, -1)
 	}
 }
@@ -138,3 +174,63 @@ func (r *resource) Cur() int64 {
 func (r *resource) UpdateCur(cur int64) {
 	r.current = cur
 }
+
+// retryBudget limits the active retries to a percentage of the active requests,
+// a retry is allowed only if the active retries are less than the budget.
+type retryBudget struct {
+	current        int64 // active retries
+	requests       *resource
+	percent        float64
+	minConcurrency uint64
+}
+
+func newRetryBudget(cfg *v2.RetryBudget, requests *resource) *retryBudget {
+	b := &retryBudget{
+		requests:       requests,
+		percent:        cfg.BudgetPercent,
+		minConcurrency: uint64(cfg.MinRetryConcurrency),
+	}
+	if b.percent <= 0 {
+		b.percent = DefaultRetryBudgetPercent
+	}
+	if b.minConcurrency == 0 {
+		b.minConcurrency = DefaultRetryBudgetMinConcurrency
+	}
+	return b
+}
+
+func (b *retryBudget) CanCreate() bool {
+	curValue := atomic.LoadInt64(&b.current)
+	if curValue < 0 {
+		return true
+	}
+	return uint64(curValue) < b.Max()
+}
+
+func (b *retryBudget) Increase() {
+	atomic.AddInt64(&b.current, 1)
+}
+
+func (b *retryBudget) Decrease() {
+	atomic.AddInt64(&b.current, -1)
+}
+
+// Max returns the current budget of the active retries
+func (b *retryBudget) Max() uint64 {
+	var budget uint64
+	if requests := atomic.LoadInt64(&b.requests.current); requests > 0 {
+		budget = uint64(float64(requests) * b.percent / 100)
+	}
+	if budget < b.minConcurrency {
+		budget = b.minConcurrency
+	}
+	return budget
+}
+
+func (b *retryBudget) Cur() int64 {
+	return atomic.LoadInt64(&b.current)
+}
+
+func (b *retryBudget) UpdateCur(cur int64) {
+	atomic.StoreInt64(&b.current, cur)
+}
@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"math"
+	"time"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	defaultSlowStartMinWeightPercent = 10
+	defaultSlowStartAggression       = 1
+)
+
+// slowStart ramps up the weight of the host in the window after the host is added or becomes healthy,
+// a nil slowStart means the slow start is disabled.
+type slowStart struct {
+	window     time.Duration
+	minFactor  float64
+	aggression float64
+}
+
+func newSlowStart(info types.ClusterInfo) *slowStart {
+	if info == nil {
+		return nil
+	}
+	ssc, ok := info.(types.SlowStartCluster)
+	if !ok {
+		return nil
+	}
+	cfg := ssc.SlowStart()
+	if cfg == nil || cfg.SlowStartWindow == nil || cfg.SlowStartWindow.Duration <= 0 {
+		return nil
+	}
+	s := &slowStart{
+		window:     cfg.SlowStartWindow.Duration,
+		minFactor:  defaultSlowStartMinWeightPercent / 100.0,
+		aggression: defaultSlowStartAggression,
+	}
+	if cfg.MinWeightPercent > 0 && cfg.MinWeightPercent <= 100 {
+		s.minFactor = cfg.MinWeightPercent / 100
+	}
+	if cfg.Aggression > 0 {
+		s.aggression = cfg.Aggression
+	}
+	return s
+}
+
+// weight returns the effective weight of the host
+func (s *slowStart) weight(host types.Host) float64 {
+	weight := float64(host.Weight())
+	if s == nil {
+		return weight
+	}
+	ssh, ok := host.(types.SlowStartHost)
+	if !ok {
+		return weight
+	}
+	elapsed := time.Since(ssh.HealthyTime())
+	if elapsed >= s.window {
+		return weight
+	}
+	factor := 0.0
+	if elapsed > 0 {
+		factor = math.Pow(float64(elapsed)/float64(s.window), 1/s.aggression)
+	}
+	if factor < s.minFactor {
+		factor = s.minFactor
+	}
+	return weight * factor
+}
+
+// lessEffectiveLoad is the same as the lessEffectiveLoad but takes the effective weight
+func (s *slowStart) lessEffectiveLoad(a, b types.Host) bool {
+	if s == nil {
+		return lessEffectiveLoad(a, b)
+	}
+	wa, wb := s.weight(a), s.weight(b)
+	if wa <= 0 {
+		wa = 1
+	}
+	if wb <= 0 {
+		wb = 1
+	}
+	la := float64(a.HostStats().UpstreamRequestActive.Count() + 1)
+	lb := float64(b.HostStats().UpstreamRequestActive.Count() + 1)
+	return la*wb < lb*wa
+}
+
+// inheritHealthyTime makes the hosts kept in the cluster continue their slow start,
+// the hosts are created again when the cluster hosts are updated.
+func inheritHealthyTime(oldHosts, newHosts types.HostSet) {
+	if oldHosts == nil || oldHosts.Size() == 0 {
+		return
+	}
+	times := make(map[string]time.Time, oldHosts.Size())
+	oldHosts.Range(func(host types.Host) bool {
+		if ssh, ok := host.(types.SlowStartHost); ok {
+			times[host.AddressString()] = ssh.HealthyTime()
+		}
+		return true
+	})
+	newHosts.Range(func(host types.Host) bool {
+		if ssh, ok := host.(types.SlowStartHost); ok {
+			if t, ok := times[host.AddressString()]; ok && t.Before(ssh.HealthyTime()) {
+				ssh.SetHealthyTime(t)
+			}
+		}
+		return true
+	})
+}
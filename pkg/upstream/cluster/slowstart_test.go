@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cluster
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func slowStartClusterInfo(lbType types.LoadBalancerType, window time.Duration) *clusterInfo {
+	return &clusterInfo{
+		name:   "slow_start",
+		lbType: lbType,
+		slowStart: &v2.SlowStartConfig{
+			SlowStartWindow: &api.DurationConfig{Duration: window},
+		},
+	}
+}
+
+func TestSlowStartWeight(t *testing.T) {
+	assert.Nil(t, newSlowStart(nil))
+	assert.Nil(t, newSlowStart(&clusterInfo{}))
+	assert.Nil(t, newSlowStart(&clusterInfo{slowStart: &v2.SlowStartConfig{}}))
+
+	info := slowStartClusterInfo(types.WeightedRoundRobin, 10*time.Second)
+	s := newSlowStart(info)
+	host := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: "127.0.3.1:8080", Weight: 100}}, info)
+	ssh := host.(types.SlowStartHost)
+
+	now := time.Now()
+	ssh.SetHealthyTime(now)
+	assert.InDelta(t, 10, s.weight(host), 1) // min weight
+	ssh.SetHealthyTime(now.Add(-5 * time.Second))
+	assert.InDelta(t, 50, s.weight(host), 1)
+	ssh.SetHealthyTime(now.Add(-20 * time.Second))
+	assert.Equal(t, float64(100), s.weight(host))
+
+	info.slowStart.MinWeightPercent = 50
+	info.slowStart.Aggression = 2
+	s = newSlowStart(info)
+	ssh.SetHealthyTime(now.Add(-time.Second))
+	assert.InDelta(t, 50, s.weight(host), 1)
+	ssh.SetHealthyTime(now.Add(-5 * time.Second))
+	assert.InDelta(t, 100*math.Sqrt(0.5), s.weight(host), 1)
+
+	// the slow start is disabled
+	var disabled *slowStart
+	assert.Equal(t, float64(100), disabled.weight(host))
+}
+
+func TestSlowStartHealthyTime(t *testing.T) {
+	info := slowStartClusterInfo(types.WeightedRoundRobin, time.Minute)
+	host := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: "127.0.3.2:8080"}}, info)
+	ssh := host.(types.SlowStartHost)
+	past := time.Now().Add(-time.Hour)
+	ssh.SetHealthyTime(past)
+	// the healthy time is not changed if the host is healthy already
+	host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+	assert.Equal(t, past.UnixNano(), ssh.HealthyTime().UnixNano())
+	// the slow start begins again if the host becomes healthy
+	host.SetHealthFlag(api.FAILED_ACTIVE_HC)
+	host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+	assert.True(t, ssh.HealthyTime().After(past))
+
+	// the hosts kept in the cluster continue their slow start
+	clusterConfig := v2.Cluster{
+		Name:        "slow_start_update",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+	}
+	c := NewCluster(clusterConfig)
+	NewSimpleHostHandler(c, weightedHostConfigs("127.0.3.3", 1))
+	kept := c.Snapshot().HostSet().Get(0)
+	kept.(types.SlowStartHost).SetHealthyTime(past)
+	NewSimpleHostHandler(c, weightedHostConfigs("127.0.3.3", 1, 1))
+	hs := c.Snapshot().HostSet()
+	assert.NotEqual(t, kept, hs.Get(0))
+	assert.Equal(t, past.UnixNano(), hs.Get(0).(types.SlowStartHost).HealthyTime().UnixNano())
+	assert.True(t, hs.Get(1).(types.SlowStartHost).HealthyTime().After(past))
+}
+
+func TestSlowStartLoadBalancer(t *testing.T) {
+	for _, lbType := range []types.LoadBalancerType{
+		types.WeightedRoundRobin,
+		types.LeastActiveRequest,
+		types.WeightedLeastActiveRequest,
+	} {
+		info := slowStartClusterInfo(lbType, time.Minute)
+		info.lbConfig = &v2.LeastRequestLbConfig{ChoiceCount: 8}
+		var hosts []types.Host
+		for _, cfg := range weightedHostConfigs("127.0.4.1", 10, 10) {
+			hosts = append(hosts, NewSimpleHost(cfg, info))
+		}
+		now := time.Now()
+		hosts[0].(types.SlowStartHost).SetHealthyTime(now.Add(-time.Hour))
+		fresh := hosts[1]
+		hs := &hostSet{}
+		hs.setFinalHost(hosts)
+		lb := NewLoadBalancer(info, hs)
+
+		// countFresh returns the ratio of the requests the fresh host takes
+		countFresh := func() float64 {
+			count := 0
+			requests := 1000
+			for i := 0; i < requests; i++ {
+				host := lb.ChooseHost(newMockLbContext(nil))
+				if host == fresh {
+					count++
+				}
+				// keep the requests active, so the least active request load balancers choose by the weight
+				mockRequest(host, true, 1)
+			}
+			hs.Range(func(host types.Host) bool {
+				host.HostStats().UpstreamRequestActive.Clear()
+				return true
+			})
+			return float64(count) / float64(requests)
+		}
+
+		// the fresh host starts with the min weight 1, so it takes about 1/11 of the requests
+		fresh.(types.SlowStartHost).SetHealthyTime(now)
+		starting := countFresh()
+		assert.InDelta(t, 1.0/11, starting, 0.05, lbType)
+
+		// the weight of the fresh host grows to 5 in the half of the window
+		fresh.(types.SlowStartHost).SetHealthyTime(now.Add(-30 * time.Second))
+		growing := countFresh()
+		assert.InDelta(t, 5.0/15, growing, 0.05, lbType)
+		assert.True(t, growing > starting, lbType)
+
+		// the fresh host takes the full weight after the window
+		fresh.(types.SlowStartHost).SetHealthyTime(now.Add(-time.Hour))
+		assert.InDelta(t, 0.5, countFresh(), 0.05, lbType)
+	}
+}
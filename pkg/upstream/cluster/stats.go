@@ -79,5 +79,15 @@ func newClusterStats(clustername string) types.ClusterStats {
 		UpstreamResponseFailed:                         s.Counter(metrics.UpstreamResponseFailed),
 		LBSubSetsFallBack:                              s.Counter(metrics.UpstreamLBSubSetsFallBack),
 		LBSubsetsCreated:                               s.Gauge(metrics.UpstreamLBSubsetsCreated),
+		UpstreamConnectionIdle:                         s.Counter(metrics.UpstreamConnectionIdle),
+		UpstreamRequestPending:                         s.Counter(metrics.UpstreamRequestPending),
+		UpstreamRequestCircuitBreakerOpen:              s.Counter(metrics.UpstreamRequestCircuitBreakerOpen),
+		UpstreamRequestConcurrencyLimited:              s.Counter(metrics.UpstreamRequestConcurrencyLimited),
+		UpstreamDnsResolveFailure:                      s.Counter(metrics.UpstreamDnsResolveFailure),
+		UpstreamConnectionHostOverflow:                 s.Counter(metrics.UpstreamConnectionHostOverflow),
+		UpstreamRequestMaintenanceMode:                 s.Counter(metrics.UpstreamRequestMaintenanceMode),
+		UpstreamRequestAdmissionRejected:               s.Counter(metrics.UpstreamRequestAdmissionRejected),
+		UpstreamConnectionGoAway:                       s.Counter(metrics.UpstreamConnectionGoAway),
+		UpstreamRequestConnectFailureRetry:             s.Counter(metrics.UpstreamRequestConnectFailureRetry),
 	}
 }
@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// stickySessionLoadBalancer chooses the host encoded in the session cookie if it is still healthy,
+// otherwise a host is chosen by the wrapped load balancer and the cookie of the host is set on the response.
+type stickySessionLoadBalancer struct {
+	types.LoadBalancer
+	config *v2.StickySessionConfig
+	// hosts maps the cookie value to the host
+	hosts map[string]types.Host
+}
+
+// newStickySessionLoadBalancer wraps the load balancer if the sticky session is enabled
+func newStickySessionLoadBalancer(info types.ClusterInfo, hosts types.HostSet, lb types.LoadBalancer) types.LoadBalancer {
+	sc, ok := info.(types.StickySessionCluster)
+	if !ok {
+		return lb
+	}
+	config := sc.StickySessionConfig()
+	if config == nil || config.CookieName == "" {
+		return lb
+	}
+	sticky := &stickySessionLoadBalancer{
+		LoadBalancer: lb,
+		config:       config,
+		hosts:        make(map[string]types.Host, hosts.Size()),
+	}
+	hosts.Range(func(host types.Host) bool {
+		sticky.hosts[stickySessionCookieValue(host)] = host
+		return true
+	})
+	return sticky
+}
+
+func (lb *stickySessionLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	if context == nil || context.DownstreamContext() == nil {
+		return lb.LoadBalancer.ChooseHost(context)
+	}
+	ctx := context.DownstreamContext()
+	if value, err := variable.GetProtocolResource(ctx, api.COOKIE, lb.config.CookieName); err == nil && value != "" {
+		if host, ok := lb.hosts[value]; ok && host.Health() {
+			return host
+		}
+	}
+	// the host in the cookie is gone, rehome the session
+	host := lb.LoadBalancer.ChooseHost(context)
+	if host == nil {
+		return nil
+	}
+	if err := variable.Set(ctx, types.VariableStickySessionCookie, lb.setCookie(host)); err != nil {
+		log.DefaultLogger.Warnf("[upstream] [sticky session] set the session cookie of host %s failed: %v", host.AddressString(), err)
+	}
+	return host
+}
+
+// setCookie returns the Set-Cookie header value which binds the session to the host
+func (lb *stickySessionLoadBalancer) setCookie(host types.Host) string {
+	var sb strings.Builder
+	sb.WriteString(lb.config.CookieName)
+	sb.WriteByte('=')
+	sb.WriteString(stickySessionCookieValue(host))
+	if lb.config.Path != "" {
+		sb.WriteString("; Path=")
+		sb.WriteString(lb.config.Path)
+	}
+	if ttl := int64(lb.config.TTL.Duration.Seconds()); ttl > 0 {
+		sb.WriteString("; Max-Age=")
+		sb.WriteString(strconv.FormatInt(ttl, 10))
+	}
+	sb.WriteString("; HttpOnly")
+	return sb.String()
+}
+
+// stickySessionCookieValue encodes the host address, the address is hashed to avoid exposing it
+func stickySessionCookieValue(host types.Host) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(host.AddressString()))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
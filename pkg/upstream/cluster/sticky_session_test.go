@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+const stickySessionTestProtocol api.ProtocolName = "StickySessionProtocol"
+
+type stickySessionCookieKey struct{}
+
+func init() {
+	cookieGetter := func(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+		if cookie, ok := ctx.Value(stickySessionCookieKey{}).(string); ok {
+			return cookie, nil
+		}
+		return "", errors.New("no cookie")
+	}
+	cookie := variable.NewStringVariable(string(stickySessionTestProtocol)+"_"+types.VarProtocolCookie, nil, cookieGetter, nil, 0)
+	variable.RegisterPrefix(cookie.Name(), cookie)
+	variable.RegisterProtocolResource(stickySessionTestProtocol, api.COOKIE, types.VarProtocolCookie)
+}
+
+// newStickySessionLbContext creates the context of a request carrying the cookie, empty means no cookie
+func newStickySessionLbContext(cookie string) types.LoadBalancerContext {
+	ctx := variable.NewVariableContext(context.Background())
+	if cookie != "" {
+		ctx = context.WithValue(ctx, stickySessionCookieKey{}, cookie)
+	}
+	_ = variable.Set(ctx, types.VariableDownStreamProtocol, stickySessionTestProtocol)
+	return &mockLbContext{
+		context: ctx,
+	}
+}
+
+// sessionCookie returns the cookie value set by the load balancer, empty means no cookie is set
+func sessionCookie(t *testing.T, lbCtx types.LoadBalancerContext) string {
+	v, err := variable.Get(lbCtx.DownstreamContext(), types.VariableStickySessionCookie)
+	if err != nil {
+		return ""
+	}
+	setCookie, _ := v.(string)
+	if setCookie == "" {
+		return ""
+	}
+	parts := strings.Split(setCookie, "; ")
+	require.True(t, strings.HasPrefix(parts[0], "session="))
+	assert.Equal(t, []string{"Path=/", "Max-Age=60", "HttpOnly"}, parts[1:])
+	return strings.TrimPrefix(parts[0], "session=")
+}
+
+func newTestStickySessionLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	info := &clusterInfo{
+		lbType: types.RoundRobin,
+		stickySession: &v2.StickySessionConfig{
+			CookieName: "session",
+			Path:       "/",
+			TTL:        api.DurationConfig{Duration: time.Minute},
+		},
+	}
+	return newStickySessionLoadBalancer(info, hosts, NewLoadBalancer(info, hosts))
+}
+
+func TestStickySessionDisabled(t *testing.T) {
+	hosts := getMockHostSet(3)
+	info := &clusterInfo{lbType: types.RoundRobin}
+	lb := NewLoadBalancer(info, hosts)
+	assert.Equal(t, lb, newStickySessionLoadBalancer(info, hosts, lb))
+}
+
+func TestStickySessionAffinity(t *testing.T) {
+	hosts := getMockHostSet(5)
+	lb := newTestStickySessionLoadBalancer(hosts)
+
+	// the first request sets the cookie
+	lbCtx := newStickySessionLbContext("")
+	host := lb.ChooseHost(lbCtx)
+	require.NotNil(t, host)
+	cookie := sessionCookie(t, lbCtx)
+	require.NotEqual(t, "", cookie)
+
+	// the requests carrying the cookie go to the same host, and the cookie is not set again
+	for i := 0; i < 10; i++ {
+		lbCtx = newStickySessionLbContext(cookie)
+		assert.Equal(t, host.AddressString(), lb.ChooseHost(lbCtx).AddressString())
+		assert.Equal(t, "", sessionCookie(t, lbCtx))
+	}
+
+	// the unknown cookie is rehomed
+	lbCtx = newStickySessionLbContext("unknown")
+	assert.NotNil(t, lb.ChooseHost(lbCtx))
+	assert.NotEqual(t, "", sessionCookie(t, lbCtx))
+}
+
+func TestStickySessionRehome(t *testing.T) {
+	hosts := getMockHostSet(5)
+	lb := newTestStickySessionLoadBalancer(hosts)
+	lbCtx := newStickySessionLbContext("")
+	host := lb.ChooseHost(lbCtx)
+	require.NotNil(t, host)
+	cookie := sessionCookie(t, lbCtx)
+
+	// the host is unhealthy
+	host.SetHealthFlag(api.FAILED_ACTIVE_HC)
+	lbCtx = newStickySessionLbContext(cookie)
+	unhealthy := lb.ChooseHost(lbCtx)
+	require.NotNil(t, unhealthy)
+	assert.NotEqual(t, host.AddressString(), unhealthy.AddressString())
+	assert.NotEqual(t, cookie, sessionCookie(t, lbCtx))
+	host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+
+	// the host is removed
+	remained := &mockHostSet{}
+	for _, h := range hosts.hosts {
+		if h.AddressString() != host.AddressString() {
+			remained.hosts = append(remained.hosts, h)
+		}
+	}
+	lb = newTestStickySessionLoadBalancer(remained)
+	lbCtx = newStickySessionLbContext(cookie)
+	rehomed := lb.ChooseHost(lbCtx)
+	require.NotNil(t, rehomed)
+	assert.NotEqual(t, host.AddressString(), rehomed.AddressString())
+	newCookie := sessionCookie(t, lbCtx)
+	assert.NotEqual(t, cookie, newCookie)
+
+	// the session sticks to the new host
+	for i := 0; i < 10; i++ {
+		lbCtx = newStickySessionLbContext(newCookie)
+		assert.Equal(t, rehomed.AddressString(), lb.ChooseHost(lbCtx).AddressString())
+	}
+}
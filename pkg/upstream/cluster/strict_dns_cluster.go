@@ -36,17 +36,26 @@ import (
 
 func init() {
 	RegisterClusterType(v2.STRICT_DNS_CLUSTER, newStrictDnsCluster)
+	RegisterClusterType(v2.LOGICAL_DNS_CLUSTER, newLogicalDnsCluster)
+}
+
+// dnsResolver resolves the domain to addresses, it is implemented by network.DnsResolver
+type dnsResolver interface {
+	DnsResolve(dnsAddr string, dnsLookupFamily v2.DnsLookupFamily) *[]network.DnsResponse
 }
 
 type strictDnsCluster struct {
 	*simpleCluster
-	dnsResolver     *network.DnsResolver
+	dnsResolver     dnsResolver
 	dnsLookupFamily v2.DnsLookupFamily
 	respectDnsTTL   bool
 	resolveTargets  []*ResolveTarget
 	dnsRefreshRate  time.Duration
 	mutex           sync.Mutex
 	version         uint64
+	// logicalDns uses only one of the resolved addresses for each domain,
+	// the address is kept as long as it is still resolved.
+	logicalDns bool
 }
 
 var DefaultRefreshTimeout time.Duration = 20 * time.Second
@@ -93,6 +102,12 @@ func newStrictDnsCluster(clusterConfig v2.Cluster) types.Cluster {
 	return cluster
 }
 
+func newLogicalDnsCluster(clusterConfig v2.Cluster) types.Cluster {
+	cluster := newStrictDnsCluster(clusterConfig).(*strictDnsCluster)
+	cluster.logicalDns = true
+	return cluster
+}
+
 // supported formats including {aaa.com:80, aaa.com}
 func getHostPortFromAddr(addr string) (string, string) {
 	s := strings.Split(addr, ":")
@@ -238,7 +253,12 @@ func (sdc *strictDnsCluster) updateDynamicHosts(newHosts []types.Host, rt *Resol
 				log.DefaultLogger.Infof("[upstream] [strict dns cluster] resolve dns new address:%s", h.AddressString())
 			}
 		}
+		oldHostSet := sdc.hostSet
 		sdc.simpleCluster.UpdateHosts(NewHostSet(allHosts))
+		// the connection pools of the hosts no longer resolved are closed after drained
+		if cm := clusterManagerInstance.clusterManager; cm != nil && oldHostSet != nil {
+			cm.drainRemovedHosts(sdc, oldHostSet)
+		}
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[upstream] [strict dns cluster] resolve dns result updated, cluster_name:%s, address:%s", sdc.simpleCluster.info.Name(), rt.dnsAddress)
 		}
@@ -303,6 +323,8 @@ func (rt *ResolveTarget) OnResolve() {
 	sdc := rt.strictDnsCluster
 	dnsResponse := sdc.dnsResolver.DnsResolve(rt.dnsAddress, sdc.dnsLookupFamily)
 	if dnsResponse == nil {
+		// keeps the last resolved hosts
+		sdc.info.Stats().UpstreamDnsResolveFailure.Inc(1)
 		rt.dnsRefreshRate <- sdc.calculateNextResolveInterval(0)
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 			log.DefaultLogger.Debugf("[upstream] [strict dns cluster] resolve failed and start a new task")
@@ -317,8 +339,7 @@ func (rt *ResolveTarget) OnResolve() {
 		if rsp.Ttl < minTtl {
 			minTtl = rsp.Ttl
 		}
-		var newAddr string
-		newAddr = rsp.Address + ":" + rt.port
+		newAddr := net.JoinHostPort(rsp.Address, rt.port)
 		host := &simpleHost{
 			hostname:      rt.config.Hostname,
 			addressString: newAddr,
@@ -326,15 +347,36 @@ func (rt *ResolveTarget) OnResolve() {
 			metaData:      rt.config.MetaData,
 			tlsDisable:    rt.config.TLSDisable,
 			weight:        rt.config.Weight,
-			healthFlags:   GetHealthFlagPointer(newAddr),
+			healthyTime:   time.Now().UnixNano(),
+			connections:   getConnectionsPointer(sdc.info.Name(), newAddr),
 		}
+		host.healthFlags, host.newAddress = getHealthFlagPointer(newAddr)
 		host.clusterInfo.Store(sdc.info)
 		hosts = append(hosts, host)
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 			log.DefaultLogger.Debugf("[upstream] [strict dns cluster] resolve dns result, address:%s, addr:%s, ttl:%.3f", rt.dnsAddress, newAddr, rsp.Ttl.Seconds())
 		}
 	}
+	if sdc.logicalDns {
+		hosts = rt.logicalHosts(hosts)
+	}
 	sdc.updateDynamicHosts(hosts, rt)
 
 	rt.dnsRefreshRate <- sdc.calculateNextResolveInterval(minTtl)
 }
+
+// logicalHosts keeps the current host if its address is still resolved,
+// otherwise uses the first resolved address.
+func (rt *ResolveTarget) logicalHosts(resolved []types.Host) []types.Host {
+	if len(resolved) == 0 {
+		return resolved
+	}
+	if len(rt.hosts) == 1 {
+		for _, h := range resolved {
+			if h.AddressString() == rt.hosts[0].AddressString() {
+				return rt.hosts
+			}
+		}
+	}
+	return resolved[:1]
+}
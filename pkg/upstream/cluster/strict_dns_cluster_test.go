@@ -18,13 +18,18 @@
 package cluster
 
 import (
+	"context"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	monkey "github.com/cch123/supermonkey"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/network"
 
@@ -251,3 +256,138 @@ func TestMultipleDnsHostsInOneCluster(t *testing.T) {
 
 	assert.Equal(t, snap.HostSet().Size(), 4)
 }
+
+// mockDnsResolver returns the addresses set by the test, nil means resolve failed
+type mockDnsResolver struct {
+	mutex sync.Mutex
+	addrs []string
+}
+
+func (r *mockDnsResolver) set(addrs ...string) {
+	r.mutex.Lock()
+	r.addrs = addrs
+	r.mutex.Unlock()
+}
+
+func (r *mockDnsResolver) DnsResolve(dnsAddr string, dnsLookupFamily v2.DnsLookupFamily) *[]network.DnsResponse {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.addrs == nil {
+		return nil
+	}
+	rsp := make([]network.DnsResponse, 0, len(r.addrs))
+	for _, addr := range r.addrs {
+		rsp = append(rsp, network.DnsResponse{
+			Address: addr,
+			Ttl:     time.Minute,
+		})
+	}
+	return &rsp
+}
+
+func createDnsClusterManager(t *testing.T, clusterType v2.ClusterType) (*strictDnsCluster, *mockDnsResolver) {
+	clusterManagerInstance.Destroy() // Destroy for test
+	NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:           "dns_test",
+			ClusterType:    clusterType,
+			LbType:         v2.LB_ROUNDROBIN,
+			DnsRefreshRate: &api.DurationConfig{Duration: 50 * time.Millisecond},
+			DrainTimeout:   &api.DurationConfig{Duration: time.Second},
+		},
+	}, nil, nil)
+	v, ok := clusterManagerInstance.clustersMap.Load("dns_test")
+	require.True(t, ok)
+	sdc := v.(*strictDnsCluster)
+	resolver := &mockDnsResolver{}
+	sdc.dnsResolver = resolver
+	return sdc, resolver
+}
+
+func dnsClusterAddrs(c types.Cluster) []string {
+	var addrs []string
+	c.Snapshot().HostSet().Range(func(host types.Host) bool {
+		addrs = append(addrs, host.AddressString())
+		return true
+	})
+	sort.Strings(addrs)
+	return addrs
+}
+
+func TestStrictDnsClusterRefresh(t *testing.T) {
+	sdc, resolver := createDnsClusterManager(t, v2.STRICT_DNS_CLUSTER)
+	resolver.set("10.0.0.1", "10.0.0.2")
+	require.Nil(t, clusterManagerInstance.UpdateClusterHosts("dns_test", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "mock.dns.test:8080", Hostname: "mock.dns.test"}},
+	}))
+	defer sdc.StopHealthChecking()
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, dnsClusterAddrs(sdc))
+	// create connection pools for all hosts
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "dns_test")
+	for i := 0; i < 2; i++ {
+		_, host := clusterManagerInstance.ConnPoolForCluster(newMockLbContext(nil), snap, mockProtocol)
+		require.NotNil(t, host)
+	}
+	removedPool := loadMockConnPool("10.0.0.1:8080")
+	keptPool := loadMockConnPool("10.0.0.2:8080")
+	require.NotNil(t, removedPool)
+	require.NotNil(t, keptPool)
+
+	// dns changed, the hosts and the connection pools follow
+	resolver.set("10.0.0.2", "10.0.0.3")
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"10.0.0.2:8080", "10.0.0.3:8080"}, dnsClusterAddrs(sdc))
+	time.Sleep(3 * drainCheckInterval)
+	require.Equal(t, uint32(1), atomic.LoadUint32(&removedPool.closed))
+	require.Nil(t, loadMockConnPool("10.0.0.1:8080"))
+	require.Equal(t, uint32(0), atomic.LoadUint32(&keptPool.closed))
+	require.Equal(t, keptPool, loadMockConnPool("10.0.0.2:8080"))
+
+	// resolve failed, keeps the last resolved hosts
+	failures := sdc.info.Stats().UpstreamDnsResolveFailure.Count()
+	resolver.set()
+	time.Sleep(200 * time.Millisecond)
+	require.True(t, sdc.info.Stats().UpstreamDnsResolveFailure.Count() > failures)
+	require.Equal(t, []string{"10.0.0.2:8080", "10.0.0.3:8080"}, dnsClusterAddrs(sdc))
+
+	// recovered
+	resolver.set("10.0.0.4")
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"10.0.0.4:8080"}, dnsClusterAddrs(sdc))
+}
+
+func TestLogicalDnsCluster(t *testing.T) {
+	sdc, resolver := createDnsClusterManager(t, v2.LOGICAL_DNS_CLUSTER)
+	require.True(t, sdc.logicalDns)
+	resolver.set("10.0.1.1", "10.0.1.2")
+	require.Nil(t, clusterManagerInstance.UpdateClusterHosts("dns_test", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "mock.dns.test:8080", Hostname: "mock.dns.test"}},
+	}))
+	defer sdc.StopHealthChecking()
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"10.0.1.1:8080"}, dnsClusterAddrs(sdc))
+	host := sdc.Snapshot().HostSet().Get(0)
+
+	// the address is still resolved, the host is kept
+	resolver.set("10.0.1.2", "10.0.1.1")
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"10.0.1.1:8080"}, dnsClusterAddrs(sdc))
+	require.True(t, host == sdc.Snapshot().HostSet().Get(0))
+
+	// the address is not resolved any more, uses the first resolved address
+	resolver.set("10.0.1.3", "10.0.1.2")
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"10.0.1.3:8080"}, dnsClusterAddrs(sdc))
+}
+
+func TestDnsResolveIPv6Address(t *testing.T) {
+	sdc, resolver := createDnsClusterManager(t, v2.STRICT_DNS_CLUSTER)
+	resolver.set("fd00::1")
+	require.Nil(t, clusterManagerInstance.UpdateClusterHosts("dns_test", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "mock.dns.test:8080", Hostname: "mock.dns.test"}},
+	}))
+	defer sdc.StopHealthChecking()
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, []string{"[fd00::1]:8080"}, dnsClusterAddrs(sdc))
+}
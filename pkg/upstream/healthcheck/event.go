@@ -0,0 +1,257 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+// HealthState is the health state of a host in the health events
+type HealthState string
+
+const (
+	StateHealthy   HealthState = "healthy"
+	StateUnhealthy HealthState = "unhealthy"
+)
+
+func healthState(healthy bool) HealthState {
+	if healthy {
+		return StateHealthy
+	}
+	return StateUnhealthy
+}
+
+// HealthEvent is published when the health state of a host changes
+type HealthEvent struct {
+	Cluster   string      `json:"cluster"`
+	Host      string      `json:"host"`
+	OldState  HealthState `json:"old_state"`
+	NewState  HealthState `json:"new_state"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// HealthEventHandler handles the health events, it is called in the health check goroutine
+// and should not block.
+type HealthEventHandler func(event HealthEvent)
+
+// eventBus dispatches the health events of all the clusters to the subscribers
+type eventBus struct {
+	mutex       sync.RWMutex
+	nextID      uint64
+	subscribers map[uint64]HealthEventHandler
+}
+
+var healthEventBus = &eventBus{
+	subscribers: make(map[uint64]HealthEventHandler),
+}
+
+// SubscribeHealthEvents subscribes the health events of all the clusters,
+// the returned function cancels the subscription.
+func SubscribeHealthEvents(handler HealthEventHandler) (unsubscribe func()) {
+	return healthEventBus.subscribe(handler)
+}
+
+func (b *eventBus) subscribe(handler HealthEventHandler) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = handler
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+func (b *eventBus) publish(event HealthEvent) {
+	b.mutex.RLock()
+	handlers := make([]HealthEventHandler, 0, len(b.subscribers))
+	for _, handler := range b.subscribers {
+		handlers = append(handlers, handler)
+	}
+	b.mutex.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// hostEventState is the state of a host published last time
+type hostEventState struct {
+	published   HealthState
+	publishedAt time.Time
+	latest      HealthState
+	// pending is not nil if the latest state waits for the min interval
+	pending *time.Timer
+}
+
+// healthEventNotifier publishes the health state changes of the hosts to the event bus and the webhook.
+// the changes of a host within the min interval are merged, only the latest state is published when
+// the interval elapses, and nothing is published if the host flaps back to the published state.
+type healthEventNotifier struct {
+	minInterval time.Duration
+	webhook     *webhook
+	mutex       sync.Mutex
+	hosts       map[string]*hostEventState
+}
+
+func newHealthEventNotifier(cfg v2.HealthCheck) *healthEventNotifier {
+	n := &healthEventNotifier{
+		minInterval: cfg.EventMinInterval.Duration,
+		hosts:       make(map[string]*hostEventState),
+	}
+	if cfg.EventWebhook != nil && cfg.EventWebhook.URL != "" {
+		n.webhook = newWebhook(cfg.EventWebhook)
+	}
+	return n
+}
+
+// onStateChange is called when the health state of the host is changed
+func (n *healthEventNotifier) onStateChange(host types.Host, healthy bool) {
+	addr := host.AddressString()
+	cluster := ""
+	if info := host.ClusterInfo(); info != nil {
+		cluster = info.Name()
+	}
+	n.mutex.Lock()
+	s, ok := n.hosts[addr]
+	if !ok {
+		// the host is changed from the opposite state
+		s = &hostEventState{
+			published: healthState(!healthy),
+		}
+		n.hosts[addr] = s
+	}
+	s.latest = healthState(healthy)
+	if s.pending != nil {
+		n.mutex.Unlock()
+		return
+	}
+	if wait := n.minInterval - time.Since(s.publishedAt); wait > 0 {
+		s.pending = time.AfterFunc(wait, func() {
+			n.mutex.Lock()
+			s.pending = nil
+			event, ok := HealthEvent{}, false
+			// the host may be removed while waiting
+			if n.hosts[addr] == s {
+				event, ok = n.transitLocked(cluster, addr, s)
+			}
+			n.mutex.Unlock()
+			if ok {
+				n.publish(event)
+			}
+		})
+		n.mutex.Unlock()
+		return
+	}
+	event, ok := n.transitLocked(cluster, addr, s)
+	n.mutex.Unlock()
+	if ok {
+		n.publish(event)
+	}
+}
+
+// transitLocked makes the latest state published, ok is false if the state is not changed
+func (n *healthEventNotifier) transitLocked(cluster, addr string, s *hostEventState) (event HealthEvent, ok bool) {
+	if s.latest == s.published {
+		return event, false
+	}
+	now := time.Now()
+	event = HealthEvent{
+		Cluster:   cluster,
+		Host:      addr,
+		OldState:  s.published,
+		NewState:  s.latest,
+		Timestamp: now,
+	}
+	s.published, s.publishedAt = s.latest, now
+	return event, true
+}
+
+func (n *healthEventNotifier) publish(event HealthEvent) {
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[upstream] [health check] [event] host %s of cluster %s changed from %s to %s",
+			event.Host, event.Cluster, event.OldState, event.NewState)
+	}
+	healthEventBus.publish(event)
+	if n.webhook != nil {
+		utils.GoWithRecover(func() {
+			n.webhook.post(event)
+		}, nil)
+	}
+}
+
+// remove drops the state of the host which is not checked any more
+func (n *healthEventNotifier) remove(host types.Host) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	addr := host.AddressString()
+	if s, ok := n.hosts[addr]; ok {
+		if s.pending != nil {
+			s.pending.Stop()
+		}
+		delete(n.hosts, addr)
+	}
+}
+
+const defaultWebhookTimeout = 3 * time.Second
+
+// webhook posts the health events as json
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhook(cfg *v2.HealthEventWebhook) *webhook {
+	timeout := cfg.TimeoutConfig.Duration
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &webhook{
+		url: cfg.URL,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (w *webhook) post(event HealthEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [webhook] marshal event failed: %v", err)
+		return
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [webhook] post event to %s failed: %v", w.url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		log.DefaultLogger.Errorf("[upstream] [health check] [webhook] post event to %s failed, status code: %d", w.url, resp.StatusCode)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+type mockClusterInfo struct {
+	types.ClusterInfo
+	name string
+}
+
+func (ci *mockClusterInfo) Name() string {
+	return ci.name
+}
+
+type eventHost struct {
+	*mockHost
+	cluster types.ClusterInfo
+}
+
+func (h *eventHost) ClusterInfo() types.ClusterInfo {
+	return h.cluster
+}
+
+// eventRecorder records the health events of a host
+type eventRecorder struct {
+	mutex  sync.Mutex
+	host   string
+	events []HealthEvent
+}
+
+func (r *eventRecorder) handle(event HealthEvent) {
+	if event.Host != r.host {
+		return
+	}
+	r.mutex.Lock()
+	r.events = append(r.events, event)
+	r.mutex.Unlock()
+}
+
+// transitions returns the recorded transitions as "old->new"
+func (r *eventRecorder) transitions() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	transitions := make([]string, 0, len(r.events))
+	for _, event := range r.events {
+		transitions = append(transitions, string(event.OldState)+"->"+string(event.NewState))
+	}
+	return transitions
+}
+
+func newEventChecker(cfg v2.HealthCheck, addr string) *sessionChecker {
+	hc := newHealthChecker(cfg, &mockSessionFactory{}).(*healthChecker)
+	host := &eventHost{
+		mockHost: &mockHost{addr: addr},
+		cluster:  &mockClusterInfo{name: "test_cluster"},
+	}
+	return newChecker(&mockSession{host}, host, hc)
+}
+
+func TestHealthEventBus(t *testing.T) {
+	c := newEventChecker(v2.HealthCheck{}, "127.0.0.1:10001")
+	recorder := &eventRecorder{host: "127.0.0.1:10001"}
+	unsubscribe := SubscribeHealthEvents(recorder.handle)
+
+	c.HandleSuccess()
+	c.HandleFailure(types.FailureActive)
+	c.HandleFailure(types.FailureNetwork)
+	c.HandleSuccess()
+	c.HandleSuccess()
+	assert.Equal(t, []string{"healthy->unhealthy", "unhealthy->healthy"}, recorder.transitions())
+	assert.Equal(t, "test_cluster", recorder.events[0].Cluster)
+	assert.False(t, recorder.events[0].Timestamp.IsZero())
+
+	// no events after unsubscribed
+	unsubscribe()
+	c.HandleFailure(types.FailureActive)
+	assert.Len(t, recorder.transitions(), 2)
+}
+
+func TestHealthEventDebounce(t *testing.T) {
+	cfg := v2.HealthCheck{}
+	cfg.EventMinInterval = api.DurationConfig{Duration: 300 * time.Millisecond}
+	c := newEventChecker(cfg, "127.0.0.1:10002")
+	recorder := &eventRecorder{host: "127.0.0.1:10002"}
+	defer SubscribeHealthEvents(recorder.handle)()
+
+	// the first change is published immediately
+	c.HandleFailure(types.FailureActive)
+	assert.Equal(t, []string{"healthy->unhealthy"}, recorder.transitions())
+
+	// the flapping is merged, the latest state is published after the interval
+	c.HandleSuccess()
+	c.HandleFailure(types.FailureActive)
+	c.HandleSuccess()
+	assert.Len(t, recorder.transitions(), 1)
+	require.Eventually(t, func() bool {
+		return len(recorder.transitions()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"healthy->unhealthy", "unhealthy->healthy"}, recorder.transitions())
+
+	// nothing is published if the host flaps back to the published state
+	c.HandleFailure(types.FailureActive)
+	c.HandleSuccess()
+	time.Sleep(500 * time.Millisecond)
+	assert.Len(t, recorder.transitions(), 2)
+
+	// the pending change is dropped when the host is removed
+	c.HandleFailure(types.FailureActive)
+	assert.Len(t, recorder.transitions(), 3)
+	c.HandleSuccess()
+	c.HealthChecker.notifier.remove(c.Host)
+	time.Sleep(500 * time.Millisecond)
+	assert.Len(t, recorder.transitions(), 3)
+}
+
+func TestHealthEventWebhook(t *testing.T) {
+	events := make(chan HealthEvent, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		event := HealthEvent{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer server.Close()
+
+	cfg := v2.HealthCheck{}
+	cfg.EventWebhook = &v2.HealthEventWebhook{URL: server.URL}
+	c := newEventChecker(cfg, "127.0.0.1:10003")
+
+	wait := func() HealthEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(3 * time.Second):
+			t.Fatal("webhook is not called")
+		}
+		return HealthEvent{}
+	}
+	c.HandleFailure(types.FailureActive)
+	event := wait()
+	require.Equal(t, "test_cluster", event.Cluster)
+	require.Equal(t, "127.0.0.1:10003", event.Host)
+	require.Equal(t, StateHealthy, event.OldState)
+	require.Equal(t, StateUnhealthy, event.NewState)
+	c.HandleSuccess()
+	event = wait()
+	require.Equal(t, StateUnhealthy, event.OldState)
+	require.Equal(t, StateHealthy, event.NewState)
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	GRPCCheckConfigKey = "grpc_check_config"
+	// GRPCHealthCheckProtocol is the health check protocol that uses grpc.health.v1
+	GRPCHealthCheckProtocol = "grpc"
+)
+
+func init() {
+	RegisterSessionFactory(types.ProtocolName(GRPCHealthCheckProtocol), &GRPCDialSessionFactory{})
+}
+
+// GrpcCheckConfig is the config of the grpc health checking protocol
+type GrpcCheckConfig struct {
+	Port      int                `json:"port,omitempty"`
+	Timeout   api.DurationConfig `json:"timeout,omitempty"`
+	Service   string             `json:"service,omitempty"`   // the service name in the HealthCheckRequest, empty means the server overall health
+	Authority string             `json:"authority,omitempty"` // the :authority header of the Check RPC
+}
+
+// GRPCDialSession checks the host by the Check RPC of the grpc.health.v1.Health service,
+// the host is healthy only if the response status is SERVING.
+type GRPCDialSession struct {
+	addr      string
+	service   string
+	authority string
+	timeout   time.Duration
+
+	mutex  sync.Mutex
+	conn   *grpc.ClientConn
+	client healthpb.HealthClient
+}
+
+type GRPCDialSessionFactory struct{}
+
+func (f *GRPCDialSessionFactory) NewSession(cfg map[string]interface{}, host types.Host) types.HealthCheckSession {
+	grpcCheckConfig := &GrpcCheckConfig{}
+	if v, ok := cfg[GRPCCheckConfigKey]; ok {
+		if c, ok := v.(*GrpcCheckConfig); ok {
+			grpcCheckConfig = c
+		} else {
+			grpcCheckConfigBytes, err := json.Marshal(v)
+			if err != nil {
+				log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] grpcCheckConfig covert %+v error %+v %+v", reflect.TypeOf(v), v, err)
+				return nil
+			}
+			if err := json.Unmarshal(grpcCheckConfigBytes, grpcCheckConfig); err != nil {
+				log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] grpcCheckConfig Unmarshal %+v error %+v %+v", reflect.TypeOf(v), v, err)
+				return nil
+			}
+		}
+	}
+
+	addr := host.AddressString()
+	if grpcCheckConfig.Port > 0 && grpcCheckConfig.Port < 65535 {
+		hostIp, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] host=%s parse error %+v", addr, err)
+			return nil
+		}
+		// re-config grpc check port
+		addr = net.JoinHostPort(hostIp, strconv.Itoa(grpcCheckConfig.Port))
+	}
+
+	grpcDial := &GRPCDialSession{
+		addr:      addr,
+		service:   grpcCheckConfig.Service,
+		authority: grpcCheckConfig.Authority,
+		timeout:   grpcCheckConfig.Timeout.Duration,
+	}
+	if grpcDial.timeout <= 0 {
+		grpcDial.timeout = defaultTimeout.Duration
+	}
+
+	log.DefaultLogger.Infof("[upstream] [health check] [grpcdial session] create a health check success for %s, service: %s", addr, grpcDial.service)
+	return grpcDial
+}
+
+// getClient returns the health client, the connection is created at the first check
+// and reused by the following checks.
+func (s *GRPCDialSession) getClient() (healthpb.HealthClient, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if s.authority != "" {
+		opts = append(opts, grpc.WithAuthority(s.authority))
+	}
+	conn, err := grpc.Dial(s.addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	s.client = healthpb.NewHealthClient(conn)
+	return s.client, nil
+}
+
+func (s *GRPCDialSession) CheckHealth() bool {
+	client, err := s.getClient()
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] dial grpc for host %s error: %v", s.addr, err)
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{
+		Service: s.service,
+	})
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] grpc check for host %s error: %v", s.addr, err)
+		return false
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] grpc check for host %s failed, service: %s, status: %s", s.addr, s.service, resp.GetStatus())
+		return false
+	}
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[upstream] [health check] [grpcdial session] grpc check for host %s succeed", s.addr)
+	}
+	return true
+}
+
+func (s *GRPCDialSession) OnTimeout() {
+	log.DefaultLogger.Errorf("[upstream] [health check] [grpcdial session] grpc check for host %s timeout", s.addr)
+}
+
+// Close closes the connection when the host is not checked any more
+func (s *GRPCDialSession) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	s.client = nil
+	return err
+}
@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func startHealthServer(t *testing.T) (*health.Server, string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	return hs, ln.Addr().String(), srv.Stop
+}
+
+func TestGRPCDialSessionNewSession(t *testing.T) {
+	f := &GRPCDialSessionFactory{}
+	h := &mockHost{addr: "127.0.0.1:22222"}
+	// no config, check the host address
+	s := f.NewSession(map[string]interface{}{}, h).(*GRPCDialSession)
+	assert.Equal(t, "127.0.0.1:22222", s.addr)
+	assert.Equal(t, "", s.service)
+	assert.Equal(t, defaultTimeout.Duration, s.timeout)
+	// config from json
+	cfg := map[string]interface{}{
+		GRPCCheckConfigKey: map[string]interface{}{
+			"port":    33333,
+			"service": "echo.EchoService",
+			"timeout": "2s",
+		},
+	}
+	s = f.NewSession(cfg, h).(*GRPCDialSession)
+	assert.Equal(t, "127.0.0.1:33333", s.addr)
+	assert.Equal(t, "echo.EchoService", s.service)
+	assert.Equal(t, 2*time.Second, s.timeout)
+	// invalid config
+	cfg[GRPCCheckConfigKey] = "xx"
+	assert.Nil(t, f.NewSession(cfg, h))
+	// invalid host address with port config
+	cfg[GRPCCheckConfigKey] = &GrpcCheckConfig{Port: 33333}
+	assert.Nil(t, f.NewSession(cfg, &mockHost{addr: "127.0.0.1"}))
+}
+
+func TestGRPCDialSessionCheckHealth(t *testing.T) {
+	hs, addr, stop := startHealthServer(t)
+	defer stop()
+	service := "echo.EchoService"
+	f := &GRPCDialSessionFactory{}
+	s := f.NewSession(map[string]interface{}{
+		GRPCCheckConfigKey: &GrpcCheckConfig{
+			Service: service,
+			Timeout: api.DurationConfig{Duration: time.Second},
+		},
+	}, &mockHost{addr: addr}).(*GRPCDialSession)
+	defer s.Close()
+	// unknown service
+	assert.False(t, s.CheckHealth())
+	hs.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	assert.True(t, s.CheckHealth())
+	hs.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.False(t, s.CheckHealth())
+	hs.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	assert.True(t, s.CheckHealth())
+	// server stopped
+	stop()
+	assert.False(t, s.CheckHealth())
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	hs, addr, stop := startHealthServer(t)
+	defer stop()
+	service := "echo.EchoService"
+	hs.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+
+	hcString := `{"protocol":"grpc","timeout":"1s","interval":"100ms","initial_delay_seconds":"100ms","healthy_threshold":2,"unhealthy_threshold":2,"service_name":"testGrpcCluster","check_config":{"grpc_check_config":{"service":"echo.EchoService","timeout":"1s"}}}`
+	cfg := &v2.HealthCheck{}
+	require.Nil(t, json.Unmarshal([]byte(hcString), cfg))
+	hc := CreateHealthCheck(*cfg)
+	h := &mockHost{addr: addr}
+	hc.SetHealthCheckerHostSet(&mockHostSet{hosts: []types.Host{h}})
+	hcc := hc.(*healthChecker)
+	hcc.Start()
+	defer hcc.Stop()
+
+	waitFlag := func(expected bool) bool {
+		for i := 0; i < 30; i++ {
+			if h.ContainHealthFlag(api.FAILED_ACTIVE_HC) == expected {
+				return true
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return false
+	}
+	time.Sleep(500 * time.Millisecond)
+	assert.False(t, h.ContainHealthFlag(api.FAILED_ACTIVE_HC))
+	// NOT_SERVING marks the host unhealthy
+	hs.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.True(t, waitFlag(true), "host should be unhealthy")
+	// SERVING marks the host healthy again
+	hs.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	assert.True(t, waitFlag(false), "host should be healthy")
+	assert.True(t, hcc.stats.success.Count() > 0)
+	assert.True(t, hcc.stats.failure.Count() >= 2)
+}
@@ -56,8 +56,10 @@ type healthChecker struct {
 	healthyThreshold   uint32
 	initialDelay       time.Duration
 	unhealthyThreshold uint32
+	warmUp             bool
 	rander             *rand.Rand
 	hostCheckCallbacks []types.HealthCheckCb
+	notifier           *healthEventNotifier
 }
 
 func newHealthChecker(cfg v2.HealthCheck, f types.HealthCheckSessionFactory) types.HealthChecker {
@@ -95,12 +97,14 @@ func newHealthChecker(cfg v2.HealthCheck, f types.HealthCheckSessionFactory) typ
 		healthyThreshold:   healthyThreshold,
 		unhealthyThreshold: unhealthyThreshold,
 		initialDelay:       initialDelay,
+		warmUp:             cfg.WarmUp,
 		//runtime and stats
 		rander:             rand.New(rand.NewSource(time.Now().UnixNano())),
 		hostCheckCallbacks: []types.HealthCheckCb{},
 		sessionFactory:     f,
 		checkers:           make(map[string]*sessionChecker),
 		stats:              newHealthCheckStats(cfg.ServiceName),
+		notifier:           newHealthEventNotifier(cfg),
 	}
 	// Add common callbacks when create
 	// common callbacks should be registered and configured
@@ -201,21 +205,43 @@ func (hc *healthChecker) startCheck(host types.Host) {
 		}
 		c := newChecker(s, host, hc)
 		hc.checkers[addr] = c
+		if hc.warmUp && isNewAddress(host) {
+			// the host of a new address is pending until it passes the health checks
+			host.SetHealthFlag(types.PENDING_ACTIVE_HC)
+		}
+		if !host.ContainHealthFlag(types.PENDING_ACTIVE_HC) {
+			atomic.AddInt64(&hc.localProcessHealthy, 1) // default host is healthy
+		}
 		utils.GoWithRecover(func() {
 			c.Start()
 		}, nil)
-		atomic.AddInt64(&hc.localProcessHealthy, 1) // default host is healthy
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[upstream] [health check] create a health check session for %s", addr)
 		}
 	}
 }
 
+// isNewAddress returns true if the address of the host is seen for the first time,
+// the host does not know it is treated as new
+func isNewAddress(host types.Host) bool {
+	if h, ok := host.(types.NewAddressHost); ok {
+		return h.NewAddress()
+	}
+	return true
+}
+
 func (hc *healthChecker) stopCheck(host types.Host) {
 	addr := host.AddressString()
 	if c, ok := hc.checkers[addr]; ok {
 		c.Stop()
 		delete(hc.checkers, addr)
+		hc.notifier.remove(host)
+		// the pending host is not counted as healthy, and the flag is cleared
+		// as the flags are shared by the hosts with the same address
+		if host.ContainHealthFlag(types.PENDING_ACTIVE_HC) {
+			host.ClearHealthFlag(types.PENDING_ACTIVE_HC)
+			return
+		}
 		// hc.localProcessHealthy--
 		atomic.AddInt64(&hc.localProcessHealthy, ^int64(0)) // deleted check is unhealthy
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
@@ -247,6 +273,7 @@ func (hc *healthChecker) incHealthy(host types.Host, changed bool) {
 			log.DefaultLogger.Infof("[upstream] [health check] host %s is healthy", host.AddressString())
 		}
 		atomic.AddInt64(&hc.localProcessHealthy, 1)
+		hc.notifier.onStateChange(host, true)
 	}
 	hc.runCallbacks(host, changed, true)
 }
@@ -259,6 +286,7 @@ func (hc *healthChecker) decHealthy(host types.Host, reason types.FailureType, c
 			log.DefaultLogger.Infof("[upstream] [health check] host %s is unhealthy", host.AddressString())
 		}
 		atomic.AddInt64(&hc.localProcessHealthy, ^int64(0))
+		hc.notifier.onStateChange(host, false)
 	}
 	switch reason {
 	case types.FailureActive:
@@ -444,3 +444,64 @@ func Test_HttpHealthCheck(t *testing.T) {
 		t.Errorf("Test_HttpHealthCheck error")
 	}
 }
+
+func TestHealthCheckWarmUp(t *testing.T) {
+	cfg := v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			ServiceName:         "testWarmUp",
+			HealthyThreshold:    3,
+			UnhealthyThreshold:  1,
+			InitialDelaySeconds: api.DurationConfig{time.Hour},
+			WarmUp:              true,
+		},
+	}
+	hc := newHealthChecker(cfg, &mockSessionFactory{}).(*healthChecker)
+	host := &mockHost{addr: "127.0.0.1:8080"}
+	hc.startCheck(host)
+	defer hc.stopCheck(host)
+	c := hc.checkers[host.addr]
+
+	// the new host is pending
+	if !host.ContainHealthFlag(types.PENDING_ACTIVE_HC) || atomic.LoadInt64(&hc.localProcessHealthy) != 0 {
+		t.Fatalf("new host should be pending, flag: %d, healthy: %d", host.flag, hc.localProcessHealthy)
+	}
+	// the failure resets the consecutive successes, and the host keeps pending
+	c.HandleSuccess()
+	c.HandleSuccess()
+	c.HandleFailure(types.FailureActive)
+	if !host.ContainHealthFlag(types.PENDING_ACTIVE_HC) || host.ContainHealthFlag(api.FAILED_ACTIVE_HC) {
+		t.Fatalf("host should keep pending, flag: %d", host.flag)
+	}
+	c.HandleSuccess()
+	c.HandleSuccess()
+	if !host.ContainHealthFlag(types.PENDING_ACTIVE_HC) {
+		t.Fatal("host becomes healthy before the threshold")
+	}
+	c.HandleSuccess()
+	if host.flag != 0 || atomic.LoadInt64(&hc.localProcessHealthy) != 1 {
+		t.Fatalf("host should be healthy, flag: %d, healthy: %d", host.flag, hc.localProcessHealthy)
+	}
+}
+
+func TestHealthCheckWarmUpInheritedHost(t *testing.T) {
+	cfg := v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			ServiceName:         "testWarmUpInherited",
+			HealthyThreshold:    3,
+			UnhealthyThreshold:  1,
+			InitialDelaySeconds: api.DurationConfig{time.Hour},
+			WarmUp:              true,
+		},
+	}
+	hc := newHealthChecker(cfg, &mockSessionFactory{}).(*healthChecker)
+	// the host inherited by the cluster update keeps healthy
+	host := &mockHost{addr: "127.0.0.1:8081", inherited: true}
+	hc.startCheck(host)
+	if host.ContainHealthFlag(types.PENDING_ACTIVE_HC) || atomic.LoadInt64(&hc.localProcessHealthy) != 1 {
+		t.Fatalf("inherited host should not be pending, flag: %d, healthy: %d", host.flag, hc.localProcessHealthy)
+	}
+	hc.stopCheck(host)
+	if atomic.LoadInt64(&hc.localProcessHealthy) != 0 {
+		t.Fatalf("stopped host should not be counted, healthy: %d", hc.localProcessHealthy)
+	}
+}
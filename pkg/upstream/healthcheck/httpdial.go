@@ -19,10 +19,13 @@ package healthcheck
 
 import (
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -38,6 +41,9 @@ const (
 
 var defaultTimeout = api.DurationConfig{time.Second * 30}
 
+// maxCheckBodySize is the max size of response body to match the expected body
+const maxCheckBodySize = 64 * 1024
+
 func init() {
 	httpDialSessionFactory := &HTTPDialSessionFactory{}
 	RegisterSessionFactory(protocol.HTTP1, httpDialSessionFactory)
@@ -56,13 +62,17 @@ type HttpCheckConfig struct {
 	Scheme  string             `json:"scheme,omitempty"`
 	Domain  string             `json:"domain,omitempty"`
 	Codes   []CodeRange        `json:"codes,omitempty"`
+	Headers map[string]string  `json:"headers,omitempty"`
+	// ExpectedBody is a regular expression, the host is healthy only if the response body matches it
+	ExpectedBody string `json:"expected_body,omitempty"`
 }
 
 type HTTPDialSession struct {
-	client  *http.Client
-	timeout time.Duration
-	request *http.Request
-	Codes   []CodeRange
+	client       *http.Client
+	timeout      time.Duration
+	request      *http.Request
+	Codes        []CodeRange
+	expectedBody *regexp.Regexp
 }
 
 type HTTPDialSessionFactory struct{}
@@ -139,8 +149,20 @@ func (f *HTTPDialSessionFactory) NewSession(cfg map[string]interface{}, host typ
 		httpDial.request.Host = httpCheckConfig.Domain
 	}
 
+	for k, v := range httpCheckConfig.Headers {
+		httpDial.request.Header.Set(k, v)
+	}
+
 	httpDial.Codes = httpCheckConfig.Codes
 
+	if httpCheckConfig.ExpectedBody != "" {
+		httpDial.expectedBody, err = regexp.Compile(httpCheckConfig.ExpectedBody)
+		if err != nil {
+			log.DefaultLogger.Errorf("[upstream] [health check] [httpdial session] compile expected body %s failed, %v", httpCheckConfig.ExpectedBody, err)
+			return nil
+		}
+	}
+
 	log.DefaultLogger.Infof("[upstream] [health check] [httpdial session]  create a health check success for %s", uri.String())
 	return httpDial
 }
@@ -158,6 +180,19 @@ func (s *HTTPDialSession) verifyCode(code int) bool {
 	return false
 }
 
+// verifyBody returns true if the expected body is not configured,
+// or the response body matches the expected body.
+func (s *HTTPDialSession) verifyBody(body io.Reader) bool {
+	if s.expectedBody == nil {
+		return true
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxCheckBodySize))
+	if err != nil {
+		return false
+	}
+	return s.expectedBody.Match(data)
+}
+
 func (s *HTTPDialSession) CheckHealth() bool {
 	// default dial timeout, maybe already timeout by checker
 	resp, err := s.client.Do(s.request)
@@ -170,6 +205,8 @@ func (s *HTTPDialSession) CheckHealth() bool {
 	result := s.verifyCode(resp.StatusCode)
 	if !result {
 		log.DefaultLogger.Errorf("[upstream] [health check] [httpdial session] http check for host %s failed, statuscode: %+v", s.request.URL.String(), resp.StatusCode)
+	} else if result = s.verifyBody(resp.Body); !result {
+		log.DefaultLogger.Errorf("[upstream] [health check] [httpdial session] http check for host %s failed, body does not match %s", s.request.URL.String(), s.expectedBody.String())
 	} else {
 		if log.DefaultLogger.GetLogLevel() > log.DEBUG {
 			log.DefaultLogger.Debugf("[upstream] [health check] [httpdial session] http check for host %s succeed", s.request.URL.String())
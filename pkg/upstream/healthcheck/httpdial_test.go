@@ -19,6 +19,7 @@ package healthcheck
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -263,3 +264,107 @@ func Test_CheckL7Health(t *testing.T) {
 
 	server.Close()
 }
+
+func Test_CheckHealthExpectedBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("X-Health-Token") != "token" {
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch request.URL.Path {
+		case "/up":
+			writer.Write([]byte(`{"status":"UP"}`))
+		case "/down":
+			writer.Write([]byte(`{"status":"DOWN"}`))
+		case "/error":
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			writer.Write([]byte(`{"status":"UP"}`))
+		}
+	}))
+	defer server.Close()
+	headers := map[string]string{"X-Health-Token": "token"}
+
+	testCases := []struct {
+		name   string
+		config HttpCheckConfig
+		expect bool
+	}{
+		{
+			name: "status and body matched",
+			config: HttpCheckConfig{
+				Path:         "/up",
+				Method:       "GET",
+				Headers:      headers,
+				ExpectedBody: `"status":\s*"UP"`,
+			},
+			expect: true,
+		},
+		{
+			name: "status matched without body config",
+			config: HttpCheckConfig{
+				Path:    "/down",
+				Headers: headers,
+			},
+			expect: true,
+		},
+		{
+			name: "body not matched",
+			config: HttpCheckConfig{
+				Path:         "/down",
+				Headers:      headers,
+				ExpectedBody: `"status":\s*"UP"`,
+			},
+			expect: false,
+		},
+		{
+			name: "status not matched",
+			config: HttpCheckConfig{
+				Path:         "/error",
+				Headers:      headers,
+				ExpectedBody: `"status":\s*"UP"`,
+			},
+			expect: false,
+		},
+		{
+			name: "status in expected codes",
+			config: HttpCheckConfig{
+				Path:         "/error",
+				Headers:      headers,
+				Codes:        []CodeRange{{Start: 200, End: 200}, {Start: 503, End: 503}},
+				ExpectedBody: `"status":\s*"UP"`,
+			},
+			expect: true,
+		},
+		{
+			name: "missing headers",
+			config: HttpCheckConfig{
+				Path:         "/up",
+				ExpectedBody: `"status":\s*"UP"`,
+			},
+			expect: false,
+		},
+	}
+
+	hdsf := &HTTPDialSessionFactory{}
+	h := &mockHost{addr: server.Listener.Addr().String()}
+	for _, tc := range testCases {
+		cfg := map[string]interface{}{
+			HTTPCheckConfigKey: &tc.config,
+		}
+		hds := hdsf.NewSession(cfg, h).(*HTTPDialSession)
+		if hds.CheckHealth() != tc.expect {
+			t.Errorf("Test_CheckHealthExpectedBody Error, case:%s", tc.name)
+		}
+	}
+
+	// invalid expected body
+	cfg := map[string]interface{}{
+		HTTPCheckConfigKey: &HttpCheckConfig{
+			Path:         "/up",
+			ExpectedBody: "[",
+		},
+	}
+	if hcs := hdsf.NewSession(cfg, h); hcs != nil {
+		t.Errorf("Test_CheckHealthExpectedBody invalid expected body should be failed")
+	}
+}
@@ -85,6 +85,8 @@ type mockHost struct {
 	types.Host
 	addr string
 	flag uint64
+	// the address is inherited from the host before the cluster update
+	inherited bool
 	// mock status
 	delay  time.Duration
 	lock   sync.Mutex
@@ -104,10 +106,18 @@ func (h *mockHost) Health() bool {
 	return health
 }
 
+func (h *mockHost) NewAddress() bool {
+	return !h.inherited
+}
+
 func (h *mockHost) AddressString() string {
 	return h.addr
 }
 
+func (h *mockHost) ClusterInfo() types.ClusterInfo {
+	return nil
+}
+
 func (h *mockHost) ClearHealthFlag(flag api.HealthFlag) {
 	h.flag &= ^uint64(flag)
 }
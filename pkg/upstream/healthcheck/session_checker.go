@@ -18,6 +18,7 @@
 package healthcheck
 
 import (
+	"io"
 	"runtime/debug"
 	"sync/atomic"
 	"time"
@@ -118,17 +119,21 @@ func (c *sessionChecker) Start() {
 
 func (c *sessionChecker) Stop() {
 	close(c.stop)
+	// release the resources held by the session, such as the connections
+	if closer, ok := c.Session.(io.Closer); ok {
+		closer.Close()
+	}
 }
 
 func (c *sessionChecker) HandleSuccess() {
 	c.unHealthCount = 0
 	changed := false
-	if c.Host.ContainHealthFlag(api.FAILED_ACTIVE_HC) {
+	if c.Host.ContainHealthFlag(api.FAILED_ACTIVE_HC | types.PENDING_ACTIVE_HC) {
 		c.healthCount++
 		// check the threshold
 		if c.healthCount == c.HealthChecker.healthyThreshold {
 			changed = true
-			c.Host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+			c.Host.ClearHealthFlag(api.FAILED_ACTIVE_HC | types.PENDING_ACTIVE_HC)
 		}
 	}
 	c.HealthChecker.incHealthy(c.Host, changed)
@@ -137,7 +142,8 @@ func (c *sessionChecker) HandleSuccess() {
 func (c *sessionChecker) HandleFailure(reason types.FailureType) {
 	c.healthCount = 0
 	changed := false
-	if !c.Host.ContainHealthFlag(api.FAILED_ACTIVE_HC) {
+	// the pending host keeps pending until it passes the consecutive checks
+	if !c.Host.ContainHealthFlag(api.FAILED_ACTIVE_HC | types.PENDING_ACTIVE_HC) {
 		c.unHealthCount++
 		// check the threshold
 		if c.unHealthCount == c.HealthChecker.unhealthyThreshold {
@@ -0,0 +1,130 @@
+package integrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/test/util"
+	"mosn.io/mosn/test/util/mosn"
+)
+
+const (
+	connectAllowedDestination = "echo.example.com:443"
+	connectDeniedDestination  = "other.example.com:443"
+)
+
+// echoServe writes back the bytes received
+func echoServe(t *testing.T, conn net.Conn) {
+	io.Copy(conn, conn)
+}
+
+type ConnectCase struct {
+	*TestCase
+}
+
+func NewConnectCase(t *testing.T) *ConnectCase {
+	return &ConnectCase{
+		TestCase: NewTestCase(t, protocol.HTTP1, protocol.HTTP1, util.NewUpstreamServer(t, "127.0.0.1:8080", echoServe)),
+	}
+}
+
+func (c *ConnectCase) Start() {
+	c.AppServer.GoServe()
+	appAddr := c.AppServer.Addr()
+	clientMeshAddr := util.CurrentMeshAddr()
+	c.ClientMeshAddr = clientMeshAddr
+	cfg := util.CreateProxyMesh(clientMeshAddr, []string{appAddr}, c.AppProtocol)
+	// enable the CONNECT tunneling on the proxy filter
+	proxyConfig := cfg.Servers[0].Listeners[0].FilterChains[0].Filters[0].Config
+	proxyConfig["http_connect"] = map[string]interface{}{
+		"allowed_destinations": []interface{}{connectAllowedDestination},
+	}
+	mesh := mosn.NewMosn(cfg)
+	go mesh.Start()
+	go func() {
+		<-c.Finish
+		c.AppServer.Close()
+		mesh.Close()
+		c.Finish <- true
+	}()
+	time.Sleep(5 * time.Second) //wait server and mesh start
+}
+
+func (c *ConnectCase) connect(destination string) (net.Conn, *bufio.Reader, int, error) {
+	conn, err := net.DialTimeout("tcp", c.ClientMeshAddr, time.Second)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", destination, destination)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, nil, 0, err
+	}
+	return conn, br, resp.StatusCode, nil
+}
+
+func (c *ConnectCase) RunCase() {
+	// the allowed destination is tunneled
+	conn, br, code, err := c.connect(connectAllowedDestination)
+	if err != nil {
+		c.C <- fmt.Errorf("CONNECT %s failed: %v", connectAllowedDestination, err)
+		return
+	}
+	defer conn.Close()
+	if code != http.StatusOK {
+		c.C <- fmt.Errorf("CONNECT %s response status: %d", connectAllowedDestination, code)
+		return
+	}
+	for _, msg := range []string{"hello", "tunnel bytes"} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			c.C <- fmt.Errorf("write to tunnel failed: %v", err)
+			return
+		}
+		echo := make([]byte, len(msg))
+		if _, err := io.ReadFull(br, echo); err != nil {
+			c.C <- fmt.Errorf("read from tunnel failed: %v", err)
+			return
+		}
+		if string(echo) != msg {
+			c.C <- fmt.Errorf("unexpected tunnel bytes: %s", echo)
+			return
+		}
+	}
+
+	// the other destination is denied
+	deniedConn, _, code, err := c.connect(connectDeniedDestination)
+	if err != nil {
+		c.C <- fmt.Errorf("CONNECT %s failed: %v", connectDeniedDestination, err)
+		return
+	}
+	deniedConn.Close()
+	if code != http.StatusForbidden {
+		c.C <- fmt.Errorf("CONNECT %s response status: %d, expected: %d", connectDeniedDestination, code, http.StatusForbidden)
+		return
+	}
+	c.C <- nil
+}
+
+func TestConnectProxy(t *testing.T) {
+	tc := NewConnectCase(t)
+	tc.Start()
+	go tc.RunCase()
+	select {
+	case err := <-tc.C:
+		if err != nil {
+			t.Errorf("[ERROR MESSAGE] CONNECT proxy test failed, error: %v\n", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Error("[ERROR MESSAGE] CONNECT proxy hang\n")
+	}
+	tc.FinishCase()
+}
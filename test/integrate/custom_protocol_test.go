@@ -0,0 +1,324 @@
+package integrate
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/header"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol"
+	"mosn.io/mosn/pkg/types"
+	testutil "mosn.io/mosn/test/util"
+	"mosn.io/mosn/test/util/mosn"
+)
+
+/**
+ * toy protocol, a length prefixed frame
+ * 0     1     2     3           5           7           9          11
+ * +-----+-----+-----+-----+-----+-----+-----+-----+-----+-----+-----+----------------+
+ * |magic| type|status|       requestId       |     payloadLength     | payload bytes  |
+ * +-----+-----+-----+-----+-----+-----+-----+-----+-----+-----+-----+----------------+
+ */
+const (
+	toyProtocolName api.ProtocolName = "toy"
+	toyMagic        byte             = 'T'
+	toyHeaderLen    int              = 11
+
+	toyTypeRequest      byte = 0
+	toyTypeResponse     byte = 1
+	toyTypeHeartbeat    byte = 2
+	toyTypeHeartbeatAck byte = 3
+)
+
+type toyFrame struct {
+	header.CommonHeader
+	typ       byte
+	status    byte
+	requestID uint32
+	data      api.IoBuffer
+}
+
+func (f *toyFrame) GetRequestId() uint64 {
+	return uint64(f.requestID)
+}
+
+func (f *toyFrame) SetRequestId(id uint64) {
+	f.requestID = uint32(id)
+}
+
+func (f *toyFrame) IsHeartbeatFrame() bool {
+	return f.typ == toyTypeHeartbeat || f.typ == toyTypeHeartbeatAck
+}
+
+func (f *toyFrame) GetTimeout() int32 {
+	return 0
+}
+
+func (f *toyFrame) GetStreamType() api.StreamType {
+	if f.typ == toyTypeResponse || f.typ == toyTypeHeartbeatAck {
+		return api.Response
+	}
+	return api.Request
+}
+
+func (f *toyFrame) GetHeader() api.HeaderMap {
+	return f
+}
+
+func (f *toyFrame) GetData() api.IoBuffer {
+	return f.data
+}
+
+func (f *toyFrame) SetData(data api.IoBuffer) {
+	f.data = data
+}
+
+func (f *toyFrame) GetStatusCode() uint32 {
+	return uint32(f.status)
+}
+
+type toyProtocol struct{}
+
+func (p *toyProtocol) Name() api.ProtocolName {
+	return toyProtocolName
+}
+
+func (p *toyProtocol) Encode(ctx context.Context, model interface{}) (api.IoBuffer, error) {
+	frame, ok := model.(*toyFrame)
+	if !ok {
+		return nil, errors.New("unknown toy frame")
+	}
+	var payload []byte
+	if frame.data != nil {
+		payload = frame.data.Bytes()
+	}
+	buf := buffer.GetIoBuffer(toyHeaderLen + len(payload))
+	buf.WriteByte(toyMagic)
+	buf.WriteByte(frame.typ)
+	buf.WriteByte(frame.status)
+	buf.WriteUint32(frame.requestID)
+	buf.WriteUint32(uint32(len(payload)))
+	buf.Write(payload)
+	return buf, nil
+}
+
+func (p *toyProtocol) Decode(ctx context.Context, data api.IoBuffer) (interface{}, error) {
+	if data.Len() < toyHeaderLen {
+		return nil, nil
+	}
+	b := data.Bytes()
+	if b[0] != toyMagic {
+		return nil, errors.New("toy magic error")
+	}
+	frameLen := toyHeaderLen + int(binary.BigEndian.Uint32(b[7:]))
+	if data.Len() < frameLen {
+		return nil, nil
+	}
+	payload := make([]byte, frameLen-toyHeaderLen)
+	copy(payload, b[toyHeaderLen:frameLen])
+	frame := &toyFrame{
+		CommonHeader: header.CommonHeader{},
+		typ:          b[1],
+		status:       b[2],
+		requestID:    binary.BigEndian.Uint32(b[3:]),
+		data:         buffer.NewIoBufferBytes(payload),
+	}
+	data.Drain(frameLen)
+	return frame, nil
+}
+
+func (p *toyProtocol) Trigger(ctx context.Context, requestId uint64) api.XFrame {
+	return &toyFrame{typ: toyTypeHeartbeat, requestID: uint32(requestId)}
+}
+
+func (p *toyProtocol) Reply(ctx context.Context, request api.XFrame) api.XRespFrame {
+	return &toyFrame{typ: toyTypeHeartbeatAck, requestID: uint32(request.GetRequestId())}
+}
+
+func (p *toyProtocol) Hijack(ctx context.Context, request api.XFrame, statusCode uint32) api.XRespFrame {
+	return &toyFrame{typ: toyTypeResponse, status: byte(statusCode), requestID: uint32(request.GetRequestId())}
+}
+
+func (p *toyProtocol) Mapping(httpStatusCode uint32) uint32 {
+	return httpStatusCode
+}
+
+func (p *toyProtocol) PoolMode() api.PoolMode {
+	return api.Multiplex
+}
+
+func (p *toyProtocol) EnableWorkerPool() bool {
+	return true
+}
+
+func (p *toyProtocol) GenerateRequestID(streamID *uint64) uint64 {
+	return atomic.AddUint64(streamID, 1)
+}
+
+// Match implements types.ProtocolMatcher
+func (p *toyProtocol) Match(data []byte) api.MatchResult {
+	if len(data) == 0 {
+		return api.MatchAgain
+	}
+	if data[0] == toyMagic {
+		return api.MatchSuccess
+	}
+	return api.MatchFailed
+}
+
+// readToyFrame reads a frame from the connection
+func readToyFrame(conn net.Conn, buf api.IoBuffer) (*toyFrame, error) {
+	p := &toyProtocol{}
+	for {
+		frame, err := p.Decode(context.Background(), buf)
+		if err != nil {
+			return nil, err
+		}
+		if frame != nil {
+			return frame.(*toyFrame), nil
+		}
+		b := make([]byte, 1024)
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b[:n])
+	}
+}
+
+func writeToyFrame(conn net.Conn, frame *toyFrame) error {
+	buf, _ := (&toyProtocol{}).Encode(context.Background(), frame)
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+// serveToy echoes the toy requests
+func serveToy(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			buf := buffer.NewIoBuffer(1024)
+			for {
+				frame, err := readToyFrame(conn, buf)
+				if err != nil {
+					return
+				}
+				resp := &toyFrame{typ: toyTypeResponse, requestID: frame.requestID}
+				if frame.typ == toyTypeHeartbeat {
+					resp.typ = toyTypeHeartbeatAck
+				} else {
+					resp.data = buffer.NewIoBufferString("echo: " + frame.data.String())
+				}
+				if err := writeToyFrame(conn, resp); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+func TestCustomProtocol(t *testing.T) {
+	if err := xprotocol.RegisterProtocol(toyProtocolName, &toyProtocol{}); err != nil {
+		t.Fatalf("register toy protocol failed: %v", err)
+	}
+	if err := xprotocol.RegisterProtocol(toyProtocolName, &toyProtocol{}); err == nil {
+		t.Fatal("register duplicate protocol should be failed")
+	}
+	if err := xprotocol.RegisterProtocol("other", &toyProtocol{}); err == nil {
+		t.Fatal("register protocol with mismatched name should be failed")
+	}
+	// the registered codec is detected by the auto protocol
+	factory, ok := protocol.GetProtocolStreamFactory(toyProtocolName)
+	if !ok {
+		t.Fatal("toy protocol stream factory is not registered")
+	}
+	if err := factory.ProtocolMatch(context.Background(), string(toyProtocolName), []byte{toyMagic}); err != nil {
+		t.Fatalf("toy protocol is not matched: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go serveToy(ln)
+
+	// the route matches all the requests
+	router := v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Match: v2.RouterMatch{
+				Variables: []v2.VariableMatcher{{Name: types.VarHeaderRPCService, Regex: ".*"}},
+			},
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName: "toyCluster",
+				},
+			},
+		},
+	}
+	meshAddr := testutil.CurrentMeshAddr()
+	cfg := testutil.NewMOSNConfig([]v2.Listener{
+		testutil.NewListener("toyListener", meshAddr, []v2.FilterChain{
+			testutil.NewFilterChain("toyRouter", toyProtocolName, toyProtocolName, []v2.Router{router}),
+		}),
+	}, v2.ClusterManagerConfig{
+		Clusters: []v2.Cluster{
+			testutil.NewBasicCluster("toyCluster", []string{ln.Addr().String()}),
+		},
+	})
+	mesh := mosn.NewMosn(cfg)
+	go mesh.Start()
+	defer mesh.Close()
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", meshAddr); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("dial mosn failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	buf := buffer.NewIoBuffer(1024)
+
+	// the request is proxied to the upstream
+	for id := uint32(1); id <= 3; id++ {
+		if err := writeToyFrame(conn, &toyFrame{typ: toyTypeRequest, requestID: id, data: buffer.NewIoBufferString("hello")}); err != nil {
+			t.Fatalf("write request failed: %v", err)
+		}
+		resp, err := readToyFrame(conn, buf)
+		if err != nil {
+			t.Fatalf("read response failed: %v", err)
+		}
+		if resp.typ != toyTypeResponse || resp.requestID != id || resp.data.String() != "echo: hello" {
+			t.Fatalf("unexpected response: %+v, %s", resp, resp.data)
+		}
+	}
+	// the heartbeat is replied by mosn
+	if err := writeToyFrame(conn, &toyFrame{typ: toyTypeHeartbeat, requestID: 10}); err != nil {
+		t.Fatalf("write heartbeat failed: %v", err)
+	}
+	ack, err := readToyFrame(conn, buf)
+	if err != nil {
+		t.Fatalf("read heartbeat ack failed: %v", err)
+	}
+	if ack.typ != toyTypeHeartbeatAck || ack.requestID != 10 {
+		t.Fatalf("unexpected heartbeat ack: %+v", ack)
+	}
+}
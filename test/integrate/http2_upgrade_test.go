@@ -0,0 +1,154 @@
+package integrate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/test/util"
+	"mosn.io/mosn/test/util/mosn"
+)
+
+// h2Handler checks the request is upgraded to http2, and echoes the request body
+type h2Handler struct{}
+
+func (h *h2Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		w.WriteHeader(http.StatusHTTPVersionNotSupported)
+		return
+	}
+	// hop-by-hop headers should be removed
+	for _, key := range []string{"Connection", "Transfer-Encoding", "Expect", "Keep-Alive", "Upgrade"} {
+		if v := r.Header.Get(key); v != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unexpected header %s: %s", key, v)
+			return
+		}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Upstream-Proto", r.Proto)
+	w.Header().Set("X-Request-Header", r.Header.Get("X-Request-Header"))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+}
+
+type HTTP2UpgradeCase struct {
+	*TestCase
+}
+
+func NewHTTP2UpgradeCase(t *testing.T) *HTTP2UpgradeCase {
+	appaddr := "127.0.0.1:8080"
+	return &HTTP2UpgradeCase{
+		TestCase: NewTestCase(t, protocol.HTTP1, protocol.HTTP2, util.NewUpstreamHTTP2(t, appaddr, &h2Handler{})),
+	}
+}
+
+// http1 client - mesh - http2 server
+func (c *HTTP2UpgradeCase) StartProxy() {
+	c.AppServer.GoServe()
+	appAddr := c.AppServer.Addr()
+	clientMeshAddr := util.CurrentMeshAddr()
+	c.ClientMeshAddr = clientMeshAddr
+	cfg := util.CreateProxyMesh(clientMeshAddr, []string{appAddr}, c.AppProtocol)
+	// force the upstream to use h2c
+	cfg.ClusterManager.Clusters[0].HTTP2Upgrade = true
+	mesh := mosn.NewMosn(cfg)
+	go mesh.Start()
+	go func() {
+		<-c.Finish
+		c.AppServer.Close()
+		mesh.Close()
+		c.Finish <- true
+	}()
+	time.Sleep(5 * time.Second) //wait server and mesh start
+}
+
+func (c *HTTP2UpgradeCase) check(req *http.Request, expectedBody string) error {
+	req.Header.Set("X-Request-Header", "upgrade")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("response status: %d, body: %s", resp.StatusCode, string(b))
+	}
+	if proto := resp.Header.Get("X-Upstream-Proto"); proto != "HTTP/2.0" {
+		return fmt.Errorf("upstream protocol is %s", proto)
+	}
+	if h := resp.Header.Get("X-Request-Header"); h != "upgrade" {
+		return fmt.Errorf("request header is not translated, got: %s", h)
+	}
+	if string(b) != expectedBody {
+		return fmt.Errorf("response body is %s, expected: %s", string(b), expectedBody)
+	}
+	return nil
+}
+
+func (c *HTTP2UpgradeCase) RunCase() {
+	url := fmt.Sprintf("http://%s/%s", c.ClientMeshAddr, HTTPTestPath)
+	body := strings.Repeat("upgrade body ", 1024)
+	cases := map[string]func() error{
+		"get": func() error {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			return c.check(req, "")
+		},
+		"post": func() error {
+			req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+			return c.check(req, body)
+		},
+		"chunked": func() error {
+			// unknown content length makes the client use chunked encoding
+			pr, pw := io.Pipe()
+			go func() {
+				for i := 0; i < 4; i++ {
+					pw.Write([]byte(body[:len(body)/4]))
+				}
+				pw.Close()
+			}()
+			req, _ := http.NewRequest(http.MethodPost, url, pr)
+			return c.check(req, strings.Repeat(body[:len(body)/4], 4))
+		},
+		"100-continue": func() error {
+			req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+			req.Header.Set("Expect", "100-continue")
+			return c.check(req, body)
+		},
+	}
+	for name, call := range cases {
+		if err := call(); err != nil {
+			c.C <- fmt.Errorf("case %s failed: %v", name, err)
+			return
+		}
+	}
+	c.C <- nil
+}
+
+func TestHTTP2Upgrade(t *testing.T) {
+	tc := NewHTTP2UpgradeCase(t)
+	tc.StartProxy()
+	go tc.RunCase()
+	select {
+	case err := <-tc.C:
+		if err != nil {
+			t.Errorf("[ERROR MESSAGE] http1 to http2 upgrade test failed, error: %v\n", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Error("[ERROR MESSAGE] http1 to http2 upgrade hang\n")
+	}
+	tc.FinishCase()
+}
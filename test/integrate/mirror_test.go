@@ -2,7 +2,9 @@ package integrate
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -165,3 +167,101 @@ func TestMirror(t *testing.T) {
 		}
 	}
 }
+
+// shadowHandler counts the mirrored requests, and fails slowly
+type shadowHandler struct {
+	ReqCnt int32
+	Delay  time.Duration
+}
+
+func (h *shadowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&h.ReqCnt, 1)
+	time.Sleep(h.Delay)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+type primaryHandler struct{}
+
+func (h *primaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("primary"))
+}
+
+func createMirrorPercentProxyMesh(addr, primary, shadow string, percent uint32) *v2.MOSNConfig {
+	cmconfig := v2.ClusterManagerConfig{
+		Clusters: []v2.Cluster{
+			util.NewBasicCluster("primary", []string{primary}),
+			util.NewBasicCluster("shadow", []string{shadow}),
+		},
+	}
+	routers := []v2.Router{
+		{
+			RouterConfig: v2.RouterConfig{
+				Match: v2.RouterMatch{
+					Prefix: "/",
+				},
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{
+						ClusterName: "primary",
+					},
+				},
+				RequestMirrorPolicies: &v2.RequestMirrorPolicy{
+					Cluster: "shadow",
+					Percent: percent,
+				},
+			},
+		},
+	}
+	chains := []v2.FilterChain{
+		util.NewFilterChain("proxyVirtualHost", protocol.HTTP1, protocol.HTTP1, routers),
+	}
+	listener := util.NewListener("proxyListener", addr, chains)
+	listener.StreamFilters = []v2.Filter{
+		{
+			Type: "mirror",
+		},
+	}
+	return util.NewMOSNConfig([]v2.Listener{listener}, cmconfig)
+}
+
+func TestMirrorPercent(t *testing.T) {
+	shadow := &shadowHandler{Delay: 100 * time.Millisecond}
+	primaryServer := util.NewHTTPServer(t, &primaryHandler{})
+	shadowServer := util.NewHTTPServer(t, shadow)
+	primaryServer.GoServe()
+	shadowServer.GoServe()
+	defer primaryServer.Close()
+	defer shadowServer.Close()
+
+	meshAddr := util.CurrentMeshAddr()
+	mesh := mosn.NewMosn(createMirrorPercentProxyMesh(meshAddr, primaryServer.Addr(), shadowServer.Addr(), 50))
+	go mesh.Start()
+	defer mesh.Close()
+	time.Sleep(5 * time.Second) //wait server and mesh start
+
+	total := 200
+	start := time.Now()
+	for i := 0; i < total; i++ {
+		resp, err := http.Get(fmt.Sprintf("http://%s/", meshAddr))
+		if err != nil {
+			t.Fatalf("request #%d failed: %v", i, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		// the failure of the shadow cluster is not propagated to the client
+		if resp.StatusCode != http.StatusOK || string(body) != "primary" {
+			t.Fatalf("request #%d got unexpected response, status: %d, body: %s", i, resp.StatusCode, string(body))
+		}
+	}
+	// the mirror requests are sent asynchronously, half of the requests waiting
+	// for the shadow cluster would cost 10s at least
+	if cost := time.Since(start); cost > 5*time.Second {
+		t.Errorf("mirror adds latency to the primary requests, cost: %v", cost)
+	}
+
+	time.Sleep(time.Second) // wait the mirror requests finish
+	cnt := int(atomic.LoadInt32(&shadow.ReqCnt))
+	if cnt < total*30/100 || cnt > total*70/100 {
+		t.Errorf("shadow cluster receives %d requests of %d, expected about 50%%", cnt, total)
+	}
+}
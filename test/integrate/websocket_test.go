@@ -0,0 +1,207 @@
+package integrate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/test/util"
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// writeWebsocketFrame writes a final text frame, the client frame is masked
+func writeWebsocketFrame(w io.Writer, payload []byte, masked bool) error {
+	frame := []byte{0x81}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	default:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+	data := append([]byte{}, payload...)
+	if masked {
+		key := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, key...)
+		for i := range data {
+			data[i] ^= key[i%4]
+		}
+	}
+	_, err := w.Write(append(frame, data...))
+	return err
+}
+
+// readWebsocketFrame reads a frame written by writeWebsocketFrame and returns the unmasked payload
+func readWebsocketFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return nil, err
+		}
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+	var key []byte
+	if header[1]&0x80 != 0 {
+		key = make([]byte, 4)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if key != nil {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return payload, nil
+}
+
+// websocketHandler accepts the websocket handshake, greets the client and echoes the frames
+type websocketHandler struct{}
+
+func (h *websocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		websocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+	// the server sends a frame before the client
+	if err := writeWebsocketFrame(rw, []byte("hello"), false); err != nil {
+		return
+	}
+	if err := rw.Flush(); err != nil {
+		return
+	}
+	for {
+		payload, err := readWebsocketFrame(rw)
+		if err != nil {
+			return
+		}
+		if err := writeWebsocketFrame(rw, append([]byte("echo: "), payload...), false); err != nil {
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+type WebsocketCase struct {
+	*TestCase
+}
+
+func NewWebsocketCase(t *testing.T) *WebsocketCase {
+	return &WebsocketCase{
+		TestCase: NewTestCase(t, protocol.HTTP1, protocol.HTTP1, util.NewHTTPServer(t, &websocketHandler{})),
+	}
+}
+
+func (c *WebsocketCase) handshake() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", c.ClientMeshAddr, time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString([]byte("mosn websocket!!"))
+	fmt.Fprintf(conn, "GET /%s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		HTTPTestPath, c.ClientMeshAddr, key)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, nil, fmt.Errorf("response status: %d", resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != websocketAccept(key) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("unexpected accept key: %s", accept)
+	}
+	return conn, br, nil
+}
+
+func (c *WebsocketCase) RunCase() {
+	conn, br, err := c.handshake()
+	if err != nil {
+		c.C <- fmt.Errorf("websocket handshake failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// upstream to downstream
+	payload, err := readWebsocketFrame(br)
+	if err != nil || string(payload) != "hello" {
+		c.C <- fmt.Errorf("read greeting frame failed, payload: %s, error: %v", payload, err)
+		return
+	}
+	// downstream to upstream and back
+	messages := []string{"ping", strings.Repeat("large message ", 100)}
+	for _, msg := range messages {
+		if err := writeWebsocketFrame(conn, []byte(msg), true); err != nil {
+			c.C <- fmt.Errorf("write frame failed: %v", err)
+			return
+		}
+		payload, err := readWebsocketFrame(br)
+		if err != nil {
+			c.C <- fmt.Errorf("read echo frame failed: %v", err)
+			return
+		}
+		if !bytes.Equal(payload, []byte("echo: "+msg)) {
+			c.C <- fmt.Errorf("unexpected echo frame: %s", payload)
+			return
+		}
+	}
+	c.C <- nil
+}
+
+func TestWebsocketProxy(t *testing.T) {
+	tc := NewWebsocketCase(t)
+	tc.StartProxy()
+	go tc.RunCase()
+	select {
+	case err := <-tc.C:
+		if err != nil {
+			t.Errorf("[ERROR MESSAGE] websocket proxy test failed, error: %v\n", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Error("[ERROR MESSAGE] websocket proxy hang\n")
+	}
+	tc.FinishCase()
+}